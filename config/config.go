@@ -17,6 +17,7 @@ package config
 import (
 	"bytes"
 	"errors"
+	"fmt"
 	"reflect"
 
 	"github.com/coreos/ignition/config/types"
@@ -38,22 +39,45 @@ var (
 	ErrInvalid     = errors.New("config is not valid")
 )
 
+// translations maps each older spec version that Parse accepts to the parser
+// that translates configs of that version to the latest spec. Supporting a
+// new older version only requires its package and an entry here.
+var translations = map[types.IgnitionVersion]func([]byte) (types.Config, report.Report, error){
+	{Major: 1}:           parseDeprecatedV1,
+	{Major: 2, Minor: 0}: ParseFromV2_0,
+}
+
 // Parse parses the raw config into a types.Config struct and generates a report of any
-// errors, warnings, info, and deprecations it encountered
+// errors, warnings, info, and deprecations it encountered. Configs of an older
+// supported version are translated to the latest version; those of any other
+// version are parsed as the latest, which rejects versions it doesn't support.
 func Parse(rawConfig []byte) (types.Config, report.Report, error) {
-	switch version(rawConfig) {
-	case types.IgnitionVersion{Major: 1}:
-		config, err := ParseFromV1(rawConfig)
-		if err != nil {
-			return types.Config{}, report.ReportFromError(err, report.EntryError), err
-		}
+	if translate, ok := translations[version(rawConfig)]; ok {
+		return translate(rawConfig)
+	}
+	return ParseFromLatest(rawConfig)
+}
+
+// CheckReport returns the error that a parse which returned r and err should
+// fail with, if any. In strict mode, it first promotes the entries of r about
+// unrecognized keys to errors, so that they make the config invalid too.
+func CheckReport(r *report.Report, err error, strict bool) error {
+	if strict {
+		r.Strict()
+	}
+	if err == nil && r.IsFatal() {
+		return ErrInvalid
+	}
+	return err
+}
 
-		return config, report.ReportFromError(ErrDeprecated, report.EntryDeprecated), nil
-	case types.IgnitionVersion{Major: 2, Minor: 0}:
-		return ParseFromV2_0(rawConfig)
-	default:
-		return ParseFromLatest(rawConfig)
+func parseDeprecatedV1(rawConfig []byte) (types.Config, report.Report, error) {
+	config, err := ParseFromV1(rawConfig)
+	if err != nil {
+		return types.Config{}, report.ReportFromError(err, report.EntryError), err
 	}
+
+	return config, report.ReportFromError(ErrDeprecated, report.EntryDeprecated), nil
 }
 
 func ParseFromLatest(rawConfig []byte) (types.Config, report.Report, error) {
@@ -102,6 +126,8 @@ func ParseFromLatest(rawConfig []byte) (types.Config, report.Report, error) {
 					Line:      line,
 					Column:    col,
 					Highlight: highlight,
+					Path:      astjson.RawPointerAt(rawConfig, terr.Offset),
+					Hint:      typeErrorHint(terr),
 				}},
 			},
 			ErrInvalid
@@ -133,6 +159,28 @@ func ParseFromLatest(rawConfig []byte) (types.Config, report.Report, error) {
 	return config, r, nil
 }
 
+// typeErrorHint describes the JSON value that was expected where a type error
+// occurred, since the Go type named in the error means little to users.
+func typeErrorHint(terr *json.UnmarshalTypeError) string {
+	if terr.Type == reflect.TypeOf(types.NodeMode(0)) {
+		return "mode must be a decimal integer, e.g. 420 for 0644"
+	}
+	switch terr.Type.Kind() {
+	case reflect.Bool:
+		return fmt.Sprintf("expected a boolean, found %s", terr.Value)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return fmt.Sprintf("expected an integer, found %s", terr.Value)
+	case reflect.String:
+		return fmt.Sprintf("expected a string, found %s", terr.Value)
+	case reflect.Slice, reflect.Array:
+		return fmt.Sprintf("expected an array, found %s", terr.Value)
+	case reflect.Struct, reflect.Map:
+		return fmt.Sprintf("expected an object, found %s", terr.Value)
+	}
+	return ""
+}
+
 func ParseFromV1(rawConfig []byte) (types.Config, error) {
 	config, err := v1.Parse(rawConfig)
 	if err != nil {
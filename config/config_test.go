@@ -20,7 +20,6 @@ import (
 
 	"github.com/coreos/ignition/config/types"
 	v1 "github.com/coreos/ignition/config/v1"
-	v2_0 "github.com/coreos/ignition/config/v2_0/types"
 )
 
 func TestParse(t *testing.T) {
@@ -38,7 +37,7 @@ func TestParse(t *testing.T) {
 	}{
 		{
 			in:  in{config: []byte(`{"ignitionVersion": 1}`)},
-			out: out{config: types.Config{Ignition: types.Ignition{Version: types.IgnitionVersion(v2_0.MaxVersion)}}},
+			out: out{config: types.Config{Ignition: types.Ignition{Version: types.IgnitionVersion(types.MaxVersion)}}},
 		},
 		{
 			in:  in{config: []byte(`{"ignition": {"version": "1.0.0"}}`)},
@@ -105,3 +104,88 @@ func TestParse(t *testing.T) {
 		}
 	}
 }
+
+func TestStrict(t *testing.T) {
+	type in struct {
+		config []byte
+	}
+	type out struct {
+		lenient bool
+		strict  bool
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{config: []byte(`{"ignition": {"version": "2.1.0-experimental"}}`)},
+			out: out{},
+		},
+		{
+			in:  in{config: []byte(`{"ignition": {"version": "2.1.0-experimental"}, "storgae": {}}`)},
+			out: out{strict: true},
+		},
+		{
+			in:  in{config: []byte(`{"ignition": {"version": "2.1.0-experimental"}, "storage": {"files": [{"filesystem": "root", "path": "/a", "mdoe": 420}]}}`)},
+			out: out{strict: true},
+		},
+		{
+			in:  in{config: []byte(`{"ignition": {"version": "2.0.0"}, "systemd": {"untis": []}}`)},
+			out: out{strict: true},
+		},
+	}
+
+	for i, test := range tests {
+		_, r, err := Parse(test.in.config)
+		if err != nil {
+			t.Errorf("#%d: parse failed: %v", i, err)
+			continue
+		}
+		if fatal := r.IsFatal(); fatal != test.out.lenient {
+			t.Errorf("#%d: bad lenient result: want fatal %v, got %v", i, test.out.lenient, fatal)
+		}
+		r.Strict()
+		if fatal := r.IsFatal(); fatal != test.out.strict {
+			t.Errorf("#%d: bad strict result: want fatal %v, got %v", i, test.out.strict, fatal)
+		}
+	}
+}
+
+func TestParseTypeErrorPath(t *testing.T) {
+	type in struct {
+		config []byte
+	}
+	type out struct {
+		path string
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{config: []byte(`{"ignition": {"version": "2.1.0-experimental"}, "storage": {"files": [{"filesystem": "root", "path": "/a", "mode": "420"}]}}`)},
+			out: out{path: "/storage/files/0/mode"},
+		},
+		{
+			in:  in{config: []byte(`{"ignition": {"version": "2.1.0-experimental"}, "systemd": {"units": [{"name": "a.service"}, {"name": "b.service", "enable": "yes"}]}}`)},
+			out: out{path: "/systemd/units/1/enable"},
+		},
+		{
+			in:  in{config: []byte(`{"ignition": {"version": "2.0.0"}, "passwd": {"users": {"name": "core"}}}`)},
+			out: out{path: "/passwd/users"},
+		},
+	}
+
+	for i, test := range tests {
+		_, r, err := Parse(test.in.config)
+		if err == nil {
+			t.Errorf("#%d: parse succeeded", i)
+			continue
+		}
+		if len(r.Entries) != 1 || r.Entries[0].Path != test.out.path {
+			t.Errorf("#%d: bad path: want %q, got %v", i, test.out.path, r.Entries)
+		}
+	}
+}
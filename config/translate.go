@@ -24,10 +24,20 @@ import (
 	"github.com/vincent-petithory/dataurl"
 )
 
+// idToPtr returns a pointer to the owner id i, or nil if i is zero. Older
+// specs can't tell an unset owner from root, and leaving it unset lets
+// storage.defaults supply the owner.
+func idToPtr(i int) *int {
+	if i == 0 {
+		return nil
+	}
+	return &i
+}
+
 func TranslateFromV1(old v1.Config) types.Config {
 	config := types.Config{
 		Ignition: types.Ignition{
-			Version: types.IgnitionVersion(v2_0.MaxVersion),
+			Version: types.IgnitionVersion(types.MaxVersion),
 		},
 	}
 
@@ -88,8 +98,8 @@ func TranslateFromV1(old v1.Config) types.Config {
 					Filesystem: filesystem.Name,
 					Path:       types.Path(oldFile.Path),
 					Mode:       types.NodeMode(oldFile.Mode),
-					User:       types.NodeUser{Id: oldFile.Uid},
-					Group:      types.NodeGroup{Id: oldFile.Gid},
+					User:       types.NodeUser{Id: idToPtr(oldFile.Uid)},
+					Group:      types.NodeGroup{Id: idToPtr(oldFile.Gid)},
 				},
 				Contents: types.FileContents{
 					Source: types.Url{
@@ -263,8 +273,8 @@ func TranslateFromV2_0(old v2_0.Config) types.Config {
 				Filesystem: oldFile.Filesystem,
 				Path:       types.Path(oldFile.Path),
 				Mode:       types.NodeMode(oldFile.Mode),
-				User:       types.NodeUser{Id: oldFile.User.Id},
-				Group:      types.NodeGroup{Id: oldFile.Group.Id},
+				User:       types.NodeUser{Id: idToPtr(oldFile.User.Id)},
+				Group:      types.NodeGroup{Id: idToPtr(oldFile.Group.Id)},
 			},
 			Contents: types.FileContents{
 				Compression:  types.Compression(oldFile.Contents.Compression),
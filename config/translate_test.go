@@ -37,7 +37,7 @@ func TestTranslateFromV1(t *testing.T) {
 	}{
 		{
 			in:  in{},
-			out: out{config: types.Config{Ignition: types.Ignition{Version: types.IgnitionVersion(v2_0.MaxVersion)}}},
+			out: out{config: types.Config{Ignition: types.Ignition{Version: types.IgnitionVersion(types.MaxVersion)}}},
 		},
 		{
 			in: in{config: v1.Config{
@@ -124,7 +124,7 @@ func TestTranslateFromV1(t *testing.T) {
 				},
 			}},
 			out: out{config: types.Config{
-				Ignition: types.Ignition{Version: types.IgnitionVersion{Major: 2}},
+				Ignition: types.Ignition{Version: types.IgnitionVersion(types.MaxVersion)},
 				Storage: types.Storage{
 					Disks: []types.Disk{
 						{
@@ -192,8 +192,8 @@ func TestTranslateFromV1(t *testing.T) {
 								Filesystem: "_translate-filesystem-0",
 								Path:       types.Path("/opt/file1"),
 								Mode:       types.NodeMode(0664),
-								User:       types.NodeUser{Id: 500},
-								Group:      types.NodeGroup{Id: 501},
+								User:       types.NodeUser{Id: idToPtr(500)},
+								Group:      types.NodeGroup{Id: idToPtr(501)},
 							},
 							Contents: types.FileContents{
 								Source: types.Url{
@@ -207,8 +207,8 @@ func TestTranslateFromV1(t *testing.T) {
 								Filesystem: "_translate-filesystem-0",
 								Path:       types.Path("/opt/file2"),
 								Mode:       types.NodeMode(0644),
-								User:       types.NodeUser{Id: 502},
-								Group:      types.NodeGroup{Id: 503},
+								User:       types.NodeUser{Id: idToPtr(502)},
+								Group:      types.NodeGroup{Id: idToPtr(503)},
 							},
 							Contents: types.FileContents{
 								Source: types.Url{
@@ -222,8 +222,8 @@ func TestTranslateFromV1(t *testing.T) {
 								Filesystem: "_translate-filesystem-1",
 								Path:       types.Path("/opt/file3"),
 								Mode:       types.NodeMode(0400),
-								User:       types.NodeUser{Id: 1000},
-								Group:      types.NodeGroup{Id: 1001},
+								User:       types.NodeUser{Id: idToPtr(1000)},
+								Group:      types.NodeGroup{Id: idToPtr(1001)},
 							},
 							Contents: types.FileContents{
 								Source: types.Url{
@@ -264,7 +264,7 @@ func TestTranslateFromV1(t *testing.T) {
 				},
 			}},
 			out: out{config: types.Config{
-				Ignition: types.Ignition{Version: types.IgnitionVersion{Major: 2}},
+				Ignition: types.Ignition{Version: types.IgnitionVersion(types.MaxVersion)},
 				Systemd: types.Systemd{
 					Units: []types.SystemdUnit{
 						{
@@ -307,7 +307,7 @@ func TestTranslateFromV1(t *testing.T) {
 				},
 			}},
 			out: out{config: types.Config{
-				Ignition: types.Ignition{Version: types.IgnitionVersion{Major: 2}},
+				Ignition: types.Ignition{Version: types.IgnitionVersion(types.MaxVersion)},
 				Networkd: types.Networkd{
 					Units: []types.NetworkdUnit{
 						{
@@ -370,7 +370,7 @@ func TestTranslateFromV1(t *testing.T) {
 				},
 			}},
 			out: out{config: types.Config{
-				Ignition: types.Ignition{Version: types.IgnitionVersion{Major: 2}},
+				Ignition: types.Ignition{Version: types.IgnitionVersion(types.MaxVersion)},
 				Passwd: types.Passwd{
 					Users: []types.User{
 						{
@@ -705,8 +705,8 @@ func TestTranslateFromV2_0(t *testing.T) {
 								Filesystem: "filesystem-0",
 								Path:       types.Path("/opt/file1"),
 								Mode:       types.NodeMode(0664),
-								User:       types.NodeUser{Id: 500},
-								Group:      types.NodeGroup{Id: 501},
+								User:       types.NodeUser{Id: idToPtr(500)},
+								Group:      types.NodeGroup{Id: idToPtr(501)},
 							},
 							Contents: types.FileContents{
 								Source: types.Url{
@@ -720,8 +720,8 @@ func TestTranslateFromV2_0(t *testing.T) {
 								Filesystem: "filesystem-0",
 								Path:       types.Path("/opt/file2"),
 								Mode:       types.NodeMode(0644),
-								User:       types.NodeUser{Id: 502},
-								Group:      types.NodeGroup{Id: 503},
+								User:       types.NodeUser{Id: idToPtr(502)},
+								Group:      types.NodeGroup{Id: idToPtr(503)},
 							},
 							Contents: types.FileContents{
 								Source: types.Url{
@@ -735,8 +735,8 @@ func TestTranslateFromV2_0(t *testing.T) {
 								Filesystem: "filesystem-1",
 								Path:       types.Path("/opt/file3"),
 								Mode:       types.NodeMode(0400),
-								User:       types.NodeUser{Id: 1000},
-								Group:      types.NodeGroup{Id: 1001},
+								User:       types.NodeUser{Id: idToPtr(1000)},
+								Group:      types.NodeGroup{Id: idToPtr(1001)},
 							},
 							Contents: types.FileContents{
 								Source: types.Url{
@@ -939,3 +939,29 @@ func TestTranslateFromV2_0(t *testing.T) {
 		}
 	}
 }
+
+func TestTranslateUnsetOwner(t *testing.T) {
+	v1Config := TranslateFromV1(v1.Config{
+		Storage: v1.Storage{
+			Filesystems: []v1.Filesystem{{Files: []v1.File{{Path: "/a"}, {Path: "/b", Uid: 500, Gid: 501}}}},
+		},
+	})
+	v2_0Config := TranslateFromV2_0(v2_0.Config{
+		Storage: v2_0.Storage{
+			Files: []v2_0.File{{Path: "/a"}, {Path: "/b", User: v2_0.FileUser{Id: 500}, Group: v2_0.FileGroup{Id: 501}}},
+		},
+	})
+
+	for i, config := range []types.Config{v1Config, v2_0Config} {
+		files := config.Storage.Files
+		if len(files) != 2 {
+			t.Fatalf("#%d: bad files: %+v", i, files)
+		}
+		if files[0].User.Id != nil || files[0].Group.Id != nil {
+			t.Errorf("#%d: bad unset owner: want nil ids, got %v, %v", i, files[0].User.Id, files[0].Group.Id)
+		}
+		if files[1].User.Uid() != 500 || files[1].Group.Gid() != 501 {
+			t.Errorf("#%d: bad owner: want 500:501, got %d:%d", i, files[1].User.Uid(), files[1].Group.Gid())
+		}
+	}
+}
@@ -0,0 +1,109 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"errors"
+	"net/url"
+
+	"github.com/coreos/ignition/config/validate/report"
+)
+
+var (
+	ErrAuthPrefix    = errors.New("auth prefix must be an http or https URL")
+	ErrAuthMethods   = errors.New("exactly one of header, basic, or bearer must be specified")
+	ErrAuthHeader    = errors.New("auth header must have a name")
+	ErrAuthMetadata  = errors.New("bearer metadata must be an http or https URL")
+	ErrAuthNoUser    = errors.New("basic auth requires a username")
+	ErrAuthPlaintext = errors.New("credentials for an http prefix are sent unencrypted")
+)
+
+// UrlAuth declares the credentials used for every fetch from the scheme and
+// host of Prefix whose path lies beneath Prefix's. When several prefixes
+// match, the longest one is used.
+type UrlAuth struct {
+	Prefix string      `json:"prefix,omitempty"`
+	Header *AuthHeader `json:"header,omitempty"`
+	Basic  *BasicAuth  `json:"basic,omitempty"`
+	Bearer *BearerAuth `json:"bearer,omitempty"`
+}
+
+// AuthHeader is a header sent verbatim with each matching request.
+type AuthHeader struct {
+	Name  string `json:"name,omitempty"`
+	Value string `json:"value,omitempty"`
+}
+
+type BasicAuth struct {
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+}
+
+// BearerAuth sends a bearer token fetched from a metadata service, with
+// HttpHeaders, such as the Metadata-Flavor header the GCE metadata service
+// requires. The service may return either the bare token or a JSON object
+// with an access_token field.
+type BearerAuth struct {
+	Metadata    Url         `json:"metadata,omitempty"`
+	HttpHeaders HttpHeaders `json:"httpHeaders,omitempty"`
+}
+
+func (a UrlAuth) Validate() report.Report {
+	r := report.Report{}
+	u, err := url.Parse(a.Prefix)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		r.Add(report.Entry{Message: ErrAuthPrefix.Error(), Kind: report.EntryError})
+	} else if u.Scheme == "http" {
+		r.Add(report.Entry{Message: ErrAuthPlaintext.Error(), Kind: report.EntryWarning})
+	}
+
+	methods := 0
+	if a.Header != nil {
+		methods++
+	}
+	if a.Basic != nil {
+		methods++
+	}
+	if a.Bearer != nil {
+		methods++
+	}
+	if methods != 1 {
+		r.Add(report.Entry{Message: ErrAuthMethods.Error(), Kind: report.EntryError})
+	}
+	return r
+}
+
+func (h AuthHeader) Validate() report.Report {
+	if h.Name == "" {
+		return report.ReportFromError(ErrAuthHeader, report.EntryError)
+	}
+	return report.Report{}
+}
+
+func (b BasicAuth) Validate() report.Report {
+	if b.Username == "" {
+		return report.ReportFromError(ErrAuthNoUser, report.EntryError)
+	}
+	return report.Report{}
+}
+
+func (b BearerAuth) Validate() report.Report {
+	switch url.URL(b.Metadata).Scheme {
+	case "http", "https":
+		return report.Report{}
+	default:
+		return report.ReportFromError(ErrAuthMetadata, report.EntryError)
+	}
+}
@@ -0,0 +1,64 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/coreos/ignition/config/validate/report"
+)
+
+func TestUrlAuthValidate(t *testing.T) {
+	type in struct {
+		auth UrlAuth
+	}
+	type out struct {
+		report report.Report
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{auth: UrlAuth{Prefix: "https://example.com/", Basic: &BasicAuth{Username: "core"}}},
+			out: out{},
+		},
+		{
+			in:  in{auth: UrlAuth{Prefix: "http://example.com/", Header: &AuthHeader{Name: "X-Api-Key"}}},
+			out: out{report: report.ReportFromError(ErrAuthPlaintext, report.EntryWarning)},
+		},
+		{
+			in:  in{auth: UrlAuth{Prefix: "example.com", Basic: &BasicAuth{Username: "core"}}},
+			out: out{report: report.ReportFromError(ErrAuthPrefix, report.EntryError)},
+		},
+		{
+			in:  in{auth: UrlAuth{Prefix: "https://example.com/"}},
+			out: out{report: report.ReportFromError(ErrAuthMethods, report.EntryError)},
+		},
+		{
+			in:  in{auth: UrlAuth{Prefix: "https://example.com/", Basic: &BasicAuth{Username: "core"}, Header: &AuthHeader{Name: "X-Api-Key"}}},
+			out: out{report: report.ReportFromError(ErrAuthMethods, report.EntryError)},
+		},
+	}
+
+	for i, test := range tests {
+		r := test.in.auth.Validate()
+		if !reflect.DeepEqual(test.out.report, r) {
+			t.Errorf("#%d: bad report: want %v, got %v", i, test.out.report, r)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/coreos/ignition/config/validate/report"
+)
+
+const (
+	// PartitionTypeLinuxRaid is the type GUID of Linux RAID member
+	// partitions.
+	PartitionTypeLinuxRaid PartitionTypeGUID = "A19D880F-05FC-4D3B-A006-743F0F84911E"
+
+	// Sizes (in MiB) of the partitions making up a mirrored boot chain.
+	bootDeviceBiosSize = 1
+	bootDevicePrepSize = 4
+	bootDeviceEspSize  = 127
+	bootDeviceBootSize = 384
+)
+
+var (
+	ErrBootDeviceLayout        = errors.New(`boot device layout must be "x86_64", "aarch64", or "ppc64le"`)
+	ErrBootDeviceMirrorDevices = errors.New("boot device mirrors require at least two devices")
+	ErrBootDeviceMirrorDup     = errors.New("boot device mirror devices must be unique")
+)
+
+// BootDevice describes the boot chain of the target system. If Mirror lists
+// devices, the partitions, arrays, and filesystems which replicate the boot
+// chain across them are added to the storage config before the stages run.
+type BootDevice struct {
+	// Layout is the architecture whose boot chain is mirrored, defaulting
+	// to "x86_64".
+	Layout string           `json:"layout,omitempty"`
+	Mirror BootDeviceMirror `json:"mirror,omitempty"`
+}
+
+type BootDeviceMirror struct {
+	Devices []Path `json:"devices,omitempty"`
+}
+
+func (b BootDevice) Validate() report.Report {
+	switch b.Layout {
+	case "", "x86_64", "aarch64", "ppc64le":
+	default:
+		return report.ReportFromError(ErrBootDeviceLayout, report.EntryError)
+	}
+	return report.Report{}
+}
+
+func (m BootDeviceMirror) Validate() report.Report {
+	if len(m.Devices) == 1 {
+		return report.ReportFromError(ErrBootDeviceMirrorDevices, report.EntryError)
+	}
+	seen := map[Path]bool{}
+	for _, dev := range m.Devices {
+		if seen[dev] {
+			return report.ReportFromError(ErrBootDeviceMirrorDup, report.EntryError)
+		}
+		seen[dev] = true
+	}
+	return report.Report{}
+}
+
+// bootDevicePartition is one of the partitions replicated on every mirror
+// device. Those with a raid name are assembled into a RAID1 array of that
+// name. The ESP is not: firmware may write to it, which would go unnoticed by
+// md and leave the members out of sync, so each device gets its own.
+type bootDevicePartition struct {
+	label    string
+	sizeMiB  int
+	typeGUID PartitionTypeGUID
+	raid     string
+}
+
+// partitions returns the partitions of the boot chain for the layout, in the
+// order they are created on each device.
+func (b BootDevice) partitions() []bootDevicePartition {
+	esp := bootDevicePartition{"esp", bootDeviceEspSize, PartitionTypeEfiSystem, ""}
+	boot := bootDevicePartition{"boot", bootDeviceBootSize, PartitionTypeLinuxRaid, "md-boot"}
+	switch b.Layout {
+	case "aarch64":
+		return []bootDevicePartition{esp, boot}
+	case "ppc64le":
+		return []bootDevicePartition{{"prep", bootDevicePrepSize, PartitionTypePrepBoot, ""}, boot}
+	default:
+		return []bootDevicePartition{{"bios", bootDeviceBiosSize, PartitionTypeBiosBoot, ""}, esp, boot}
+	}
+}
+
+// WithBootDevice returns s with the boot device mirror expanded: the boot
+// chain partitions are prepended to each mirror device's disk (numbered from
+// 1 and labeled "<name>-<n>" for the nth device), a vfat filesystem of the
+// same name is created on each device's ESP, and the /boot partitions are
+// assembled into a RAID1 array, with metadata at the end of the members so
+// that boot loaders can read them, on which the "boot" filesystem is created.
+// s is returned unchanged if no mirror is configured.
+func (s Storage) WithBootDevice() Storage {
+	if s.BootDevice == nil || len(s.BootDevice.Mirror.Devices) < 2 {
+		return s
+	}
+	devices := s.BootDevice.Mirror.Devices
+	parts := s.BootDevice.partitions()
+
+	disks := make([]Disk, len(s.Disks))
+	copy(disks, s.Disks)
+	arrays := map[string]*Raid{}
+	var order []string
+	var filesystems []Filesystem
+	for i, dev := range devices {
+		var generated []Partition
+		for n, p := range parts {
+			label := fmt.Sprintf("%s-%d", p.label, i+1)
+			generated = append(generated, Partition{
+				Label:    PartitionLabel(label),
+				Number:   n + 1,
+				SizeMiB:  p.sizeMiB,
+				TypeGUID: p.typeGUID,
+			})
+			if p.typeGUID == PartitionTypeEfiSystem {
+				filesystems = append(filesystems, bootDeviceEsp(label))
+			}
+			if p.raid == "" {
+				continue
+			}
+			if arrays[p.raid] == nil {
+				arrays[p.raid] = &Raid{
+					Name:    p.raid,
+					Level:   "raid1",
+					Options: RaidOptions{"--metadata=1.0"},
+				}
+				order = append(order, p.raid)
+			}
+			arrays[p.raid].Devices = append(arrays[p.raid].Devices, Path("/dev/disk/by-partlabel/"+label))
+		}
+
+		merged := false
+		for j := range disks {
+			if disks[j].Device == dev {
+				disks[j].Partitions = append(generated, disks[j].Partitions...)
+				merged = true
+				break
+			}
+		}
+		if !merged {
+			disks = append(disks, Disk{Device: dev, Partitions: generated})
+		}
+	}
+
+	var raids []Raid
+	for _, name := range order {
+		raids = append(raids, *arrays[name])
+		filesystems = append(filesystems, bootDeviceBoot(name))
+	}
+
+	s.Disks = disks
+	s.Arrays = append(raids, s.Arrays...)
+	s.Filesystems = append(filesystems, s.Filesystems...)
+	s.BootDevice = nil
+	return s
+}
+
+// bootDeviceEsp returns the filesystem created on the ESP partition with the
+// given label. It isn't mounted on the target system, since each device has
+// its own.
+func bootDeviceEsp(label string) Filesystem {
+	return Filesystem{Name: label, Mount: &FilesystemMount{
+		Device: Path("/dev/disk/by-partlabel/" + label), Format: "vfat", Create: &FilesystemCreate{}, Label: &label,
+	}}
+}
+
+// bootDeviceBoot returns the filesystem created on the mirrored array of the
+// given name.
+func bootDeviceBoot(array string) Filesystem {
+	path, label := Path("/boot"), "boot"
+	return Filesystem{Name: "boot", Mount: &FilesystemMount{
+		Device: Path("/dev/md/" + array), Format: "ext4", Create: &FilesystemCreate{}, Path: &path, Label: &label,
+	}}
+}
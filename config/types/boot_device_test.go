@@ -0,0 +1,192 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/coreos/ignition/config/validate/report"
+)
+
+func TestBootDeviceValidate(t *testing.T) {
+	type in struct {
+		device BootDevice
+	}
+	type out struct {
+		err error
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{device: BootDevice{}},
+			out: out{},
+		},
+		{
+			in:  in{device: BootDevice{Layout: "ppc64le"}},
+			out: out{},
+		},
+		{
+			in:  in{device: BootDevice{Layout: "s390x"}},
+			out: out{err: ErrBootDeviceLayout},
+		},
+	}
+
+	for i, test := range tests {
+		err := test.in.device.Validate()
+		if !reflect.DeepEqual(report.ReportFromError(test.out.err, report.EntryError), err) {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.out.err, err)
+		}
+	}
+}
+
+func TestBootDeviceMirrorValidate(t *testing.T) {
+	type in struct {
+		mirror BootDeviceMirror
+	}
+	type out struct {
+		err error
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{mirror: BootDeviceMirror{Devices: []Path{"/dev/vda", "/dev/vdb"}}},
+			out: out{},
+		},
+		{
+			in:  in{mirror: BootDeviceMirror{Devices: []Path{"/dev/vda"}}},
+			out: out{err: ErrBootDeviceMirrorDevices},
+		},
+		{
+			in:  in{mirror: BootDeviceMirror{Devices: []Path{"/dev/vda", "/dev/vda"}}},
+			out: out{err: ErrBootDeviceMirrorDup},
+		},
+	}
+
+	for i, test := range tests {
+		err := test.in.mirror.Validate()
+		if !reflect.DeepEqual(report.ReportFromError(test.out.err, report.EntryError), err) {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.out.err, err)
+		}
+	}
+}
+
+func TestStorageWithBootDevice(t *testing.T) {
+	type in struct {
+		storage Storage
+	}
+	type out struct {
+		labels  map[Path][]PartitionLabel
+		arrays  map[string][]Path
+		devices map[string]Path
+		ndisks  int
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in: in{storage: Storage{Disks: []Disk{{Device: "/dev/vda"}}}},
+			out: out{
+				labels:  map[Path][]PartitionLabel{"/dev/vda": nil},
+				arrays:  map[string][]Path{},
+				devices: map[string]Path{},
+				ndisks:  1,
+			},
+		},
+		{
+			in: in{storage: Storage{
+				BootDevice: &BootDevice{Mirror: BootDeviceMirror{Devices: []Path{"/dev/vda", "/dev/vdb"}}},
+				Disks:      []Disk{{Device: "/dev/vdb", Partitions: []Partition{{Label: "root", Number: 4}}}},
+			}},
+			out: out{
+				labels: map[Path][]PartitionLabel{
+					"/dev/vda": {"bios-1", "esp-1", "boot-1"},
+					"/dev/vdb": {"bios-2", "esp-2", "boot-2", "root"},
+				},
+				arrays: map[string][]Path{
+					"md-boot": {"/dev/disk/by-partlabel/boot-1", "/dev/disk/by-partlabel/boot-2"},
+				},
+				devices: map[string]Path{
+					"esp-1": "/dev/disk/by-partlabel/esp-1",
+					"esp-2": "/dev/disk/by-partlabel/esp-2",
+					"boot":  "/dev/md/md-boot",
+				},
+				ndisks: 2,
+			},
+		},
+		{
+			in: in{storage: Storage{
+				BootDevice: &BootDevice{Layout: "ppc64le", Mirror: BootDeviceMirror{Devices: []Path{"/dev/sda", "/dev/sdb", "/dev/sdc"}}},
+			}},
+			out: out{
+				labels: map[Path][]PartitionLabel{
+					"/dev/sda": {"prep-1", "boot-1"},
+					"/dev/sdb": {"prep-2", "boot-2"},
+					"/dev/sdc": {"prep-3", "boot-3"},
+				},
+				arrays: map[string][]Path{
+					"md-boot": {"/dev/disk/by-partlabel/boot-1", "/dev/disk/by-partlabel/boot-2", "/dev/disk/by-partlabel/boot-3"},
+				},
+				devices: map[string]Path{"boot": "/dev/md/md-boot"},
+				ndisks:  3,
+			},
+		},
+	}
+
+	for i, test := range tests {
+		s := test.in.storage.WithBootDevice()
+		if s.BootDevice != nil {
+			t.Errorf("#%d: boot device left unexpanded", i)
+		}
+		if len(s.Disks) != test.out.ndisks {
+			t.Errorf("#%d: bad disk count: want %d, got %d", i, test.out.ndisks, len(s.Disks))
+		}
+		labels := map[Path][]PartitionLabel{}
+		for _, d := range s.Disks {
+			labels[d.Device] = nil
+			for _, p := range d.Partitions {
+				labels[d.Device] = append(labels[d.Device], p.Label)
+			}
+		}
+		if !reflect.DeepEqual(test.out.labels, labels) {
+			t.Errorf("#%d: bad partitions: want %v, got %v", i, test.out.labels, labels)
+		}
+		arrays := map[string][]Path{}
+		for _, a := range s.Arrays {
+			arrays[a.Name] = a.Devices
+		}
+		if !reflect.DeepEqual(test.out.arrays, arrays) {
+			t.Errorf("#%d: bad arrays: want %v, got %v", i, test.out.arrays, arrays)
+		}
+		devices := map[string]Path{}
+		for _, fs := range s.Filesystems {
+			devices[fs.Name] = fs.Mount.Device
+			if fs.Mount.Path != nil && fs.Name != "boot" {
+				t.Errorf("#%d: filesystem %q is mounted at %q", i, fs.Name, *fs.Mount.Path)
+			}
+		}
+		if !reflect.DeepEqual(test.out.devices, devices) {
+			t.Errorf("#%d: bad filesystems: want %v, got %v", i, test.out.devices, devices)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/coreos/ignition/config/validate/report"
+)
+
+const (
+	grubPasswordHashPrefix   = "grub.pbkdf2.sha512."
+	grubUserNameInvalidChars = " \t\n\"'"
+)
+
+var (
+	ErrGrubUserNameInvalid = errors.New("grub user names must be non-empty and may not contain whitespace or quotes")
+	ErrGrubPasswordInvalid = errors.New("grub password hashes must be generated by grub-mkpasswd-pbkdf2")
+	ErrGrubTimeoutNegative = errors.New("grub timeout may not be negative")
+	ErrGrubNoSuperuserPass = errors.New("grub superusers require a password hash")
+	ErrEfiEntryNoLabel     = errors.New("efi boot entries require a label")
+	ErrEfiEntryNoLoader    = errors.New("efi boot entries require a loader")
+	ErrEfiEntryLoaderPath  = errors.New("efi loader paths must be absolute and use backslashes (e.g. \\EFI\\BOOT\\BOOTX64.EFI)")
+	ErrEfiEntryNoDevice    = errors.New("efi boot entries require a device and partition number")
+)
+
+type Bootloader struct {
+	Grub Grub    `json:"grub,omitempty"`
+	Efi  EfiBoot `json:"efi,omitempty"`
+}
+
+type Grub struct {
+	Users   []GrubUser `json:"users,omitempty"`
+	Timeout *int       `json:"timeout,omitempty"`
+}
+
+func (g Grub) Validate() report.Report {
+	if g.Timeout != nil && *g.Timeout < 0 {
+		return report.ReportFromError(ErrGrubTimeoutNegative, report.EntryError)
+	}
+	return report.Report{}
+}
+
+type GrubUser struct {
+	Name         string `json:"name,omitempty"`
+	PasswordHash string `json:"passwordHash,omitempty"`
+	Superuser    bool   `json:"superuser,omitempty"`
+}
+
+func (u GrubUser) Validate() report.Report {
+	if u.Name == "" || strings.ContainsAny(u.Name, grubUserNameInvalidChars) {
+		return report.ReportFromError(ErrGrubUserNameInvalid, report.EntryError)
+	}
+	if u.PasswordHash == "" {
+		if u.Superuser {
+			return report.ReportFromError(ErrGrubNoSuperuserPass, report.EntryError)
+		}
+		return report.Report{}
+	}
+	if !strings.HasPrefix(u.PasswordHash, grubPasswordHashPrefix) || strings.ContainsAny(u.PasswordHash, " \t\n") {
+		return report.ReportFromError(ErrGrubPasswordInvalid, report.EntryError)
+	}
+	return report.Report{}
+}
+
+type EfiBoot struct {
+	Entries   []EfiBootEntry `json:"entries,omitempty"`
+	BootOrder []string       `json:"bootOrder,omitempty"`
+}
+
+type EfiBootEntry struct {
+	Label       string `json:"label,omitempty"`
+	Device      *Path  `json:"device,omitempty"`
+	Partition   int    `json:"partition,omitempty"`
+	Loader      string `json:"loader,omitempty"`
+	ShouldExist *bool  `json:"shouldExist,omitempty"`
+}
+
+// Exists returns whether or not the entry should be present, which is the
+// default.
+func (e EfiBootEntry) Exists() bool {
+	return e.ShouldExist == nil || *e.ShouldExist
+}
+
+func (e EfiBootEntry) Validate() report.Report {
+	if e.Label == "" {
+		return report.ReportFromError(ErrEfiEntryNoLabel, report.EntryError)
+	}
+	if !e.Exists() {
+		return report.Report{}
+	}
+	if e.Device == nil || e.Partition <= 0 {
+		return report.ReportFromError(ErrEfiEntryNoDevice, report.EntryError)
+	}
+	if e.Loader == "" {
+		return report.ReportFromError(ErrEfiEntryNoLoader, report.EntryError)
+	}
+	if !strings.HasPrefix(e.Loader, "\\") || strings.Contains(e.Loader, "/") {
+		return report.ReportFromError(ErrEfiEntryLoaderPath, report.EntryError)
+	}
+	return report.Report{}
+}
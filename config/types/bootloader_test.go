@@ -0,0 +1,117 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/coreos/ignition/config/validate/report"
+)
+
+func TestGrubUserValidate(t *testing.T) {
+	type in struct {
+		user GrubUser
+	}
+	type out struct {
+		err error
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{user: GrubUser{Name: "root", PasswordHash: "grub.pbkdf2.sha512.10000.AB.CD", Superuser: true}},
+			out: out{},
+		},
+		{
+			in:  in{user: GrubUser{Name: "guest"}},
+			out: out{},
+		},
+		{
+			in:  in{user: GrubUser{Name: "root", Superuser: true}},
+			out: out{err: ErrGrubNoSuperuserPass},
+		},
+		{
+			in:  in{user: GrubUser{Name: "root", PasswordHash: "$6$salt$hash"}},
+			out: out{err: ErrGrubPasswordInvalid},
+		},
+		{
+			in:  in{user: GrubUser{Name: "bad user"}},
+			out: out{err: ErrGrubUserNameInvalid},
+		},
+		{
+			in:  in{user: GrubUser{}},
+			out: out{err: ErrGrubUserNameInvalid},
+		},
+	}
+
+	for i, test := range tests {
+		err := test.in.user.Validate()
+		if !reflect.DeepEqual(report.ReportFromError(test.out.err, report.EntryError), err) {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.out.err, err)
+		}
+	}
+}
+
+func TestEfiBootEntryValidate(t *testing.T) {
+	type in struct {
+		entry EfiBootEntry
+	}
+	type out struct {
+		err error
+	}
+
+	device := Path("/dev/sda")
+	no := false
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{entry: EfiBootEntry{Label: "Linux", Device: &device, Partition: 1, Loader: `\EFI\BOOT\BOOTX64.EFI`}},
+			out: out{},
+		},
+		{
+			in:  in{entry: EfiBootEntry{Label: "Old", ShouldExist: &no}},
+			out: out{},
+		},
+		{
+			in:  in{entry: EfiBootEntry{Device: &device, Partition: 1, Loader: `\EFI\BOOT\BOOTX64.EFI`}},
+			out: out{err: ErrEfiEntryNoLabel},
+		},
+		{
+			in:  in{entry: EfiBootEntry{Label: "Linux", Loader: `\EFI\BOOT\BOOTX64.EFI`}},
+			out: out{err: ErrEfiEntryNoDevice},
+		},
+		{
+			in:  in{entry: EfiBootEntry{Label: "Linux", Device: &device, Partition: 1}},
+			out: out{err: ErrEfiEntryNoLoader},
+		},
+		{
+			in:  in{entry: EfiBootEntry{Label: "Linux", Device: &device, Partition: 1, Loader: "/EFI/BOOT/BOOTX64.EFI"}},
+			out: out{err: ErrEfiEntryLoaderPath},
+		},
+	}
+
+	for i, test := range tests {
+		err := test.in.entry.Validate()
+		if !reflect.DeepEqual(report.ReportFromError(test.out.err, report.EntryError), err) {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.out.err, err)
+		}
+	}
+}
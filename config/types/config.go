@@ -31,11 +31,14 @@ var (
 )
 
 type Config struct {
-	Ignition Ignition `json:"ignition"`
-	Storage  Storage  `json:"storage,omitempty"`
-	Systemd  Systemd  `json:"systemd,omitempty"`
-	Networkd Networkd `json:"networkd,omitempty"`
-	Passwd   Passwd   `json:"passwd,omitempty"`
+	Ignition        Ignition        `json:"ignition"`
+	Storage         Storage         `json:"storage,omitempty"`
+	Systemd         Systemd         `json:"systemd,omitempty"`
+	Networkd        Networkd        `json:"networkd,omitempty"`
+	Passwd          Passwd          `json:"passwd,omitempty"`
+	Bootloader      Bootloader      `json:"bootloader,omitempty"`
+	System          System          `json:"system,omitempty"`
+	KernelArguments KernelArguments `json:"kernelArguments,omitempty"`
 }
 
 func (c Config) Validate() report.Report {
@@ -43,6 +46,8 @@ func (c Config) Validate() report.Report {
 	rules := []rule{
 		checkFilesFilesystems,
 		checkDuplicateFilesystems,
+		checkDuplicateLuks,
+		checkBootDeviceMirror,
 	}
 
 	for _, rule := range rules {
@@ -55,7 +60,7 @@ type rule func(cfg Config, report *report.Report)
 
 func checkFilesFilesystems(cfg Config, r *report.Report) {
 	filesystems := map[string]struct{}{"root": {}}
-	for _, filesystem := range cfg.Storage.Filesystems {
+	for _, filesystem := range cfg.Storage.WithBootDevice().Filesystems {
 		filesystems[filesystem.Name] = struct{}{}
 	}
 	for _, file := range cfg.Storage.Files {
@@ -76,7 +81,7 @@ func checkFilesFilesystems(cfg Config, r *report.Report) {
 
 func checkDuplicateFilesystems(cfg Config, r *report.Report) {
 	filesystems := map[string]struct{}{"root": {}}
-	for _, filesystem := range cfg.Storage.Filesystems {
+	for _, filesystem := range cfg.Storage.WithBootDevice().Filesystems {
 		if _, ok := filesystems[filesystem.Name]; ok {
 			r.Add(report.Entry{
 				Kind:    report.EntryWarning,
@@ -86,3 +91,36 @@ func checkDuplicateFilesystems(cfg Config, r *report.Report) {
 		filesystems[filesystem.Name] = struct{}{}
 	}
 }
+
+func checkDuplicateLuks(cfg Config, r *report.Report) {
+	volumes := map[string]struct{}{}
+	for _, volume := range cfg.Storage.Luks {
+		if _, ok := volumes[volume.Name]; ok {
+			r.Add(report.Entry{
+				Kind:    report.EntryError,
+				Message: fmt.Sprintf("Luks volume %q is defined more than once", volume.Name),
+			})
+		}
+		volumes[volume.Name] = struct{}{}
+	}
+}
+
+// checkBootDeviceMirror reports partitions on the mirror devices whose
+// numbers collide with those of the mirrored boot chain.
+func checkBootDeviceMirror(cfg Config, r *report.Report) {
+	if cfg.Storage.BootDevice == nil {
+		return
+	}
+	mirrored := map[Path]bool{}
+	for _, dev := range cfg.Storage.BootDevice.Mirror.Devices {
+		mirrored[dev] = true
+	}
+	for _, disk := range cfg.Storage.WithBootDevice().Disks {
+		if mirrored[disk.Device] && disk.partitionNumbersCollide() {
+			r.Add(report.Entry{
+				Kind:    report.EntryError,
+				Message: fmt.Sprintf("disk %q: partition numbers collide with the mirrored boot chain", disk.Device),
+			})
+		}
+	}
+}
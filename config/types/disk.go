@@ -15,15 +15,87 @@
 package types
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/coreos/ignition/config/validate/report"
 )
 
+var (
+	ErrSlotPairNoLabel     = errors.New("partition slot pairs require a label")
+	ErrSlotPairLabelLength = errors.New("partition slot pair labels may not exceed 34 characters")
+	ErrSlotPairNoSize      = errors.New("partition slot pairs require an explicit size")
+	ErrSlotPairSizeMiB     = errors.New("partition slot pairs cannot give both size and sizeMiB")
+	ErrSlotPairActive      = errors.New(`partition slot pair active slot must be "a" or "b"`)
+	ErrResizeWipeTable     = errors.New("partitions cannot be resized when the partition table is wiped; they are created instead")
+	ErrForceNoWipeTable    = errors.New("force has no effect unless the partition table is wiped")
+)
+
 type Disk struct {
-	Device     Path        `json:"device,omitempty"`
-	WipeTable  bool        `json:"wipeTable,omitempty"`
-	Partitions []Partition `json:"partitions,omitempty"`
+	Device    Path `json:"device,omitempty"`
+	WipeTable bool `json:"wipeTable,omitempty"`
+	// Force allows the partition table to be wiped even if the disk holds
+	// the running system or a partition table other than GPT.
+	Force      bool                `json:"force,omitempty"`
+	Partitions []Partition         `json:"partitions,omitempty"`
+	SlotPairs  []PartitionSlotPair `json:"slotPairs,omitempty"`
+}
+
+// PartitionSlotPair describes a pair of identically sized partitions for use
+// in an A/B update scheme. The partitions are labeled "<label>-a" and
+// "<label>-b" and the active slot is marked with the legacy BIOS bootable
+// attribute.
+type PartitionSlotPair struct {
+	Label PartitionLabel     `json:"label,omitempty"`
+	Size  PartitionDimension `json:"size"`
+	// SizeMiB gives the size of each partition in MiB, in place of Size.
+	SizeMiB  int               `json:"sizeMiB,omitempty"`
+	TypeGUID PartitionTypeGUID `json:"typeGuid,omitempty"`
+	Active   string            `json:"active,omitempty"`
+}
+
+func (p PartitionSlotPair) Validate() report.Report {
+	if p.Label == "" {
+		return report.ReportFromError(ErrSlotPairNoLabel, report.EntryError)
+	}
+	if len(p.SlotLabel("a")) > 36 {
+		return report.ReportFromError(ErrSlotPairLabelLength, report.EntryError)
+	}
+	if p.Size != 0 && p.SizeMiB != 0 {
+		return report.ReportFromError(ErrSlotPairSizeMiB, report.EntryError)
+	}
+	if p.Size == 0 && p.SizeMiB <= 0 {
+		return report.ReportFromError(ErrSlotPairNoSize, report.EntryError)
+	}
+	switch p.Active {
+	case "", "a", "b":
+	default:
+		return report.ReportFromError(ErrSlotPairActive, report.EntryError)
+	}
+	return report.Report{}
+}
+
+// SizeSectors returns the size of each partition in sectors of sectorSize
+// bytes.
+func (p PartitionSlotPair) SizeSectors(sectorSize uint64) uint64 {
+	if p.SizeMiB != 0 {
+		return uint64(p.SizeMiB) * MiB / sectorSize
+	}
+	return uint64(p.Size)
+}
+
+// SlotLabel returns the label of the partition for the given slot.
+func (p PartitionSlotPair) SlotLabel(slot string) PartitionLabel {
+	return PartitionLabel(fmt.Sprintf("%s-%s", p.Label, slot))
+}
+
+// ActiveSlot returns the slot which is to be marked active, defaulting to
+// "a".
+func (p PartitionSlotPair) ActiveSlot() string {
+	if p.Active == "" {
+		return "a"
+	}
+	return p.Active
 }
 
 func (n Disk) Validate() report.Report {
@@ -40,6 +112,29 @@ func (n Disk) Validate() report.Report {
 			Kind:    report.EntryError,
 		})
 	}
+	if n.WipeTable {
+		for _, p := range n.Partitions {
+			if p.Resize {
+				r.Add(report.Entry{
+					Message: ErrResizeWipeTable.Error(),
+					Kind:    report.EntryWarning,
+				})
+				break
+			}
+		}
+	}
+	if n.Force && !n.WipeTable {
+		r.Add(report.Entry{
+			Message: ErrForceNoWipeTable.Error(),
+			Kind:    report.EntryWarning,
+		})
+	}
+	if n.partitionGUIDsCollide() {
+		r.Add(report.Entry{
+			Message: fmt.Sprintf("disk %q: partition guids collide", n.Device),
+			Kind:    report.EntryError,
+		})
+	}
 	if n.partitionsOverlap() {
 		r.Add(report.Entry{
 			Message: fmt.Sprintf("disk %q: partitions overlap", n.Device),
@@ -57,9 +152,13 @@ func (n Disk) Validate() report.Report {
 }
 
 // partitionNumbersCollide returns true if partition numbers in n.Partitions are not unique.
+// Partitions without a number are given the next free one, so never collide.
 func (n Disk) partitionNumbersCollide() bool {
 	m := map[int][]Partition{}
 	for _, p := range n.Partitions {
+		if p.Number == 0 {
+			continue
+		}
 		m[p.Number] = append(m[p.Number], p)
 	}
 	for _, n := range m {
@@ -71,13 +170,35 @@ func (n Disk) partitionNumbersCollide() bool {
 	return false
 }
 
-// end returns the last sector of a partition.
-func (p Partition) end() PartitionDimension {
-	if p.Size == 0 {
+// partitionGUIDsCollide returns true if two partitions in n.Partitions are
+// given the same unique GUID.
+func (n Disk) partitionGUIDsCollide() bool {
+	for i, p := range n.Partitions {
+		if p.GUID == "" {
+			continue
+		}
+		for _, o := range n.Partitions[i+1:] {
+			if p.GUID.Is(o.GUID) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// start returns the first sector of a partition, in 512-byte sectors.
+func (p Partition) start() uint64 {
+	return p.StartSectors(legacySectorSize)
+}
+
+// end returns the last sector of a partition, in 512-byte sectors.
+func (p Partition) end() uint64 {
+	size := p.SizeSectors(legacySectorSize)
+	if size == 0 {
 		// a size of 0 means "fill available", just return the start as the end for those.
-		return p.Start
+		return p.start()
 	}
-	return p.Start + p.Size - 1
+	return p.start() + size - 1
 }
 
 // partitionsOverlap returns true if any explicitly dimensioned partitions overlap
@@ -85,27 +206,28 @@ func (n Disk) partitionsOverlap() bool {
 	for _, p := range n.Partitions {
 		// Starts of 0 are placed by sgdisk into the "largest available block" at that time.
 		// We aren't going to check those for overlap since we don't have the disk geometry.
-		if p.Start == 0 {
+		// Partitions which are deleted occupy no space.
+		if p.start() == 0 || !p.Exists() {
 			continue
 		}
 
 		for _, o := range n.Partitions {
-			if p == o || o.Start == 0 {
+			if p == o || o.start() == 0 || !o.Exists() {
 				continue
 			}
 
-			// is p.Start within o?
-			if p.Start >= o.Start && p.Start <= o.end() {
+			// is p.start() within o?
+			if p.start() >= o.start() && p.start() <= o.end() {
 				return true
 			}
 
 			// is p.end() within o?
-			if p.end() >= o.Start && p.end() <= o.end() {
+			if p.end() >= o.start() && p.end() <= o.end() {
 				return true
 			}
 
-			// do p.Start and p.end() straddle o?
-			if p.Start < o.Start && p.end() > o.end() {
+			// do p.start() and p.end() straddle o?
+			if p.start() < o.start() && p.end() > o.end() {
 				return true
 			}
 		}
@@ -114,6 +236,7 @@ func (n Disk) partitionsOverlap() bool {
 }
 
 // partitionsMisaligned returns true if any of the partitions don't start on a 2048-sector (1MiB) boundary.
+// Partitions placed with startMiB are always aligned.
 func (n Disk) partitionsMisaligned() bool {
 	for _, p := range n.Partitions {
 		if (p.Start & (2048 - 1)) != 0 {
@@ -0,0 +1,91 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"testing"
+)
+
+func TestDiskValidate(t *testing.T) {
+	type in struct {
+		disk Disk
+	}
+	type out struct {
+		err     bool
+		entries int
+	}
+
+	no := false
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{disk: Disk{Device: "/dev/sda", Partitions: []Partition{{Label: "a"}, {Label: "b"}}}},
+			out: out{},
+		},
+		{
+			in:  in{disk: Disk{Device: "/dev/sda", Partitions: []Partition{{Number: 1}, {Number: 1}}}},
+			out: out{err: true, entries: 1},
+		},
+		{
+			in:  in{disk: Disk{Device: "/dev/sda", Partitions: []Partition{{Number: 1, GUID: "5a1a7a3d-3c5f-4b8e-9d5c-8e2b4f6a1c0d"}, {Number: 2, GUID: "6b2b8b4e-4d6a-4c9f-8e6d-9f3c5a7b2d1e"}}}},
+			out: out{},
+		},
+		{
+			in:  in{disk: Disk{Device: "/dev/sda", Partitions: []Partition{{Number: 1, GUID: "5a1a7a3d-3c5f-4b8e-9d5c-8e2b4f6a1c0d"}, {Number: 2, GUID: "5A1A7A3D-3C5F-4B8E-9D5C-8E2B4F6A1C0D"}}}},
+			out: out{err: true, entries: 1},
+		},
+		{
+			in:  in{disk: Disk{Device: "/dev/sda", Partitions: []Partition{{Number: 1, Start: 2048, Size: 4096, ShouldExist: &no}, {Number: 2, Start: 2048, Size: 4096}}}},
+			out: out{},
+		},
+		{
+			in:  in{disk: Disk{Device: "/dev/sda", Partitions: []Partition{{Number: 9, Resize: true}}}},
+			out: out{},
+		},
+		{
+			in:  in{disk: Disk{Device: "/dev/sda", WipeTable: true, Partitions: []Partition{{Number: 9, Resize: true}}}},
+			out: out{entries: 1},
+		},
+		{
+			in:  in{disk: Disk{Device: "/dev/sda", Partitions: []Partition{{Number: 1, StartMiB: 1, SizeMiB: 4}, {Number: 2, Start: 10240, Size: 2048}}}},
+			out: out{},
+		},
+		{
+			in:  in{disk: Disk{Device: "/dev/sda", Partitions: []Partition{{Number: 1, StartMiB: 1, SizeMiB: 4}, {Number: 2, Start: 8192, Size: 2048}}}},
+			out: out{err: true, entries: 1},
+		},
+		{
+			in:  in{disk: Disk{Device: "/dev/sda", WipeTable: true, Force: true}},
+			out: out{},
+		},
+		{
+			in:  in{disk: Disk{Device: "/dev/sda", Force: true}},
+			out: out{entries: 1},
+		},
+	}
+
+	for i, test := range tests {
+		r := test.in.disk.Validate()
+		if test.out.err != r.IsFatal() {
+			t.Errorf("#%d: bad fatality: want %t, got %t", i, test.out.err, r.IsFatal())
+		}
+		if test.out.entries != len(r.Entries) {
+			t.Errorf("#%d: bad entries: want %d, got %v", i, test.out.entries, r.Entries)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"errors"
+	"net"
+
+	"github.com/coreos/ignition/config/validate/report"
+)
+
+var (
+	ErrDnsNameserver   = errors.New("nameservers must be IP addresses")
+	ErrDnsSearchDomain = errors.New("invalid search domain")
+)
+
+// Dns configures the resolver used for Ignition's own fetches. It does not
+// affect the network configuration of the provisioned system.
+type Dns struct {
+	Nameservers []Nameserver   `json:"nameservers,omitempty"`
+	Search      []SearchDomain `json:"search,omitempty"`
+}
+
+type Nameserver string
+
+func (n Nameserver) Validate() report.Report {
+	if net.ParseIP(string(n)) == nil {
+		return report.ReportFromError(ErrDnsNameserver, report.EntryError)
+	}
+	return report.Report{}
+}
+
+type SearchDomain string
+
+func (d SearchDomain) Validate() report.Report {
+	if len(d) > 253 || !hostnameRegexp.MatchString(string(d)) {
+		return report.ReportFromError(ErrDnsSearchDomain, report.EntryError)
+	}
+	return report.Report{}
+}
@@ -14,14 +14,102 @@
 
 package types
 
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"text/template"
+
+	"github.com/coreos/ignition/config/validate/report"
+
+	"github.com/vincent-petithory/dataurl"
+)
+
+var (
+	ErrFileSourceAndFragments  = errors.New("file contents cannot specify both a source and fragments")
+	ErrFragmentSourceAndInline = errors.New("file fragment cannot specify both a source and inline contents")
+)
+
 // File represents regular files
 type File struct {
 	Node
 	Contents FileContents `json:"contents,omitempty"`
+	// Append is added to the end of the file's contents. If the contents
+	// are empty, it is added to the existing file instead, so that several
+	// configs can each contribute to the same file.
+	Append []FileFragment `json:"append,omitempty"`
 }
 
 type FileContents struct {
+	Compression  Compression    `json:"compression,omitempty"`
+	Source       Url            `json:"source,omitempty"`
+	Fragments    []FileFragment `json:"fragments,omitempty"`
+	Template     bool           `json:"template,omitempty"`
+	Verification Verification   `json:"verification,omitempty"`
+	HttpHeaders  HttpHeaders    `json:"httpHeaders,omitempty"`
+}
+
+func (c FileContents) Validate() report.Report {
+	if c.Source.String() != "" && len(c.Fragments) != 0 {
+		return report.ReportFromError(ErrFileSourceAndFragments, report.EntryError)
+	}
+	if contents, ok := c.inlineContents(); c.Template && ok {
+		if _, err := template.New("contents").Parse(contents); err != nil {
+			return report.ReportFromError(fmt.Errorf("file contents are not a valid template: %v", err), report.EntryError)
+		}
+	}
+	return validateHttpHeaders(c.Source, c.HttpHeaders)
+}
+
+// inlineContents returns the contents given entirely within the config, and
+// whether they are. Contents with any part that is fetched or compressed
+// can't be known until the file is written.
+func (c FileContents) inlineContents() (string, bool) {
+	if len(c.Fragments) == 0 {
+		return inlineSource(c.Source, c.Compression)
+	}
+	contents := ""
+	for _, f := range c.Fragments {
+		if f.Inline != "" {
+			contents += f.Inline
+			continue
+		}
+		s, ok := inlineSource(f.Source, f.Compression)
+		if !ok {
+			return "", false
+		}
+		contents += s
+	}
+	return contents, true
+}
+
+func inlineSource(u Url, compression Compression) (string, bool) {
+	if u.String() == "" {
+		return "", true
+	}
+	if url.URL(u).Scheme != "data" || compression != "" {
+		return "", false
+	}
+	data, err := dataurl.DecodeString(u.String())
+	if err != nil {
+		return "", false
+	}
+	return string(data.Data), true
+}
+
+// FileFragment is one piece of a file's contents, which are assembled by
+// concatenating its fragments in order.
+type FileFragment struct {
+	Inline       string       `json:"inline,omitempty"`
 	Compression  Compression  `json:"compression,omitempty"`
 	Source       Url          `json:"source,omitempty"`
 	Verification Verification `json:"verification,omitempty"`
+	HttpHeaders  HttpHeaders  `json:"httpHeaders,omitempty"`
+}
+
+func (f FileFragment) Validate() report.Report {
+	if f.Source.String() != "" && f.Inline != "" {
+		return report.ReportFromError(ErrFragmentSourceAndInline, report.EntryError)
+	}
+	return validateHttpHeaders(f.Source, f.HttpHeaders)
 }
@@ -0,0 +1,126 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"errors"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/coreos/ignition/config/validate/report"
+)
+
+func TestFileContentsValidate(t *testing.T) {
+	type in struct {
+		contents FileContents
+	}
+	type out struct {
+		err error
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{contents: FileContents{Source: Url(url.URL{Scheme: "data", Opaque: ",hello"})}},
+			out: out{},
+		},
+		{
+			in:  in{contents: FileContents{Fragments: []FileFragment{{Inline: "hello"}}}},
+			out: out{},
+		},
+		{
+			in: in{contents: FileContents{
+				Source:    Url(url.URL{Scheme: "data", Opaque: ",hello"}),
+				Fragments: []FileFragment{{Inline: "hello"}},
+			}},
+			out: out{err: ErrFileSourceAndFragments},
+		},
+		{
+			in:  in{contents: FileContents{Source: Url(url.URL{Scheme: "data", Opaque: ",%7B%7B.Hostname%7D%7D"}), Template: true}},
+			out: out{},
+		},
+		{
+			in:  in{contents: FileContents{Source: Url(url.URL{Scheme: "data", Opaque: ",%7B%7B.Hostname%7D"}), Template: true}},
+			out: out{err: errors.New(`file contents are not a valid template: template: contents:1: bad character U+007D '}'`)},
+		},
+		{
+			in:  in{contents: FileContents{Source: Url(url.URL{Scheme: "data", Opaque: ",%7B%7B.Hostname%7D"})}},
+			out: out{},
+		},
+		{
+			in:  in{contents: FileContents{Source: Url(url.URL{Scheme: "https", Host: "example.com"}), Template: true}},
+			out: out{},
+		},
+		{
+			in:  in{contents: FileContents{Fragments: []FileFragment{{Inline: "{{if .Hostname}}"}, {Inline: "{{end}}"}}, Template: true}},
+			out: out{},
+		},
+		{
+			in:  in{contents: FileContents{Fragments: []FileFragment{{Inline: "{{if .Hostname}}"}}, Template: true}},
+			out: out{err: errors.New(`file contents are not a valid template: template: contents:1: unexpected EOF`)},
+		},
+		{
+			in: in{contents: FileContents{Fragments: []FileFragment{
+				{Inline: "{{if .Hostname}}"},
+				{Source: Url(url.URL{Scheme: "https", Host: "example.com"})},
+			}, Template: true}},
+			out: out{},
+		},
+	}
+
+	for i, test := range tests {
+		err := test.in.contents.Validate()
+		if !reflect.DeepEqual(report.ReportFromError(test.out.err, report.EntryError), err) {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.out.err, err)
+		}
+	}
+}
+
+func TestFileFragmentValidate(t *testing.T) {
+	type in struct {
+		fragment FileFragment
+	}
+	type out struct {
+		err error
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{fragment: FileFragment{Inline: "hello"}},
+			out: out{},
+		},
+		{
+			in:  in{fragment: FileFragment{Source: Url(url.URL{Scheme: "https", Host: "example.com"})}},
+			out: out{},
+		},
+		{
+			in:  in{fragment: FileFragment{Inline: "hello", Source: Url(url.URL{Scheme: "https", Host: "example.com"})}},
+			out: out{err: ErrFragmentSourceAndInline},
+		},
+	}
+
+	for i, test := range tests {
+		err := test.in.fragment.Validate()
+		if !reflect.DeepEqual(report.ReportFromError(test.out.err, report.EntryError), err) {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.out.err, err)
+		}
+	}
+}
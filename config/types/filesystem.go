@@ -16,6 +16,9 @@ package types
 
 import (
 	"errors"
+	"path"
+	"regexp"
+	"strings"
 
 	"github.com/coreos/ignition/config/validate/report"
 )
@@ -24,26 +27,133 @@ var (
 	ErrFilesystemInvalidFormat = errors.New("invalid filesystem format")
 	ErrFilesystemNoMountPath   = errors.New("filesystem is missing mount or path")
 	ErrFilesystemMountAndPath  = errors.New("filesystem has both mount and path defined")
+	ErrFilesystemTargetKinds   = errors.New("filesystem has more than one of mount, path, network, tmpfs, or overlay defined")
+	ErrNetworkInvalidFormat    = errors.New("invalid network filesystem format")
+	ErrNetworkInvalidSource    = errors.New("invalid network filesystem source")
+	ErrSubvolumesNotBtrfs      = errors.New("subvolumes are only supported on btrfs filesystems")
+	ErrSubvolumeInvalidName    = errors.New("subvolume name must be a relative path without \"..\"")
+	ErrSubvolumeManyDefaults   = errors.New("more than one subvolume is marked as default")
+	ErrSubvolOptionNotBtrfs    = errors.New("subvol and subvolid mount options are only supported on btrfs filesystems")
+	ErrFilesystemLabelTooLong  = errors.New("filesystem label is too long for the format")
+	ErrFilesystemInvalidUuid   = errors.New("filesystem uuid must have the form \"01234567-89ab-cdef-edcb-a98765432101\"")
+	ErrVfatInvalidUuid         = errors.New("vfat filesystem uuid must be a volume id of the form \"0123-4567\"")
+	ErrSwapMountPath           = errors.New("swap areas cannot be mounted at a path")
+	ErrProjectsNotXfs          = errors.New("project quotas are only supported on xfs filesystems")
+	ErrProjectInvalidId        = errors.New("project id must be positive")
+	ErrProjectInvalidLimit     = errors.New("project limit must be a size in bytes with an optional k, m, g, or t suffix")
+	ErrOverlayNoLower          = errors.New("overlay filesystem is missing lower directories")
+	ErrOverlayNoUpper          = errors.New("overlay filesystem has work but no upper directory")
+	ErrOverlayNoWork           = errors.New("overlay filesystem has upper but no work directory")
 )
 
 type Filesystem struct {
-	Name  string           `json:"name,omitempty"`
-	Mount *FilesystemMount `json:"mount,omitempty"`
-	Path  *Path            `json:"path,omitempty"`
+	Name    string             `json:"name,omitempty"`
+	Mount   *FilesystemMount   `json:"mount,omitempty"`
+	Path    *Path              `json:"path,omitempty"`
+	Network *FilesystemNetwork `json:"network,omitempty"`
+	Tmpfs   *FilesystemTmpfs   `json:"tmpfs,omitempty"`
+	Overlay *FilesystemOverlay `json:"overlay,omitempty"`
 }
 
 type FilesystemMount struct {
 	Device Path              `json:"device,omitempty"`
 	Format FilesystemFormat  `json:"format,omitempty"`
 	Create *FilesystemCreate `json:"create,omitempty"`
+	// Path is where the filesystem is mounted on the target system.
+	Path *Path `json:"path,omitempty"`
+	// Label and Uuid are applied after the filesystem is created, or to the
+	// existing filesystem if it is not.
+	Label *string `json:"label,omitempty"`
+	Uuid  *string `json:"uuid,omitempty"`
+	// MountOptions are used whenever the filesystem is mounted, both by
+	// Ignition and on the target system.
+	MountOptions MountOptions `json:"mountOptions,omitempty"`
 }
 
 type FilesystemCreate struct {
-	Force   bool        `json:"force,omitempty"`
-	Options MkfsOptions `json:"options,omitempty"`
+	Force      bool             `json:"force,omitempty"`
+	Options    MkfsOptions      `json:"options,omitempty"`
+	Subvolumes []BtrfsSubvolume `json:"subvolumes,omitempty"`
+	Projects   []XfsProject     `json:"projects,omitempty"`
+	// Discard, if set, overrides whether mkfs discards the device's blocks.
+	Discard *bool `json:"discard,omitempty"`
+}
+
+// BtrfsSubvolume is a subvolume created along with a btrfs filesystem. If it
+// has a path, it is mounted there on the target system.
+type BtrfsSubvolume struct {
+	Name    string `json:"name,omitempty"`
+	Default bool   `json:"default,omitempty"`
+	Path    *Path  `json:"path,omitempty"`
+}
+
+// FilesystemNetwork describes a remote filesystem that is mounted on the
+// target system by a generated mount unit.
+type FilesystemNetwork struct {
+	Format  NetworkFormat `json:"format,omitempty"`
+	Source  string        `json:"source,omitempty"`
+	Path    Path          `json:"path,omitempty"`
+	Options MountOptions  `json:"options,omitempty"`
+}
+
+// FilesystemTmpfs describes a tmpfs that is mounted on the target system by a
+// generated mount unit.
+type FilesystemTmpfs struct {
+	Path    Path         `json:"path,omitempty"`
+	Size    string       `json:"size,omitempty"`
+	Mode    *NodeMode    `json:"mode,omitempty"`
+	Options MountOptions `json:"options,omitempty"`
+}
+
+// FilesystemOverlay describes an overlayfs that is mounted on the target
+// system by a generated mount unit. Without an upper directory the overlay is
+// read-only.
+type FilesystemOverlay struct {
+	Path    Path         `json:"path,omitempty"`
+	Lower   []Path       `json:"lower,omitempty"`
+	Upper   *Path        `json:"upper,omitempty"`
+	Work    *Path        `json:"work,omitempty"`
+	Options MountOptions `json:"options,omitempty"`
+}
+
+type MountOptions []string
+
+// HasSubvol returns whether the options select a btrfs subvolume.
+func (o MountOptions) HasSubvol() bool {
+	return len(o.WithoutSubvol()) != len(o)
+}
+
+// WithoutSubvol returns the options without any selecting a btrfs subvolume,
+// so that another can be selected instead.
+func (o MountOptions) WithoutSubvol() MountOptions {
+	var opts MountOptions
+	for _, opt := range o {
+		if !strings.HasPrefix(opt, "subvol=") && !strings.HasPrefix(opt, "subvolid=") {
+			opts = append(opts, opt)
+		}
+	}
+	return opts
+}
+
+// TargetOnly returns whether the filesystem is only mounted on the target
+// system, in which case Ignition cannot write into it.
+func (f Filesystem) TargetOnly() bool {
+	return f.Network != nil || f.Tmpfs != nil || f.Overlay != nil
 }
 
 func (f Filesystem) Validate() report.Report {
+	kinds := 0
+	for _, set := range []bool{f.Mount != nil, f.Path != nil, f.Network != nil, f.Tmpfs != nil, f.Overlay != nil} {
+		if set {
+			kinds++
+		}
+	}
+	if kinds > 1 && f.TargetOnly() {
+		return report.ReportFromError(ErrFilesystemTargetKinds, report.EntryError)
+	}
+	if f.TargetOnly() {
+		return report.Report{}
+	}
 	if f.Mount == nil && f.Path == nil {
 		return report.ReportFromError(ErrFilesystemNoMountPath, report.EntryError)
 	}
@@ -57,7 +167,7 @@ type FilesystemFormat string
 
 func (f FilesystemFormat) Validate() report.Report {
 	switch f {
-	case "ext4", "btrfs", "xfs":
+	case "ext4", "btrfs", "xfs", "vfat", "swap":
 		return report.Report{}
 	default:
 		return report.ReportFromError(ErrFilesystemInvalidFormat, report.EntryError)
@@ -65,3 +175,129 @@ func (f FilesystemFormat) Validate() report.Report {
 }
 
 type MkfsOptions []string
+
+func (n FilesystemNetwork) Validate() report.Report {
+	switch n.Format {
+	case "nfs", "nfs4":
+		if !strings.Contains(n.Source, ":") {
+			return report.ReportFromError(ErrNetworkInvalidSource, report.EntryError)
+		}
+	case "cifs":
+		if !strings.HasPrefix(n.Source, "//") {
+			return report.ReportFromError(ErrNetworkInvalidSource, report.EntryError)
+		}
+	}
+	return report.Report{}
+}
+
+// XfsProject is a project quota set up along with an xfs filesystem. The
+// directory is an absolute path within the filesystem.
+type XfsProject struct {
+	Id        int    `json:"id,omitempty"`
+	Directory Path   `json:"directory,omitempty"`
+	SoftLimit string `json:"softLimit,omitempty"`
+	HardLimit string `json:"hardLimit,omitempty"`
+}
+
+var (
+	xfsLimitRegexp = regexp.MustCompile(`^[0-9]+[kmgt]?$`)
+	uuidRegexp     = regexp.MustCompile("^[[:xdigit:]]{8}-[[:xdigit:]]{4}-[[:xdigit:]]{4}-[[:xdigit:]]{4}-[[:xdigit:]]{12}$")
+	vfatUuidRegexp = regexp.MustCompile("^[[:xdigit:]]{4}-[[:xdigit:]]{4}$")
+)
+
+// filesystemLabelLengths are the maximum label lengths of each format.
+var filesystemLabelLengths = map[FilesystemFormat]int{
+	"btrfs": 255,
+	"ext4":  16,
+	"swap":  15,
+	"vfat":  11,
+	"xfs":   12,
+}
+
+func (m FilesystemMount) Validate() report.Report {
+	if max, ok := filesystemLabelLengths[m.Format]; ok && m.Label != nil && len(*m.Label) > max {
+		return report.ReportFromError(ErrFilesystemLabelTooLong, report.EntryError)
+	}
+	if m.Uuid != nil && m.Format == "vfat" && !vfatUuidRegexp.MatchString(*m.Uuid) {
+		return report.ReportFromError(ErrVfatInvalidUuid, report.EntryError)
+	}
+	if m.Uuid != nil && m.Format != "vfat" && !uuidRegexp.MatchString(*m.Uuid) {
+		return report.ReportFromError(ErrFilesystemInvalidUuid, report.EntryError)
+	}
+	if m.Path != nil && m.Format == "swap" {
+		return report.ReportFromError(ErrSwapMountPath, report.EntryError)
+	}
+	if m.Format != "btrfs" && m.MountOptions.HasSubvol() {
+		return report.ReportFromError(ErrSubvolOptionNotBtrfs, report.EntryError)
+	}
+	if m.Create == nil {
+		return report.Report{}
+	}
+	if len(m.Create.Projects) > 0 && m.Format != "xfs" {
+		return report.ReportFromError(ErrProjectsNotXfs, report.EntryError)
+	}
+	if len(m.Create.Subvolumes) == 0 {
+		return report.Report{}
+	}
+	if m.Format != "btrfs" {
+		return report.ReportFromError(ErrSubvolumesNotBtrfs, report.EntryError)
+	}
+	defaults := 0
+	for _, sv := range m.Create.Subvolumes {
+		if sv.Default {
+			defaults++
+		}
+	}
+	if defaults > 1 {
+		return report.ReportFromError(ErrSubvolumeManyDefaults, report.EntryError)
+	}
+	return report.Report{}
+}
+
+func (sv BtrfsSubvolume) Validate() report.Report {
+	if sv.Name == "" || path.IsAbs(sv.Name) {
+		return report.ReportFromError(ErrSubvolumeInvalidName, report.EntryError)
+	}
+	for _, elem := range strings.Split(sv.Name, "/") {
+		if elem == ".." {
+			return report.ReportFromError(ErrSubvolumeInvalidName, report.EntryError)
+		}
+	}
+	return report.Report{}
+}
+
+func (p XfsProject) Validate() report.Report {
+	if p.Id <= 0 {
+		return report.ReportFromError(ErrProjectInvalidId, report.EntryError)
+	}
+	for _, limit := range []string{p.SoftLimit, p.HardLimit} {
+		if limit != "" && !xfsLimitRegexp.MatchString(limit) {
+			return report.ReportFromError(ErrProjectInvalidLimit, report.EntryError)
+		}
+	}
+	return report.Report{}
+}
+
+func (o FilesystemOverlay) Validate() report.Report {
+	if len(o.Lower) == 0 {
+		return report.ReportFromError(ErrOverlayNoLower, report.EntryError)
+	}
+	if o.Upper != nil && o.Work == nil {
+		return report.ReportFromError(ErrOverlayNoWork, report.EntryError)
+	}
+	if o.Upper == nil && o.Work != nil {
+		return report.ReportFromError(ErrOverlayNoUpper, report.EntryError)
+	}
+	return report.Report{}
+}
+
+type NetworkFormat string
+
+func (f NetworkFormat) Validate() report.Report {
+	switch f {
+	case "nfs", "nfs4", "cifs":
+		return report.Report{}
+	default:
+		return report.ReportFromError(ErrNetworkInvalidFormat, report.EntryError)
+	}
+}
@@ -41,6 +41,14 @@ func TestFilesystemFormatValidate(t *testing.T) {
 			in:  in{format: FilesystemFormat("btrfs")},
 			out: out{},
 		},
+		{
+			in:  in{format: FilesystemFormat("vfat")},
+			out: out{},
+		},
+		{
+			in:  in{format: FilesystemFormat("swap")},
+			out: out{},
+		},
 		{
 			in:  in{format: FilesystemFormat("")},
 			out: out{err: ErrFilesystemInvalidFormat},
@@ -79,6 +87,22 @@ func TestFilesystemValidate(t *testing.T) {
 			in:  in{filesystem: Filesystem{Path: func(p Path) *Path { return &p }("/mount"), Mount: &FilesystemMount{Device: "/foo", Format: "ext4"}}},
 			out: out{err: ErrFilesystemMountAndPath},
 		},
+		{
+			in:  in{filesystem: Filesystem{Network: &FilesystemNetwork{Format: "nfs", Source: "server:/export", Path: "/srv"}}},
+			out: out{},
+		},
+		{
+			in:  in{filesystem: Filesystem{Path: func(p Path) *Path { return &p }("/mount"), Network: &FilesystemNetwork{Format: "nfs", Source: "server:/export", Path: "/srv"}}},
+			out: out{err: ErrFilesystemTargetKinds},
+		},
+		{
+			in:  in{filesystem: Filesystem{Tmpfs: &FilesystemTmpfs{Path: "/scratch"}}},
+			out: out{},
+		},
+		{
+			in:  in{filesystem: Filesystem{Tmpfs: &FilesystemTmpfs{Path: "/scratch"}, Overlay: &FilesystemOverlay{Path: "/etc", Lower: []Path{"/etc"}}}},
+			out: out{err: ErrFilesystemTargetKinds},
+		},
 		{
 			in:  in{filesystem: Filesystem{}},
 			out: out{err: ErrFilesystemNoMountPath},
@@ -92,3 +116,258 @@ func TestFilesystemValidate(t *testing.T) {
 		}
 	}
 }
+
+func TestFilesystemNetworkValidate(t *testing.T) {
+	type in struct {
+		network FilesystemNetwork
+	}
+	type out struct {
+		err error
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{network: FilesystemNetwork{Format: "nfs4", Source: "server:/export", Path: "/srv"}},
+			out: out{},
+		},
+		{
+			in:  in{network: FilesystemNetwork{Format: "cifs", Source: "//server/share", Path: "/srv"}},
+			out: out{},
+		},
+		{
+			in:  in{network: FilesystemNetwork{Format: "nfs", Source: "/export", Path: "/srv"}},
+			out: out{err: ErrNetworkInvalidSource},
+		},
+		{
+			in:  in{network: FilesystemNetwork{Format: "cifs", Source: "server:/share", Path: "/srv"}},
+			out: out{err: ErrNetworkInvalidSource},
+		},
+	}
+
+	for i, test := range tests {
+		err := test.in.network.Validate()
+		if !reflect.DeepEqual(report.ReportFromError(test.out.err, report.EntryError), err) {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.out.err, err)
+		}
+	}
+}
+
+func TestFilesystemOverlayValidate(t *testing.T) {
+	type in struct {
+		overlay FilesystemOverlay
+	}
+	type out struct {
+		err error
+	}
+
+	upper := Path("/var/overlay/etc/upper")
+	work := Path("/var/overlay/etc/work")
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{overlay: FilesystemOverlay{Path: "/etc", Lower: []Path{"/etc"}, Upper: &upper, Work: &work}},
+			out: out{},
+		},
+		{
+			in:  in{overlay: FilesystemOverlay{Path: "/opt", Lower: []Path{"/usr/share/opt", "/opt"}}},
+			out: out{},
+		},
+		{
+			in:  in{overlay: FilesystemOverlay{Path: "/etc", Upper: &upper, Work: &work}},
+			out: out{err: ErrOverlayNoLower},
+		},
+		{
+			in:  in{overlay: FilesystemOverlay{Path: "/etc", Lower: []Path{"/etc"}, Upper: &upper}},
+			out: out{err: ErrOverlayNoWork},
+		},
+		{
+			in:  in{overlay: FilesystemOverlay{Path: "/etc", Lower: []Path{"/etc"}, Work: &work}},
+			out: out{err: ErrOverlayNoUpper},
+		},
+	}
+
+	for i, test := range tests {
+		err := test.in.overlay.Validate()
+		if !reflect.DeepEqual(report.ReportFromError(test.out.err, report.EntryError), err) {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.out.err, err)
+		}
+	}
+}
+
+func TestFilesystemMountValidate(t *testing.T) {
+	type in struct {
+		mount FilesystemMount
+	}
+	type out struct {
+		err error
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{mount: FilesystemMount{Device: "/dev/sda1", Format: "ext4"}},
+			out: out{},
+		},
+		{
+			in: in{mount: FilesystemMount{Device: "/dev/sda1", Format: "btrfs", Create: &FilesystemCreate{
+				Subvolumes: []BtrfsSubvolume{{Name: "@", Default: true}, {Name: "@home"}},
+			}}},
+			out: out{},
+		},
+		{
+			in: in{mount: FilesystemMount{Device: "/dev/sda1", Format: "xfs", Create: &FilesystemCreate{
+				Subvolumes: []BtrfsSubvolume{{Name: "@"}},
+			}}},
+			out: out{err: ErrSubvolumesNotBtrfs},
+		},
+		{
+			in: in{mount: FilesystemMount{Device: "/dev/sda1", Format: "btrfs", Create: &FilesystemCreate{
+				Subvolumes: []BtrfsSubvolume{{Name: "@", Default: true}, {Name: "@home", Default: true}},
+			}}},
+			out: out{err: ErrSubvolumeManyDefaults},
+		},
+		{
+			in: in{mount: FilesystemMount{Device: "/dev/sda1", Format: "xfs", Create: &FilesystemCreate{
+				Projects: []XfsProject{{Id: 10, Directory: "/tenants/a", HardLimit: "10g"}},
+			}}},
+			out: out{},
+		},
+		{
+			in: in{mount: FilesystemMount{Device: "/dev/sda1", Format: "ext4", Create: &FilesystemCreate{
+				Projects: []XfsProject{{Id: 10, Directory: "/tenants/a", HardLimit: "10g"}},
+			}}},
+			out: out{err: ErrProjectsNotXfs},
+		},
+		{
+			in:  in{mount: FilesystemMount{Device: "/dev/sda1", Format: "ext4", Label: func(s string) *string { return &s }("DATA"), Uuid: func(s string) *string { return &s }("9b3c4a6e-2f1d-4c8e-a0b7-5d6e7f809a1b")}},
+			out: out{},
+		},
+		{
+			in:  in{mount: FilesystemMount{Device: "/dev/sda1", Format: "xfs", Label: func(s string) *string { return &s }("much-too-long")}},
+			out: out{err: ErrFilesystemLabelTooLong},
+		},
+		{
+			in:  in{mount: FilesystemMount{Device: "/dev/sda1", Format: "ext4", Uuid: func(s string) *string { return &s }("not-a-uuid")}},
+			out: out{err: ErrFilesystemInvalidUuid},
+		},
+		{
+			in:  in{mount: FilesystemMount{Device: "/dev/sda1", Format: "vfat", Label: func(s string) *string { return &s }("EFI-SYSTEM"), Uuid: func(s string) *string { return &s }("1A2B-3C4D")}},
+			out: out{},
+		},
+		{
+			in:  in{mount: FilesystemMount{Device: "/dev/sda1", Format: "vfat", Uuid: func(s string) *string { return &s }("9b3c4a6e-2f1d-4c8e-a0b7-5d6e7f809a1b")}},
+			out: out{err: ErrVfatInvalidUuid},
+		},
+		{
+			in:  in{mount: FilesystemMount{Device: "/dev/sda1", Format: "vfat", Label: func(s string) *string { return &s }("TOO-LONG-LABEL")}},
+			out: out{err: ErrFilesystemLabelTooLong},
+		},
+		{
+			in:  in{mount: FilesystemMount{Device: "/dev/sda2", Format: "swap", Create: &FilesystemCreate{}, Path: func(p Path) *Path { return &p }("/swap")}},
+			out: out{err: ErrSwapMountPath},
+		},
+		{
+			in:  in{mount: FilesystemMount{Device: "/dev/sda1", Format: "btrfs", MountOptions: MountOptions{"subvol=@", "compress=zstd"}}},
+			out: out{},
+		},
+		{
+			in:  in{mount: FilesystemMount{Device: "/dev/sda1", Format: "ext4", MountOptions: MountOptions{"noatime", "subvolid=5"}}},
+			out: out{err: ErrSubvolOptionNotBtrfs},
+		},
+	}
+
+	for i, test := range tests {
+		err := test.in.mount.Validate()
+		if !reflect.DeepEqual(report.ReportFromError(test.out.err, report.EntryError), err) {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.out.err, err)
+		}
+	}
+}
+
+func TestBtrfsSubvolumeValidate(t *testing.T) {
+	type in struct {
+		subvolume BtrfsSubvolume
+	}
+	type out struct {
+		err error
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{subvolume: BtrfsSubvolume{Name: "@home"}},
+			out: out{},
+		},
+		{
+			in:  in{subvolume: BtrfsSubvolume{Name: "var/lib/docker"}},
+			out: out{},
+		},
+		{
+			in:  in{subvolume: BtrfsSubvolume{}},
+			out: out{err: ErrSubvolumeInvalidName},
+		},
+		{
+			in:  in{subvolume: BtrfsSubvolume{Name: "/@"}},
+			out: out{err: ErrSubvolumeInvalidName},
+		},
+		{
+			in:  in{subvolume: BtrfsSubvolume{Name: "@/../escape"}},
+			out: out{err: ErrSubvolumeInvalidName},
+		},
+	}
+
+	for i, test := range tests {
+		err := test.in.subvolume.Validate()
+		if !reflect.DeepEqual(report.ReportFromError(test.out.err, report.EntryError), err) {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.out.err, err)
+		}
+	}
+}
+
+func TestXfsProjectValidate(t *testing.T) {
+	type in struct {
+		project XfsProject
+	}
+	type out struct {
+		err error
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{project: XfsProject{Id: 1, Directory: "/a", SoftLimit: "512m", HardLimit: "1g"}},
+			out: out{},
+		},
+		{
+			in:  in{project: XfsProject{Id: 1, Directory: "/a"}},
+			out: out{},
+		},
+		{
+			in:  in{project: XfsProject{Directory: "/a", HardLimit: "1g"}},
+			out: out{err: ErrProjectInvalidId},
+		},
+		{
+			in:  in{project: XfsProject{Id: 1, Directory: "/a", HardLimit: "1GiB"}},
+			out: out{err: ErrProjectInvalidLimit},
+		},
+	}
+
+	for i, test := range tests {
+		err := test.in.project.Validate()
+		if !reflect.DeepEqual(report.ReportFromError(test.out.err, report.EntryError), err) {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.out.err, err)
+		}
+	}
+}
@@ -14,9 +14,31 @@
 
 package types
 
+import (
+	"errors"
+
+	"github.com/coreos/ignition/config/validate/report"
+)
+
+var (
+	ErrGroupNameEmpty = errors.New("groups require a name")
+)
+
+// Group describes a group created in the target root. A group which already
+// exists there is left as it is, provided its GID matches.
 type Group struct {
 	Name         string `json:"name,omitempty"`
 	Gid          *uint  `json:"gid,omitempty"`
 	PasswordHash string `json:"passwordHash,omitempty"`
 	System       bool   `json:"system,omitempty"`
 }
+
+func (g Group) Validate() report.Report {
+	if g.Name == "" {
+		return report.ReportFromError(ErrGroupNameEmpty, report.EntryError)
+	}
+	if !validAccountField(g.Name) || !validAccountField(g.PasswordHash) {
+		return report.ReportFromError(ErrAccountFieldChar, report.EntryError)
+	}
+	return report.Report{}
+}
@@ -0,0 +1,39 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"errors"
+
+	"github.com/coreos/ignition/config/validate/report"
+)
+
+var (
+	ErrGrowRootNoPartition = errors.New("growRoot requires a partition number")
+)
+
+// GrowRoot enlarges a partition, and the filesystem on it, to fill the free
+// space following it on its disk.
+type GrowRoot struct {
+	Device    Path `json:"device,omitempty"`
+	Partition int  `json:"partition,omitempty"`
+}
+
+func (g GrowRoot) Validate() report.Report {
+	if g.Partition < 1 {
+		return report.ReportFromError(ErrGrowRootNoPartition, report.EntryError)
+	}
+	return report.Report{}
+}
@@ -0,0 +1,56 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/coreos/ignition/config/validate/report"
+)
+
+func TestGrowRootValidate(t *testing.T) {
+	type in struct {
+		grow GrowRoot
+	}
+	type out struct {
+		err error
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{grow: GrowRoot{Device: "/dev/sda", Partition: 9}},
+			out: out{},
+		},
+		{
+			in:  in{grow: GrowRoot{Device: "/dev/sda"}},
+			out: out{err: ErrGrowRootNoPartition},
+		},
+		{
+			in:  in{grow: GrowRoot{Device: "/dev/sda", Partition: -1}},
+			out: out{err: ErrGrowRootNoPartition},
+		},
+	}
+
+	for i, test := range tests {
+		err := test.in.grow.Validate()
+		if !reflect.DeepEqual(report.ReportFromError(test.out.err, report.EntryError), err) {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.out.err, err)
+		}
+	}
+}
@@ -59,6 +59,8 @@ func (h Hash) MarshalJSON() ([]byte, error) {
 func (h Hash) Validate() report.Report {
 	var hash crypto.Hash
 	switch h.Function {
+	case "sha256":
+		hash = crypto.SHA256
 	case "sha512":
 		hash = crypto.SHA512
 	default:
@@ -77,6 +77,14 @@ func TestHashValidate(t *testing.T) {
 			in:  in{hash: Hash{Function: "xor"}},
 			out: out{err: ErrHashUnrecognized},
 		},
+		{
+			in:  in{hash: Hash{Function: "sha256", Sum: "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"}},
+			out: out{},
+		},
+		{
+			in:  in{hash: Hash{Function: "sha256", Sum: "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"}},
+			out: out{err: ErrHashWrongSize},
+		},
 		{
 			in:  in{hash: Hash{Function: "sha512", Sum: "123"}},
 			out: out{err: ErrHashWrongSize},
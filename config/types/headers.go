@@ -0,0 +1,80 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+
+	"github.com/coreos/ignition/config/validate/report"
+)
+
+var (
+	ErrHttpHeaderName      = errors.New("http headers must have a name")
+	ErrDuplicateHttpHeader = errors.New("http header specified more than once")
+	ErrHttpHeadersNotHttp  = errors.New("http headers can only be used with http or https sources")
+)
+
+// HttpHeader is a header sent verbatim when fetching a resource. It takes
+// precedence over any auth rule for the same header.
+type HttpHeader struct {
+	Name  string `json:"name,omitempty"`
+	Value string `json:"value,omitempty"`
+}
+
+func (h HttpHeader) Validate() report.Report {
+	if h.Name == "" {
+		return report.ReportFromError(ErrHttpHeaderName, report.EntryError)
+	}
+	return report.Report{}
+}
+
+type HttpHeaders []HttpHeader
+
+func (hs HttpHeaders) Validate() report.Report {
+	seen := map[string]struct{}{}
+	for _, h := range hs {
+		name := http.CanonicalHeaderKey(h.Name)
+		if _, ok := seen[name]; ok {
+			return report.ReportFromError(ErrDuplicateHttpHeader, report.EntryError)
+		}
+		seen[name] = struct{}{}
+	}
+	return report.Report{}
+}
+
+// Header returns the headers in the form used by net/http.
+func (hs HttpHeaders) Header() http.Header {
+	header := http.Header{}
+	for _, h := range hs {
+		header.Set(h.Name, h.Value)
+	}
+	return header
+}
+
+// validateHttpHeaders checks that headers are only given alongside a source
+// which is fetched over http.
+func validateHttpHeaders(source Url, headers HttpHeaders) report.Report {
+	if len(headers) == 0 {
+		return report.Report{}
+	}
+	switch url.URL(source).Scheme {
+	case "http", "https":
+		return report.Report{}
+	default:
+		return report.ReportFromError(ErrHttpHeadersNotHttp, report.EntryError)
+	}
+}
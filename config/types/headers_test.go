@@ -0,0 +1,87 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/coreos/ignition/config/validate/report"
+)
+
+func TestHttpHeadersValidate(t *testing.T) {
+	type in struct {
+		headers HttpHeaders
+	}
+	type out struct {
+		report report.Report
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{headers: HttpHeaders{{Name: "Authorization", Value: "Bearer token"}, {Name: "X-Token"}}},
+			out: out{},
+		},
+		{
+			in:  in{headers: HttpHeaders{{Name: "x-token", Value: "a"}, {Name: "X-Token", Value: "b"}}},
+			out: out{report: report.ReportFromError(ErrDuplicateHttpHeader, report.EntryError)},
+		},
+	}
+
+	for i, test := range tests {
+		r := test.in.headers.Validate()
+		if !reflect.DeepEqual(test.out.report, r) {
+			t.Errorf("#%d: bad report: want %v, got %v", i, test.out.report, r)
+		}
+	}
+}
+
+func TestConfigReferenceValidate(t *testing.T) {
+	type in struct {
+		ref ConfigReference
+	}
+	type out struct {
+		report report.Report
+	}
+
+	headers := HttpHeaders{{Name: "X-Token", Value: "secret"}}
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{ref: ConfigReference{Source: Url{Scheme: "https", Host: "example.com"}, HttpHeaders: headers}},
+			out: out{},
+		},
+		{
+			in:  in{ref: ConfigReference{Source: Url{Scheme: "data", Opaque: ",{}"}, HttpHeaders: headers}},
+			out: out{report: report.ReportFromError(ErrHttpHeadersNotHttp, report.EntryError)},
+		},
+		{
+			in:  in{ref: ConfigReference{Source: Url{Scheme: "data", Opaque: ",{}"}}},
+			out: out{},
+		},
+	}
+
+	for i, test := range tests {
+		r := test.in.ref.Validate()
+		if !reflect.DeepEqual(test.out.report, r) {
+			t.Errorf("#%d: bad report: want %v, got %v", i, test.out.report, r)
+		}
+	}
+}
@@ -32,6 +32,12 @@ type Ignition struct {
 	Version  IgnitionVersion `json:"version,omitempty"  merge:"old"`
 	Config   IgnitionConfig  `json:"config,omitempty"   merge:"new"`
 	Timeouts Timeouts        `json:"timeouts,omitempty" merge:"new"`
+	Auth     []UrlAuth       `json:"auth,omitempty"`
+	Dns      Dns             `json:"dns,omitempty"`
+	Security Security        `json:"security,omitempty"`
+	Proxy    Proxy           `json:"proxy,omitempty"    merge:"new"`
+	// StatusReport, if set, is notified of the outcome of each stage.
+	StatusReport *StatusReport `json:"statusReport,omitempty"`
 }
 
 type IgnitionConfig struct {
@@ -41,7 +47,16 @@ type IgnitionConfig struct {
 
 type ConfigReference struct {
 	Source       Url          `json:"source,omitempty"`
+	Compression  Compression  `json:"compression,omitempty"`
 	Verification Verification `json:"verification,omitempty"`
+	HttpHeaders  HttpHeaders  `json:"httpHeaders,omitempty"`
+	// Signature, if set, must verify the config once it is fetched and
+	// decompressed.
+	Signature *ConfigSignature `json:"signature,omitempty"`
+}
+
+func (c ConfigReference) Validate() report.Report {
+	return validateHttpHeaders(c.Source, c.HttpHeaders)
 }
 
 type IgnitionVersion semver.Version
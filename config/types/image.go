@@ -0,0 +1,40 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"errors"
+
+	"github.com/coreos/ignition/config/validate/report"
+)
+
+var (
+	ErrImageNoSource = errors.New("images require a source")
+)
+
+// Image is a raw disk or partition image to be written onto a block device.
+type Image struct {
+	Device       Path         `json:"device,omitempty"`
+	Source       Url          `json:"source,omitempty"`
+	Compression  Compression  `json:"compression,omitempty"`
+	Verification Verification `json:"verification,omitempty"`
+}
+
+func (i Image) Validate() report.Report {
+	if i.Source.String() == "" {
+		return report.ReportFromError(ErrImageNoSource, report.EntryError)
+	}
+	return report.Report{}
+}
@@ -0,0 +1,62 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/coreos/ignition/config/validate/report"
+)
+
+var (
+	ErrKernelArgumentInvalid  = errors.New("kernel arguments must be non-empty and cannot contain whitespace")
+	ErrKernelArgumentConflict = errors.New("kernel arguments cannot be both required and forbidden")
+)
+
+// KernelArguments describes the arguments the target system's kernel should
+// and should not be booted with. Arguments are compared verbatim, so "quiet"
+// and "console=ttyS0" are distinct from "console=tty0".
+type KernelArguments struct {
+	ShouldExist    []KernelArgument `json:"shouldExist,omitempty"`
+	ShouldNotExist []KernelArgument `json:"shouldNotExist,omitempty"`
+}
+
+func (k KernelArguments) Validate() report.Report {
+	exist := map[KernelArgument]bool{}
+	for _, arg := range k.ShouldExist {
+		exist[arg] = true
+	}
+	for _, arg := range k.ShouldNotExist {
+		if exist[arg] {
+			return report.ReportFromError(ErrKernelArgumentConflict, report.EntryError)
+		}
+	}
+	return report.Report{}
+}
+
+// Empty reports whether no arguments are listed.
+func (k KernelArguments) Empty() bool {
+	return len(k.ShouldExist) == 0 && len(k.ShouldNotExist) == 0
+}
+
+type KernelArgument string
+
+func (a KernelArgument) Validate() report.Report {
+	if a == "" || strings.ContainsAny(string(a), " \t\n") {
+		return report.ReportFromError(ErrKernelArgumentInvalid, report.EntryError)
+	}
+	return report.Report{}
+}
@@ -0,0 +1,86 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/coreos/ignition/config/validate/report"
+)
+
+func TestKernelArgumentsValidate(t *testing.T) {
+	type in struct {
+		kargs KernelArguments
+	}
+	type out struct {
+		report report.Report
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{kargs: KernelArguments{ShouldExist: []KernelArgument{"nosmt", "console=ttyS0"}, ShouldNotExist: []KernelArgument{"console=tty0"}}},
+			out: out{report: report.Report{}},
+		},
+		{
+			in:  in{kargs: KernelArguments{ShouldExist: []KernelArgument{"nosmt"}, ShouldNotExist: []KernelArgument{"nosmt"}}},
+			out: out{report: report.ReportFromError(ErrKernelArgumentConflict, report.EntryError)},
+		},
+	}
+
+	for i, test := range tests {
+		r := test.in.kargs.Validate()
+		if !reflect.DeepEqual(test.out.report, r) {
+			t.Errorf("#%d: bad report: want %v, got %v", i, test.out.report, r)
+		}
+	}
+}
+
+func TestKernelArgumentValidate(t *testing.T) {
+	type in struct {
+		arg KernelArgument
+	}
+	type out struct {
+		report report.Report
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{arg: KernelArgument("mitigations=auto,nosmt")},
+			out: out{report: report.Report{}},
+		},
+		{
+			in:  in{arg: KernelArgument("")},
+			out: out{report: report.ReportFromError(ErrKernelArgumentInvalid, report.EntryError)},
+		},
+		{
+			in:  in{arg: KernelArgument("quiet splash")},
+			out: out{report: report.ReportFromError(ErrKernelArgumentInvalid, report.EntryError)},
+		},
+	}
+
+	for i, test := range tests {
+		r := test.in.arg.Validate()
+		if !reflect.DeepEqual(test.out.report, r) {
+			t.Errorf("#%d: bad report: want %v, got %v", i, test.out.report, r)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"errors"
+	"path"
+
+	"github.com/coreos/ignition/config/validate/report"
+)
+
+var (
+	ErrLinkNoTarget     = errors.New("links require a target")
+	ErrHardLinkRelative = errors.New("hard link targets must be absolute paths")
+	ErrLinkMode         = errors.New("links have no mode of their own; the mode is ignored")
+)
+
+// Link represents symbolic and hard links. With Overwrite, whatever already
+// exists at the path is replaced; otherwise an existing link to the same
+// target is kept and anything else is an error.
+type Link struct {
+	Node
+	// Target is what a symbolic link points to, verbatim, or the path of the
+	// file a hard link shares, on the same filesystem.
+	Target string `json:"target,omitempty"`
+	Hard   bool   `json:"hard,omitempty"`
+}
+
+func (l Link) Validate() report.Report {
+	if l.Target == "" {
+		return report.ReportFromError(ErrLinkNoTarget, report.EntryError)
+	}
+	if l.Hard && !path.IsAbs(l.Target) {
+		return report.ReportFromError(ErrHardLinkRelative, report.EntryError)
+	}
+	if l.Mode != 0 {
+		return report.ReportFromError(ErrLinkMode, report.EntryWarning)
+	}
+	return report.Report{}
+}
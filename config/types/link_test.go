@@ -0,0 +1,66 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/coreos/ignition/config/validate/report"
+)
+
+func TestLinkValidate(t *testing.T) {
+	type in struct {
+		link Link
+	}
+	type out struct {
+		report report.Report
+	}
+
+	node := Node{Filesystem: "root", Path: "/etc/localtime"}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{link: Link{Node: node, Target: "../usr/share/zoneinfo/UTC"}},
+			out: out{},
+		},
+		{
+			in:  in{link: Link{Node: node}},
+			out: out{report: report.ReportFromError(ErrLinkNoTarget, report.EntryError)},
+		},
+		{
+			in:  in{link: Link{Node: node, Target: "/usr/share/zoneinfo/UTC", Hard: true}},
+			out: out{},
+		},
+		{
+			in:  in{link: Link{Node: node, Target: "../usr/share/zoneinfo/UTC", Hard: true}},
+			out: out{report: report.ReportFromError(ErrHardLinkRelative, report.EntryError)},
+		},
+		{
+			in:  in{link: Link{Node: Node{Filesystem: "root", Path: "/etc/localtime", Mode: 0644}, Target: "/usr/share/zoneinfo/UTC"}},
+			out: out{report: report.ReportFromError(ErrLinkMode, report.EntryWarning)},
+		},
+	}
+
+	for i, test := range tests {
+		r := test.in.link.Validate()
+		if !reflect.DeepEqual(test.out.report, r) {
+			t.Errorf("#%d: bad report: want %v, got %v", i, test.out.report, r)
+		}
+	}
+}
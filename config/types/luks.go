@@ -0,0 +1,161 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/coreos/ignition/config/validate/report"
+)
+
+var (
+	ErrLuksNoName      = errors.New("luks volumes require a name")
+	ErrLuksInvalidName = errors.New("luks volume names may not contain slashes")
+	ErrLuksNoDevice    = errors.New("luks volumes require a device")
+	ErrLuksNoKeyFile   = errors.New("luks volumes require a key file source or clevis pins")
+	ErrLuksInvalidUuid = errors.New("invalid luks volume uuid")
+	ErrLuksOption      = errors.New("luks options may not set the volume's type, key file, label, or uuid")
+	ErrClevisNoPins    = errors.New("clevis requires tpm2 or at least one tang server")
+	ErrClevisThreshold = errors.New("clevis threshold must be between 1 and the number of pins")
+	ErrTangNoUrl       = errors.New("tang servers require a url")
+
+	luksUuidRegexp = regexp.MustCompile("^[[:xdigit:]]{8}-[[:xdigit:]]{4}-[[:xdigit:]]{4}-[[:xdigit:]]{4}-[[:xdigit:]]{12}$")
+)
+
+// Luks is a LUKS2 encrypted volume, opened as /dev/mapper/<name> so that
+// filesystems can be created within it.
+type Luks struct {
+	Name       string      `json:"name,omitempty"`
+	Device     Path        `json:"device,omitempty"`
+	KeyFile    LuksKeyFile `json:"keyFile,omitempty"`
+	Label      *string     `json:"label,omitempty"`
+	Uuid       *string     `json:"uuid,omitempty"`
+	Options    LuksOptions `json:"options,omitempty"`
+	WipeVolume bool        `json:"wipeVolume,omitempty"`
+	Clevis     *Clevis     `json:"clevis,omitempty"`
+	// Discard passes discards from the filesystems within the volume
+	// through to the device, both when Ignition opens it and at boot.
+	Discard bool `json:"discard,omitempty"`
+}
+
+// Clevis binds a volume to TPM2 sealing and tang servers so that it unlocks
+// automatically at boot. A volume with clevis pins and no key file is given a
+// random key which is discarded once the pins are bound.
+type Clevis struct {
+	Tpm2 bool   `json:"tpm2,omitempty"`
+	Tang []Tang `json:"tang,omitempty"`
+	// Threshold is how many of the pins must succeed to unlock the
+	// volume. Defaults to 1.
+	Threshold int `json:"threshold,omitempty"`
+}
+
+// Tang is a tang server whose advertisement is trusted if it is signed by the
+// key with Thumbprint, or unconditionally if Thumbprint is empty.
+type Tang struct {
+	Url        string `json:"url,omitempty"`
+	Thumbprint string `json:"thumbprint,omitempty"`
+}
+
+// Pins returns the number of pins the volume is bound to.
+func (c Clevis) Pins() int {
+	n := len(c.Tang)
+	if c.Tpm2 {
+		n++
+	}
+	return n
+}
+
+// EffectiveThreshold returns the threshold, defaulting to 1.
+func (c Clevis) EffectiveThreshold() int {
+	if c.Threshold == 0 {
+		return 1
+	}
+	return c.Threshold
+}
+
+func (c Clevis) Validate() report.Report {
+	if c.Pins() == 0 {
+		return report.ReportFromError(ErrClevisNoPins, report.EntryError)
+	}
+	if c.Threshold < 0 || c.Threshold > c.Pins() {
+		return report.ReportFromError(ErrClevisThreshold, report.EntryError)
+	}
+	return report.Report{}
+}
+
+func (t Tang) Validate() report.Report {
+	if t.Url == "" {
+		return report.ReportFromError(ErrTangNoUrl, report.EntryError)
+	}
+	return report.Report{}
+}
+
+// HasKeyFile returns whether the volume's key is given by the config, rather
+// than generated.
+func (l Luks) HasKeyFile() bool {
+	return l.KeyFile.Source.String() != ""
+}
+
+// LuksKeyFile is the key which unlocks a volume, fetched like the contents of
+// a file.
+type LuksKeyFile struct {
+	Source       Url          `json:"source,omitempty"`
+	Compression  Compression  `json:"compression,omitempty"`
+	Verification Verification `json:"verification,omitempty"`
+	HttpHeaders  HttpHeaders  `json:"httpHeaders,omitempty"`
+}
+
+// MapperDevice returns the path of the opened volume.
+func (l Luks) MapperDevice() string {
+	return "/dev/mapper/" + l.Name
+}
+
+func (l Luks) Validate() report.Report {
+	switch {
+	case l.Name == "":
+		return report.ReportFromError(ErrLuksNoName, report.EntryError)
+	case strings.Contains(l.Name, "/"):
+		return report.ReportFromError(ErrLuksInvalidName, report.EntryError)
+	case l.Device == "":
+		return report.ReportFromError(ErrLuksNoDevice, report.EntryError)
+	case !l.HasKeyFile() && l.Clevis == nil:
+		return report.ReportFromError(ErrLuksNoKeyFile, report.EntryError)
+	case l.Uuid != nil && !luksUuidRegexp.MatchString(*l.Uuid):
+		return report.ReportFromError(ErrLuksInvalidUuid, report.EntryError)
+	}
+	return report.Report{}
+}
+
+// LuksOptions are extra arguments to "cryptsetup luksFormat" (e.g.
+// "--cipher=aes-xts-plain64").
+type LuksOptions []string
+
+// managedLuksOptions are the cryptsetup options which Ignition derives from
+// the rest of the volume's config.
+var managedLuksOptions = []string{"--type", "-M", "--key-file", "-d", "--label", "--uuid"}
+
+func (o LuksOptions) Validate() report.Report {
+	for _, opt := range o {
+		name := strings.SplitN(opt, "=", 2)[0]
+		for _, m := range managedLuksOptions {
+			if name == m || (len(m) == 2 && strings.HasPrefix(opt, m)) {
+				return report.ReportFromError(ErrLuksOption, report.EntryError)
+			}
+		}
+	}
+	return report.Report{}
+}
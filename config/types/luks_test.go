@@ -0,0 +1,154 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/coreos/ignition/config/validate/report"
+)
+
+func TestLuksValidate(t *testing.T) {
+	type in struct {
+		luks Luks
+	}
+	type out struct {
+		err error
+	}
+
+	key := LuksKeyFile{Source: Url(url.URL{Scheme: "data", Opaque: ",secret"})}
+	uuid := func(s string) *string { return &s }
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{luks: Luks{Name: "data", Device: "/dev/sdb", KeyFile: key}},
+			out: out{},
+		},
+		{
+			in:  in{luks: Luks{Device: "/dev/sdb", KeyFile: key}},
+			out: out{err: ErrLuksNoName},
+		},
+		{
+			in:  in{luks: Luks{Name: "a/b", Device: "/dev/sdb", KeyFile: key}},
+			out: out{err: ErrLuksInvalidName},
+		},
+		{
+			in:  in{luks: Luks{Name: "data", KeyFile: key}},
+			out: out{err: ErrLuksNoDevice},
+		},
+		{
+			in:  in{luks: Luks{Name: "data", Device: "/dev/sdb"}},
+			out: out{err: ErrLuksNoKeyFile},
+		},
+		{
+			in:  in{luks: Luks{Name: "data", Device: "/dev/sdb", KeyFile: key, Uuid: uuid("9a3c4f2e-1b6d-4e8a-9c7f-2d5b8e1a4c6f")}},
+			out: out{},
+		},
+		{
+			in:  in{luks: Luks{Name: "data", Device: "/dev/sdb", KeyFile: key, Uuid: uuid("9a3c4f2e")}},
+			out: out{err: ErrLuksInvalidUuid},
+		},
+		{
+			in:  in{luks: Luks{Name: "data", Device: "/dev/sdb", Clevis: &Clevis{Tpm2: true}}},
+			out: out{},
+		},
+	}
+
+	for i, test := range tests {
+		r := test.in.luks.Validate()
+		if !reflect.DeepEqual(report.ReportFromError(test.out.err, report.EntryError), r) {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.out.err, r)
+		}
+	}
+}
+
+func TestLuksOptionsValidate(t *testing.T) {
+	type in struct {
+		options LuksOptions
+	}
+	type out struct {
+		err error
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{options: LuksOptions{"--cipher=aes-xts-plain64", "--key-size", "512"}},
+			out: out{},
+		},
+		{
+			in:  in{options: LuksOptions{"--type=luks1"}},
+			out: out{err: ErrLuksOption},
+		},
+		{
+			in:  in{options: LuksOptions{"--key-file", "/tmp/key"}},
+			out: out{err: ErrLuksOption},
+		},
+	}
+
+	for i, test := range tests {
+		r := test.in.options.Validate()
+		if !reflect.DeepEqual(report.ReportFromError(test.out.err, report.EntryError), r) {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.out.err, r)
+		}
+	}
+}
+
+func TestClevisValidate(t *testing.T) {
+	type in struct {
+		clevis Clevis
+	}
+	type out struct {
+		err error
+	}
+
+	tang := []Tang{{Url: "http://tang1.example.com"}, {Url: "http://tang2.example.com", Thumbprint: "x3f5ZVgbLeJh4jA6rOzbJ5BqeuM"}}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{clevis: Clevis{Tpm2: true}},
+			out: out{},
+		},
+		{
+			in:  in{clevis: Clevis{Tpm2: true, Tang: tang, Threshold: 3}},
+			out: out{},
+		},
+		{
+			in:  in{clevis: Clevis{}},
+			out: out{err: ErrClevisNoPins},
+		},
+		{
+			in:  in{clevis: Clevis{Tang: tang, Threshold: 3}},
+			out: out{err: ErrClevisThreshold},
+		},
+	}
+
+	for i, test := range tests {
+		r := test.in.clevis.Validate()
+		if !reflect.DeepEqual(report.ReportFromError(test.out.err, report.EntryError), r) {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.out.err, r)
+		}
+	}
+}
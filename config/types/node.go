@@ -16,7 +16,9 @@ package types
 
 import (
 	"errors"
+	"fmt"
 	"os"
+	"strconv"
 
 	"github.com/coreos/ignition/config/validate/report"
 )
@@ -24,6 +26,8 @@ import (
 var (
 	ErrNoFilesystem    = errors.New("no filesystem specified")
 	ErrFileIllegalMode = errors.New("illegal file mode")
+	ErrFileModeOctal   = errors.New("file mode looks like it was written in octal")
+	ErrNodeIdAndName   = errors.New("owners may be given by id or by name, but not both")
 )
 
 // Node represents all common info for files (special types, e.g. directories, included).
@@ -33,14 +37,84 @@ type Node struct {
 	Mode       NodeMode  `json:"mode,omitempty"`
 	User       NodeUser  `json:"user,omitempty"`
 	Group      NodeGroup `json:"group,omitempty"`
+	// Overwrite replaces a node of a different type already at the path,
+	// such as a directory where a file is to be written. Otherwise that is
+	// an error.
+	Overwrite bool `json:"overwrite,omitempty"`
 }
 
+// NodeUser is the owner of a node, given by ID or by a name which is
+// resolved against the target system's account databases. The ID is a
+// pointer so that an explicit 0, root, can be told apart from no owner.
 type NodeUser struct {
-	Id int `json:"id,omitempty"`
+	Id   *int   `json:"id,omitempty"`
+	Name string `json:"name,omitempty"`
+}
+
+// Uid returns the ID of the user, which is root's if it is unset.
+func (u NodeUser) Uid() int {
+	if u.Id == nil {
+		return 0
+	}
+	return *u.Id
+}
+
+func (u NodeUser) Validate() report.Report {
+	if u.Id != nil && u.Name != "" {
+		return report.ReportFromError(ErrNodeIdAndName, report.EntryError)
+	}
+	return report.Report{}
 }
 
 type NodeGroup struct {
-	Id int `json:"id,omitempty"`
+	Id   *int   `json:"id,omitempty"`
+	Name string `json:"name,omitempty"`
+}
+
+// Gid returns the ID of the group, which is root's if it is unset.
+func (g NodeGroup) Gid() int {
+	if g.Id == nil {
+		return 0
+	}
+	return *g.Id
+}
+
+func (g NodeGroup) Validate() report.Report {
+	if g.Id != nil && g.Name != "" {
+		return report.ReportFromError(ErrNodeIdAndName, report.EntryError)
+	}
+	return report.Report{}
+}
+
+// NodeDefaults are the mode and owner given to the files, directories, and
+// links which leave theirs unset, in place of Ignition's own defaults.
+type NodeDefaults struct {
+	FileMode      *NodeMode `json:"fileMode,omitempty"`
+	DirectoryMode *NodeMode `json:"directoryMode,omitempty"`
+	User          NodeUser  `json:"user,omitempty"`
+	Group         NodeGroup `json:"group,omitempty"`
+}
+
+// Apply returns n, the node of a directory if dir is set, with the mode and
+// owner given by d in place of those n leaves unset. A nil d changes nothing.
+func (d *NodeDefaults) Apply(n Node, dir bool) Node {
+	if d == nil {
+		return n
+	}
+	mode := d.FileMode
+	if dir {
+		mode = d.DirectoryMode
+	}
+	if n.Mode == 0 && mode != nil {
+		n.Mode = *mode
+	}
+	if n.User == (NodeUser{}) {
+		n.User = d.User
+	}
+	if n.Group == (NodeGroup{}) {
+		n.Group = d.Group
+	}
+	return n
 }
 
 func (n Node) Validate() report.Report {
@@ -52,9 +126,52 @@ func (n Node) Validate() report.Report {
 
 type NodeMode os.FileMode
 
+// FileMode returns m, in which the setuid, setgid, and sticky bits are those
+// of a Unix mode, as an os.FileMode, which represents them differently.
+func (m NodeMode) FileMode() os.FileMode {
+	mode := os.FileMode(m).Perm()
+	if m&04000 != 0 {
+		mode |= os.ModeSetuid
+	}
+	if m&02000 != 0 {
+		mode |= os.ModeSetgid
+	}
+	if m&01000 != 0 {
+		mode |= os.ModeSticky
+	}
+	return mode
+}
+
 func (m NodeMode) Validate() report.Report {
 	if (m &^ 07777) != 0 {
-		return report.ReportFromError(ErrFileIllegalMode, report.EntryError)
+		r := report.ReportFromError(ErrFileIllegalMode, report.EntryError)
+		if octal, ok := m.asOctal(); ok {
+			r.Entries[0].Hint = m.octalHint(octal)
+		}
+		return r
+	}
+	// Decimal values 512 through 777 set the sticky bit with nearly no
+	// permissions, which is almost certainly an octal mode written verbatim.
+	if m >= 01000 && m <= 777 {
+		if octal, ok := m.asOctal(); ok {
+			r := report.ReportFromError(ErrFileModeOctal, report.EntryWarning)
+			r.Entries[0].Hint = m.octalHint(octal)
+			return r
+		}
 	}
 	return report.Report{}
 }
+
+// asOctal reinterprets the decimal digits of m as an octal number, reporting
+// whether that yields a valid mode.
+func (m NodeMode) asOctal() (NodeMode, bool) {
+	octal, err := strconv.ParseUint(strconv.FormatUint(uint64(m), 10), 8, 32)
+	if err != nil || octal&^07777 != 0 {
+		return 0, false
+	}
+	return NodeMode(octal), true
+}
+
+func (m NodeMode) octalHint(octal NodeMode) string {
+	return fmt.Sprintf("mode must be decimal, did you mean 0%o → %d?", octal, octal)
+}
@@ -15,6 +15,7 @@
 package types
 
 import (
+	"os"
 	"reflect"
 	"testing"
 
@@ -83,6 +84,26 @@ func TestNodeModeValidate(t *testing.T) {
 			in:  in{mode: NodeMode(010000)},
 			out: out{report: report.ReportFromError(ErrFileIllegalMode, report.EntryError)},
 		},
+		{
+			in: in{mode: NodeMode(644)},
+			out: out{report: report.Report{Entries: []report.Entry{{
+				Kind:    report.EntryWarning,
+				Message: ErrFileModeOctal.Error(),
+				Hint:    "mode must be decimal, did you mean 0644 → 420?",
+			}}}},
+		},
+		{
+			in: in{mode: NodeMode(4755)},
+			out: out{report: report.Report{Entries: []report.Entry{{
+				Kind:    report.EntryError,
+				Message: ErrFileIllegalMode.Error(),
+				Hint:    "mode must be decimal, did you mean 04755 → 2541?",
+			}}}},
+		},
+		{
+			in:  in{mode: NodeMode(420)},
+			out: out{},
+		},
 	}
 
 	for i, test := range tests {
@@ -92,3 +113,147 @@ func TestNodeModeValidate(t *testing.T) {
 		}
 	}
 }
+
+func TestNodeModeFileMode(t *testing.T) {
+	type in struct {
+		mode NodeMode
+	}
+	type out struct {
+		mode os.FileMode
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{mode: 0644},
+			out: out{mode: 0644},
+		},
+		{
+			in:  in{mode: 04755},
+			out: out{mode: os.ModeSetuid | 0755},
+		},
+		{
+			in:  in{mode: 02775},
+			out: out{mode: os.ModeSetgid | 0775},
+		},
+		{
+			in:  in{mode: 01777},
+			out: out{mode: os.ModeSticky | 0777},
+		},
+	}
+
+	for i, test := range tests {
+		mode := test.in.mode.FileMode()
+		if test.out.mode != mode {
+			t.Errorf("#%d: bad mode: want %v, got %v", i, test.out.mode, mode)
+		}
+	}
+}
+
+func intToPtr(i int) *int {
+	return &i
+}
+
+func TestNodeDefaultsApply(t *testing.T) {
+	type in struct {
+		defaults *NodeDefaults
+		node     Node
+		dir      bool
+	}
+	type out struct {
+		node Node
+	}
+
+	fileMode := NodeMode(0640)
+	dirMode := NodeMode(0750)
+	defaults := &NodeDefaults{
+		FileMode:      &fileMode,
+		DirectoryMode: &dirMode,
+		User:          NodeUser{Name: "core"},
+		Group:         NodeGroup{Id: intToPtr(500)},
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{node: Node{Path: "/a"}},
+			out: out{node: Node{Path: "/a"}},
+		},
+		{
+			in:  in{defaults: defaults, node: Node{Path: "/a"}},
+			out: out{node: Node{Path: "/a", Mode: 0640, User: NodeUser{Name: "core"}, Group: NodeGroup{Id: intToPtr(500)}}},
+		},
+		{
+			in:  in{defaults: defaults, node: Node{Path: "/a"}, dir: true},
+			out: out{node: Node{Path: "/a", Mode: 0750, User: NodeUser{Name: "core"}, Group: NodeGroup{Id: intToPtr(500)}}},
+		},
+		{
+			in:  in{defaults: defaults, node: Node{Path: "/a", Mode: 0600, User: NodeUser{Id: intToPtr(1000)}, Group: NodeGroup{Name: "wheel"}}},
+			out: out{node: Node{Path: "/a", Mode: 0600, User: NodeUser{Id: intToPtr(1000)}, Group: NodeGroup{Name: "wheel"}}},
+		},
+		{
+			in:  in{defaults: &NodeDefaults{User: NodeUser{Id: intToPtr(1000)}}, node: Node{Path: "/a"}},
+			out: out{node: Node{Path: "/a", User: NodeUser{Id: intToPtr(1000)}}},
+		},
+		{
+			in:  in{defaults: defaults, node: Node{Path: "/a", User: NodeUser{Id: intToPtr(0)}, Group: NodeGroup{Id: intToPtr(0)}}},
+			out: out{node: Node{Path: "/a", Mode: 0640, User: NodeUser{Id: intToPtr(0)}, Group: NodeGroup{Id: intToPtr(0)}}},
+		},
+	}
+
+	for i, test := range tests {
+		node := test.in.defaults.Apply(test.in.node, test.in.dir)
+		if !reflect.DeepEqual(test.out.node, node) {
+			t.Errorf("#%d: bad node: want %+v, got %+v", i, test.out.node, node)
+		}
+	}
+}
+
+func TestNodeOwnerValidate(t *testing.T) {
+	type in struct {
+		user  NodeUser
+		group NodeGroup
+	}
+	type out struct {
+		user  report.Report
+		group report.Report
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{},
+			out: out{},
+		},
+		{
+			in:  in{user: NodeUser{Id: intToPtr(500)}, group: NodeGroup{Id: intToPtr(500)}},
+			out: out{},
+		},
+		{
+			in:  in{user: NodeUser{Name: "core"}, group: NodeGroup{Name: "core"}},
+			out: out{},
+		},
+		{
+			in: in{user: NodeUser{Id: intToPtr(500), Name: "core"}, group: NodeGroup{Id: intToPtr(500), Name: "core"}},
+			out: out{
+				user:  report.ReportFromError(ErrNodeIdAndName, report.EntryError),
+				group: report.ReportFromError(ErrNodeIdAndName, report.EntryError),
+			},
+		},
+	}
+
+	for i, test := range tests {
+		if r := test.in.user.Validate(); !reflect.DeepEqual(test.out.user, r) {
+			t.Errorf("#%d: bad user report: want %v, got %v", i, test.out.user, r)
+		}
+		if r := test.in.group.Validate(); !reflect.DeepEqual(test.out.group, r) {
+			t.Errorf("#%d: bad group report: want %v, got %v", i, test.out.group, r)
+		}
+	}
+}
@@ -15,18 +15,145 @@
 package types
 
 import (
+	"errors"
 	"fmt"
 	"regexp"
+	"strings"
 
 	"github.com/coreos/ignition/config/validate/report"
 )
 
+const (
+	// PartitionTypePrepBoot is the type GUID of the PowerPC PReP boot
+	// partition, from which the firmware of POWER systems loads the
+	// bootloader.
+	PartitionTypePrepBoot PartitionTypeGUID = "9E1A2D38-C612-4316-AA26-8B49521E5A8B"
+
+	// PartitionTypeEfiSystem is the type GUID of the EFI System Partition,
+	// from which UEFI firmware loads the bootloader.
+	PartitionTypeEfiSystem PartitionTypeGUID = "C12A7328-F81F-11D2-BA4B-00A0C93EC93B"
+
+	// PartitionTypeBiosBoot is the type GUID of the BIOS boot partition, in
+	// which BIOS bootloaders embed their core image on GPT disks.
+	PartitionTypeBiosBoot PartitionTypeGUID = "21686148-6449-6E6F-744E-656564454649"
+
+	// MiB is the unit of the MiB-based partition dimensions, and the
+	// boundary partitions are aligned to.
+	MiB = 1024 * 1024
+
+	// legacySectorSize is the sector size the sector-based dimensions are
+	// checked against when the config is validated, without the disk.
+	legacySectorSize = 512
+
+	// prepBootMaxSize is the largest PReP boot partition (in sectors) which
+	// is loadable by firmware.
+	prepBootMaxSize = 16384
+
+	// efiSystemMinSize is the smallest EFI System Partition (in sectors)
+	// which can hold a FAT32 filesystem.
+	efiSystemMinSize = 65536
+)
+
+var guidRegexp = regexp.MustCompile("^(|[[:xdigit:]]{8}-[[:xdigit:]]{4}-[[:xdigit:]]{4}-[[:xdigit:]]{4}-[[:xdigit:]]{12})$")
+
+var (
+	ErrPrepBootNoSize    = errors.New("PReP boot partitions require an explicit size")
+	ErrPrepBootTooLarge  = errors.New("PReP boot partitions may not exceed 8 MiB")
+	ErrEfiSystemTooSmall = errors.New("EFI system partitions smaller than 32 MiB may not be usable by all firmware")
+	ErrResizeNoNumber    = errors.New("resized partitions require an explicit number")
+	ErrDeleteNoNumber    = errors.New("partitions which should not exist require an explicit number")
+	ErrWipeEntryNoNumber = errors.New("partitions whose entry is wiped require an explicit number")
+	ErrResizeWipeEntry   = errors.New("partitions cannot be both resized and have their entry wiped")
+	ErrSizeMiBConflict   = errors.New("partitions cannot give both size and sizeMiB")
+	ErrStartMiBConflict  = errors.New("partitions cannot give both start and startMiB")
+	ErrNegativeMiB       = errors.New("partition sizeMiB and startMiB cannot be negative")
+)
+
 type Partition struct {
-	Label    PartitionLabel     `json:"label,omitempty"`
-	Number   int                `json:"number"`
-	Size     PartitionDimension `json:"size"`
-	Start    PartitionDimension `json:"start"`
-	TypeGUID PartitionTypeGUID  `json:"typeGuid,omitempty"`
+	Label  PartitionLabel     `json:"label,omitempty"`
+	Number int                `json:"number"`
+	Size   PartitionDimension `json:"size"`
+	Start  PartitionDimension `json:"start"`
+	// SizeMiB and StartMiB give the size and start in MiB, so that the
+	// partition is laid out the same whatever the disk's sector size. They
+	// take the place of Size and Start, which are in the disk's logical
+	// sectors.
+	SizeMiB  int               `json:"sizeMiB,omitempty"`
+	StartMiB int               `json:"startMiB,omitempty"`
+	TypeGUID PartitionTypeGUID `json:"typeGuid,omitempty"`
+	// GUID is the unique partition GUID. sgdisk picks a random one if it
+	// is empty.
+	GUID PartitionGUID `json:"guid,omitempty"`
+	// Resize, if set, extends the existing partition with the same number
+	// to Size in place, rather than creating it, if the partition exists.
+	Resize bool `json:"resize,omitempty"`
+	// ShouldExist, if false, deletes the existing partition with the same
+	// number instead of creating one.
+	ShouldExist *bool `json:"shouldExist,omitempty"`
+	// WipePartitionEntry, if set, deletes the existing partition with the
+	// same number before it is created again.
+	WipePartitionEntry bool `json:"wipePartitionEntry,omitempty"`
+}
+
+// Exists returns whether or not the partition should be present, which is
+// the default.
+func (p Partition) Exists() bool {
+	return p.ShouldExist == nil || *p.ShouldExist
+}
+
+// SizeSectors returns the size of the partition in sectors of sectorSize
+// bytes, or 0 if it fills the available space.
+func (p Partition) SizeSectors(sectorSize uint64) uint64 {
+	if p.SizeMiB != 0 {
+		return uint64(p.SizeMiB) * MiB / sectorSize
+	}
+	return uint64(p.Size)
+}
+
+// StartSectors returns the start of the partition in sectors of sectorSize
+// bytes, or 0 if it starts at the beginning of the largest free space.
+func (p Partition) StartSectors(sectorSize uint64) uint64 {
+	if p.StartMiB != 0 {
+		return uint64(p.StartMiB) * MiB / sectorSize
+	}
+	return uint64(p.Start)
+}
+
+func (p Partition) Validate() report.Report {
+	if p.Size != 0 && p.SizeMiB != 0 {
+		return report.ReportFromError(ErrSizeMiBConflict, report.EntryError)
+	}
+	if p.Start != 0 && p.StartMiB != 0 {
+		return report.ReportFromError(ErrStartMiBConflict, report.EntryError)
+	}
+	if p.SizeMiB < 0 || p.StartMiB < 0 {
+		return report.ReportFromError(ErrNegativeMiB, report.EntryError)
+	}
+	if p.Resize && p.Number == 0 {
+		return report.ReportFromError(ErrResizeNoNumber, report.EntryError)
+	}
+	if !p.Exists() && p.Number == 0 {
+		return report.ReportFromError(ErrDeleteNoNumber, report.EntryError)
+	}
+	if p.WipePartitionEntry && p.Number == 0 {
+		return report.ReportFromError(ErrWipeEntryNoNumber, report.EntryError)
+	}
+	if p.Resize && p.WipePartitionEntry {
+		return report.ReportFromError(ErrResizeWipeEntry, report.EntryError)
+	}
+	size := p.SizeSectors(legacySectorSize)
+	if p.TypeGUID.Is(PartitionTypePrepBoot) {
+		if size == 0 {
+			return report.ReportFromError(ErrPrepBootNoSize, report.EntryError)
+		}
+		if size > prepBootMaxSize {
+			return report.ReportFromError(ErrPrepBootTooLarge, report.EntryError)
+		}
+	}
+	if p.TypeGUID.Is(PartitionTypeEfiSystem) && size != 0 && size < efiSystemMinSize {
+		return report.ReportFromError(ErrEfiSystemTooSmall, report.EntryWarning)
+	}
+	return report.Report{}
 }
 
 type PartitionLabel string
@@ -47,13 +174,30 @@ type PartitionDimension uint64
 
 type PartitionTypeGUID string
 
+// Is returns true if d and o refer to the same partition type. GUIDs are
+// compared case-insensitively.
+func (d PartitionTypeGUID) Is(o PartitionTypeGUID) bool {
+	return strings.EqualFold(string(d), string(o))
+}
+
 func (d PartitionTypeGUID) Validate() report.Report {
-	ok, err := regexp.MatchString("^(|[[:xdigit:]]{8}-[[:xdigit:]]{4}-[[:xdigit:]]{4}-[[:xdigit:]]{4}-[[:xdigit:]]{12})$", string(d))
-	if err != nil {
-		return report.ReportFromError(fmt.Errorf("error matching type-guid regexp: %v", err), report.EntryError)
-	}
-	if !ok {
+	if !guidRegexp.MatchString(string(d)) {
 		return report.ReportFromError(fmt.Errorf(`partition type-guid must have the form "01234567-89AB-CDEF-EDCB-A98765432101", got: %q`, string(d)), report.EntryError)
 	}
 	return report.Report{}
 }
+
+type PartitionGUID string
+
+// Is returns true if g and o are the same GUID. GUIDs are compared
+// case-insensitively.
+func (g PartitionGUID) Is(o PartitionGUID) bool {
+	return strings.EqualFold(string(g), string(o))
+}
+
+func (g PartitionGUID) Validate() report.Report {
+	if !guidRegexp.MatchString(string(g)) {
+		return report.ReportFromError(fmt.Errorf(`partition guid must have the form "01234567-89AB-CDEF-EDCB-A98765432101", got: %q`, string(g)), report.EntryError)
+	}
+	return report.Report{}
+}
@@ -0,0 +1,214 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/coreos/ignition/config/validate/report"
+)
+
+func TestPartitionValidate(t *testing.T) {
+	type in struct {
+		partition Partition
+	}
+	type out struct {
+		report report.Report
+	}
+
+	no := false
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{partition: Partition{}},
+			out: out{},
+		},
+		{
+			in:  in{partition: Partition{TypeGUID: PartitionTypePrepBoot, Size: 8192}},
+			out: out{},
+		},
+		{
+			in:  in{partition: Partition{TypeGUID: "9e1a2d38-c612-4316-aa26-8b49521e5a8b", Size: 16384}},
+			out: out{},
+		},
+		{
+			in:  in{partition: Partition{TypeGUID: PartitionTypePrepBoot}},
+			out: out{report: report.ReportFromError(ErrPrepBootNoSize, report.EntryError)},
+		},
+		{
+			in:  in{partition: Partition{TypeGUID: PartitionTypePrepBoot, Size: 16385}},
+			out: out{report: report.ReportFromError(ErrPrepBootTooLarge, report.EntryError)},
+		},
+		{
+			in:  in{partition: Partition{TypeGUID: PartitionTypeEfiSystem}},
+			out: out{},
+		},
+		{
+			in:  in{partition: Partition{TypeGUID: PartitionTypeEfiSystem, Size: 262144}},
+			out: out{},
+		},
+		{
+			in:  in{partition: Partition{TypeGUID: PartitionTypeEfiSystem, Size: 2048}},
+			out: out{report: report.ReportFromError(ErrEfiSystemTooSmall, report.EntryWarning)},
+		},
+		{
+			in:  in{partition: Partition{Number: 9, Resize: true}},
+			out: out{},
+		},
+		{
+			in:  in{partition: Partition{Resize: true}},
+			out: out{report: report.ReportFromError(ErrResizeNoNumber, report.EntryError)},
+		},
+		{
+			in:  in{partition: Partition{Number: 3, ShouldExist: &no}},
+			out: out{},
+		},
+		{
+			in:  in{partition: Partition{ShouldExist: &no}},
+			out: out{report: report.ReportFromError(ErrDeleteNoNumber, report.EntryError)},
+		},
+		{
+			in:  in{partition: Partition{Number: 3, WipePartitionEntry: true}},
+			out: out{},
+		},
+		{
+			in:  in{partition: Partition{WipePartitionEntry: true}},
+			out: out{report: report.ReportFromError(ErrWipeEntryNoNumber, report.EntryError)},
+		},
+		{
+			in:  in{partition: Partition{Number: 3, Resize: true, WipePartitionEntry: true}},
+			out: out{report: report.ReportFromError(ErrResizeWipeEntry, report.EntryError)},
+		},
+		{
+			in:  in{partition: Partition{TypeGUID: PartitionTypePrepBoot, SizeMiB: 8}},
+			out: out{},
+		},
+		{
+			in:  in{partition: Partition{TypeGUID: PartitionTypePrepBoot, SizeMiB: 9}},
+			out: out{report: report.ReportFromError(ErrPrepBootTooLarge, report.EntryError)},
+		},
+		{
+			in:  in{partition: Partition{TypeGUID: PartitionTypeEfiSystem, SizeMiB: 16}},
+			out: out{report: report.ReportFromError(ErrEfiSystemTooSmall, report.EntryWarning)},
+		},
+		{
+			in:  in{partition: Partition{Size: 2048, SizeMiB: 1}},
+			out: out{report: report.ReportFromError(ErrSizeMiBConflict, report.EntryError)},
+		},
+		{
+			in:  in{partition: Partition{Start: 2048, StartMiB: 1}},
+			out: out{report: report.ReportFromError(ErrStartMiBConflict, report.EntryError)},
+		},
+		{
+			in:  in{partition: Partition{SizeMiB: -1}},
+			out: out{report: report.ReportFromError(ErrNegativeMiB, report.EntryError)},
+		},
+	}
+
+	for i, test := range tests {
+		r := test.in.partition.Validate()
+		if !reflect.DeepEqual(test.out.report, r) {
+			t.Errorf("#%d: bad report: want %v, got %v", i, test.out.report, r)
+		}
+	}
+}
+
+func TestPartitionSectors(t *testing.T) {
+	type in struct {
+		partition  Partition
+		sectorSize uint64
+	}
+	type out struct {
+		start uint64
+		size  uint64
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{partition: Partition{Start: 2048, Size: 4096}, sectorSize: 512},
+			out: out{start: 2048, size: 4096},
+		},
+		{
+			in:  in{partition: Partition{Start: 2048, Size: 4096}, sectorSize: 4096},
+			out: out{start: 2048, size: 4096},
+		},
+		{
+			in:  in{partition: Partition{StartMiB: 1, SizeMiB: 2}, sectorSize: 512},
+			out: out{start: 2048, size: 4096},
+		},
+		{
+			in:  in{partition: Partition{StartMiB: 1, SizeMiB: 2}, sectorSize: 4096},
+			out: out{start: 256, size: 512},
+		},
+		{
+			in:  in{partition: Partition{}, sectorSize: 4096},
+			out: out{},
+		},
+	}
+
+	for i, test := range tests {
+		if start := test.in.partition.StartSectors(test.in.sectorSize); start != test.out.start {
+			t.Errorf("#%d: bad start: want %d, got %d", i, test.out.start, start)
+		}
+		if size := test.in.partition.SizeSectors(test.in.sectorSize); size != test.out.size {
+			t.Errorf("#%d: bad size: want %d, got %d", i, test.out.size, size)
+		}
+	}
+}
+
+func TestPartitionGUIDValidate(t *testing.T) {
+	type in struct {
+		guid PartitionGUID
+	}
+	type out struct {
+		err bool
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{guid: ""},
+			out: out{},
+		},
+		{
+			in:  in{guid: "5a1a7a3d-3c5f-4b8e-9d5c-8e2b4f6a1c0d"},
+			out: out{},
+		},
+		{
+			in:  in{guid: "5A1A7A3D-3C5F-4B8E-9D5C-8E2B4F6A1C0D"},
+			out: out{},
+		},
+		{
+			in:  in{guid: "5a1a7a3d3c5f4b8e9d5c8e2b4f6a1c0d"},
+			out: out{err: true},
+		},
+	}
+
+	for i, test := range tests {
+		r := test.in.guid.Validate()
+		if test.out.err != r.IsFatal() {
+			t.Errorf("#%d: bad fatality: want %t, got %t", i, test.out.err, r.IsFatal())
+		}
+	}
+}
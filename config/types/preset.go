@@ -0,0 +1,64 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/coreos/ignition/config/validate/report"
+)
+
+var (
+	ErrPresetExtension = errors.New("invalid systemd preset extension")
+)
+
+// SystemdPreset is a systemd preset file, expressing enable/disable policy
+// for units by name or glob.
+type SystemdPreset struct {
+	Name     SystemdPresetName `json:"name,omitempty"`
+	Contents string            `json:"contents,omitempty"`
+}
+
+func (p SystemdPreset) Validate() report.Report {
+	scanner := bufio.NewScanner(strings.NewReader(p.Contents))
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") || strings.HasPrefix(fields[0], ";") {
+			continue
+		}
+		switch fields[0] {
+		case "enable", "disable", "ignore":
+			if len(fields) >= 2 {
+				continue
+			}
+		}
+		return report.ReportFromError(fmt.Errorf("invalid preset directive on line %d: %q", line, scanner.Text()), report.EntryError)
+	}
+
+	return report.Report{}
+}
+
+type SystemdPresetName string
+
+func (n SystemdPresetName) Validate() report.Report {
+	if path.Ext(string(n)) != ".preset" || strings.Contains(string(n), "/") {
+		return report.ReportFromError(ErrPresetExtension, report.EntryError)
+	}
+	return report.Report{}
+}
@@ -0,0 +1,95 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/coreos/ignition/config/validate/report"
+)
+
+func TestSystemdPresetValidate(t *testing.T) {
+	type in struct {
+		preset SystemdPreset
+	}
+	type out struct {
+		err error
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{preset: SystemdPreset{Name: "80-fleet.preset", Contents: "# policy\nenable etcd-member.service\n\ndisable *\n"}},
+			out: out{},
+		},
+		{
+			in:  in{preset: SystemdPreset{Name: "80-fleet.preset", Contents: "ignore getty@.service tty1 tty2"}},
+			out: out{},
+		},
+		{
+			in:  in{preset: SystemdPreset{Name: "80-fleet.preset", Contents: "enable"}},
+			out: out{err: errors.New(`invalid preset directive on line 1: "enable"`)},
+		},
+		{
+			in:  in{preset: SystemdPreset{Name: "80-fleet.preset", Contents: "enable a.service\nstart b.service"}},
+			out: out{err: errors.New(`invalid preset directive on line 2: "start b.service"`)},
+		},
+	}
+
+	for i, test := range tests {
+		err := test.in.preset.Validate()
+		if !reflect.DeepEqual(report.ReportFromError(test.out.err, report.EntryError), err) {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.out.err, err)
+		}
+	}
+}
+
+func TestSystemdPresetNameValidate(t *testing.T) {
+	type in struct {
+		name SystemdPresetName
+	}
+	type out struct {
+		err error
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{name: "80-fleet.preset"},
+			out: out{},
+		},
+		{
+			in:  in{name: "80-fleet.conf"},
+			out: out{err: ErrPresetExtension},
+		},
+		{
+			in:  in{name: "../80-fleet.preset"},
+			out: out{err: ErrPresetExtension},
+		},
+	}
+
+	for i, test := range tests {
+		err := test.in.name.Validate()
+		if !reflect.DeepEqual(report.ReportFromError(test.out.err, report.EntryError), err) {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.out.err, err)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+
+	"github.com/coreos/ignition/config/validate/report"
+)
+
+var (
+	ErrInvalidProxy   = errors.New("proxies must be http or https URLs")
+	ErrInvalidNoProxy = errors.New("noProxy entries must be non-empty and contain no whitespace")
+)
+
+// Proxy configures the proxies used for Ignition's own fetches. It does not
+// affect the network configuration of the provisioned system.
+type Proxy struct {
+	HttpProxy  string        `json:"httpProxy,omitempty"`
+	HttpsProxy string        `json:"httpsProxy,omitempty"`
+	NoProxy    []NoProxyItem `json:"noProxy,omitempty"`
+}
+
+func (p Proxy) Validate() report.Report {
+	r := report.Report{}
+	for _, proxy := range []string{p.HttpProxy, p.HttpsProxy} {
+		if proxy == "" {
+			continue
+		}
+		u, err := url.Parse(proxy)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			r.Add(report.Entry{Message: ErrInvalidProxy.Error(), Kind: report.EntryError})
+		}
+	}
+	return r
+}
+
+// IsEmpty reports whether p declares no proxy settings.
+func (p Proxy) IsEmpty() bool {
+	return p.HttpProxy == "" && p.HttpsProxy == "" && len(p.NoProxy) == 0
+}
+
+// NoProxyItem is a host, domain, IP address, or CIDR range which is fetched
+// from directly. A leading "." matches subdomains only and "*" matches every
+// host.
+type NoProxyItem string
+
+func (n NoProxyItem) Validate() report.Report {
+	if n == "" || strings.ContainsAny(string(n), " \t\n") {
+		return report.ReportFromError(ErrInvalidNoProxy, report.EntryError)
+	}
+	return report.Report{}
+}
@@ -15,19 +15,78 @@
 package types
 
 import (
+	"errors"
 	"fmt"
+	"regexp"
+	"strings"
 
 	"github.com/coreos/ignition/config/validate/report"
 )
 
+var (
+	ErrRaidInvalidUuid = errors.New("invalid array uuid")
+	ErrRaidOption      = errors.New("raid options may not set the array's name, level, devices, spares, or uuid")
+
+	raidUuidRegexp = regexp.MustCompile("^[[:xdigit:]]{32}$")
+)
+
 type Raid struct {
-	Name    string `json:"name"`
-	Level   string `json:"level"`
-	Devices []Path `json:"devices,omitempty"`
-	Spares  int    `json:"spares,omitempty"`
+	Name      string  `json:"name"`
+	Level     string  `json:"level"`
+	Devices   []Path  `json:"devices,omitempty"`
+	Spares    int     `json:"spares,omitempty"`
+	Uuid      *string `json:"uuid,omitempty"`
+	WipeArray bool    `json:"wipeArray,omitempty"`
+	// Options are passed to mdadm when the array is created.
+	Options RaidOptions `json:"options,omitempty"`
+}
+
+// RaidOptions are extra arguments to "mdadm --create" (e.g. "--chunk=256").
+type RaidOptions []string
+
+// managedRaidOptions are the mdadm options which Ignition derives from the
+// rest of the array's config. The name, in particular, identifies an existing
+// array as the one configured.
+var managedRaidOptions = []string{
+	"--create", "--level", "-l", "--raid-devices", "-n", "--spare-devices", "-x", "--uuid", "-u", "--name", "-N",
+}
+
+func (o RaidOptions) Validate() report.Report {
+	for _, opt := range o {
+		name := strings.SplitN(opt, "=", 2)[0]
+		for _, m := range managedRaidOptions {
+			if name == m || (len(m) == 2 && strings.HasPrefix(opt, m)) {
+				return report.ReportFromError(ErrRaidOption, report.EntryError)
+			}
+		}
+	}
+	return report.Report{}
+}
+
+// CanonicalLevel returns the level of the array using the names reported by
+// mdadm (e.g. "raid1" for "mirror").
+func (n Raid) CanonicalLevel() string {
+	switch n.Level {
+	case "0", "stripe":
+		return "raid0"
+	case "1", "mirror":
+		return "raid1"
+	case "4", "5", "6", "10":
+		return "raid" + n.Level
+	}
+	return n.Level
+}
+
+// CanonicalUuid returns the array UUID as 32 lowercase hex digits, stripped of
+// the separators used by mdadm (":") and by other tools ("-").
+func CanonicalUuid(uuid string) string {
+	return strings.ToLower(strings.NewReplacer(":", "", "-", "").Replace(uuid))
 }
 
 func (n Raid) Validate() report.Report {
+	if n.Uuid != nil && !raidUuidRegexp.MatchString(CanonicalUuid(*n.Uuid)) {
+		return report.ReportFromError(ErrRaidInvalidUuid, report.EntryError)
+	}
 	switch n.Level {
 	case "linear", "raid0", "0", "stripe":
 		if n.Spares != 0 {
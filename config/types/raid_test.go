@@ -0,0 +1,138 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/coreos/ignition/config/validate/report"
+)
+
+func TestRaidValidate(t *testing.T) {
+	type in struct {
+		raid Raid
+	}
+	type out struct {
+		err error
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{raid: Raid{Name: "data", Level: "raid1"}},
+			out: out{},
+		},
+		{
+			in:  in{raid: Raid{Name: "data", Level: "raid0", Spares: 1}},
+			out: out{err: fmt.Errorf("spares unsupported for %q arrays", "raid0")},
+		},
+		{
+			in:  in{raid: Raid{Name: "data", Level: "raid1", Uuid: func(s string) *string { return &s }("3bd0c4dc:5b2b4b2e:7b3b0c57:6a0d1e2f")}},
+			out: out{},
+		},
+		{
+			in:  in{raid: Raid{Name: "data", Level: "raid1", Uuid: func(s string) *string { return &s }("3BD0C4DC-5B2B-4B2E-7B3B-0C576A0D1E2F")}},
+			out: out{},
+		},
+		{
+			in:  in{raid: Raid{Name: "data", Level: "raid1", Uuid: func(s string) *string { return &s }("3bd0c4dc")}},
+			out: out{err: ErrRaidInvalidUuid},
+		},
+	}
+
+	for i, test := range tests {
+		err := test.in.raid.Validate()
+		if !reflect.DeepEqual(report.ReportFromError(test.out.err, report.EntryError), err) {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.out.err, err)
+		}
+	}
+}
+
+func TestRaidOptionsValidate(t *testing.T) {
+	type in struct {
+		options RaidOptions
+	}
+	type out struct {
+		err error
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{options: RaidOptions{"--chunk=256", "--bitmap", "internal", "--metadata=1.2"}},
+			out: out{},
+		},
+		{
+			in:  in{options: RaidOptions{"--level=raid5"}},
+			out: out{err: ErrRaidOption},
+		},
+		{
+			in:  in{options: RaidOptions{"--raid-devices", "3"}},
+			out: out{err: ErrRaidOption},
+		},
+		{
+			in:  in{options: RaidOptions{"-n3"}},
+			out: out{err: ErrRaidOption},
+		},
+		{
+			in:  in{options: RaidOptions{"--name=other"}},
+			out: out{err: ErrRaidOption},
+		},
+		{
+			in:  in{options: RaidOptions{"-Nother"}},
+			out: out{err: ErrRaidOption},
+		},
+	}
+
+	for i, test := range tests {
+		err := test.in.options.Validate()
+		if !reflect.DeepEqual(report.ReportFromError(test.out.err, report.EntryError), err) {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.out.err, err)
+		}
+	}
+}
+
+func TestRaidCanonicalLevel(t *testing.T) {
+	type in struct {
+		level string
+	}
+	type out struct {
+		level string
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{in: in{level: "linear"}, out: out{level: "linear"}},
+		{in: in{level: "stripe"}, out: out{level: "raid0"}},
+		{in: in{level: "mirror"}, out: out{level: "raid1"}},
+		{in: in{level: "5"}, out: out{level: "raid5"}},
+		{in: in{level: "raid10"}, out: out{level: "raid10"}},
+	}
+
+	for i, test := range tests {
+		level := Raid{Level: test.in.level}.CanonicalLevel()
+		if level != test.out.level {
+			t.Errorf("#%d: bad level: want %q, got %q", i, test.out.level, level)
+		}
+	}
+}
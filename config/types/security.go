@@ -0,0 +1,27 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+// Security holds the options securing Ignition's own fetches.
+type Security struct {
+	Tls Tls `json:"tls,omitempty"`
+}
+
+type Tls struct {
+	// CertificateAuthorities are trusted, in addition to the system's CAs,
+	// when fetching over https. They are also added to the trust store of
+	// the target system.
+	CertificateAuthorities []CaReference `json:"certificateAuthorities,omitempty"`
+}
@@ -0,0 +1,44 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"errors"
+
+	"github.com/coreos/ignition/config/validate/report"
+)
+
+var (
+	ErrSignatureNoSource        = errors.New("signatures require a source or inline contents")
+	ErrSignatureSourceAndInline = errors.New("signatures cannot have both a source and inline contents")
+)
+
+// ConfigSignature is a detached OpenPGP signature of a referenced config,
+// fetched from Source or given armored in Inline. It must be made by one of
+// the keys Ignition trusts.
+type ConfigSignature struct {
+	Source Url    `json:"source,omitempty"`
+	Inline string `json:"inline,omitempty"`
+}
+
+func (s ConfigSignature) Validate() report.Report {
+	switch {
+	case s.Source.String() == "" && s.Inline == "":
+		return report.ReportFromError(ErrSignatureNoSource, report.EntryError)
+	case s.Source.String() != "" && s.Inline != "":
+		return report.ReportFromError(ErrSignatureSourceAndInline, report.EntryError)
+	}
+	return report.Report{}
+}
@@ -0,0 +1,66 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/coreos/ignition/config/validate/report"
+)
+
+func TestConfigSignatureValidate(t *testing.T) {
+	type in struct {
+		source string
+		inline string
+	}
+	type out struct {
+		err error
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{source: "https://example.com/config.ign.sig"},
+			out: out{},
+		},
+		{
+			in:  in{inline: "-----BEGIN PGP SIGNATURE-----"},
+			out: out{},
+		},
+		{
+			in:  in{},
+			out: out{err: ErrSignatureNoSource},
+		},
+		{
+			in:  in{source: "https://example.com/config.ign.sig", inline: "-----BEGIN PGP SIGNATURE-----"},
+			out: out{err: ErrSignatureSourceAndInline},
+		},
+	}
+
+	for i, test := range tests {
+		u, err := url.Parse(test.in.source)
+		if err != nil {
+			t.Fatalf("#%d: bad url: %v", i, err)
+		}
+		r := ConfigSignature{Source: Url(*u), Inline: test.in.inline}.Validate()
+		if !reflect.DeepEqual(report.ReportFromError(test.out.err, report.EntryError), r) {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.out.err, r)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"errors"
+	"net/url"
+
+	"github.com/coreos/ignition/config/validate/report"
+)
+
+var (
+	ErrStatusReportNoUrl  = errors.New("status reports require a url")
+	ErrStatusReportScheme = errors.New("status reports can only be sent to http or https urls")
+)
+
+// StatusReport is an endpoint to which the outcome of each stage is POSTed,
+// so that provisioning can be tracked without the logs. Reports are signed
+// with HMAC-SHA256 if SigningKey is set.
+type StatusReport struct {
+	Url         Url         `json:"url,omitempty"`
+	SigningKey  string      `json:"signingKey,omitempty"`
+	HttpHeaders HttpHeaders `json:"httpHeaders,omitempty"`
+}
+
+func (s StatusReport) Validate() report.Report {
+	switch url.URL(s.Url).Scheme {
+	case "http", "https":
+	case "":
+		return report.ReportFromError(ErrStatusReportNoUrl, report.EntryError)
+	default:
+		return report.ReportFromError(ErrStatusReportScheme, report.EntryError)
+	}
+	return report.Report{}
+}
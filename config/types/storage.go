@@ -15,9 +15,19 @@
 package types
 
 type Storage struct {
+	Zfcp        []Zfcp       `json:"zfcp,omitempty"`
 	Disks       []Disk       `json:"disks,omitempty"`
+	GrowRoot    *GrowRoot    `json:"growRoot,omitempty"`
+	BootDevice  *BootDevice  `json:"bootDevice,omitempty"`
 	Arrays      []Raid       `json:"raid,omitempty"`
+	Luks        []Luks       `json:"luks,omitempty"`
+	Images      []Image      `json:"images,omitempty"`
 	Filesystems []Filesystem `json:"filesystems,omitempty"`
 	Files       []File       `json:"files,omitempty"`
 	Directories []Directory  `json:"directories,omitempty"`
+	Links       []Link       `json:"links,omitempty"`
+	SwapFiles   []SwapFile   `json:"swapFiles,omitempty"`
+	// Defaults are the mode and owner of the files, directories, and links
+	// which leave theirs unset.
+	Defaults *NodeDefaults `json:"defaults,omitempty"`
 }
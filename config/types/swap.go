@@ -0,0 +1,61 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"errors"
+
+	"github.com/coreos/ignition/config/validate/report"
+)
+
+var (
+	ErrSwapFileTooSmall    = errors.New("swap files must be at least 40KiB")
+	ErrSwapFileLabelLength = errors.New("swap file labels must be at most 15 characters")
+	ErrSwapFileInvalidUuid = errors.New("invalid swap file uuid")
+)
+
+// minSwapFileSize is the smallest swap area mkswap will create.
+const minSwapFileSize = 40 * 1024
+
+// SwapFile is a swap area in a file on one of the filesystems, activated on
+// the target system along with the swap filesystems.
+type SwapFile struct {
+	Filesystem string `json:"filesystem,omitempty"`
+	Path       Path   `json:"path,omitempty"`
+	// Size is the size of the file in bytes.
+	Size  int     `json:"size,omitempty"`
+	Label *string `json:"label,omitempty"`
+	Uuid  *string `json:"uuid,omitempty"`
+	// Options are the swapon options of the area, such as pri=10.
+	Options MountOptions `json:"options,omitempty"`
+	// Overwrite replaces a file or other node already at the path.
+	Overwrite bool `json:"overwrite,omitempty"`
+}
+
+func (s SwapFile) Validate() report.Report {
+	if s.Filesystem == "" {
+		return report.ReportFromError(ErrNoFilesystem, report.EntryError)
+	}
+	if s.Size < minSwapFileSize {
+		return report.ReportFromError(ErrSwapFileTooSmall, report.EntryError)
+	}
+	if s.Label != nil && len(*s.Label) > filesystemLabelLengths["swap"] {
+		return report.ReportFromError(ErrSwapFileLabelLength, report.EntryError)
+	}
+	if s.Uuid != nil && !uuidRegexp.MatchString(*s.Uuid) {
+		return report.ReportFromError(ErrSwapFileInvalidUuid, report.EntryError)
+	}
+	return report.Report{}
+}
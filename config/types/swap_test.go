@@ -0,0 +1,78 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/coreos/ignition/config/validate/report"
+)
+
+func TestSwapFileValidate(t *testing.T) {
+	type in struct {
+		swap SwapFile
+	}
+	type out struct {
+		err error
+	}
+
+	str := func(s string) *string { return &s }
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{swap: SwapFile{Filesystem: "root", Path: "/var/swapfile", Size: 1 << 30}},
+			out: out{},
+		},
+		{
+			in:  in{swap: SwapFile{Path: "/var/swapfile", Size: 1 << 30}},
+			out: out{err: ErrNoFilesystem},
+		},
+		{
+			in:  in{swap: SwapFile{Filesystem: "root", Path: "/var/swapfile"}},
+			out: out{err: ErrSwapFileTooSmall},
+		},
+		{
+			in:  in{swap: SwapFile{Filesystem: "root", Path: "/var/swapfile", Size: 4096}},
+			out: out{err: ErrSwapFileTooSmall},
+		},
+		{
+			in:  in{swap: SwapFile{Filesystem: "root", Path: "/var/swapfile", Size: 1 << 30, Label: str("swap")}},
+			out: out{},
+		},
+		{
+			in:  in{swap: SwapFile{Filesystem: "root", Path: "/var/swapfile", Size: 1 << 30, Label: str("a-very-long-label")}},
+			out: out{err: ErrSwapFileLabelLength},
+		},
+		{
+			in:  in{swap: SwapFile{Filesystem: "root", Path: "/var/swapfile", Size: 1 << 30, Uuid: str("8ea3d7ca-5ba2-4c3e-9c4b-1a6bc9d1e0f2")}},
+			out: out{},
+		},
+		{
+			in:  in{swap: SwapFile{Filesystem: "root", Path: "/var/swapfile", Size: 1 << 30, Uuid: str("swap")}},
+			out: out{err: ErrSwapFileInvalidUuid},
+		},
+	}
+
+	for i, test := range tests {
+		r := test.in.swap.Validate()
+		if !reflect.DeepEqual(report.ReportFromError(test.out.err, report.EntryError), r) {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.out.err, r)
+		}
+	}
+}
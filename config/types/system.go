@@ -0,0 +1,161 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"errors"
+	"net"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/coreos/ignition/config/validate/report"
+)
+
+var (
+	ErrMachineIdMode    = errors.New("machine-id mode must be one of preserve, set, or regenerate")
+	ErrMachineIdInvalid = errors.New("machine-id must be 32 lowercase hex digits")
+	ErrMachineIdUnused  = errors.New("machine-id may only be given in the set mode")
+	ErrHostsInvalidIp   = errors.New("hosts entries require a valid IP address")
+	ErrHostsNoHostnames = errors.New("hosts entries require at least one hostname")
+	ErrHostsHostname    = errors.New("invalid hostname in hosts entry")
+	ErrSysctlKey        = errors.New("invalid sysctl key")
+	ErrSysctlValue      = errors.New("sysctl values must be non-empty and may not contain newlines")
+	ErrModuleName       = errors.New("invalid kernel module name")
+	ErrModuleOptions    = errors.New("kernel module options may not contain newlines")
+	ErrModuleLoadAndBan = errors.New("kernel modules cannot be both loaded and blacklisted")
+	ErrHookKinds        = errors.New("post-provisioning hooks require exactly one of a command or a unit")
+	ErrHookCommandPath  = errors.New("post-provisioning commands must be given by absolute path")
+
+	machineIdRegexp = regexp.MustCompile("^[0-9a-f]{32}$")
+	moduleRegexp    = regexp.MustCompile(`^[[:alnum:]_-]+$`)
+	sysctlKeyRegexp = regexp.MustCompile(`^[[:alnum:]_]+([./][[:alnum:]_:@-]+)+$`)
+	hostnameRegexp  = regexp.MustCompile(`^[[:alnum:]]([[:alnum:]-]{0,61}[[:alnum:]])?(\.[[:alnum:]]([[:alnum:]-]{0,61}[[:alnum:]])?)*$`)
+)
+
+// System describes settings of the operating system on the target root.
+type System struct {
+	MachineId     *MachineId     `json:"machineId,omitempty"`
+	Hosts         []HostEntry    `json:"hosts,omitempty"`
+	TrustAnchors  []CaReference  `json:"trustAnchors,omitempty"`
+	Sysctl        []Sysctl       `json:"sysctl,omitempty"`
+	KernelModules []KernelModule `json:"kernelModules,omitempty"`
+	PostProvision *PostProvision `json:"postProvision,omitempty"`
+}
+
+// PostProvision is a command, or a unit to start, run exactly once on the
+// first boot of the provisioned system.
+type PostProvision struct {
+	Command []string         `json:"command,omitempty"`
+	Unit    *SystemdUnitName `json:"unit,omitempty"`
+}
+
+func (p PostProvision) Validate() report.Report {
+	if (len(p.Command) == 0) == (p.Unit == nil) {
+		return report.ReportFromError(ErrHookKinds, report.EntryError)
+	}
+	if len(p.Command) != 0 && !path.IsAbs(p.Command[0]) {
+		return report.ReportFromError(ErrHookCommandPath, report.EntryError)
+	}
+	return report.Report{}
+}
+
+// KernelModule describes how a kernel module is loaded at boot.
+type KernelModule struct {
+	Name      string `json:"name,omitempty"`
+	Load      bool   `json:"load,omitempty"`
+	Blacklist bool   `json:"blacklist,omitempty"`
+	Options   string `json:"options,omitempty"`
+}
+
+func (m KernelModule) Validate() report.Report {
+	if !moduleRegexp.MatchString(m.Name) {
+		return report.ReportFromError(ErrModuleName, report.EntryError)
+	}
+	if strings.ContainsAny(m.Options, "\n\r") {
+		return report.ReportFromError(ErrModuleOptions, report.EntryError)
+	}
+	if m.Load && m.Blacklist {
+		return report.ReportFromError(ErrModuleLoadAndBan, report.EntryError)
+	}
+	return report.Report{}
+}
+
+// Sysctl is a kernel parameter to be set at boot, e.g. net.ipv4.ip_forward.
+type Sysctl struct {
+	Key   string `json:"key,omitempty"`
+	Value string `json:"value,omitempty"`
+}
+
+func (s Sysctl) Validate() report.Report {
+	if !sysctlKeyRegexp.MatchString(s.Key) {
+		return report.ReportFromError(ErrSysctlKey, report.EntryError)
+	}
+	if strings.TrimSpace(s.Value) == "" || strings.ContainsAny(s.Value, "\n\r") {
+		return report.ReportFromError(ErrSysctlValue, report.EntryError)
+	}
+	return report.Report{}
+}
+
+// HostEntry is a line of /etc/hosts, mapping an address to its hostnames.
+type HostEntry struct {
+	Address   string   `json:"address,omitempty"`
+	Hostnames []string `json:"hostnames,omitempty"`
+}
+
+func (h HostEntry) Validate() report.Report {
+	if net.ParseIP(h.Address) == nil {
+		return report.ReportFromError(ErrHostsInvalidIp, report.EntryError)
+	}
+	if len(h.Hostnames) == 0 {
+		return report.ReportFromError(ErrHostsNoHostnames, report.EntryError)
+	}
+	for _, name := range h.Hostnames {
+		if len(name) > 253 || !hostnameRegexp.MatchString(name) {
+			return report.ReportFromError(ErrHostsHostname, report.EntryError)
+		}
+	}
+	return report.Report{}
+}
+
+type MachineIdMode string
+
+const (
+	MachineIdPreserve   MachineIdMode = "preserve"
+	MachineIdSet        MachineIdMode = "set"
+	MachineIdRegenerate MachineIdMode = "regenerate"
+)
+
+// MachineId controls the contents of /etc/machine-id.
+type MachineId struct {
+	Mode MachineIdMode `json:"mode,omitempty"`
+	Id   string        `json:"id,omitempty"`
+}
+
+func (m MachineId) Validate() report.Report {
+	switch m.Mode {
+	case MachineIdSet:
+		if !machineIdRegexp.MatchString(m.Id) {
+			return report.ReportFromError(ErrMachineIdInvalid, report.EntryError)
+		}
+	case MachineIdPreserve, MachineIdRegenerate:
+		if m.Id != "" {
+			return report.ReportFromError(ErrMachineIdUnused, report.EntryError)
+		}
+	default:
+		return report.ReportFromError(ErrMachineIdMode, report.EntryError)
+	}
+	return report.Report{}
+}
@@ -0,0 +1,252 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/coreos/ignition/config/validate/report"
+)
+
+func TestMachineIdValidate(t *testing.T) {
+	type in struct {
+		machineId MachineId
+	}
+	type out struct {
+		err error
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{machineId: MachineId{Mode: MachineIdPreserve}},
+			out: out{},
+		},
+		{
+			in:  in{machineId: MachineId{Mode: MachineIdRegenerate}},
+			out: out{},
+		},
+		{
+			in:  in{machineId: MachineId{Mode: MachineIdSet, Id: "b08dfa6083e7567a1921a715000001fb"}},
+			out: out{},
+		},
+		{
+			in:  in{machineId: MachineId{Mode: MachineIdSet, Id: "B08DFA6083E7567A1921A715000001FB"}},
+			out: out{err: ErrMachineIdInvalid},
+		},
+		{
+			in:  in{machineId: MachineId{Mode: MachineIdSet}},
+			out: out{err: ErrMachineIdInvalid},
+		},
+		{
+			in:  in{machineId: MachineId{Mode: MachineIdRegenerate, Id: "b08dfa6083e7567a1921a715000001fb"}},
+			out: out{err: ErrMachineIdUnused},
+		},
+		{
+			in:  in{machineId: MachineId{}},
+			out: out{err: ErrMachineIdMode},
+		},
+	}
+
+	for i, test := range tests {
+		err := test.in.machineId.Validate()
+		if !reflect.DeepEqual(report.ReportFromError(test.out.err, report.EntryError), err) {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.out.err, err)
+		}
+	}
+}
+
+func TestHostEntryValidate(t *testing.T) {
+	type in struct {
+		entry HostEntry
+	}
+	type out struct {
+		err error
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{entry: HostEntry{Address: "10.0.0.1", Hostnames: []string{"node1.cluster.local", "node1"}}},
+			out: out{},
+		},
+		{
+			in:  in{entry: HostEntry{Address: "fd00::1", Hostnames: []string{"node1"}}},
+			out: out{},
+		},
+		{
+			in:  in{entry: HostEntry{Address: "node1", Hostnames: []string{"node1"}}},
+			out: out{err: ErrHostsInvalidIp},
+		},
+		{
+			in:  in{entry: HostEntry{Address: "10.0.0.1"}},
+			out: out{err: ErrHostsNoHostnames},
+		},
+		{
+			in:  in{entry: HostEntry{Address: "10.0.0.1", Hostnames: []string{"node 1"}}},
+			out: out{err: ErrHostsHostname},
+		},
+		{
+			in:  in{entry: HostEntry{Address: "10.0.0.1", Hostnames: []string{"-node1"}}},
+			out: out{err: ErrHostsHostname},
+		},
+	}
+
+	for i, test := range tests {
+		err := test.in.entry.Validate()
+		if !reflect.DeepEqual(report.ReportFromError(test.out.err, report.EntryError), err) {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.out.err, err)
+		}
+	}
+}
+
+func TestSysctlValidate(t *testing.T) {
+	type in struct {
+		sysctl Sysctl
+	}
+	type out struct {
+		err error
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{sysctl: Sysctl{Key: "net.ipv4.ip_forward", Value: "1"}},
+			out: out{},
+		},
+		{
+			in:  in{sysctl: Sysctl{Key: "net/ipv4/conf/eth0.100/rp_filter", Value: "2"}},
+			out: out{},
+		},
+		{
+			in:  in{sysctl: Sysctl{Key: "net.ipv4.ip_local_port_range", Value: "32768 60999"}},
+			out: out{},
+		},
+		{
+			in:  in{sysctl: Sysctl{Key: "swappiness", Value: "10"}},
+			out: out{err: ErrSysctlKey},
+		},
+		{
+			in:  in{sysctl: Sysctl{Key: "vm.swappiness = 10", Value: "10"}},
+			out: out{err: ErrSysctlKey},
+		},
+		{
+			in:  in{sysctl: Sysctl{Key: "vm.swappiness"}},
+			out: out{err: ErrSysctlValue},
+		},
+		{
+			in:  in{sysctl: Sysctl{Key: "vm.swappiness", Value: "10\nkernel.panic = 1"}},
+			out: out{err: ErrSysctlValue},
+		},
+	}
+
+	for i, test := range tests {
+		err := test.in.sysctl.Validate()
+		if !reflect.DeepEqual(report.ReportFromError(test.out.err, report.EntryError), err) {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.out.err, err)
+		}
+	}
+}
+
+func TestKernelModuleValidate(t *testing.T) {
+	type in struct {
+		module KernelModule
+	}
+	type out struct {
+		err error
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{module: KernelModule{Name: "vfio-pci", Load: true, Options: "ids=10de:1b80"}},
+			out: out{},
+		},
+		{
+			in:  in{module: KernelModule{Name: "nouveau", Blacklist: true}},
+			out: out{},
+		},
+		{
+			in:  in{module: KernelModule{Name: "nouveau modeset=0"}},
+			out: out{err: ErrModuleName},
+		},
+		{
+			in:  in{module: KernelModule{Name: "ixgbe", Options: "max_vfs=8\nblacklist e1000"}},
+			out: out{err: ErrModuleOptions},
+		},
+		{
+			in:  in{module: KernelModule{Name: "nouveau", Load: true, Blacklist: true}},
+			out: out{err: ErrModuleLoadAndBan},
+		},
+	}
+
+	for i, test := range tests {
+		err := test.in.module.Validate()
+		if !reflect.DeepEqual(report.ReportFromError(test.out.err, report.EntryError), err) {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.out.err, err)
+		}
+	}
+}
+
+func TestPostProvisionValidate(t *testing.T) {
+	type in struct {
+		hook PostProvision
+	}
+	type out struct {
+		err error
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{hook: PostProvision{Command: []string{"/opt/bin/join-cluster"}}},
+			out: out{},
+		},
+		{
+			in:  in{hook: PostProvision{Unit: func(n SystemdUnitName) *SystemdUnitName { return &n }("join.service")}},
+			out: out{},
+		},
+		{
+			in:  in{hook: PostProvision{}},
+			out: out{err: ErrHookKinds},
+		},
+		{
+			in:  in{hook: PostProvision{Command: []string{"join-cluster"}}},
+			out: out{err: ErrHookCommandPath},
+		},
+		{
+			in:  in{hook: PostProvision{Command: []string{"/opt/bin/join-cluster"}, Unit: func(n SystemdUnitName) *SystemdUnitName { return &n }("join.service")}},
+			out: out{err: ErrHookKinds},
+		},
+	}
+
+	for i, test := range tests {
+		err := test.in.hook.Validate()
+		if !reflect.DeepEqual(report.ReportFromError(test.out.err, report.EntryError), err) {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.out.err, err)
+		}
+	}
+}
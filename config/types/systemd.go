@@ -14,6 +14,27 @@
 
 package types
 
+import (
+	"errors"
+	"path"
+
+	"github.com/coreos/ignition/config/validate/report"
+)
+
+var (
+	ErrDefaultTargetNotTarget = errors.New("default target must be a .target unit")
+)
+
 type Systemd struct {
-	Units []SystemdUnit `json:"units,omitempty"`
+	Units         []SystemdUnit    `json:"units,omitempty"`
+	Presets       []SystemdPreset  `json:"presets,omitempty"`
+	ApplyPresets  bool             `json:"applyPresets,omitempty"`
+	DefaultTarget *SystemdUnitName `json:"defaultTarget,omitempty"`
+}
+
+func (s Systemd) Validate() report.Report {
+	if s.DefaultTarget != nil && path.Ext(string(*s.DefaultTarget)) != ".target" {
+		return report.ReportFromError(ErrDefaultTargetNotTarget, report.EntryError)
+	}
+	return report.Report{}
 }
@@ -14,7 +14,29 @@
 
 package types
 
+import (
+	"errors"
+
+	"github.com/coreos/ignition/config/validate/report"
+)
+
+var (
+	ErrNegativeTimeout = errors.New("timeouts and retries cannot be negative")
+)
+
+// Timeouts bounds Ignition's http fetches. Unset fields keep their defaults.
 type Timeouts struct {
 	HttpResponseHeaders *int `json:"httpResponseHeaders,omitempty"`
 	HttpTotal           *int `json:"httpTotal,omitempty"`
+	HttpRetries         *int `json:"httpRetries,omitempty"`
+	HttpMaxBackoff      *int `json:"httpMaxBackoff,omitempty"`
+}
+
+func (t Timeouts) Validate() report.Report {
+	for _, v := range []*int{t.HttpResponseHeaders, t.HttpTotal, t.HttpRetries, t.HttpMaxBackoff} {
+		if v != nil && *v < 0 {
+			return report.ReportFromError(ErrNegativeTimeout, report.EntryError)
+		}
+	}
+	return report.Report{}
 }
@@ -19,15 +19,27 @@ import (
 	"errors"
 	"fmt"
 	"path"
+	"strings"
 
 	"github.com/coreos/go-systemd/unit"
 
 	"github.com/coreos/ignition/config/validate/report"
 )
 
+var (
+	ErrUnitEnableConflict = errors.New("units cannot be both enabled and disabled")
+	ErrUnitMaskEnabled    = errors.New("masked units cannot be started; enabling them has no effect")
+	ErrDuplicateDropIn    = errors.New("drop-in names must be unique within a unit")
+	ErrUnitNameSlash      = errors.New("unit names cannot contain slashes")
+	ErrTemplateNoDefault  = errors.New("template units without a DefaultInstance are only enabled through their instances")
+)
+
+// SystemdUnit represents a systemd unit. Enable is the older spelling of
+// Enabled set to true; Enabled set to false disables the unit.
 type SystemdUnit struct {
 	Name     SystemdUnitName     `json:"name,omitempty"`
 	Enable   bool                `json:"enable,omitempty"`
+	Enabled  *bool               `json:"enabled,omitempty"`
 	Mask     bool                `json:"mask,omitempty"`
 	Contents string              `json:"contents,omitempty"`
 	DropIns  []SystemdUnitDropIn `json:"dropins,omitempty"`
@@ -37,10 +49,49 @@ func (u SystemdUnit) Validate() report.Report {
 	if err := validateUnitContent(u.Contents); err != nil {
 		return report.ReportFromError(err, report.EntryError)
 	}
+	if err := validateDropIns(u.DropIns); err != nil {
+		return report.ReportFromError(err, report.EntryError)
+	}
+	if u.Enable && u.Enabled != nil && !*u.Enabled {
+		return report.ReportFromError(ErrUnitEnableConflict, report.EntryError)
+	}
+	if enabled := u.Enablement(); u.Mask && enabled != nil && *enabled {
+		return report.ReportFromError(ErrUnitMaskEnabled, report.EntryWarning)
+	} else if enabled != nil && *enabled && u.Name.IsTemplate() && u.Contents != "" && u.DefaultInstance() == "" {
+		return report.ReportFromError(ErrTemplateNoDefault, report.EntryWarning)
+	}
 
 	return report.Report{}
 }
 
+// Enablement reports whether the unit is to be enabled or disabled, or nil
+// if neither was requested.
+func (u SystemdUnit) Enablement() *bool {
+	if u.Enable {
+		enabled := true
+		return &enabled
+	}
+	return u.Enabled
+}
+
+// DefaultInstance returns the DefaultInstance from the [Install] section of the
+// unit's contents, if any.
+func (u SystemdUnit) DefaultInstance() string {
+	opts, err := unit.Deserialize(bytes.NewBufferString(u.Contents))
+	if err != nil {
+		return ""
+	}
+	instance := ""
+	for _, opt := range opts {
+		if opt.Section == "Install" && opt.Name == "DefaultInstance" {
+			instance = opt.Value
+		}
+	}
+	return instance
+}
+
+// SystemdUnitDropIn represents a drop-in written to the unit's ".d" directory,
+// which amends the unit without replacing it.
 type SystemdUnitDropIn struct {
 	Name     SystemdUnitDropInName `json:"name,omitempty"`
 	Contents string                `json:"contents,omitempty"`
@@ -57,6 +108,9 @@ func (u SystemdUnitDropIn) Validate() report.Report {
 type SystemdUnitName string
 
 func (n SystemdUnitName) Validate() report.Report {
+	if strings.Contains(string(n), "/") {
+		return report.ReportFromError(ErrUnitNameSlash, report.EntryError)
+	}
 	switch path.Ext(string(n)) {
 	case ".service", ".socket", ".device", ".mount", ".automount", ".swap", ".target", ".path", ".timer", ".snapshot", ".slice", ".scope":
 		return report.Report{}
@@ -65,9 +119,34 @@ func (n SystemdUnitName) Validate() report.Report {
 	}
 }
 
+// IsTemplate reports whether n names a template unit, such as
+// "getty@.service".
+func (n SystemdUnitName) IsTemplate() bool {
+	return strings.HasSuffix(strings.TrimSuffix(string(n), path.Ext(string(n))), "@")
+}
+
+// Instance splits an instance name, such as "getty@tty1.service", into its
+// template and instance. Other names are returned unchanged, with an empty
+// instance.
+func (n SystemdUnitName) Instance() (SystemdUnitName, string) {
+	s := string(n)
+	at := strings.Index(s, "@")
+	if at < 0 {
+		return n, ""
+	}
+	ext := path.Ext(s)
+	if at+1 > len(s)-len(ext) {
+		return n, ""
+	}
+	return SystemdUnitName(s[:at+1] + ext), s[at+1 : len(s)-len(ext)]
+}
+
 type SystemdUnitDropInName string
 
 func (n SystemdUnitDropInName) Validate() report.Report {
+	if strings.Contains(string(n), "/") {
+		return report.ReportFromError(ErrUnitNameSlash, report.EntryError)
+	}
 	switch path.Ext(string(n)) {
 	case ".conf":
 		return report.Report{}
@@ -76,15 +155,21 @@ func (n SystemdUnitDropInName) Validate() report.Report {
 	}
 }
 
+// NetworkdUnit represents a networkd configuration file. Its drop-ins are
+// written to the file's ".d" directory.
 type NetworkdUnit struct {
-	Name     NetworkdUnitName `json:"name,omitempty"`
-	Contents string           `json:"contents,omitempty"`
+	Name     NetworkdUnitName    `json:"name,omitempty"`
+	Contents string              `json:"contents,omitempty"`
+	DropIns  []SystemdUnitDropIn `json:"dropins,omitempty"`
 }
 
 func (u NetworkdUnit) Validate() report.Report {
 	if err := validateUnitContent(u.Contents); err != nil {
 		return report.ReportFromError(err, report.EntryError)
 	}
+	if err := validateDropIns(u.DropIns); err != nil {
+		return report.ReportFromError(err, report.EntryError)
+	}
 
 	return report.Report{}
 }
@@ -100,6 +185,17 @@ func (n NetworkdUnitName) Validate() report.Report {
 	}
 }
 
+func validateDropIns(dropins []SystemdUnitDropIn) error {
+	names := map[SystemdUnitDropInName]bool{}
+	for _, d := range dropins {
+		if names[d.Name] {
+			return ErrDuplicateDropIn
+		}
+		names[d.Name] = true
+	}
+	return nil
+}
+
 func validateUnitContent(content string) error {
 	c := bytes.NewBufferString(content)
 	_, err := unit.Deserialize(c)
@@ -46,6 +46,26 @@ func TestSystemdUnitValidate(t *testing.T) {
 			in:  in{unit: SystemdUnit{Contents: "", DropIns: []SystemdUnitDropIn{{}}}},
 			out: out{err: nil},
 		},
+		{
+			in:  in{unit: SystemdUnit{DropIns: []SystemdUnitDropIn{{Name: "a.conf"}, {Name: "b.conf"}}}},
+			out: out{err: nil},
+		},
+		{
+			in:  in{unit: SystemdUnit{DropIns: []SystemdUnitDropIn{{Name: "a.conf"}, {Name: "a.conf"}}}},
+			out: out{err: ErrDuplicateDropIn},
+		},
+		{
+			in:  in{unit: SystemdUnit{Enabled: boolToPtr(false)}},
+			out: out{err: nil},
+		},
+		{
+			in:  in{unit: SystemdUnit{Enable: true, Enabled: boolToPtr(true)}},
+			out: out{err: nil},
+		},
+		{
+			in:  in{unit: SystemdUnit{Enable: true, Enabled: boolToPtr(false)}},
+			out: out{err: ErrUnitEnableConflict},
+		},
 	}
 
 	for i, test := range tests {
@@ -80,6 +100,10 @@ func TestSystemdUnitNameValidate(t *testing.T) {
 			in:  in{unit: SystemdUnitName("test.blah")},
 			out: out{err: errors.New("invalid systemd unit extension")},
 		},
+		{
+			in:  in{unit: SystemdUnitName("../test.service")},
+			out: out{err: ErrUnitNameSlash},
+		},
 	}
 
 	for i, test := range tests {
@@ -90,6 +114,49 @@ func TestSystemdUnitNameValidate(t *testing.T) {
 	}
 }
 
+func TestSystemdUnitNameInstance(t *testing.T) {
+	type in struct {
+		name SystemdUnitName
+	}
+	type out struct {
+		template bool
+		name     SystemdUnitName
+		instance string
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{name: SystemdUnitName("sshd.service")},
+			out: out{template: false, name: "sshd.service", instance: ""},
+		},
+		{
+			in:  in{name: SystemdUnitName("getty@.service")},
+			out: out{template: true, name: "getty@.service", instance: ""},
+		},
+		{
+			in:  in{name: SystemdUnitName("getty@tty1.service")},
+			out: out{template: false, name: "getty@.service", instance: "tty1"},
+		},
+		{
+			in:  in{name: SystemdUnitName("container@a@b.service")},
+			out: out{template: false, name: "container@.service", instance: "a@b"},
+		},
+	}
+
+	for i, test := range tests {
+		if template := test.in.name.IsTemplate(); template != test.out.template {
+			t.Errorf("#%d: bad template: want %v, got %v", i, test.out.template, template)
+		}
+		name, instance := test.in.name.Instance()
+		if name != test.out.name || instance != test.out.instance {
+			t.Errorf("#%d: bad instance: want %q %q, got %q %q", i, test.out.name, test.out.instance, name, instance)
+		}
+	}
+}
+
 func TestSystemdUnitDropInValidate(t *testing.T) {
 	type in struct {
 		unit SystemdUnitDropIn
@@ -120,6 +187,40 @@ func TestSystemdUnitDropInValidate(t *testing.T) {
 	}
 }
 
+func TestSystemdUnitDropInNameValidate(t *testing.T) {
+	type in struct {
+		name SystemdUnitDropInName
+	}
+	type out struct {
+		err error
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{name: SystemdUnitDropInName("10-override.conf")},
+			out: out{err: nil},
+		},
+		{
+			in:  in{name: SystemdUnitDropInName("10-override")},
+			out: out{err: errors.New("invalid systemd unit drop-in extension")},
+		},
+		{
+			in:  in{name: SystemdUnitDropInName("../10-override.conf")},
+			out: out{err: ErrUnitNameSlash},
+		},
+	}
+
+	for i, test := range tests {
+		err := test.in.name.Validate()
+		if !reflect.DeepEqual(report.ReportFromError(test.out.err, report.EntryError), err) {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.out.err, err)
+		}
+	}
+}
+
 func TestNetworkdUnitNameValidate(t *testing.T) {
 	type in struct {
 		unit NetworkdUnitName
@@ -178,6 +279,14 @@ func TestNetworkdUnitValidate(t *testing.T) {
 			in:  in{unit: NetworkdUnit{Contents: "[Foo"}},
 			out: out{err: errors.New("invalid unit content: unable to find end of section")},
 		},
+		{
+			in:  in{unit: NetworkdUnit{DropIns: []SystemdUnitDropIn{{Name: "10-mtu.conf"}, {Name: "20-dns.conf"}}}},
+			out: out{err: nil},
+		},
+		{
+			in:  in{unit: NetworkdUnit{DropIns: []SystemdUnitDropIn{{Name: "10-mtu.conf"}, {Name: "10-mtu.conf"}}}},
+			out: out{err: ErrDuplicateDropIn},
+		},
 	}
 
 	for i, test := range tests {
@@ -187,3 +296,41 @@ func TestNetworkdUnitValidate(t *testing.T) {
 		}
 	}
 }
+
+func TestSystemdValidate(t *testing.T) {
+	type in struct {
+		systemd Systemd
+	}
+	type out struct {
+		err error
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{systemd: Systemd{}},
+			out: out{},
+		},
+		{
+			in:  in{systemd: Systemd{DefaultTarget: func(n SystemdUnitName) *SystemdUnitName { return &n }("multi-user.target")}},
+			out: out{},
+		},
+		{
+			in:  in{systemd: Systemd{DefaultTarget: func(n SystemdUnitName) *SystemdUnitName { return &n }("sshd.service")}},
+			out: out{err: ErrDefaultTargetNotTarget},
+		},
+	}
+
+	for i, test := range tests {
+		err := test.in.systemd.Validate()
+		if !reflect.DeepEqual(report.ReportFromError(test.out.err, report.EntryError), err) {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.out.err, err)
+		}
+	}
+}
+
+func boolToPtr(b bool) *bool {
+	return &b
+}
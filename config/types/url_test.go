@@ -50,6 +50,10 @@ func TestURLValidate(t *testing.T) {
 			in:  in{u: "oem:///foobar"},
 			out: out{},
 		},
+		{
+			in:  in{u: "ssm://us-east-1/app/secret"},
+			out: out{},
+		},
 		{
 			in:  in{u: "data:,example%20file%0A"},
 			out: out{},
@@ -14,13 +14,63 @@
 
 package types
 
+import (
+	"errors"
+	"path"
+	"strings"
+
+	"github.com/coreos/ignition/config/validate/report"
+)
+
+var (
+	ErrUserNameEmpty    = errors.New("users require a name")
+	ErrAccountFieldChar = errors.New("account names and fields cannot contain colons or newlines")
+	ErrHomedirRelative  = errors.New("home directories must be absolute paths")
+	ErrShellRelative    = errors.New("shells must be absolute paths")
+	ErrPasswordHashForm = errors.New("password hash does not look like a crypt(3) hash; set it to the output of e.g. mkpasswd, not the password")
+)
+
+// User describes an account. Locked disables password logins, whether or not
+// a password hash is set, by prefixing the hash in /etc/shadow with "!"; SSH
+// keys keep working.
 type User struct {
 	Name              string      `json:"name,omitempty"`
 	PasswordHash      string      `json:"passwordHash,omitempty"`
+	Locked            bool        `json:"locked,omitempty"`
 	SSHAuthorizedKeys []string    `json:"sshAuthorizedKeys,omitempty"`
 	Create            *UserCreate `json:"create,omitempty"`
 }
 
+func (u User) Validate() report.Report {
+	if u.Name == "" {
+		return report.ReportFromError(ErrUserNameEmpty, report.EntryError)
+	}
+	if !validAccountField(u.Name) || !validAccountField(u.PasswordHash) {
+		return report.ReportFromError(ErrAccountFieldChar, report.EntryError)
+	}
+	if u.PasswordHash != "" && !validPasswordHash(u.PasswordHash) {
+		return report.ReportFromError(ErrPasswordHashForm, report.EntryWarning)
+	}
+	return report.Report{}
+}
+
+// validPasswordHash reports whether hash has the form of a crypt(3) hash: a
+// modular one such as "$6$salt$hash", a traditional DES one, or "*" or "!"
+// for no password at all.
+func validPasswordHash(hash string) bool {
+	hash = strings.TrimLeft(hash, "!")
+	switch {
+	case hash == "" || hash == "*":
+		return true
+	case strings.HasPrefix(hash, "$"):
+		return strings.Count(hash, "$") >= 3
+	default:
+		return len(hash) == 13 && strings.Trim(hash, "./0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz") == ""
+	}
+}
+
+// UserCreate describes the account created for a user by useradd, in the
+// target root. Without it, the user must already exist there.
 type UserCreate struct {
 	Uid          *uint    `json:"uid,omitempty"`
 	GECOS        string   `json:"gecos,omitempty"`
@@ -33,3 +83,25 @@ type UserCreate struct {
 	NoLogInit    bool     `json:"noLogInit,omitempty"`
 	Shell        string   `json:"shell,omitempty"`
 }
+
+func (c UserCreate) Validate() report.Report {
+	fields := append([]string{c.GECOS, c.Homedir, c.PrimaryGroup, c.Shell}, c.Groups...)
+	for _, f := range fields {
+		if !validAccountField(f) {
+			return report.ReportFromError(ErrAccountFieldChar, report.EntryError)
+		}
+	}
+	if c.Homedir != "" && !path.IsAbs(c.Homedir) {
+		return report.ReportFromError(ErrHomedirRelative, report.EntryError)
+	}
+	if c.Shell != "" && !path.IsAbs(c.Shell) {
+		return report.ReportFromError(ErrShellRelative, report.EntryError)
+	}
+	return report.Report{}
+}
+
+// validAccountField reports whether s can be stored in a field of the account
+// databases, which are colon-separated lines.
+func validAccountField(s string) bool {
+	return !strings.ContainsAny(s, ":\n")
+}
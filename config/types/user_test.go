@@ -0,0 +1,118 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/coreos/ignition/config/validate/report"
+)
+
+func TestUserValidate(t *testing.T) {
+	type in struct {
+		user User
+	}
+	type out struct {
+		report report.Report
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{user: User{Name: "core"}},
+			out: out{report: report.Report{}},
+		},
+		{
+			in:  in{user: User{}},
+			out: out{report: report.ReportFromError(ErrUserNameEmpty, report.EntryError)},
+		},
+		{
+			in:  in{user: User{Name: "core:0"}},
+			out: out{report: report.ReportFromError(ErrAccountFieldChar, report.EntryError)},
+		},
+		{
+			in:  in{user: User{Name: "core", PasswordHash: "$6$rounds=4096$salt$hash", Locked: true}},
+			out: out{report: report.Report{}},
+		},
+		{
+			in:  in{user: User{Name: "core", PasswordHash: "abJnggxhB/yWI"}},
+			out: out{report: report.Report{}},
+		},
+		{
+			in:  in{user: User{Name: "core", PasswordHash: "!*"}},
+			out: out{report: report.Report{}},
+		},
+		{
+			in:  in{user: User{Name: "core", PasswordHash: "hunter2"}},
+			out: out{report: report.ReportFromError(ErrPasswordHashForm, report.EntryWarning)},
+		},
+		{
+			in:  in{user: User{Name: "core", PasswordHash: "$6$salt:hash"}},
+			out: out{report: report.ReportFromError(ErrAccountFieldChar, report.EntryError)},
+		},
+	}
+
+	for i, test := range tests {
+		r := test.in.user.Validate()
+		if !reflect.DeepEqual(test.out.report, r) {
+			t.Errorf("#%d: bad report: want %v, got %v", i, test.out.report, r)
+		}
+	}
+}
+
+func TestUserCreateValidate(t *testing.T) {
+	type in struct {
+		create UserCreate
+	}
+	type out struct {
+		report report.Report
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{create: UserCreate{GECOS: "Core User, Ops", Homedir: "/home/core", Shell: "/bin/bash"}},
+			out: out{report: report.Report{}},
+		},
+		{
+			in:  in{create: UserCreate{GECOS: "a:b"}},
+			out: out{report: report.ReportFromError(ErrAccountFieldChar, report.EntryError)},
+		},
+		{
+			in:  in{create: UserCreate{Groups: []string{"wheel\nroot"}}},
+			out: out{report: report.ReportFromError(ErrAccountFieldChar, report.EntryError)},
+		},
+		{
+			in:  in{create: UserCreate{Homedir: "home/core"}},
+			out: out{report: report.ReportFromError(ErrHomedirRelative, report.EntryError)},
+		},
+		{
+			in:  in{create: UserCreate{Shell: "bash"}},
+			out: out{report: report.ReportFromError(ErrShellRelative, report.EntryError)},
+		},
+	}
+
+	for i, test := range tests {
+		r := test.in.create.Validate()
+		if !reflect.DeepEqual(test.out.report, r) {
+			t.Errorf("#%d: bad report: want %v, got %v", i, test.out.report, r)
+		}
+	}
+}
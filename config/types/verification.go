@@ -16,4 +16,8 @@ package types
 
 type Verification struct {
 	Hash *Hash `json:"hash,omitempty"`
+	// Decompressed checks Hash against the contents once they are
+	// decompressed, rather than as fetched, so that a digest published for
+	// the uncompressed artifact can be used.
+	Decompressed bool `json:"decompressed,omitempty"`
 }
@@ -0,0 +1,52 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"errors"
+	"regexp"
+
+	"github.com/coreos/ignition/config/validate/report"
+)
+
+var (
+	ErrZfcpInvalidBusId = errors.New("zfcp bus id must have the form \"0.0.1234\"")
+	ErrZfcpInvalidWwpn  = errors.New("zfcp wwpn must be a 16 digit hex number prefixed with \"0x\"")
+	ErrZfcpInvalidLun   = errors.New("zfcp lun must be a 16 digit hex number prefixed with \"0x\"")
+
+	zfcpBusIdRegexp = regexp.MustCompile("^[[:xdigit:]]\\.[[:xdigit:]]\\.[[:xdigit:]]{4}$")
+	zfcpPortRegexp  = regexp.MustCompile("^0x[[:xdigit:]]{16}$")
+)
+
+// Zfcp describes a SCSI device attached through a zFCP (FCP channel) adapter
+// on IBM Z.
+type Zfcp struct {
+	BusId string `json:"busId,omitempty"`
+	Wwpn  string `json:"wwpn,omitempty"`
+	Lun   string `json:"lun,omitempty"`
+}
+
+func (z Zfcp) Validate() report.Report {
+	if !zfcpBusIdRegexp.MatchString(z.BusId) {
+		return report.ReportFromError(ErrZfcpInvalidBusId, report.EntryError)
+	}
+	if !zfcpPortRegexp.MatchString(z.Wwpn) {
+		return report.ReportFromError(ErrZfcpInvalidWwpn, report.EntryError)
+	}
+	if !zfcpPortRegexp.MatchString(z.Lun) {
+		return report.ReportFromError(ErrZfcpInvalidLun, report.EntryError)
+	}
+	return report.Report{}
+}
@@ -0,0 +1,64 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/coreos/ignition/config/validate/report"
+)
+
+func TestZfcpValidate(t *testing.T) {
+	type in struct {
+		zfcp Zfcp
+	}
+	type out struct {
+		err error
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{zfcp: Zfcp{BusId: "0.0.1900", Wwpn: "0x500507630300c562", Lun: "0x4010403200000000"}},
+			out: out{},
+		},
+		{
+			in:  in{zfcp: Zfcp{BusId: "1900", Wwpn: "0x500507630300c562", Lun: "0x4010403200000000"}},
+			out: out{err: ErrZfcpInvalidBusId},
+		},
+		{
+			in:  in{zfcp: Zfcp{BusId: "0.0.1900", Wwpn: "500507630300c562", Lun: "0x4010403200000000"}},
+			out: out{err: ErrZfcpInvalidWwpn},
+		},
+		{
+			in:  in{zfcp: Zfcp{BusId: "0.0.1900", Wwpn: "0x500507630300c562", Lun: "0x40104032"}},
+			out: out{err: ErrZfcpInvalidLun},
+		},
+		{
+			in:  in{zfcp: Zfcp{}},
+			out: out{err: ErrZfcpInvalidBusId},
+		},
+	}
+
+	for i, test := range tests {
+		err := test.in.zfcp.Validate()
+		if !reflect.DeepEqual(report.ReportFromError(test.out.err, report.EntryError), err) {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.out.err, err)
+		}
+	}
+}
@@ -84,6 +84,7 @@ func Parse(rawConfig []byte) (types.Config, report.Report, error) {
 					Line:      line,
 					Column:    col,
 					Highlight: highlight,
+					Path:      astjson.RawPointerAt(rawConfig, terr.Offset),
 				}},
 			},
 			ErrInvalid
@@ -15,6 +15,7 @@
 package node
 
 import (
+	"fmt"
 	"io"
 
 	json "github.com/ajeddeloh/go-json"
@@ -71,3 +72,36 @@ func posFromOffset(offset int, source io.ReadSeeker) (int, int, string) {
 	source.Seek(0, 0) // Reset the reader to the start so the next call isn't relative to this position
 	return line, col, highlight
 }
+
+// PointerAt returns the JSON pointer, relative to n, of the innermost value containing offset, such
+// as the offset at which decoding a value failed.
+func PointerAt(n json.Node, offset int) string {
+	if offset < n.Start || offset > n.End {
+		return ""
+	}
+	switch value := n.Value.(type) {
+	case map[string]json.Node:
+		for k, child := range value {
+			if offset >= child.Start && offset <= child.End {
+				return "/" + validate.EscapePointer(k) + PointerAt(child, offset)
+			}
+		}
+	case []json.Node:
+		for i, child := range value {
+			if offset >= child.Start && offset <= child.End {
+				return fmt.Sprintf("/%d", i) + PointerAt(child, offset)
+			}
+		}
+	}
+	return ""
+}
+
+// RawPointerAt returns the JSON pointer of the innermost value of rawConfig containing offset, or ""
+// if rawConfig can't be parsed as JSON.
+func RawPointerAt(rawConfig []byte, offset int64) string {
+	var ast json.Node
+	if err := json.Unmarshal(rawConfig, &ast); err != nil {
+		return ""
+	}
+	return PointerAt(ast, int(offset))
+}
@@ -85,6 +85,17 @@ func (r *Report) AddPosition(line, col int, highlight string) {
 	}
 }
 
+// AddPath updates all the entries without a Path and sets it to path, the JSON pointer of the node
+// that produced them. Entries from deeper nodes have already been given their own, more precise
+// path by the time this is called on their parents.
+func (r *Report) AddPath(path string) {
+	for i, e := range r.Entries {
+		if e.Path == "" {
+			r.Entries[i].Path = path
+		}
+	}
+}
+
 func (r *Report) Add(e Entry) {
 	r.Entries = append(r.Entries, e)
 }
@@ -111,6 +122,16 @@ func (r Report) IsFatal() bool {
 	return false
 }
 
+// Strict promotes the entries about unrecognized keys to errors, so that typos
+// make the config invalid instead of being silently ignored.
+func (r *Report) Strict() {
+	for i, e := range r.Entries {
+		if e.Unrecognized {
+			r.Entries[i].Kind = EntryError
+		}
+	}
+}
+
 // IsDeprecated returns if the report has deprecations
 func (r Report) IsDeprecated() bool {
 	for _, entry := range r.Entries {
@@ -127,13 +148,30 @@ type Entry struct {
 	Line      int       `json:"line,omitempty"`
 	Column    int       `json:"column,omitempty"`
 	Highlight string    `json:"-"`
+	// Path is the JSON pointer (RFC 6901) of the offending value, e.g. /storage/files/0/mode.
+	Path string `json:"path,omitempty"`
+	// Hint is an optional suggestion for how to fix the problem.
+	Hint string `json:"hint,omitempty"`
+	// Unrecognized marks entries about keys the config spec doesn't define.
+	Unrecognized bool `json:"-"`
 }
 
 func (e Entry) String() string {
-	if e.Line != 0 {
-		return fmt.Sprintf("%s at line %d, column %d\n%s%v", e.Kind.String(), e.Line, e.Column, e.Highlight, e.Message)
+	var str string
+	switch {
+	case e.Line != 0 && e.Path != "":
+		str = fmt.Sprintf("%s at %s, line %d, column %d\n%s%v", e.Kind.String(), e.Path, e.Line, e.Column, e.Highlight, e.Message)
+	case e.Line != 0:
+		str = fmt.Sprintf("%s at line %d, column %d\n%s%v", e.Kind.String(), e.Line, e.Column, e.Highlight, e.Message)
+	case e.Path != "":
+		str = fmt.Sprintf("%s at %s: %v", e.Kind.String(), e.Path, e.Message)
+	default:
+		str = fmt.Sprintf("%s: %v", e.Kind.String(), e.Message)
+	}
+	if e.Hint != "" {
+		str += fmt.Sprintf(" (%s)", e.Hint)
 	}
-	return fmt.Sprintf("%s: %v", e.Kind.String(), e.Message)
+	return str
 }
 
 type entryKind int
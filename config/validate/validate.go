@@ -55,7 +55,13 @@ type AstNode interface {
 
 // Validate walks down a struct tree calling Validate on every node that implements it, building
 // A report of all the errors, warnings, info, and deprecations it encounters
-func Validate(vObj reflect.Value, ast AstNode, source io.ReadSeeker) (r report.Report) {
+func Validate(vObj reflect.Value, ast AstNode, source io.ReadSeeker) report.Report {
+	return validate(vObj, ast, source, "")
+}
+
+// validate does the work of Validate, tracking the JSON pointer of the node being validated so
+// every entry can be attributed to the exact key it came from.
+func validate(vObj reflect.Value, ast AstNode, source io.ReadSeeker, path string) (r report.Report) {
 	if !vObj.IsValid() {
 		return
 	}
@@ -75,6 +81,7 @@ func Validate(vObj reflect.Value, ast AstNode, source io.ReadSeeker) (r report.R
 			(!vObj.IsNil() && !vObj.Elem().Type().Implements(reflect.TypeOf((*validator)(nil)).Elem()))) {
 		sub_r := obj.Validate()
 		sub_r.AddPosition(line, col, highlight)
+		sub_r.AddPath(path)
 		r.Merge(sub_r)
 
 		// Dont recurse on invalid inner nodes, it mostly leads to bogus messages
@@ -85,11 +92,11 @@ func Validate(vObj reflect.Value, ast AstNode, source io.ReadSeeker) (r report.R
 
 	switch vObj.Kind() {
 	case reflect.Ptr:
-		sub_report := Validate(vObj.Elem(), ast, source)
+		sub_report := validate(vObj.Elem(), ast, source, path)
 		sub_report.AddPosition(line, col, "")
 		r.Merge(sub_report)
 	case reflect.Struct:
-		sub_report := validateStruct(vObj, ast, source)
+		sub_report := validateStruct(vObj, ast, source, path)
 		sub_report.AddPosition(line, col, "")
 		r.Merge(sub_report)
 	case reflect.Slice:
@@ -100,7 +107,7 @@ func Validate(vObj reflect.Value, ast AstNode, source io.ReadSeeker) (r report.R
 					sub_node = n
 				}
 			}
-			sub_report := Validate(vObj.Index(i), sub_node, source)
+			sub_report := validate(vObj.Index(i), sub_node, source, fmt.Sprintf("%s/%d", path, i))
 			sub_report.AddPosition(line, col, "")
 			r.Merge(sub_report)
 		}
@@ -137,7 +144,7 @@ func getFields(vObj reflect.Value) []field {
 	return ret
 }
 
-func validateStruct(vObj reflect.Value, ast AstNode, source io.ReadSeeker) report.Report {
+func validateStruct(vObj reflect.Value, ast AstNode, source io.ReadSeeker, path string) report.Report {
 	r := report.Report{}
 
 	// isFromObject will be true if this struct was unmarshalled from a JSON object.
@@ -173,7 +180,7 @@ func validateStruct(vObj reflect.Value, ast AstNode, source io.ReadSeeker) repor
 				src = source
 			}
 		}
-		sub_report := Validate(f.Value, sub_node, src)
+		sub_report := validate(f.Value, sub_node, src, path+"/"+EscapePointer(fieldName(f.Type)))
 		// Default to deepest node if the node's type isn't an object,
 		// such as when a json string actually unmarshal to structs (like with version)
 		line, col := 0, 0
@@ -196,11 +203,13 @@ func validateStruct(vObj reflect.Value, ast AstNode, source io.ReadSeeker) repor
 		typo := similar(k, tags)
 
 		r.Add(report.Entry{
-			Kind:      report.EntryWarning,
-			Message:   fmt.Sprintf("Config has unrecognized key: %s", k),
-			Line:      line,
-			Column:    col,
-			Highlight: highlight,
+			Kind:         report.EntryWarning,
+			Message:      fmt.Sprintf("Config has unrecognized key: %s", k),
+			Line:         line,
+			Column:       col,
+			Highlight:    highlight,
+			Path:         path + "/" + EscapePointer(k),
+			Unrecognized: true,
 		})
 
 		if typo != "" {
@@ -210,6 +219,7 @@ func validateStruct(vObj reflect.Value, ast AstNode, source io.ReadSeeker) repor
 				Line:      line,
 				Column:    col,
 				Highlight: highlight,
+				Path:      path + "/" + EscapePointer(k),
 			})
 		}
 	}
@@ -227,3 +237,17 @@ func similar(str string, candidates []string) string {
 	}
 	return ""
 }
+
+// fieldName returns the JSON key of a struct field, falling back to the Go field name for
+// fields without a json tag.
+func fieldName(f reflect.StructField) string {
+	if name := strings.SplitN(f.Tag.Get("json"), ",", 2)[0]; name != "" && name != "-" {
+		return name
+	}
+	return f.Name
+}
+
+// EscapePointer escapes a JSON object key for use as a JSON pointer (RFC 6901) reference token.
+func EscapePointer(key string) string {
+	return strings.Replace(strings.Replace(key, "~", "~0", -1), "/", "~1", -1)
+}
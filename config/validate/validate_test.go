@@ -29,7 +29,8 @@ func TestValidate(t *testing.T) {
 		cfg Config
 	}
 	type out struct {
-		err error
+		err  error
+		path string
 	}
 
 	tests := []struct {
@@ -42,7 +43,7 @@ func TestValidate(t *testing.T) {
 		},
 		{
 			in:  in{cfg: Config{}},
-			out: out{err: ErrOldVersion},
+			out: out{err: ErrOldVersion, path: "/ignition/version"},
 		},
 		{
 			in: in{cfg: Config{
@@ -57,7 +58,7 @@ func TestValidate(t *testing.T) {
 					},
 				},
 			}},
-			out: out{errors.New("unrecognized hash function")},
+			out: out{err: errors.New("unrecognized hash function"), path: "/ignition/config/replace/verification/hash"},
 		},
 		{
 			in: in{cfg: Config{
@@ -95,13 +96,16 @@ func TestValidate(t *testing.T) {
 				Ignition: Ignition{Version: IgnitionVersion{Major: 2}},
 				Systemd:  Systemd{Units: []SystemdUnit{{Name: "foo.bar", Contents: "[Foo]\nfoo=qux"}}},
 			}},
-			out: out{err: errors.New("invalid systemd unit extension")},
+			out: out{err: errors.New("invalid systemd unit extension"), path: "/systemd/units/0/name"},
 		},
 	}
 
 	for i, test := range tests {
 		r := ValidateWithoutSource(reflect.ValueOf(test.in.cfg))
 		expectedReport := report.ReportFromError(test.out.err, report.EntryError)
+		if test.out.path != "" {
+			expectedReport.Entries[0].Path = test.out.path
+		}
 		if !reflect.DeepEqual(expectedReport, r) {
 			t.Errorf("#%d: bad error: want %v, got %v", i, expectedReport, r)
 		}
@@ -0,0 +1,224 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package engine runs Ignition's stages in-process, against a config from an
+// arbitrary provider and an arbitrary root, for installers and test tools
+// that would otherwise shell out to the ignition binary.
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"github.com/coreos/ignition/config/types"
+	"github.com/coreos/ignition/internal/exec"
+	"github.com/coreos/ignition/internal/exec/stages"
+	_ "github.com/coreos/ignition/internal/exec/stages/disks"
+	_ "github.com/coreos/ignition/internal/exec/stages/files"
+	_ "github.com/coreos/ignition/internal/exec/stages/kargs"
+	_ "github.com/coreos/ignition/internal/exec/stages/metadata"
+	_ "github.com/coreos/ignition/internal/exec/stages/umount"
+	"github.com/coreos/ignition/internal/log"
+	"github.com/coreos/ignition/internal/providers/util"
+)
+
+var (
+	ErrNoProvider       = errors.New("no config provider given")
+	ErrRelativeRoot     = errors.New("root must be an absolute path")
+	ErrRelativeStateDir = errors.New("state directory must be an absolute path")
+	ErrFetchFailed      = errors.New("failed to fetch the config")
+	ErrConfigInvalid    = errors.New("config is invalid")
+	ErrStageFailed      = errors.New("stage failed")
+)
+
+// Provider supplies the config the engine runs against.
+type Provider interface {
+	// FetchConfig returns the raw config, giving up when ctx is done.
+	FetchConfig(ctx context.Context) ([]byte, error)
+}
+
+// ProviderFunc adapts a function to a Provider.
+type ProviderFunc func(ctx context.Context) ([]byte, error)
+
+func (f ProviderFunc) FetchConfig(ctx context.Context) ([]byte, error) {
+	return f(ctx)
+}
+
+// StaticConfig is a Provider always returning the same raw config.
+type StaticConfig []byte
+
+func (c StaticConfig) FetchConfig(context.Context) ([]byte, error) {
+	return c, nil
+}
+
+// Logger receives the engine's messages, already formatted, at the syslog
+// severity of each.
+type Logger interface {
+	Emerg(string) error
+	Alert(string) error
+	Crit(string) error
+	Err(string) error
+	Warning(string) error
+	Notice(string) error
+	Info(string) error
+	Debug(string) error
+	Close() error
+}
+
+// Option configures an Engine created by New.
+type Option func(*Engine) error
+
+// WithRoot runs the stages against the system rooted at root, which defaults
+// to "/".
+func WithRoot(root string) Option {
+	return func(e *Engine) error {
+		if !filepath.IsAbs(root) {
+			return ErrRelativeRoot
+		}
+		e.root = filepath.Clean(root)
+		return nil
+	}
+}
+
+// WithStateDir keeps the state the stages hand to one another, such as the
+// filesystems left mounted for the umount stage, fetched luks keys, and the
+// kargs reboot marker, in dir. It defaults to run/ignition beneath the root,
+// which is /run/ignition, as for the ignition binary, when the root is "/".
+func WithStateDir(dir string) Option {
+	return func(e *Engine) error {
+		if !filepath.IsAbs(dir) {
+			return ErrRelativeStateDir
+		}
+		e.stateDir = filepath.Clean(dir)
+		return nil
+	}
+}
+
+// WithProvider fetches the config from p. It is required.
+func WithProvider(p Provider) Option {
+	return func(e *Engine) error {
+		e.provider = p
+		return nil
+	}
+}
+
+// WithLogger sends the engine's messages to l rather than to syslog. The
+// engine doesn't close l.
+func WithLogger(l Logger) Option {
+	return func(e *Engine) error {
+		e.logger = l
+		return nil
+	}
+}
+
+// WithStages runs only the named stages, in the order given, rather than all
+// of them. With no names, Run only fetches and validates the config.
+func WithStages(names ...string) Option {
+	return func(e *Engine) error {
+		for _, name := range names {
+			if stages.Get(name) == nil {
+				return fmt.Errorf("unknown stage %q", name)
+			}
+		}
+		e.stages = append([]string{}, names...)
+		return nil
+	}
+}
+
+// WithPlatform names the platform the stages run on, as the -oem flag of the
+// ignition binary does.
+func WithPlatform(name string) Option {
+	return func(e *Engine) error {
+		e.platform = name
+		return nil
+	}
+}
+
+// Engine runs the stages against the config from its provider.
+type Engine struct {
+	root     string
+	stateDir string
+	provider Provider
+	logger   Logger
+	stages   []string
+	platform string
+}
+
+// New creates an Engine configured by opts.
+func New(opts ...Option) (*Engine, error) {
+	e := &Engine{
+		root:   "/",
+		stages: Stages(),
+	}
+	for _, opt := range opts {
+		if err := opt(e); err != nil {
+			return nil, err
+		}
+	}
+	if e.provider == nil {
+		return nil, ErrNoProvider
+	}
+	if e.stateDir == "" {
+		e.stateDir = filepath.Join(e.root, "run", "ignition")
+	}
+	return e, nil
+}
+
+// Stages returns the names of all the stages, in the order in which they
+// run by default.
+func Stages() []string {
+	return stages.Ordered()
+}
+
+// Run fetches the config and runs the stages against it. Cancelling ctx
+// aborts fetches in flight and stops the stages before their next step.
+//
+// Unlike the ignition binary, Run neither caches the config nor writes the
+// rendered config, the result file, or the completion marker. The only files
+// it writes outside the root are those in the state directory.
+func (e *Engine) Run(ctx context.Context) error {
+	logger := log.New()
+	if e.logger != nil {
+		logger = log.NewWithOps(e.logger)
+	} else {
+		defer logger.Close()
+	}
+
+	root := types.Path(e.root)
+	engine := exec.Engine{
+		Logger:        &logger,
+		Root:          e.root,
+		StateDir:      e.stateDir,
+		Platform:      e.platform,
+		NoConfigCache: true,
+		FetchFunc:     util.FetchRawFunc(e.provider.FetchConfig),
+		OemBaseConfig: types.Config{
+			Storage: types.Storage{
+				Filesystems: []types.Filesystem{{Name: "root", Path: &root}},
+			},
+		},
+	}
+
+	switch engine.RunStages(ctx, e.stages) {
+	case exec.ResultFetchFailed:
+		return ErrFetchFailed
+	case exec.ResultConfigInvalid:
+		return ErrConfigInvalid
+	case exec.ResultStageFailed:
+		return ErrStageFailed
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package engine
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// discard is a Logger dropping every message.
+type discard struct{}
+
+func (discard) Emerg(string) error   { return nil }
+func (discard) Alert(string) error   { return nil }
+func (discard) Crit(string) error    { return nil }
+func (discard) Err(string) error     { return nil }
+func (discard) Warning(string) error { return nil }
+func (discard) Notice(string) error  { return nil }
+func (discard) Info(string) error    { return nil }
+func (discard) Debug(string) error   { return nil }
+func (discard) Close() error         { return nil }
+
+func TestNew(t *testing.T) {
+	type in struct {
+		opts []Option
+	}
+	type out struct {
+		err      error
+		root     string
+		stateDir string
+		stages   []string
+	}
+
+	provider := StaticConfig(`{"ignition": {"version": "2.0.0"}}`)
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{opts: []Option{WithProvider(provider)}},
+			out: out{root: "/", stateDir: "/run/ignition", stages: Stages()},
+		},
+		{
+			in:  in{opts: []Option{WithProvider(provider), WithRoot("/mnt/target/"), WithStages("files", "disks")}},
+			out: out{root: "/mnt/target", stateDir: "/mnt/target/run/ignition", stages: []string{"files", "disks"}},
+		},
+		{
+			in:  in{opts: []Option{WithProvider(provider), WithRoot("/mnt/target"), WithStateDir("/tmp/state/")}},
+			out: out{root: "/mnt/target", stateDir: "/tmp/state", stages: Stages()},
+		},
+		{
+			in:  in{opts: []Option{WithProvider(provider), WithStages()}},
+			out: out{root: "/", stateDir: "/run/ignition", stages: []string{}},
+		},
+		{
+			in:  in{},
+			out: out{err: ErrNoProvider},
+		},
+		{
+			in:  in{opts: []Option{WithProvider(provider), WithRoot("target")}},
+			out: out{err: ErrRelativeRoot},
+		},
+		{
+			in:  in{opts: []Option{WithProvider(provider), WithStateDir("state")}},
+			out: out{err: ErrRelativeStateDir},
+		},
+		{
+			in:  in{opts: []Option{WithProvider(provider), WithStages("bogus")}},
+			out: out{err: errors.New(`unknown stage "bogus"`)},
+		},
+	}
+
+	for i, test := range tests {
+		e, err := New(test.in.opts...)
+		if !reflect.DeepEqual(test.out.err, err) {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.out.err, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if e.root != test.out.root {
+			t.Errorf("#%d: bad root: want %q, got %q", i, test.out.root, e.root)
+		}
+		if e.stateDir != test.out.stateDir {
+			t.Errorf("#%d: bad state dir: want %q, got %q", i, test.out.stateDir, e.stateDir)
+		}
+		if !reflect.DeepEqual(test.out.stages, e.stages) {
+			t.Errorf("#%d: bad stages: want %v, got %v", i, test.out.stages, e.stages)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	type in struct {
+		provider Provider
+	}
+	type out struct {
+		err error
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{provider: StaticConfig(`{"ignition": {"version": "2.0.0"}}`)},
+			out: out{},
+		},
+		{
+			in:  in{provider: StaticConfig(`{"ignition": {"version": "2.0.0"}, "storage": {"files": [{"path": "relative"}]}}`)},
+			out: out{err: ErrConfigInvalid},
+		},
+		{
+			in: in{provider: ProviderFunc(func(context.Context) ([]byte, error) {
+				return nil, errors.New("metadata service unreachable")
+			})},
+			out: out{err: ErrFetchFailed},
+		},
+	}
+
+	for i, test := range tests {
+		e, err := New(WithProvider(test.in.provider), WithLogger(discard{}), WithStages())
+		if err != nil {
+			t.Errorf("#%d: failed to create engine: %v", i, err)
+			continue
+		}
+		if err := e.Run(context.Background()); err != test.out.err {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.out.err, err)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package distro holds the settings which differ between the distributions
+// Ignition is built for. Each may be overridden at build time, e.g. with
+// -ldflags "-X github.com/coreos/ignition/internal/distro.writeAuthorizedKeysFragment=false".
+package distro
+
+var (
+	// writeAuthorizedKeysFragment selects whether SSH keys are written to a
+	// fragment in ~/.ssh/authorized_keys.d, which update-ssh-keys merges into
+	// ~/.ssh/authorized_keys, or to ~/.ssh/authorized_keys directly.
+	writeAuthorizedKeysFragment = "true"
+
+	// kargsHook is an executable which applies kernel arguments to the
+	// target system in place of Ignition's own editing of its boot loader
+	// config, for distributions managing it some other way.
+	kargsHook = ""
+
+	// userdataHandler is an executable to which userdata that is a
+	// cloud-config or a script is handed off, such as a wrapper which
+	// enables cloud-init, for distributions supporting both.
+	userdataHandler = ""
+)
+
+func WriteAuthorizedKeysFragment() bool {
+	return writeAuthorizedKeysFragment == "true"
+}
+
+func KargsHook() string {
+	return kargsHook
+}
+
+func UserdataHandler() string {
+	return userdataHandler
+}
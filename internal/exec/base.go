@@ -0,0 +1,120 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/coreos/ignition/config"
+	"github.com/coreos/ignition/config/types"
+)
+
+// DefaultBaseConfigDir is where distributions ship the base configs baked
+// into the initramfs.
+const DefaultBaseConfigDir = "/usr/lib/ignition"
+
+// loadBaseConfigs appends the *.ign configs in the base.d directory of
+// e.BaseConfigDir and then those in its base.platform.d/<platform> directory,
+// each in lexical order, so later ones override earlier ones. Base configs are
+// not rendered, so any configs they reference are ignored.
+func (e Engine) loadBaseConfigs() (types.Config, error) {
+	cfg := types.Config{}
+	if e.BaseConfigDir == "" {
+		return cfg, nil
+	}
+
+	dirs := []string{filepath.Join(e.BaseConfigDir, "base.d")}
+	if e.Platform != "" {
+		dirs = append(dirs, filepath.Join(e.BaseConfigDir, "base.platform.d", e.Platform))
+	}
+	for _, dir := range dirs {
+		paths, err := filepath.Glob(filepath.Join(dir, "*.ign"))
+		if err != nil {
+			return types.Config{}, err
+		}
+		for _, path := range paths {
+			base, ok, err := e.readImageConfig("base", path)
+			if err != nil {
+				return types.Config{}, err
+			}
+			if !ok {
+				continue
+			}
+			if base.Ignition.Config.Replace != nil || len(base.Ignition.Config.Append) != 0 {
+				e.Logger.Warning("base config %q references other configs, which are ignored", path)
+				base.Ignition.Config = types.IgnitionConfig{}
+			}
+			e.Logger.Info("using base config %q", path)
+			cfg = config.Append(cfg, base)
+		}
+	}
+	return cfg, nil
+}
+
+// loadUserConfigs returns the user.ign config in e.BaseConfigDir merged with
+// the *.ign configs in its user.d directory, in lexical order, so that images
+// can ship their own provisioning config. Unlike base configs, they are
+// rendered like the provider's config, beneath which they are merged.
+func (e *Engine) loadUserConfigs() (types.Config, error) {
+	cfg := types.Config{}
+	if e.BaseConfigDir == "" {
+		return cfg, nil
+	}
+
+	paths, err := filepath.Glob(filepath.Join(e.BaseConfigDir, "user.d", "*.ign"))
+	if err != nil {
+		return types.Config{}, err
+	}
+	paths = append([]string{filepath.Join(e.BaseConfigDir, "user.ign")}, paths...)
+	for _, path := range paths {
+		user, ok, err := e.readImageConfig("user", path)
+		if err != nil {
+			return types.Config{}, err
+		}
+		if !ok {
+			continue
+		}
+		e.Logger.Info("using embedded user config %q", path)
+		if user, err = e.renderConfig(user); err != nil {
+			return types.Config{}, err
+		}
+		cfg = config.Append(cfg, user)
+	}
+	return cfg, nil
+}
+
+// readImageConfig parses the kind of config shipped in the image at path,
+// returning false if there is none or it is empty.
+func (e Engine) readImageConfig(kind, path string) (types.Config, bool, error) {
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return types.Config{}, false, nil
+	}
+	if err != nil {
+		return types.Config{}, false, fmt.Errorf("failed to read %s config: %v", kind, err)
+	}
+	cfg, r, err := config.Parse(b)
+	err = e.checkReport(r, err)
+	if err == config.ErrEmpty {
+		return types.Config{}, false, nil
+	}
+	if err != nil {
+		return types.Config{}, false, invalidConfigError{fmt.Errorf("invalid %s config %q: %v", kind, path, err)}
+	}
+	return cfg, true, nil
+}
@@ -0,0 +1,211 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/coreos/ignition/config/types"
+	"github.com/coreos/ignition/internal/log"
+	"github.com/coreos/ignition/internal/resource"
+
+	"golang.org/x/net/context"
+)
+
+func TestLoadBaseConfigs(t *testing.T) {
+	config := func(path string) string {
+		return `{"ignition":{"version":"2.1.0-experimental"},"storage":{"files":[{"filesystem":"root","path":"` + path + `"}]}}`
+	}
+
+	type in struct {
+		files    map[string]string
+		platform string
+	}
+	type out struct {
+		paths   []types.Path
+		invalid bool
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{},
+			out: out{},
+		},
+		{
+			in: in{
+				files: map[string]string{
+					"base.d/20-b.ign":                 config("/b"),
+					"base.d/10-a.ign":                 config("/a"),
+					"base.d/README":                   "not a config",
+					"base.platform.d/qemu/10-q.ign":   config("/qemu"),
+					"base.platform.d/vmware/10-v.ign": config("/vmware"),
+				},
+				platform: "qemu",
+			},
+			out: out{paths: []types.Path{"/a", "/b", "/qemu"}},
+		},
+		{
+			in: in{
+				files: map[string]string{
+					"base.d/10-a.ign":     config("/a"),
+					"base.d/20-empty.ign": "",
+				},
+			},
+			out: out{paths: []types.Path{"/a"}},
+		},
+		{
+			in: in{
+				files: map[string]string{
+					"base.d/10-bad.ign": `{"ignition":`,
+				},
+			},
+			out: out{invalid: true},
+		},
+	}
+
+	logger := log.New()
+	defer logger.Close()
+
+	for i, test := range tests {
+		dir, err := ioutil.TempDir("", "ignition-base")
+		if err != nil {
+			t.Fatalf("failed to create temp dir: %v", err)
+		}
+		defer os.RemoveAll(dir)
+		for name, contents := range test.in.files {
+			path := filepath.Join(dir, name)
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				t.Fatalf("failed to create dir: %v", err)
+			}
+			if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+				t.Fatalf("failed to write %q: %v", name, err)
+			}
+		}
+
+		e := Engine{Logger: &logger, BaseConfigDir: dir, Platform: test.in.platform}
+		cfg, err := e.loadBaseConfigs()
+		if _, ok := err.(invalidConfigError); ok != test.out.invalid {
+			t.Errorf("#%d: bad error: want invalid %v, got %v", i, test.out.invalid, err)
+		}
+		var paths []types.Path
+		for _, f := range cfg.Storage.Files {
+			paths = append(paths, f.Path)
+		}
+		if !reflect.DeepEqual(test.out.paths, paths) {
+			t.Errorf("#%d: bad files: want %v, got %v", i, test.out.paths, paths)
+		}
+	}
+}
+
+func TestLoadUserConfigs(t *testing.T) {
+	config := func(path string) string {
+		return `{"ignition":{"version":"2.1.0-experimental"},"storage":{"files":[{"filesystem":"root","path":"` + path + `"}]}}`
+	}
+
+	type in struct {
+		files map[string]string
+	}
+	type out struct {
+		paths   []types.Path
+		invalid bool
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{},
+			out: out{},
+		},
+		{
+			in: in{
+				files: map[string]string{
+					"user.d/20-b.ign": config("/b"),
+					"user.d/10-a.ign": config("/a"),
+					"user.ign":        config("/user"),
+					"base.d/10-x.ign": config("/x"),
+				},
+			},
+			out: out{paths: []types.Path{"/user", "/a", "/b"}},
+		},
+		{
+			in: in{
+				files: map[string]string{
+					"user.d/10-a.ign": config("/a"),
+					"user.ign":        "",
+				},
+			},
+			out: out{paths: []types.Path{"/a"}},
+		},
+		{
+			in: in{
+				files: map[string]string{
+					"user.ign": `{"ignition":{"version":"2.1.0-experimental","config":{"append":[{"source":"data:,` + url.PathEscape(config("/appended")) + `"}]}}}`,
+				},
+			},
+			out: out{paths: []types.Path{"/appended"}},
+		},
+		{
+			in: in{
+				files: map[string]string{
+					"user.d/10-bad.ign": `{"ignition":`,
+				},
+			},
+			out: out{invalid: true},
+		},
+	}
+
+	logger := log.New()
+	defer logger.Close()
+
+	for i, test := range tests {
+		dir, err := ioutil.TempDir("", "ignition-user")
+		if err != nil {
+			t.Fatalf("failed to create temp dir: %v", err)
+		}
+		defer os.RemoveAll(dir)
+		for name, contents := range test.in.files {
+			path := filepath.Join(dir, name)
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				t.Fatalf("failed to create dir: %v", err)
+			}
+			if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+				t.Fatalf("failed to write %q: %v", name, err)
+			}
+		}
+
+		e := Engine{Logger: &logger, BaseConfigDir: dir, client: resource.NewHttpClient(&logger), ctx: context.Background(), results: &results{}}
+		cfg, err := e.loadUserConfigs()
+		if _, ok := err.(invalidConfigError); ok != test.out.invalid {
+			t.Errorf("#%d: bad error: want invalid %v, got %v", i, test.out.invalid, err)
+		}
+		var paths []types.Path
+		for _, f := range cfg.Storage.Files {
+			paths = append(paths, f.Path)
+		}
+		if !reflect.DeepEqual(test.out.paths, paths) {
+			t.Errorf("#%d: bad files: want %v, got %v", i, test.out.paths, paths)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"unsafe"
+
+	"github.com/coreos/ignition/config/types"
+	"github.com/coreos/ignition/internal/exec/util"
+)
+
+const (
+	// cacheKeyDescription names the key in root's user keyring which seals
+	// the secrets of the config cache. The keyring lasts for the boot and is
+	// shared by every invocation of Ignition, but is never written to disk,
+	// so a copy of the cache is useless without it.
+	cacheKeyDescription = "ignition:config-cache"
+	cacheKeySize        = 32
+
+	keySpecUserKeyring = -4
+	keyctlSearch       = 10
+	keyctlRead         = 11
+
+	tmpfsMagic = 0x01021994
+	ramfsMagic = 0x858458f6
+)
+
+var (
+	errNoCacheKey = errors.New("no config cache key")
+
+	// cacheKey returns the key sealing the config cache, creating it first
+	// if create is set. It returns errNoCacheKey if there is none.
+	cacheKey = keyringCacheKey
+)
+
+// readCache returns the config cached at e.ConfigCache, if there is one
+// which can be unsealed.
+func (e Engine) readCache() (types.Config, bool, error) {
+	b, err := ioutil.ReadFile(e.ConfigCache)
+	if err != nil {
+		return types.Config{}, false, nil
+	}
+	key, err := cacheKey(false)
+	if err != nil {
+		e.Logger.Warning("ignoring cached config: %v", err)
+		return types.Config{}, false, nil
+	}
+	cfg, err := util.UnsealConfig(b, func(s string) (string, error) { return openSecret(key, s) })
+	if err != nil {
+		e.Logger.Crit("failed to parse cached config: %v", err)
+		return types.Config{}, true, invalidConfigError{err}
+	}
+	return cfg, true, nil
+}
+
+// writeCache caches cfg at e.ConfigCache, readable only by root, with its
+// secrets sealed. It is not an error if cfg can't be cached because there is
+// no keyring; the later stages then fetch the config again.
+func (e Engine) writeCache(cfg types.Config) error {
+	key, err := cacheKey(true)
+	if err != nil {
+		e.Logger.Warning("not caching config: %v", err)
+		return nil
+	}
+	b, err := util.SealConfig(cfg, func(s string) (string, error) { return sealSecret(key, s) })
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(e.ConfigCache)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(dir, &st); err == nil && st.Type != tmpfsMagic && st.Type != ramfsMagic {
+		e.Logger.Warning("config cache %q is not on a tmpfs and may outlive the boot", e.ConfigCache)
+	}
+	return util.WriteFileAtomic(e.ConfigCache, b, 0600)
+}
+
+// sealSecret encrypts s with AES-GCM under key, returning the nonce and
+// ciphertext in base64.
+func sealSecret(key []byte, s string) (string, error) {
+	aead, err := newAead(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(aead.Seal(nonce, nonce, []byte(s), nil)), nil
+}
+
+// openSecret decrypts a secret sealed by sealSecret.
+func openSecret(key []byte, s string) (string, error) {
+	aead, err := newAead(key)
+	if err != nil {
+		return "", err
+	}
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return "", err
+	}
+	if len(b) < aead.NonceSize() {
+		return "", errors.New("sealed secret is truncated")
+	}
+	plain, err := aead.Open(nil, b[:aead.NonceSize()], b[aead.NonceSize():], nil)
+	return string(plain), err
+}
+
+func newAead(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// keyringCacheKey looks up the config cache key in root's user keyring,
+// adding a random one if create is set and there is none.
+func keyringCacheKey(create bool) ([]byte, error) {
+	keyType, err := syscall.BytePtrFromString("user")
+	if err != nil {
+		return nil, err
+	}
+	desc, err := syscall.BytePtrFromString(cacheKeyDescription)
+	if err != nil {
+		return nil, err
+	}
+	userKeyring := keySpecUserKeyring
+
+	id, _, errno := syscall.Syscall6(syscall.SYS_KEYCTL, keyctlSearch, uintptr(userKeyring),
+		uintptr(unsafe.Pointer(keyType)), uintptr(unsafe.Pointer(desc)), 0, 0)
+	if errno == syscall.ENOKEY && create {
+		key := make([]byte, cacheKeySize)
+		if _, err := io.ReadFull(rand.Reader, key); err != nil {
+			return nil, err
+		}
+		if _, _, errno := syscall.Syscall6(syscall.SYS_ADD_KEY, uintptr(unsafe.Pointer(keyType)), uintptr(unsafe.Pointer(desc)),
+			uintptr(unsafe.Pointer(&key[0])), uintptr(len(key)), uintptr(userKeyring), 0); errno != 0 {
+			return nil, errno
+		}
+		return key, nil
+	} else if errno == syscall.ENOKEY {
+		return nil, errNoCacheKey
+	} else if errno != 0 {
+		return nil, errno
+	}
+
+	key := make([]byte, cacheKeySize)
+	n, _, errno := syscall.Syscall6(syscall.SYS_KEYCTL, keyctlRead, id,
+		uintptr(unsafe.Pointer(&key[0])), uintptr(len(key)), 0, 0)
+	if errno != 0 {
+		return nil, errno
+	}
+	if n != cacheKeySize {
+		return nil, errNoCacheKey
+	}
+	return key, nil
+}
@@ -0,0 +1,101 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/coreos/ignition/config/types"
+	"github.com/coreos/ignition/internal/log"
+)
+
+// testCacheKey replaces the keyring in tests.
+func testCacheKey(bool) ([]byte, error) {
+	return bytes.Repeat([]byte{0x42}, cacheKeySize), nil
+}
+
+func TestConfigCache(t *testing.T) {
+	type in struct {
+		cfg types.Config
+	}
+	type out struct {
+		hidden []string
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{cfg: types.Config{Passwd: types.Passwd{Users: []types.User{{Name: "core", PasswordHash: "$6$salt$hash"}}}}},
+			out: out{hidden: []string{"$6$salt$hash"}},
+		},
+		{
+			in: in{cfg: types.Config{Storage: types.Storage{Files: []types.File{{
+				Node:     types.Node{Filesystem: "root", Path: "/etc/key"},
+				Contents: types.FileContents{Source: types.Url{Scheme: "data", Opaque: ",luks-passphrase"}},
+			}}}}},
+			out: out{hidden: []string{"luks-passphrase"}},
+		},
+		{
+			in:  in{cfg: types.Config{Storage: types.Storage{Files: []types.File{{Node: types.Node{Filesystem: "root", Path: "/etc/motd"}}}}}},
+			out: out{},
+		},
+	}
+
+	dir, err := ioutil.TempDir("", "ignition-cache")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(f func(bool) ([]byte, error)) { cacheKey = f }(cacheKey)
+	cacheKey = testCacheKey
+
+	logger := log.New()
+	for i, test := range tests {
+		e := Engine{Logger: &logger, ConfigCache: filepath.Join(dir, "run", "config-cache.json")}
+		if err := e.writeCache(test.in.cfg); err != nil {
+			t.Errorf("#%d: failed to write cache: %v", i, err)
+			continue
+		}
+		info, err := os.Stat(e.ConfigCache)
+		if err != nil {
+			t.Errorf("#%d: cache missing: %v", i, err)
+			continue
+		}
+		if info.Mode().Perm() != 0600 {
+			t.Errorf("#%d: bad mode: want %04o, got %04o", i, 0600, info.Mode().Perm())
+		}
+		b, _ := ioutil.ReadFile(e.ConfigCache)
+		for _, secret := range test.out.hidden {
+			if bytes.Contains(b, []byte(secret)) {
+				t.Errorf("#%d: cache contains %q", i, secret)
+			}
+		}
+		cfg, ok, err := e.readCache()
+		if !ok || err != nil {
+			t.Errorf("#%d: failed to read cache: %t, %v", i, ok, err)
+			continue
+		}
+		if !reflect.DeepEqual(test.in.cfg, cfg) {
+			t.Errorf("#%d: bad config: want %+v, got %+v", i, test.in.cfg, cfg)
+		}
+	}
+}
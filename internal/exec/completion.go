@@ -0,0 +1,85 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/coreos/ignition/config"
+	"github.com/coreos/ignition/internal/exec/util"
+	"github.com/coreos/ignition/internal/providers/cmdline"
+	"github.com/coreos/ignition/internal/version"
+)
+
+// DefaultCompletionFile is on the provisioned root, so that it persists
+// across boots.
+const DefaultCompletionFile = "/sysroot/etc/.ignition-complete"
+
+// completed reports whether a previous run wrote e.CompletionFile.
+func (e Engine) completed() bool {
+	if e.CompletionFile == "" {
+		return false
+	}
+	_, err := os.Stat(e.CompletionFile)
+	return err == nil
+}
+
+// completionReadable reports whether e.CompletionFile can be consulted. A
+// marker on the target's root filesystem can't be until the root is mounted,
+// which happens after the disks stage.
+func (e Engine) completionReadable() bool {
+	root := targetRoot(config.Append(baseConfig, e.OemBaseConfig))
+	if root == "" || !strings.HasPrefix(filepath.Clean(e.CompletionFile), filepath.Clean(root)+"/") {
+		return true
+	}
+	return rootMounted(root)
+}
+
+// skipCompleted reports whether a previous run completed provisioning and
+// this one is not forced, in which case the run is skipped. Runs before the
+// root is mounted can't tell, and rely on the disks stage reusing what it
+// created and refusing to format devices which hold anything else.
+func (e Engine) skipCompleted() bool {
+	if e.completed() {
+		if e.Force {
+			return false
+		}
+		e.Logger.Info("provisioning already completed (%q exists), skipping; use -force or %s to run again", e.CompletionFile, cmdline.ForceFlag)
+		return true
+	}
+	if e.CompletionFile != "" && !e.completionReadable() {
+		e.Logger.Info("the root filesystem isn't mounted yet, so whether provisioning already completed can't be checked until a later stage")
+	}
+	return false
+}
+
+// markComplete writes e.CompletionFile, recording when and by which version
+// of Ignition the system was provisioned.
+func (e Engine) markComplete() error {
+	if e.CompletionFile == "" {
+		return nil
+	}
+	return e.Logger.LogOp(func() error {
+		if err := os.MkdirAll(filepath.Dir(e.CompletionFile), 0755); err != nil {
+			return err
+		}
+		contents := fmt.Sprintf("%s\ncompleted %s\n", version.String, time.Now().UTC().Format(time.RFC3339))
+		return util.WriteFileAtomic(e.CompletionFile, []byte(contents), 0644)
+	}, "writing completion marker %q", e.CompletionFile)
+}
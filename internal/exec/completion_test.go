@@ -0,0 +1,97 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/coreos/ignition/config/types"
+	"github.com/coreos/ignition/internal/log"
+)
+
+func TestCompletionFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ignition-completion")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	logger := log.New()
+	e := Engine{Logger: &logger, CompletionFile: filepath.Join(dir, "etc", ".ignition-complete")}
+	if e.completed() {
+		t.Fatalf("completed before the marker was written")
+	}
+	if err := e.markComplete(); err != nil {
+		t.Fatalf("failed to write marker: %v", err)
+	}
+	if !e.completed() {
+		t.Errorf("not completed after the marker was written")
+	}
+
+	e.CompletionFile = ""
+	if e.completed() {
+		t.Errorf("completed with the marker disabled")
+	}
+}
+
+func TestCompletionReadable(t *testing.T) {
+	type in struct {
+		completionFile string
+		mounted        bool
+	}
+	type out struct {
+		readable bool
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{completionFile: "/sysroot/etc/.ignition-complete"},
+			out: out{readable: false},
+		},
+		{
+			in:  in{completionFile: "/sysroot/etc/.ignition-complete", mounted: true},
+			out: out{readable: true},
+		},
+		{
+			in:  in{completionFile: "/var/lib/ignition-complete"},
+			out: out{readable: true},
+		},
+		{
+			in:  in{completionFile: "/sysrootx/.ignition-complete"},
+			out: out{readable: true},
+		},
+	}
+
+	defer func(f func(string) bool) { rootMounted = f }(rootMounted)
+	root := types.Path("/sysroot")
+	logger := log.New()
+	for i, test := range tests {
+		rootMounted = func(string) bool { return test.in.mounted }
+		e := Engine{
+			Logger:         &logger,
+			CompletionFile: test.in.completionFile,
+			OemBaseConfig:  types.Config{Storage: types.Storage{Filesystems: []types.Filesystem{{Name: "root", Path: &root}}}},
+		}
+		if readable := e.completionReadable(); readable != test.out.readable {
+			t.Errorf("#%d: bad readable: want %t, got %t", i, test.out.readable, readable)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/coreos/ignition/config"
+	"github.com/coreos/ignition/config/types"
+	"github.com/coreos/ignition/config/validate/report"
+	"github.com/coreos/ignition/internal/log"
+	"github.com/coreos/ignition/internal/providers"
+	"github.com/coreos/ignition/internal/resource"
+
+	"golang.org/x/net/context"
+)
+
+// DefaultProbeTimeout bounds each provider probe, so that diagnosing every
+// provider doesn't wait out the full online timeout of each.
+const DefaultProbeTimeout = 10 * time.Second
+
+// Probe is a provider to diagnose.
+type Probe struct {
+	Name  string
+	Fetch providers.FuncFetchConfig
+}
+
+// ProbeResult is the outcome of fetching the config from a single provider.
+type ProbeResult struct {
+	Name     string
+	Duration time.Duration
+	Config   types.Config
+	Report   report.Report
+	Err      error
+	TimedOut bool
+}
+
+// String summarizes the result on a single line.
+func (p ProbeResult) String() string {
+	prefix := fmt.Sprintf("%s (%s): ", p.Name, p.Duration.Round(time.Millisecond))
+	switch {
+	case p.TimedOut:
+		return prefix + "no response, gave up waiting"
+	case p.Err == nil:
+		return prefix + "online, " + summarizeConfig(p.Config) + summarizeReport(p.Report)
+	case p.Err == providers.ErrNoProvider:
+		return prefix + "not online"
+	case p.Err == config.ErrEmpty:
+		return prefix + "online, no config provided"
+	case isUserdata(p.Err):
+		return prefix + fmt.Sprintf("online, provided non-Ignition userdata (%v)", p.Err)
+	default:
+		return prefix + fmt.Sprintf("failed: %v", p.Err) + summarizeReport(p.Report)
+	}
+}
+
+// DiagnoseProviders fetches the config from each provider in turn, giving up
+// on any provider that doesn't respond within timeout. A provider's fetch is
+// cancelled when it times out, and waited for before the next is probed, so
+// that no fetch outlives the diagnosis.
+func DiagnoseProviders(logger *log.Logger, timeout time.Duration, probes []Probe) []ProbeResult {
+	results := make([]ProbeResult, 0, len(probes))
+	for _, probe := range probes {
+		client := resource.NewHttpClient(logger)
+		client.SetTimeout(timeout)
+
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		done := make(chan ProbeResult, 1)
+		start := time.Now()
+		go func(probe Probe) {
+			cfg, r, err := probe.Fetch(logger, &client, ctx)
+			done <- ProbeResult{Config: cfg, Report: r, Err: err}
+		}(probe)
+
+		var result ProbeResult
+		select {
+		case result = <-done:
+		case <-ctx.Done():
+			result.TimedOut = true
+		}
+		result.Name = probe.Name
+		result.Duration = time.Since(start)
+		cancel()
+		if result.TimedOut {
+			<-done
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+func summarizeConfig(cfg types.Config) string {
+	v := cfg.Ignition.Version
+	version := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if v.PreRelease != "" {
+		version += "-" + string(v.PreRelease)
+	}
+	return fmt.Sprintf("config version %s with %d files, %d units, and %d users",
+		version, len(cfg.Storage.Files), len(cfg.Systemd.Units), len(cfg.Passwd.Users))
+}
+
+func summarizeReport(r report.Report) string {
+	errors, warnings := 0, 0
+	for _, e := range r.Entries {
+		switch e.Kind {
+		case report.EntryError:
+			errors++
+		case report.EntryWarning:
+			warnings++
+		}
+	}
+	if errors == 0 && warnings == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" (%d errors, %d warnings)", errors, warnings)
+}
@@ -0,0 +1,80 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coreos/ignition/config"
+	"github.com/coreos/ignition/config/types"
+	"github.com/coreos/ignition/config/validate/report"
+	"github.com/coreos/ignition/internal/log"
+	"github.com/coreos/ignition/internal/providers"
+	"github.com/coreos/ignition/internal/resource"
+
+	"golang.org/x/net/context"
+)
+
+func TestDiagnoseProviders(t *testing.T) {
+	fetchConfig := func(*log.Logger, *resource.HttpClient, context.Context) (types.Config, report.Report, error) {
+		return types.Config{
+			Ignition: types.Ignition{Version: types.IgnitionVersion(types.MaxVersion)},
+			Storage:  types.Storage{Files: []types.File{{}}},
+		}, report.Report{}, nil
+	}
+	fetchOffline := func(*log.Logger, *resource.HttpClient, context.Context) (types.Config, report.Report, error) {
+		return types.Config{}, report.Report{}, providers.ErrNoProvider
+	}
+	fetchEmpty := func(*log.Logger, *resource.HttpClient, context.Context) (types.Config, report.Report, error) {
+		return types.Config{}, report.Report{}, config.ErrEmpty
+	}
+	fetchHang := func(_ *log.Logger, _ *resource.HttpClient, ctx context.Context) (types.Config, report.Report, error) {
+		<-ctx.Done()
+		return types.Config{}, report.Report{}, ctx.Err()
+	}
+
+	type out struct {
+		summary string
+	}
+
+	probes := []Probe{
+		{Name: "a", Fetch: fetchConfig},
+		{Name: "b", Fetch: fetchOffline},
+		{Name: "c", Fetch: fetchEmpty},
+		{Name: "d", Fetch: fetchHang},
+	}
+	tests := []out{
+		{summary: "online, config version 2.1.0-experimental with 1 files, 0 units, and 0 users"},
+		{summary: "not online"},
+		{summary: "online, no config provided"},
+		{summary: "no response, gave up waiting"},
+	}
+
+	logger := log.New()
+	results := DiagnoseProviders(&logger, 50*time.Millisecond, probes)
+	if len(results) != len(tests) {
+		t.Fatalf("bad results: want %d, got %d", len(tests), len(results))
+	}
+	for i, test := range tests {
+		if results[i].Name != probes[i].Name {
+			t.Errorf("#%d: bad name: want %q, got %q", i, probes[i].Name, results[i].Name)
+		}
+		if s := results[i].String(); !strings.HasSuffix(s, "): "+test.summary) {
+			t.Errorf("#%d: bad summary: want %q, got %q", i, test.summary, s)
+		}
+	}
+}
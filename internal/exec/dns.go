@@ -0,0 +1,43 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"io/ioutil"
+	"os"
+
+	"github.com/coreos/ignition/config/types"
+	"github.com/coreos/ignition/internal/exec/util"
+)
+
+// DefaultResolvConf is the resolver configuration of the initramfs.
+const DefaultResolvConf = "/etc/resolv.conf"
+
+// configureDns points the resolver at e.ResolvConf to the nameservers and
+// search domains of dns, so that the remaining fetches use them. Nothing is
+// written if e.ResolvConf is empty or dns declares nothing.
+func (e Engine) configureDns(dns types.Dns) error {
+	if e.ResolvConf == "" || (len(dns.Nameservers) == 0 && len(dns.Search) == 0) {
+		return nil
+	}
+
+	existing, err := ioutil.ReadFile(e.ResolvConf)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return e.Logger.LogOp(func() error {
+		return ioutil.WriteFile(e.ResolvConf, util.ResolvConfWithDns(existing, dns), 0644)
+	}, "configuring provisioning resolver in %q", e.ResolvConf)
+}
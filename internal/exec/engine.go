@@ -15,9 +15,13 @@
 package exec
 
 import (
-	"encoding/json"
+	"errors"
+	"fmt"
 	"io/ioutil"
 	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/coreos/ignition/config"
@@ -35,9 +39,21 @@ import (
 
 const (
 	DefaultOnlineTimeout = time.Minute
+	// DefaultMaxReferenceDepth bounds how deeply configs may reference one
+	// another unless Engine.MaxReferenceDepth says otherwise.
+	DefaultMaxReferenceDepth = 10
+	// DefaultConfigCache lives on a tmpfs so the cached config never
+	// outlives the boot in which it was fetched.
+	DefaultConfigCache = "/run/ignition/config-cache.json"
+	// DefaultRenderedConfig is where operators find the config that was
+	// applied during the boot.
+	DefaultRenderedConfig = "/run/ignition.json"
 )
 
 var (
+	ErrConfigCycle    = errors.New("config references itself")
+	ErrReferenceDepth = errors.New("configs are referenced too deeply")
+
 	baseConfig = types.Config{
 		Ignition: types.Ignition{Version: types.IgnitionVersion(types.MaxVersion)},
 		Storage: types.Storage{
@@ -49,22 +65,249 @@ var (
 	}
 )
 
+// Result describes the outcome of Engine.Run.
+type Result int
+
+const (
+	ResultSuccess Result = iota
+	ResultFetchFailed
+	ResultConfigInvalid
+	ResultStageFailed
+)
+
+// invalidConfigError marks errors caused by the content of a config, as
+// opposed to a failure to fetch it.
+type invalidConfigError struct {
+	error
+}
+
 // Engine represents the entity that fetches and executes a configuration.
 type Engine struct {
-	ConfigCache       string
-	Logger            *log.Logger
-	Root              string
-	FetchFunc         providers.FuncFetchConfig
-	OemBaseConfig     types.Config
+	// ConfigCache is where the fetched config is cached between stages,
+	// readable only by root and with its secrets sealed by a key kept in
+	// root's keyring. DefaultConfigCache is used if it is empty.
+	ConfigCache string
+	// NoConfigCache disables the config cache, so that each invocation
+	// fetches the config again.
+	NoConfigCache bool
+	// RenderedConfig is where the fully rendered config is written, with its
+	// secrets redacted, once it is merged. None is written if it is empty.
+	RenderedConfig string
+	// ResolvConf is the resolver configuration updated with the config's
+	// provisioning DNS settings. None is updated if it is empty.
+	ResolvConf string
+	// ResultFile records the provider, the referenced configs, and the
+	// outcome of each stage as they run. It is a path on the target system,
+	// within the config's root filesystem (or Root, if that has no path),
+	// written once the root is mounted. None is written if it is empty.
+	ResultFile string
+	// CompletionFile is written once the last stage succeeds. While it is
+	// present, runs are skipped unless Force is set. No file is consulted or
+	// written if it is empty.
+	CompletionFile string
+	Force          bool
+	// ClientCert and ClientKey locate the client certificate presented to
+	// https servers, as absolute paths or oem URLs.
+	ClientCert string
+	ClientKey  string
+	// Proxy is used for fetches until a config declares its own.
+	Proxy         types.Proxy
+	OnlineTimeout time.Duration
+	Logger        *log.Logger
+	Root          string
+	Platform      string
+	FetchFunc     providers.FuncFetchConfig
+	OemBaseConfig types.Config
+	// BaseConfigDir holds the base.d and base.platform.d directories of
+	// configs merged between OemBaseConfig and the user config, and the
+	// user.ign config and user.d directory merged beneath the provider's
+	// config. None are merged if it is empty.
+	BaseConfigDir     string
 	DefaultUserConfig types.Config
+	// Strict makes configs with keys the spec doesn't define invalid, rather
+	// than only warning about them.
+	Strict bool
+	// LocalConfig, if non-nil, is the raw config to run against. It bypasses
+	// both the config cache and all providers.
+	LocalConfig []byte
+	// StatusReport is notified of the outcome of each stage, unless the
+	// config names its own endpoint. No reports are sent if both are nil.
+	StatusReport *types.StatusReport
+	// NeedNetMarker is written by the fetch-offline stage when the config
+	// cannot be acquired or applied without networking. None is written if
+	// it is empty.
+	NeedNetMarker string
+	// SigningKeysDir holds the OpenPGP public keys trusted to sign
+	// referenced configs, along with those at SigningKeys, which are
+	// absolute paths or oem URLs.
+	SigningKeysDir string
+	SigningKeys    []string
+	// AllowUnsignedConfigs accepts unsigned configs even when signing keys
+	// are trusted. Otherwise, the config named on the kernel command line
+	// must have a detached signature at its URL with ".sig" appended, other
+	// providers' configs may only reference signed configs, and every
+	// referenced config must be signed.
+	AllowUnsignedConfigs bool
+	// UserdataFile is where provider userdata which is a cloud-config or a
+	// script is written, and UserdataHandler, if set, is run on it, so that
+	// another agent can apply it. Nothing is written if UserdataFile is
+	// empty.
+	UserdataFile    string
+	UserdataHandler string
+	// MeasurePcr, if not 0, is the TPM PCR extended with the digest of the
+	// rendered config, once per boot.
+	MeasurePcr int
+	// NetWait lists the conditions the network must meet before the config
+	// is fetched from the provider.
+	NetWait cmdline.NetWait
+	// RequireImdsV2 makes requests to the EC2 instance metadata service fail
+	// when they cannot carry an IMDSv2 session token, rather than fall back
+	// to IMDSv1.
+	RequireImdsV2 bool
+	// MaxConfigSize bounds the size of each fetched config, MaxRedirects the
+	// redirects followed by each request, and MaxReferenceDepth how deeply
+	// configs may reference one another, so that a misbehaving server or
+	// config can't exhaust memory or hang the boot. Zero leaves the default.
+	MaxConfigSize     int64
+	MaxRedirects      int
+	MaxReferenceDepth int
+	// StateDir is where the stages keep state for the later stages of the
+	// boot: the mount record, fetched luks keys, the kargs reboot marker,
+	// the platform metadata, and the record of the measured config.
+	// util.DefaultStateDir is used if it is empty.
+	StateDir string
+	// ProviderName, if set, names the provider which returned the config,
+	// for a FetchFunc trying several. Otherwise the provider is recorded as
+	// Platform.
+	ProviderName func() string
+	// ReportReady, if set, tells the platform that the machine has been
+	// provisioned, once the last stage succeeds.
+	ReportReady providers.FuncReportReady
+
+	client  resource.HttpClient
+	results *results
+	// targetRoot is where the config's root filesystem is, once the config
+	// has been rendered.
+	targetRoot string
+	// ctx cancels the fetches and stages of the run being rendered.
+	ctx context.Context
+	// references are the URLs of the referenced configs being rendered,
+	// outermost first.
+	references []string
+	// onNeedNet, if set, makes the client refuse requests needing the
+	// network, and is called on the first one.
+	onNeedNet func()
+}
+
+// Run executes the stage of the given name, or every stage in order if the
+// name is stages.All. It returns ResultSuccess if the stages successfully ran
+// and otherwise classifies the failure. Cancelling ctx aborts fetches in flight
+// and stops the stages before their next step.
+func (e Engine) Run(ctx context.Context, stageName string) Result {
+	switch stageName {
+	case stages.FetchOffline:
+		if e.skipCompleted() {
+			return ResultSuccess
+		}
+		return e.FetchOffline(ctx)
+	case stages.Fetch:
+		return e.RunStages(ctx, nil)
+	case stages.All:
+		return e.RunStages(ctx, stages.Ordered())
+	}
+	return e.RunStages(ctx, []string{stageName})
+}
+
+// RunStages acquires the config once and executes the named stages against
+// it in the given order, or only acquires it if names is empty. It returns
+// the result as Run does.
+func (e Engine) RunStages(ctx context.Context, names []string) Result {
+	if e.skipCompleted() {
+		return ResultSuccess
+	}
+
+	cfg, result := e.Render(ctx)
+	defer e.client.ReleaseCache()
+	e.targetRoot = targetRoot(cfg)
+	if result != ResultSuccess {
+		e.reportStatus(cfg, stages.Fetch, fetchError(result))
+		return result
+	}
+	if err := e.measureConfig(cfg); err != nil {
+		e.Logger.Crit("failed to measure config into PCR %d: %v", e.MeasurePcr, err)
+		e.reportStatus(cfg, stages.Fetch, err)
+		return ResultFetchFailed
+	}
+	if len(names) == 0 {
+		e.reportStatus(cfg, stages.Fetch, nil)
+		return result
+	}
 
-	client resource.HttpClient
+	// Stages may run in separate invocations, so provisioning is complete
+	// once the last of them succeeds.
+	ordered := stages.Ordered()
+	completes := len(ordered) != 0 && names[len(names)-1] == ordered[len(ordered)-1]
+	for i, name := range names {
+		if err := e.runStage(name, cfg); err != nil {
+			// A failed run doesn't reach the files stage, which would
+			// otherwise discard the luks keys the disks stage fetched.
+			if err := e.stateUtil().ClearLuksKeyCache(); err != nil {
+				e.Logger.Warning("failed to remove fetched luks keys: %v", err)
+			}
+			e.reportStatus(cfg, name, err)
+			return ResultStageFailed
+		}
+		if completes && i == len(names)-1 {
+			if err := e.markComplete(); err != nil {
+				e.Logger.Crit("failed to write completion marker: %v", err)
+				e.reportStatus(cfg, name, err)
+				return ResultStageFailed
+			}
+			e.results.Complete = true
+			e.saveResults()
+			if e.ReportReady != nil {
+				if err := e.ReportReady(e.Logger, &e.client, ctx); err != nil {
+					e.Logger.Warning("failed to report ready to the platform: %v", err)
+				}
+			}
+		}
+		e.reportStatus(cfg, name, nil)
+		if err := ctx.Err(); err != nil {
+			e.Logger.Crit("cancelled after stage %q: %v", name, err)
+			return ResultStageFailed
+		}
+	}
+	return ResultSuccess
 }
 
-// Run executes the stage of the given name. It returns true if the stage
-// successfully ran and false if there were any errors.
-func (e Engine) Run(stageName string) bool {
+// Render acquires the config, resolves its replace and append references,
+// and merges it with the base configs, returning the config the stages would
+// run against. Cancelling ctx aborts fetches in flight.
+func (e *Engine) Render(ctx context.Context) (types.Config, Result) {
+	e.ctx = ctx
+	e.loadResults()
 	e.client = resource.NewHttpClient(e.Logger)
+	e.client.SetTimeout(e.OnlineTimeout)
+	if e.onNeedNet != nil {
+		e.client.SetOffline(e.onNeedNet)
+	}
+	if e.RequireImdsV2 {
+		e.client.RequireImdsV2()
+	}
+	if e.MaxConfigSize != 0 {
+		e.client.SetMaxConfigSize(e.MaxConfigSize)
+	}
+	if e.MaxRedirects != 0 {
+		e.client.SetMaxRedirects(e.MaxRedirects)
+	}
+	if err := e.loadClientCertificate(); err != nil {
+		e.Logger.Crit("%v", err)
+		return types.Config{}, ResultFetchFailed
+	}
+	if err := e.configureProxy(e.Proxy); err != nil {
+		e.Logger.Crit("failed to configure provisioning proxy: %v", err)
+		return types.Config{}, ResultFetchFailed
+	}
 
 	cfg, err := e.acquireConfig()
 	switch err {
@@ -73,48 +316,160 @@ func (e Engine) Run(stageName string) bool {
 		e.Logger.Info("%v: ignoring user-provided config", err)
 		cfg = e.DefaultUserConfig
 	default:
+		if e.client.NeedsNetwork() {
+			e.Logger.Info("config cannot be acquired without networking: %v", err)
+			return types.Config{}, ResultFetchFailed
+		}
 		e.Logger.Crit("failed to acquire config: %v", err)
-		return false
+		if _, ok := err.(invalidConfigError); ok {
+			return types.Config{}, ResultConfigInvalid
+		}
+		return types.Config{}, ResultFetchFailed
 	}
 
-	e.Logger.PushPrefix(stageName)
+	base, err := e.loadBaseConfigs()
+	if err != nil {
+		e.Logger.Crit("failed to load base configs: %v", err)
+		if _, ok := err.(invalidConfigError); ok {
+			return types.Config{}, ResultConfigInvalid
+		}
+		return types.Config{}, ResultFetchFailed
+	}
+
+	defaults := config.Append(baseConfig, config.Append(e.OemBaseConfig, base))
+	cfg = config.Append(defaults, cfg)
+	cfg.Storage = cfg.Storage.WithBootDevice()
+	util.ExpectFetches(&e.client, cfg)
+	e.client.SetAuth(cfg.Ignition.Auth)
+	e.client.SetTimeouts(cfg.Ignition.Timeouts)
+	if err := e.configureDns(cfg.Ignition.Dns); err != nil {
+		e.Logger.Crit("failed to configure provisioning resolver: %v", err)
+		return types.Config{}, ResultFetchFailed
+	}
+	if err := e.configureProxy(cfg.Ignition.Proxy); err != nil {
+		e.Logger.Crit("failed to configure provisioning proxy: %v", err)
+		return types.Config{}, ResultFetchFailed
+	}
+	if err := e.trustCertificateAuthorities(cfg.Ignition.Security.Tls.CertificateAuthorities); err != nil {
+		e.Logger.Crit("%v", err)
+		return types.Config{}, ResultFetchFailed
+	}
+	e.Logger.Debug("rendered config:\n%s", util.DumpConfig(cfg, defaults))
+	if err := e.writeRenderedConfig(cfg); err != nil {
+		e.Logger.Warning("failed to write rendered config: %v", err)
+	}
+	return cfg, ResultSuccess
+}
+
+// writeRenderedConfig writes cfg, redacted, to e.RenderedConfig for debugging.
+func (e Engine) writeRenderedConfig(cfg types.Config) error {
+	if e.RenderedConfig == "" {
+		return nil
+	}
+	b, err := util.RedactConfig(cfg)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(e.RenderedConfig), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(e.RenderedConfig, b, 0640)
+}
+
+// runStage executes the named stage against the fully-assembled config,
+// returning why it failed, if it did.
+func (e *Engine) runStage(stageName string, cfg types.Config) error {
+	e.Logger.PushPrefix("%s", stageName)
 	defer e.Logger.PopPrefix()
-	return stages.Get(stageName).Create(e.Logger, &e.client, e.Root).Run(config.Append(baseConfig, config.Append(e.OemBaseConfig, cfg)))
+	start := time.Now()
+	err := stages.Get(stageName).Create(e.Logger, &e.client, e.ctx, e.Root, e.StateDir, e.ResultFile, e.Platform).Run(cfg)
+	duration := time.Since(start)
+	if err != nil {
+		e.Logger.Crit("%v", err)
+	}
+	e.Logger.Debug("stage took %v", duration)
+	e.recordStage(stageName, err, duration)
+	return err
+}
+
+// stateUtil returns a Util for the state the stages keep in e.StateDir.
+func (e Engine) stateUtil() util.Util {
+	return util.Util{StateDir: e.StateDir, Logger: e.Logger}
 }
 
 // acquireConfig returns the configuration, first checking a local cache
-// before attempting to fetch it from the provider.
+// before attempting to fetch it from the provider. A LocalConfig takes
+// precedence over both. Userdata which isn't an Ignition config is replaced
+// by e.DefaultUserConfig before it is cached.
 func (e *Engine) acquireConfig() (cfg types.Config, err error) {
+	if e.LocalConfig != nil {
+		e.resetResults("local")
+		if cfg, err = e.parseLocalConfig(); err != nil {
+			return
+		}
+		return e.withUserConfigs(cfg)
+	}
+
+	if e.ConfigCache == "" {
+		e.ConfigCache = DefaultConfigCache
+	}
+
 	// First try read the config @ e.ConfigCache.
-	b, err := ioutil.ReadFile(e.ConfigCache)
-	if err == nil {
-		if err = json.Unmarshal(b, &cfg); err != nil {
-			e.Logger.Crit("failed to parse cached config: %v", err)
+	if !e.NoConfigCache {
+		if cfg, ok, err := e.readCache(); ok {
+			return cfg, err
 		}
-		return
 	}
 
 	// (Re)Fetch the config if the cache is unreadable.
+	e.resetResults("")
+	if e.onNeedNet == nil {
+		e.waitForNetwork()
+	}
+	start := time.Now()
 	cfg, err = e.fetchProviderConfig()
-	if err != nil {
-		e.Logger.Crit("failed to fetch config: %s", err)
-		return
+	e.recordFetch(time.Since(start))
+	if u, ok := err.(providers.UserdataError); ok {
+		if err = e.handOffUserdata(u); err != nil {
+			return types.Config{}, fmt.Errorf("failed to hand off userdata: %v", err)
+		}
+		err = u.Err
 	}
-
-	// Populate the config cache.
-	b, err = json.Marshal(cfg)
-	if err != nil {
-		e.Logger.Crit("failed to marshal cached config: %v", err)
+	switch err {
+	case nil:
+	case config.ErrCloudConfig, config.ErrScript, config.ErrEmpty:
+		// The default is cached too, so that later stages don't fetch the
+		// userdata again only to ignore it.
+		e.Logger.Info("%v: ignoring user-provided config", err)
+		cfg, err = e.DefaultUserConfig, nil
+	default:
+		if !e.client.NeedsNetwork() {
+			e.Logger.Crit("failed to fetch config: %s", err)
+		}
 		return
 	}
-	if err = ioutil.WriteFile(e.ConfigCache, b, 0640); err != nil {
-		e.Logger.Crit("failed to write cached config: %v", err)
+	if cfg, err = e.withUserConfigs(cfg); err != nil {
 		return
 	}
 
+	// Populate the config cache.
+	if !e.NoConfigCache {
+		if err = e.writeCache(cfg); err != nil {
+			e.Logger.Crit("failed to write cached config: %v", err)
+		}
+	}
 	return
 }
 
+// withUserConfigs merges the user configs shipped in the image beneath cfg.
+func (e *Engine) withUserConfigs(cfg types.Config) (types.Config, error) {
+	user, err := e.loadUserConfigs()
+	if err != nil {
+		return types.Config{}, err
+	}
+	return config.Append(user, cfg), nil
+}
+
 // fetchProviderConfig returns the externally-provided configuration. It first
 // checks to see if the command-line option is present. If so, it uses that
 // source for the configuration. If the command-line option is not present, it
@@ -122,13 +477,43 @@ func (e *Engine) acquireConfig() (cfg types.Config, err error) {
 // unavailable. This will also render the config (see renderConfig) before
 // returning.
 func (e Engine) fetchProviderConfig() (types.Config, error) {
-	cfg, r, err := cmdline.FetchConfig(e.Logger, &e.client)
+	required, err := e.requireSignatures()
+	if err != nil {
+		return types.Config{}, err
+	}
+	var verify func(url.URL, []byte) error
+	if required {
+		verify = e.verifyProviderConfig
+	}
+
+	e.results.Provider = "cmdline"
+	cfg, r, err := cmdline.FetchVerifiedConfig(e.Logger, &e.client, e.ctx, verify)
+	signed := required
 	if err == providers.ErrNoProvider {
-		cfg, r, err = e.FetchFunc(e.Logger, &e.client)
+		cfg, r, err = e.FetchFunc(e.Logger, &e.client, e.ctx)
+		signed = false
+		e.results.Provider = e.Platform
+		if e.ProviderName != nil {
+			e.results.Provider = e.ProviderName()
+		}
 	}
 
-	e.logReport(r)
-	if err != nil {
+	if err := e.checkReport(r, err); err != nil {
+		return types.Config{}, err
+	}
+	if required && !signed {
+		if err := checkUnsigned(cfg); err != nil {
+			return types.Config{}, invalidConfigError{fmt.Errorf("config from %s: %v", e.results.Provider, err)}
+		}
+	}
+
+	return e.renderConfig(cfg)
+}
+
+// parseLocalConfig parses and renders the engine's LocalConfig.
+func (e Engine) parseLocalConfig() (types.Config, error) {
+	cfg, r, err := config.Parse(e.LocalConfig)
+	if err := e.checkReport(r, err); err != nil {
 		return types.Config{}, err
 	}
 
@@ -142,8 +527,23 @@ func (e Engine) fetchProviderConfig() (types.Config, error) {
 // evaluated and appended to the provided config. If neither option is set, the
 // provided config will be returned unmodified.
 func (e *Engine) renderConfig(cfg types.Config) (types.Config, error) {
+	// Credentials, timeouts, resolvers, proxies, and CAs declared by a config
+	// apply to the configs it references.
+	e.client.AddAuth(cfg.Ignition.Auth)
+	e.client.SetTimeouts(cfg.Ignition.Timeouts)
+	if err := e.configureDns(cfg.Ignition.Dns); err != nil {
+		return types.Config{}, err
+	}
+	if err := e.configureProxy(cfg.Ignition.Proxy); err != nil {
+		return types.Config{}, err
+	}
+	if err := e.trustCertificateAuthorities(cfg.Ignition.Security.Tls.CertificateAuthorities); err != nil {
+		return types.Config{}, err
+	}
+
 	if cfgRef := cfg.Ignition.Config.Replace; cfgRef != nil {
-		return e.fetchReferencedConfig(*cfgRef)
+		newCfg, err := e.fetchReferencedConfig(*cfgRef)
+		return inheritProxy(newCfg, cfg.Ignition.Proxy), err
 	}
 
 	appendedCfg := cfg
@@ -153,34 +553,95 @@ func (e *Engine) renderConfig(cfg types.Config) (types.Config, error) {
 			return newCfg, err
 		}
 
-		appendedCfg = config.Append(appendedCfg, newCfg)
+		appendedCfg = config.Append(appendedCfg, inheritProxy(newCfg, appendedCfg.Ignition.Proxy))
 	}
 	return appendedCfg, nil
 }
 
 // fetchReferencedConfig fetches, renders, and attempts to verify the requested
-// config.
+// config. A config which references itself, directly or through other
+// configs, is invalid.
 func (e Engine) fetchReferencedConfig(cfgRef types.ConfigReference) (types.Config, error) {
-	rawCfg, err := resource.Fetch(e.Logger, &e.client, context.Background(), url.URL(cfgRef.Source))
-	if err != nil {
-		return types.Config{}, err
+	source := cfgRef.Source.String()
+	chain := append(append([]string{}, e.references...), source)
+	for _, ref := range e.references {
+		if ref == source {
+			return types.Config{}, invalidConfigError{fmt.Errorf("%v: %s", ErrConfigCycle, strings.Join(chain, " -> "))}
+		}
+	}
+	maxDepth := e.MaxReferenceDepth
+	if maxDepth == 0 {
+		maxDepth = DefaultMaxReferenceDepth
+	}
+	if len(e.references) >= maxDepth {
+		return types.Config{}, invalidConfigError{fmt.Errorf("%v: %s", ErrReferenceDepth, strings.Join(chain, " -> "))}
 	}
+	e.references = chain
 
-	if err := util.AssertValid(cfgRef.Verification, rawCfg); err != nil {
+	if cfgRef.Signature == nil {
+		required, err := e.requireSignatures()
+		if err != nil {
+			return types.Config{}, err
+		}
+		if required {
+			return types.Config{}, invalidConfigError{fmt.Errorf("%v: %s", ErrUnsignedConfig, source)}
+		}
+	}
+
+	e.results.References = append(e.results.References, resultUrl(source))
+	fetcher := resource.Fetcher{Logger: e.Logger, Client: &e.client, Context: e.ctx}
+	rawCfg, err := fetcher.Fetch(url.URL(cfgRef.Source), resource.FetchOptions{
+		Headers:      cfgRef.HttpHeaders.Header(),
+		Verification: cfgRef.Verification,
+		Compression:  cfgRef.Compression,
+		MaxSize:      e.client.MaxConfigSize(),
+	})
+	if err != nil {
 		return types.Config{}, err
 	}
+	if cfgRef.Signature != nil {
+		if err := e.verifySignature(rawCfg, *cfgRef.Signature); err != nil {
+			return types.Config{}, fmt.Errorf("failed to verify %q: %v", source, err)
+		}
+		e.Logger.Info("verified signature of %q", source)
+	}
 
 	cfg, r, err := config.Parse(rawCfg)
-	e.logReport(r)
-	if err != nil {
+	if err := e.checkReport(r, err); err != nil {
 		return types.Config{}, err
 	}
 
 	return e.renderConfig(cfg)
 }
 
+// classify marks err as an invalidConfigError if the report that accompanied
+// it shows the config itself was at fault.
+func classify(err error, r report.Report) error {
+	if r.IsFatal() {
+		return invalidConfigError{err}
+	}
+	return err
+}
+
+// checkReport logs the report of parsing a config and returns the error the
+// parse should fail with, given err, that it failed with if any. In strict
+// mode, unrecognized keys make the config invalid too.
+func (e Engine) checkReport(r report.Report, err error) error {
+	err = config.CheckReport(&r, err, e.Strict)
+	e.logReport(r)
+	if err != nil {
+		return classify(err, r)
+	}
+	return nil
+}
+
+// logReport logs the entries of r, without quoting the source of any secret
+// they concern.
 func (e Engine) logReport(r report.Report) {
 	for _, entry := range r.Entries {
+		if util.SensitivePath(entry.Path) {
+			entry.Highlight = ""
+		}
 		switch entry.Kind {
 		case report.EntryError:
 			e.Logger.Crit("%v", entry)
@@ -0,0 +1,124 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/coreos/ignition/internal/log"
+
+	"golang.org/x/net/context"
+)
+
+func TestRenderReferences(t *testing.T) {
+	configs := map[string]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		config, ok := configs[r.URL.Path]
+		if r.URL.Path == "/private" && r.Header.Get("X-Token") != "secret" {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, strings.Replace(config, "SERVER", "http://"+r.Host, -1))
+	}))
+	defer server.Close()
+
+	const header = `{"ignition":{"version":"2.1.0-experimental"`
+	configs["/a"] = header + `,"config":{"append":[{"source":"SERVER/b"},{"source":"SERVER/c"}]}},"storage":{"files":[{"filesystem":"root","path":"/a"}]}}`
+	configs["/b"] = header + `,"config":{"append":[{"source":"SERVER/d"}]}},"storage":{"files":[{"filesystem":"root","path":"/b"}]}}`
+	configs["/c"] = header + `},"storage":{"files":[{"filesystem":"root","path":"/c"}]}}`
+	configs["/d"] = header + `},"storage":{"files":[{"filesystem":"root","path":"/d"}]}}`
+	configs["/loop"] = header + `,"config":{"append":[{"source":"SERVER/loop2"}]}}}`
+	configs["/loop2"] = header + `,"config":{"replace":{"source":"SERVER/loop"}}}}`
+	configs["/self"] = header + `,"config":{"append":[{"source":"SERVER/self"}]}}}`
+	configs["/private"] = header + `},"storage":{"files":[{"filesystem":"root","path":"/private"}]}}`
+
+	type in struct {
+		config string
+	}
+	type out struct {
+		result Result
+		files  []string
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{config: header + `,"config":{"replace":{"source":"SERVER/a"}}}}`},
+			out: out{result: ResultSuccess, files: []string{"/a", "/b", "/d", "/c"}},
+		},
+		{
+			in:  in{config: header + `,"config":{"append":[{"source":"SERVER/c"},{"source":"SERVER/c"}]}}}`},
+			out: out{result: ResultSuccess, files: []string{"/c", "/c"}},
+		},
+		{
+			in:  in{config: header + `,"config":{"append":[{"source":"SERVER/loop"}]}}}`},
+			out: out{result: ResultConfigInvalid},
+		},
+		{
+			in:  in{config: header + `,"config":{"append":[{"source":"SERVER/self"}]}}}`},
+			out: out{result: ResultConfigInvalid},
+		},
+		{
+			in:  in{config: header + `,"config":{"append":[{"source":"SERVER/private","httpHeaders":[{"name":"X-Token","value":"secret"}]}]}}}`},
+			out: out{result: ResultSuccess, files: []string{"/private"}},
+		},
+		{
+			in:  in{config: header + `,"config":{"append":[{"source":"SERVER/private"}]}}}`},
+			out: out{result: ResultFetchFailed},
+		},
+		{
+			in:  in{config: header + `,"config":{"append":[{"source":"data:;base64,eyJpZ25pdGlvbiI6eyJ2ZXJzaW9uIjoiMi4xLjAtZXhwZXJpbWVudGFsIn0sInN0b3JhZ2UiOnsiZmlsZXMiOlt7ImZpbGVzeXN0ZW0iOiJyb290IiwicGF0aCI6Ii9pbmxpbmUifV19fQ=="},{"source":"SERVER/c"}]}}}`},
+			out: out{result: ResultSuccess, files: []string{"/inline", "/c"}},
+		},
+		{
+			in:  in{config: header + `,"config":{"append":[{"source":"data:;base64,H4sIAAAAAAAC/y2LQQrCQBAE/9LnmKjHfEU8LNrGgezOMjOESNi/J4q3KqjaIFOREC0YNyw0/yGu/aU/n7hWmmSWSDNaBw+1NPGbvmSmY7z96ePBfHymGuhQU7wPGx6aq9GdT7R7azuyAvbXbgAAAA==","compression":"gzip","verification":{"hash":"sha256-fd94427d00d18da82ce15b12abc8cd53798577a2566b9d283e0ccbb20918e5d4"}}]}}}`},
+			out: out{result: ResultSuccess, files: []string{"/compressed"}},
+		},
+		{
+			in:  in{config: header + `,"config":{"append":[{"source":"data:;base64,H4sIAAAAAAAC/y2LQQrCQBAE/9LnmKjHfEU8LNrGgezOMjOESNi/J4q3KqjaIFOREC0YNyw0/yGu/aU/n7hWmmSWSDNaBw+1NPGbvmSmY7z96ePBfHymGuhQU7wPGx6aq9GdT7R7azuyAvbXbgAAAA==","compression":"gzip","verification":{"hash":"sha256-4447f0c0ce7d559c35072beab13126b46e877effe3db7086736cb564750fd30f"}}]}}}`},
+			out: out{result: ResultFetchFailed},
+		},
+	}
+
+	logger := log.New()
+	for i, test := range tests {
+		e := Engine{
+			Logger:      &logger,
+			LocalConfig: []byte(strings.Replace(test.in.config, "SERVER", server.URL, -1)),
+		}
+		cfg, result := e.Render(context.Background())
+		if result != test.out.result {
+			t.Errorf("#%d: bad result: want %v, got %v", i, test.out.result, result)
+			continue
+		}
+		files := []string{}
+		for _, f := range cfg.Storage.Files {
+			files = append(files, string(f.Path))
+		}
+		if result == ResultSuccess && fmt.Sprint(files) != fmt.Sprint(test.out.files) {
+			t.Errorf("#%d: bad files: want %v, got %v", i, test.out.files, files)
+		}
+	}
+}
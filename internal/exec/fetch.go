@@ -0,0 +1,90 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/coreos/ignition/internal/exec/util"
+
+	"golang.org/x/net/context"
+)
+
+// DefaultNeedNetMarker is on a tmpfs, where the initramfs looks for it before
+// deciding whether to bring up networking for the later stages.
+const DefaultNeedNetMarker = "/run/ignition/neednet"
+
+// FetchOffline acquires and caches the config as Render does, but only from
+// sources which don't need the network: the config cache, a local config, the
+// kernel command line, and providers reading local devices. Requests which
+// would use the network fail instead. If the config cannot be acquired
+// without them, or applying it fetches remote resources, e.NeedNetMarker is
+// written so that the initramfs brings up networking before the remaining
+// stages, which acquire the config again if it wasn't cached. Needing the
+// network is not a failure.
+func (e *Engine) FetchOffline(ctx context.Context) Result {
+	if err := e.clearNeedNet(); err != nil {
+		e.Logger.Crit("failed to remove %q: %v", e.NeedNetMarker, err)
+		return ResultFetchFailed
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	e.onNeedNet = cancel
+	defer func() { e.onNeedNet = nil }()
+
+	cfg, result := e.Render(ctx)
+	if e.client.NeedsNetwork() {
+		return e.markNeedNet()
+	}
+	if result != ResultSuccess {
+		return result
+	}
+	if sources := util.RemoteSources(cfg); len(sources) != 0 {
+		e.Logger.Info("applying the config fetches remote resources: %s", strings.Join(sources, ", "))
+		return e.markNeedNet()
+	}
+	e.Logger.Info("the config was acquired and can be applied without networking")
+	return ResultSuccess
+}
+
+// markNeedNet writes e.NeedNetMarker.
+func (e Engine) markNeedNet() Result {
+	if e.NeedNetMarker == "" {
+		return ResultSuccess
+	}
+	if err := e.Logger.LogOp(func() error {
+		if err := os.MkdirAll(filepath.Dir(e.NeedNetMarker), 0755); err != nil {
+			return err
+		}
+		return util.WriteFileAtomic(e.NeedNetMarker, nil, 0644)
+	}, "writing networking marker %q", e.NeedNetMarker); err != nil {
+		return ResultFetchFailed
+	}
+	return ResultSuccess
+}
+
+// clearNeedNet removes the e.NeedNetMarker left by an earlier run, if any.
+func (e Engine) clearNeedNet() error {
+	if e.NeedNetMarker == "" {
+		return nil
+	}
+	if err := os.Remove(e.NeedNetMarker); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/coreos/ignition/config"
+	"github.com/coreos/ignition/config/types"
+	"github.com/coreos/ignition/config/validate/report"
+	"github.com/coreos/ignition/internal/exec/stages"
+	"github.com/coreos/ignition/internal/log"
+	"github.com/coreos/ignition/internal/resource"
+
+	"golang.org/x/net/context"
+)
+
+func TestFetchStage(t *testing.T) {
+	type in struct {
+		err error
+	}
+	type out struct {
+		files []string
+	}
+
+	userConfig := types.Config{Storage: types.Storage{Files: []types.File{{Node: types.Node{Filesystem: "root", Path: "/user"}}}}}
+	defaultConfig := types.Config{Storage: types.Storage{Files: []types.File{{Node: types.Node{Filesystem: "root", Path: "/default"}}}}}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{},
+			out: out{files: []string{"/user"}},
+		},
+		{
+			in:  in{err: config.ErrCloudConfig},
+			out: out{files: []string{"/default"}},
+		},
+	}
+
+	dir, err := ioutil.TempDir("", "ignition-fetch")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(f func(bool) ([]byte, error)) { cacheKey = f }(cacheKey)
+	cacheKey = testCacheKey
+
+	logger := log.New()
+	for i, test := range tests {
+		fetches := 0
+		e := Engine{
+			Logger:            &logger,
+			ConfigCache:       filepath.Join(dir, fmt.Sprintf("cache-%d.json", i)),
+			DefaultUserConfig: defaultConfig,
+			FetchFunc: func(*log.Logger, *resource.HttpClient, context.Context) (types.Config, report.Report, error) {
+				fetches++
+				return userConfig, report.Report{}, test.in.err
+			},
+		}
+		if result := e.Run(context.Background(), stages.Fetch); result != ResultSuccess {
+			t.Errorf("#%d: bad result: want %v, got %v", i, ResultSuccess, result)
+			continue
+		}
+		cfg, result := e.Render(context.Background())
+		if result != ResultSuccess {
+			t.Errorf("#%d: bad result: want %v, got %v", i, ResultSuccess, result)
+			continue
+		}
+		if fetches != 1 {
+			t.Errorf("#%d: bad fetch count: want 1, got %d", i, fetches)
+		}
+		files := []string{}
+		for _, f := range cfg.Storage.Files {
+			files = append(files, string(f.Path))
+		}
+		if fmt.Sprint(files) != fmt.Sprint(test.out.files) {
+			t.Errorf("#%d: bad files: want %v, got %v", i, test.out.files, files)
+		}
+	}
+}
+
+func TestFetchOffline(t *testing.T) {
+	type in struct {
+		config string
+	}
+	type out struct {
+		result  Result
+		needNet bool
+	}
+
+	const header = `{"ignition":{"version":"2.1.0-experimental"`
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{config: header + `},"storage":{"files":[{"filesystem":"root","path":"/a","contents":{"source":"data:,a"}}]}}`},
+			out: out{result: ResultSuccess},
+		},
+		{
+			in:  in{config: header + `,"config":{"append":[{"source":"http://127.0.0.1:1/b"}]}}}`},
+			out: out{result: ResultSuccess, needNet: true},
+		},
+		{
+			in:  in{config: header + `},"storage":{"files":[{"filesystem":"root","path":"/c","contents":{"source":"https://example.com/c"}}]}}`},
+			out: out{result: ResultSuccess, needNet: true},
+		},
+		{
+			in:  in{config: header + `},"storage":{"luks":[{"name":"data","device":"/dev/vdb","clevis":{"tang":[{"url":"http://tang.example.com"}]}}]}}`},
+			out: out{result: ResultSuccess, needNet: true},
+		},
+		{
+			in:  in{config: `{`},
+			out: out{result: ResultConfigInvalid},
+		},
+	}
+
+	dir, err := ioutil.TempDir("", "ignition-fetch-offline")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	marker := filepath.Join(dir, "run", "neednet")
+
+	logger := log.New()
+	for i, test := range tests {
+		e := Engine{
+			Logger:        &logger,
+			LocalConfig:   []byte(test.in.config),
+			NeedNetMarker: marker,
+		}
+		if result := e.FetchOffline(context.Background()); result != test.out.result {
+			t.Errorf("#%d: bad result: want %v, got %v", i, test.out.result, result)
+		}
+		if _, err := os.Stat(marker); (err == nil) != test.out.needNet {
+			t.Errorf("#%d: bad marker: want %t, got %t", i, test.out.needNet, err == nil)
+		}
+	}
+}
@@ -0,0 +1,337 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/coreos/ignition/config/types"
+	"github.com/coreos/ignition/internal/exec/stages"
+	"github.com/coreos/ignition/internal/exec/util"
+)
+
+// liveStage is the only stage which may be applied to a running system.
+// liveUnmountStage then detaches whatever it mounted.
+const (
+	liveStage        = "files"
+	liveUnmountStage = "umount"
+)
+
+var (
+	ErrLiveStorage      = errors.New("live apply cannot partition, format, or write images to devices")
+	ErrLiveFilesystem   = errors.New("live apply can only write files to the root filesystem")
+	ErrLiveFirstBoot    = errors.New("live apply cannot install trust anchors, certificate authorities, or post-provisioning hooks, which only take effect on first boot")
+	ErrLiveMachineId    = errors.New("live apply cannot change the machine-id of a running system")
+	ErrLiveBootEntries  = errors.New("live apply cannot modify EFI boot entries")
+	ErrLiveTokenInvalid = errors.New("the live apply plan has changed since it was reviewed")
+	ErrLiveUnverified   = errors.New("live apply requires a verification hash for every remote source, so that the reviewed contents are the ones applied")
+)
+
+var (
+	verificationType = reflect.TypeOf(types.Verification{})
+	urlType          = reflect.TypeOf(types.Url{})
+)
+
+// CheckLive returns an error describing the first part of cfg which cannot
+// be safely applied to a running system, or nil if there is none.
+func CheckLive(cfg types.Config) error {
+	s := cfg.Storage
+	if len(s.Zfcp) != 0 || len(s.Disks) != 0 || s.GrowRoot != nil || len(s.Arrays) != 0 || len(s.Luks) != 0 || len(s.Images) != 0 {
+		return ErrLiveStorage
+	}
+	for _, fs := range s.Filesystems {
+		if fs.Mount != nil && fs.Mount.Create != nil {
+			return ErrLiveStorage
+		}
+	}
+	for _, f := range s.Files {
+		if f.Filesystem != "root" {
+			return ErrLiveFilesystem
+		}
+	}
+	for _, d := range s.Directories {
+		if d.Filesystem != "root" {
+			return ErrLiveFilesystem
+		}
+	}
+	for _, l := range s.Links {
+		if l.Filesystem != "root" {
+			return ErrLiveFilesystem
+		}
+	}
+	for _, sf := range s.SwapFiles {
+		if sf.Filesystem != "root" {
+			return ErrLiveFilesystem
+		}
+	}
+	if len(cfg.System.TrustAnchors) != 0 || len(cfg.Ignition.Security.Tls.CertificateAuthorities) != 0 || cfg.System.PostProvision != nil {
+		return ErrLiveFirstBoot
+	}
+	if m := cfg.System.MachineId; m != nil && m.Mode != types.MachineIdPreserve {
+		return ErrLiveMachineId
+	}
+	if len(cfg.Bootloader.Efi.Entries) != 0 || len(cfg.Bootloader.Efi.BootOrder) != 0 {
+		return ErrLiveBootEntries
+	}
+	// Remote contents are fetched once to plan and again to apply. Only a
+	// hash guarantees that both fetches return the same bytes.
+	cfg.Ignition = types.Ignition{}
+	if hasUnverifiedSource(reflect.ValueOf(cfg)) {
+		return ErrLiveUnverified
+	}
+	return nil
+}
+
+// hasUnverifiedSource reports whether any struct in v with a Source and a
+// Verification names a remote source without a hash.
+func hasUnverifiedSource(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Ptr:
+		return !v.IsNil() && hasUnverifiedSource(v.Elem())
+	case reflect.Struct:
+		source, verification := v.FieldByName("Source"), v.FieldByName("Verification")
+		if source.IsValid() && source.Type() == urlType && verification.IsValid() && verification.Type() == verificationType {
+			u := url.URL(source.Interface().(types.Url))
+			if u.Scheme != "" && u.Scheme != "data" && verification.Interface().(types.Verification).Hash == nil {
+				return true
+			}
+		}
+		for i := 0; i < v.NumField(); i++ {
+			if hasUnverifiedSource(v.Field(i)) {
+				return true
+			}
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			if hasUnverifiedSource(v.Index(i)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// liveConfig returns cfg with the root filesystem at e.Root rather than at
+// the mount point of the root filesystem within the initramfs.
+func (e Engine) liveConfig(cfg types.Config) types.Config {
+	filesystems := make([]types.Filesystem, len(cfg.Storage.Filesystems))
+	copy(filesystems, cfg.Storage.Filesystems)
+	for i, fs := range filesystems {
+		if fs.Name == "root" {
+			filesystems[i].Path = func(p types.Path) *types.Path { return &p }(types.Path(e.Root))
+		}
+	}
+	cfg.Storage.Filesystems = filesystems
+	return cfg
+}
+
+// PlanLive returns, one per line, the changes that applying cfg would make to
+// the running system at e.Root. Remote contents are fetched and verified to
+// compute the plan.
+func (e *Engine) PlanLive(cfg types.Config) ([]string, error) {
+	cfg = e.liveConfig(cfg)
+	u := util.Util{DestDir: e.Root, Platform: e.Platform, Logger: e.Logger}
+	plan := []string{}
+
+	addFile := func(f *util.File) error {
+		if f == nil {
+			return nil
+		}
+		change, err := planFile(u, f)
+		if err != nil {
+			return fmt.Errorf("failed to plan %q: %v", f.Path, err)
+		}
+		plan = append(plan, change)
+		return nil
+	}
+
+	for _, g := range cfg.Passwd.Groups {
+		plan = append(plan, fmt.Sprintf("group %s: create or update", g.Name))
+	}
+	for _, user := range cfg.Passwd.Users {
+		plan = append(plan, fmt.Sprintf("user %s: create or update", user.Name))
+	}
+	for _, d := range cfg.Storage.Directories {
+		plan = append(plan, fmt.Sprintf("directory %s: create if missing", d.Path))
+	}
+	for _, f := range cfg.Storage.Files {
+		rendered := util.RenderFile(e.Logger, &e.client, e.ctx, f)
+		if rendered == nil {
+			return nil, fmt.Errorf("failed to resolve file %q", f.Path)
+		}
+		if f.Contents.Template {
+			u.TemplateFile(rendered)
+		}
+		if err := addFile(rendered); err != nil {
+			return nil, err
+		}
+	}
+	for _, unit := range cfg.Systemd.Units {
+		for _, dropin := range unit.DropIns {
+			if dropin.Contents != "" {
+				if err := addFile(util.FileFromUnitDropin(unit, dropin)); err != nil {
+					return nil, err
+				}
+			}
+		}
+		if unit.Contents != "" {
+			if err := addFile(util.FileFromSystemdUnit(unit)); err != nil {
+				return nil, err
+			}
+		}
+		if enabled := unit.Enablement(); enabled != nil && *enabled {
+			plan = append(plan, fmt.Sprintf("unit %s: enable", unit.Name))
+		} else if enabled != nil {
+			plan = append(plan, fmt.Sprintf("unit %s: disable", unit.Name))
+		}
+		if unit.Mask {
+			plan = append(plan, fmt.Sprintf("unit %s: mask", unit.Name))
+		}
+	}
+	for _, unit := range cfg.Networkd.Units {
+		for _, dropin := range unit.DropIns {
+			if dropin.Contents != "" {
+				if err := addFile(util.FileFromNetworkdUnitDropin(unit, dropin)); err != nil {
+					return nil, err
+				}
+			}
+		}
+		if unit.Contents != "" {
+			if err := addFile(util.FileFromNetworkdUnit(unit)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, preset := range cfg.Systemd.Presets {
+		if err := addFile(util.FileFromSystemdPreset(preset)); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.Systemd.ApplyPresets {
+		plan = append(plan, "presets: apply to all units")
+	}
+	if cfg.Systemd.DefaultTarget != nil {
+		plan = append(plan, fmt.Sprintf("default target: set to %s", *cfg.Systemd.DefaultTarget))
+	}
+	if grub := cfg.Bootloader.Grub; len(grub.Users) != 0 || grub.Timeout != nil {
+		if err := addFile(util.FileFromGrubConfig(grub)); err != nil {
+			return nil, err
+		}
+	}
+	if len(cfg.System.Hosts) != 0 {
+		existing, err := ioutil.ReadFile(u.JoinPath("/etc/hosts"))
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		if err := addFile(&util.File{
+			Path:       "/etc/hosts",
+			ReadCloser: ioutil.NopCloser(bytes.NewReader(util.HostsWithEntries(existing, cfg.System.Hosts))),
+			Mode:       util.DefaultFilePermissions,
+		}); err != nil {
+			return nil, err
+		}
+	}
+	if len(cfg.System.Sysctl) != 0 {
+		if err := addFile(util.FileFromSysctl(cfg.System.Sysctl)); err != nil {
+			return nil, err
+		}
+	}
+	load, modprobe := util.FilesFromKernelModules(cfg.System.KernelModules)
+	if err := addFile(load); err != nil {
+		return nil, err
+	}
+	if err := addFile(modprobe); err != nil {
+		return nil, err
+	}
+
+	return plan, nil
+}
+
+// planFile describes how writing f would change the file at its path.
+func planFile(u util.Util, f *util.File) (string, error) {
+	defer f.Close()
+	contents, err := ioutil.ReadAll(f)
+	if err != nil {
+		return "", err
+	}
+	if err := f.Verify(); err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(contents)
+
+	path := u.JoinPath(string(f.Path))
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return fmt.Sprintf("file %s: create (%d bytes, mode %04o, sha256 %x)", f.Path, len(contents), f.Mode, sum), nil
+	} else if err != nil {
+		return "", err
+	}
+	existing, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	changes := []string{}
+	if !bytes.Equal(existing, contents) {
+		changes = append(changes, fmt.Sprintf("contents %d -> %d bytes, sha256 %x", len(existing), len(contents), sum))
+	}
+	if info.Mode().Perm() != f.Mode.Perm() {
+		changes = append(changes, fmt.Sprintf("mode %04o -> %04o", info.Mode().Perm(), f.Mode.Perm()))
+	}
+	if len(changes) == 0 {
+		return fmt.Sprintf("file %s: unchanged", f.Path), nil
+	}
+	return fmt.Sprintf("file %s: modify (%s)", f.Path, strings.Join(changes, ", ")), nil
+}
+
+// LiveToken returns the token which must be passed to ApplyLive to confirm
+// that plan was reviewed.
+func LiveToken(plan []string) string {
+	sum := sha256.Sum256([]byte(strings.Join(plan, "\n")))
+	return hex.EncodeToString(sum[:])[:16]
+}
+
+// ApplyLive applies cfg to the running system at e.Root, provided that the
+// plan for doing so still matches token.
+func (e *Engine) ApplyLive(cfg types.Config, token string) (Result, error) {
+	if err := CheckLive(cfg); err != nil {
+		return ResultConfigInvalid, err
+	}
+	plan, err := e.PlanLive(cfg)
+	if err != nil {
+		return ResultFetchFailed, err
+	}
+	if LiveToken(plan) != token {
+		return ResultConfigInvalid, ErrLiveTokenInvalid
+	}
+	defer e.client.ReleaseCache()
+	live := e.liveConfig(cfg)
+	if e.runStage(liveStage, live) != nil {
+		return ResultStageFailed, nil
+	}
+	if stages.Get(liveUnmountStage) != nil && e.runStage(liveUnmountStage, live) != nil {
+		return ResultStageFailed, nil
+	}
+	return ResultSuccess, nil
+}
@@ -0,0 +1,166 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/coreos/ignition/config/types"
+	"github.com/coreos/ignition/internal/exec/util"
+)
+
+func TestCheckLive(t *testing.T) {
+	type in struct {
+		cfg types.Config
+	}
+	type out struct {
+		err error
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{cfg: types.Config{}},
+			out: out{},
+		},
+		{
+			in:  in{cfg: types.Config{Storage: types.Storage{Files: []types.File{{Node: types.Node{Filesystem: "root", Path: "/etc/motd"}}}}}},
+			out: out{},
+		},
+		{
+			in:  in{cfg: types.Config{Storage: types.Storage{Files: []types.File{{Node: types.Node{Filesystem: "data", Path: "/motd"}}}}}},
+			out: out{err: ErrLiveFilesystem},
+		},
+		{
+			in:  in{cfg: types.Config{Storage: types.Storage{Disks: []types.Disk{{Device: "/dev/sda"}}}}},
+			out: out{err: ErrLiveStorage},
+		},
+		{
+			in:  in{cfg: types.Config{Storage: types.Storage{Filesystems: []types.Filesystem{{Name: "data", Mount: &types.FilesystemMount{Device: "/dev/sdb", Format: "ext4", Create: &types.FilesystemCreate{}}}}}}},
+			out: out{err: ErrLiveStorage},
+		},
+		{
+			in:  in{cfg: types.Config{System: types.System{MachineId: &types.MachineId{Mode: types.MachineIdPreserve}}}},
+			out: out{},
+		},
+		{
+			in:  in{cfg: types.Config{System: types.System{MachineId: &types.MachineId{Mode: types.MachineIdRegenerate}}}},
+			out: out{err: ErrLiveMachineId},
+		},
+		{
+			in:  in{cfg: types.Config{System: types.System{PostProvision: &types.PostProvision{Command: []string{"/bin/true"}}}}},
+			out: out{err: ErrLiveFirstBoot},
+		},
+		{
+			in:  in{cfg: types.Config{Storage: types.Storage{Files: []types.File{{Node: types.Node{Filesystem: "root", Path: "/etc/motd"}, Contents: types.FileContents{Source: liveUrl("https://example.com/motd")}}}}}},
+			out: out{err: ErrLiveUnverified},
+		},
+		{
+			in:  in{cfg: types.Config{Storage: types.Storage{Files: []types.File{{Node: types.Node{Filesystem: "root", Path: "/etc/motd"}, Contents: types.FileContents{Fragments: []types.FileFragment{{Source: liveUrl("https://example.com/motd")}}}}}}}},
+			out: out{err: ErrLiveUnverified},
+		},
+		{
+			in:  in{cfg: types.Config{Storage: types.Storage{Files: []types.File{{Node: types.Node{Filesystem: "root", Path: "/etc/motd"}, Contents: types.FileContents{Source: liveUrl("https://example.com/motd"), Verification: types.Verification{Hash: &types.Hash{Function: "sha512", Sum: "00"}}}}}}}},
+			out: out{},
+		},
+		{
+			in:  in{cfg: types.Config{Storage: types.Storage{Files: []types.File{{Node: types.Node{Filesystem: "root", Path: "/etc/motd"}, Contents: types.FileContents{Source: liveUrl("data:,hello")}}}}}},
+			out: out{},
+		},
+	}
+
+	for i, test := range tests {
+		err := CheckLive(test.in.cfg)
+		if err != test.out.err {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.out.err, err)
+		}
+	}
+}
+
+func liveUrl(raw string) types.Url {
+	u, err := url.Parse(raw)
+	if err != nil {
+		panic(err)
+	}
+	return types.Url(*u)
+}
+
+func TestPlanFile(t *testing.T) {
+	type in struct {
+		existing *string
+		mode     os.FileMode
+		contents string
+	}
+	type out struct {
+		prefix string
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{contents: "hello\n", mode: 0644},
+			out: out{prefix: "file /etc/motd: create (6 bytes, mode 0644"},
+		},
+		{
+			in:  in{existing: func(s string) *string { return &s }("hello\n"), contents: "hello\n", mode: 0644},
+			out: out{prefix: "file /etc/motd: unchanged"},
+		},
+		{
+			in:  in{existing: func(s string) *string { return &s }("hello\n"), contents: "goodbye\n", mode: 0644},
+			out: out{prefix: "file /etc/motd: modify (contents 6 -> 8 bytes"},
+		},
+		{
+			in:  in{existing: func(s string) *string { return &s }("hello\n"), contents: "hello\n", mode: 0600},
+			out: out{prefix: "file /etc/motd: modify (mode 0644 -> 0600)"},
+		},
+	}
+
+	for i, test := range tests {
+		root, err := ioutil.TempDir("", "ignition-live")
+		if err != nil {
+			t.Fatalf("failed to create temp directory: %v", err)
+		}
+		path := filepath.Join(root, "etc", "motd")
+		if test.in.existing != nil {
+			os.MkdirAll(filepath.Dir(path), 0755)
+			if err := ioutil.WriteFile(path, []byte(*test.in.existing), 0644); err != nil {
+				t.Fatalf("failed to write file: %v", err)
+			}
+			os.Chmod(path, 0644)
+		}
+
+		change, err := planFile(util.Util{DestDir: root}, &util.File{
+			Path:       "/etc/motd",
+			ReadCloser: ioutil.NopCloser(strings.NewReader(test.in.contents)),
+			Mode:       test.in.mode,
+		})
+		os.RemoveAll(root)
+		if err != nil {
+			t.Errorf("#%d: unexpected error: %v", i, err)
+		}
+		if !strings.HasPrefix(change, test.out.prefix) {
+			t.Errorf("#%d: bad change: want prefix %q, got %q", i, test.out.prefix, change)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/coreos/ignition/config/types"
+	"github.com/coreos/ignition/internal/exec/util"
+	"github.com/coreos/ignition/internal/log"
+	"github.com/coreos/ignition/internal/tool"
+)
+
+// MaxPcr is the highest PCR a TPM 2.0 is required to implement.
+const MaxPcr = 23
+
+// measurePending follows the digest in the marker until the PCR has been
+// extended with it.
+const measurePending = " pending"
+
+var (
+	ErrMeasuredOther      = errors.New("config differs from the one measured earlier in this boot")
+	ErrMeasureInterrupted = errors.New("an earlier measurement in this boot was interrupted, so the PCR may or may not hold the config")
+)
+
+// extendPcr extends pcr of the TPM's SHA-256 bank with digest.
+var extendPcr = func(logger *log.Logger, pcr int, digest string) error {
+	if err := tool.Require(tool.Tpm2Pcrextend); err != nil {
+		return err
+	}
+	return logger.LogCmd(tool.Tpm2Pcrextend.Command(fmt.Sprintf("%d:sha256=%s", pcr, digest)), "measuring config into PCR %d", pcr)
+}
+
+// ConfigDigest returns the SHA-256 digest of cfg, serialized as -render
+// prints it, so that a verifier can recompute it from the same config.
+func ConfigDigest(cfg types.Config) (string, error) {
+	b, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(append(b, '\n'))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// measuredMarker records the digest measured during this boot, so that the
+// stages run in separate invocations measure the config only once.
+func (e Engine) measuredMarker() string {
+	return e.stateUtil().StatePath("measured")
+}
+
+// measureConfig extends PCR e.MeasurePcr with the digest of cfg, unless it
+// was already measured during this boot, and records the digest in the
+// results. The marker is written, as pending, before the PCR is extended, so
+// that a run interrupted in between fails later runs rather than extending
+// the PCR twice or not at all. A config other than the one measured fails
+// too, since the PCR doesn't attest to it.
+func (e Engine) measureConfig(cfg types.Config) error {
+	if e.MeasurePcr == 0 {
+		return nil
+	}
+	digest, err := ConfigDigest(cfg)
+	if err != nil {
+		return err
+	}
+	e.results.ConfigDigest = "sha256-" + digest
+
+	marker := e.measuredMarker()
+	if measured, err := ioutil.ReadFile(marker); err == nil {
+		measured := strings.TrimSpace(string(measured))
+		if strings.HasSuffix(measured, measurePending) {
+			return ErrMeasureInterrupted
+		}
+		if measured != digest {
+			return ErrMeasuredOther
+		}
+		return nil
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(marker), 0755); err != nil {
+		return err
+	}
+	if err := util.WriteFileAtomic(marker, []byte(digest+measurePending+"\n"), 0644); err != nil {
+		return err
+	}
+	if err := extendPcr(e.Logger, e.MeasurePcr, digest); err != nil {
+		return err
+	}
+	e.Logger.Info("measured config sha256-%s into PCR %d", digest, e.MeasurePcr)
+	return util.WriteFileAtomic(marker, []byte(digest+"\n"), 0644)
+}
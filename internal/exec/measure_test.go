@@ -0,0 +1,90 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/coreos/ignition/config/types"
+	"github.com/coreos/ignition/internal/log"
+)
+
+func TestMeasureConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ignition-measure")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	var extended []string
+	defer func(f func(*log.Logger, int, string) error) { extendPcr = f }(extendPcr)
+	extendPcr = func(_ *log.Logger, pcr int, digest string) error {
+		if pcr != 9 {
+			t.Errorf("bad pcr: want 9, got %d", pcr)
+		}
+		extended = append(extended, digest)
+		return nil
+	}
+
+	cfg := types.Config{Ignition: types.Ignition{Version: types.IgnitionVersion(types.MaxVersion)}}
+	digest, err := ConfigDigest(cfg)
+	if err != nil {
+		t.Fatalf("failed to digest config: %v", err)
+	}
+
+	logger := log.New()
+	disabled := Engine{Logger: &logger, StateDir: dir, results: &results{}}
+	if err := disabled.measureConfig(cfg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(extended) != 0 || disabled.results.ConfigDigest != "" {
+		t.Errorf("measured with PCR 0")
+	}
+
+	// Each stage measures the config, but only the first extends the PCR.
+	for i := 0; i < 2; i++ {
+		e := Engine{Logger: &logger, StateDir: dir, MeasurePcr: 9, results: &results{}}
+		if err := e.measureConfig(cfg); err != nil {
+			t.Fatalf("#%d: unexpected error: %v", i, err)
+		}
+		if want := "sha256-" + digest; e.results.ConfigDigest != want {
+			t.Errorf("#%d: bad digest: want %q, got %q", i, want, e.results.ConfigDigest)
+		}
+	}
+	if len(extended) != 1 || extended[0] != digest {
+		t.Errorf("bad extensions: want [%s], got %v", digest, extended)
+	}
+
+	// Another config can't be attested to by the PCR.
+	other := cfg
+	other.Ignition.Timeouts.HttpTotal = func(i int) *int { return &i }(10)
+	e := Engine{Logger: &logger, StateDir: dir, MeasurePcr: 9, results: &results{}}
+	if err := e.measureConfig(other); err != ErrMeasuredOther {
+		t.Errorf("bad error for another config: want %v, got %v", ErrMeasuredOther, err)
+	}
+
+	// Nor can the config, if the run measuring it was interrupted.
+	if err := ioutil.WriteFile(e.measuredMarker(), []byte(digest+measurePending+"\n"), 0644); err != nil {
+		t.Fatalf("failed to write marker: %v", err)
+	}
+	if err := e.measureConfig(cfg); err != ErrMeasureInterrupted {
+		t.Errorf("bad error after an interruption: want %v, got %v", ErrMeasureInterrupted, err)
+	}
+	if len(extended) != 1 {
+		t.Errorf("extended the PCR again: %v", extended)
+	}
+}
@@ -0,0 +1,141 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+// netWaitInterval is how often the network conditions are checked.
+const netWaitInterval = 500 * time.Millisecond
+
+// The routing tables checked for a default route.
+var (
+	ipv4RoutePath = "/proc/net/route"
+	ipv6RoutePath = "/proc/net/ipv6_route"
+)
+
+// waitForNetwork waits until the network meets the conditions of e.NetWait,
+// so that fetching the config starts once it can succeed rather than
+// retrying until the online timeout. It gives up, logging what is still
+// missing, once the online timeout elapses or the run is cancelled; the fetch
+// is attempted regardless.
+func (e Engine) waitForNetwork() {
+	if e.NetWait.Empty() {
+		return
+	}
+	var deadline <-chan time.Time
+	if e.OnlineTimeout > 0 {
+		timer := time.NewTimer(e.OnlineTimeout)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
+	start := time.Now()
+	logged := ""
+	for {
+		pending := e.pendingNetwork()
+		if pending == "" {
+			e.Logger.Info("network ready after %v", time.Since(start))
+			return
+		}
+		if pending != logged {
+			e.Logger.Info("waiting for the network: %s", pending)
+			logged = pending
+		}
+		select {
+		case <-time.After(netWaitInterval):
+		case <-deadline:
+			e.Logger.Warning("gave up waiting for the network after %v: %s", e.OnlineTimeout, pending)
+			return
+		case <-e.ctx.Done():
+			return
+		}
+	}
+}
+
+// pendingNetwork describes the first of the conditions of e.NetWait which
+// doesn't hold yet, or returns "" if they all do.
+func (e Engine) pendingNetwork() string {
+	for _, name := range e.NetWait.Interfaces {
+		if !interfaceReady(name) {
+			return fmt.Sprintf("interface %q is not up with an address", name)
+		}
+	}
+	if e.NetWait.DefaultRoute && !hasDefaultRoute() {
+		return "there is no default route"
+	}
+	for _, host := range e.NetWait.Hosts {
+		if _, err := net.DefaultResolver.LookupHost(e.ctx, host); err != nil {
+			return fmt.Sprintf("%q does not resolve: %v", host, err)
+		}
+	}
+	return ""
+}
+
+// interfaceReady reports whether the named interface is up and has an
+// address other than a link-local one, which it gains without a network.
+func interfaceReady(name string) bool {
+	iface, err := net.InterfaceByName(name)
+	if err != nil || iface.Flags&net.FlagUp == 0 {
+		return false
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return false
+	}
+	for _, addr := range addrs {
+		if ipnet, ok := addr.(*net.IPNet); ok && !ipnet.IP.IsLinkLocalUnicast() {
+			return true
+		}
+	}
+	return false
+}
+
+// hasDefaultRoute reports whether the IPv4 or IPv6 routing table has a
+// default route.
+func hasDefaultRoute() bool {
+	// Fields: interface, destination, gateway, flags, ..., mask.
+	if routeTableHas(ipv4RoutePath, func(f []string) bool {
+		return len(f) > 7 && f[1] == "00000000" && f[7] == "00000000"
+	}) {
+		return true
+	}
+	// Fields: destination, prefix length, ..., interface.
+	return routeTableHas(ipv6RoutePath, func(f []string) bool {
+		return len(f) > 9 && f[0] == strings.Repeat("0", 32) && f[1] == "00" && f[9] != "lo"
+	})
+}
+
+// routeTableHas reports whether a line of the routing table at path matches.
+func routeTableHas(path string, match func(fields []string) bool) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if match(strings.Fields(scanner.Text())) {
+			return true
+		}
+	}
+	return false
+}
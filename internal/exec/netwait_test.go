@@ -0,0 +1,120 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/coreos/ignition/internal/log"
+	"github.com/coreos/ignition/internal/providers/cmdline"
+
+	"golang.org/x/net/context"
+)
+
+func TestHasDefaultRoute(t *testing.T) {
+	type in struct {
+		ipv4 string
+		ipv6 string
+	}
+	type out struct {
+		found bool
+	}
+
+	const ipv4Header = "Iface\tDestination\tGateway \tFlags\tRefCnt\tUse\tMetric\tMask\t\tMTU\tWindow\tIRTT\n"
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{ipv4: ipv4Header + "eth0\t0002A8C0\t00000000\t0001\t0\t0\t0\t00FFFFFF\t0\t0\t0\n"},
+			out: out{found: false},
+		},
+		{
+			in:  in{ipv4: ipv4Header + "eth0\t00000000\t0102A8C0\t0003\t0\t0\t100\t00000000\t0\t0\t0\n"},
+			out: out{found: true},
+		},
+		{
+			in:  in{ipv6: "00000000000000000000000000000000 00 00000000000000000000000000000000 00 fe800000000000000000000000000001 00000400 00000001 00000000 00000003 eth0\n"},
+			out: out{found: true},
+		},
+		{
+			in:  in{ipv6: "00000000000000000000000000000000 00 00000000000000000000000000000000 00 00000000000000000000000000000000 ffffffff 00000001 00000000 00200200 lo\n"},
+			out: out{found: false},
+		},
+	}
+
+	dir, err := ioutil.TempDir("", "ignition-routes")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(ipv4, ipv6 string) { ipv4RoutePath, ipv6RoutePath = ipv4, ipv6 }(ipv4RoutePath, ipv6RoutePath)
+	ipv4RoutePath = filepath.Join(dir, "route")
+	ipv6RoutePath = filepath.Join(dir, "ipv6_route")
+
+	for i, test := range tests {
+		if err := ioutil.WriteFile(ipv4RoutePath, []byte(test.in.ipv4), 0644); err != nil {
+			t.Fatalf("#%d: failed to write routes: %v", i, err)
+		}
+		if err := ioutil.WriteFile(ipv6RoutePath, []byte(test.in.ipv6), 0644); err != nil {
+			t.Fatalf("#%d: failed to write routes: %v", i, err)
+		}
+		if found := hasDefaultRoute(); found != test.out.found {
+			t.Errorf("#%d: bad default route: want %t, got %t", i, test.out.found, found)
+		}
+	}
+}
+
+func TestWaitForNetwork(t *testing.T) {
+	type in struct {
+		wait cmdline.NetWait
+	}
+	type out struct {
+		gaveUp bool
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{wait: cmdline.NetWait{Interfaces: []string{"lo"}, Hosts: []string{"localhost"}}},
+			out: out{gaveUp: false},
+		},
+		{
+			in:  in{wait: cmdline.NetWait{Interfaces: []string{"ignition-bogus0"}}},
+			out: out{gaveUp: true},
+		},
+	}
+
+	logger := log.New()
+	for i, test := range tests {
+		e := Engine{
+			Logger:        &logger,
+			OnlineTimeout: 2 * netWaitInterval,
+			NetWait:       test.in.wait,
+			ctx:           context.Background(),
+		}
+		start := time.Now()
+		e.waitForNetwork()
+		if gaveUp := time.Since(start) >= e.OnlineTimeout; gaveUp != test.out.gaveUp {
+			t.Errorf("#%d: bad wait: want gave up %t, got %t (pending: %s)", i, test.out.gaveUp, gaveUp, e.pendingNetwork())
+		}
+	}
+}
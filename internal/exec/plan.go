@@ -0,0 +1,231 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"fmt"
+
+	"github.com/coreos/ignition/config/types"
+	"github.com/coreos/ignition/internal/exec/util"
+)
+
+// Plan returns, one per line and in the order the stages would perform them,
+// the operations that provisioning with cfg would carry out. It describes the
+// config alone: nothing is fetched, and neither the disks nor the target
+// filesystems are examined, so it is safe to run anywhere. Inline contents and
+// URL credentials are left out.
+func Plan(cfg types.Config) []string {
+	plan := []string{}
+	add := func(format string, args ...interface{}) {
+		plan = append(plan, fmt.Sprintf(format, args...))
+	}
+	cfg.Storage = cfg.Storage.WithBootDevice()
+
+	for _, z := range cfg.Storage.Zfcp {
+		add("zfcp %s wwpn %s lun %s: attach", z.BusId, z.Wwpn, z.Lun)
+	}
+	for _, disk := range cfg.Storage.Disks {
+		if disk.WipeTable {
+			add("disk %s: wipe partition table", disk.Device)
+		}
+		for _, part := range disk.Partitions {
+			if !part.Exists() {
+				add("partition %d on %s: delete if present", part.Number, disk.Device)
+				continue
+			}
+			if part.WipePartitionEntry && !disk.WipeTable {
+				add("partition %d on %s: delete if present", part.Number, disk.Device)
+			}
+			if part.Resize && !disk.WipeTable {
+				add("partition %d (%s) on %s: grow to size %s, or create if missing", part.Number, part.Label, disk.Device, dimension(part.Size, part.SizeMiB))
+				continue
+			}
+			add("partition %d (%s) on %s: create (start %s, size %s)", part.Number, part.Label, disk.Device, dimension(part.Start, part.StartMiB), dimension(part.Size, part.SizeMiB))
+		}
+		for _, pair := range disk.SlotPairs {
+			add("slot pair %s on %s: create (size %s each)", pair.Label, disk.Device, dimension(pair.Size, pair.SizeMiB))
+		}
+	}
+	if g := cfg.Storage.GrowRoot; g != nil {
+		add("partition %d on %s: grow to fill the disk", g.Partition, g.Device)
+	}
+	for _, array := range cfg.Storage.Arrays {
+		add("raid %s: create (%s, %d devices, %d spares)", array.Name, array.Level, len(array.Devices), array.Spares)
+	}
+	for _, volume := range cfg.Storage.Luks {
+		if volume.WipeVolume {
+			add("luks %s on %s: format", volume.Name, volume.Device)
+		} else {
+			add("luks %s on %s: format, or reuse if present", volume.Name, volume.Device)
+		}
+		if c := volume.Clevis; c != nil {
+			add("luks %s: bind clevis (tpm2 %t, %d tang servers, threshold %d)", volume.Name, c.Tpm2, len(c.Tang), c.EffectiveThreshold())
+		}
+	}
+	for _, image := range cfg.Storage.Images {
+		add("image %s: write from %s", image.Device, util.RedactSource(image.Source.String()))
+	}
+	for _, fs := range cfg.Storage.Filesystems {
+		switch {
+		case fs.Mount != nil && fs.Mount.Create != nil:
+			add("filesystem %s on %s: format as %s", fs.Name, fs.Mount.Device, fs.Mount.Format)
+		case fs.Mount != nil:
+			add("filesystem %s on %s: use existing %s", fs.Name, fs.Mount.Device, fs.Mount.Format)
+		case fs.Network != nil:
+			add("filesystem %s: mount %s at %s", fs.Name, fs.Network.Source, fs.Network.Path)
+		case fs.Tmpfs != nil:
+			add("filesystem %s: mount tmpfs at %s", fs.Name, fs.Tmpfs.Path)
+		case fs.Overlay != nil:
+			add("filesystem %s: mount overlay at %s", fs.Name, fs.Overlay.Path)
+		}
+	}
+	for _, d := range cfg.Storage.Directories {
+		d = types.Directory(cfg.Storage.Defaults.Apply(types.Node(d), true))
+		add("directory %s on %s: create (mode %04o)", d.Path, d.Filesystem, d.Mode)
+	}
+	for _, f := range cfg.Storage.Files {
+		f.Node = cfg.Storage.Defaults.Apply(f.Node, false)
+		switch {
+		case f.Contents.Source.String() != "":
+			add("file %s on %s: write from %s (mode %04o)", f.Path, f.Filesystem, util.RedactSource(f.Contents.Source.String()), f.Mode)
+		case len(f.Contents.Fragments) != 0:
+			add("file %s on %s: write from %d fragments (mode %04o)", f.Path, f.Filesystem, len(f.Contents.Fragments), f.Mode)
+		case len(f.Append) != 0:
+			add("file %s on %s: keep existing contents (mode %04o)", f.Path, f.Filesystem, f.Mode)
+		default:
+			add("file %s on %s: write empty (mode %04o)", f.Path, f.Filesystem, f.Mode)
+		}
+		if len(f.Append) != 0 {
+			add("file %s on %s: append %d fragments", f.Path, f.Filesystem, len(f.Append))
+		}
+	}
+	for _, l := range cfg.Storage.Links {
+		if l.Hard {
+			add("link %s on %s: hard link to %s", l.Path, l.Filesystem, l.Target)
+		} else {
+			add("link %s on %s: symlink to %s", l.Path, l.Filesystem, l.Target)
+		}
+	}
+	for _, sf := range cfg.Storage.SwapFiles {
+		add("swap file %s on %s: create (size %d)", sf.Path, sf.Filesystem, sf.Size)
+	}
+
+	for _, g := range cfg.Passwd.Groups {
+		add("group %s: create", g.Name)
+	}
+	for _, user := range cfg.Passwd.Users {
+		if user.Create != nil {
+			add("user %s: create", user.Name)
+		}
+		if len(user.SSHAuthorizedKeys) != 0 {
+			add("user %s: authorize %d ssh keys", user.Name, len(user.SSHAuthorizedKeys))
+		}
+		if user.PasswordHash != "" {
+			add("user %s: set password", user.Name)
+		}
+		if user.Locked {
+			add("user %s: lock password", user.Name)
+		}
+	}
+
+	for _, unit := range cfg.Systemd.Units {
+		for _, dropin := range unit.DropIns {
+			if dropin.Contents != "" {
+				add("unit %s: write dropin %s", unit.Name, dropin.Name)
+			}
+		}
+		if unit.Contents != "" {
+			add("unit %s: write", unit.Name)
+		}
+		if enabled := unit.Enablement(); enabled != nil && *enabled {
+			add("unit %s: enable", unit.Name)
+		} else if enabled != nil {
+			add("unit %s: disable", unit.Name)
+		}
+		if unit.Mask {
+			add("unit %s: mask", unit.Name)
+		}
+	}
+	for _, unit := range cfg.Networkd.Units {
+		for _, dropin := range unit.DropIns {
+			if dropin.Contents != "" {
+				add("networkd unit %s: write dropin %s", unit.Name, dropin.Name)
+			}
+		}
+		if unit.Contents != "" {
+			add("networkd unit %s: write", unit.Name)
+		}
+	}
+	for _, preset := range cfg.Systemd.Presets {
+		add("preset %s: write", preset.Name)
+	}
+	if cfg.Systemd.ApplyPresets {
+		add("presets: apply to all units")
+	}
+	if cfg.Systemd.DefaultTarget != nil {
+		add("default target: set to %s", *cfg.Systemd.DefaultTarget)
+	}
+
+	if grub := cfg.Bootloader.Grub; len(grub.Users) != 0 || grub.Timeout != nil {
+		add("grub: write config (%d users)", len(grub.Users))
+	}
+	for _, entry := range cfg.Bootloader.Efi.Entries {
+		if !entry.Exists() {
+			add("efi boot entry %s: remove", entry.Label)
+		} else {
+			add("efi boot entry %s: create", entry.Label)
+		}
+	}
+	if len(cfg.Bootloader.Efi.BootOrder) != 0 {
+		add("efi boot order: set to %v", cfg.Bootloader.Efi.BootOrder)
+	}
+	if id := cfg.System.MachineId; id != nil && id.Mode != "" {
+		add("machine id: %s", id.Mode)
+	}
+	if len(cfg.System.Hosts) != 0 {
+		add("/etc/hosts: add %d entries", len(cfg.System.Hosts))
+	}
+	if len(cfg.System.Sysctl) != 0 {
+		add("sysctl: set %d keys", len(cfg.System.Sysctl))
+	}
+	for _, module := range cfg.System.KernelModules {
+		if module.Load {
+			add("kernel module %s: load at boot", module.Name)
+		}
+		if module.Blacklist {
+			add("kernel module %s: blacklist", module.Name)
+		}
+	}
+	if cfg.System.PostProvision != nil {
+		add("post-provision: run once on first boot")
+	}
+	for _, arg := range cfg.KernelArguments.ShouldExist {
+		add("kernel argument %s: add", arg)
+	}
+	for _, arg := range cfg.KernelArguments.ShouldNotExist {
+		add("kernel argument %s: remove", arg)
+	}
+
+	return plan
+}
+
+// dimension describes a partition dimension given either in sectors or, if
+// mib is set, in MiB.
+func dimension(sectors types.PartitionDimension, mib int) string {
+	if mib != 0 {
+		return fmt.Sprintf("%d MiB", mib)
+	}
+	return fmt.Sprint(sectors)
+}
@@ -0,0 +1,41 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"github.com/coreos/ignition/config/types"
+)
+
+// configureProxy routes the remaining fetches, including those of the files
+// stage, through the proxies of proxy. Nothing changes if proxy declares
+// nothing.
+func (e Engine) configureProxy(proxy types.Proxy) error {
+	if proxy.IsEmpty() {
+		return nil
+	}
+	return e.Logger.LogOp(func() error {
+		return e.client.SetProxy(proxy)
+	}, "configuring provisioning proxy")
+}
+
+// inheritProxy gives cfg the proxy settings in effect, proxy, unless it
+// declares its own, so that the rendered config records the proxy its
+// references were fetched through.
+func inheritProxy(cfg types.Config, proxy types.Proxy) types.Config {
+	if cfg.Ignition.Proxy.IsEmpty() {
+		cfg.Ignition.Proxy = proxy
+	}
+	return cfg
+}
@@ -0,0 +1,231 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/coreos/ignition/config/types"
+	"github.com/coreos/ignition/internal/version"
+)
+
+// DefaultResultFile is on the provisioned root, so that later boots can check
+// whether provisioning succeeded.
+const DefaultResultFile = "/var/lib/ignition/result.json"
+
+// rootMounted reports whether a filesystem is mounted at root, as the target
+// root is once the stages after disks run. Before then, the result file would
+// be written to the directory the root is later mounted over.
+var rootMounted = func(root string) bool {
+	var st, parent syscall.Stat_t
+	if err := syscall.Stat(root, &st); err != nil {
+		return false
+	}
+	if err := syscall.Stat(filepath.Join(root, ".."), &parent); err != nil {
+		return false
+	}
+	return st.Dev != parent.Dev || st.Ino == parent.Ino
+}
+
+// results records the outcome of provisioning, as written to the result file.
+type results struct {
+	Version string `json:"version"`
+	// Provider is "cmdline", "local", or the platform whose provider
+	// returned the config.
+	Provider   string   `json:"provider,omitempty"`
+	References []string `json:"references,omitempty"`
+	// ConfigDigest is the digest of the rendered config measured into the
+	// TPM, if it was.
+	ConfigDigest string `json:"configDigest,omitempty"`
+	// FetchMs is how long fetching the config and its references took.
+	FetchMs int64 `json:"fetchMs,omitempty"`
+	// Resources are the HTTP requests made while fetching and by the
+	// stages, in the order they finished.
+	Resources []resourceResult `json:"resources,omitempty"`
+	Stages    []stageResult    `json:"stages"`
+	// Complete is set once the last stage has succeeded.
+	Complete bool `json:"complete"`
+	// PostProvision is recorded by the unit running the post-provisioning
+	// hook, once the hook has run on first boot.
+	PostProvision *postProvisionResult `json:"postProvision,omitempty"`
+}
+
+type postProvisionResult struct {
+	ExitStatus int    `json:"exitStatus"`
+	Output     string `json:"output"`
+}
+
+type stageResult struct {
+	Name       string `json:"name"`
+	Success    bool   `json:"success"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"durationMs"`
+}
+
+type resourceResult struct {
+	Method     string `json:"method"`
+	Url        string `json:"url"`
+	Status     int    `json:"status,omitempty"`
+	Attempts   int    `json:"attempts"`
+	DurationMs int64  `json:"durationMs"`
+}
+
+// resultUrl returns rawurl as it is recorded in the results, without its user
+// info, query, or fragment, or the contents of a data URL. Signed URLs carry
+// their credentials in the query, and the result file outlives the boot.
+func resultUrl(rawurl string) string {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return ""
+	}
+	u.Opaque = ""
+	u.User = nil
+	u.RawQuery = ""
+	u.ForceQuery = false
+	u.Fragment = ""
+	return u.String()
+}
+
+// milliseconds returns d in whole milliseconds.
+func milliseconds(d time.Duration) int64 {
+	return int64(d / time.Millisecond)
+}
+
+// targetRoot returns the path of the last definition of cfg's root
+// filesystem, or "" if it has none.
+func targetRoot(cfg types.Config) string {
+	root := ""
+	for _, fs := range cfg.Storage.Filesystems {
+		if fs.Name == "root" {
+			root = ""
+			if fs.Path != nil {
+				root = string(*fs.Path)
+			}
+		}
+	}
+	return root
+}
+
+// resultRoot returns the root the result file is written within: the
+// target's root filesystem, or Root if it has no path.
+func (e Engine) resultRoot() string {
+	if e.targetRoot != "" {
+		return e.targetRoot
+	}
+	return e.Root
+}
+
+// resultsCache is where the results are kept between the stages of a boot,
+// which may run in separate invocations before the root is mounted.
+func (e Engine) resultsCache() string {
+	return e.stateUtil().StatePath("result.json")
+}
+
+// loadResults resumes the results recorded by earlier stages of the boot.
+func (e *Engine) loadResults() {
+	e.results = &results{Version: version.String, Stages: []stageResult{}}
+	if e.ResultFile == "" {
+		return
+	}
+	b, err := ioutil.ReadFile(e.resultsCache())
+	if err != nil {
+		return
+	}
+	if err := json.Unmarshal(b, e.results); err != nil {
+		e.Logger.Warning("discarding unreadable results of earlier stages: %v", err)
+		e.results = &results{Version: version.String, Stages: []stageResult{}}
+	}
+}
+
+// resetResults starts the results afresh for a newly acquired config.
+func (e *Engine) resetResults(provider string) {
+	e.results = &results{Version: version.String, Provider: provider, Stages: []stageResult{}}
+}
+
+// recordFetch records how long fetching the config took, along with the
+// requests made.
+func (e Engine) recordFetch(duration time.Duration) {
+	e.Logger.Debug("fetching config took %v", duration)
+	e.results.FetchMs = milliseconds(duration)
+	e.recordResources()
+}
+
+// recordResources records the requests finished since they were last
+// recorded.
+func (e Engine) recordResources() {
+	for _, t := range e.client.TakeTimings() {
+		e.results.Resources = append(e.results.Resources, resourceResult{
+			Method:     t.Method,
+			Url:        resultUrl(t.Url),
+			Status:     t.Status,
+			Attempts:   t.Attempts,
+			DurationMs: milliseconds(t.Duration),
+		})
+	}
+}
+
+// recordStage records the outcome of the named stage and how long it took,
+// replacing that of an earlier run of it, along with the requests it made,
+// and saves the results.
+func (e Engine) recordStage(name string, err error, duration time.Duration) {
+	e.recordResources()
+	result := stageResult{Name: name, Success: err == nil, DurationMs: milliseconds(duration)}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	stages := []stageResult{}
+	for _, s := range e.results.Stages {
+		if s.Name != name {
+			stages = append(stages, s)
+		}
+	}
+	e.results.Stages = append(stages, result)
+	e.saveResults()
+}
+
+// saveResults writes the results to the cache and, once the root is mounted,
+// to the result file within it. The first stage to run with the root mounted
+// carries the results of those before it into the file.
+func (e Engine) saveResults() {
+	if e.ResultFile == "" {
+		return
+	}
+	b, err := json.MarshalIndent(e.results, "", "  ")
+	if err != nil {
+		e.Logger.Warning("failed to marshal results: %v", err)
+		return
+	}
+	paths := []string{e.resultsCache()}
+	if root := e.resultRoot(); rootMounted(root) {
+		paths = append(paths, filepath.Join(root, e.ResultFile))
+	}
+	for _, path := range paths {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			e.Logger.Warning("failed to write results: %v", err)
+			continue
+		}
+		// The errors recorded may quote the config, so only root may read
+		// the file.
+		if err := ioutil.WriteFile(path, append(b, '\n'), 0600); err != nil {
+			e.Logger.Warning("failed to write results: %v", err)
+		}
+	}
+}
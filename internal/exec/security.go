@@ -0,0 +1,283 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/coreos/ignition/config/types"
+	"github.com/coreos/ignition/internal/resource"
+	"github.com/coreos/ignition/internal/tool"
+)
+
+// DefaultSigningKeysDir holds the OpenPGP public keys, baked into the
+// initramfs, trusted to sign referenced configs.
+const DefaultSigningKeysDir = "/usr/lib/ignition/signing-keys.d"
+
+var (
+	ErrClientCertIncomplete = errors.New("a client certificate requires both a certificate and a key")
+	ErrLocalSource          = errors.New("must be an absolute path or an oem URL")
+	ErrNoSigningKeys        = errors.New("config is signed but no signing keys are trusted")
+	ErrUnsignedConfig       = errors.New("config is not signed, but signing keys are trusted")
+	ErrUnsignedContents     = errors.New("unsigned config may only declare ignition.version, ignition.timeouts, and references to signed configs")
+	ErrBadArmor             = errors.New("malformed ASCII-armored key")
+)
+
+// trustCertificateAuthorities fetches and verifies the CAs in cas and adds
+// them to those trusted by the client. CAs already added are skipped.
+func (e *Engine) trustCertificateAuthorities(cas []types.CaReference) error {
+	for _, ca := range cas {
+		source := ca.Source.String()
+		if e.client.HasCA(source) {
+			continue
+		}
+		fetcher := resource.Fetcher{Logger: e.Logger, Client: &e.client, Context: e.ctx}
+		data, err := fetcher.Fetch(url.URL(ca.Source), resource.FetchOptions{Verification: ca.Verification})
+		if err != nil {
+			return fmt.Errorf("failed to fetch CA %q: %v", source, err)
+		}
+		if err := e.client.AddCA(source, data); err != nil {
+			return fmt.Errorf("failed to add CA %q: %v", source, err)
+		}
+		e.Logger.Info("trusting CA %q for https fetches", source)
+	}
+	return nil
+}
+
+// loadClientCertificate presents the certificate and key at e.ClientCert and
+// e.ClientKey to https servers requesting client authentication.
+func (e *Engine) loadClientCertificate() error {
+	if e.ClientCert == "" && e.ClientKey == "" {
+		return nil
+	}
+	if e.ClientCert == "" || e.ClientKey == "" {
+		return ErrClientCertIncomplete
+	}
+
+	cert, err := e.readLocal(e.ClientCert)
+	if err != nil {
+		return fmt.Errorf("failed to read client certificate %q: %v", e.ClientCert, err)
+	}
+	key, err := e.readLocal(e.ClientKey)
+	if err != nil {
+		return fmt.Errorf("failed to read client key %q: %v", e.ClientKey, err)
+	}
+	if err := e.client.SetClientCertificate(cert, key); err != nil {
+		return fmt.Errorf("failed to load client certificate: %v", err)
+	}
+	e.Logger.Info("presenting client certificate %q for https fetches", e.ClientCert)
+	return nil
+}
+
+// readLocal reads the file at source, which is either an absolute path or an
+// oem URL.
+func (e *Engine) readLocal(source string) ([]byte, error) {
+	u, err := url.Parse(source)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case u.Scheme == "oem":
+		return resource.Fetcher{Logger: e.Logger, Client: &e.client, Context: e.ctx}.Fetch(*u, resource.FetchOptions{})
+	case u.Scheme == "" && filepath.IsAbs(source):
+		return ioutil.ReadFile(source)
+	default:
+		return nil, ErrLocalSource
+	}
+}
+
+// verifySignature checks that sig is a detached signature of data made by
+// one of the trusted signing keys, using gpgv.
+func (e *Engine) verifySignature(data []byte, sig types.ConfigSignature) error {
+	signature := []byte(sig.Inline)
+	if sig.Inline == "" {
+		var err error
+		fetcher := resource.Fetcher{Logger: e.Logger, Client: &e.client, Context: e.ctx}
+		if signature, err = fetcher.Fetch(url.URL(sig.Source), resource.FetchOptions{}); err != nil {
+			return fmt.Errorf("failed to fetch signature %q: %v", sig.Source.String(), err)
+		}
+	}
+	keys, err := e.signingKeys()
+	if err != nil {
+		return err
+	}
+	if len(keys) == 0 {
+		return ErrNoSigningKeys
+	}
+	if err := tool.Require(tool.Gpgv); err != nil {
+		return err
+	}
+
+	dir, err := ioutil.TempDir("", "ignition-gpgv")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	// gpgv only consults the keyrings given, not any of the user's.
+	args := []string{"--homedir", dir}
+	for i, key := range keys {
+		// gpgv reads binary keyrings only.
+		if key, err = dearmor(key); err != nil {
+			return fmt.Errorf("failed to read signing key: %v", err)
+		}
+		keyring := filepath.Join(dir, fmt.Sprintf("key-%d.gpg", i))
+		if err := ioutil.WriteFile(keyring, key, 0600); err != nil {
+			return err
+		}
+		args = append(args, "--keyring", keyring)
+	}
+	sigPath, dataPath := filepath.Join(dir, "config.sig"), filepath.Join(dir, "config")
+	if err := ioutil.WriteFile(sigPath, signature, 0600); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(dataPath, data, 0600); err != nil {
+		return err
+	}
+
+	e.Logger.Debug("verifying signature with %d trusted keys", len(keys))
+	if out, err := tool.Gpgv.Command(append(args, sigPath, dataPath)...).CombinedOutput(); err != nil {
+		return fmt.Errorf("bad signature: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// requireSignatures reports whether every config fetched must be signed,
+// which it must be when any signing keys are trusted, unless
+// e.AllowUnsignedConfigs is set.
+func (e *Engine) requireSignatures() (bool, error) {
+	if e.AllowUnsignedConfigs {
+		return false, nil
+	}
+	keys, err := e.signingKeys()
+	if err != nil {
+		return false, err
+	}
+	return len(keys) != 0, nil
+}
+
+// verifyProviderConfig checks the config fetched from the provider at u
+// against the detached signature at u with ".sig" appended.
+func (e *Engine) verifyProviderConfig(u url.URL, data []byte) error {
+	sig := u
+	sig.Path += ".sig"
+	sig.RawPath = ""
+	if err := e.verifySignature(data, types.ConfigSignature{Source: types.Url(sig)}); err != nil {
+		return fmt.Errorf("failed to verify %q: %v", u.String(), err)
+	}
+	e.Logger.Info("verified signature of %q", u.String())
+	return nil
+}
+
+// checkUnsigned returns ErrUnsignedContents unless cfg, which isn't signed,
+// only points at other configs, which must be, so that none of what it
+// applies rests on the unsigned config.
+func checkUnsigned(cfg types.Config) error {
+	pointer := types.Config{Ignition: types.Ignition{
+		Version:  cfg.Ignition.Version,
+		Config:   cfg.Ignition.Config,
+		Timeouts: cfg.Ignition.Timeouts,
+	}}
+	if !reflect.DeepEqual(cfg, pointer) {
+		return ErrUnsignedContents
+	}
+	return nil
+}
+
+// dearmor returns the binary form of key, which may be ASCII-armored, as
+// written by gpg --armor --export.
+func dearmor(key []byte) ([]byte, error) {
+	if !bytes.HasPrefix(bytes.TrimSpace(key), []byte("-----BEGIN PGP")) {
+		return key, nil
+	}
+	// Armor headers, if any, follow the BEGIN line up to a blank line, and
+	// the checksum line, starting with "=", follows the base64 body.
+	const (
+		beforeArmor = iota
+		inHeaders
+		inBody
+	)
+	var body bytes.Buffer
+	state := beforeArmor
+	scanner := bufio.NewScanner(bytes.NewReader(key))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case state == beforeArmor:
+			if strings.HasPrefix(line, "-----BEGIN PGP") {
+				state = inHeaders
+			}
+		case strings.HasPrefix(line, "-----END PGP"):
+			return decodeArmor(body.String())
+		case state == inHeaders && line == "":
+			state = inBody
+		case state == inHeaders && strings.Contains(line, ": "):
+		case state == inHeaders:
+			state = inBody
+			body.WriteString(line)
+		case strings.HasPrefix(line, "="):
+		default:
+			body.WriteString(line)
+		}
+	}
+	return nil, ErrBadArmor
+}
+
+func decodeArmor(body string) ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(body)
+	if err != nil {
+		return nil, ErrBadArmor
+	}
+	return data, nil
+}
+
+// signingKeys reads the keys in e.SigningKeysDir, in name order, followed by
+// those at e.SigningKeys.
+func (e *Engine) signingKeys() ([][]byte, error) {
+	var keys [][]byte
+	if e.SigningKeysDir != "" {
+		entries, err := ioutil.ReadDir(e.SigningKeysDir)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to read signing keys: %v", err)
+		}
+		for _, entry := range entries {
+			if !entry.Mode().IsRegular() {
+				continue
+			}
+			key, err := ioutil.ReadFile(filepath.Join(e.SigningKeysDir, entry.Name()))
+			if err != nil {
+				return nil, fmt.Errorf("failed to read signing key: %v", err)
+			}
+			keys = append(keys, key)
+		}
+	}
+	for _, source := range e.SigningKeys {
+		key, err := e.readLocal(source)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read signing key %q: %v", source, err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
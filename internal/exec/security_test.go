@@ -0,0 +1,196 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"io/ioutil"
+	"os"
+	osexec "os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/coreos/ignition/config/types"
+	"github.com/coreos/ignition/internal/log"
+)
+
+func TestVerifySignature(t *testing.T) {
+	type in struct {
+		data    string
+		keysDir string
+	}
+	type out struct {
+		ok  bool
+		err error
+	}
+
+	if _, err := osexec.LookPath("gpg"); err != nil {
+		t.Skipf("signing test configs needs gpg: %v", err)
+	}
+	dir, err := ioutil.TempDir("", "ignition-signature")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Sign the config with a throwaway key, exported to the keys dir.
+	home, keysDir, armoredDir := filepath.Join(dir, "gnupg"), filepath.Join(dir, "keys.d"), filepath.Join(dir, "armored.d")
+	for _, d := range []string{home, keysDir, armoredDir} {
+		if err := os.Mkdir(d, 0700); err != nil {
+			t.Fatalf("failed to create %s: %v", d, err)
+		}
+	}
+	config := `{"ignition": {"version": "2.1.0-experimental"}}`
+	configPath := filepath.Join(dir, "config.ign")
+	if err := ioutil.WriteFile(configPath, []byte(config), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	gpg := func(args ...string) []byte {
+		out, err := osexec.Command("gpg", append([]string{"--homedir", home, "--batch", "--passphrase", "", "--pinentry-mode", "loopback"}, args...)...).Output()
+		if err != nil {
+			t.Fatalf("gpg %v failed: %v", args, err)
+		}
+		return out
+	}
+	defer osexec.Command("gpgconf", "--homedir", home, "--kill", "gpg-agent").Run()
+	gpg("--quick-gen-key", "Ignition Test <test@example.com>", "ed25519", "sign", "never")
+	if err := ioutil.WriteFile(filepath.Join(keysDir, "test.gpg"), gpg("--export"), 0644); err != nil {
+		t.Fatalf("failed to write key: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(armoredDir, "test.asc"), gpg("--armor", "--export"), 0644); err != nil {
+		t.Fatalf("failed to write armored key: %v", err)
+	}
+	signature := string(gpg("--armor", "--output", "-", "--detach-sign", configPath))
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{data: config, keysDir: keysDir},
+			out: out{ok: true},
+		},
+		{
+			in:  in{data: config, keysDir: armoredDir},
+			out: out{ok: true},
+		},
+		{
+			in:  in{data: config + " ", keysDir: keysDir},
+			out: out{ok: false},
+		},
+		{
+			in:  in{data: config},
+			out: out{ok: false, err: ErrNoSigningKeys},
+		},
+	}
+
+	logger := log.New()
+	for i, test := range tests {
+		e := Engine{Logger: &logger, SigningKeysDir: filepath.Join(dir, "none")}
+		if test.in.keysDir != "" {
+			e.SigningKeysDir = test.in.keysDir
+		}
+		err := e.verifySignature([]byte(test.in.data), types.ConfigSignature{Inline: signature})
+		if (err == nil) != test.out.ok {
+			t.Errorf("#%d: bad result: want ok %t, got %v", i, test.out.ok, err)
+		}
+		if test.out.err != nil && !reflect.DeepEqual(test.out.err, err) {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.out.err, err)
+		}
+	}
+}
+
+func TestDearmor(t *testing.T) {
+	type in struct {
+		key string
+	}
+	type out struct {
+		key string
+		err error
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{key: "\x99binary"},
+			out: out{key: "\x99binary"},
+		},
+		{
+			in:  in{key: "-----BEGIN PGP PUBLIC KEY BLOCK-----\nComment: test\n\naGVsbG8s\nIHdvcmxk\n=abcd\n-----END PGP PUBLIC KEY BLOCK-----\n"},
+			out: out{key: "hello, world"},
+		},
+		{
+			in:  in{key: "-----BEGIN PGP PUBLIC KEY BLOCK-----\naGVsbG8sIHdvcmxk\n-----END PGP PUBLIC KEY BLOCK-----\n"},
+			out: out{key: "hello, world"},
+		},
+		{
+			in:  in{key: "-----BEGIN PGP PUBLIC KEY BLOCK-----\n\naGVsbG8s\n"},
+			out: out{err: ErrBadArmor},
+		},
+		{
+			in:  in{key: "-----BEGIN PGP PUBLIC KEY BLOCK-----\n\n!!!!\n-----END PGP PUBLIC KEY BLOCK-----\n"},
+			out: out{err: ErrBadArmor},
+		},
+	}
+
+	for i, test := range tests {
+		key, err := dearmor([]byte(test.in.key))
+		if err != test.out.err {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.out.err, err)
+		}
+		if string(key) != test.out.key {
+			t.Errorf("#%d: bad key: want %q, got %q", i, test.out.key, key)
+		}
+	}
+}
+
+func TestCheckUnsigned(t *testing.T) {
+	type in struct {
+		cfg types.Config
+	}
+	type out struct {
+		err error
+	}
+
+	replace := types.ConfigReference{Signature: &types.ConfigSignature{Inline: "sig"}}
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{cfg: types.Config{Ignition: types.Ignition{Version: types.IgnitionVersion(types.MaxVersion), Config: types.IgnitionConfig{Replace: &replace}}}},
+			out: out{},
+		},
+		{
+			in: in{cfg: types.Config{
+				Ignition: types.Ignition{Config: types.IgnitionConfig{Replace: &replace}},
+				Storage:  types.Storage{Files: []types.File{{Node: types.Node{Path: "/etc/motd"}}}},
+			}},
+			out: out{err: ErrUnsignedContents},
+		},
+		{
+			in:  in{cfg: types.Config{Ignition: types.Ignition{Auth: []types.UrlAuth{{Prefix: "https://example.com/"}}}}},
+			out: out{err: ErrUnsignedContents},
+		},
+	}
+
+	for i, test := range tests {
+		if err := checkUnsigned(test.in.cfg); err != test.out.err {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.out.err, err)
+		}
+	}
+}
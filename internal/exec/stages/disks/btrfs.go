@@ -0,0 +1,57 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package disks
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/coreos/ignition/config/types"
+	"github.com/coreos/ignition/internal/exec/util"
+	"github.com/coreos/ignition/internal/tool"
+)
+
+// createSubvolumes creates the subvolumes declared for a freshly created
+// btrfs filesystem, in order, and selects the default subvolume if one is
+// marked.
+func (s stage) createSubvolumes(fs types.FilesystemMount) error {
+	dev := util.DeviceAlias(string(fs.Device))
+	return s.withMount(dev, "btrfs", "", func(mnt string) error {
+		return s.createSubvolumesAt(dev, mnt, fs.Create.Subvolumes)
+	})
+}
+
+func (s stage) createSubvolumesAt(dev, mnt string, subvolumes []types.BtrfsSubvolume) error {
+	for _, sv := range subvolumes {
+		path := filepath.Join(mnt, sv.Name)
+		if err := s.Logger.LogCmd(
+			tool.Btrfs.Command("subvolume", "create", path),
+			"creating subvolume %q on %q", sv.Name, dev,
+		); err != nil {
+			return fmt.Errorf("failed to create subvolume %q: %v", sv.Name, err)
+		}
+		if !sv.Default {
+			continue
+		}
+		if err := s.Logger.LogCmd(
+			tool.Btrfs.Command("subvolume", "set-default", path),
+			"setting subvolume %q as default on %q", sv.Name, dev,
+		); err != nil {
+			return fmt.Errorf("failed to set default subvolume %q: %v", sv.Name, err)
+		}
+	}
+
+	return nil
+}
@@ -20,7 +20,9 @@ package disks
 
 import (
 	"fmt"
-	"os/exec"
+	"io/ioutil"
+	"os"
+	"syscall"
 
 	"github.com/coreos/ignition/config/types"
 	"github.com/coreos/ignition/internal/exec/stages"
@@ -29,6 +31,9 @@ import (
 	"github.com/coreos/ignition/internal/resource"
 	"github.com/coreos/ignition/internal/sgdisk"
 	"github.com/coreos/ignition/internal/systemd"
+	"github.com/coreos/ignition/internal/tool"
+
+	"golang.org/x/net/context"
 )
 
 const (
@@ -41,11 +46,14 @@ func init() {
 
 type creator struct{}
 
-func (creator) Create(logger *log.Logger, client *resource.HttpClient, root string) stages.Stage {
+func (creator) Create(logger *log.Logger, client *resource.HttpClient, ctx context.Context, root, stateDir, resultFile, platform string) stages.Stage {
 	return &stage{
 		Util: util.Util{
-			DestDir: root,
-			Logger:  logger,
+			DestDir:  root,
+			StateDir: stateDir,
+			Platform: platform,
+			Context:  ctx,
+			Logger:   logger,
 		},
 		client: client,
 	}
@@ -65,28 +73,36 @@ func (stage) Name() string {
 	return name
 }
 
-func (s stage) Run(config types.Config) bool {
-	if err := s.createPartitions(config); err != nil {
-		s.Logger.Crit("create partitions failed: %v", err)
-		return false
-	}
-
-	if err := s.createRaids(config); err != nil {
-		s.Logger.Crit("failed to create raids: %v", err)
-		return false
+func (s stage) Run(config types.Config) error {
+	if err := tool.Require(requiredTools(config)...); err != nil {
+		return err
 	}
-
-	if err := s.createFilesystems(config); err != nil {
-		s.Logger.Crit("failed to create filesystems: %v", err)
-		return false
+	// Keys left by an earlier run may not be the ones this config names.
+	if err := s.ClearLuksKeyCache(); err != nil {
+		return fmt.Errorf("failed to remove luks keys fetched earlier: %v", err)
 	}
 
-	return true
+	return s.RunSteps(config, []util.Step{
+		{Name: "attach zfcp devices", Run: s.attachZfcpDevices},
+		{Name: "create partitions", Run: s.createPartitions},
+		{Name: "grow root", Run: s.growRoot},
+		{Name: "create raids", Run: s.createRaids},
+		{Name: "create luks volumes", Run: s.createLuks},
+		{Name: "write images", Run: s.writeImages},
+		{Name: "create filesystems", Run: s.createFilesystems},
+		{Name: "configure EFI boot entries", Run: s.configureEfiBoot},
+	})
 }
 
 // waitOnDevices waits for the devices enumerated in devs as a logged operation
-// using ctxt for the logging and systemd unit identity.
+// using ctxt for the logging and systemd unit identity. udev is first let
+// settle, so that the /dev/disk/by-* links to devices created or changed by
+// earlier steps point at them before they are resolved.
 func (s stage) waitOnDevices(devs []string, ctxt string) error {
+	s.settleUdev()
+	if err := s.WaitForDevicePaths(devs, deviceTimeout); err != nil {
+		return fmt.Errorf("failed to wait on %s devs: %v", ctxt, err)
+	}
 	if err := s.LogOp(
 		func() error { return systemd.WaitOnDevices(devs, ctxt) },
 		"waiting for devices %v", devs,
@@ -140,37 +156,69 @@ func (s stage) createPartitions(config types.Config) error {
 		return err
 	}
 
-	for _, dev := range config.Storage.Disks {
-		devAlias := util.DeviceAlias(string(dev.Device))
+	disks := config.Storage.Disks
+	keys := make([]string, len(disks))
+	for i, disk := range disks {
+		keys[i] = deviceKey(util.DeviceAlias(string(disk.Device)))
+	}
+	return s.inParallel(keys, func(s stage, i int) error {
+		return s.partitionDisk(disks[i])
+	})
+}
 
-		err := s.Logger.LogOp(func() error {
-			op := sgdisk.Begin(s.Logger, devAlias)
-			if dev.WipeTable {
-				s.Logger.Info("wiping partition table requested on %q", devAlias)
-				op.WipeTable(true)
-			}
+// partitionDisk partitions the disk described by dev.
+func (s stage) partitionDisk(dev types.Disk) error {
+	devAlias := util.DeviceAlias(string(dev.Device))
+
+	return s.Logger.LogOp(func() error {
+		plan, err := s.planPartitions(dev, devAlias)
+		if err != nil {
+			return err
+		}
 
-			for _, part := range dev.Partitions {
-				op.CreatePartition(sgdisk.Partition{
-					Number:   part.Number,
-					Length:   uint64(part.Size),
-					Offset:   uint64(part.Start),
-					Label:    string(part.Label),
-					TypeGUID: string(part.TypeGUID),
-				})
+		op := sgdisk.Begin(s.Logger, devAlias)
+		op.SetAlignment(types.MiB / plan.sectorSize)
+		if dev.WipeTable {
+			if err := s.checkWipeTable(dev, devAlias); err != nil {
+				return err
 			}
+			s.Logger.Info("wiping partition table requested on %q", devAlias)
+			op.WipeTable(true)
+		}
+
+		if len(plan.resized) != 0 {
+			op.MoveBackupHeader(true)
+		}
+		for _, n := range plan.deletions {
+			op.DeletePartition(n)
+		}
+		for _, n := range plan.resized {
+			op.DeletePartition(n)
+		}
+		for _, part := range plan.parts {
+			op.CreatePartition(part)
+		}
+
+		if err := op.Commit(); err != nil {
+			return fmt.Errorf("commit failure: %v", err)
+		}
 
-			if err := op.Commit(); err != nil {
-				return fmt.Errorf("commit failure: %v", err)
+		for _, n := range plan.resized {
+			if err := s.updatePartition(devAlias, n); err != nil {
+				return err
 			}
-			return nil
-		}, "partitioning %q", devAlias)
-		if err != nil {
-			return err
 		}
-	}
 
-	return nil
+		if plan.bootCode != nil {
+			if err := s.Logger.LogOp(
+				func() error { return writeBootCode(devAlias, plan.bootCode) },
+				"reinstalling stage1 bootloader on %q", devAlias,
+			); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, "partitioning %q", devAlias)
 }
 
 // createRaids creates the raid arrays described in config.Storage.Arrays.
@@ -181,10 +229,19 @@ func (s stage) createRaids(config types.Config) error {
 	s.Logger.PushPrefix("createRaids")
 	defer s.Logger.PopPrefix()
 
+	// Members which are arrays themselves only appear once those are
+	// created, so they are waited on just before the array built on them.
+	arrays, keys := orderRaids(config.Storage.Arrays)
+	produced := map[string]bool{}
+	for _, md := range arrays {
+		produced[raidDevice(md)] = true
+	}
 	devs := []string{}
-	for _, array := range config.Storage.Arrays {
+	for _, array := range arrays {
 		for _, dev := range array.Devices {
-			devs = append(devs, string(dev))
+			if !produced[string(dev)] {
+				devs = append(devs, string(dev))
+			}
 		}
 	}
 
@@ -192,31 +249,56 @@ func (s stage) createRaids(config types.Config) error {
 		return err
 	}
 
-	for _, md := range config.Storage.Arrays {
-		// FIXME(vc): this is utterly flummoxed by a preexisting md.Name, the magic of device-resident md metadata really interferes with us.
-		// It's as if what ignition really needs is to turn off automagic md probing/running before getting started.
-		args := []string{
-			"--create", md.Name,
-			"--force",
-			"--run",
-			"--level", md.Level,
-			"--raid-devices", fmt.Sprintf("%d", len(md.Devices)-md.Spares),
+	return s.inParallel(keys, func(s stage, i int) error {
+		nested := []string{}
+		for _, dev := range arrays[i].Devices {
+			if produced[string(dev)] {
+				nested = append(nested, string(dev))
+			}
 		}
-
-		if md.Spares > 0 {
-			args = append(args, "--spare-devices", fmt.Sprintf("%d", md.Spares))
+		if err := s.waitOnDevicesAndCreateAliases(nested, "raids"); err != nil {
+			return err
 		}
+		return s.createRaid(arrays[i])
+	})
+}
 
-		for _, dev := range md.Devices {
-			args = append(args, util.DeviceAlias(string(dev)))
-		}
+// createRaid creates the raid array described by md, or assembles it if it
+// already exists.
+func (s stage) createRaid(md types.Raid) error {
+	if !md.WipeArray && s.matchesExistingArray(md) {
+		return s.assembleArray(md)
+	}
 
-		if err := s.Logger.LogCmd(
-			exec.Command("/sbin/mdadm", args...),
-			"creating %q", md.Name,
-		); err != nil {
-			return fmt.Errorf("mdadm failed: %v", err)
-		}
+	// FIXME(vc): this is utterly flummoxed by a preexisting md.Name, the magic of device-resident md metadata really interferes with us.
+	// It's as if what ignition really needs is to turn off automagic md probing/running before getting started.
+	args := []string{
+		"--create", md.Name,
+		"--force",
+		"--run",
+		"--level", md.Level,
+		"--raid-devices", fmt.Sprintf("%d", len(md.Devices)-md.Spares),
+	}
+
+	if md.Spares > 0 {
+		args = append(args, "--spare-devices", fmt.Sprintf("%d", md.Spares))
+	}
+
+	if md.Uuid != nil {
+		args = append(args, "--uuid", *md.Uuid)
+	}
+
+	args = append(args, md.Options...)
+
+	for _, dev := range md.Devices {
+		args = append(args, util.DeviceAlias(string(dev)))
+	}
+
+	if err := s.Logger.LogCmd(
+		tool.Mdadm.Command(args...),
+		"creating %q", md.Name,
+	); err != nil {
+		return fmt.Errorf("mdadm failed: %v", err)
 	}
 
 	return nil
@@ -246,52 +328,117 @@ func (s stage) createFilesystems(config types.Config) error {
 		return err
 	}
 
-	for _, fs := range fss {
-		if err := s.createFilesystem(fs); err != nil {
-			return err
-		}
+	keys := make([]string, len(fss))
+	for i, fs := range fss {
+		keys[i] = deviceKey(util.DeviceAlias(string(fs.Device)))
 	}
-
-	return nil
+	return s.inParallel(keys, func(s stage, i int) error {
+		return s.createFilesystem(fss[i])
+	})
 }
 
 func (s stage) createFilesystem(fs types.FilesystemMount) error {
 	if fs.Create == nil {
-		return nil
+		return s.tagFilesystem(fs)
+	}
+	devAlias := util.DeviceAlias(string(fs.Device))
+	if !fs.Create.Force {
+		info := s.probeDevice(devAlias)
+		if s.matchesExisting(info, string(fs.Device), string(fs.Format), fs.Label, fs.Uuid) {
+			s.Logger.Info("reusing existing %q filesystem on %q", fs.Format, fs.Device)
+			return nil
+		}
+		if err := s.checkFormat(fs.Device, info, devAlias, "create.force"); err != nil {
+			return err
+		}
 	}
 
-	mkfs := ""
-	args := []string(fs.Create.Options)
+	mkfs := tool.Tool{}
+	// Copy the options so appending below never writes into the config.
+	args := append([]string{}, fs.Create.Options...)
+	discard := fs.Create.Discard
 	switch fs.Format {
 	case "btrfs":
-		mkfs = "/sbin/mkfs.btrfs"
+		mkfs = tool.MkfsBtrfs
 		if fs.Create.Force {
 			args = append(args, "--force")
 		}
+		if discard != nil && !*discard {
+			args = append(args, "--nodiscard")
+		}
 	case "ext4":
-		mkfs = "/sbin/mkfs.ext4"
+		mkfs = tool.MkfsExt4
 		args = append(args, "-p")
 		if fs.Create.Force {
 			args = append(args, "-F")
 		}
+		if discard != nil {
+			if *discard {
+				args = append(args, "-E", "discard")
+			} else {
+				args = append(args, "-E", "nodiscard")
+			}
+		}
 	case "xfs":
-		mkfs = "/sbin/mkfs.xfs"
+		mkfs = tool.MkfsXfs
 		if fs.Create.Force {
 			args = append(args, "-f")
 		}
+		if discard != nil && !*discard {
+			args = append(args, "-K")
+		}
+	case "vfat":
+		mkfs = tool.MkfsVfat
+	case "swap":
+		mkfs = tool.Mkswap
+		if fs.Create.Force {
+			args = append(args, "--force")
+		}
 	default:
 		return fmt.Errorf("unsupported filesystem format: %q", fs.Format)
 	}
 
-	devAlias := util.DeviceAlias(string(fs.Device))
 	args = append(args, devAlias)
 	if err := s.Logger.LogCmd(
-		exec.Command(mkfs, args...),
+		mkfs.Command(args...),
 		"creating %q filesystem on %q",
 		fs.Format, devAlias,
 	); err != nil {
 		return fmt.Errorf("mkfs failed: %v", err)
 	}
 
-	return nil
+	if len(fs.Create.Subvolumes) > 0 {
+		if err := s.createSubvolumes(fs); err != nil {
+			return err
+		}
+	}
+	if len(fs.Create.Projects) > 0 {
+		if err := s.createProjects(fs); err != nil {
+			return err
+		}
+	}
+
+	return s.tagFilesystem(fs)
+}
+
+// withMount temporarily mounts dev and calls f with the mount point.
+func (s stage) withMount(dev, format, options string, f func(mnt string) error) error {
+	mnt, err := ioutil.TempDir("", "ignition-disks")
+	if err != nil {
+		return fmt.Errorf("failed to create temp directory: %v", err)
+	}
+	defer os.Remove(mnt)
+
+	if err := s.Logger.LogOp(
+		func() error { return syscall.Mount(dev, mnt, format, 0, options) },
+		"mounting %q at %q", dev, mnt,
+	); err != nil {
+		return err
+	}
+	defer s.Logger.LogOp(
+		func() error { return syscall.Unmount(mnt, 0) },
+		"unmounting %q at %q", dev, mnt,
+	)
+
+	return f(mnt)
 }
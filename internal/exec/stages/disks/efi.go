@@ -0,0 +1,152 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package disks
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/coreos/ignition/config/types"
+	"github.com/coreos/ignition/internal/exec/util"
+	"github.com/coreos/ignition/internal/tool"
+)
+
+const (
+	efiVarsPath = "/sys/firmware/efi/efivars"
+)
+
+var (
+	efiBootEntryRegexp = regexp.MustCompile(`^Boot([[:xdigit:]]{4})\*?\s+(.*?)(\t.*)?$`)
+)
+
+// efiBootEntry is a boot entry currently registered with the firmware.
+type efiBootEntry struct {
+	num   string
+	label string
+}
+
+// configureEfiBoot creates, removes, and orders the EFI boot entries
+// described in config.Bootloader.Efi.
+func (s stage) configureEfiBoot(config types.Config) error {
+	efi := config.Bootloader.Efi
+	if len(efi.Entries) == 0 && len(efi.BootOrder) == 0 {
+		return nil
+	}
+	s.Logger.PushPrefix("configureEfiBoot")
+	defer s.Logger.PopPrefix()
+
+	if _, err := os.Stat(efiVarsPath); err != nil {
+		s.Logger.Warning("system was not booted via UEFI, ignoring EFI boot entries: %v", err)
+		return nil
+	}
+
+	devs := []string{}
+	for _, e := range efi.Entries {
+		if e.Exists() {
+			devs = append(devs, string(*e.Device))
+		}
+	}
+	if err := s.waitOnDevicesAndCreateAliases(devs, "efi"); err != nil {
+		return err
+	}
+
+	for _, e := range efi.Entries {
+		existing, err := s.efiBootEntries()
+		if err != nil {
+			return err
+		}
+
+		// Entries are replaced rather than updated in place, since the
+		// firmware identifies them by number, not by label.
+		for _, old := range existing {
+			if old.label != e.Label {
+				continue
+			}
+			if err := s.Logger.LogCmd(
+				tool.Efibootmgr.Command("--bootnum", old.num, "--delete-bootnum"),
+				"removing EFI boot entry %s (%q)", old.num, old.label,
+			); err != nil {
+				return err
+			}
+		}
+
+		if !e.Exists() {
+			continue
+		}
+		if err := s.Logger.LogCmd(
+			tool.Efibootmgr.Command(
+				"--create",
+				"--disk", util.DeviceAlias(string(*e.Device)),
+				"--part", strconv.Itoa(e.Partition),
+				"--label", e.Label,
+				"--loader", e.Loader,
+			),
+			"creating EFI boot entry %q", e.Label,
+		); err != nil {
+			return err
+		}
+	}
+
+	if len(efi.BootOrder) == 0 {
+		return nil
+	}
+
+	existing, err := s.efiBootEntries()
+	if err != nil {
+		return err
+	}
+	order := []string{}
+	for _, label := range efi.BootOrder {
+		found := false
+		for _, e := range existing {
+			if e.label == label {
+				order = append(order, e.num)
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("boot order references nonexistent entry %q", label)
+		}
+	}
+
+	return s.Logger.LogCmd(
+		tool.Efibootmgr.Command("--bootorder", strings.Join(order, ",")),
+		"setting EFI boot order to %v", efi.BootOrder,
+	)
+}
+
+// efiBootEntries returns the boot entries currently known to the firmware.
+func (s stage) efiBootEntries() ([]efiBootEntry, error) {
+	out, err := tool.Efibootmgr.Output(s.Logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list EFI boot entries: %v", err)
+	}
+
+	entries := []efiBootEntry{}
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		if m := efiBootEntryRegexp.FindStringSubmatch(scanner.Text()); m != nil {
+			entries = append(entries, efiBootEntry{num: m[1], label: strings.TrimSpace(m[2])})
+		}
+	}
+	s.Logger.Debug("found EFI boot entries: %v", entries)
+
+	return entries, nil
+}
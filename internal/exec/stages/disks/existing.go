@@ -0,0 +1,133 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package disks
+
+import (
+	"fmt"
+
+	"github.com/coreos/ignition/config/types"
+	"github.com/coreos/ignition/internal/sgdisk"
+)
+
+// planExisting updates plan for the declared partitions which act on the
+// existing partition with the same number on devAlias, leaving the rest of
+// the table intact. Partitions which should not exist are deleted, and those
+// whose entry is to be wiped are deleted and created again. Partitions
+// marked for resizing are recreated at their existing offset with their
+// declared size, keeping their label, type, and GUID unless the config sets
+// them; those already of the declared size are left alone. Shrinking is
+// refused, since the filesystem within would be truncated. Partitions which
+// don't exist yet are created as usual.
+func (s stage) planExisting(plan *partitionPlan, disk types.Disk, devAlias string) error {
+	existing, err := sgdisk.Partitions(s.Logger, devAlias)
+	if err != nil {
+		return err
+	}
+	find := func(number int) *sgdisk.Partition {
+		for i, e := range existing {
+			if e.Number == number {
+				return &existing[i]
+			}
+		}
+		return nil
+	}
+
+	for _, d := range disk.Partitions {
+		if d.Exists() && !d.WipePartitionEntry {
+			continue
+		}
+		if find(d.Number) == nil {
+			continue
+		}
+		if d.Exists() {
+			s.Logger.Info("wiping entry of partition %d on %q", d.Number, devAlias)
+		} else {
+			s.Logger.Info("deleting partition %d on %q", d.Number, devAlias)
+		}
+		plan.deletions = append(plan.deletions, d.Number)
+	}
+
+	parts := plan.parts[:0]
+	for _, p := range plan.parts {
+		if p.Number == 0 || !declaresResizeOf(disk, p.Number) {
+			parts = append(parts, p)
+			continue
+		}
+
+		old := find(p.Number)
+		if old == nil {
+			s.Logger.Info("partition %d on %q does not exist, creating it", p.Number, devAlias)
+			parts = append(parts, p)
+			continue
+		}
+
+		if p.Offset != 0 && p.Offset != old.Offset {
+			return fmt.Errorf("cannot resize partition %d on %q: declared start %d does not match existing start %d", p.Number, devAlias, p.Offset, old.Offset)
+		}
+		if p.Length != 0 && p.Length < old.Length {
+			return fmt.Errorf("refusing to shrink partition %d on %q from %d to %d sectors", p.Number, devAlias, old.Length, p.Length)
+		}
+		if p.Length == old.Length {
+			s.Logger.Info("partition %d on %q is already %d sectors, not resizing", p.Number, devAlias, old.Length)
+			continue
+		}
+
+		p.Offset = old.Offset
+		if p.Label == "" {
+			p.Label = old.Label
+		}
+		if p.TypeGUID == "" {
+			p.TypeGUID = old.TypeGUID
+		}
+		if p.GUID == "" {
+			p.GUID = old.GUID
+		}
+		s.Logger.Info("resizing partition %d on %q", p.Number, devAlias)
+		plan.resized = append(plan.resized, p.Number)
+		parts = append(parts, p)
+	}
+	plan.parts = parts
+
+	return nil
+}
+
+// modifiesExisting returns whether any of the declared partitions acts on an
+// existing partition.
+func modifiesExisting(disk types.Disk) bool {
+	for _, p := range disk.Partitions {
+		if p.Resize || p.WipePartitionEntry || !p.Exists() {
+			return true
+		}
+	}
+	return false
+}
+
+func declaresResize(disk types.Disk) bool {
+	for _, p := range disk.Partitions {
+		if p.Resize {
+			return true
+		}
+	}
+	return false
+}
+
+func declaresResizeOf(disk types.Disk, number int) bool {
+	for _, p := range disk.Partitions {
+		if p.Number == number && p.Resize {
+			return true
+		}
+	}
+	return false
+}
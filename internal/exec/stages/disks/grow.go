@@ -0,0 +1,153 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package disks
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/coreos/ignition/config/types"
+	"github.com/coreos/ignition/internal/exec/util"
+	"github.com/coreos/ignition/internal/sgdisk"
+	"github.com/coreos/ignition/internal/tool"
+)
+
+// growRoot enlarges the partition described in config.Storage.GrowRoot to
+// fill the rest of its disk and then grows the filesystem on it to match.
+func (s stage) growRoot(config types.Config) error {
+	grow := config.Storage.GrowRoot
+	if grow == nil {
+		return nil
+	}
+	s.Logger.PushPrefix("growRoot")
+	defer s.Logger.PopPrefix()
+
+	disk := string(grow.Device)
+	if err := s.waitOnDevicesAndCreateAliases([]string{disk}, "growroot"); err != nil {
+		return err
+	}
+	devAlias := util.DeviceAlias(disk)
+
+	parts, err := sgdisk.Partitions(s.Logger, devAlias)
+	if err != nil {
+		return err
+	}
+	var part *sgdisk.Partition
+	for i, p := range parts {
+		if p.Number == grow.Partition {
+			part = &parts[i]
+		}
+	}
+	if part == nil {
+		return fmt.Errorf("partition %d not found on %q", grow.Partition, disk)
+	}
+	for _, p := range parts {
+		if p.Offset > part.Offset {
+			return fmt.Errorf("partition %d is not the last partition on %q", grow.Partition, disk)
+		}
+	}
+
+	// Recreate the partition at the same offset with the same identity,
+	// letting sgdisk extend it to the end of the disk.
+	op := sgdisk.Begin(s.Logger, devAlias)
+	op.MoveBackupHeader(true)
+	op.DeletePartition(part.Number)
+	op.CreatePartition(sgdisk.Partition{
+		Number:   part.Number,
+		Offset:   part.Offset,
+		Length:   0,
+		Label:    part.Label,
+		TypeGUID: part.TypeGUID,
+		GUID:     part.GUID,
+	})
+	if err := op.Commit(); err != nil {
+		return fmt.Errorf("commit failure: %v", err)
+	}
+
+	return s.updatePartition(devAlias, part.Number)
+}
+
+// updatePartition tells the kernel that partition n on the disk at devAlias
+// has been enlarged and grows the filesystem on it to match.
+func (s stage) updatePartition(devAlias string, n int) error {
+	// The partition may be in use, in which case the kernel refuses to
+	// reread the whole table; update just this partition instead.
+	if err := s.Logger.LogCmd(
+		tool.Partx.Command("--update", "--nr", fmt.Sprint(n), devAlias),
+		"updating kernel partition table for %q", devAlias,
+	); err != nil {
+		return fmt.Errorf("failed to update partition %d: %v", n, err)
+	}
+
+	dev, err := partitionDevice(devAlias, n)
+	if err != nil {
+		return err
+	}
+	if err := s.waitOnDevices([]string{dev}, "growpartition"); err != nil {
+		return err
+	}
+
+	return s.growFilesystem(dev)
+}
+
+// growFilesystem grows the filesystem on dev, if any, to fill the device.
+func (s stage) growFilesystem(dev string) error {
+	out, err := tool.Blkid.Output(s.Logger, "-o", "value", "-s", "TYPE", dev)
+	if err != nil {
+		s.Logger.Info("no filesystem found on %q, skipping filesystem growth", dev)
+		return nil
+	}
+	format := strings.TrimSpace(string(out))
+
+	switch format {
+	case "ext4":
+		return s.Logger.LogCmd(
+			tool.Resize2fs.Command(dev),
+			"growing ext4 filesystem on %q", dev,
+		)
+	case "xfs":
+		return s.withMount(dev, format, "", func(mnt string) error {
+			return s.Logger.LogCmd(
+				tool.XfsGrowfs.Command(mnt),
+				"growing xfs filesystem on %q", dev,
+			)
+		})
+	case "btrfs":
+		return s.withMount(dev, format, "", func(mnt string) error {
+			return s.Logger.LogCmd(
+				tool.Btrfs.Command("filesystem", "resize", "max", mnt),
+				"growing btrfs filesystem on %q", dev,
+			)
+		})
+	default:
+		return fmt.Errorf("growing %q filesystems is not supported", format)
+	}
+}
+
+// partitionDevice returns the path of partition n of the disk at dev,
+// following the kernel's naming convention of inserting a "p" when the disk
+// name ends in a digit (e.g. /dev/nvme0n1p1 versus /dev/sda1).
+func partitionDevice(dev string, n int) (string, error) {
+	disk, err := filepath.EvalSymlinks(dev)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %q: %v", dev, err)
+	}
+	if r := rune(disk[len(disk)-1]); unicode.IsDigit(r) {
+		disk += "p"
+	}
+	return fmt.Sprintf("%s%d", disk, n), nil
+}
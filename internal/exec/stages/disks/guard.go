@@ -0,0 +1,164 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package disks
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/coreos/ignition/config/types"
+)
+
+const (
+	mountInfoPath  = "/proc/self/mountinfo"
+	sysDevBlockDir = "/sys/dev/block"
+)
+
+// systemMountPoints are the mount points of the running system's own
+// filesystems, whose devices are never wiped or formatted unless forced: the
+// root and /usr of the initramfs and of the real root beneath /sysroot, and
+// those of the medium Ignition booted from.
+var systemMountPoints = []string{
+	"/",
+	"/usr",
+	"/sysroot",
+	"/sysroot/usr",
+	"/boot",
+	"/boot/efi",
+	"/sysroot/boot",
+	"/sysroot/boot/efi",
+	"/run/initramfs/live",
+	"/run/initramfs/isoscan",
+}
+
+// checkWipeTable refuses to wipe the partition table of disk, unless forced,
+// if the disk holds the running system or a partition table Ignition would
+// not have written, so that a config copied from another machine cannot
+// destroy the wrong disk.
+func (s stage) checkWipeTable(disk types.Disk, devAlias string) error {
+	if disk.Force {
+		return nil
+	}
+	mp, ok, err := s.systemDevice(devAlias)
+	if err != nil {
+		return fmt.Errorf("refusing to wipe the partition table of %q, since it can't be checked against the running system: %v; set force to wipe it anyway", disk.Device, err)
+	}
+	if ok {
+		return fmt.Errorf("refusing to wipe the partition table of %q, which holds the running system's %s; set force to wipe it anyway", disk.Device, mp)
+	}
+	if pt := s.probeDevice(devAlias)["PTTYPE"]; pt != "" && pt != "gpt" {
+		return fmt.Errorf("refusing to wipe the foreign %s partition table of %q; set force to wipe it anyway", pt, disk.Device)
+	}
+	return nil
+}
+
+// checkFormat refuses to format device, whose existing contents don't match
+// the config, if it holds the running system or, as info from probeDevice
+// shows, anything at all: a filesystem, a LUKS volume, or a partition table.
+// override names the setting which formats it anyway.
+func (s stage) checkFormat(device types.Path, info map[string]string, devAlias, override string) error {
+	mp, ok, err := s.systemDevice(devAlias)
+	if err != nil {
+		return fmt.Errorf("refusing to format %q, since it can't be checked against the running system: %v; set %s to format it anyway", device, err, override)
+	}
+	if ok {
+		return fmt.Errorf("refusing to format %q, which holds the running system's %s; set %s to format it anyway", device, mp, override)
+	}
+	if info["TYPE"] != "" {
+		return fmt.Errorf("refusing to format %q, which holds %s; set %s to replace it", device, info["TYPE"], override)
+	}
+	if info["PTTYPE"] != "" {
+		return fmt.Errorf("refusing to format %q, which holds a %s partition table; set %s to format it anyway", device, info["PTTYPE"], override)
+	}
+	return nil
+}
+
+// systemDevice returns the mount point of the running system's filesystem
+// which dev holds or is part of, if any. It fails, rather than reporting that
+// dev is safe to modify, if dev can't be resolved or the mounts of the
+// running system can't be read.
+func (s stage) systemDevice(dev string) (string, bool, error) {
+	path, err := filepath.EvalSymlinks(dev)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to resolve %q: %v", dev, err)
+	}
+	devs, err := systemDevices()
+	if err != nil {
+		return "", false, fmt.Errorf("failed to find the devices of the running system: %v", err)
+	}
+	mp, ok := devs[filepath.Base(path)]
+	return mp, ok, nil
+}
+
+// systemDevices returns the kernel names of the block devices beneath the
+// filesystems mounted at systemMountPoints, mapped to their mount point: the
+// devices mounted, those they are built from, and the disks holding any of
+// them.
+func systemDevices() (map[string]string, error) {
+	f, err := os.Open(mountInfoPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	devs := map[string]string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// Each line starts "<id> <parent id> <major>:<minor> <root> <mount point>".
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 5 || !isSystemMountPoint(fields[4]) {
+			continue
+		}
+		// Filesystems not backed by a block device, such as overlays,
+		// have no entry.
+		if path, err := filepath.EvalSymlinks(filepath.Join(sysDevBlockDir, fields[2])); err == nil {
+			addBlockDevice(devs, path, fields[4])
+		}
+	}
+	return devs, scanner.Err()
+}
+
+func isSystemMountPoint(path string) bool {
+	for _, mp := range systemMountPoints {
+		if path == mp {
+			return true
+		}
+	}
+	return false
+}
+
+// addBlockDevice adds the block device whose sysfs directory is path to devs,
+// along with its disk, if it is a partition, and the devices it is built
+// from, such as the members of an array or the device beneath a LUKS volume.
+func addBlockDevice(devs map[string]string, path, mountPoint string) {
+	name := filepath.Base(path)
+	if _, ok := devs[name]; ok {
+		return
+	}
+	devs[name] = mountPoint
+	if _, err := os.Stat(filepath.Join(path, "partition")); err == nil {
+		addBlockDevice(devs, filepath.Dir(path), mountPoint)
+	}
+	slaves, _ := ioutil.ReadDir(filepath.Join(path, "slaves"))
+	for _, slave := range slaves {
+		if p, err := filepath.EvalSymlinks(filepath.Join(path, "slaves", slave.Name())); err == nil {
+			addBlockDevice(devs, p, mountPoint)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package disks
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/coreos/ignition/config/types"
+	"github.com/coreos/ignition/internal/exec/util"
+	"github.com/coreos/ignition/internal/log"
+)
+
+// progressInterval is how often, in bytes written, progress is logged.
+const progressInterval = 256 * 1024 * 1024
+
+// writeImages streams the images described in config.Storage.Images onto
+// their devices.
+func (s stage) writeImages(config types.Config) error {
+	if len(config.Storage.Images) == 0 {
+		return nil
+	}
+	s.Logger.PushPrefix("writeImages")
+	defer s.Logger.PopPrefix()
+
+	devs := []string{}
+	for _, image := range config.Storage.Images {
+		devs = append(devs, string(image.Device))
+	}
+
+	if err := s.waitOnDevicesAndCreateAliases(devs, "images"); err != nil {
+		return err
+	}
+
+	for _, image := range config.Storage.Images {
+		devAlias := util.DeviceAlias(string(image.Device))
+		if err := s.Logger.LogOp(
+			func() error { return s.writeImage(image, devAlias) },
+			"writing image %q to %q", image.Source.String(), image.Device,
+		); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeImage fetches, decompresses and verifies image while copying it onto
+// dev. A failed verification leaves a partially or wrongly written device.
+func (s stage) writeImage(image types.Image, dev string) error {
+	f := util.RenderFile(s.Logger, s.client, s.Context, types.File{
+		Node: types.Node{Path: image.Device},
+		Contents: types.FileContents{
+			Source:       image.Source,
+			Compression:  image.Compression,
+			Verification: image.Verification,
+		},
+	})
+	if f == nil {
+		return fmt.Errorf("failed to resolve image %q", image.Source.String())
+	}
+	defer f.Close()
+
+	out, err := os.OpenFile(dev, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	pw := &progressWriter{Writer: out, logger: s.Logger, dev: dev, next: progressInterval}
+	if _, err := io.Copy(pw, f); err != nil {
+		return fmt.Errorf("failed after writing %d bytes: %v", pw.written, err)
+	}
+	if err := out.Sync(); err != nil {
+		return err
+	}
+	if err := f.Verify(); err != nil {
+		return err
+	}
+
+	s.Logger.Info("wrote %d bytes to %q", pw.written, dev)
+	return nil
+}
+
+// progressWriter logs the number of bytes written through it at every
+// progressInterval.
+type progressWriter struct {
+	io.Writer
+	logger  *log.Logger
+	dev     string
+	written int64
+	next    int64
+}
+
+func (w *progressWriter) Write(p []byte) (int, error) {
+	n, err := w.Writer.Write(p)
+	w.written += int64(n)
+	if w.written >= w.next {
+		w.logger.Info("wrote %d MiB to %q", w.written/(1024*1024), w.dev)
+		w.next += progressInterval
+	}
+	return n, err
+}
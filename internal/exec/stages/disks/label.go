@@ -0,0 +1,118 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package disks
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/coreos/ignition/config/types"
+	"github.com/coreos/ignition/internal/exec/util"
+	"github.com/coreos/ignition/internal/tool"
+)
+
+// tagFilesystem applies the declared label and UUID to the filesystem in
+// place, without touching its contents.
+func (s stage) tagFilesystem(fs types.FilesystemMount) error {
+	dev := util.DeviceAlias(string(fs.Device))
+
+	if fs.Label != nil {
+		var cmd *exec.Cmd
+		switch fs.Format {
+		case "btrfs":
+			cmd = tool.Btrfs.Command("filesystem", "label", dev, *fs.Label)
+		case "ext4":
+			cmd = tool.Tune2fs.Command("-L", *fs.Label, dev)
+		case "xfs":
+			cmd = tool.XfsAdmin.Command("-L", *fs.Label, dev)
+		case "vfat":
+			cmd = tool.Fatlabel.Command(dev, *fs.Label)
+		case "swap":
+			cmd = tool.Swaplabel.Command("-L", *fs.Label, dev)
+		default:
+			return fmt.Errorf("unsupported filesystem format: %q", fs.Format)
+		}
+		if err := s.Logger.LogCmd(cmd, "setting label %q on %q", *fs.Label, dev); err != nil {
+			return fmt.Errorf("failed to set label: %v", err)
+		}
+	}
+
+	if fs.Uuid != nil {
+		var cmd *exec.Cmd
+		switch fs.Format {
+		case "btrfs":
+			// -f skips the interactive confirmation.
+			cmd = tool.Btrfstune.Command("-f", "-U", *fs.Uuid, dev)
+		case "ext4":
+			cmd = tool.Tune2fs.Command("-U", *fs.Uuid, dev)
+		case "xfs":
+			cmd = tool.XfsAdmin.Command("-U", *fs.Uuid, dev)
+		case "vfat":
+			// The volume id is given without its separator.
+			cmd = tool.Fatlabel.Command("-i", dev, strings.Replace(*fs.Uuid, "-", "", 1))
+		case "swap":
+			cmd = tool.Swaplabel.Command("-U", *fs.Uuid, dev)
+		default:
+			return fmt.Errorf("unsupported filesystem format: %q", fs.Format)
+		}
+		if err := s.Logger.LogCmd(cmd, "setting uuid %q on %q", *fs.Uuid, dev); err != nil {
+			return fmt.Errorf("failed to set uuid: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// probeDevice returns what blkid reports about the contents of dev (e.g.
+// TYPE, LABEL, and UUID), which is empty if dev holds nothing it recognizes.
+func (s stage) probeDevice(dev string) map[string]string {
+	info := map[string]string{}
+	out, err := tool.Blkid.Output(s.Logger, "-o", "export", dev)
+	if err != nil {
+		return info
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		kv := strings.SplitN(scanner.Text(), "=", 2)
+		if len(kv) == 2 {
+			info[kv[0]] = kv[1]
+		}
+	}
+	return info
+}
+
+// matchesExisting returns whether info, as reported by probeDevice for the
+// device called name, shows contents of format with the declared label and
+// UUID, if any.
+func (s stage) matchesExisting(info map[string]string, name, format string, label, uuid *string) bool {
+	switch {
+	case info["TYPE"] == "":
+		s.Logger.Debug("%q holds nothing recognizable", name)
+		return false
+	case info["TYPE"] != format:
+		s.Logger.Info("%q holds %s rather than %s", name, info["TYPE"], format)
+		return false
+	case label != nil && info["LABEL"] != *label:
+		s.Logger.Info("%q is labeled %q rather than %q", name, info["LABEL"], *label)
+		return false
+	case uuid != nil && !strings.EqualFold(info["UUID"], *uuid):
+		s.Logger.Info("%q has uuid %q rather than %q", name, info["UUID"], *uuid)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,232 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package disks
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/coreos/ignition/config/types"
+	"github.com/coreos/ignition/internal/exec/util"
+	"github.com/coreos/ignition/internal/tool"
+)
+
+// createLuks formats and opens the LUKS volumes described in
+// config.Storage.Luks. An existing volume is reused, rather than formatted
+// again, unless the config asks for it to be wiped.
+func (s stage) createLuks(config types.Config) error {
+	if len(config.Storage.Luks) == 0 {
+		return nil
+	}
+	s.Logger.PushPrefix("createLuks")
+	defer s.Logger.PopPrefix()
+
+	devs := []string{}
+	for _, volume := range config.Storage.Luks {
+		devs = append(devs, string(volume.Device))
+	}
+
+	if err := s.waitOnDevicesAndCreateAliases(devs, "luks"); err != nil {
+		return err
+	}
+
+	for _, volume := range config.Storage.Luks {
+		if err := s.Logger.LogOp(
+			func() error { return s.createLuksVolume(volume) },
+			"creating luks volume %q on %q", volume.Name, volume.Device,
+		); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s stage) createLuksVolume(volume types.Luks) error {
+	devAlias := util.DeviceAlias(string(volume.Device))
+
+	if !volume.WipeVolume {
+		info := s.probeDevice(devAlias)
+		if s.matchesExisting(info, string(volume.Device), "crypto_LUKS", volume.Label, volume.Uuid) {
+			s.Logger.Info("reusing existing luks volume on %q", volume.Device)
+			if !volume.HasKeyFile() {
+				return s.unlockWithClevis(volume, devAlias)
+			}
+			key, err := s.fetchLuksKey(volume)
+			if err != nil {
+				return err
+			}
+			return s.openLuksVolume(volume, devAlias, key)
+		}
+		if err := s.checkFormat(volume.Device, info, devAlias, "wipeVolume"); err != nil {
+			return err
+		}
+	}
+
+	key, err := s.luksKey(volume)
+	if err != nil {
+		return err
+	}
+
+	args := []string{"luksFormat", "--type", "luks2", "--batch-mode", "--key-file=-"}
+	if volume.Label != nil {
+		args = append(args, "--label", *volume.Label)
+	}
+	if volume.Uuid != nil {
+		args = append(args, "--uuid", *volume.Uuid)
+	}
+	args = append(args, volume.Options...)
+	args = append(args, devAlias)
+
+	cmd := tool.Cryptsetup.Command(args...)
+	cmd.Stdin = bytes.NewReader(key)
+	if err := s.Logger.LogCmd(cmd, "formatting %q", volume.Device); err != nil {
+		return fmt.Errorf("cryptsetup failed: %v", err)
+	}
+
+	if volume.Clevis != nil {
+		if err := s.bindClevis(volume, devAlias, key); err != nil {
+			return err
+		}
+	}
+
+	return s.openLuksVolume(volume, devAlias, key)
+}
+
+// luksKey returns the key to format volume with: its key file, or a random
+// key if it is unlocked by clevis alone.
+func (s stage) luksKey(volume types.Luks) ([]byte, error) {
+	if volume.HasKeyFile() {
+		return s.fetchLuksKey(volume)
+	}
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to generate key of luks volume %q: %v", volume.Name, err)
+	}
+	return []byte(hex.EncodeToString(key)), nil
+}
+
+// clevisConfig returns the configuration of the sss pin which combines the
+// tpm2 and tang pins of c.
+func clevisConfig(c types.Clevis) (string, error) {
+	pins := map[string]interface{}{}
+	if c.Tpm2 {
+		pins["tpm2"] = map[string]interface{}{}
+	}
+	if len(c.Tang) != 0 {
+		tang := []map[string]string{}
+		for _, t := range c.Tang {
+			pin := map[string]string{"url": t.Url}
+			if t.Thumbprint != "" {
+				pin["thp"] = t.Thumbprint
+			}
+			tang = append(tang, pin)
+		}
+		pins["tang"] = tang
+	}
+	b, err := json.Marshal(map[string]interface{}{
+		"t":    c.EffectiveThreshold(),
+		"pins": pins,
+	})
+	return string(b), err
+}
+
+// bindClevis binds the clevis pins of volume to a new key slot of the volume
+// on dev, which key unlocks.
+func (s stage) bindClevis(volume types.Luks, dev string, key []byte) error {
+	config, err := clevisConfig(*volume.Clevis)
+	if err != nil {
+		return err
+	}
+
+	// -y trusts the advertisements of tang servers without a thumbprint.
+	cmd := tool.Clevis.Command("luks", "bind", "-y", "-k", "-", "-d", dev, "sss", config)
+	cmd.Stdin = bytes.NewReader(key)
+	if err := s.Logger.LogCmd(cmd, "binding clevis pins to %q", volume.Device); err != nil {
+		return fmt.Errorf("clevis failed: %v", err)
+	}
+	return nil
+}
+
+// unlockWithClevis unlocks the volume on dev with its bound clevis pins,
+// unless it is already open.
+func (s stage) unlockWithClevis(volume types.Luks, dev string) error {
+	if _, err := os.Stat(volume.MapperDevice()); err == nil {
+		s.Logger.Info("luks volume %q is already open", volume.Name)
+		return nil
+	}
+
+	if err := s.Logger.LogCmd(
+		tool.Clevis.Command("luks", "unlock", "-d", dev, "-n", volume.Name),
+		"unlocking luks volume %q with clevis", volume.Name,
+	); err != nil {
+		return fmt.Errorf("failed to unlock luks volume %q; if it was not created by this config, set wipeVolume to replace it: %v", volume.Name, err)
+	}
+
+	return s.waitOnDevices([]string{volume.MapperDevice()}, "luks")
+}
+
+// openLuksVolume unlocks the volume on dev as /dev/mapper/<name>, unless it
+// is already open.
+func (s stage) openLuksVolume(volume types.Luks, dev string, key []byte) error {
+	if _, err := os.Stat(volume.MapperDevice()); err == nil {
+		s.Logger.Info("luks volume %q is already open", volume.Name)
+		return nil
+	}
+
+	args := []string{"open", "--type", "luks2", "--key-file=-"}
+	if volume.Discard {
+		args = append(args, "--allow-discards")
+	}
+	cmd := tool.Cryptsetup.Command(append(args, dev, volume.Name)...)
+	cmd.Stdin = bytes.NewReader(key)
+	if err := s.Logger.LogCmd(cmd, "opening luks volume %q", volume.Name); err != nil {
+		return fmt.Errorf("failed to open luks volume %q; if it was not created by this config, set wipeVolume to replace it: %v", volume.Name, err)
+	}
+
+	return s.waitOnDevices([]string{volume.MapperDevice()}, "luks")
+}
+
+// fetchLuksKey returns the verified key of volume, fetched only once during
+// the stage, and keeps it for the files stage.
+func (s stage) fetchLuksKey(volume types.Luks) ([]byte, error) {
+	if key, ok := s.CachedLuksKey(volume); ok {
+		s.Logger.Info("using the key of luks volume %q fetched earlier", volume.Name)
+		return key, nil
+	}
+
+	f := util.RenderFile(s.Logger, s.client, s.Context, util.LuksKeyFile(volume))
+	if f == nil {
+		return nil, fmt.Errorf("failed to resolve key of luks volume %q", volume.Name)
+	}
+	defer f.Close()
+
+	key, err := ioutil.ReadAll(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch key of luks volume %q: %v", volume.Name, err)
+	}
+	if err := f.Verify(); err != nil {
+		return nil, fmt.Errorf("failed to verify key of luks volume %q: %v", volume.Name, err)
+	}
+	if err := s.CacheLuksKey(volume, key); err != nil {
+		return nil, fmt.Errorf("failed to keep key of luks volume %q: %v", volume.Name, err)
+	}
+	return key, nil
+}
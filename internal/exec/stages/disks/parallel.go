@@ -0,0 +1,97 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package disks
+
+import (
+	"path/filepath"
+	"sync"
+)
+
+// maxConcurrentDevices bounds how many devices a step works on at once.
+const maxConcurrentDevices = 16
+
+// inParallel calls run with the index of each of keys, concurrently for
+// different keys, so that independent devices are partitioned or formatted
+// at the same time, but one after the other, in order, for equal keys, such
+// as those of jobs on the same device. Each concurrent job is passed a copy
+// of s logging through its own fork of the logger. Once every job has run,
+// or been skipped after an earlier job with the same key failed, the error
+// of the first failed job is returned.
+func (s stage) inParallel(keys []string, run func(s stage, i int) error) error {
+	var groups [][]int
+	group := map[string]int{}
+	for i, key := range keys {
+		g, ok := group[key]
+		if !ok {
+			g = len(groups)
+			group[key] = g
+			groups = append(groups, nil)
+		}
+		groups[g] = append(groups[g], i)
+	}
+
+	jobs := make(chan []int, len(groups))
+	for _, g := range groups {
+		jobs <- g
+	}
+	close(jobs)
+	workers := maxConcurrentDevices
+	if len(groups) < workers {
+		workers = len(groups)
+	}
+
+	errs := make([]error, len(keys))
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func(s stage) {
+			defer wg.Done()
+			for g := range jobs {
+				for _, i := range g {
+					if errs[i] = run(s, i); errs[i] != nil {
+						break
+					}
+				}
+			}
+		}(s.fork())
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fork returns a copy of s logging through a fork of its logger.
+func (s stage) fork() stage {
+	s.Logger = s.Logger.Fork()
+	if s.client != nil {
+		c := s.client.WithLogger(s.Logger)
+		s.client = &c
+	}
+	return s
+}
+
+// deviceKey returns the device which the alias dev points at, so that jobs
+// naming the same device through different links are not run at once.
+func deviceKey(dev string) string {
+	if path, err := filepath.EvalSymlinks(dev); err == nil {
+		return path
+	}
+	return dev
+}
@@ -0,0 +1,258 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package disks
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/coreos/ignition/config/types"
+	"github.com/coreos/ignition/internal/sgdisk"
+)
+
+// preservedPartitionTypes are the types of partitions which are carried over
+// when the partition table of a disk is wiped. These partitions hold
+// firmware-loaded boot code which would otherwise be destroyed along with the
+// table, leaving the machine unbootable.
+var preservedPartitionTypes = []types.PartitionTypeGUID{
+	types.PartitionTypePrepBoot,
+	types.PartitionTypeEfiSystem,
+	types.PartitionTypeBiosBoot,
+}
+
+// mbrBootCodeSize is the size of the bootstrap code area at the start of the
+// MBR, which holds the stage1 of BIOS bootloaders (e.g. GRUB's boot.img).
+const mbrBootCodeSize = 440
+
+// partitionPlan describes the changes to be made to a disk's partition table.
+type partitionPlan struct {
+	// parts are the partitions to create.
+	parts []sgdisk.Partition
+
+	// deletions are the numbers of existing partitions to delete before any
+	// are created.
+	deletions []int
+
+	// resized are the numbers of existing partitions which are deleted and
+	// recreated, larger, at the same offset.
+	resized []int
+
+	// bootCode is the MBR bootstrap code to restore once the partition table
+	// has been wiped and rewritten, if any.
+	bootCode []byte
+
+	// sectorSize is the logical sector size of the disk, in which the
+	// partitions are measured.
+	sectorSize uint64
+}
+
+// planPartitions returns the plan for satisfying disk on devAlias. Slot pairs
+// are expanded into their two partitions, placed after the explicitly declared
+// partitions. If the partition table is going to be wiped, the existing table
+// is consulted so that boot partitions survive the wipe. If a BIOS boot
+// partition is preserved, the stage1 in the MBR (which references it) is
+// captured too, since wiping the table also clears the MBR.
+func (s stage) planPartitions(disk types.Disk, devAlias string) (partitionPlan, error) {
+	sectorSize, err := logicalSectorSize(devAlias)
+	if err != nil {
+		return partitionPlan{}, fmt.Errorf("failed to read sector size of %q: %v", devAlias, err)
+	}
+	plan := partitionPlan{sectorSize: sectorSize}
+	for _, part := range disk.Partitions {
+		if !part.Exists() {
+			continue
+		}
+		plan.parts = append(plan.parts, sgdisk.Partition{
+			Number:   part.Number,
+			Length:   part.SizeSectors(sectorSize),
+			Offset:   part.StartSectors(sectorSize),
+			Label:    string(part.Label),
+			TypeGUID: string(part.TypeGUID),
+			GUID:     string(part.GUID),
+		})
+	}
+
+	for _, pair := range disk.SlotPairs {
+		for _, slot := range []string{"a", "b"} {
+			plan.parts = append(plan.parts, sgdisk.Partition{
+				Length:   pair.SizeSectors(sectorSize),
+				Label:    string(pair.SlotLabel(slot)),
+				TypeGUID: string(pair.TypeGUID),
+				Bootable: slot == pair.ActiveSlot(),
+			})
+		}
+	}
+
+	if !disk.WipeTable {
+		if modifiesExisting(disk) {
+			if err := s.planExisting(&plan, disk, devAlias); err != nil {
+				return partitionPlan{}, err
+			}
+		}
+		return plan, nil
+	}
+
+	existing, err := sgdisk.Partitions(s.Logger, devAlias)
+	if err != nil {
+		s.Logger.Warning("unable to read existing partitions on %q, none will be preserved: %v", devAlias, err)
+		return plan, nil
+	}
+
+	if err := s.placeEfiSystemPartitions(plan.parts, existing, devAlias); err != nil {
+		return partitionPlan{}, err
+	}
+
+	preserved := s.preservedPartitions(disk, existing, devAlias)
+	for _, p := range preserved {
+		if !types.PartitionTypeBiosBoot.Is(types.PartitionTypeGUID(p.TypeGUID)) {
+			continue
+		}
+		if plan.bootCode, err = readBootCode(devAlias); err != nil {
+			return partitionPlan{}, fmt.Errorf("failed to save stage1 bootloader on %q: %v", devAlias, err)
+		}
+		s.Logger.Info("saved stage1 bootloader on %q", devAlias)
+	}
+	plan.parts = append(preserved, plan.parts...)
+
+	return plan, nil
+}
+
+// readBootCode returns the bootstrap code area of the MBR on dev.
+func readBootCode(dev string) ([]byte, error) {
+	f, err := os.Open(dev)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	code := make([]byte, mbrBootCodeSize)
+	if _, err := f.ReadAt(code, 0); err != nil {
+		return nil, err
+	}
+	return code, nil
+}
+
+// writeBootCode writes code into the bootstrap code area of the MBR on dev,
+// leaving the partition entries and signature intact.
+func writeBootCode(dev string, code []byte) error {
+	f, err := os.OpenFile(dev, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err := f.WriteAt(code[:mbrBootCodeSize], 0); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+// preservedPartitions returns the existing partitions which need to be
+// recreated after the partition table is wiped. A partition is not preserved
+// if the config declares a partition of the same type (the config then takes
+// responsibility for it) or declares a partition with the same number. Since
+// the partitions are recreated at their original offsets, their contents
+// survive the wipe.
+func (s stage) preservedPartitions(disk types.Disk, existing []sgdisk.Partition, devAlias string) []sgdisk.Partition {
+	preserved := []sgdisk.Partition{}
+	for _, p := range existing {
+		if !isPreservedType(types.PartitionTypeGUID(p.TypeGUID)) || declaresType(disk, p.TypeGUID) {
+			continue
+		}
+		if declaresNumber(disk, p.Number) {
+			s.Logger.Warning("not preserving partition %d (%s) on %q: partition number is declared in the config", p.Number, p.TypeGUID, devAlias)
+			continue
+		}
+
+		s.Logger.Info("preserving partition %d (%s) on %q", p.Number, p.TypeGUID, devAlias)
+		preserved = append(preserved, p)
+	}
+
+	return preserved
+}
+
+// placeEfiSystemPartitions anchors each declared EFI System Partition to the
+// existing ESP with the same number (or the only existing ESP, if the number
+// was left unspecified) so that its contents survive the wipe. Declared ESPs
+// may grow the existing partition, but they may not shrink it, since the FAT
+// filesystem within would be truncated.
+func (s stage) placeEfiSystemPartitions(parts []sgdisk.Partition, existing []sgdisk.Partition, devAlias string) error {
+	esps := []sgdisk.Partition{}
+	for _, p := range existing {
+		if types.PartitionTypeEfiSystem.Is(types.PartitionTypeGUID(p.TypeGUID)) {
+			esps = append(esps, p)
+		}
+	}
+
+	for i, p := range parts {
+		if !types.PartitionTypeEfiSystem.Is(types.PartitionTypeGUID(p.TypeGUID)) {
+			continue
+		}
+
+		var old *sgdisk.Partition
+		for j, e := range esps {
+			if e.Number == p.Number || (p.Number == 0 && len(esps) == 1) {
+				old = &esps[j]
+				break
+			}
+		}
+		if old == nil {
+			continue
+		}
+
+		if p.Offset == 0 {
+			parts[i].Offset = old.Offset
+		} else if p.Offset != old.Offset {
+			s.Logger.Warning("EFI system partition %d on %q is being moved; its contents will not be preserved", old.Number, devAlias)
+			continue
+		}
+
+		if p.Length != 0 && p.Length < old.Length {
+			return fmt.Errorf("refusing to shrink EFI system partition %d on %q from %d to %d sectors", old.Number, devAlias, old.Length, p.Length)
+		}
+		if p.Length > old.Length {
+			s.Logger.Info("enlarging EFI system partition %d on %q from %d to %d sectors", old.Number, devAlias, old.Length, p.Length)
+		}
+	}
+
+	return nil
+}
+
+func isPreservedType(guid types.PartitionTypeGUID) bool {
+	for _, t := range preservedPartitionTypes {
+		if t.Is(guid) {
+			return true
+		}
+	}
+	return false
+}
+
+func declaresType(disk types.Disk, guid string) bool {
+	for _, p := range disk.Partitions {
+		if p.TypeGUID.Is(types.PartitionTypeGUID(guid)) {
+			return true
+		}
+	}
+	return false
+}
+
+func declaresNumber(disk types.Disk, number int) bool {
+	for _, p := range disk.Partitions {
+		if p.Number == number {
+			return true
+		}
+	}
+	return false
+}
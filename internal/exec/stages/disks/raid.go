@@ -0,0 +1,183 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package disks
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/coreos/ignition/config/types"
+	"github.com/coreos/ignition/internal/exec/util"
+	"github.com/coreos/ignition/internal/tool"
+)
+
+// raidDevice returns the path of the md device of md.
+func raidDevice(md types.Raid) string {
+	return filepath.Join("/dev/md", filepath.Base(md.Name))
+}
+
+// orderRaids returns arrays ordered so that each follows the arrays among its
+// members, such as the RAID1 halves of a RAID10 built by hand, along with a
+// key for each which is shared by all arrays built on one another, so that
+// those are created one after the other while unrelated arrays are created
+// concurrently.
+func orderRaids(arrays []types.Raid) ([]types.Raid, []string) {
+	index := map[string]int{}
+	for i, md := range arrays {
+		index[raidDevice(md)] = i
+	}
+
+	// group[i] is the first of the arrays connected to array i.
+	group := make([]int, len(arrays))
+	for i := range group {
+		group[i] = i
+	}
+	var find func(i int) int
+	find = func(i int) int {
+		if group[i] != i {
+			group[i] = find(group[i])
+		}
+		return group[i]
+	}
+
+	var ordered []int
+	state := make([]int, len(arrays)) // 0 unvisited, 1 visiting, 2 done
+	var visit func(i int)
+	visit = func(i int) {
+		if state[i] != 0 {
+			// A cycle is left for mdadm to reject.
+			return
+		}
+		state[i] = 1
+		for _, dev := range arrays[i].Devices {
+			if j, ok := index[string(dev)]; ok && j != i {
+				a, b := find(i), find(j)
+				if a > b {
+					a, b = b, a
+				}
+				group[b] = a
+				visit(j)
+			}
+		}
+		state[i] = 2
+		ordered = append(ordered, i)
+	}
+	for i := range arrays {
+		visit(i)
+	}
+
+	sorted := make([]types.Raid, len(ordered))
+	keys := make([]string, len(ordered))
+	for n, i := range ordered {
+		sorted[n] = arrays[i]
+		keys[n] = arrays[find(i)].Name
+	}
+	return sorted, keys
+}
+
+// matchesExistingArray reports whether every member of md carries the md
+// superblock of one existing array with the name, level, member count, and
+// (if specified) UUID declared by md.
+func (s stage) matchesExistingArray(md types.Raid) bool {
+	uuid := ""
+	for _, dev := range md.Devices {
+		info, err := examineMember(util.DeviceAlias(string(dev)))
+		if err != nil {
+			s.Logger.Debug("no usable md superblock on %q: %v", dev, err)
+			return false
+		}
+
+		// MD_NAME is of the form "homehost:name".
+		name := info["MD_NAME"]
+		if i := strings.LastIndex(name, ":"); i >= 0 {
+			name = name[i+1:]
+		}
+		count, _ := strconv.Atoi(info["MD_DEVICES"])
+		switch {
+		case name != filepath.Base(md.Name):
+			s.Logger.Info("%q belongs to array %q rather than %q", dev, name, md.Name)
+			return false
+		case info["MD_LEVEL"] != md.CanonicalLevel():
+			s.Logger.Info("%q belongs to a %s rather than %s array", dev, info["MD_LEVEL"], md.CanonicalLevel())
+			return false
+		case count != len(md.Devices)-md.Spares:
+			s.Logger.Info("%q belongs to an array of %d rather than %d devices", dev, count, len(md.Devices)-md.Spares)
+			return false
+		}
+
+		memberUuid := types.CanonicalUuid(info["MD_UUID"])
+		if md.Uuid != nil && memberUuid != types.CanonicalUuid(*md.Uuid) {
+			s.Logger.Info("%q belongs to array %q rather than %q", dev, info["MD_UUID"], *md.Uuid)
+			return false
+		}
+		if uuid != "" && memberUuid != uuid {
+			s.Logger.Info("members of %q belong to different arrays", md.Name)
+			return false
+		}
+		uuid = memberUuid
+	}
+
+	return true
+}
+
+// examineMember returns the md superblock fields of dev, as exported by
+// mdadm.
+func examineMember(dev string) (map[string]string, error) {
+	out, err := tool.Mdadm.Command("--examine", "--export", dev).Output()
+	if err != nil {
+		return nil, err
+	}
+
+	info := map[string]string{}
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		kv := strings.SplitN(scanner.Text(), "=", 2)
+		if len(kv) == 2 {
+			info[kv[0]] = kv[1]
+		}
+	}
+	if info["MD_UUID"] == "" {
+		return nil, fmt.Errorf("no array uuid found")
+	}
+	return info, nil
+}
+
+// assembleArray starts the existing array md, unless udev has already done
+// so.
+func (s stage) assembleArray(md types.Raid) error {
+	if _, err := os.Stat(raidDevice(md)); err == nil {
+		s.Logger.Info("reusing running array %q", md.Name)
+		return nil
+	}
+
+	args := []string{"--assemble", md.Name, "--run"}
+	for _, dev := range md.Devices {
+		args = append(args, util.DeviceAlias(string(dev)))
+	}
+
+	if err := s.Logger.LogCmd(
+		tool.Mdadm.Command(args...),
+		"assembling existing array %q", md.Name,
+	); err != nil {
+		return fmt.Errorf("mdadm failed: %v", err)
+	}
+
+	return nil
+}
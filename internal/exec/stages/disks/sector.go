@@ -0,0 +1,40 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package disks
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// blkSszGet is the BLKSSZGET ioctl, from <linux/fs.h>.
+const blkSszGet = 0x1268
+
+// logicalSectorSize returns the logical sector size of the block device at
+// dev, in which sgdisk measures partitions.
+func logicalSectorSize(dev string) (uint64, error) {
+	f, err := os.Open(dev)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	var size int32
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), blkSszGet, uintptr(unsafe.Pointer(&size))); errno != 0 {
+		return 0, errno
+	}
+	return uint64(size), nil
+}
@@ -0,0 +1,119 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package disks
+
+import (
+	"os"
+
+	"github.com/coreos/ignition/config/types"
+	"github.com/coreos/ignition/internal/tool"
+)
+
+// requiredTools returns the external tools needed to apply config, so that a
+// missing tool is reported before any device is modified.
+func requiredTools(config types.Config) []tool.Tool {
+	tools := []tool.Tool{}
+	storage := config.Storage
+
+	if len(storage.Disks) != 0 {
+		tools = append(tools, tool.Sgdisk)
+	}
+	for _, disk := range storage.Disks {
+		if disk.WipeTable && !disk.Force {
+			tools = append(tools, tool.Blkid)
+			break
+		}
+	}
+	for _, disk := range storage.Disks {
+		if !disk.WipeTable && declaresResize(disk) {
+			tools = append(tools, tool.Partx, tool.Blkid)
+			break
+		}
+	}
+	if storage.GrowRoot != nil {
+		tools = append(tools, tool.Sgdisk, tool.Partx, tool.Blkid)
+	}
+	if len(storage.Arrays) != 0 {
+		tools = append(tools, tool.Mdadm)
+	}
+	if len(storage.Luks) != 0 {
+		tools = append(tools, tool.Cryptsetup, tool.Blkid)
+	}
+	for _, volume := range storage.Luks {
+		if volume.Clevis != nil {
+			tools = append(tools, tool.Clevis)
+			break
+		}
+	}
+
+	for _, fs := range storage.Filesystems {
+		if fs.Mount == nil {
+			continue
+		}
+		m := fs.Mount
+		if m.Create != nil {
+			if !m.Create.Force {
+				tools = append(tools, tool.Blkid)
+			}
+			switch m.Format {
+			case "btrfs":
+				tools = append(tools, tool.MkfsBtrfs)
+			case "ext4":
+				tools = append(tools, tool.MkfsExt4)
+			case "xfs":
+				tools = append(tools, tool.MkfsXfs)
+			case "vfat":
+				tools = append(tools, tool.MkfsVfat)
+			case "swap":
+				tools = append(tools, tool.Mkswap)
+			}
+			if len(m.Create.Subvolumes) != 0 {
+				tools = append(tools, tool.Btrfs)
+			}
+			if len(m.Create.Projects) != 0 {
+				tools = append(tools, tool.XfsQuota)
+			}
+		}
+		if m.Label != nil || m.Uuid != nil {
+			switch m.Format {
+			case "btrfs":
+				if m.Label != nil {
+					tools = append(tools, tool.Btrfs)
+				}
+				if m.Uuid != nil {
+					tools = append(tools, tool.Btrfstune)
+				}
+			case "ext4":
+				tools = append(tools, tool.Tune2fs)
+			case "xfs":
+				tools = append(tools, tool.XfsAdmin)
+			case "vfat":
+				tools = append(tools, tool.Fatlabel)
+			case "swap":
+				tools = append(tools, tool.Swaplabel)
+			}
+		}
+	}
+
+	efi := config.Bootloader.Efi
+	if len(efi.Entries) != 0 || len(efi.BootOrder) != 0 {
+		// EFI boot entries are ignored on systems not booted via UEFI.
+		if _, err := os.Stat(efiVarsPath); err == nil {
+			tools = append(tools, tool.Efibootmgr)
+		}
+	}
+
+	return tools
+}
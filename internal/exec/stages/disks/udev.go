@@ -0,0 +1,49 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package disks
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/coreos/ignition/internal/tool"
+)
+
+const (
+	// settleTimeout bounds the wait for udev to process the events of the
+	// devices the stage created or changed.
+	settleTimeout = 30 * time.Second
+
+	// deviceTimeout bounds the wait for the devices the config names to
+	// appear.
+	deviceTimeout = 90 * time.Second
+)
+
+// settleUdev waits for udev to finish processing the events queued so far,
+// such as those for the partitions sgdisk just created, so that the links
+// udev maintains under /dev/disk are current. A udev which doesn't settle in
+// time is only warned about, since the devices may still be usable.
+func (s stage) settleUdev() {
+	if !tool.Udevadm.Available() {
+		s.Logger.Debug("udevadm not found, not waiting for udev to settle")
+		return
+	}
+	if err := s.Logger.LogCmd(
+		tool.Udevadm.Command("settle", fmt.Sprintf("--timeout=%d", int(settleTimeout/time.Second))),
+		"waiting for udev to settle",
+	); err != nil {
+		s.Logger.Warning("udev did not settle: %v", err)
+	}
+}
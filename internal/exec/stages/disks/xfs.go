@@ -0,0 +1,63 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package disks
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/coreos/ignition/config/types"
+	"github.com/coreos/ignition/internal/exec/util"
+	"github.com/coreos/ignition/internal/tool"
+)
+
+// createProjects sets up the project quotas declared for a freshly created
+// xfs filesystem, creating each project's directory if needed.
+func (s stage) createProjects(fs types.FilesystemMount) error {
+	dev := util.DeviceAlias(string(fs.Device))
+	return s.withMount(dev, "xfs", "prjquota", func(mnt string) error {
+		for _, p := range fs.Create.Projects {
+			dir := filepath.Join(mnt, string(p.Directory))
+			if err := os.MkdirAll(dir, util.DefaultDirectoryPermissions); err != nil {
+				return fmt.Errorf("failed to create project directory %q: %v", p.Directory, err)
+			}
+			if err := s.Logger.LogCmd(
+				tool.XfsQuota.Command("-x", "-c", fmt.Sprintf("project -s -p %s %d", dir, p.Id), mnt),
+				"assigning %q to project %d on %q", p.Directory, p.Id, dev,
+			); err != nil {
+				return fmt.Errorf("failed to set up project %d: %v", p.Id, err)
+			}
+
+			limits := ""
+			if p.SoftLimit != "" {
+				limits += " bsoft=" + p.SoftLimit
+			}
+			if p.HardLimit != "" {
+				limits += " bhard=" + p.HardLimit
+			}
+			if limits == "" {
+				continue
+			}
+			if err := s.Logger.LogCmd(
+				tool.XfsQuota.Command("-x", "-c", fmt.Sprintf("limit -p%s %d", limits, p.Id), mnt),
+				"limiting project %d on %q", p.Id, dev,
+			); err != nil {
+				return fmt.Errorf("failed to limit project %d: %v", p.Id, err)
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,186 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package disks
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/coreos/ignition/config/types"
+
+	"golang.org/x/net/context"
+)
+
+const (
+	cmdlinePath     = "/proc/cmdline"
+	cmdlineZfcpFlag = "rd.zfcp"
+
+	ccwDevicesPath  = "/sys/bus/ccw/devices"
+	zfcpDriverPath  = "/sys/bus/ccw/drivers/zfcp"
+	scsiDevicesPath = "/sys/bus/scsi/devices"
+
+	// zfcpPollInterval is how often the SCSI devices are checked for an
+	// added LUN.
+	zfcpPollInterval = 100 * time.Millisecond
+)
+
+// attachZfcpDevices brings the zFCP-attached SCSI devices described in
+// config.Storage.Zfcp and on the kernel command line (rd.zfcp=) online so
+// they can be referenced by the remainder of the disks stage. The SCSI scan
+// of an added LUN is asynchronous, so each device's node is waited for.
+func (s stage) attachZfcpDevices(config types.Config) error {
+	devs := append(s.zfcpDevicesFromCmdline(), config.Storage.Zfcp...)
+	if len(devs) == 0 {
+		return nil
+	}
+	s.Logger.PushPrefix("attachZfcpDevices")
+	defer s.Logger.PopPrefix()
+
+	seen := map[types.Zfcp]struct{}{}
+	for _, dev := range devs {
+		if _, ok := seen[dev]; ok {
+			continue
+		}
+		seen[dev] = struct{}{}
+
+		if err := s.Logger.LogOp(
+			func() error { return attachZfcpDevice(dev) },
+			"attaching zfcp device %s:%s:%s", dev.BusId, dev.Wwpn, dev.Lun,
+		); err != nil {
+			return err
+		}
+		if err := s.waitOnZfcpDevice(dev); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// waitOnZfcpDevice waits for the block device of the SCSI device attached as
+// dev to appear, and then for its node and links.
+func (s stage) waitOnZfcpDevice(dev types.Zfcp) error {
+	ctx := s.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancel := context.WithTimeout(ctx, deviceTimeout)
+	defer cancel()
+
+	s.Logger.Debug("waiting for the block device of zfcp device %s:%s:%s", dev.BusId, dev.Wwpn, dev.Lun)
+	for {
+		if block, ok := zfcpBlockDevice(dev); ok {
+			return s.waitOnDevices([]string{block}, "zfcp")
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("zfcp device %s:%s:%s did not appear: %v", dev.BusId, dev.Wwpn, dev.Lun, ctx.Err())
+		case <-time.After(zfcpPollInterval):
+		}
+	}
+}
+
+// zfcpBlockDevice returns the node of the block device of the SCSI device
+// attached as dev, and whether it has appeared. zfcp gives each of its SCSI
+// devices the adapter, port, and LUN it is attached through.
+func zfcpBlockDevice(dev types.Zfcp) (string, bool) {
+	luns, err := filepath.Glob(filepath.Join(scsiDevicesPath, "*", "fcp_lun"))
+	if err != nil {
+		return "", false
+	}
+	for _, lun := range luns {
+		dir := filepath.Dir(lun)
+		if !sysfsAttrIs(dir, "hba_id", dev.BusId) || !sysfsAttrIs(dir, "wwpn", dev.Wwpn) || !sysfsAttrIs(dir, "fcp_lun", dev.Lun) {
+			continue
+		}
+		blocks, err := filepath.Glob(filepath.Join(dir, "block", "*"))
+		if err != nil || len(blocks) == 0 {
+			return "", false
+		}
+		return filepath.Join("/dev", filepath.Base(blocks[0])), true
+	}
+	return "", false
+}
+
+// sysfsAttrIs reports whether the attribute attr of the sysfs directory dir
+// is value, ignoring case, since sysfs prints hex digits in lower case.
+func sysfsAttrIs(dir, attr, value string) bool {
+	b, err := ioutil.ReadFile(filepath.Join(dir, attr))
+	return err == nil && strings.EqualFold(strings.TrimSpace(string(b)), value)
+}
+
+// attachZfcpDevice sets the FCP adapter at dev.BusId online and adds the LUN
+// on the given remote port. Adapters and LUNs which are already online are
+// left untouched.
+func attachZfcpDevice(dev types.Zfcp) error {
+	online := filepath.Join(ccwDevicesPath, dev.BusId, "online")
+	state, err := ioutil.ReadFile(online)
+	if err != nil {
+		return fmt.Errorf("failed to read adapter state: %v", err)
+	}
+	if strings.TrimSpace(string(state)) != "1" {
+		if err := ioutil.WriteFile(online, []byte("1"), 0644); err != nil {
+			return fmt.Errorf("failed to set adapter online: %v", err)
+		}
+	}
+
+	port := filepath.Join(zfcpDriverPath, dev.BusId, dev.Wwpn)
+	if _, err := os.Stat(filepath.Join(port, dev.Lun)); err == nil {
+		return nil
+	}
+	if err := ioutil.WriteFile(filepath.Join(port, "unit_add"), []byte(dev.Lun), 0644); err != nil {
+		return fmt.Errorf("failed to add lun: %v", err)
+	}
+
+	return nil
+}
+
+// zfcpDevicesFromCmdline returns the devices specified on the kernel command
+// line using the rd.zfcp=<busid>,<wwpn>,<lun> syntax understood by dracut.
+// Malformed entries are logged and skipped.
+func (s stage) zfcpDevicesFromCmdline() []types.Zfcp {
+	args, err := ioutil.ReadFile(cmdlinePath)
+	if err != nil {
+		s.Logger.Info("couldn't read cmdline: %v", err)
+		return nil
+	}
+
+	devs := []types.Zfcp{}
+	for _, arg := range strings.Fields(string(args)) {
+		parts := strings.SplitN(arg, "=", 2)
+		if parts[0] != cmdlineZfcpFlag || len(parts) != 2 {
+			continue
+		}
+
+		fields := strings.Split(parts[1], ",")
+		if len(fields) != 3 {
+			s.Logger.Warning("ignoring malformed %s argument %q", cmdlineZfcpFlag, parts[1])
+			continue
+		}
+
+		dev := types.Zfcp{BusId: fields[0], Wwpn: fields[1], Lun: fields[2]}
+		if r := dev.Validate(); r.IsFatal() {
+			s.Logger.Warning("ignoring invalid %s argument %q: %v", cmdlineZfcpFlag, parts[1], r)
+			continue
+		}
+		devs = append(devs, dev)
+	}
+
+	return devs
+}
@@ -0,0 +1,130 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package files
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/coreos/ignition/config/types"
+	"github.com/coreos/ignition/internal/exec/util"
+	"github.com/coreos/ignition/internal/log"
+	"github.com/coreos/ignition/internal/resource"
+
+	"golang.org/x/net/context"
+)
+
+// maxConcurrentFetches bounds how many files have their contents fetched at
+// once.
+const maxConcurrentFetches = 8
+
+// prefetch is the remote contents of a file, fetched ahead of it being
+// written.
+type prefetch struct {
+	file types.File
+	done chan struct{}
+	path string // the fetched contents, once done
+	err  error
+}
+
+type prefetchedFileEntry struct {
+	fetch *prefetch
+}
+
+// create waits for the file's contents and writes them in place. The fetched
+// contents are removed if they were not moved there, as when writing fails.
+func (e prefetchedFileEntry) create(l *log.Logger, c *resource.HttpClient, u util.Util) error {
+	<-e.fetch.done
+	if e.fetch.err != nil {
+		return fmt.Errorf("failed to fetch file %q: %v", e.fetch.file.Path, e.fetch.err)
+	}
+	defer os.Remove(e.fetch.path)
+	return writeFile(l, c, u, e.fetch.file, e.fetch.path)
+}
+
+// prefetchFiles starts fetching the remote contents of the files among
+// entries into temporary files in the directories they are written to, in
+// order and at most maxConcurrentFetches at a time. The contents are thus on
+// the filesystem of their file, and can be moved into place rather than
+// copied, and don't leave temporary files elsewhere on the target. The files are still written in order, each
+// waiting for its contents. It returns the entries to create and a function
+// which stops the fetches and removes the contents which were not written.
+func (s stage) prefetchFiles(u util.Util, entries []filesystemEntry) ([]filesystemEntry, func()) {
+	var fetches []*prefetch
+	prefetched := make([]filesystemEntry, len(entries))
+	for i, e := range entries {
+		prefetched[i] = e
+		if f, ok := e.(fileEntry); ok && isRemote(types.File(f)) {
+			p := &prefetch{file: types.File(f), done: make(chan struct{})}
+			fetches = append(fetches, p)
+			prefetched[i] = prefetchedFileEntry{fetch: p}
+		}
+	}
+	// A single file gains nothing from being fetched ahead.
+	if len(fetches) < 2 {
+		return entries, func() {}
+	}
+
+	ctx := u.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancel := context.WithCancel(ctx)
+
+	jobs := make(chan *prefetch, len(fetches))
+	for _, p := range fetches {
+		jobs <- p
+	}
+	close(jobs)
+	workers := maxConcurrentFetches
+	if len(fetches) < workers {
+		workers = len(fetches)
+	}
+	for i := 0; i < workers; i++ {
+		l := s.Logger.Fork()
+		var client *resource.HttpClient
+		if s.client != nil {
+			c := s.client.WithLogger(l)
+			client = &c
+		}
+		go func() {
+			for p := range jobs {
+				path := u.JoinPath(string(p.file.Path))
+				if p.err = util.MkdirForFile(path); p.err == nil {
+					p.path, p.err = util.FetchToFile(l, client, ctx, p.file, filepath.Dir(path))
+				}
+				close(p.done)
+			}
+		}()
+	}
+
+	return prefetched, func() {
+		cancel()
+		for _, p := range fetches {
+			<-p.done
+			if p.err == nil {
+				os.Remove(p.path)
+			}
+		}
+	}
+}
+
+// isRemote returns whether the contents of f are fetched from elsewhere,
+// rather than given in the config.
+func isRemote(f types.File) bool {
+	scheme := f.Contents.Source.Scheme
+	return len(f.Contents.Fragments) == 0 && scheme != "" && scheme != "data"
+}
@@ -15,19 +15,22 @@
 package files
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"sort"
-	"syscall"
 
 	"github.com/coreos/ignition/config/types"
 	"github.com/coreos/ignition/internal/exec/stages"
 	"github.com/coreos/ignition/internal/exec/util"
 	"github.com/coreos/ignition/internal/log"
 	"github.com/coreos/ignition/internal/resource"
+	"github.com/coreos/ignition/internal/tool"
+
+	"golang.org/x/net/context"
 )
 
 const (
@@ -35,7 +38,10 @@ const (
 )
 
 var (
-	ErrFilesystemUndefined = errors.New("the referenced filesystem was not defined")
+	ErrFilesystemUndefined  = errors.New("the referenced filesystem was not defined")
+	ErrFilesystemTargetOnly = errors.New("the referenced filesystem is only mounted on the target system")
+	ErrFilesystemSwap       = errors.New("the referenced filesystem is a swap area")
+	ErrSwapFileNotMounted   = errors.New("swap files must be on a filesystem mounted on the target system")
 )
 
 func init() {
@@ -44,11 +50,16 @@ func init() {
 
 type creator struct{}
 
-func (creator) Create(logger *log.Logger, client *resource.HttpClient, root string) stages.Stage {
+func (creator) Create(logger *log.Logger, client *resource.HttpClient, ctx context.Context, root, stateDir, resultFile, platform string) stages.Stage {
 	return &stage{
 		Util: util.Util{
-			DestDir: root,
-			Logger:  logger,
+			DestDir:    root,
+			StateDir:   stateDir,
+			ResultFile: resultFile,
+			Platform:   platform,
+			Context:    ctx,
+			Logger:     logger,
+			Relabel:    &util.Relabeler{},
 		},
 		client: client,
 	}
@@ -62,32 +73,62 @@ type stage struct {
 	util.Util
 
 	client *resource.HttpClient
+	// mounted maps the names of the filesystems mounted at their paths on
+	// the target system to where they are mounted.
+	mounted map[string]string
 }
 
 func (stage) Name() string {
 	return name
 }
 
-func (s stage) Run(config types.Config) bool {
-	if err := s.createPasswd(config); err != nil {
-		s.Logger.Crit("failed to create users/groups: %v", err)
-		return false
+func (s stage) Run(config types.Config) error {
+	// Users and groups fall back to editing the account databases directly,
+	// but presets and the default target need systemctl.
+	if config.Systemd.ApplyPresets || config.Systemd.DefaultTarget != nil {
+		if err := tool.Require(tool.Systemctl); err != nil {
+			return err
+		}
 	}
-
-	if err := s.createFilesystemsEntries(config); err != nil {
-		s.Logger.Crit("failed to create files: %v", err)
-		return false
+	if len(config.Storage.SwapFiles) != 0 {
+		if err := tool.Require(tool.Mkswap); err != nil {
+			return err
+		}
 	}
 
-	if err := s.createUnits(config); err != nil {
-		s.Logger.Crit("failed to create units: %v", err)
-		return false
+	mounted, unmount, err := s.mountTargetFilesystems(config)
+	if err != nil {
+		return err
 	}
-
-	return true
+	s.mounted = mounted
+
+	// On success the filesystems stay mounted until the umount stage, after
+	// every stage writing to the target root has run. When the stages are
+	// run one at a time, they stay mounted unless umount is run as well.
+	if err := s.RunSteps(config, []util.Step{
+		{Name: "create users/groups", Run: s.createPasswd},
+		{Name: "create files", Run: s.createFilesystemsEntries},
+		{Name: "create overlay directories", Run: s.createOverlayDirectories},
+		{Name: "install CA certificates", Run: s.installTrustAnchors},
+		{Name: "create units", Run: s.createUnits},
+		{Name: "write bootloader config", Run: s.writeBootloaderConfig},
+		{Name: "write machine-id", Run: s.writeMachineId},
+		{Name: "write hosts entries", Run: s.writeHosts},
+		{Name: "write sysctl settings", Run: s.writeSysctl},
+		{Name: "write kernel module configuration", Run: s.writeKernelModules},
+		{Name: "write luks configuration", Run: s.writeLuks},
+		{Name: "relabel files", Run: s.relabelFiles},
+	}); err != nil {
+		unmount()
+		return err
+	}
+	return nil
 }
 
-// createFilesystemsEntries creates the files described in config.Storage.{Files,Directories}.
+// createFilesystemsEntries creates the files described in config.Storage.{Files,Directories,Links,SwapFiles}.
+// Entries on filesystems which are mounted at a path on the target system are
+// written through s.mounted, so that they land where they will be seen on the
+// target system.
 func (s stage) createFilesystemsEntries(config types.Config) error {
 	if len(config.Storage.Filesystems) == 0 {
 		return nil
@@ -100,8 +141,8 @@ func (s stage) createFilesystemsEntries(config types.Config) error {
 		return err
 	}
 
-	for fs, f := range entryMap {
-		if err := s.createEntries(fs, f); err != nil {
+	for _, fs := range orderedFilesystems(config, entryMap) {
+		if err := s.createEntries(fs, entryMap[fs], s.mounted[fs.Name]); err != nil {
 			return fmt.Errorf("failed to create files: %v", err)
 		}
 	}
@@ -117,14 +158,44 @@ type filesystemEntry interface {
 type fileEntry types.File
 
 func (tmp fileEntry) create(l *log.Logger, c *resource.HttpClient, u util.Util) error {
-	f := types.File(tmp)
-	file := util.RenderFile(l, c, f)
+	return writeFile(l, c, u, types.File(tmp), "")
+}
+
+// writeFile writes f, taking its contents from the file at fetched if they
+// were already fetched there.
+func writeFile(l *log.Logger, c *resource.HttpClient, u util.Util, f types.File, fetched string) error {
+	var err error
+	if f.Node, err = u.ResolveNodeOwner(f.Node); err != nil {
+		return err
+	}
+	var file *util.File
+	if fetched != "" {
+		file = util.RenderFetchedFile(l, c, u.Context, f, fetched)
+	} else {
+		file = util.RenderFile(l, c, u.Context, f)
+	}
 	if file == nil {
 		return fmt.Errorf("failed to resolve file %q", f.Path)
 	}
+	if f.Contents.Template {
+		u.TemplateFile(file)
+	}
 
 	if err := l.LogOp(
-		func() error { return u.WriteFile(file) },
+		func() error {
+			// An existing symbolic link, such as /etc/resolv.conf, is
+			// replaced like an existing regular file, leaving its target
+			// alone.
+			overwrite := f.Overwrite
+			if info, err := os.Lstat(u.JoinPath(string(f.Path))); err == nil && info.Mode()&os.ModeSymlink != 0 {
+				overwrite = true
+			}
+			if err := u.PrepareNode(f.Path, 0, overwrite); err != nil {
+				file.Close()
+				return err
+			}
+			return u.WriteFile(file)
+		},
 		"writing file %q", string(f.Path),
 	); err != nil {
 		return fmt.Errorf("failed to create file %q: %v", file.Path, err)
@@ -135,16 +206,32 @@ func (tmp fileEntry) create(l *log.Logger, c *resource.HttpClient, u util.Util)
 
 type dirEntry types.Directory
 
+// create creates the directory along with any missing parents. The new
+// parents and the directory itself, whether or not it already existed, are
+// given the directory's mode and ownership.
 func (tmp dirEntry) create(l *log.Logger, _ *resource.HttpClient, u util.Util) error {
-	d := types.Directory(tmp)
-	err := l.LogOp(func() error {
+	n, err := u.ResolveNodeOwner(types.Node(tmp))
+	if err != nil {
+		return err
+	}
+	d := types.Directory(n)
+	mode := d.Mode.FileMode()
+	if mode == 0 {
+		mode = util.DefaultDirectoryPermissions
+	}
+	err = l.LogOp(func() error {
+		if err := u.PrepareNode(d.Path, os.ModeDir, d.Overwrite); err != nil {
+			return err
+		}
 		path := filepath.Clean(u.JoinPath(string(d.Path)))
 
 		// Build a list of paths to create. Since os.MkdirAll only sets the mode for new directories and not the
 		// ownership, we need to determine which directories will be created so we don't chown something that already
 		// exists.
-		newPaths := []string{}
-		for p := path; p != "/"; p = filepath.Dir(p) {
+		_, err := os.Stat(path)
+		existed := err == nil
+		newPaths := []string{path}
+		for p := filepath.Dir(path); p != "/"; p = filepath.Dir(p) {
 			_, err := os.Stat(p)
 			if err == nil {
 				break
@@ -155,18 +242,25 @@ func (tmp dirEntry) create(l *log.Logger, _ *resource.HttpClient, u util.Util) e
 			newPaths = append(newPaths, p)
 		}
 
-		if err := os.MkdirAll(path, os.FileMode(d.Mode)); err != nil {
+		if err := os.MkdirAll(path, mode); err != nil {
 			return err
 		}
 
+		// Chown before chmod, since changing the owner may clear the
+		// setuid and setgid bits.
 		for _, newPath := range newPaths {
-			if err := os.Chmod(newPath, os.FileMode(d.Mode)); err != nil {
+			if err := os.Chown(newPath, d.User.Uid(), d.Group.Gid()); err != nil {
 				return err
 			}
-			if err := os.Chown(newPath, d.User.Id, d.Group.Id); err != nil {
+			if err := os.Chmod(newPath, mode); err != nil {
 				return err
 			}
 		}
+		// Relabel the topmost new directory, leaving the context of an
+		// existing directory alone.
+		if !existed {
+			u.Relabel.Add(newPaths[len(newPaths)-1])
+		}
 		return nil
 	}, "creating directory %q", string(d.Path))
 	if err != nil {
@@ -176,6 +270,27 @@ func (tmp dirEntry) create(l *log.Logger, _ *resource.HttpClient, u util.Util) e
 	return nil
 }
 
+type linkEntry types.Link
+
+func (tmp linkEntry) create(l *log.Logger, _ *resource.HttpClient, u util.Util) error {
+	link := types.Link(tmp)
+	var err error
+	if link.Node, err = u.ResolveNodeOwner(link.Node); err != nil {
+		return err
+	}
+	kind := "symbolic"
+	if link.Hard {
+		kind = "hard"
+	}
+	if err := l.LogOp(
+		func() error { return u.CreateLink(link) },
+		"creating %s link %q to %q", kind, string(link.Path), link.Target,
+	); err != nil {
+		return fmt.Errorf("failed to create link %q: %v", link.Path, err)
+	}
+	return nil
+}
+
 // ByDirectorySegments is used to sort directories so /foo gets created before /foo/bar if they are both specified.
 type ByDirectorySegments []types.Directory
 
@@ -200,39 +315,81 @@ func (s stage) mapEntriesToFilesystems(config types.Config) (map[types.Filesyste
 
 	entryMap := map[types.Filesystem][]filesystemEntry{}
 
-	// Sort directories to ensure /a gets created before /a/b.
-	sortedDirs := config.Storage.Directories
-	sort.Sort(ByDirectorySegments(sortedDirs))
+	// Sort directories to ensure /a gets created before /a/b. Directories of
+	// the same depth are created in the order they are listed.
+	sortedDirs := append([]types.Directory(nil), config.Storage.Directories...)
+	sort.Stable(ByDirectorySegments(sortedDirs))
 
 	// Add directories first to ensure they are created before files.
+	defaults := config.Storage.Defaults
 	for _, d := range sortedDirs {
-		if fs, ok := filesystems[d.Filesystem]; ok {
-			entryMap[fs] = append(entryMap[fs], dirEntry(d))
-		} else {
-			s.Logger.Crit("the filesystem (%q), was not defined", d.Filesystem)
-			return nil, ErrFilesystemUndefined
+		d = types.Directory(defaults.Apply(types.Node(d), true))
+		fs, err := s.entryFilesystem(filesystems, d.Filesystem)
+		if err != nil {
+			return nil, err
 		}
+		entryMap[fs] = append(entryMap[fs], dirEntry(d))
 	}
 
 	for _, f := range config.Storage.Files {
-		if fs, ok := filesystems[f.Filesystem]; ok {
-			entryMap[fs] = append(entryMap[fs], fileEntry(f))
-		} else {
-			s.Logger.Crit("the filesystem (%q), was not defined", f.Filesystem)
-			return nil, ErrFilesystemUndefined
+		f.Node = defaults.Apply(f.Node, false)
+		fs, err := s.entryFilesystem(filesystems, f.Filesystem)
+		if err != nil {
+			return nil, err
+		}
+		entryMap[fs] = append(entryMap[fs], fileEntry(f))
+	}
+
+	// Links come after files, which hard links may point to.
+	for _, l := range config.Storage.Links {
+		l.Node = defaults.Apply(l.Node, false)
+		fs, err := s.entryFilesystem(filesystems, l.Filesystem)
+		if err != nil {
+			return nil, err
+		}
+		entryMap[fs] = append(entryMap[fs], linkEntry(l))
+	}
+
+	for _, sf := range config.Storage.SwapFiles {
+		fs, err := s.entryFilesystem(filesystems, sf.Filesystem)
+		if err != nil {
+			return nil, err
 		}
+		entryMap[fs] = append(entryMap[fs], swapFileEntry(sf))
 	}
 
 	return entryMap, nil
 }
 
+// entryFilesystem looks up the named filesystem for a file or directory,
+// ensuring it is one Ignition can write into.
+func (s stage) entryFilesystem(filesystems map[string]types.Filesystem, name string) (types.Filesystem, error) {
+	fs, ok := filesystems[name]
+	if !ok {
+		s.Logger.Crit("the filesystem (%q), was not defined", name)
+		return types.Filesystem{}, ErrFilesystemUndefined
+	}
+	if fs.TargetOnly() {
+		s.Logger.Crit("the filesystem (%q) is only mounted on the target system", name)
+		return types.Filesystem{}, ErrFilesystemTargetOnly
+	}
+	if fs.Mount != nil && fs.Mount.Format == "swap" {
+		s.Logger.Crit("the filesystem (%q) is a swap area", name)
+		return types.Filesystem{}, ErrFilesystemSwap
+	}
+	return fs, nil
+}
+
 // createEntries creates any files or directories listed for the filesystem in Storage.{Files,Directories}.
-func (s stage) createEntries(fs types.Filesystem, files []filesystemEntry) error {
+// If dir is not empty, the filesystem is already mounted there.
+func (s stage) createEntries(fs types.Filesystem, files []filesystemEntry, dir string) error {
 	s.Logger.PushPrefix("createFiles")
 	defer s.Logger.PopPrefix()
 
-	var mnt string
-	if fs.Path == nil {
+	mnt := dir
+	switch {
+	case mnt != "":
+	case fs.Path == nil:
 		var err error
 		mnt, err = ioutil.TempDir("", "ignition-files")
 		if err != nil {
@@ -240,28 +397,31 @@ func (s stage) createEntries(fs types.Filesystem, files []filesystemEntry) error
 		}
 		defer os.Remove(mnt)
 
-		dev := string(fs.Mount.Device)
-		format := string(fs.Mount.Format)
-
-		if err := s.Logger.LogOp(
-			func() error { return syscall.Mount(dev, mnt, format, 0, "") },
-			"mounting %q at %q", dev, mnt,
-		); err != nil {
-			return fmt.Errorf("failed to mount device %q at %q: %v", dev, mnt, err)
+		if err := s.MountFilesystem(*fs.Mount, mnt); err != nil {
+			return err
 		}
-		defer s.Logger.LogOp(
-			func() error { return syscall.Unmount(mnt, 0) },
-			"unmounting %q at %q", dev, mnt,
-		)
-	} else {
+		defer s.UnmountFilesystem(*fs.Mount, mnt)
+	default:
 		mnt = string(*fs.Path)
 	}
 
+	// Owners given by name are those of the target root, whatever the
+	// filesystem, including the accounts created in the previous step.
 	u := util.Util{
-		Logger:  s.Logger,
-		DestDir: mnt,
+		Logger:      s.Logger,
+		DestDir:     mnt,
+		Platform:    s.Platform,
+		Context:     s.Context,
+		AccountRoot: s.DestDir,
+	}
+	// Only entries which are visible on the target system can be relabeled.
+	if dir != "" || fs.Path != nil {
+		u.Relabel = s.Relabel
 	}
 
+	files, cancel := s.prefetchFiles(u, files)
+	defer cancel()
+
 	for _, e := range files {
 		if err := e.create(s.Logger, s.client, u); err != nil {
 			return err
@@ -271,20 +431,31 @@ func (s stage) createEntries(fs types.Filesystem, files []filesystemEntry) error
 	return nil
 }
 
-// createUnits creates the units listed under systemd.units and networkd.units.
+// relabelFiles gives everything created on the target system its SELinux
+// context.
+func (s stage) relabelFiles(config types.Config) error {
+	return s.RelabelFiles()
+}
+
+// createUnits creates the units listed under systemd.units and networkd.units,
+// preceded by the units generated for storage.filesystems, storage.swapFiles,
+// system.trustAnchors, and system.postProvision. A listed unit of the same
+// name replaces a generated one.
 func (s stage) createUnits(config types.Config) error {
-	for _, unit := range config.Systemd.Units {
+	swaps, err := swapUnits(config)
+	if err != nil {
+		return err
+	}
+	units := append(mountUnits(config), swaps...)
+	units = append(units, s.trustUnits(config)...)
+	if hook := config.System.PostProvision; hook != nil {
+		units = append(units, util.PostProvisionUnit(*hook, s.ResultFile))
+	}
+	units = append(units, config.Systemd.Units...)
+	for _, unit := range units {
 		if err := s.writeSystemdUnit(unit); err != nil {
 			return err
 		}
-		if unit.Enable {
-			if err := s.Logger.LogOp(
-				func() error { return s.EnableUnit(unit) },
-				"enabling unit %q", unit.Name,
-			); err != nil {
-				return err
-			}
-		}
 		if unit.Mask {
 			if err := s.Logger.LogOp(
 				func() error { return s.MaskUnit(unit) },
@@ -294,12 +465,55 @@ func (s stage) createUnits(config types.Config) error {
 			}
 		}
 	}
+	if err := s.Logger.LogOp(
+		func() error { return s.WritePresets(units) },
+		"enabling and disabling units",
+	); err != nil {
+		return err
+	}
 	for _, unit := range config.Networkd.Units {
 		if err := s.writeNetworkdUnit(unit); err != nil {
 			return err
 		}
 	}
-	return nil
+	if err := s.createPresets(config); err != nil {
+		return err
+	}
+	return s.setDefaultTarget(config)
+}
+
+// createPresets writes the preset files listed under systemd.presets and, if
+// requested, applies every preset on the target root to its units.
+func (s stage) createPresets(config types.Config) error {
+	for _, preset := range config.Systemd.Presets {
+		f := util.FileFromSystemdPreset(preset)
+		if err := s.Logger.LogOp(
+			func() error { return s.WriteFile(f) },
+			"writing preset %q at %q", preset.Name, f.Path,
+		); err != nil {
+			return err
+		}
+	}
+	if !config.Systemd.ApplyPresets {
+		return nil
+	}
+	return s.Logger.LogCmd(
+		tool.Systemctl.Command("--root", s.DestDir, "preset-all"),
+		"applying presets",
+	)
+}
+
+// setDefaultTarget sets the unit the target root boots into, if one was
+// specified in systemd.defaultTarget.
+func (s stage) setDefaultTarget(config types.Config) error {
+	target := config.Systemd.DefaultTarget
+	if target == nil {
+		return nil
+	}
+	return s.Logger.LogCmd(
+		tool.Systemctl.Command("--root", s.DestDir, "set-default", string(*target)),
+		"setting default target to %q", *target,
+	)
 }
 
 // writeSystemdUnit creates the specified unit and any dropins for that unit.
@@ -337,10 +551,25 @@ func (s stage) writeSystemdUnit(unit types.SystemdUnit) error {
 	}, "processing unit %q", unit.Name)
 }
 
-// writeNetworkdUnit creates the specified unit. If the contents of the unit or
-// are empty, the unit is not created.
+// writeNetworkdUnit creates the specified unit and any dropins for that unit.
+// If the contents of the unit or are empty, the unit is not created. The same
+// applies to the unit's dropins.
 func (s stage) writeNetworkdUnit(unit types.NetworkdUnit) error {
 	return s.Logger.LogOp(func() error {
+		for _, dropin := range unit.DropIns {
+			if dropin.Contents == "" {
+				continue
+			}
+
+			f := util.FileFromNetworkdUnitDropin(unit, dropin)
+			if err := s.Logger.LogOp(
+				func() error { return s.WriteFile(f) },
+				"writing drop-in %q at %q", dropin.Name, f.Path,
+			); err != nil {
+				return err
+			}
+		}
+
 		if unit.Contents == "" {
 			return nil
 		}
@@ -357,6 +586,104 @@ func (s stage) writeNetworkdUnit(unit types.NetworkdUnit) error {
 	}, "processing unit %q", unit.Name)
 }
 
+// writeBootloaderConfig writes the bootloader settings described in
+// config.Bootloader. Nothing is written if no settings were provided.
+func (s stage) writeBootloaderConfig(config types.Config) error {
+	grub := config.Bootloader.Grub
+	if len(grub.Users) == 0 && grub.Timeout == nil {
+		return nil
+	}
+
+	f := util.FileFromGrubConfig(grub)
+	return s.Logger.LogOp(
+		func() error { return s.WriteFile(f) },
+		"writing grub config at %q", f.Path,
+	)
+}
+
+// writeMachineId sets or regenerates /etc/machine-id as described in
+// config.System.MachineId. The existing machine-id is preserved otherwise.
+func (s stage) writeMachineId(config types.Config) error {
+	machineId := config.System.MachineId
+	if machineId == nil || machineId.Mode == types.MachineIdPreserve {
+		return nil
+	}
+
+	id := machineId.Id
+	if machineId.Mode == types.MachineIdRegenerate {
+		var err error
+		if id, err = util.NewMachineId(); err != nil {
+			return err
+		}
+	}
+
+	f := util.FileFromMachineId(id)
+	return s.Logger.LogOp(
+		func() error { return s.WriteFile(f) },
+		"writing machine-id %q at %q", id, f.Path,
+	)
+}
+
+// writeHosts merges the entries in config.System.Hosts into /etc/hosts,
+// preserving the rest of the existing file.
+func (s stage) writeHosts(config types.Config) error {
+	if len(config.System.Hosts) == 0 {
+		return nil
+	}
+
+	path := filepath.Join("/etc", "hosts")
+	mode := util.DefaultFilePermissions
+	existing, err := ioutil.ReadFile(s.JoinPath(path))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if info, err := os.Stat(s.JoinPath(path)); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	f := &util.File{
+		Path:       types.Path(path),
+		ReadCloser: ioutil.NopCloser(bytes.NewReader(util.HostsWithEntries(existing, config.System.Hosts))),
+		Mode:       mode,
+	}
+	return s.Logger.LogOp(
+		func() error { return s.WriteFile(f) },
+		"writing %d hosts entries to %q", len(config.System.Hosts), f.Path,
+	)
+}
+
+// writeSysctl writes the kernel parameters in config.System.Sysctl to
+// /etc/sysctl.d.
+func (s stage) writeSysctl(config types.Config) error {
+	if len(config.System.Sysctl) == 0 {
+		return nil
+	}
+
+	f := util.FileFromSysctl(config.System.Sysctl)
+	return s.Logger.LogOp(
+		func() error { return s.WriteFile(f) },
+		"writing sysctl settings at %q", f.Path,
+	)
+}
+
+// writeKernelModules writes the configuration of the kernel modules in
+// config.System.KernelModules to /etc/modules-load.d and /etc/modprobe.d.
+func (s stage) writeKernelModules(config types.Config) error {
+	load, modprobe := util.FilesFromKernelModules(config.System.KernelModules)
+	for _, f := range []*util.File{load, modprobe} {
+		if f == nil {
+			continue
+		}
+		if err := s.Logger.LogOp(
+			func() error { return s.WriteFile(f) },
+			"writing kernel module configuration at %q", f.Path,
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // createPasswd creates the users and groups as described in config.Passwd.
 func (s stage) createPasswd(config types.Config) error {
 	if err := s.createGroups(config); err != nil {
@@ -389,6 +716,11 @@ func (s stage) createUsers(config types.Config) error {
 				u.Name, err)
 		}
 
+		if err := s.LockPassword(u); err != nil {
+			return fmt.Errorf("failed to lock password of %q: %v",
+				u.Name, err)
+		}
+
 		if err := s.AuthorizeSSHKeys(u); err != nil {
 			return fmt.Errorf("failed to add keys to user %q: %v",
 				u.Name, err)
@@ -415,3 +747,62 @@ func (s stage) createGroups(config types.Config) error {
 
 	return nil
 }
+
+// writeLuks writes the key of each volume in config.Storage.Luks, unless it
+// is unlocked by clevis alone, to /etc/luks and lists the volumes in
+// /etc/crypttab, so that they are unlocked at boot. Keys already fetched by
+// the disks stage are written as they were fetched, then discarded.
+func (s stage) writeLuks(config types.Config) error {
+	if len(config.Storage.Luks) == 0 {
+		return nil
+	}
+
+	for _, volume := range config.Storage.Luks {
+		if !volume.HasKeyFile() {
+			continue
+		}
+		key := util.LuksKeyFile(volume)
+		var f *util.File
+		if cached, ok := s.CachedLuksKey(volume); ok {
+			f = &util.File{
+				Path:       key.Path,
+				ReadCloser: ioutil.NopCloser(bytes.NewReader(cached)),
+				Mode:       key.Mode.FileMode(),
+			}
+		} else if f = util.RenderFile(s.Logger, s.client, s.Context, key); f == nil {
+			return fmt.Errorf("failed to resolve key of luks volume %q", volume.Name)
+		}
+		if err := s.Logger.LogOp(
+			func() error { return s.WriteFile(f) },
+			"writing key of luks volume %q to %q", volume.Name, key.Path,
+		); err != nil {
+			return err
+		}
+	}
+
+	path := filepath.Join("/etc", "crypttab")
+	mode := util.DefaultFilePermissions
+	existing, err := ioutil.ReadFile(s.JoinPath(path))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if info, err := os.Stat(s.JoinPath(path)); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	f := &util.File{
+		Path:       types.Path(path),
+		ReadCloser: ioutil.NopCloser(bytes.NewReader(util.CrypttabWithEntries(existing, config.Storage.Luks))),
+		Mode:       mode,
+	}
+	if err := s.Logger.LogOp(
+		func() error { return s.WriteFile(f) },
+		"writing %d luks volumes to %q", len(config.Storage.Luks), f.Path,
+	); err != nil {
+		return err
+	}
+	if err := s.ClearLuksKeyCache(); err != nil {
+		s.Logger.Warning("failed to remove fetched luks keys: %v", err)
+	}
+	return nil
+}
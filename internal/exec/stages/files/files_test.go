@@ -15,15 +15,27 @@
 package files
 
 import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
 	"reflect"
 	"sort"
+	"strings"
 	"testing"
 
 	"github.com/coreos/ignition/config/types"
 	"github.com/coreos/ignition/internal/exec/util"
 	"github.com/coreos/ignition/internal/log"
+	"github.com/coreos/ignition/internal/resource"
 )
 
+func intToPtr(i int) *int {
+	return &i
+}
+
 func TestMapEntriesToFilesystems(t *testing.T) {
 	type in struct {
 		config types.Config
@@ -145,3 +157,629 @@ func TestDirectorySort(t *testing.T) {
 		}
 	}
 }
+
+func TestMountUnits(t *testing.T) {
+	type in struct {
+		config types.Config
+	}
+	type out struct {
+		units []types.SystemdUnit
+	}
+
+	mode := types.NodeMode(01777)
+	upper := types.Path("/var/overlay/upper")
+	work := types.Path("/var/overlay/work")
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{config: types.Config{}},
+			out: out{},
+		},
+		{
+			in: in{config: types.Config{Storage: types.Storage{Filesystems: []types.Filesystem{
+				{Name: "root", Path: func(p types.Path) *types.Path { return &p }("/sysroot")},
+				{Name: "data", Mount: &types.FilesystemMount{
+					Device: "/dev/disk/by-label/DATA",
+					Format: "xfs",
+					Path:   func(p types.Path) *types.Path { return &p }("/var/lib/data"),
+				}},
+				{Name: "home", Mount: &types.FilesystemMount{
+					Device:       "/dev/disk/by-label/HOME",
+					Format:       "btrfs",
+					MountOptions: types.MountOptions{"subvol=@", "compress=zstd"},
+					Create: &types.FilesystemCreate{Subvolumes: []types.BtrfsSubvolume{
+						{Name: "@", Default: true},
+						{Name: "@home", Path: func(p types.Path) *types.Path { return &p }("/home")},
+					}},
+				}},
+				{Name: "shared", Network: &types.FilesystemNetwork{
+					Format:  "nfs4",
+					Source:  "nas:/export/shared",
+					Path:    "/srv/shared",
+					Options: types.MountOptions{"ro", "soft"},
+				}},
+			}}}},
+			out: out{units: []types.SystemdUnit{{
+				Name:   "var-lib-data.mount",
+				Enable: true,
+				Contents: "# Generated by Ignition\n" +
+					"[Mount]\n" +
+					"What=/dev/disk/by-label/DATA\n" +
+					"Where=/var/lib/data\n" +
+					"Type=xfs\n" +
+					"\n[Install]\n" +
+					"WantedBy=local-fs.target\n",
+			}, {
+				Name:   "home.mount",
+				Enable: true,
+				Contents: "# Generated by Ignition\n" +
+					"[Mount]\n" +
+					"What=/dev/disk/by-label/HOME\n" +
+					"Where=/home\n" +
+					"Type=btrfs\n" +
+					"Options=subvol=@home,compress=zstd\n" +
+					"\n[Install]\n" +
+					"WantedBy=local-fs.target\n",
+			}, {
+				Name:   "srv-shared.mount",
+				Enable: true,
+				Contents: "# Generated by Ignition\n" +
+					"[Mount]\n" +
+					"What=nas:/export/shared\n" +
+					"Where=/srv/shared\n" +
+					"Type=nfs4\n" +
+					"Options=ro,soft\n" +
+					"\n[Install]\n" +
+					"WantedBy=remote-fs.target\n",
+			}}},
+		},
+		{
+			in: in{config: types.Config{Storage: types.Storage{Filesystems: []types.Filesystem{
+				{Name: "scratch", Tmpfs: &types.FilesystemTmpfs{Path: "/scratch", Size: "50%", Mode: &mode}},
+				{Name: "etc", Overlay: &types.FilesystemOverlay{
+					Path:  "/etc",
+					Lower: []types.Path{"/usr/share/etc", "/etc"},
+					Upper: &upper,
+					Work:  &work,
+				}},
+			}}}},
+			out: out{units: []types.SystemdUnit{{
+				Name:   "scratch.mount",
+				Enable: true,
+				Contents: "# Generated by Ignition\n" +
+					"[Mount]\n" +
+					"What=tmpfs\n" +
+					"Where=/scratch\n" +
+					"Type=tmpfs\n" +
+					"Options=size=50%,mode=1777\n" +
+					"\n[Install]\n" +
+					"WantedBy=local-fs.target\n",
+			}, {
+				Name:   "etc.mount",
+				Enable: true,
+				Contents: "# Generated by Ignition\n" +
+					"[Mount]\n" +
+					"What=overlay\n" +
+					"Where=/etc\n" +
+					"Type=overlay\n" +
+					"Options=lowerdir=/usr/share/etc:/etc,upperdir=/var/overlay/upper,workdir=/var/overlay/work\n" +
+					"\n[Install]\n" +
+					"WantedBy=local-fs.target\n",
+			}}},
+		},
+		{
+			in: in{config: types.Config{Storage: types.Storage{Filesystems: []types.Filesystem{
+				{Name: "var", Mount: &types.FilesystemMount{
+					Device:       "/dev/disk/by-label/VAR",
+					Format:       "xfs",
+					Path:         func(p types.Path) *types.Path { return &p }("/var"),
+					Create:       &types.FilesystemCreate{Projects: []types.XfsProject{{Id: 1, Directory: "/log"}}},
+					MountOptions: types.MountOptions{"noatime", "nodev"},
+				}},
+			}}}},
+			out: out{units: []types.SystemdUnit{{
+				Name:   "var.mount",
+				Enable: true,
+				Contents: "# Generated by Ignition\n" +
+					"[Mount]\n" +
+					"What=/dev/disk/by-label/VAR\n" +
+					"Where=/var\n" +
+					"Type=xfs\n" +
+					"Options=prjquota,noatime,nodev\n" +
+					"\n[Install]\n" +
+					"WantedBy=local-fs.target\n",
+			}}},
+		},
+	}
+
+	for i, test := range tests {
+		units := mountUnits(test.in.config)
+		if !reflect.DeepEqual(test.out.units, units) {
+			t.Errorf("#%d: bad units: want %#v, got %#v", i, test.out.units, units)
+		}
+	}
+}
+
+func TestTargetMounts(t *testing.T) {
+	type in struct {
+		config types.Config
+	}
+	type out struct {
+		names []string
+		paths []types.Path
+	}
+
+	path := func(p types.Path) *types.Path { return &p }
+	mount := func(dev string, p types.Path) *types.FilesystemMount {
+		return &types.FilesystemMount{Device: types.Path(dev), Format: "xfs", Path: path(p)}
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{config: types.Config{}},
+			out: out{},
+		},
+		{
+			in: in{config: types.Config{Storage: types.Storage{Filesystems: []types.Filesystem{
+				{Name: "root", Path: path("/sysroot")},
+				{Name: "log", Mount: mount("/dev/sdb1", "/var/log")},
+				{Name: "audit", Mount: mount("/dev/sdb2", "/var/log/audit")},
+				{Name: "scratch", Mount: &types.FilesystemMount{Device: "/dev/sdb3", Format: "xfs"}},
+				{Name: "var", Mount: mount("/dev/sda1", "/var")},
+				{Name: "srv", Mount: mount("/dev/sdc1", "/srv")},
+			}}}},
+			out: out{
+				names: []string{"srv", "var", "log", "audit"},
+				paths: []types.Path{"/srv", "/var", "/var/log", "/var/log/audit"},
+			},
+		},
+		{
+			in: in{config: types.Config{Storage: types.Storage{Filesystems: []types.Filesystem{
+				{Name: "var", Mount: mount("/dev/sda1", "/var")},
+				{Name: "data", Mount: mount("/dev/sdb1", "/var/lib/data")},
+				{Name: "var", Mount: &types.FilesystemMount{Device: "/dev/sda1", Format: "xfs"}},
+			}}}},
+			out: out{names: []string{"data"}, paths: []types.Path{"/var/lib/data"}},
+		},
+		{
+			in: in{config: types.Config{Storage: types.Storage{Filesystems: []types.Filesystem{
+				{Name: "root", Path: path("/sysroot")},
+				{Name: "data", Mount: &types.FilesystemMount{
+					Device: "/dev/sdb1",
+					Format: "btrfs",
+					Path:   path("/srv"),
+					Create: &types.FilesystemCreate{Subvolumes: []types.BtrfsSubvolume{
+						{Name: "@", Default: true},
+						{Name: "@www", Path: path("/srv/www")},
+						{Name: "@log", Path: path("/var/log")},
+					}},
+				}},
+			}}}},
+			out: out{names: []string{"data", "", ""}, paths: []types.Path{"/srv", "/srv/www", "/var/log"}},
+		},
+	}
+
+	for i, test := range tests {
+		var names []string
+		var paths []types.Path
+		for _, fs := range targetMounts(test.in.config) {
+			names = append(names, fs.Name)
+			paths = append(paths, *fs.Mount.Path)
+		}
+		if !reflect.DeepEqual(test.out.names, names) {
+			t.Errorf("#%d: bad mounts: want %v, got %v", i, test.out.names, names)
+		}
+		if !reflect.DeepEqual(test.out.paths, paths) {
+			t.Errorf("#%d: bad mount paths: want %v, got %v", i, test.out.paths, paths)
+		}
+	}
+}
+
+func TestSwapUnits(t *testing.T) {
+	type in struct {
+		config types.Config
+	}
+	type out struct {
+		units []types.SystemdUnit
+		err   error
+	}
+
+	path := func(p types.Path) *types.Path { return &p }
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{config: types.Config{}},
+			out: out{},
+		},
+		{
+			in: in{config: types.Config{Storage: types.Storage{
+				Filesystems: []types.Filesystem{
+					{Name: "root", Path: path("/sysroot")},
+					{Name: "swap", Mount: &types.FilesystemMount{
+						Device:       "/dev/disk/by-partlabel/swap",
+						Format:       "swap",
+						MountOptions: types.MountOptions{"pri=10"},
+					}},
+					{Name: "var", Mount: &types.FilesystemMount{Device: "/dev/sdb1", Format: "xfs", Path: path("/var")}},
+				},
+				SwapFiles: []types.SwapFile{
+					{Filesystem: "root", Path: "/swapfile", Size: 1 << 30},
+					{Filesystem: "var", Path: "/swapfile", Size: 1 << 30},
+				},
+			}}},
+			out: out{units: []types.SystemdUnit{{
+				Name:   `dev-disk-by\x2dpartlabel-swap.swap`,
+				Enable: true,
+				Contents: "# Generated by Ignition\n" +
+					"[Swap]\n" +
+					"What=/dev/disk/by-partlabel/swap\n" +
+					"Options=pri=10\n" +
+					"\n[Install]\n" +
+					"WantedBy=swap.target\n",
+			}, {
+				Name:   "swapfile.swap",
+				Enable: true,
+				Contents: "# Generated by Ignition\n" +
+					"[Swap]\n" +
+					"What=/swapfile\n" +
+					"\n[Install]\n" +
+					"WantedBy=swap.target\n",
+			}, {
+				Name:   "var-swapfile.swap",
+				Enable: true,
+				Contents: "# Generated by Ignition\n" +
+					"[Swap]\n" +
+					"What=/var/swapfile\n" +
+					"\n[Install]\n" +
+					"WantedBy=swap.target\n",
+			}}},
+		},
+		{
+			in: in{config: types.Config{Storage: types.Storage{
+				Filesystems: []types.Filesystem{
+					{Name: "scratch", Mount: &types.FilesystemMount{Device: "/dev/sdb1", Format: "xfs"}},
+				},
+				SwapFiles: []types.SwapFile{{Filesystem: "scratch", Path: "/swapfile", Size: 1 << 30}},
+			}}},
+			out: out{err: ErrSwapFileNotMounted},
+		},
+		{
+			in: in{config: types.Config{Storage: types.Storage{
+				SwapFiles: []types.SwapFile{{Filesystem: "missing", Path: "/swapfile", Size: 1 << 30}},
+			}}},
+			out: out{err: ErrFilesystemUndefined},
+		},
+	}
+
+	for i, test := range tests {
+		units, err := swapUnits(test.in.config)
+		if test.out.err != err {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.out.err, err)
+		}
+		if !reflect.DeepEqual(test.out.units, units) {
+			t.Errorf("#%d: bad units: want %#v, got %#v", i, test.out.units, units)
+		}
+	}
+}
+
+func TestAllocateSwapFile(t *testing.T) {
+	type in struct {
+		existing  bool
+		overwrite bool
+	}
+	type out struct {
+		fail bool
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{},
+			out: out{},
+		},
+		{
+			in:  in{existing: true},
+			out: out{fail: true},
+		},
+		{
+			in:  in{existing: true, overwrite: true},
+			out: out{},
+		},
+	}
+
+	for i, test := range tests {
+		dir, err := ioutil.TempDir("", "ignition-swap-test")
+		if err != nil {
+			t.Fatalf("#%d: failed to create temp dir: %v", i, err)
+		}
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, "swapfile")
+		if test.in.existing {
+			if err := ioutil.WriteFile(path, []byte("keep"), 0644); err != nil {
+				t.Fatalf("#%d: failed to write file: %v", i, err)
+			}
+		}
+
+		err = allocateSwapFile(path, 64*1024, test.in.overwrite)
+		if (err != nil) != test.out.fail {
+			t.Errorf("#%d: bad error: want fail %v, got %v", i, test.out.fail, err)
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatalf("#%d: failed to stat swap file: %v", i, err)
+		}
+		if test.out.fail {
+			if info.Size() != 4 {
+				t.Errorf("#%d: existing file was replaced", i)
+			}
+		} else if info.Size() != 64*1024 || info.Mode().Perm() != 0600 {
+			t.Errorf("#%d: bad swap file: want size 65536 mode 0600, got size %d mode %v", i, info.Size(), info.Mode().Perm())
+		}
+	}
+}
+
+func TestDirEntryCreate(t *testing.T) {
+	type in struct {
+		existing []string
+		dir      types.Directory
+	}
+	type out struct {
+		modes map[string]os.FileMode
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in: in{dir: types.Directory{Path: "/a/b", Mode: 0700}},
+			out: out{modes: map[string]os.FileMode{
+				"/a":   0700,
+				"/a/b": 0700,
+			}},
+		},
+		{
+			in: in{existing: []string{"/a"}, dir: types.Directory{Path: "/a/b/c", Mode: 0750}},
+			out: out{modes: map[string]os.FileMode{
+				"/a":     0755,
+				"/a/b":   0750,
+				"/a/b/c": 0750,
+			}},
+		},
+		{
+			in: in{existing: []string{"/a"}, dir: types.Directory{Path: "/a", Mode: 0711}},
+			out: out{modes: map[string]os.FileMode{
+				"/a": 0711,
+			}},
+		},
+		{
+			in: in{dir: types.Directory{Path: "/a"}},
+			out: out{modes: map[string]os.FileMode{
+				"/a": util.DefaultDirectoryPermissions,
+			}},
+		},
+		{
+			in: in{dir: types.Directory{Path: "/a/b", Mode: 03775}},
+			out: out{modes: map[string]os.FileMode{
+				"/a":   os.ModeSetgid | os.ModeSticky | 0775,
+				"/a/b": os.ModeSetgid | os.ModeSticky | 0775,
+			}},
+		},
+	}
+
+	for i, test := range tests {
+		root, err := ioutil.TempDir("", "ignition-files-test")
+		if err != nil {
+			t.Fatalf("#%d: failed to create temp dir: %v", i, err)
+		}
+		defer os.RemoveAll(root)
+
+		for _, dir := range test.in.existing {
+			if err := os.MkdirAll(filepath.Join(root, dir), 0755); err != nil {
+				t.Fatalf("#%d: failed to create %q: %v", i, dir, err)
+			}
+			if err := os.Chmod(filepath.Join(root, dir), 0755); err != nil {
+				t.Fatalf("#%d: failed to chmod %q: %v", i, dir, err)
+			}
+		}
+
+		logger := log.New()
+		d := test.in.dir
+		d.User.Id = intToPtr(os.Getuid())
+		d.Group.Id = intToPtr(os.Getgid())
+		if err := dirEntry(d).create(&logger, nil, util.Util{Logger: &logger, DestDir: root}); err != nil {
+			t.Errorf("#%d: unexpected error: %v", i, err)
+			continue
+		}
+
+		for path, mode := range test.out.modes {
+			info, err := os.Stat(filepath.Join(root, path))
+			if err != nil {
+				t.Errorf("#%d: failed to stat %q: %v", i, path, err)
+				continue
+			}
+			if got := info.Mode() &^ os.ModeType; got != mode {
+				t.Errorf("#%d: bad mode of %q: want %v, got %v", i, path, mode, got)
+			}
+		}
+	}
+}
+
+func TestFileEntryCreate(t *testing.T) {
+	type in struct {
+		existing  string // "dir", "symlink" or none
+		overwrite bool
+	}
+	type out struct {
+		ok bool
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{},
+			out: out{ok: true},
+		},
+		{
+			in:  in{existing: "symlink"},
+			out: out{ok: true},
+		},
+		{
+			in:  in{existing: "dir"},
+			out: out{ok: false},
+		},
+		{
+			in:  in{existing: "dir", overwrite: true},
+			out: out{ok: true},
+		},
+	}
+
+	for i, test := range tests {
+		root, err := ioutil.TempDir("", "ignition-files-test")
+		if err != nil {
+			t.Fatalf("#%d: failed to create temp dir: %v", i, err)
+		}
+		defer os.RemoveAll(root)
+
+		target := filepath.Join(root, "target")
+		if err := ioutil.WriteFile(target, []byte("target"), 0644); err != nil {
+			t.Fatalf("#%d: failed to write target: %v", i, err)
+		}
+		path := filepath.Join(root, "file")
+		switch test.in.existing {
+		case "dir":
+			err = os.Mkdir(path, 0755)
+		case "symlink":
+			err = os.Symlink(target, path)
+		}
+		if err != nil {
+			t.Fatalf("#%d: failed to create existing node: %v", i, err)
+		}
+
+		logger := log.New()
+		c := resource.NewHttpClient(&logger)
+		f := types.File{
+			Node: types.Node{
+				Path:      "/file",
+				Overwrite: test.in.overwrite,
+				User:      types.NodeUser{Id: intToPtr(os.Getuid())},
+				Group:     types.NodeGroup{Id: intToPtr(os.Getgid())},
+			},
+			Contents: types.FileContents{Source: types.Url(url.URL{Scheme: "data", Opaque: ",hello"})},
+		}
+		err = fileEntry(f).create(&logger, &c, util.Util{Logger: &logger, DestDir: root})
+		if test.out.ok != (err == nil) {
+			t.Errorf("#%d: bad error: want ok %v, got %v", i, test.out.ok, err)
+			continue
+		}
+		if !test.out.ok {
+			continue
+		}
+		if data, err := ioutil.ReadFile(path); err != nil || string(data) != "hello" {
+			t.Errorf("#%d: bad file: want %q, got %q (%v)", i, "hello", data, err)
+		}
+		if info, err := os.Lstat(path); err != nil || !info.Mode().IsRegular() {
+			t.Errorf("#%d: file is not a regular file: %v", i, err)
+		}
+		if data, err := ioutil.ReadFile(target); err != nil || string(data) != "target" {
+			t.Errorf("#%d: bad link target: want %q, got %q (%v)", i, "target", data, err)
+		}
+	}
+}
+
+func TestPrefetchFiles(t *testing.T) {
+	type in struct {
+		files []string // the paths served, each with its path as contents, unless under /missing
+	}
+	type out struct {
+		files []string
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/missing/") {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(r.URL.Path))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{files: []string{"/a"}},
+			out: out{files: []string{"/a"}},
+		},
+		{
+			in:  in{files: []string{"/a", "/b", "/c/d"}},
+			out: out{files: []string{"/a", "/b", "/c/d"}},
+		},
+		{
+			in:  in{files: []string{"/a", "/missing/b", "/c/d"}},
+			out: out{files: []string{"/a", "/c/d"}},
+		},
+	}
+
+	for i, test := range tests {
+		root, err := ioutil.TempDir("", "ignition-files-test")
+		if err != nil {
+			t.Fatalf("#%d: failed to create temp dir: %v", i, err)
+		}
+		defer os.RemoveAll(root)
+
+		var entries []filesystemEntry
+		for _, path := range test.in.files {
+			source, err := url.Parse(server.URL + path)
+			if err != nil {
+				t.Fatalf("#%d: bad url: %v", i, err)
+			}
+			entries = append(entries, fileEntry(types.File{
+				Node:     types.Node{Filesystem: "root", Path: types.Path(path), Mode: 0644, User: types.NodeUser{Id: intToPtr(os.Getuid())}, Group: types.NodeGroup{Id: intToPtr(os.Getgid())}},
+				Contents: types.FileContents{Source: types.Url(*source)},
+			}))
+		}
+
+		logger := log.New()
+		client := resource.NewHttpClient(&logger)
+		s := stage{Util: util.Util{Logger: &logger}, client: &client}
+		u := util.Util{Logger: &logger, DestDir: root}
+		prefetched, cancel := s.prefetchFiles(u, entries)
+		for j, e := range prefetched {
+			err := e.create(&logger, &client, u)
+			if missing := strings.HasPrefix(test.in.files[j], "/missing/"); missing != (err != nil) {
+				t.Errorf("#%d: bad error for %q: %v", i, test.in.files[j], err)
+			}
+		}
+		cancel()
+
+		var files []string
+		filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err == nil && info.Mode().IsRegular() {
+				rel := strings.TrimPrefix(path, root)
+				if contents, err := ioutil.ReadFile(path); err != nil || string(contents) != rel {
+					t.Errorf("#%d: bad contents of %q: %q (%v)", i, rel, contents, err)
+				}
+				files = append(files, rel)
+			}
+			return nil
+		})
+		if !reflect.DeepEqual(test.out.files, files) {
+			t.Errorf("#%d: bad files: want %v, got %v", i, test.out.files, files)
+		}
+	}
+}
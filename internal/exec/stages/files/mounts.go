@@ -0,0 +1,261 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package files
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/coreos/ignition/config/types"
+	"github.com/coreos/ignition/internal/exec/util"
+)
+
+// mountUnits returns the mount units needed on the target system for the
+// filesystems in config.
+func mountUnits(config types.Config) []types.SystemdUnit {
+	var units []types.SystemdUnit
+	for _, fs := range config.Storage.Filesystems {
+		if m := fs.Mount; m != nil {
+			if m.Path != nil {
+				var opts []string
+				if m.Create != nil && len(m.Create.Projects) > 0 {
+					opts = append(opts, "prjquota")
+				}
+				opts = append(opts, m.MountOptions...)
+				units = append(units, util.MountUnit(string(m.Device), string(*m.Path), string(m.Format), opts, "local-fs.target"))
+			}
+			if m.Create != nil {
+				for _, sv := range m.Create.Subvolumes {
+					if sv.Path != nil {
+						svm := subvolumeMount(*m, sv)
+						units = append(units, util.MountUnit(string(svm.Device), string(*svm.Path), string(svm.Format), svm.MountOptions, "local-fs.target"))
+					}
+				}
+			}
+		}
+		if n := fs.Network; n != nil {
+			units = append(units, util.MountUnit(n.Source, string(n.Path), string(n.Format), n.Options, "remote-fs.target"))
+		}
+		if t := fs.Tmpfs; t != nil {
+			units = append(units, util.MountUnit("tmpfs", string(t.Path), "tmpfs", tmpfsOptions(*t), "local-fs.target"))
+		}
+		if o := fs.Overlay; o != nil {
+			units = append(units, util.MountUnit("overlay", string(o.Path), "overlay", overlayOptions(*o), "local-fs.target"))
+		}
+	}
+	return units
+}
+
+// subvolumeMount returns how the subvolume sv of the btrfs filesystem m is
+// mounted at its path: with the filesystem's options, but selecting sv.
+func subvolumeMount(m types.FilesystemMount, sv types.BtrfsSubvolume) types.FilesystemMount {
+	m.Path = sv.Path
+	m.MountOptions = append(types.MountOptions{"subvol=" + sv.Name}, m.MountOptions.WithoutSubvol()...)
+	return m
+}
+
+func tmpfsOptions(t types.FilesystemTmpfs) []string {
+	var opts []string
+	if t.Size != "" {
+		opts = append(opts, "size="+t.Size)
+	}
+	if t.Mode != nil {
+		opts = append(opts, fmt.Sprintf("mode=%04o", *t.Mode))
+	}
+	return append(opts, t.Options...)
+}
+
+func overlayOptions(o types.FilesystemOverlay) []string {
+	lower := make([]string, 0, len(o.Lower))
+	for _, l := range o.Lower {
+		lower = append(lower, string(l))
+	}
+	opts := []string{"lowerdir=" + strings.Join(lower, ":")}
+	if o.Upper != nil && o.Work != nil {
+		opts = append(opts, "upperdir="+string(*o.Upper), "workdir="+string(*o.Work))
+	}
+	return append(opts, o.Options...)
+}
+
+// createOverlayDirectories creates the upper and work directories of each
+// writable overlay on the target system, since the kernel requires them to
+// exist at mount time.
+func (s stage) createOverlayDirectories(config types.Config) error {
+	for _, fs := range config.Storage.Filesystems {
+		o := fs.Overlay
+		if o == nil || o.Upper == nil || o.Work == nil {
+			continue
+		}
+		for _, dir := range []types.Path{*o.Upper, *o.Work} {
+			path := s.JoinPath(string(dir))
+			if err := s.Logger.LogOp(
+				func() error { return os.MkdirAll(path, util.DefaultDirectoryPermissions) },
+				"creating overlay directory %q", dir,
+			); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// mountTargetFilesystems mounts the filesystems and btrfs subvolumes which are
+// mounted at a path on the target system at that path within the root
+// filesystem, parents before children, so that files beneath a mount point
+// land in what is mounted there on the target system, and records each
+// mount for the umount stage. It returns where each filesystem was mounted and
+// a function unmounting them again, in reverse, and clearing the record.
+// Nothing is mounted unless config has storage entries to create. The record
+// is started afresh, since one left by an earlier run would have the umount
+// stage unmount what this run didn't mount.
+func (s stage) mountTargetFilesystems(config types.Config) (map[string]string, func(), error) {
+	if err := s.ClearMountRecord(); err != nil {
+		return nil, nil, fmt.Errorf("failed to clear mount record: %v", err)
+	}
+	mounted := map[string]string{}
+	var unmounts []func()
+	unmount := func() {
+		for i := len(unmounts) - 1; i >= 0; i-- {
+			unmounts[i]()
+		}
+		if len(unmounts) != 0 {
+			if err := s.ClearMountRecord(); err != nil {
+				s.Logger.Warning("failed to clear mount record: %v", err)
+			}
+		}
+	}
+
+	st := config.Storage
+	if len(st.Files) == 0 && len(st.Directories) == 0 && len(st.Links) == 0 && len(st.SwapFiles) == 0 {
+		return mounted, unmount, nil
+	}
+	root := lastFilesystem(config, "root")
+	if root == nil || root.Path == nil {
+		return mounted, unmount, nil
+	}
+
+	for _, fs := range targetMounts(config) {
+		m := *fs.Mount
+		dir := filepath.Join(string(*root.Path), string(*m.Path))
+		if err := os.MkdirAll(dir, util.DefaultDirectoryPermissions); err != nil {
+			unmount()
+			return nil, nil, fmt.Errorf("failed to create mount point %q: %v", dir, err)
+		}
+		if err := s.MountFilesystem(m, dir); err != nil {
+			unmount()
+			return nil, nil, err
+		}
+		unmounts = append(unmounts, func() { s.UnmountFilesystem(m, dir) })
+		if err := s.RecordMount(dir); err != nil {
+			unmount()
+			return nil, nil, fmt.Errorf("failed to record mount of %q: %v", dir, err)
+		}
+		// Relabeling is recursive, so only filesystems created for this
+		// system are relabeled as a whole.
+		if m.Create != nil {
+			s.Relabel.Add(dir)
+		}
+		if fs.Name != "" {
+			mounted[fs.Name] = dir
+		}
+	}
+	return mounted, unmount, nil
+}
+
+// lastFilesystem returns the final definition of the named filesystem, or nil
+// if there is none.
+func lastFilesystem(config types.Config, name string) *types.Filesystem {
+	var last *types.Filesystem
+	for i, fs := range config.Storage.Filesystems {
+		if fs.Name == name {
+			last = &config.Storage.Filesystems[i]
+		}
+	}
+	return last
+}
+
+// targetMounts returns the final definitions of the filesystems mounted at a
+// path on the target system, sorted so that /var is mounted before /var/log.
+// Each btrfs subvolume with a path is included as an unnamed filesystem, as
+// entries can only target a filesystem as a whole.
+func targetMounts(config types.Config) []types.Filesystem {
+	var mounts []types.Filesystem
+	seen := map[string]bool{}
+	for i := len(config.Storage.Filesystems) - 1; i >= 0; i-- {
+		fs := config.Storage.Filesystems[i]
+		if seen[fs.Name] {
+			continue
+		}
+		seen[fs.Name] = true
+		if fs.Mount == nil {
+			continue
+		}
+		if fs.Mount.Path != nil {
+			mounts = append(mounts, fs)
+		}
+		if fs.Mount.Create == nil {
+			continue
+		}
+		for _, sv := range fs.Mount.Create.Subvolumes {
+			if sv.Path != nil {
+				svm := subvolumeMount(*fs.Mount, sv)
+				mounts = append(mounts, types.Filesystem{Mount: &svm})
+			}
+		}
+	}
+	sort.Stable(ByMountDepth(mounts))
+	return mounts
+}
+
+// orderedFilesystems returns the filesystems of entryMap in a deterministic
+// order: those with a path, such as the root filesystem, first and the rest
+// by mount depth, with ties broken by the order of their definitions.
+func orderedFilesystems(config types.Config, entryMap map[types.Filesystem][]filesystemEntry) []types.Filesystem {
+	var ordered []types.Filesystem
+	seen := map[types.Filesystem]bool{}
+	for _, fs := range config.Storage.Filesystems {
+		if _, ok := entryMap[fs]; ok && !seen[fs] {
+			ordered = append(ordered, fs)
+			seen[fs] = true
+		}
+	}
+	sort.Stable(ByMountDepth(ordered))
+	return ordered
+}
+
+// ByMountDepth orders filesystems by the depth of their mount path on the
+// target system. Filesystems without one come first.
+type ByMountDepth []types.Filesystem
+
+func (lst ByMountDepth) Len() int { return len(lst) }
+
+func (lst ByMountDepth) Swap(i, j int) {
+	lst[i], lst[j] = lst[j], lst[i]
+}
+
+func (lst ByMountDepth) Less(i, j int) bool {
+	return mountDepth(lst[i]) < mountDepth(lst[j])
+}
+
+func mountDepth(fs types.Filesystem) int {
+	if fs.Mount == nil || fs.Mount.Path == nil {
+		return 0
+	}
+	d := types.Directory{Path: *fs.Mount.Path}
+	return 1 + d.Depth()
+}
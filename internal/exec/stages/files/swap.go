@@ -0,0 +1,143 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package files
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/coreos/ignition/config/types"
+	"github.com/coreos/ignition/internal/exec/util"
+	"github.com/coreos/ignition/internal/log"
+	"github.com/coreos/ignition/internal/resource"
+	"github.com/coreos/ignition/internal/tool"
+)
+
+type swapFileEntry types.SwapFile
+
+// create allocates the swap file and formats it with mkswap. The file is
+// fully allocated, since the kernel refuses swap files with holes.
+func (tmp swapFileEntry) create(l *log.Logger, _ *resource.HttpClient, u util.Util) error {
+	sf := types.SwapFile(tmp)
+	path := u.JoinPath(string(sf.Path))
+
+	if err := l.LogOp(
+		func() error {
+			if err := u.PrepareNode(sf.Path, 0, sf.Overwrite); err != nil {
+				return err
+			}
+			return allocateSwapFile(path, int64(sf.Size), sf.Overwrite)
+		},
+		"allocating swap file %q", string(sf.Path),
+	); err != nil {
+		return fmt.Errorf("failed to allocate swap file %q: %v", sf.Path, err)
+	}
+
+	args := []string{"--force"}
+	if sf.Label != nil {
+		args = append(args, "-L", *sf.Label)
+	}
+	if sf.Uuid != nil {
+		args = append(args, "-U", *sf.Uuid)
+	}
+	if err := l.LogCmd(
+		tool.Mkswap.Command(append(args, path)...),
+		"creating swap area in %q", string(sf.Path),
+	); err != nil {
+		return fmt.Errorf("failed to create swap area in %q: %v", sf.Path, err)
+	}
+	u.Relabel.Add(path)
+	return nil
+}
+
+// allocateSwapFile creates the file at path with size bytes allocated,
+// writing zeros where the filesystem does not support fallocate. An existing
+// file is replaced only if overwrite is set.
+func allocateSwapFile(path string, size int64, overwrite bool) error {
+	if err := util.MkdirForFile(path); err != nil {
+		return err
+	}
+	flags := os.O_WRONLY | os.O_CREATE | os.O_EXCL
+	if overwrite {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	}
+	f, err := os.OpenFile(path, flags, 0600)
+	if os.IsExist(err) {
+		return fmt.Errorf("%q already exists; set overwrite to replace it", path)
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+	// The mode of an existing file is left alone by OpenFile.
+	if err := f.Chmod(0600); err != nil {
+		return err
+	}
+
+	if err := syscall.Fallocate(int(f.Fd()), 0, 0, size); err == nil {
+		return f.Sync()
+	} else if err != syscall.EOPNOTSUPP {
+		return err
+	}
+
+	zeros := make([]byte, 1<<20)
+	for remaining := size; remaining > 0; {
+		n := int64(len(zeros))
+		if n > remaining {
+			n = remaining
+		}
+		if _, err := f.Write(zeros[:n]); err != nil {
+			return err
+		}
+		remaining -= n
+	}
+	return f.Sync()
+}
+
+// swapUnits returns the swap units activating the swap filesystems and swap
+// files of config on the target system.
+func swapUnits(config types.Config) ([]types.SystemdUnit, error) {
+	var units []types.SystemdUnit
+	for _, fs := range config.Storage.Filesystems {
+		if m := fs.Mount; m != nil && m.Format == "swap" {
+			units = append(units, util.SwapUnit(string(m.Device), m.MountOptions))
+		}
+	}
+	for _, sf := range config.Storage.SwapFiles {
+		path, err := swapFileTargetPath(config, sf)
+		if err != nil {
+			return nil, err
+		}
+		units = append(units, util.SwapUnit(path, sf.Options))
+	}
+	return units, nil
+}
+
+// swapFileTargetPath returns the path of sf on the target system. The swap
+// file must be on the root filesystem or on one mounted at a path.
+func swapFileTargetPath(config types.Config, sf types.SwapFile) (string, error) {
+	fs := lastFilesystem(config, sf.Filesystem)
+	switch {
+	case fs == nil:
+		return "", ErrFilesystemUndefined
+	case fs.Path != nil:
+		return string(sf.Path), nil
+	case fs.Mount != nil && fs.Mount.Path != nil:
+		return filepath.Join(string(*fs.Mount.Path), string(sf.Path)), nil
+	default:
+		return "", ErrSwapFileNotMounted
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package files
+
+import (
+	"fmt"
+
+	"github.com/coreos/ignition/config/types"
+	"github.com/coreos/ignition/internal/exec/util"
+)
+
+// trustAnchors returns the CA certificates to add to the trust store of the
+// target root: those in config.System.TrustAnchors, followed by the CAs
+// Ignition trusted for its own fetches.
+func trustAnchors(config types.Config) []types.CaReference {
+	return append(append([]types.CaReference{}, config.System.TrustAnchors...),
+		config.Ignition.Security.Tls.CertificateAuthorities...)
+}
+
+// installTrustAnchors writes the CA certificates from trustAnchors into the
+// system trust store of the target root. The store itself is rebuilt on
+// first boot by the unit from trustUnits.
+func (s stage) installTrustAnchors(config types.Config) error {
+	anchors := trustAnchors(config)
+	if len(anchors) == 0 {
+		return nil
+	}
+	s.Logger.PushPrefix("installTrustAnchors")
+	defer s.Logger.PopPrefix()
+
+	store := s.TrustStore()
+	for i, ca := range anchors {
+		f := util.RenderFile(s.Logger, s.client, s.Context, types.File{
+			Node: types.Node{
+				Path: store.TrustAnchorPath(i),
+				Mode: types.NodeMode(util.DefaultFilePermissions),
+			},
+			Contents: types.FileContents{
+				Source:       ca.Source,
+				Verification: ca.Verification,
+			},
+		})
+		if f == nil {
+			return fmt.Errorf("failed to resolve CA certificate %q", ca.Source.String())
+		}
+		if err := s.Logger.LogOp(
+			func() error { return s.WriteFile(f) },
+			"writing CA certificate at %q", f.Path,
+		); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// trustUnits returns the units needed on the target system to add the CA
+// certificates from trustAnchors to its trust store.
+func (s stage) trustUnits(config types.Config) []types.SystemdUnit {
+	if len(trustAnchors(config)) == 0 {
+		return nil
+	}
+	return []types.SystemdUnit{util.TrustUpdateUnit(s.TrustStore())}
+}
@@ -0,0 +1,205 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// The kargs stage is responsible for applying the kernel arguments of
+// config.KernelArguments to the boot loader config of the target system and
+// for requesting a reboot if the running kernel lacks them, so that they are
+// in effect from the system's first boot.
+
+package kargs
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/coreos/ignition/config/types"
+	"github.com/coreos/ignition/internal/distro"
+	"github.com/coreos/ignition/internal/exec/stages"
+	"github.com/coreos/ignition/internal/exec/util"
+	"github.com/coreos/ignition/internal/log"
+	"github.com/coreos/ignition/internal/resource"
+
+	"golang.org/x/net/context"
+)
+
+const (
+	name = "kargs"
+)
+
+var (
+	// CmdlinePath is the command line of the running kernel.
+	CmdlinePath = "/proc/cmdline"
+)
+
+// rebootMarker is written to the state directory when the running kernel was
+// not booted with the requested arguments, listing them as "+arg" and "-arg"
+// lines, so that the initramfs can reboot into the updated boot loader
+// config.
+const rebootMarker = "kargs-reboot"
+
+func init() {
+	stages.Register(creator{})
+}
+
+type creator struct{}
+
+func (creator) Create(logger *log.Logger, client *resource.HttpClient, ctx context.Context, root, stateDir, resultFile, platform string) stages.Stage {
+	return &stage{
+		Util: util.Util{
+			DestDir:  root,
+			StateDir: stateDir,
+			Platform: platform,
+			Context:  ctx,
+			Logger:   logger,
+		},
+	}
+}
+
+func (creator) Name() string {
+	return name
+}
+
+type stage struct {
+	util.Util
+}
+
+func (stage) Name() string {
+	return name
+}
+
+// Run applies config.KernelArguments, either through the distribution's hook
+// or by editing the target system's boot loader config, with its /boot
+// filesystem mounted if the config declares one.
+func (s stage) Run(config types.Config) error {
+	kargs := config.KernelArguments
+	if kargs.Empty() {
+		return nil
+	}
+	add := argStrings(kargs.ShouldExist)
+	remove := argStrings(kargs.ShouldNotExist)
+
+	if hook := distro.KargsHook(); hook != "" {
+		if err := s.Logger.LogCmd(
+			exec.Command(hook, hookArgs(s.DestDir, add, remove)...),
+			"applying kernel arguments with %q", hook,
+		); err != nil {
+			return fmt.Errorf("failed to apply kernel arguments: %v", err)
+		}
+	} else {
+		unmount, err := s.mountBoot(config)
+		if err != nil {
+			return err
+		}
+		defer unmount()
+
+		if err := s.Logger.LogOp(func() error {
+			_, err := s.UpdateKernelArguments(add, remove)
+			return err
+		}, "updating kernel arguments"); err != nil {
+			return fmt.Errorf("failed to apply kernel arguments: %v", err)
+		}
+	}
+
+	return s.requestReboot(add, remove)
+}
+
+// mountBoot mounts the filesystem config declares at /boot on the target
+// system within the target root, unless its boot loader entries are already
+// visible there, and returns a function unmounting it again.
+func (s stage) mountBoot(config types.Config) (func(), error) {
+	nothing := func() {}
+	boot := bootFilesystem(config)
+	if boot == nil {
+		return nothing, nil
+	}
+	dir := s.JoinPath("/boot")
+	if entries, err := util.BLSEntries(dir); err == nil && len(entries) != 0 {
+		return nothing, nil
+	}
+	if err := os.MkdirAll(dir, util.DefaultDirectoryPermissions); err != nil {
+		return nil, fmt.Errorf("failed to create mount point %q: %v", dir, err)
+	}
+	if err := s.MountFilesystem(*boot, dir); err != nil {
+		return nil, err
+	}
+	return func() { s.UnmountFilesystem(*boot, dir) }, nil
+}
+
+// bootFilesystem returns the final definition of the filesystem mounted at
+// /boot on the target system, or nil if there is none.
+func bootFilesystem(config types.Config) *types.FilesystemMount {
+	seen := map[string]bool{}
+	for i := len(config.Storage.Filesystems) - 1; i >= 0; i-- {
+		fs := config.Storage.Filesystems[i]
+		if seen[fs.Name] {
+			continue
+		}
+		seen[fs.Name] = true
+		if m := fs.Mount; m != nil && m.Path != nil && filepath.Clean(string(*m.Path)) == "/boot" {
+			return m
+		}
+	}
+	return nil
+}
+
+// requestReboot writes rebootMarker if the running kernel's command line
+// does not already reflect add and remove.
+func (s stage) requestReboot(add, remove []string) error {
+	cmdline, err := ioutil.ReadFile(CmdlinePath)
+	if err != nil {
+		return fmt.Errorf("failed to read kernel command line: %v", err)
+	}
+	if util.KernelArgumentsApplied(string(cmdline), add, remove) {
+		return nil
+	}
+
+	var lines []string
+	for _, arg := range add {
+		lines = append(lines, "+"+arg)
+	}
+	for _, arg := range remove {
+		lines = append(lines, "-"+arg)
+	}
+	return s.Logger.LogOp(func() error {
+		marker := s.StatePath(rebootMarker)
+		if err := os.MkdirAll(filepath.Dir(marker), util.DefaultDirectoryPermissions); err != nil {
+			return err
+		}
+		return util.WriteFileAtomic(marker, []byte(strings.Join(lines, "\n")+"\n"), util.DefaultFilePermissions)
+	}, "requesting a reboot for the new kernel arguments")
+}
+
+// hookArgs returns the arguments to the distribution's kargs hook.
+func hookArgs(root string, add, remove []string) []string {
+	args := []string{"--root", root}
+	for _, arg := range add {
+		args = append(args, "--should-exist", arg)
+	}
+	for _, arg := range remove {
+		args = append(args, "--should-not-exist", arg)
+	}
+	return args
+}
+
+func argStrings(args []types.KernelArgument) []string {
+	strs := make([]string, 0, len(args))
+	for _, arg := range args {
+		strs = append(strs, string(arg))
+	}
+	return strs
+}
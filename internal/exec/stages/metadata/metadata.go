@@ -0,0 +1,129 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// The metadata stage is responsible for writing the platform metadata of the
+// machine (e.g. its instance ID, region, and IP addresses) to an environment
+// file under /run for consumption by units on the provisioned system.
+
+package metadata
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/coreos/ignition/config/types"
+	"github.com/coreos/ignition/internal/exec/stages"
+	"github.com/coreos/ignition/internal/exec/util"
+	"github.com/coreos/ignition/internal/log"
+	"github.com/coreos/ignition/internal/oem"
+	"github.com/coreos/ignition/internal/resource"
+
+	"golang.org/x/net/context"
+)
+
+const (
+	name = "metadata"
+
+	// envPrefix is prepended to the attribute names in the environment file.
+	envPrefix = "IGNITION_"
+)
+
+// metadataFile is the environment file written to the state directory. /run
+// is carried over into the provisioned system when the initramfs switches
+// root.
+const metadataFile = "metadata.env"
+
+func init() {
+	stages.Register(creator{})
+}
+
+type creator struct{}
+
+func (creator) Create(logger *log.Logger, client *resource.HttpClient, ctx context.Context, root, stateDir, resultFile, platform string) stages.Stage {
+	return &stage{
+		Util: util.Util{
+			DestDir:  root,
+			StateDir: stateDir,
+			Platform: platform,
+			Context:  ctx,
+			Logger:   logger,
+		},
+		client: client,
+	}
+}
+
+func (creator) Name() string {
+	return name
+}
+
+type stage struct {
+	util.Util
+
+	client *resource.HttpClient
+}
+
+func (stage) Name() string {
+	return name
+}
+
+// Run writes the metadata environment file. Platforms without a metadata
+// service are skipped. A failure to reach the metadata service is logged but
+// does not fail provisioning, since the metadata is advisory.
+func (s stage) Run(_ types.Config) error {
+	cfg, ok := oem.Get(s.Platform)
+	if !ok || cfg.MetadataFunc() == nil {
+		s.Logger.Info("no metadata available for platform %q", s.Platform)
+		return nil
+	}
+
+	attrs, err := cfg.MetadataFunc()(s.Logger, s.client, s.Context)
+	if err != nil {
+		s.Logger.Err("failed to fetch platform metadata: %v", err)
+		return nil
+	}
+
+	path := s.StatePath(metadataFile)
+	if err := s.Logger.LogOp(
+		func() error { return writeMetadata(path, attrs) },
+		"writing %d metadata attributes to %q", len(attrs), path,
+	); err != nil {
+		return fmt.Errorf("failed to write platform metadata: %v", err)
+	}
+
+	return nil
+}
+
+// writeMetadata writes attrs to path as a systemd EnvironmentFile, sorted by
+// name.
+func writeMetadata(path string, attrs map[string]string) error {
+	names := []string{}
+	for name := range attrs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	buf := &bytes.Buffer{}
+	for _, name := range names {
+		fmt.Fprintf(buf, "%s%s=%s\n", envPrefix, name, attrs[name])
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), util.DefaultDirectoryPermissions); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, buf.Bytes(), util.DefaultFilePermissions)
+}
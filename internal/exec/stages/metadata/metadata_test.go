@@ -0,0 +1,70 @@
+// Copyright 2015 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metadata
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteMetadata(t *testing.T) {
+	type in struct {
+		attrs map[string]string
+	}
+	type out struct {
+		env string
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{attrs: map[string]string{}},
+			out: out{env: ""},
+		},
+		{
+			in: in{attrs: map[string]string{
+				"REGION":      "us-east-1",
+				"INSTANCE_ID": "i-0123456789abcdef0",
+				"IPV4_PUBLIC": "203.0.113.7",
+			}},
+			out: out{env: "IGNITION_INSTANCE_ID=i-0123456789abcdef0\nIGNITION_IPV4_PUBLIC=203.0.113.7\nIGNITION_REGION=us-east-1\n"},
+		},
+	}
+
+	dir, err := ioutil.TempDir("", "ignition-metadata")
+	if err != nil {
+		t.Fatalf("failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	for i, test := range tests {
+		path := filepath.Join(dir, "run", "metadata.env")
+		if err := writeMetadata(path, test.in.attrs); err != nil {
+			t.Errorf("#%d: unexpected error: %v", i, err)
+			continue
+		}
+		env, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Errorf("#%d: failed to read metadata: %v", i, err)
+		}
+		if string(env) != test.out.env {
+			t.Errorf("#%d: bad metadata: want %q, got %q", i, test.out.env, string(env))
+		}
+	}
+}
@@ -20,7 +20,7 @@ import (
 
 // Name is used to identify a StageCreator (which instantiates the stage of
 // the same name) from the command line. It must be in the set of registered
-// stages.
+// stages or All.
 type Name string
 
 func (s Name) String() string {
@@ -28,7 +28,7 @@ func (s Name) String() string {
 }
 
 func (s *Name) Set(val string) error {
-	if stage := Get(val); stage == nil {
+	if stage := Get(val); stage == nil && val != All && val != Fetch && val != FetchOffline {
 		return fmt.Errorf("%s is not a valid stage", val)
 	}
 
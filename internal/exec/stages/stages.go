@@ -19,21 +19,43 @@ import (
 	"github.com/coreos/ignition/internal/log"
 	"github.com/coreos/ignition/internal/registry"
 	"github.com/coreos/ignition/internal/resource"
+
+	"golang.org/x/net/context"
 )
 
 // Stage is responsible for actually executing a stage of the configuration.
+// Run returns why the stage failed, if it did.
 type Stage interface {
-	Run(config types.Config) bool
+	Run(config types.Config) error
 	Name() string
 }
 
 // StageCreator is responsible for instantiating a particular stage given a
-// logger and root path under the root partition.
+// logger, the context cancelling it, root path under the root partition, the
+// directory in which stages keep state for later ones, the path of the result
+// file on the target system, and the name of the platform.
 type StageCreator interface {
-	Create(logger *log.Logger, client *resource.HttpClient, root string) Stage
+	Create(logger *log.Logger, client *resource.HttpClient, ctx context.Context, root, stateDir, resultFile, platform string) Stage
 	Name() string
 }
 
+// All is the pseudo-stage name that selects every registered stage.
+const All = "all"
+
+// Fetch is the pseudo-stage name that only acquires and caches the config,
+// so that the stages run later, or again, use the cached copy rather than
+// fetching it anew.
+const Fetch = "fetch"
+
+// FetchOffline is the pseudo-stage name that only acquires and caches the
+// config, without using the network, and reports whether the remaining
+// stages need it.
+const FetchOffline = "fetch-offline"
+
+// canonicalOrder is the order in which stages run when All is selected.
+// Registered stages not listed here run afterward in alphabetical order.
+var canonicalOrder = []string{"disks", "files", "kargs", "metadata", "umount"}
+
 var stages = registry.Create("stages")
 
 func Register(stage StageCreator) {
@@ -50,3 +72,21 @@ func Get(name string) StageCreator {
 func Names() (names []string) {
 	return stages.Names()
 }
+
+// Ordered returns the names of all registered stages in the order in which
+// they must run.
+func Ordered() (names []string) {
+	seen := map[string]struct{}{}
+	for _, name := range canonicalOrder {
+		if Get(name) != nil {
+			names = append(names, name)
+			seen[name] = struct{}{}
+		}
+	}
+	for _, name := range Names() {
+		if _, ok := seen[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	return
+}
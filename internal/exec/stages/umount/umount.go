@@ -0,0 +1,97 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// The umount stage is responsible for unmounting the filesystems which the
+// files stage left mounted within the target root, in the reverse of the order
+// they were mounted, and syncing them, so that the root filesystem is cleanly
+// detached before the initramfs switches root.
+
+package umount
+
+import (
+	"fmt"
+	"syscall"
+
+	"github.com/coreos/ignition/config/types"
+	"github.com/coreos/ignition/internal/exec/stages"
+	"github.com/coreos/ignition/internal/exec/util"
+	"github.com/coreos/ignition/internal/log"
+	"github.com/coreos/ignition/internal/resource"
+
+	"golang.org/x/net/context"
+)
+
+const (
+	name = "umount"
+)
+
+func init() {
+	stages.Register(creator{})
+}
+
+type creator struct{}
+
+func (creator) Create(logger *log.Logger, client *resource.HttpClient, ctx context.Context, root, stateDir, resultFile, platform string) stages.Stage {
+	return &stage{
+		Util: util.Util{
+			DestDir:  root,
+			StateDir: stateDir,
+			Platform: platform,
+			Context:  ctx,
+			Logger:   logger,
+		},
+	}
+}
+
+func (creator) Name() string {
+	return name
+}
+
+type stage struct {
+	util.Util
+}
+
+func (stage) Name() string {
+	return name
+}
+
+// Run unmounts the filesystems in the mount record, last mounted first, and
+// syncs all filesystems. Mount points which are no longer mounted, as when
+// the record is left over from an earlier run, are skipped.
+func (s stage) Run(config types.Config) error {
+	dirs, err := s.RecordedMounts()
+	if err != nil {
+		return fmt.Errorf("failed to read mount record: %v", err)
+	}
+
+	for i := len(dirs) - 1; i >= 0; i-- {
+		dir := dirs[i]
+		if err := s.Logger.LogOp(func() error {
+			err := syscall.Unmount(dir, 0)
+			if err == syscall.EINVAL || err == syscall.ENOENT {
+				return nil
+			}
+			return err
+		}, "unmounting %q", dir); err != nil {
+			return fmt.Errorf("failed to unmount %q: %v", dir, err)
+		}
+	}
+
+	s.Logger.LogOp(func() error {
+		syscall.Sync()
+		return nil
+	}, "syncing filesystems")
+
+	return s.ClearMountRecord()
+}
@@ -0,0 +1,116 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"time"
+
+	"github.com/coreos/ignition/config/types"
+)
+
+const (
+	// StatusSignatureHeader carries the HMAC-SHA256 of a status report's
+	// body under the signing key, as "sha256=<hex>".
+	StatusSignatureHeader = "X-Ignition-Signature"
+
+	// statusReportTimeout bounds the time spent delivering a report, so that
+	// an unreachable endpoint doesn't hold up provisioning.
+	statusReportTimeout = 30 * time.Second
+)
+
+var (
+	errFetchFailed   = errors.New("the config could not be fetched")
+	errConfigInvalid = errors.New("the config is invalid")
+)
+
+// statusReport is the body POSTed to the status report endpoint: the outcome
+// of one stage along with the results recorded so far.
+type statusReport struct {
+	Stage   string `json:"stage"`
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+	// Time is when the report was sent, so that endpoints can reject
+	// replayed reports.
+	Time string `json:"time"`
+	*results
+}
+
+// fetchError returns the error reported for a failure to render the config.
+func fetchError(result Result) error {
+	if result == ResultConfigInvalid {
+		return errConfigInvalid
+	}
+	return errFetchFailed
+}
+
+// reportStatus POSTs the outcome of the named stage to the endpoint named by
+// cfg, or else by e.StatusReport. Failing to deliver a report is logged but
+// doesn't fail provisioning.
+func (e Engine) reportStatus(cfg types.Config, stage string, err error) {
+	endpoint := cfg.Ignition.StatusReport
+	if endpoint == nil {
+		endpoint = e.StatusReport
+	}
+	if endpoint == nil || e.results == nil {
+		return
+	}
+
+	report := statusReport{
+		Stage:   stage,
+		Success: err == nil,
+		Time:    time.Now().UTC().Format(time.RFC3339),
+		results: e.results,
+	}
+	if err != nil {
+		report.Error = err.Error()
+	}
+	body, merr := json.Marshal(report)
+	if merr != nil {
+		e.Logger.Warning("failed to marshal status report: %v", merr)
+		return
+	}
+
+	header := endpoint.HttpHeaders.Header()
+	header.Set("Content-Type", "application/json")
+	if endpoint.SigningKey != "" {
+		header.Set(StatusSignatureHeader, "sha256="+signStatus(endpoint.SigningKey, body))
+	}
+
+	client := e.client
+	client.SetTimeout(statusReportTimeout)
+	u := url.URL(endpoint.Url)
+	status, perr := client.PostWithHeader(e.ctx, u.String(), body, header)
+	switch {
+	case perr != nil:
+		e.Logger.Warning("failed to send status report: %v", perr)
+	case status < 200 || status > 299:
+		e.Logger.Warning("status report was rejected with %d", status)
+	default:
+		e.Logger.Info("sent status report for stage %q", stage)
+	}
+}
+
+// signStatus returns the hex HMAC-SHA256 of body under key.
+func signStatus(key string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
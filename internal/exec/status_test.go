@@ -0,0 +1,122 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/coreos/ignition/config/types"
+	"github.com/coreos/ignition/internal/exec/stages"
+	"github.com/coreos/ignition/internal/log"
+
+	"golang.org/x/net/context"
+)
+
+func TestReportStatus(t *testing.T) {
+	type in struct {
+		config   string
+		endpoint bool
+	}
+	type out struct {
+		result    Result
+		reported  bool
+		success   bool
+		signature bool
+	}
+
+	type received struct {
+		report struct {
+			Stage   string `json:"stage"`
+			Success bool   `json:"success"`
+		}
+		signature string
+	}
+	var reports []received
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		rec := received{signature: r.Header.Get(StatusSignatureHeader)}
+		if err := json.Unmarshal(body, &rec.report); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		reports = append(reports, rec)
+		if sig := r.Header.Get(StatusSignatureHeader); sig != "" && sig != "sha256="+signStatus("secret", body) {
+			http.Error(w, "bad signature", http.StatusForbidden)
+		}
+	}))
+	defer server.Close()
+
+	const header = `{"ignition":{"version":"2.1.0-experimental"`
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{config: header + `,"statusReport":{"url":"SERVER/status","signingKey":"secret"}}}`},
+			out: out{result: ResultSuccess, reported: true, success: true, signature: true},
+		},
+		{
+			in:  in{config: header + `}}`, endpoint: true},
+			out: out{result: ResultSuccess, reported: true, success: true},
+		},
+		{
+			in:  in{config: `{`, endpoint: true},
+			out: out{result: ResultConfigInvalid, reported: true},
+		},
+		{
+			in:  in{config: header + `}}`},
+			out: out{result: ResultSuccess},
+		},
+	}
+
+	u, err := url.Parse(server.URL + "/status")
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := log.New()
+	for i, test := range tests {
+		reports = nil
+		e := Engine{
+			Logger:      &logger,
+			LocalConfig: []byte(strings.Replace(test.in.config, "SERVER", server.URL, -1)),
+		}
+		if test.in.endpoint {
+			e.StatusReport = &types.StatusReport{Url: types.Url(*u)}
+		}
+		if result := e.Run(context.Background(), stages.Fetch); result != test.out.result {
+			t.Errorf("#%d: bad result: want %v, got %v", i, test.out.result, result)
+		}
+		if (len(reports) != 0) != test.out.reported {
+			t.Errorf("#%d: bad report count: got %d", i, len(reports))
+			continue
+		}
+		if len(reports) == 0 {
+			continue
+		}
+		r := reports[0]
+		if r.report.Stage != stages.Fetch || r.report.Success != test.out.success {
+			t.Errorf("#%d: bad report: want stage %q success %t, got %q %t", i, stages.Fetch, test.out.success, r.report.Stage, r.report.Success)
+		}
+		if (r.signature != "") != test.out.signature {
+			t.Errorf("#%d: bad signature: %q", i, r.signature)
+		}
+	}
+}
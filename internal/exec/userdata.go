@@ -0,0 +1,81 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	osexec "os/exec"
+	"path/filepath"
+
+	"github.com/coreos/ignition/config"
+	"github.com/coreos/ignition/internal/exec/util"
+	"github.com/coreos/ignition/internal/providers"
+)
+
+// DefaultUserdataFile is where userdata which isn't an Ignition config is
+// left for other agents, such as cloud-init, to apply.
+const DefaultUserdataFile = "/run/ignition/userdata"
+
+// isUserdata reports whether err reports userdata which isn't an Ignition
+// config.
+func isUserdata(err error) bool {
+	if u, ok := err.(providers.UserdataError); ok {
+		err = u.Err
+	}
+	return err == config.ErrCloudConfig || err == config.ErrScript
+}
+
+// userdataKind names the kind of userdata reported by err, as passed to the
+// handler.
+func userdataKind(err error) string {
+	if err == config.ErrScript {
+		return "script"
+	}
+	return "cloud-config"
+}
+
+// handOffUserdata writes the userdata of u to e.UserdataFile and runs
+// e.UserdataHandler, if any, as "<handler> <kind> <file>", where kind is
+// cloud-config or script. Userdata already handed off during this boot isn't
+// handed off again.
+func (e Engine) handOffUserdata(u providers.UserdataError) error {
+	if e.UserdataFile == "" {
+		return nil
+	}
+	if prev, err := ioutil.ReadFile(e.UserdataFile); err == nil && bytes.Equal(prev, u.Data) {
+		e.Logger.Debug("userdata was already handed off")
+		return nil
+	}
+
+	kind := userdataKind(u.Err)
+	if err := os.MkdirAll(filepath.Dir(e.UserdataFile), 0755); err != nil {
+		return err
+	}
+	if err := util.WriteFileAtomic(e.UserdataFile, u.Data, 0600); err != nil {
+		return err
+	}
+	e.Logger.Info("wrote %s userdata to %q", kind, e.UserdataFile)
+	if e.UserdataHandler == "" {
+		return nil
+	}
+	err := e.Logger.LogCmd(osexec.Command(e.UserdataHandler, kind, e.UserdataFile), "handing off %s userdata to %q", kind, e.UserdataHandler)
+	if err != nil {
+		// Let a later run retry the handler.
+		os.Remove(e.UserdataFile)
+	}
+	return err
+}
@@ -0,0 +1,92 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/coreos/ignition/config"
+	"github.com/coreos/ignition/internal/log"
+	"github.com/coreos/ignition/internal/providers"
+)
+
+func TestHandOffUserdata(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ignition-userdata")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	calls := filepath.Join(dir, "calls")
+	handler := filepath.Join(dir, "handler")
+	script := "#!/bin/sh\necho \"$1 $(cat \"$2\")\" >> " + calls + "\n"
+	if err := ioutil.WriteFile(handler, []byte(script), 0755); err != nil {
+		t.Fatalf("failed to write handler: %v", err)
+	}
+	failing := filepath.Join(dir, "failing")
+	if err := ioutil.WriteFile(failing, []byte("#!/bin/sh\nexit 1\n"), 0755); err != nil {
+		t.Fatalf("failed to write handler: %v", err)
+	}
+
+	type in struct {
+		handler  string
+		userdata providers.UserdataError
+	}
+	type out struct {
+		fail  bool
+		calls string
+	}
+
+	script1 := providers.UserdataError{Err: config.ErrScript, Data: []byte("#!/bin/sh")}
+	cloudConfig := providers.UserdataError{Err: config.ErrCloudConfig, Data: []byte("#cloud-config")}
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{handler: handler, userdata: script1},
+			out: out{calls: "script #!/bin/sh\n"},
+		},
+		// The same userdata is only handed off once per boot.
+		{
+			in:  in{handler: handler, userdata: script1},
+			out: out{calls: "script #!/bin/sh\n"},
+		},
+		{
+			in:  in{handler: failing, userdata: cloudConfig},
+			out: out{fail: true, calls: "script #!/bin/sh\n"},
+		},
+		// A failed hand-off is retried.
+		{
+			in:  in{handler: handler, userdata: cloudConfig},
+			out: out{calls: "script #!/bin/sh\ncloud-config #cloud-config\n"},
+		},
+	}
+
+	logger := log.New()
+	for i, test := range tests {
+		e := Engine{Logger: &logger, UserdataFile: filepath.Join(dir, "run", "userdata"), UserdataHandler: test.in.handler}
+		if err := e.handOffUserdata(test.in.userdata); (err != nil) != test.out.fail {
+			t.Errorf("#%d: bad error: want failure %t, got %v", i, test.out.fail, err)
+		}
+		b, _ := ioutil.ReadFile(calls)
+		if string(b) != test.out.calls {
+			t.Errorf("#%d: bad handler calls: want %q, got %q", i, test.out.calls, string(b))
+		}
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/coreos/ignition/config/types"
+)
+
+const (
+	// GrubUsersPermissions keeps the password hashes away from unprivileged
+	// users.
+	GrubUsersPermissions os.FileMode = 0700
+)
+
+func GrubConfigPath() string {
+	return filepath.Join("etc", "grub.d", "01_ignition")
+}
+
+// FileFromGrubConfig returns a grub.d script which emits the configured
+// users and settings when grub-mkconfig generates the bootloader config.
+func FileFromGrubConfig(grub types.Grub) *File {
+	buf := &bytes.Buffer{}
+	buf.WriteString("#!/bin/sh\n# Generated by Ignition\ncat <<'EOF'\n")
+
+	superusers := []string{}
+	for _, u := range grub.Users {
+		if u.Superuser {
+			superusers = append(superusers, u.Name)
+		}
+	}
+	if len(superusers) > 0 {
+		fmt.Fprintf(buf, "set superusers=\"%s\"\n", strings.Join(superusers, " "))
+	}
+	for _, u := range grub.Users {
+		if u.PasswordHash != "" {
+			fmt.Fprintf(buf, "password_pbkdf2 %s %s\n", u.Name, u.PasswordHash)
+		}
+	}
+	if grub.Timeout != nil {
+		fmt.Fprintf(buf, "set timeout=%d\n", *grub.Timeout)
+	}
+
+	buf.WriteString("EOF\n")
+
+	return &File{
+		Path:       types.Path(GrubConfigPath()),
+		ReadCloser: ioutil.NopCloser(buf),
+		Mode:       GrubUsersPermissions,
+	}
+}
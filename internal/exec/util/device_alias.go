@@ -15,12 +15,20 @@
 package util
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
 )
 
 const deviceAliasDir = "/dev_aliases"
 
+// devicePollInterval is how often WaitForDevicePaths checks for the paths.
+var devicePollInterval = 100 * time.Millisecond
+
 // DeviceAlias returns the aliased form of the supplied path.
 // Note device paths in ignition are always absolute.
 func DeviceAlias(path string) string {
@@ -53,3 +61,43 @@ func CreateDeviceAlias(path string) (string, error) {
 
 	return target, nil
 }
+
+// WaitForDevicePaths waits for each of paths to exist, such as the
+// /dev/disk/by-* links udev creates for a partition that was just created,
+// logging each one it has to wait for. It gives up once timeout elapses or
+// u.Context is done.
+func (u Util) WaitForDevicePaths(paths []string, timeout time.Duration) error {
+	ctx := u.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	waiting := map[string]bool{}
+	for {
+		missing := []string{}
+		for _, path := range paths {
+			if _, err := os.Stat(path); err == nil {
+				continue
+			}
+			missing = append(missing, path)
+			if !waiting[path] {
+				u.Info("waiting for %q to appear", path)
+				waiting[path] = true
+			}
+		}
+		if len(missing) == 0 {
+			return nil
+		}
+
+		select {
+		case <-time.After(devicePollInterval):
+		case <-ctx.Done():
+			if ctx.Err() == context.DeadlineExceeded {
+				return fmt.Errorf("gave up after %v waiting for %s", timeout, strings.Join(missing, ", "))
+			}
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,63 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/coreos/ignition/internal/log"
+)
+
+func TestWaitForDevicePaths(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ignition-devices")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	present := filepath.Join(dir, "present")
+	late := filepath.Join(dir, "by-partlabel", "ROOT")
+	missing := filepath.Join(dir, "missing")
+	if err := ioutil.WriteFile(present, nil, 0644); err != nil {
+		t.Fatalf("failed to create device: %v", err)
+	}
+
+	logger := log.New()
+	u := Util{Logger: &logger}
+	defer func(d time.Duration) { devicePollInterval = d }(devicePollInterval)
+	devicePollInterval = time.Millisecond
+
+	if err := u.WaitForDevicePaths([]string{present}, time.Second); err != nil {
+		t.Errorf("unexpected error waiting for existing device: %v", err)
+	}
+
+	// udev creates the link while the stage is waiting.
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		os.MkdirAll(filepath.Dir(late), 0755)
+		os.Symlink(present, late)
+	}()
+	if err := u.WaitForDevicePaths([]string{present, late}, 5*time.Second); err != nil {
+		t.Errorf("unexpected error waiting for late device: %v", err)
+	}
+
+	if err := u.WaitForDevicePaths([]string{present, missing}, 20*time.Millisecond); err == nil {
+		t.Errorf("waiting for missing device succeeded")
+	}
+}
@@ -0,0 +1,218 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/url"
+	"reflect"
+	"strings"
+
+	"github.com/coreos/ignition/config/types"
+)
+
+const (
+	redacted        = "<redacted>"
+	defaultedKey    = "//"
+	defaultedMarker = "from base config"
+)
+
+// sensitiveKeys are the config keys whose values are never logged.
+var sensitiveKeys = map[string]struct{}{
+	"inline":       {},
+	"password":     {},
+	"passwordHash": {},
+	"signingKey":   {},
+}
+
+// headerKeys are the config keys of headers, whose values may carry
+// credentials.
+var headerKeys = map[string]struct{}{
+	"header":      {},
+	"httpHeaders": {},
+}
+
+// DumpConfig renders cfg as canonical, indented JSON suitable for debug
+// logging. Secrets, including the contents of data URLs, are redacted and
+// every object that was merged in unchanged from defaults is annotated.
+func DumpConfig(cfg, defaults types.Config) string {
+	tree, err := toTree(cfg)
+	if err != nil {
+		return err.Error()
+	}
+	defaultTree, err := toTree(defaults)
+	if err != nil {
+		return err.Error()
+	}
+
+	annotateDefaults(tree, defaultTree)
+	redact(tree, false)
+
+	b, err := encodeTree(tree)
+	if err != nil {
+		return err.Error()
+	}
+	return strings.TrimSuffix(string(b), "\n")
+}
+
+// RedactConfig renders cfg as canonical, indented JSON with its secrets
+// redacted as by DumpConfig, but without annotations, so that it remains a
+// valid config.
+func RedactConfig(cfg types.Config) ([]byte, error) {
+	tree, err := toTree(cfg)
+	if err != nil {
+		return nil, err
+	}
+	redact(tree, true)
+	return encodeTree(tree)
+}
+
+func encodeTree(tree interface{}) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(tree); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// toTree converts v into the generic map/slice form produced by
+// encoding/json, which marshals back out with sorted keys.
+func toTree(v interface{}) (interface{}, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var tree interface{}
+	err = json.Unmarshal(b, &tree)
+	return tree, err
+}
+
+// annotateDefaults marks the objects in node's arrays that also appear in
+// the corresponding array of defaults.
+func annotateDefaults(node, defaults interface{}) {
+	switch n := node.(type) {
+	case map[string]interface{}:
+		d, _ := defaults.(map[string]interface{})
+		for k, v := range n {
+			annotateDefaults(v, d[k])
+		}
+	case []interface{}:
+		d, _ := defaults.([]interface{})
+		for _, v := range n {
+			obj, ok := v.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			for _, dv := range d {
+				if reflect.DeepEqual(obj, dv) {
+					obj[defaultedKey] = defaultedMarker
+					break
+				}
+			}
+		}
+	}
+}
+
+// redact replaces sensitive values within node in place: the values of
+// sensitive keys and headers, the contents of data URLs, and the credentials
+// of other URLs. If escape is set, the markers replacing parts of URLs are
+// escaped to keep the URLs valid.
+func redact(node interface{}, escape bool) {
+	switch n := node.(type) {
+	case map[string]interface{}:
+		for k, v := range n {
+			if _, ok := sensitiveKeys[k]; ok {
+				n[k] = redacted
+				continue
+			}
+			if _, ok := headerKeys[k]; ok {
+				redactHeaders(v)
+				continue
+			}
+			if s, ok := v.(string); ok {
+				n[k] = redactUrl(k, s, escape)
+				continue
+			}
+			redact(v, escape)
+		}
+	case []interface{}:
+		for _, v := range n {
+			redact(v, escape)
+		}
+	}
+}
+
+// redactHeaders replaces the values of the header, or array of headers, at
+// node.
+func redactHeaders(node interface{}) {
+	headers, ok := node.([]interface{})
+	if !ok {
+		headers = []interface{}{node}
+	}
+	for _, h := range headers {
+		if header, ok := h.(map[string]interface{}); ok {
+			if _, ok := header["value"]; ok {
+				header["value"] = redacted
+			}
+		}
+	}
+}
+
+// redactUrl returns s, the value of key k, with the contents redacted if it is
+// a data URL source, or with the user info redacted if it is another URL.
+func redactUrl(k, s string, escape bool) string {
+	marker := redacted
+	if escape {
+		marker = url.QueryEscape(redacted)
+	}
+	if k == "source" && strings.HasPrefix(s, "data:") {
+		if escape {
+			return "data:," + marker
+		}
+		return "data:" + marker
+	}
+	u, err := url.Parse(s)
+	if err != nil || u.User == nil || !strings.Contains(s, "://") {
+		return s
+	}
+	u.User = nil
+	return strings.Replace(u.String(), "://", "://"+marker+"@", 1)
+}
+
+// RedactSource returns the source URL s as it may be shown to the user: data
+// URLs lose their contents and other URLs their user info.
+func RedactSource(s string) string {
+	return redactUrl("source", s, false)
+}
+
+// SensitivePath reports whether the value at the JSON pointer path is one
+// that redact replaces, so that reports can avoid quoting it.
+func SensitivePath(path string) bool {
+	tokens := strings.Split(path, "/")
+	last := tokens[len(tokens)-1]
+	if _, ok := sensitiveKeys[last]; ok {
+		return true
+	}
+	for _, token := range tokens {
+		if _, ok := headerKeys[token]; ok {
+			return true
+		}
+	}
+	return false
+}
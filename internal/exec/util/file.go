@@ -16,14 +16,13 @@ package util
 
 import (
 	"bufio"
-	"compress/gzip"
-	"encoding/hex"
-	"hash"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"net/url"
 	"os"
 	"path/filepath"
+	"syscall"
 
 	"github.com/coreos/ignition/config/types"
 	"github.com/coreos/ignition/internal/log"
@@ -39,121 +38,164 @@ const (
 
 type File struct {
 	io.ReadCloser
-	hash.Hash
-	Path        types.Path
-	Mode        os.FileMode
-	Uid         int
-	Gid         int
-	expectedSum string
+	Path types.Path
+	Mode os.FileMode
+	Uid  int
+	Gid  int
+	// fetched is the resource the contents were fetched from, if any.
+	fetched *resource.Resource
+	// appended is read after the contents, if not nil.
+	appended io.ReadCloser
+	// keepExisting is whether the contents follow those of any existing
+	// file rather than replacing them.
+	keepExisting bool
+	// fetchedPath is the file holding the contents as fetched, if any.
+	fetchedPath string
+	// templated is whether the contents are rendered as a template.
+	templated bool
 }
 
+// Verify checks the fetched contents, once they have been read, against the
+// hash declared for them.
 func (f File) Verify() error {
-	if f.Hash == nil {
+	if f.fetched == nil {
 		return nil
 	}
-	sum := f.Sum(nil)
-	encodedSum := make([]byte, hex.EncodedLen(len(sum)))
-	hex.Encode(encodedSum, sum)
-
-	if string(encodedSum) != f.expectedSum {
-		return ErrHashMismatch{
-			Calculated: string(encodedSum),
-			Expected:   f.expectedSum,
-		}
-	}
-	return nil
-}
-
-// newHashedReader returns a new ReadCloser that also writes to the provided hash.
-func newHashedReader(reader io.ReadCloser, hasher hash.Hash) io.ReadCloser {
-	return struct {
-		io.Reader
-		io.Closer
-	}{
-		Reader: io.TeeReader(reader, hasher),
-		Closer: reader,
-	}
+	return f.fetched.Verify()
 }
 
 // RenderFile returns a *File with a Reader that downloads, hashes, and decompresses the incoming data.
 // It returns nil if f had invalid options. Errors reading/verifying/decompressing the file will
 // present themselves when the Reader is actually read from.
-func RenderFile(l *log.Logger, c *resource.HttpClient, f types.File) *File {
-	var reader io.ReadCloser
-	var err error
-	var expectedSum string
+func RenderFile(l *log.Logger, c *resource.HttpClient, ctx context.Context, f types.File) *File {
+	fetcher := resource.Fetcher{Logger: l, Client: c, Context: ctx}
+	opts := resource.FetchOptions{
+		Verification: f.Contents.Verification,
+		Compression:  f.Contents.Compression,
+	}
 
-	reader, err = resource.FetchAsReader(l, c, context.Background(), url.URL(f.Contents.Source))
-	if err != nil {
-		l.Crit("Error fetching file %q: %v", f.Path, err)
+	if !validAppend(l, f) {
 		return nil
 	}
 
-	fileHash, err := GetHasher(f.Contents.Verification)
+	var fetched *resource.Resource
+	var err error
+	if len(f.Contents.Fragments) != 0 {
+		for _, frag := range f.Contents.Fragments {
+			if _, err := resource.GetHasher(frag.Verification); err != nil {
+				l.Crit("Error verifying fragment of file %q: %v", f.Path, err)
+				return nil
+			}
+		}
+		fetched, err = resource.NewResource(newFragmentReader(fetcher, f.Contents.Fragments), opts)
+	} else {
+		opts.Headers = f.Contents.HttpHeaders.Header()
+		fetched, err = fetcher.Open(url.URL(f.Contents.Source), opts)
+	}
 	if err != nil {
-		l.Crit("Error verifying file %q: %v", f.Path, err)
+		l.Crit("Error fetching file %q: %v", f.Path, err)
 		return nil
 	}
 
-	if fileHash != nil {
-		reader = newHashedReader(reader, fileHash)
-		expectedSum = f.Contents.Verification.Hash.Sum
-	}
+	return newFile(fetcher, f, fetched, fetched)
+}
 
-	reader, err = decompressFileStream(l, f, reader)
-	if err != nil {
-		l.Crit("Error decompressing file %q: %v", f.Path, err)
+// RenderFetchedFile is RenderFile for a file whose contents were already
+// fetched and verified into the file at path, as by FetchToFile. Where
+// possible, WriteFile moves that file into place rather than copying it.
+func RenderFetchedFile(l *log.Logger, c *resource.HttpClient, ctx context.Context, f types.File, path string) *File {
+	if !validAppend(l, f) {
 		return nil
 	}
-
-	return &File{
-		Path:        f.Path,
-		ReadCloser:  reader,
-		Hash:        fileHash,
-		Mode:        os.FileMode(f.Mode),
-		Uid:         f.User.Id,
-		Gid:         f.Group.Id,
-		expectedSum: expectedSum,
+	contents, err := os.Open(path)
+	if err != nil {
+		l.Crit("Error opening fetched contents of file %q: %v", f.Path, err)
+		return nil
 	}
+	file := newFile(resource.Fetcher{Logger: l, Client: c, Context: ctx}, f, contents, nil)
+	file.fetchedPath = path
+	return file
 }
 
-// gzipReader is a wrapper for gzip's reader that closes the stream it wraps as well
-// as itself when Close() is called.
-type gzipReader struct {
-	*gzip.Reader //actually a ReadCloser
-	source       io.Closer
-}
+// FetchToFile fetches the contents of f into a new file in dir, verifying
+// them, and returns the new file's path. The caller removes the file.
+func FetchToFile(l *log.Logger, c *resource.HttpClient, ctx context.Context, f types.File, dir string) (string, error) {
+	file := RenderFile(l, c, ctx, types.File{Node: f.Node, Contents: f.Contents})
+	if file == nil {
+		return "", fmt.Errorf("failed to resolve file %q", f.Path)
+	}
+	defer file.Close()
 
-func newGzipReader(reader io.ReadCloser) (io.ReadCloser, error) {
-	gzReader, err := gzip.NewReader(reader)
+	tmp, err := ioutil.TempFile(dir, ".ignition-fetch")
+	if err != nil {
+		return "", err
+	}
+	if _, err = io.Copy(tmp, file); err == nil {
+		err = file.Verify()
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
 	if err != nil {
-		return nil, err
+		os.Remove(tmp.Name())
+		return "", err
 	}
-	return gzipReader{
-		Reader: gzReader,
-		source: reader,
-	}, nil
+	return tmp.Name(), nil
 }
 
-func (gz gzipReader) Close() error {
-	if err := gz.Reader.Close(); err != nil {
-		return err
+// moveFetched moves the fetched contents of f into place at path when they are
+// the whole of the file, reporting whether it did. Contents on another
+// filesystem are left to be copied.
+func (f *File) moveFetched(path string) (bool, error) {
+	if f.fetchedPath == "" || f.templated || f.keepExisting || f.appended != nil {
+		return false, nil
 	}
-	if err := gz.source.Close(); err != nil {
-		return err
+	if err := os.Chown(f.fetchedPath, f.Uid, f.Gid); err != nil {
+		return false, err
+	}
+	if err := os.Chmod(f.fetchedPath, f.Mode); err != nil {
+		return false, err
 	}
-	return nil
+	if err := os.Rename(f.fetchedPath, path); err != nil {
+		if linkErr, ok := err.(*os.LinkError); ok && linkErr.Err == syscall.EXDEV {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, SyncDir(filepath.Dir(path))
 }
 
-func decompressFileStream(l *log.Logger, f types.File, contents io.ReadCloser) (io.ReadCloser, error) {
-	switch f.Contents.Compression {
-	case "":
-		return contents, nil
-	case "gzip":
-		return newGzipReader(contents)
-	default:
-		return nil, types.ErrCompressionInvalid
+// validAppend checks the fragments appended to f, logging any which cannot
+// be verified.
+func validAppend(l *log.Logger, f types.File) bool {
+	for _, frag := range f.Append {
+		if _, err := resource.GetHasher(frag.Verification); err != nil {
+			l.Crit("Error verifying appended fragment of file %q: %v", f.Path, err)
+			return false
+		}
+	}
+	return true
+}
+
+// newFile returns the File for f reading its contents from contents, which
+// are verified against fetched if it is not nil.
+func newFile(fetcher resource.Fetcher, f types.File, contents io.ReadCloser, fetched *resource.Resource) *File {
+	file := &File{
+		Path:       f.Path,
+		ReadCloser: contents,
+		Mode:       f.Mode.FileMode(),
+		Uid:        f.User.Uid(),
+		Gid:        f.Group.Gid(),
+		fetched:    fetched,
+	}
+	if len(f.Append) != 0 {
+		file.appended = newFragmentReader(fetcher, f.Append)
+		file.keepExisting = f.Contents.Source.String() == "" && len(f.Contents.Fragments) == 0
 	}
+	return file
 }
 
 // WriteFile creates and writes the file described by f using the provided context.
@@ -163,8 +205,15 @@ func (u Util) WriteFile(f *File) error {
 
 	path := u.JoinPath(string(f.Path))
 
-	if err := MkdirForFile(path); err != nil {
+	if err := u.mkdirForFile(path); err != nil {
+		return err
+	}
+
+	if moved, err := f.moveFetched(path); err != nil {
 		return err
+	} else if moved {
+		u.Relabel.Add(path)
+		return nil
 	}
 
 	// Create a temporary file in the same directory to ensure it's on the same filesystem
@@ -182,15 +231,33 @@ func (u Util) WriteFile(f *File) error {
 
 	fileWriter := bufio.NewWriter(tmp)
 
+	if f.keepExisting {
+		if err = copyExisting(fileWriter, path); err != nil {
+			return err
+		}
+	}
+
 	if _, err = io.Copy(fileWriter, f); err != nil {
 		return err
 	}
-	fileWriter.Flush()
 
 	if err = f.Verify(); err != nil {
 		return err
 	}
 
+	if f.appended != nil {
+		defer f.appended.Close()
+		if _, err = io.Copy(fileWriter, f.appended); err != nil {
+			return err
+		}
+	}
+	if err = fileWriter.Flush(); err != nil {
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		return err
+	}
+
 	// XXX(vc): Note that we assume to be operating on the file we just wrote, this is only guaranteed
 	// by using syscall.Fchown() and syscall.Fchmod()
 
@@ -206,8 +273,101 @@ func (u Util) WriteFile(f *File) error {
 	if err = os.Rename(tmp.Name(), path); err != nil {
 		return err
 	}
+	u.Relabel.Add(path)
+
+	return SyncDir(filepath.Dir(path))
+}
+
+// WriteFileAtomic writes data to path with the given mode by way of a
+// temporary file in the same directory, which is synced and renamed into
+// place. A crash leaves either the old contents at path or the new ones,
+// never a partial write.
+func WriteFileAtomic(path string, data []byte, mode os.FileMode) (err error) {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".ignition")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			os.Remove(tmp.Name())
+		}
+	}()
 
-	return nil
+	if _, err = tmp.Write(data); err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	if err = os.Chmod(tmp.Name(), mode); err != nil {
+		return err
+	}
+	if err = os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	return SyncDir(filepath.Dir(path))
+}
+
+// SyncDir syncs the directory dir, making renames and new entries within it
+// durable.
+func SyncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// PrepareNode prepares path, within DestDir, for a node of type typ, which is
+// os.ModeDir, os.ModeSymlink, or 0 for a regular file. A node of a different
+// type already at path is removed, along with any contents, if overwrite is
+// set and is otherwise an error.
+func (u Util) PrepareNode(path types.Path, typ os.FileMode, overwrite bool) error {
+	p := u.JoinPath(string(path))
+	info, err := os.Lstat(p)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeType == typ {
+		return nil
+	}
+	if !overwrite {
+		return fmt.Errorf("%q already exists as %s; set overwrite to replace it", path, describeNode(info.Mode()))
+	}
+	return os.RemoveAll(p)
+}
+
+// describeNode names the type of node with mode m.
+func describeNode(m os.FileMode) string {
+	switch {
+	case m.IsRegular():
+		return "a regular file"
+	case m.IsDir():
+		return "a directory"
+	case m&os.ModeSymlink != 0:
+		return "a symbolic link"
+	default:
+		return "a special file"
+	}
+}
+
+// copyExisting copies the contents of the file at path, if there is one, to w.
+func copyExisting(w io.Writer, path string) error {
+	existing, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer existing.Close()
+	_, err = io.Copy(w, existing)
+	return err
 }
 
 // MkdirForFile helper creates the directory components of path.
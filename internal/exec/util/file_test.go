@@ -0,0 +1,328 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/coreos/ignition/config/types"
+	"github.com/coreos/ignition/internal/log"
+)
+
+func intToPtr(i int) *int {
+	return &i
+}
+
+func TestWriteFileAppend(t *testing.T) {
+	type in struct {
+		existing *string
+		file     types.File
+	}
+	type out struct {
+		data string
+	}
+
+	str := func(s string) *string { return &s }
+	data := func(s string) types.Url {
+		return types.Url(url.URL{Scheme: "data", Opaque: "," + s})
+	}
+	node := types.Node{Filesystem: "root", Path: "/etc/hosts", Mode: 0644}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{existing: str("base\n"), file: types.File{Node: node, Contents: types.FileContents{Source: data("new%0A")}}},
+			out: out{data: "new\n"},
+		},
+		{
+			in: in{existing: str("base\n"), file: types.File{Node: node, Append: []types.FileFragment{
+				{Inline: "one\n"},
+				{Source: data("two%0A")},
+			}}},
+			out: out{data: "base\none\ntwo\n"},
+		},
+		{
+			in: in{file: types.File{Node: node, Append: []types.FileFragment{
+				{Inline: "one\n"},
+			}}},
+			out: out{data: "one\n"},
+		},
+		{
+			in: in{existing: str("base\n"), file: types.File{Node: node, Contents: types.FileContents{Source: data("new%0A")}, Append: []types.FileFragment{
+				{Inline: "one\n"},
+			}}},
+			out: out{data: "new\none\n"},
+		},
+	}
+
+	logger := log.New()
+	for i, test := range tests {
+		root, err := ioutil.TempDir("", "ignition-file-test")
+		if err != nil {
+			t.Fatalf("#%d: failed to create temp dir: %v", i, err)
+		}
+		defer os.RemoveAll(root)
+
+		u := Util{Logger: &logger, DestDir: root}
+		path := u.JoinPath(string(test.in.file.Path))
+		if test.in.existing != nil {
+			if err := MkdirForFile(path); err != nil {
+				t.Fatalf("#%d: failed to create directory: %v", i, err)
+			}
+			if err := ioutil.WriteFile(path, []byte(*test.in.existing), 0644); err != nil {
+				t.Fatalf("#%d: failed to write existing file: %v", i, err)
+			}
+		}
+
+		f := test.in.file
+		f.User.Id = intToPtr(os.Getuid())
+		f.Group.Id = intToPtr(os.Getgid())
+		file := RenderFile(&logger, nil, nil, f)
+		if file == nil {
+			t.Errorf("#%d: failed to render file", i)
+			continue
+		}
+		if err := u.WriteFile(file); err != nil {
+			t.Errorf("#%d: unexpected error: %v", i, err)
+			continue
+		}
+
+		contents, err := ioutil.ReadFile(filepath.Join(root, "etc", "hosts"))
+		if err != nil {
+			t.Errorf("#%d: failed to read file: %v", i, err)
+			continue
+		}
+		if string(contents) != test.out.data {
+			t.Errorf("#%d: bad contents: want %q, got %q", i, test.out.data, string(contents))
+		}
+	}
+}
+
+func TestPrepareNode(t *testing.T) {
+	type in struct {
+		existing  os.FileMode // the type of node already at the path, or none
+		typ       os.FileMode
+		overwrite bool
+	}
+	type out struct {
+		ok      bool
+		removed bool
+	}
+
+	const none = os.FileMode(1<<32 - 1)
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{existing: none, typ: 0},
+			out: out{ok: true},
+		},
+		{
+			in:  in{existing: 0, typ: 0},
+			out: out{ok: true},
+		},
+		{
+			in:  in{existing: os.ModeDir, typ: os.ModeDir},
+			out: out{ok: true},
+		},
+		{
+			in:  in{existing: os.ModeDir, typ: 0},
+			out: out{ok: false},
+		},
+		{
+			in:  in{existing: os.ModeDir, typ: 0, overwrite: true},
+			out: out{ok: true, removed: true},
+		},
+		{
+			in:  in{existing: os.ModeSymlink, typ: 0},
+			out: out{ok: false},
+		},
+		{
+			in:  in{existing: os.ModeSymlink, typ: os.ModeDir, overwrite: true},
+			out: out{ok: true, removed: true},
+		},
+		{
+			in:  in{existing: 0, typ: os.ModeSymlink, overwrite: true},
+			out: out{ok: true, removed: true},
+		},
+	}
+
+	for i, test := range tests {
+		root, err := ioutil.TempDir("", "ignition-node-test")
+		if err != nil {
+			t.Fatalf("#%d: failed to create temp dir: %v", i, err)
+		}
+		defer os.RemoveAll(root)
+
+		path := filepath.Join(root, "node")
+		switch test.in.existing {
+		case none:
+		case os.ModeDir:
+			err = os.MkdirAll(filepath.Join(path, "child"), 0755)
+		case os.ModeSymlink:
+			err = os.Symlink("/etc", path)
+		default:
+			err = ioutil.WriteFile(path, nil, 0644)
+		}
+		if err != nil {
+			t.Fatalf("#%d: failed to create existing node: %v", i, err)
+		}
+
+		u := Util{DestDir: root}
+		err = u.PrepareNode("/node", test.in.typ, test.in.overwrite)
+		if test.out.ok != (err == nil) {
+			t.Errorf("#%d: bad error: want ok %v, got %v", i, test.out.ok, err)
+		}
+		_, err = os.Lstat(path)
+		if removed := os.IsNotExist(err); test.in.existing != none && removed != test.out.removed {
+			t.Errorf("#%d: bad removal: want %v, got %v", i, test.out.removed, removed)
+		}
+	}
+}
+
+func TestWriteFileFetched(t *testing.T) {
+	type in struct {
+		file types.File
+	}
+	type out struct {
+		data  string
+		moved bool
+	}
+
+	node := types.Node{Filesystem: "root", Path: "/etc/fetched", Mode: 0600}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{file: types.File{Node: node}},
+			out: out{data: "fetched\n", moved: true},
+		},
+		{
+			in:  in{file: types.File{Node: node, Append: []types.FileFragment{{Inline: "appended\n"}}}},
+			out: out{data: "fetched\nappended\n", moved: false},
+		},
+	}
+
+	logger := log.New()
+	for i, test := range tests {
+		root, err := ioutil.TempDir("", "ignition-file-test")
+		if err != nil {
+			t.Fatalf("#%d: failed to create temp dir: %v", i, err)
+		}
+		defer os.RemoveAll(root)
+
+		fetched := filepath.Join(root, ".ignition-fetch")
+		if err := ioutil.WriteFile(fetched, []byte("fetched\n"), 0644); err != nil {
+			t.Fatalf("#%d: failed to write fetched contents: %v", i, err)
+		}
+		u := Util{Logger: &logger, DestDir: root}
+		f := test.in.file
+		f.User.Id = intToPtr(os.Getuid())
+		f.Group.Id = intToPtr(os.Getgid())
+		file := RenderFetchedFile(&logger, nil, nil, f, fetched)
+		if file == nil {
+			t.Errorf("#%d: failed to render file", i)
+			continue
+		}
+		if err := u.WriteFile(file); err != nil {
+			t.Errorf("#%d: unexpected error: %v", i, err)
+			continue
+		}
+
+		path := filepath.Join(root, "etc", "fetched")
+		contents, err := ioutil.ReadFile(path)
+		if err != nil || string(contents) != test.out.data {
+			t.Errorf("#%d: bad contents: want %q, got %q (%v)", i, test.out.data, contents, err)
+		}
+		if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+			t.Errorf("#%d: bad mode: want 0600, got %v (%v)", i, info.Mode(), err)
+		}
+		if _, err := os.Stat(fetched); os.IsNotExist(err) != test.out.moved {
+			t.Errorf("#%d: bad move: want %v, got %v", i, test.out.moved, os.IsNotExist(err))
+		}
+	}
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	type in struct {
+		existing string
+		data     string
+		mode     os.FileMode
+	}
+	type out struct {
+		data string
+		mode os.FileMode
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{data: "new\n", mode: 0644},
+			out: out{data: "new\n", mode: 0644},
+		},
+		{
+			in:  in{existing: "old\n", data: "new\n", mode: 0600},
+			out: out{data: "new\n", mode: 0600},
+		},
+	}
+
+	for i, test := range tests {
+		dir, err := ioutil.TempDir("", "ignition-file-test")
+		if err != nil {
+			t.Fatalf("#%d: failed to create temp dir: %v", i, err)
+		}
+		defer os.RemoveAll(dir)
+
+		path := filepath.Join(dir, "config")
+		if test.in.existing != "" {
+			if err := ioutil.WriteFile(path, []byte(test.in.existing), 0644); err != nil {
+				t.Fatalf("#%d: failed to write existing file: %v", i, err)
+			}
+		}
+		if err := WriteFileAtomic(path, []byte(test.in.data), test.in.mode); err != nil {
+			t.Errorf("#%d: unexpected error: %v", i, err)
+			continue
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatalf("#%d: failed to read file: %v", i, err)
+		}
+		if string(data) != test.out.data {
+			t.Errorf("#%d: bad contents: want %q, got %q", i, test.out.data, data)
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatalf("#%d: failed to stat file: %v", i, err)
+		}
+		if info.Mode().Perm() != test.out.mode {
+			t.Errorf("#%d: bad mode: want %v, got %v", i, test.out.mode, info.Mode().Perm())
+		}
+		if entries, err := ioutil.ReadDir(dir); err != nil || len(entries) != 1 {
+			t.Errorf("#%d: bad directory entries: want 1, got %d (%v)", i, len(entries), err)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"io"
+	"io/ioutil"
+	"net/url"
+	"strings"
+
+	"github.com/coreos/ignition/config/types"
+	"github.com/coreos/ignition/internal/resource"
+)
+
+// fragmentReader reads the concatenation of a file's fragments. Each fragment
+// is only fetched once the previous one has been read in full, and is
+// verified as soon as it has been read.
+type fragmentReader struct {
+	fetcher   resource.Fetcher
+	fragments []types.FileFragment
+	current   *resource.Resource
+}
+
+func newFragmentReader(fetcher resource.Fetcher, fragments []types.FileFragment) io.ReadCloser {
+	return &fragmentReader{fetcher: fetcher, fragments: fragments}
+}
+
+func (r *fragmentReader) Read(p []byte) (int, error) {
+	for {
+		if r.current == nil {
+			if len(r.fragments) == 0 {
+				return 0, io.EOF
+			}
+			f, err := openFragment(r.fetcher, r.fragments[0])
+			if err != nil {
+				return 0, err
+			}
+			r.fragments = r.fragments[1:]
+			r.current = f
+		}
+
+		n, err := r.current.Read(p)
+		if err != io.EOF {
+			return n, err
+		}
+
+		r.current.Close()
+		if err := r.current.Verify(); err != nil {
+			return n, err
+		}
+		r.current = nil
+		if n > 0 {
+			return n, nil
+		}
+	}
+}
+
+func (r *fragmentReader) Close() error {
+	if r.current != nil {
+		return r.current.Close()
+	}
+	return nil
+}
+
+// openFragment returns the Resource reading the decompressed contents of
+// frag, whose Verify checks them against the fragment's hash.
+func openFragment(fetcher resource.Fetcher, frag types.FileFragment) (*resource.Resource, error) {
+	opts := resource.FetchOptions{
+		Verification: frag.Verification,
+		Compression:  frag.Compression,
+	}
+	if frag.Inline != "" {
+		return resource.NewResource(ioutil.NopCloser(strings.NewReader(frag.Inline)), opts)
+	}
+	opts.Headers = frag.HttpHeaders.Header()
+	return fetcher.Open(url.URL(frag.Source), opts)
+}
@@ -0,0 +1,101 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"io/ioutil"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/coreos/ignition/config/types"
+	"github.com/coreos/ignition/internal/resource"
+)
+
+func TestFragmentReader(t *testing.T) {
+	type in struct {
+		fragments []types.FileFragment
+	}
+	type out struct {
+		data string
+		err  error
+	}
+
+	data := func(s string) types.Url {
+		return types.Url(url.URL{Scheme: "data", Opaque: "," + s})
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{},
+			out: out{data: ""},
+		},
+		{
+			in: in{fragments: []types.FileFragment{
+				{Inline: "hello "},
+				{Source: data("world")},
+				{Inline: "\n"},
+			}},
+			out: out{data: "hello world\n"},
+		},
+		{
+			in: in{fragments: []types.FileFragment{
+				{
+					Source: data("hello"),
+					Verification: types.Verification{
+						Hash: &types.Hash{
+							Function: "sha512",
+							Sum:      "9b71d224bd62f3785d96d46ad3ea3d73319bfbc2890caadae2dff72519673ca72323c3d99ba5c11d7c7acc6e14b8c5da0c4663475c2e5c3adef46f73bcdec043",
+						},
+					},
+				},
+			}},
+			out: out{data: "hello"},
+		},
+		{
+			in: in{fragments: []types.FileFragment{
+				{Inline: "hello"},
+				{
+					Inline: "world",
+					Verification: types.Verification{
+						Hash: &types.Hash{
+							Function: "sha512",
+							Sum:      "9b71d224bd62f3785d96d46ad3ea3d73319bfbc2890caadae2dff72519673ca72323c3d99ba5c11d7c7acc6e14b8c5da0c4663475c2e5c3adef46f73bcdec043",
+						},
+					},
+				},
+			}},
+			out: out{data: "helloworld", err: resource.ErrHashMismatch{
+				Calculated: "11853df40f4b2b919d3815f64792e58d08663767a494bcbb38c0b2389d9140bbb170281b4a847be7757bde12c9cd0054ce3652d0ad3a1a0c92babb69798246ee",
+				Expected:   "9b71d224bd62f3785d96d46ad3ea3d73319bfbc2890caadae2dff72519673ca72323c3d99ba5c11d7c7acc6e14b8c5da0c4663475c2e5c3adef46f73bcdec043",
+			}},
+		},
+	}
+
+	for i, test := range tests {
+		reader := newFragmentReader(resource.Fetcher{}, test.in.fragments)
+		b, err := ioutil.ReadAll(reader)
+		reader.Close()
+		if !reflect.DeepEqual(test.out.err, err) {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.out.err, err)
+		}
+		if string(b) != test.out.data {
+			t.Errorf("#%d: bad data: want %q, got %q", i, test.out.data, string(b))
+		}
+	}
+}
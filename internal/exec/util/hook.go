@@ -0,0 +1,101 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/coreos/ignition/config/types"
+)
+
+const (
+	PostProvisionUnitName = "ignition-post-provision.service"
+
+	PostProvisionLog  = "/var/log/ignition/post-provision.log"
+	postProvisionDone = "/var/lib/ignition/post-provision.done"
+
+	// postProvisionOutputMax bounds the output of the hook recorded in the
+	// result file, which keeps the end of it.
+	postProvisionOutputMax = 64 * 1024
+)
+
+// PostProvisionUnit returns an enabled unit which runs hook exactly once,
+// once the first boot of the target system has reached multi-user.target.
+// The output and exit status of the hook are appended to PostProvisionLog
+// and, if resultFile is set and was written, recorded as its postProvision.
+func PostProvisionUnit(hook types.PostProvision, resultFile string) types.SystemdUnit {
+	cmd := hook.Command
+	if hook.Unit != nil {
+		cmd = []string{"/usr/bin/systemctl", "start", string(*hook.Unit)}
+	}
+
+	script := postProvisionScript(PostProvisionLog, postProvisionDone, resultFile)
+	args := []string{"/bin/sh", "-c", script, "sh"}
+	args = append(args, cmd...)
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = quoteExecArg(arg)
+	}
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "# Generated by Ignition\n")
+	fmt.Fprintf(buf, "[Unit]\n")
+	fmt.Fprintf(buf, "Description=Run the Ignition post-provisioning hook\n")
+	fmt.Fprintf(buf, "Wants=network-online.target\n")
+	fmt.Fprintf(buf, "After=network-online.target multi-user.target\n")
+	fmt.Fprintf(buf, "ConditionPathExists=!%s\n", postProvisionDone)
+	fmt.Fprintf(buf, "\n[Service]\n")
+	fmt.Fprintf(buf, "Type=oneshot\n")
+	fmt.Fprintf(buf, "RemainAfterExit=yes\n")
+	fmt.Fprintf(buf, "ExecStart=%s\n", strings.Join(quoted, " "))
+	fmt.Fprintf(buf, "\n[Install]\n")
+	fmt.Fprintf(buf, "WantedBy=multi-user.target\n")
+
+	return types.SystemdUnit{
+		Name:     PostProvisionUnitName,
+		Enable:   true,
+		Contents: buf.String(),
+	}
+}
+
+// postProvisionScript returns the shell script which runs its arguments as the
+// hook and records the outcome. The target system has no JSON tools, so the
+// output is escaped with sed and awk and the result file, as written by the
+// engine, is rewritten with postProvision as its first member.
+func postProvisionScript(log, done, resultFile string) string {
+	steps := []string{
+		"umask 077",
+		fmt.Sprintf("mkdir -p %s %s", filepath.Dir(log), filepath.Dir(done)),
+		`output=$("$@" 2>&1)`,
+		"status=$?",
+		fmt.Sprintf(`{ [ -z "$output" ] || printf '%%s\n' "$output"; echo "exit status $status"; } >>%s`, log),
+	}
+	if resultFile != "" {
+		escape := fmt.Sprintf(`printf '%%s' "$output" | tail -c %d | tr -d '\000-\010\013\014\016-\037' | sed -e 's/\\/\\\\/g' -e 's/"/\\"/g' -e 's/\t/\\t/g' -e 's/\r/\\r/g' | awk 'NR > 1 { printf "%%s", "\\n" } { printf "%%s", $0 }'`, postProvisionOutputMax)
+		steps = append(steps, fmt.Sprintf(`if [ -f %[1]s ]; then { echo '{'; printf '  "postProvision": {"exitStatus": %%d, "output": "%%s"},\n' $status "$(%[2]s)"; sed 1d %[1]s; } >%[1]s.tmp && mv %[1]s.tmp %[1]s; fi`, resultFile, escape))
+	}
+	steps = append(steps, "touch "+done, "exit $status")
+	return strings.Join(steps, "; ")
+}
+
+// quoteExecArg quotes arg as a single argument of a systemd Exec line,
+// escaping the characters systemd would otherwise interpret.
+func quoteExecArg(arg string) string {
+	arg = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "$", "$$", "%", "%%").Replace(arg)
+	return `"` + arg + `"`
+}
@@ -0,0 +1,183 @@
+// Copyright 2016 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/coreos/ignition/config/types"
+)
+
+func TestQuoteExecArg(t *testing.T) {
+	type in struct {
+		arg string
+	}
+	type out struct {
+		quoted string
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{in: in{arg: "/usr/bin/join"}, out: out{quoted: `"/usr/bin/join"`}},
+		{in: in{arg: "two words"}, out: out{quoted: `"two words"`}},
+		{in: in{arg: `say "hi"`}, out: out{quoted: `"say \"hi\""`}},
+		{in: in{arg: `$HOME\%i`}, out: out{quoted: `"$$HOME\\%%i"`}},
+	}
+
+	for i, test := range tests {
+		quoted := quoteExecArg(test.in.arg)
+		if quoted != test.out.quoted {
+			t.Errorf("#%d: bad quoting: want %s, got %s", i, test.out.quoted, quoted)
+		}
+	}
+}
+
+func TestPostProvisionUnit(t *testing.T) {
+	type in struct {
+		hook types.PostProvision
+	}
+	type out struct {
+		execSuffix string
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{hook: types.PostProvision{Command: []string{"/opt/bin/join-cluster", "--token", "abc"}}},
+			out: out{execSuffix: `"sh" "/opt/bin/join-cluster" "--token" "abc"`},
+		},
+		{
+			in:  in{hook: types.PostProvision{Unit: func(n types.SystemdUnitName) *types.SystemdUnitName { return &n }("join.service")}},
+			out: out{execSuffix: `"sh" "/usr/bin/systemctl" "start" "join.service"`},
+		},
+	}
+
+	for i, test := range tests {
+		unit := PostProvisionUnit(test.in.hook, "/var/lib/ignition/result.json")
+		if unit.Name != PostProvisionUnitName || !unit.Enable {
+			t.Errorf("#%d: bad unit: %+v", i, unit)
+		}
+		exec := ""
+		for _, line := range strings.Split(unit.Contents, "\n") {
+			if strings.HasPrefix(line, "ExecStart=") {
+				exec = line
+			}
+		}
+		if !strings.HasPrefix(exec, `ExecStart="/bin/sh" "-c" `) || !strings.HasSuffix(exec, test.out.execSuffix) {
+			t.Errorf("#%d: bad ExecStart: want suffix %s, got %s", i, test.out.execSuffix, exec)
+		}
+	}
+}
+
+func TestPostProvisionScript(t *testing.T) {
+	type in struct {
+		hook   string
+		result bool
+	}
+	type out struct {
+		status int
+		output string
+		log    string
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{hook: `echo 'say "hi"'; printf 'a\\b\tc\n' >&2; exit 3`, result: true},
+			out: out{status: 3, output: "say \"hi\"\na\\b\tc", log: "say \"hi\"\na\\b\tc\nexit status 3\n"},
+		},
+		{
+			in:  in{hook: "true", result: true},
+			out: out{log: "exit status 0\n"},
+		},
+		{
+			in:  in{hook: "echo done"},
+			out: out{log: "done\nexit status 0\n"},
+		},
+	}
+
+	for i, test := range tests {
+		dir, err := ioutil.TempDir("", "ignition-hook")
+		if err != nil {
+			t.Fatalf("#%d: failed to create temp dir: %v", i, err)
+		}
+		defer os.RemoveAll(dir)
+
+		log := filepath.Join(dir, "log", "post-provision.log")
+		done := filepath.Join(dir, "lib", "post-provision.done")
+		resultFile := filepath.Join(dir, "result.json")
+		if test.in.result {
+			if err := ioutil.WriteFile(resultFile, []byte("{\n  \"complete\": true\n}\n"), 0600); err != nil {
+				t.Fatalf("#%d: failed to write result file: %v", i, err)
+			}
+		}
+
+		script := postProvisionScript(log, done, resultFile)
+		err = exec.Command("/bin/sh", "-c", script, "sh", "/bin/sh", "-c", test.in.hook).Run()
+		status := 0
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			status = exitErr.Sys().(interface {
+				ExitStatus() int
+			}).ExitStatus()
+		} else if err != nil {
+			t.Fatalf("#%d: failed to run script: %v", i, err)
+		}
+		if status != test.out.status {
+			t.Errorf("#%d: bad exit status: want %d, got %d", i, test.out.status, status)
+		}
+		if b, err := ioutil.ReadFile(log); err != nil || string(b) != test.out.log {
+			t.Errorf("#%d: bad log: want %q, got %q (%v)", i, test.out.log, b, err)
+		}
+		if _, err := os.Stat(done); err != nil {
+			t.Errorf("#%d: hook not marked done: %v", i, err)
+		}
+
+		b, err := ioutil.ReadFile(resultFile)
+		if !test.in.result {
+			if !os.IsNotExist(err) {
+				t.Errorf("#%d: unexpected result file: %q (%v)", i, b, err)
+			}
+			continue
+		}
+		var results struct {
+			Complete      bool `json:"complete"`
+			PostProvision struct {
+				ExitStatus int    `json:"exitStatus"`
+				Output     string `json:"output"`
+			} `json:"postProvision"`
+		}
+		if err := json.Unmarshal(b, &results); err != nil {
+			t.Errorf("#%d: bad result file %q: %v", i, b, err)
+			continue
+		}
+		want := test.out
+		if got := (out{status: results.PostProvision.ExitStatus, output: results.PostProvision.Output, log: want.log}); !reflect.DeepEqual(want, got) || !results.Complete {
+			t.Errorf("#%d: bad results: want %+v, got %+v (%s)", i, want, got, b)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/coreos/ignition/internal/tool"
+)
+
+const (
+	grubDefaultsPath = "/etc/default/grub"
+	grubCmdlineVar   = "GRUB_CMDLINE_LINUX"
+)
+
+var (
+	ErrNoKargsTarget = errors.New("found neither boot loader entries under /boot nor /etc/default/grub to apply kernel arguments to")
+)
+
+// UpdateKernelArguments adds the arguments in add and drops those in remove
+// from the boot loader config of the target root, reporting whether it
+// changed. On OSTree, the kernel arguments of the deployments are edited with
+// ostree, which regenerates their boot loader entries from them. Elsewhere,
+// the Boot Loader Specification entries under /boot are updated where there
+// are any, since grub reads them directly; otherwise GRUB_CMDLINE_LINUX in
+// /etc/default/grub is, which takes effect once grub.cfg is regenerated.
+func (u Util) UpdateKernelArguments(add, remove []string) (bool, error) {
+	entries, err := BLSEntries(u.JoinPath("/boot"))
+	if err != nil {
+		return false, err
+	}
+	if u.IsOstree() {
+		return u.updateOstreeKernelArguments(entries, add, remove)
+	}
+	if len(entries) != 0 {
+		changed := false
+		for _, entry := range entries {
+			c, err := UpdateBLSEntry(entry, add, remove)
+			if err != nil {
+				return false, fmt.Errorf("failed to update %q: %v", entry, err)
+			}
+			changed = changed || c
+		}
+		return changed, nil
+	}
+
+	path := u.JoinPath(grubDefaultsPath)
+	contents, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return false, ErrNoKargsTarget
+	} else if err != nil {
+		return false, err
+	}
+	updated := updateGrubDefaults(string(contents), add, remove)
+	if updated == string(contents) {
+		return false, nil
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return false, err
+	}
+	u.Warning("kernel arguments in %q take effect once grub.cfg is regenerated", grubDefaultsPath)
+	return true, WriteFileAtomic(path, []byte(updated), info.Mode())
+}
+
+// updateOstreeKernelArguments applies the arguments to the OSTree deployments
+// of the target root, whose boot loader entries are entries. ostree isn't run
+// if every entry already has them.
+func (u Util) updateOstreeKernelArguments(entries []string, add, remove []string) (bool, error) {
+	applied := len(entries) != 0
+	for _, entry := range entries {
+		contents, err := ioutil.ReadFile(entry)
+		if err != nil {
+			return false, err
+		}
+		if !KernelArgumentsApplied(blsOptions(string(contents)), add, remove) {
+			applied = false
+		}
+	}
+	if applied {
+		return false, nil
+	}
+	if err := tool.Require(tool.Ostree); err != nil {
+		return false, err
+	}
+	return true, u.LogCmd(tool.Ostree.Command(ostreeKargsArgs(u.DestDir, add, remove)...),
+		"updating kernel arguments of the OSTree deployments")
+}
+
+// ostreeKargsArgs returns the arguments to ostree editing the kernel
+// arguments of the deployments in the sysroot root.
+func ostreeKargsArgs(root string, add, remove []string) []string {
+	args := []string{"admin", "kargs", "edit-in-place", "--sysroot=" + root}
+	for _, arg := range add {
+		args = append(args, "--append-if-missing="+arg)
+	}
+	for _, arg := range remove {
+		args = append(args, "--delete-if-present="+arg)
+	}
+	return args
+}
+
+// updateGrubDefaults returns the contents of /etc/default/grub with the
+// arguments of GRUB_CMDLINE_LINUX updated. The variable is appended if it is
+// not set.
+func updateGrubDefaults(defaults string, add, remove []string) string {
+	lines := strings.Split(defaults, "\n")
+	prefix := grubCmdlineVar + "="
+	found := false
+	for i, line := range lines {
+		if !strings.HasPrefix(line, prefix) {
+			continue
+		}
+		found = true
+		value := strings.TrimPrefix(line, prefix)
+		if unquoted, err := strconv.Unquote(value); err == nil {
+			value = unquoted
+		} else {
+			value = strings.Trim(value, "'")
+		}
+		lines[i] = prefix + strconv.Quote(strings.Join(updateArgs(strings.Fields(value), add, remove), " "))
+	}
+	if !found && len(add) > 0 {
+		if lines[len(lines)-1] == "" {
+			lines = lines[:len(lines)-1]
+		}
+		lines = append(lines, prefix+strconv.Quote(strings.Join(updateArgs(nil, add, remove), " ")), "")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// KernelArgumentsApplied reports whether the kernel command line cmdline
+// has every argument in add and none of those in remove.
+func KernelArgumentsApplied(cmdline string, add, remove []string) bool {
+	have := map[string]bool{}
+	for _, arg := range strings.Fields(cmdline) {
+		have[arg] = true
+	}
+	for _, arg := range add {
+		if !have[arg] {
+			return false
+		}
+	}
+	for _, arg := range remove {
+		if have[arg] {
+			return false
+		}
+	}
+	return true
+}
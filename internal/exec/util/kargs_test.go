@@ -0,0 +1,210 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/coreos/ignition/internal/log"
+)
+
+func TestUpdateGrubDefaults(t *testing.T) {
+	type in struct {
+		defaults string
+		add      []string
+		remove   []string
+	}
+	type out struct {
+		defaults string
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{defaults: "GRUB_TIMEOUT=5\nGRUB_CMDLINE_LINUX=\"rhgb quiet\"\n", add: []string{"nosmt"}, remove: []string{"rhgb"}},
+			out: out{defaults: "GRUB_TIMEOUT=5\nGRUB_CMDLINE_LINUX=\"quiet nosmt\"\n"},
+		},
+		{
+			in:  in{defaults: "GRUB_CMDLINE_LINUX='quiet'\n", add: []string{"console=ttyS0,115200"}},
+			out: out{defaults: "GRUB_CMDLINE_LINUX=\"quiet console=ttyS0,115200\"\n"},
+		},
+		{
+			in:  in{defaults: "GRUB_TIMEOUT=5\n", add: []string{"nosmt"}},
+			out: out{defaults: "GRUB_TIMEOUT=5\nGRUB_CMDLINE_LINUX=\"nosmt\"\n"},
+		},
+		{
+			in:  in{defaults: "GRUB_TIMEOUT=5\n", remove: []string{"quiet"}},
+			out: out{defaults: "GRUB_TIMEOUT=5\n"},
+		},
+	}
+
+	for i, test := range tests {
+		defaults := updateGrubDefaults(test.in.defaults, test.in.add, test.in.remove)
+		if defaults != test.out.defaults {
+			t.Errorf("#%d: bad defaults: want %q, got %q", i, test.out.defaults, defaults)
+		}
+	}
+}
+
+func TestKernelArgumentsApplied(t *testing.T) {
+	type in struct {
+		cmdline string
+		add     []string
+		remove  []string
+	}
+	type out struct {
+		applied bool
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{cmdline: "root=/dev/sda1 nosmt quiet\n", add: []string{"nosmt"}, remove: []string{"rhgb"}},
+			out: out{applied: true},
+		},
+		{
+			in:  in{cmdline: "root=/dev/sda1 quiet\n", add: []string{"nosmt"}},
+			out: out{applied: false},
+		},
+		{
+			in:  in{cmdline: "root=/dev/sda1 rhgb quiet\n", remove: []string{"rhgb"}},
+			out: out{applied: false},
+		},
+	}
+
+	for i, test := range tests {
+		applied := KernelArgumentsApplied(test.in.cmdline, test.in.add, test.in.remove)
+		if applied != test.out.applied {
+			t.Errorf("#%d: bad result: want %v, got %v", i, test.out.applied, applied)
+		}
+	}
+}
+
+func TestUpdateKernelArguments(t *testing.T) {
+	type in struct {
+		entry string
+		add   []string
+	}
+	type out struct {
+		entry   string
+		changed bool
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{entry: "title Fedora\nlinux /vmlinuz\noptions root=UUID=1 quiet\n", add: []string{"nosmt"}},
+			out: out{entry: "title Fedora\nlinux /vmlinuz\noptions root=UUID=1 quiet nosmt\n", changed: true},
+		},
+		{
+			in:  in{entry: "title Fedora\noptions root=UUID=1 nosmt\n", add: []string{"nosmt"}},
+			out: out{entry: "title Fedora\noptions root=UUID=1 nosmt\n", changed: false},
+		},
+	}
+
+	logger := log.New()
+	for i, test := range tests {
+		root, err := ioutil.TempDir("", "ignition-kargs-test")
+		if err != nil {
+			t.Fatalf("#%d: failed to create temp dir: %v", i, err)
+		}
+		defer os.RemoveAll(root)
+
+		entries := filepath.Join(root, "boot", "loader", "entries")
+		if err := os.MkdirAll(entries, 0755); err != nil {
+			t.Fatalf("#%d: failed to create entries: %v", i, err)
+		}
+		path := filepath.Join(entries, "fedora.conf")
+		if err := ioutil.WriteFile(path, []byte(test.in.entry), 0644); err != nil {
+			t.Fatalf("#%d: failed to write entry: %v", i, err)
+		}
+
+		u := Util{Logger: &logger, DestDir: root}
+		changed, err := u.UpdateKernelArguments(test.in.add, nil)
+		if err != nil {
+			t.Errorf("#%d: unexpected error: %v", i, err)
+			continue
+		}
+		if changed != test.out.changed {
+			t.Errorf("#%d: bad change: want %v, got %v", i, test.out.changed, changed)
+		}
+		entry, _ := ioutil.ReadFile(path)
+		if string(entry) != test.out.entry {
+			t.Errorf("#%d: bad entry: want %q, got %q", i, test.out.entry, entry)
+		}
+	}
+
+	root, err := ioutil.TempDir("", "ignition-kargs-test")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(root)
+	u := Util{Logger: &logger, DestDir: root}
+	if _, err := u.UpdateKernelArguments([]string{"nosmt"}, nil); err != ErrNoKargsTarget {
+		t.Errorf("bad error without a boot loader config: want %v, got %v", ErrNoKargsTarget, err)
+	}
+}
+
+func TestUpdateOstreeKernelArguments(t *testing.T) {
+	root, err := ioutil.TempDir("", "ignition-kargs-test")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(root)
+
+	entries := filepath.Join(root, "boot", "loader", "entries")
+	if err := os.MkdirAll(entries, 0755); err != nil {
+		t.Fatalf("failed to create entries: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(root, "ostree"), 0755); err != nil {
+		t.Fatalf("failed to create ostree dir: %v", err)
+	}
+	entry := "title Fedora CoreOS\noptions root=UUID=1 ostree=/ostree/boot.1/fedora-coreos/0 nosmt\n"
+	path := filepath.Join(entries, "ostree-1-fedora-coreos.conf")
+	if err := ioutil.WriteFile(path, []byte(entry), 0644); err != nil {
+		t.Fatalf("failed to write entry: %v", err)
+	}
+
+	// The deployments already have the arguments, so ostree isn't needed.
+	logger := log.New()
+	u := Util{Logger: &logger, DestDir: root}
+	changed, err := u.UpdateKernelArguments([]string{"nosmt"}, []string{"quiet"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if changed {
+		t.Errorf("bad change: want false, got true")
+	}
+	if contents, _ := ioutil.ReadFile(path); string(contents) != entry {
+		t.Errorf("edited the entry of an OSTree deployment: got %q", contents)
+	}
+}
+
+func TestOstreeKargsArgs(t *testing.T) {
+	want := []string{"admin", "kargs", "edit-in-place", "--sysroot=/sysroot", "--append-if-missing=nosmt", "--append-if-missing=console=ttyS0", "--delete-if-present=quiet"}
+	if args := ostreeKargsArgs("/sysroot", []string{"nosmt", "console=ttyS0"}, []string{"quiet"}); !reflect.DeepEqual(want, args) {
+		t.Errorf("bad args: want %v, got %v", want, args)
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/coreos/ignition/config/types"
+)
+
+// CreateLink creates the symbolic or hard link described by l, along with any
+// missing parent directories. Symbolic links are given l's ownership, while
+// hard links share the ownership of their target.
+func (u Util) CreateLink(l types.Link) error {
+	path := u.JoinPath(string(l.Path))
+
+	if info, err := os.Lstat(path); err == nil {
+		if !l.Overwrite {
+			if u.linkMatches(l, info) && l.Hard {
+				return nil
+			}
+			if u.linkMatches(l, info) {
+				return os.Lchown(path, l.User.Uid(), l.Group.Gid())
+			}
+			return fmt.Errorf("%q already exists as %s; set overwrite to replace it", l.Path, describeNode(info.Mode()))
+		}
+		if err := os.RemoveAll(path); err != nil {
+			return err
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	if err := u.mkdirForFile(path); err != nil {
+		return err
+	}
+
+	if l.Hard {
+		// Hard links share the context of their target.
+		return os.Link(u.JoinPath(l.Target), path)
+	}
+	if err := os.Symlink(l.Target, path); err != nil {
+		return err
+	}
+	u.Relabel.Add(path)
+	return os.Lchown(path, l.User.Uid(), l.Group.Gid())
+}
+
+// linkMatches returns whether info, the existing file at l's path, is already
+// the link l describes.
+func (u Util) linkMatches(l types.Link, info os.FileInfo) bool {
+	if l.Hard {
+		target, err := os.Stat(u.JoinPath(l.Target))
+		return err == nil && os.SameFile(info, target)
+	}
+	if info.Mode()&os.ModeSymlink == 0 {
+		return false
+	}
+	target, err := os.Readlink(u.JoinPath(string(l.Path)))
+	return err == nil && target == l.Target
+}
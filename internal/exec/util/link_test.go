@@ -0,0 +1,114 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/coreos/ignition/config/types"
+)
+
+func TestCreateLink(t *testing.T) {
+	type in struct {
+		existing string // the symlink target already at the path, if any
+		link     types.Link
+	}
+	type out struct {
+		ok     bool
+		target string // the symlink target afterwards, or "" for a hard link
+	}
+
+	node := types.Node{Filesystem: "root", Path: "/etc/link"}
+	overwrite := types.Node{Filesystem: "root", Path: "/etc/link", Overwrite: true}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{link: types.Link{Node: node, Target: "/etc/file"}},
+			out: out{ok: true, target: "/etc/file"},
+		},
+		{
+			in:  in{link: types.Link{Node: node, Target: "/etc/file", Hard: true}},
+			out: out{ok: true},
+		},
+		{
+			in:  in{existing: "/etc/file", link: types.Link{Node: node, Target: "/etc/file"}},
+			out: out{ok: true, target: "/etc/file"},
+		},
+		{
+			in:  in{existing: "/etc/other", link: types.Link{Node: node, Target: "/etc/file"}},
+			out: out{ok: false, target: "/etc/other"},
+		},
+		{
+			in:  in{existing: "/etc/other", link: types.Link{Node: overwrite, Target: "/etc/file"}},
+			out: out{ok: true, target: "/etc/file"},
+		},
+		{
+			in:  in{existing: "/etc/other", link: types.Link{Node: overwrite, Target: "/etc/file", Hard: true}},
+			out: out{ok: true},
+		},
+		{
+			in:  in{link: types.Link{Node: types.Node{Filesystem: "root", Path: "/new/dir/link"}, Target: "/etc/file"}},
+			out: out{ok: true, target: "/etc/file"},
+		},
+	}
+
+	for i, test := range tests {
+		root, err := ioutil.TempDir("", "ignition-link-test")
+		if err != nil {
+			t.Fatalf("#%d: failed to create temp dir: %v", i, err)
+		}
+		defer os.RemoveAll(root)
+		if err := os.Mkdir(filepath.Join(root, "etc"), 0755); err != nil {
+			t.Fatalf("#%d: failed to create etc: %v", i, err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(root, "etc", "file"), nil, 0644); err != nil {
+			t.Fatalf("#%d: failed to create file: %v", i, err)
+		}
+		if test.in.existing != "" {
+			if err := os.Symlink(test.in.existing, filepath.Join(root, "etc", "link")); err != nil {
+				t.Fatalf("#%d: failed to create existing link: %v", i, err)
+			}
+		}
+
+		u := Util{DestDir: root}
+		link := test.in.link
+		link.User.Id = intToPtr(os.Getuid())
+		link.Group.Id = intToPtr(os.Getgid())
+		err = u.CreateLink(link)
+		if test.out.ok != (err == nil) {
+			t.Errorf("#%d: bad error: want ok %v, got %v", i, test.out.ok, err)
+		}
+
+		path := u.JoinPath(string(link.Path))
+		if test.out.target == "" {
+			info, err := os.Lstat(path)
+			file, ferr := os.Stat(filepath.Join(root, "etc", "file"))
+			if err != nil || ferr != nil || !os.SameFile(info, file) {
+				t.Errorf("#%d: bad link: want a hard link to /etc/file", i)
+			}
+			continue
+		}
+		target, err := os.Readlink(path)
+		if err != nil || target != test.out.target {
+			t.Errorf("#%d: bad link: want %q, got %q (%v)", i, test.out.target, target, err)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/coreos/ignition/config/types"
+)
+
+const (
+	DefaultLuksKeyPermissions os.FileMode = 0400
+
+	crypttabBlockBegin = "# BEGIN Ignition luks volumes"
+	crypttabBlockEnd   = "# END Ignition luks volumes"
+)
+
+// luksKeyCache is where, within the state directory, the disks stage keeps
+// the keys it fetched, so that the files stage writes the very keys the
+// volumes were formatted with to the target system, rather than fetching them
+// again from a service which may hand each key out only once.
+const luksKeyCache = "luks"
+
+// luksKeyCachePath returns where the key of volume is kept. The key is kept
+// under the volume's name along with its key file's source and hash, so that
+// a config naming another key for the volume doesn't get this one.
+func (u Util) luksKeyCachePath(volume types.Luks) string {
+	id := volume.Name + "\x00" + volume.KeyFile.Source.String()
+	if h := volume.KeyFile.Verification.Hash; h != nil {
+		id += "\x00" + h.Function + "-" + h.Sum
+	}
+	sum := sha256.Sum256([]byte(id))
+	return filepath.Join(u.StatePath(luksKeyCache), hex.EncodeToString(sum[:]))
+}
+
+// CacheLuksKey keeps key as the key of volume for later stages.
+func (u Util) CacheLuksKey(volume types.Luks, key []byte) error {
+	if err := os.MkdirAll(u.StatePath(luksKeyCache), 0700); err != nil {
+		return err
+	}
+	return WriteFileAtomic(u.luksKeyCachePath(volume), key, 0600)
+}
+
+// CachedLuksKey returns the key kept for volume by CacheLuksKey, if there is
+// one.
+func (u Util) CachedLuksKey(volume types.Luks) ([]byte, bool) {
+	key, err := ioutil.ReadFile(u.luksKeyCachePath(volume))
+	if err != nil {
+		return nil, false
+	}
+	return key, true
+}
+
+// ClearLuksKeyCache removes the keys kept by CacheLuksKey. Since /run outlives
+// the initramfs, they would otherwise remain readable on the booted system.
+// The cache is cleared when the disks stage starts, once the files stage has
+// written the keys, and when a run fails.
+func (u Util) ClearLuksKeyCache() error {
+	return os.RemoveAll(u.StatePath(luksKeyCache))
+}
+
+// LuksKeyPath returns where the key of the named volume is kept on the target
+// system, so that it can be unlocked at boot.
+func LuksKeyPath(name string) string {
+	return filepath.Join("/etc", "luks", name)
+}
+
+// LuksKeyFile returns the file holding the key of volume, to be fetched with
+// RenderFile.
+func LuksKeyFile(volume types.Luks) types.File {
+	mode := types.NodeMode(DefaultLuksKeyPermissions)
+	return types.File{
+		Node: types.Node{Path: types.Path(LuksKeyPath(volume.Name)), Mode: mode},
+		Contents: types.FileContents{
+			Source:       volume.KeyFile.Source,
+			Compression:  volume.KeyFile.Compression,
+			Verification: volume.KeyFile.Verification,
+			HttpHeaders:  volume.KeyFile.HttpHeaders,
+		},
+	}
+}
+
+// CrypttabWithEntries returns the contents of an /etc/crypttab which keeps
+// the lines of existing and lists volumes in a block delimited by markers,
+// replacing any block written previously. Volumes with a UUID are referred
+// to by it, and the rest by their device. Volumes without a key file are left
+// to clevis to unlock.
+func CrypttabWithEntries(existing []byte, volumes []types.Luks) []byte {
+	block := &bytes.Buffer{}
+	for _, v := range volumes {
+		device := string(v.Device)
+		if v.Uuid != nil {
+			device = "UUID=" + *v.Uuid
+		}
+		key := "none"
+		if v.HasKeyFile() {
+			key = LuksKeyPath(v.Name)
+		}
+		options := "luks"
+		if v.Discard {
+			options += ",discard"
+		}
+		if v.Clevis != nil && len(v.Clevis.Tang) != 0 {
+			// Tang servers can only be reached once the network is up.
+			options += ",_netdev"
+		}
+		fmt.Fprintf(block, "%s %s %s %s\n", v.Name, device, key, options)
+	}
+	return withBlock(existing, crypttabBlockBegin, crypttabBlockEnd, block.Bytes())
+}
@@ -0,0 +1,125 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"io/ioutil"
+	"net/url"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/coreos/ignition/config/types"
+)
+
+func TestCrypttabWithEntries(t *testing.T) {
+	type in struct {
+		existing string
+		volumes  []types.Luks
+	}
+	type out struct {
+		crypttab string
+	}
+
+	uuid := "9a3c4f2e-1b6d-4e8a-9c7f-2d5b8e1a4c6f"
+	key := types.LuksKeyFile{Source: types.Url(url.URL{Scheme: "data", Opaque: ",secret"})}
+	volumes := []types.Luks{
+		{Name: "root", Device: "/dev/disk/by-partlabel/root", KeyFile: key},
+		{Name: "data", Device: "/dev/sdb", Uuid: &uuid, KeyFile: key},
+	}
+	block := "# BEGIN Ignition luks volumes\nroot /dev/disk/by-partlabel/root /etc/luks/root luks\ndata UUID=" + uuid + " /etc/luks/data luks\n# END Ignition luks volumes\n"
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{volumes: volumes},
+			out: out{crypttab: block},
+		},
+		{
+			in:  in{existing: "swap /dev/sda3 /dev/urandom swap\n", volumes: volumes},
+			out: out{crypttab: "swap /dev/sda3 /dev/urandom swap\n" + block},
+		},
+		{
+			in: in{
+				existing: "# BEGIN Ignition luks volumes\nold /dev/sdc /etc/luks/old luks\n# END Ignition luks volumes\n",
+				volumes:  volumes,
+			},
+			out: out{crypttab: block},
+		},
+		{
+			in: in{volumes: []types.Luks{
+				{Name: "tpm", Device: "/dev/sdc", Clevis: &types.Clevis{Tpm2: true}},
+				{Name: "net", Device: "/dev/sdd", Clevis: &types.Clevis{Tang: []types.Tang{{Url: "http://tang.example.com"}}}},
+			}},
+			out: out{crypttab: "# BEGIN Ignition luks volumes\ntpm /dev/sdc none luks\nnet /dev/sdd none luks,_netdev\n# END Ignition luks volumes\n"},
+		},
+		{
+			in:  in{volumes: []types.Luks{{Name: "ssd", Device: "/dev/nvme0n1", Discard: true, Clevis: &types.Clevis{Tang: []types.Tang{{Url: "http://tang.example.com"}}}}}},
+			out: out{crypttab: "# BEGIN Ignition luks volumes\nssd /dev/nvme0n1 none luks,discard,_netdev\n# END Ignition luks volumes\n"},
+		},
+	}
+
+	for i, test := range tests {
+		crypttab := string(CrypttabWithEntries([]byte(test.in.existing), test.in.volumes))
+		if crypttab != test.out.crypttab {
+			t.Errorf("#%d: bad crypttab: want %q, got %q", i, test.out.crypttab, crypttab)
+		}
+	}
+}
+
+func TestLuksKeyCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ignition-luks-test")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	u := Util{StateDir: dir}
+
+	data := types.Luks{Name: "data", KeyFile: types.LuksKeyFile{Source: types.Url(url.URL{Scheme: "https", Host: "keys.example.com", Path: "/data"})}}
+	if _, ok := u.CachedLuksKey(data); ok {
+		t.Errorf("unexpected key before caching")
+	}
+	if err := u.CacheLuksKey(data, []byte("secret")); err != nil {
+		t.Fatalf("failed to cache key: %v", err)
+	}
+	key, ok := u.CachedLuksKey(data)
+	if !ok || !reflect.DeepEqual([]byte("secret"), key) {
+		t.Errorf("bad key: want %q, got %q (%t)", "secret", key, ok)
+	}
+	if info, err := os.Stat(u.luksKeyCachePath(data)); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("bad cached key file: %v, %v", info, err)
+	}
+	if _, ok := u.CachedLuksKey(types.Luks{Name: "other", KeyFile: data.KeyFile}); ok {
+		t.Errorf("unexpected key of another volume")
+	}
+	moved := data
+	moved.KeyFile.Source = types.Url(url.URL{Scheme: "https", Host: "keys.example.com", Path: "/other"})
+	if _, ok := u.CachedLuksKey(moved); ok {
+		t.Errorf("unexpected key of another source")
+	}
+	pinned := data
+	pinned.KeyFile.Verification.Hash = &types.Hash{Function: "sha512", Sum: "00"}
+	if _, ok := u.CachedLuksKey(pinned); ok {
+		t.Errorf("unexpected key of another hash")
+	}
+	if err := u.ClearLuksKeyCache(); err != nil {
+		t.Fatalf("failed to clear cache: %v", err)
+	}
+	if _, ok := u.CachedLuksKey(data); ok {
+		t.Errorf("unexpected key after clearing")
+	}
+}
@@ -0,0 +1,162 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/coreos/ignition/config/types"
+	"github.com/coreos/ignition/internal/tool"
+
+	"github.com/coreos/go-systemd/unit"
+)
+
+// mountRecord is where, within the state directory, the mount points of the
+// filesystems mounted within the target root are recorded, one per line in
+// the order they were mounted, so that the umount stage can detach them even
+// when run separately.
+const mountRecord = "mounts"
+
+// MountUnit returns an enabled unit which mounts what at where on the target
+// system and is pulled in by wantedBy.
+func MountUnit(what, where, fsType string, options []string, wantedBy string) types.SystemdUnit {
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "# Generated by Ignition\n")
+	fmt.Fprintf(buf, "[Mount]\n")
+	fmt.Fprintf(buf, "What=%s\n", what)
+	fmt.Fprintf(buf, "Where=%s\n", where)
+	fmt.Fprintf(buf, "Type=%s\n", fsType)
+	if len(options) > 0 {
+		fmt.Fprintf(buf, "Options=%s\n", strings.Join(options, ","))
+	}
+	fmt.Fprintf(buf, "\n[Install]\n")
+	fmt.Fprintf(buf, "WantedBy=%s\n", wantedBy)
+
+	return types.SystemdUnit{
+		Name:     types.SystemdUnitName(MountUnitName(where)),
+		Enable:   true,
+		Contents: buf.String(),
+	}
+}
+
+// MountUnitName returns the name systemd requires for a mount unit mounting
+// at where.
+func MountUnitName(where string) string {
+	return unit.UnitNamePathEscape(where) + ".mount"
+}
+
+// SwapUnit returns an enabled unit activating the swap area at what with the
+// given swapon options.
+func SwapUnit(what string, options []string) types.SystemdUnit {
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "# Generated by Ignition\n")
+	fmt.Fprintf(buf, "[Swap]\n")
+	fmt.Fprintf(buf, "What=%s\n", what)
+	if len(options) > 0 {
+		fmt.Fprintf(buf, "Options=%s\n", strings.Join(options, ","))
+	}
+	fmt.Fprintf(buf, "\n[Install]\n")
+	fmt.Fprintf(buf, "WantedBy=swap.target\n")
+
+	return types.SystemdUnit{
+		Name:     types.SystemdUnitName(unit.UnitNamePathEscape(what) + ".swap"),
+		Enable:   true,
+		Contents: buf.String(),
+	}
+}
+
+// MountFilesystem mounts the filesystem described by m at dir. Mount options are
+// handed to mount(8), which understands the generic options as well as the
+// filesystem specific ones.
+func (u Util) MountFilesystem(m types.FilesystemMount, dir string) error {
+	dev := string(m.Device)
+	format := string(m.Format)
+
+	var err error
+	if len(m.MountOptions) == 0 {
+		err = u.Logger.LogOp(
+			func() error { return syscall.Mount(dev, dir, format, 0, "") },
+			"mounting %q at %q", dev, dir,
+		)
+	} else {
+		opts := strings.Join(m.MountOptions, ",")
+		err = u.Logger.LogCmd(
+			tool.Mount.Command("-t", format, "-o", opts, dev, dir),
+			"mounting %q at %q with options %q", dev, dir, opts,
+		)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to mount device %q at %q: %v", dev, dir, err)
+	}
+	return nil
+}
+
+// UnmountFilesystem unmounts the filesystem described by m from dir.
+func (u Util) UnmountFilesystem(m types.FilesystemMount, dir string) error {
+	return u.Logger.LogOp(
+		func() error { return syscall.Unmount(dir, 0) },
+		"unmounting %q at %q", m.Device, dir,
+	)
+}
+
+// RecordMount appends dir to the mount record, which the files stage starts
+// afresh each time it runs.
+func (u Util) RecordMount(dir string) error {
+	path := u.StatePath(mountRecord)
+	if err := os.MkdirAll(filepath.Dir(path), DefaultDirectoryPermissions); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, DefaultFilePermissions)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := fmt.Fprintln(f, dir); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+// RecordedMounts returns the mount points in the mount record, in the order
+// they were mounted.
+func (u Util) RecordedMounts() ([]string, error) {
+	b, err := ioutil.ReadFile(u.StatePath(mountRecord))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var dirs []string
+	for _, line := range strings.Split(string(b), "\n") {
+		if line != "" {
+			dirs = append(dirs, line)
+		}
+	}
+	return dirs, nil
+}
+
+// ClearMountRecord removes the mount record, if any.
+func (u Util) ClearMountRecord() error {
+	if err := os.Remove(u.StatePath(mountRecord)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMountRecord(t *testing.T) {
+	type in struct {
+		dirs []string
+	}
+	type out struct {
+		dirs []string
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{},
+			out: out{},
+		},
+		{
+			in:  in{dirs: []string{"/sysroot/var", "/sysroot/var/log", "/sysroot/srv data"}},
+			out: out{dirs: []string{"/sysroot/var", "/sysroot/var/log", "/sysroot/srv data"}},
+		},
+	}
+
+	tmp, err := ioutil.TempDir("", "ignition-mounts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	u := Util{StateDir: filepath.Join(tmp, "run")}
+
+	for i, test := range tests {
+		for _, dir := range test.in.dirs {
+			if err := u.RecordMount(dir); err != nil {
+				t.Fatalf("#%d: failed to record mount: %v", i, err)
+			}
+		}
+		dirs, err := u.RecordedMounts()
+		if err != nil {
+			t.Errorf("#%d: failed to read mount record: %v", i, err)
+		}
+		if !reflect.DeepEqual(test.out.dirs, dirs) {
+			t.Errorf("#%d: bad mounts: want %q, got %q", i, test.out.dirs, dirs)
+		}
+		if err := u.ClearMountRecord(); err != nil {
+			t.Errorf("#%d: failed to clear mount record: %v", i, err)
+		}
+		if dirs, _ := u.RecordedMounts(); len(dirs) != 0 {
+			t.Errorf("#%d: mount record not cleared: %q", i, dirs)
+		}
+	}
+}
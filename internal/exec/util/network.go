@@ -0,0 +1,109 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"net/url"
+	"reflect"
+
+	"github.com/coreos/ignition/config/types"
+	"github.com/coreos/ignition/internal/resource"
+)
+
+var verificationType = reflect.TypeOf(types.Verification{})
+
+// localSchemes are the source URL schemes which are fetched without the
+// network.
+var localSchemes = map[string]bool{"": true, "data": true, "oem": true}
+
+var urlType = reflect.TypeOf(types.Url{})
+
+// RemoteSources returns the sources the stages would fetch over the network
+// to apply cfg: the remote sources of its files, fragments, images, trust
+// anchors, and luks keys, and its tang servers. The ignition section is left
+// out, since its references and CAs are fetched while the config is
+// rendered.
+func RemoteSources(cfg types.Config) []string {
+	cfg.Ignition = types.Ignition{}
+	var sources []string
+	collectRemoteSources(reflect.ValueOf(cfg), &sources)
+	for _, volume := range cfg.Storage.Luks {
+		if c := volume.Clevis; c != nil {
+			for _, tang := range c.Tang {
+				sources = append(sources, tang.Url)
+			}
+		}
+	}
+	return sources
+}
+
+func collectRemoteSources(v reflect.Value, sources *[]string) {
+	if v.Type() == urlType {
+		u := url.URL(v.Interface().(types.Url))
+		if !localSchemes[u.Scheme] {
+			*sources = append(*sources, RedactSource(u.String()))
+		}
+		return
+	}
+	switch v.Kind() {
+	case reflect.Ptr:
+		if !v.IsNil() {
+			collectRemoteSources(v.Elem(), sources)
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			collectRemoteSources(v.Field(i), sources)
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			collectRemoteSources(v.Index(i), sources)
+		}
+	}
+}
+
+// ExpectFetches tells c of the verified resources the stages will fetch for
+// cfg, so that those referenced more than once are fetched once and shared.
+// The CAs of the ignition section are fetched again by the files stage, and
+// so are counted twice.
+func ExpectFetches(c *resource.HttpClient, cfg types.Config) {
+	for _, ca := range cfg.Ignition.Security.Tls.CertificateAuthorities {
+		c.ExpectFetch(url.URL(ca.Source), ca.Verification)
+	}
+	cfg.Ignition = types.Ignition{Security: cfg.Ignition.Security}
+	expectFetches(c, reflect.ValueOf(cfg))
+}
+
+// expectFetches records every struct in v with a Source and a Verification,
+// such as file contents, fragments, CAs, images, and luks key files.
+func expectFetches(c *resource.HttpClient, v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if !v.IsNil() {
+			expectFetches(c, v.Elem())
+		}
+	case reflect.Struct:
+		source, verification := v.FieldByName("Source"), v.FieldByName("Verification")
+		if source.IsValid() && source.Type() == urlType && verification.IsValid() && verification.Type() == verificationType {
+			c.ExpectFetch(url.URL(source.Interface().(types.Url)), verification.Interface().(types.Verification))
+		}
+		for i := 0; i < v.NumField(); i++ {
+			expectFetches(c, v.Field(i))
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			expectFetches(c, v.Index(i))
+		}
+	}
+}
@@ -0,0 +1,124 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// IsOstree reports whether the target root is an OSTree sysroot. Such roots
+// are booted through Boot Loader Specification entries which OSTree
+// regenerates from the kernel arguments of its deployments, so kernel
+// arguments must be applied to the deployments rather than to the entries.
+func (u Util) IsOstree() bool {
+	_, err := os.Stat(u.JoinPath("/run/ostree-booted"))
+	if err == nil {
+		return true
+	}
+	_, err = os.Stat(u.JoinPath("/ostree"))
+	return err == nil
+}
+
+// BLSEntries returns the paths of the Boot Loader Specification entries
+// under bootDir.
+func BLSEntries(bootDir string) ([]string, error) {
+	return filepath.Glob(filepath.Join(bootDir, "loader", "entries", "*.conf"))
+}
+
+// UpdateBLSEntry rewrites the options line of the BLS entry at path, adding
+// the arguments in add which are missing and dropping those in remove, and
+// reports whether the entry changed. The rest of the entry is left untouched.
+func UpdateBLSEntry(path string, add, remove []string) (bool, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return false, err
+	}
+	updated := updateBLSOptions(string(contents), add, remove)
+	if updated == string(contents) {
+		return false, nil
+	}
+	return true, WriteFileAtomic(path, []byte(updated), info.Mode())
+}
+
+// blsOptions returns the arguments on the options lines of entry.
+func blsOptions(entry string) string {
+	options := []string{}
+	for _, line := range strings.Split(entry, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 0 && fields[0] == "options" {
+			options = append(options, fields[1:]...)
+		}
+	}
+	return strings.Join(options, " ")
+}
+
+// updateBLSOptions returns entry with the arguments of its options line
+// updated. An options line is appended if the entry has none.
+func updateBLSOptions(entry string, add, remove []string) string {
+	lines := strings.Split(entry, "\n")
+	found := false
+	for i, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || fields[0] != "options" {
+			continue
+		}
+		found = true
+		lines[i] = "options " + strings.Join(updateArgs(fields[1:], add, remove), " ")
+	}
+	if !found && len(add) > 0 {
+		if lines[len(lines)-1] == "" {
+			lines = lines[:len(lines)-1]
+		}
+		lines = append(lines, "options "+strings.Join(updateArgs(nil, add, remove), " "), "")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// updateArgs returns args with the arguments of remove dropped and the
+// missing arguments of add appended, in order.
+func updateArgs(args, add, remove []string) []string {
+	drop := map[string]struct{}{}
+	for _, arg := range remove {
+		drop[arg] = struct{}{}
+	}
+
+	kept := []string{}
+	have := map[string]struct{}{}
+	for _, arg := range args {
+		if _, ok := drop[arg]; ok {
+			continue
+		}
+		kept = append(kept, arg)
+		have[arg] = struct{}{}
+	}
+	for _, arg := range add {
+		if _, ok := have[arg]; ok {
+			continue
+		}
+		if _, ok := drop[arg]; ok {
+			continue
+		}
+		kept = append(kept, arg)
+		have[arg] = struct{}{}
+	}
+	return kept
+}
@@ -0,0 +1,112 @@
+// Copyright 2016 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestUpdateBLSOptions(t *testing.T) {
+	type in struct {
+		entry  string
+		add    []string
+		remove []string
+	}
+	type out struct {
+		entry string
+	}
+
+	entry := "title Fedora CoreOS (ostree:0)\nversion 1\nlinux /ostree/fedora-coreos-abc/vmlinuz\noptions root=UUID=1234 rw ostree=/ostree/boot.1/fedora-coreos/abc/0 quiet\n"
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{entry: entry},
+			out: out{entry: entry},
+		},
+		{
+			in:  in{entry: entry, add: []string{"nosmt", "console=ttyS0,115200n8", "quiet"}},
+			out: out{entry: "title Fedora CoreOS (ostree:0)\nversion 1\nlinux /ostree/fedora-coreos-abc/vmlinuz\noptions root=UUID=1234 rw ostree=/ostree/boot.1/fedora-coreos/abc/0 quiet nosmt console=ttyS0,115200n8\n"},
+		},
+		{
+			in:  in{entry: entry, add: []string{"nosmt"}, remove: []string{"quiet"}},
+			out: out{entry: "title Fedora CoreOS (ostree:0)\nversion 1\nlinux /ostree/fedora-coreos-abc/vmlinuz\noptions root=UUID=1234 rw ostree=/ostree/boot.1/fedora-coreos/abc/0 nosmt\n"},
+		},
+		{
+			in:  in{entry: "title Test\nlinux /vmlinuz\n", add: []string{"nosmt"}},
+			out: out{entry: "title Test\nlinux /vmlinuz\noptions nosmt\n"},
+		},
+		{
+			in:  in{entry: "title Test\nlinux /vmlinuz\n", remove: []string{"nosmt"}},
+			out: out{entry: "title Test\nlinux /vmlinuz\n"},
+		},
+	}
+
+	for i, test := range tests {
+		entry := updateBLSOptions(test.in.entry, test.in.add, test.in.remove)
+		if entry != test.out.entry {
+			t.Errorf("#%d: bad entry: want %q, got %q", i, test.out.entry, entry)
+		}
+	}
+}
+
+func TestIsOstree(t *testing.T) {
+	type in struct {
+		paths []string
+	}
+	type out struct {
+		ostree bool
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{},
+			out: out{ostree: false},
+		},
+		{
+			in:  in{paths: []string{"ostree/deploy"}},
+			out: out{ostree: true},
+		},
+		{
+			in:  in{paths: []string{"run/ostree-booted"}},
+			out: out{ostree: true},
+		},
+	}
+
+	for i, test := range tests {
+		root, err := ioutil.TempDir("", "ignition-ostree-test")
+		if err != nil {
+			t.Fatalf("#%d: failed to create temp dir: %v", i, err)
+		}
+		defer os.RemoveAll(root)
+		for _, path := range test.in.paths {
+			if err := os.MkdirAll(filepath.Join(root, path), 0755); err != nil {
+				t.Fatalf("#%d: failed to create %q: %v", i, path, err)
+			}
+		}
+
+		if ostree := (Util{DestDir: root}).IsOstree(); ostree != test.out.ostree {
+			t.Errorf("#%d: bad result: want %v, got %v", i, test.out.ostree, ostree)
+		}
+	}
+}
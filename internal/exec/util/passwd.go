@@ -16,11 +16,16 @@ package util
 
 import (
 	"fmt"
-	"os/exec"
+	"io/ioutil"
+	"os"
+	"os/user"
+	"path/filepath"
 	"strconv"
 	"strings"
 
 	"github.com/coreos/ignition/config/types"
+	"github.com/coreos/ignition/internal/distro"
+	"github.com/coreos/ignition/internal/tool"
 
 	keys "github.com/coreos/update-ssh-keys/authorized_keys_d"
 )
@@ -31,8 +36,20 @@ func (u Util) CreateUser(c types.User) error {
 		return nil
 	}
 
+	if !tool.Useradd.Available() {
+		return u.LogOp(func() error { return u.addUserNative(c) },
+			"creating user %q without useradd", c.Name)
+	}
+
+	return u.LogCmd(tool.Useradd.Command(useraddArgs(u.DestDir, c)...),
+		"creating user %q", c.Name)
+}
+
+// useraddArgs returns the arguments to useradd creating the user described by
+// c in the root at dir.
+func useraddArgs(dir string, c types.User) []string {
 	cu := c.Create
-	args := []string{"--root", u.DestDir}
+	args := []string{"--root", dir}
 
 	if c.PasswordHash != "" {
 		args = append(args, "--password", c.PasswordHash)
@@ -46,7 +63,7 @@ func (u Util) CreateUser(c types.User) error {
 	}
 
 	if cu.GECOS != "" {
-		args = append(args, "--comment", fmt.Sprintf("%q", cu.GECOS))
+		args = append(args, "--comment", cu.GECOS)
 	}
 
 	if cu.Homedir != "" {
@@ -83,13 +100,12 @@ func (u Util) CreateUser(c types.User) error {
 		args = append(args, "--shell", cu.Shell)
 	}
 
-	args = append(args, c.Name)
-
-	return u.LogCmd(exec.Command("useradd", args...),
-		"creating user %q", c.Name)
+	return append(args, c.Name)
 }
 
-// Add the provided SSH public keys to the user's authorized keys.
+// Add the provided SSH public keys to the user's authorized keys, either as a
+// fragment in ~/.ssh/authorized_keys.d or directly in ~/.ssh/authorized_keys,
+// as the distribution prefers. Either way ~/.ssh is relabeled.
 func (u Util) AuthorizeSSHKeys(c types.User) error {
 	if len(c.SSHAuthorizedKeys) == 0 {
 		return nil
@@ -101,33 +117,95 @@ func (u Util) AuthorizeSSHKeys(c types.User) error {
 			return fmt.Errorf("unable to lookup user %q", c.Name)
 		}
 
-		akd, err := keys.Open(usr, true)
+		if distro.WriteAuthorizedKeysFragment() {
+			err = writeAuthorizedKeysFragment(usr, c.SSHAuthorizedKeys)
+		} else {
+			err = writeAuthorizedKeysFile(usr, c.SSHAuthorizedKeys)
+		}
 		if err != nil {
 			return err
 		}
-		defer akd.Close()
-
-		// TODO(vc): introduce key names to config?
-		// TODO(vc): validate c.SSHAuthorizedKeys well-formedness.
-		ks := strings.Join(c.SSHAuthorizedKeys, "\n")
-		// XXX(vc): for now ensure the addition is always
-		// newline-terminated.  A future version of akd will handle this
-		// for us in addition to validating the ssh keys for
-		// well-formedness.
-		if !strings.HasSuffix(ks, "\n") {
-			ks = ks + "\n"
-		}
+		u.Relabel.Add(filepath.Join(usr.HomeDir, ".ssh"))
+		return nil
+	}, "adding ssh keys to user %q", c.Name)
+}
 
-		if err := akd.Add("coreos-ignition", []byte(ks), true, true); err != nil {
-			return err
-		}
+// writeAuthorizedKeysFragment writes keys to Ignition's fragment in the
+// authorized_keys.d directory of usr, regenerating authorized_keys from the
+// fragments.
+func writeAuthorizedKeysFragment(usr *user.User, sshKeys []string) error {
+	akd, err := keys.Open(usr, true)
+	if err != nil {
+		return err
+	}
+	defer akd.Close()
+
+	// TODO(vc): introduce key names to config?
+	// TODO(vc): validate sshKeys well-formedness.
+	ks := strings.Join(sshKeys, "\n")
+	// XXX(vc): for now ensure the addition is always
+	// newline-terminated.  A future version of akd will handle this
+	// for us in addition to validating the ssh keys for
+	// well-formedness.
+	if !strings.HasSuffix(ks, "\n") {
+		ks = ks + "\n"
+	}
 
-		if err := akd.Sync(); err != nil {
-			return err
+	if err := akd.Add("coreos-ignition", []byte(ks), true, true); err != nil {
+		return err
+	}
+
+	return akd.Sync()
+}
+
+// writeAuthorizedKeysFile adds keys to the authorized_keys file of usr,
+// keeping the keys already there. The file and ~/.ssh are owned by usr and
+// private to it.
+func writeAuthorizedKeysFile(usr *user.User, sshKeys []string) error {
+	uid, err := strconv.Atoi(usr.Uid)
+	if err != nil {
+		return err
+	}
+	gid, err := strconv.Atoi(usr.Gid)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Join(usr.HomeDir, ".ssh")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	if err := os.Chown(dir, uid, gid); err != nil {
+		return err
+	}
+
+	path := filepath.Join(dir, "authorized_keys")
+	existing, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	lines := strings.Split(strings.TrimSuffix(string(existing), "\n"), "\n")
+	if len(existing) == 0 {
+		lines = nil
+	}
+	present := map[string]bool{}
+	for _, line := range lines {
+		present[strings.TrimSpace(line)] = true
+	}
+	for _, key := range sshKeys {
+		for _, line := range strings.Split(key, "\n") {
+			line = strings.TrimSpace(line)
+			if line != "" && !present[line] {
+				lines = append(lines, line)
+				present[line] = true
+			}
 		}
+	}
 
-		return nil
-	}, "adding ssh keys to user %q", c.Name)
+	if err := WriteFileAtomic(path, []byte(strings.Join(lines, "\n")+"\n"), 0600); err != nil {
+		return err
+	}
+	return os.Chown(path, uid, gid)
 }
 
 // SetPasswordHash sets the password hash of the specified user.
@@ -136,6 +214,11 @@ func (u Util) SetPasswordHash(c types.User) error {
 		return nil
 	}
 
+	if !tool.Usermod.Available() {
+		return u.LogOp(func() error { return u.setPasswordNative(c.Name, c.PasswordHash) },
+			"setting password for %q without usermod", c.Name)
+	}
+
 	args := []string{
 		"--root", u.DestDir,
 		"--password", c.PasswordHash,
@@ -143,12 +226,41 @@ func (u Util) SetPasswordHash(c types.User) error {
 
 	args = append(args, c.Name)
 
-	return u.LogCmd(exec.Command("usermod", args...),
+	return u.LogCmd(tool.Usermod.Command(args...),
 		"setting password for %q", c.Name)
 }
 
-// CreateGroup creates the group as described.
+// LockPassword locks the password of the specified user, if requested, so that
+// it cannot log in with a password.
+func (u Util) LockPassword(c types.User) error {
+	if !c.Locked {
+		return nil
+	}
+
+	if !tool.Usermod.Available() {
+		return u.LogOp(func() error { return u.lockPasswordNative(c.Name) },
+			"locking password of %q without usermod", c.Name)
+	}
+
+	return u.LogCmd(tool.Usermod.Command("--root", u.DestDir, "--lock", c.Name),
+		"locking password of %q", c.Name)
+}
+
+// CreateGroup creates the group as described, unless it already exists with
+// the requested GID.
 func (u Util) CreateGroup(g types.Group) error {
+	if exists, err := u.groupExists(g); err != nil {
+		return err
+	} else if exists {
+		u.Info("group %q already exists", g.Name)
+		return nil
+	}
+
+	if !tool.Groupadd.Available() {
+		return u.LogOp(func() error { return u.addGroupNative(g) },
+			"adding group %q without groupadd", g.Name)
+	}
+
 	args := []string{"--root", u.DestDir}
 
 	if g.Gid != nil {
@@ -168,6 +280,40 @@ func (u Util) CreateGroup(g types.Group) error {
 
 	args = append(args, g.Name)
 
-	return u.LogCmd(exec.Command("groupadd", args...),
+	return u.LogCmd(tool.Groupadd.Command(args...),
 		"adding group %q", g.Name)
 }
+
+// ResolveNodeOwner returns n with the names of its user and group replaced by
+// their IDs in the account databases under u.AccountRoot, which include any
+// accounts created earlier in the files stage.
+func (u Util) ResolveNodeOwner(n types.Node) (types.Node, error) {
+	if u.AccountRoot != "" {
+		u.DestDir = u.AccountRoot
+	}
+	if n.User.Name != "" {
+		passwd, err := u.readAccountDb(passwdPath, 0644)
+		if err != nil {
+			return n, err
+		}
+		uid, err := lookupId(passwd, n.User.Name)
+		if err != nil {
+			return n, fmt.Errorf("failed to resolve owner of %q: user %v", n.Path, err)
+		}
+		id := int(uid)
+		n.User = types.NodeUser{Id: &id}
+	}
+	if n.Group.Name != "" {
+		groups, err := u.readAccountDb(groupPath, 0644)
+		if err != nil {
+			return n, err
+		}
+		gid, err := lookupId(groups, n.Group.Name)
+		if err != nil {
+			return n, fmt.Errorf("failed to resolve group of %q: group %v", n.Path, err)
+		}
+		id := int(gid)
+		n.Group = types.NodeGroup{Id: &id}
+	}
+	return n, nil
+}
@@ -0,0 +1,402 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/coreos/ignition/config/types"
+)
+
+// The account databases edited directly when useradd, usermod, or groupadd
+// are not available, as in stripped-down initramfs images.
+const (
+	passwdPath  = "/etc/passwd"
+	shadowPath  = "/etc/shadow"
+	groupPath   = "/etc/group"
+	gshadowPath = "/etc/gshadow"
+	skelPath    = "/etc/skel"
+
+	// The ID ranges shadow-utils allocates from by default.
+	sysIdMin = 101
+	sysIdMax = 999
+	idMin    = 1000
+	idMax    = 60000
+
+	// usersGid is the primary group of users without a user group.
+	usersGid = 100
+)
+
+// accountDb is an account database such as /etc/passwd, as a list of
+// colon-separated entries.
+type accountDb struct {
+	path    string
+	mode    os.FileMode
+	entries [][]string
+}
+
+// readAccountDb reads the database at path under u.DestDir. A missing
+// database is empty and is created with mode when written.
+func (u Util) readAccountDb(path string, mode os.FileMode) (*accountDb, error) {
+	db := &accountDb{path: u.JoinPath(path), mode: mode}
+	data, err := ioutil.ReadFile(db.path)
+	if os.IsNotExist(err) {
+		return db, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if info, err := os.Stat(db.path); err == nil {
+		db.mode = info.Mode().Perm()
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if line != "" {
+			db.entries = append(db.entries, strings.Split(line, ":"))
+		}
+	}
+	return db, nil
+}
+
+// find returns the entry named name, or nil.
+func (db *accountDb) find(name string) []string {
+	for _, e := range db.entries {
+		if e[0] == name {
+			return e
+		}
+	}
+	return nil
+}
+
+// ids returns the IDs in the third field of the entries.
+func (db *accountDb) ids() map[uint]bool {
+	ids := map[uint]bool{}
+	for _, e := range db.entries {
+		if len(e) > 2 {
+			if id, err := strconv.ParseUint(e[2], 10, 32); err == nil {
+				ids[uint(id)] = true
+			}
+		}
+	}
+	return ids
+}
+
+func (db *accountDb) write() error {
+	buf := &bytes.Buffer{}
+	for _, e := range db.entries {
+		buf.WriteString(strings.Join(e, ":"))
+		buf.WriteString("\n")
+	}
+	if err := os.MkdirAll(filepath.Dir(db.path), 0755); err != nil {
+		return err
+	}
+	return WriteFileAtomic(db.path, buf.Bytes(), db.mode)
+}
+
+// nextId returns a free ID, allocating system IDs downward from sysIdMax and
+// other IDs upward from idMin, as shadow-utils does.
+func nextId(used map[uint]bool, system bool) (uint, error) {
+	if system {
+		for id := uint(sysIdMax); id >= sysIdMin; id-- {
+			if !used[id] {
+				return id, nil
+			}
+		}
+	} else {
+		for id := uint(idMin); id <= idMax; id++ {
+			if !used[id] {
+				return id, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("no free IDs")
+}
+
+// shadowDays returns the days since the epoch, as recorded in the last
+// password change field of /etc/shadow.
+func shadowDays() string {
+	return strconv.FormatInt(time.Now().Unix()/(24*60*60), 10)
+}
+
+// addGroupNative creates the group g by editing /etc/group and, if present,
+// /etc/gshadow.
+func (u Util) addGroupNative(g types.Group) error {
+	groups, err := u.readAccountDb(groupPath, 0644)
+	if err != nil {
+		return err
+	}
+	if groups.find(g.Name) != nil {
+		return fmt.Errorf("group %q already exists", g.Name)
+	}
+
+	var gid uint
+	if g.Gid != nil {
+		gid = *g.Gid
+		if groups.ids()[gid] {
+			return fmt.Errorf("gid %d is already in use", gid)
+		}
+	} else if gid, err = nextId(groups.ids(), g.System); err != nil {
+		return err
+	}
+
+	hash := g.PasswordHash
+	if hash == "" {
+		hash = "*"
+	}
+	return u.appendGroup(groups, g.Name, gid, hash)
+}
+
+// groupExists reports whether the group g is already in /etc/group. It is an
+// error if that group has a different GID than requested.
+func (u Util) groupExists(g types.Group) (bool, error) {
+	groups, err := u.readAccountDb(groupPath, 0644)
+	if err != nil {
+		return false, err
+	}
+	if groups.find(g.Name) == nil {
+		return false, nil
+	}
+	gid, err := lookupId(groups, g.Name)
+	if err != nil {
+		return false, err
+	}
+	if g.Gid != nil && *g.Gid != gid {
+		return false, fmt.Errorf("group %q already exists with gid %d", g.Name, gid)
+	}
+	return true, nil
+}
+
+// appendGroup adds the group name to groups and writes it, also adding the
+// group to /etc/gshadow if it exists.
+func (u Util) appendGroup(groups *accountDb, name string, gid uint, hash string) error {
+	groups.entries = append(groups.entries, []string{name, "x", strconv.FormatUint(uint64(gid), 10), ""})
+	if err := groups.write(); err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(u.JoinPath(gshadowPath)); err != nil {
+		return nil
+	}
+	gshadow, err := u.readAccountDb(gshadowPath, 0)
+	if err != nil {
+		return err
+	}
+	gshadow.entries = append(gshadow.entries, []string{name, hash, "", ""})
+	return gshadow.write()
+}
+
+// addUserNative creates the user c by editing the account databases
+// directly, creating its home directory from /etc/skel as useradd would.
+func (u Util) addUserNative(c types.User) error {
+	cu := c.Create
+	passwd, err := u.readAccountDb(passwdPath, 0644)
+	if err != nil {
+		return err
+	}
+	shadow, err := u.readAccountDb(shadowPath, 0)
+	if err != nil {
+		return err
+	}
+	groups, err := u.readAccountDb(groupPath, 0644)
+	if err != nil {
+		return err
+	}
+	if passwd.find(c.Name) != nil {
+		return fmt.Errorf("user %q already exists", c.Name)
+	}
+
+	var uid uint
+	if cu.Uid != nil {
+		uid = *cu.Uid
+		if passwd.ids()[uid] {
+			return fmt.Errorf("uid %d is already in use", uid)
+		}
+	} else if uid, err = nextId(passwd.ids(), cu.System); err != nil {
+		return err
+	}
+
+	var gid uint
+	switch {
+	case cu.PrimaryGroup != "":
+		if gid, err = lookupGid(groups, cu.PrimaryGroup); err != nil {
+			return err
+		}
+	case cu.NoUserGroup:
+		gid = usersGid
+	default:
+		if groups.find(c.Name) != nil {
+			return fmt.Errorf("group %q already exists", c.Name)
+		}
+		gid = uid
+		if groups.ids()[gid] {
+			if gid, err = nextId(groups.ids(), cu.System); err != nil {
+				return err
+			}
+		}
+		if err := u.appendGroup(groups, c.Name, gid, "!"); err != nil {
+			return err
+		}
+		if groups, err = u.readAccountDb(groupPath, 0644); err != nil {
+			return err
+		}
+	}
+
+	for _, name := range cu.Groups {
+		group := groups.find(name)
+		if group == nil || len(group) < 4 {
+			return fmt.Errorf("group %q does not exist", name)
+		}
+		if group[3] == "" {
+			group[3] = c.Name
+		} else {
+			group[3] += "," + c.Name
+		}
+	}
+
+	home := cu.Homedir
+	if home == "" {
+		home = filepath.Join("/home", c.Name)
+	}
+	hash := c.PasswordHash
+	if hash == "" {
+		hash = "*"
+	}
+	uidStr := strconv.FormatUint(uint64(uid), 10)
+	gidStr := strconv.FormatUint(uint64(gid), 10)
+	passwd.entries = append(passwd.entries, []string{c.Name, "x", uidStr, gidStr, cu.GECOS, home, cu.Shell})
+	shadow.entries = append(shadow.entries, []string{c.Name, hash, shadowDays(), "0", "99999", "7", "", "", ""})
+
+	for _, db := range []*accountDb{groups, passwd, shadow} {
+		if err := db.write(); err != nil {
+			return err
+		}
+	}
+
+	if cu.NoCreateHome {
+		return nil
+	}
+	return u.createHome(home, int(uid), int(gid))
+}
+
+// lookupGid returns the gid of the group given by name or number.
+func lookupGid(groups *accountDb, group string) (uint, error) {
+	if e := groups.find(group); e != nil && len(e) > 2 {
+		gid, err := strconv.ParseUint(e[2], 10, 32)
+		return uint(gid), err
+	}
+	if gid, err := strconv.ParseUint(group, 10, 32); err == nil {
+		return uint(gid), nil
+	}
+	return 0, fmt.Errorf("group %q does not exist", group)
+}
+
+// lookupId returns the ID of the entry named name in db.
+func lookupId(db *accountDb, name string) (uint, error) {
+	e := db.find(name)
+	if e == nil || len(e) < 3 {
+		return 0, fmt.Errorf("%q does not exist", name)
+	}
+	id, err := strconv.ParseUint(e[2], 10, 32)
+	return uint(id), err
+}
+
+// createHome creates the home directory home, populated from /etc/skel and
+// owned by uid and gid.
+func (u Util) createHome(home string, uid, gid int) error {
+	dir := u.JoinPath(home)
+	if err := os.MkdirAll(filepath.Dir(dir), 0755); err != nil {
+		return err
+	}
+	if err := os.Mkdir(dir, 0700); err != nil && !os.IsExist(err) {
+		return err
+	}
+	if err := os.Chown(dir, uid, gid); err != nil {
+		return err
+	}
+
+	skel := u.JoinPath(skelPath)
+	if _, err := os.Stat(skel); err != nil {
+		return nil
+	}
+	return filepath.Walk(skel, func(path string, info os.FileInfo, err error) error {
+		if err != nil || path == skel {
+			return err
+		}
+		rel, err := filepath.Rel(skel, path)
+		if err != nil {
+			return err
+		}
+		dest := filepath.Join(dir, rel)
+		switch {
+		case info.IsDir():
+			err = os.Mkdir(dest, info.Mode().Perm())
+		case info.Mode()&os.ModeSymlink != 0:
+			var target string
+			if target, err = os.Readlink(path); err == nil {
+				err = os.Symlink(target, dest)
+			}
+		case info.Mode().IsRegular():
+			var data []byte
+			if data, err = ioutil.ReadFile(path); err == nil {
+				err = ioutil.WriteFile(dest, data, info.Mode().Perm())
+			}
+		default:
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return os.Lchown(dest, uid, gid)
+	})
+}
+
+// lockPasswordNative locks the password of the user name in /etc/shadow, as
+// usermod --lock does, by prefixing its hash with "!".
+func (u Util) lockPasswordNative(name string) error {
+	shadow, err := u.readAccountDb(shadowPath, 0)
+	if err != nil {
+		return err
+	}
+	e := shadow.find(name)
+	if e == nil || len(e) < 3 {
+		return fmt.Errorf("user %q not found in %s", name, shadowPath)
+	}
+	if strings.HasPrefix(e[1], "!") {
+		return nil
+	}
+	e[1] = "!" + e[1]
+	e[2] = shadowDays()
+	return shadow.write()
+}
+
+// setPasswordNative sets the password hash of the user name in /etc/shadow.
+func (u Util) setPasswordNative(name, hash string) error {
+	shadow, err := u.readAccountDb(shadowPath, 0)
+	if err != nil {
+		return err
+	}
+	e := shadow.find(name)
+	if e == nil || len(e) < 3 {
+		return fmt.Errorf("user %q not found in %s", name, shadowPath)
+	}
+	e[1] = hash
+	e[2] = shadowDays()
+	return shadow.write()
+}
@@ -0,0 +1,292 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"testing"
+
+	"github.com/coreos/ignition/config/types"
+	"github.com/coreos/ignition/internal/log"
+)
+
+func TestAddUserNative(t *testing.T) {
+	type in struct {
+		users  []types.User
+		groups []types.Group
+	}
+	type out struct {
+		passwd string
+		shadow string
+		group  string
+	}
+
+	uid := func(u uint) *uint { return &u }
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in: in{users: []types.User{{Name: "core", PasswordHash: "$6$hash", Create: &types.UserCreate{NoCreateHome: true}}}},
+			out: out{
+				passwd: "root:x:0:0:root:/root:/bin/bash\ncore:x:1000:1000::/home/core:\n",
+				shadow: "root:*:1:0:99999:7:::\ncore:$6$hash:D:0:99999:7:::\n",
+				group:  "root:x:0:\nwheel:x:10:root\ncore:x:1000:\n",
+			},
+		},
+		{
+			in: in{
+				groups: []types.Group{{Name: "docker", System: true}},
+				users: []types.User{{Name: "svc", Create: &types.UserCreate{
+					Uid: uid(500), PrimaryGroup: "wheel", Groups: []string{"docker"}, Shell: "/sbin/nologin", Homedir: "/var/svc", NoCreateHome: true,
+				}}},
+			},
+			out: out{
+				passwd: "root:x:0:0:root:/root:/bin/bash\nsvc:x:500:10::/var/svc:/sbin/nologin\n",
+				shadow: "root:*:1:0:99999:7:::\nsvc:*:D:0:99999:7:::\n",
+				group:  "root:x:0:\nwheel:x:10:root\ndocker:x:999:svc\n",
+			},
+		},
+		{
+			in: in{users: []types.User{{Name: "nogroup", Create: &types.UserCreate{NoUserGroup: true, System: true, NoCreateHome: true}}}},
+			out: out{
+				passwd: "root:x:0:0:root:/root:/bin/bash\nnogroup:x:999:100::/home/nogroup:\n",
+				shadow: "root:*:1:0:99999:7:::\nnogroup:*:D:0:99999:7:::\n",
+				group:  "root:x:0:\nwheel:x:10:root\n",
+			},
+		},
+	}
+
+	days := regexp.MustCompile(`:[0-9]+:0:99999`)
+	for i, test := range tests {
+		dir, err := ioutil.TempDir("", "ignition-passwd")
+		if err != nil {
+			t.Fatalf("failed to create temp dir: %v", err)
+		}
+		defer os.RemoveAll(dir)
+		etc := filepath.Join(dir, "etc")
+		os.MkdirAll(etc, 0755)
+		ioutil.WriteFile(filepath.Join(etc, "passwd"), []byte("root:x:0:0:root:/root:/bin/bash\n"), 0644)
+		ioutil.WriteFile(filepath.Join(etc, "shadow"), []byte("root:*:1:0:99999:7:::\n"), 0640)
+		ioutil.WriteFile(filepath.Join(etc, "group"), []byte("root:x:0:\nwheel:x:10:root\n"), 0644)
+
+		u := Util{DestDir: dir}
+		for _, g := range test.in.groups {
+			if err := u.addGroupNative(g); err != nil {
+				t.Errorf("#%d: failed to add group: %v", i, err)
+			}
+		}
+		for _, usr := range test.in.users {
+			if err := u.addUserNative(usr); err != nil {
+				t.Errorf("#%d: failed to add user: %v", i, err)
+			}
+		}
+
+		read := func(name string) string {
+			b, _ := ioutil.ReadFile(filepath.Join(etc, name))
+			return days.ReplaceAllString(string(b), ":D:0:99999")
+		}
+		if passwd := read("passwd"); passwd != test.out.passwd {
+			t.Errorf("#%d: bad passwd: want %q, got %q", i, test.out.passwd, passwd)
+		}
+		want := days.ReplaceAllString(test.out.shadow, ":D:0:99999")
+		if shadow := read("shadow"); shadow != want {
+			t.Errorf("#%d: bad shadow: want %q, got %q", i, want, shadow)
+		}
+		if group := read("group"); group != test.out.group {
+			t.Errorf("#%d: bad group: want %q, got %q", i, test.out.group, group)
+		}
+	}
+}
+
+func TestAddUserNativeExisting(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ignition-passwd")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	os.MkdirAll(filepath.Join(dir, "etc"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, "etc", "passwd"), []byte("core:x:500:500::/home/core:\n"), 0644)
+
+	u := Util{DestDir: dir}
+	if err := u.addUserNative(types.User{Name: "core", Create: &types.UserCreate{}}); err == nil {
+		t.Errorf("added existing user")
+	}
+	uid := uint(500)
+	if err := u.addUserNative(types.User{Name: "other", Create: &types.UserCreate{Uid: &uid}}); err == nil {
+		t.Errorf("added user with uid in use")
+	}
+}
+
+func TestCreateGroupExisting(t *testing.T) {
+	type in struct {
+		group types.Group
+	}
+	type out struct {
+		ok bool
+	}
+
+	gid := func(g uint) *uint { return &g }
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{group: types.Group{Name: "docker"}},
+			out: out{ok: true},
+		},
+		{
+			in:  in{group: types.Group{Name: "docker", Gid: gid(990)}},
+			out: out{ok: true},
+		},
+		{
+			in:  in{group: types.Group{Name: "docker", Gid: gid(991)}},
+			out: out{ok: false},
+		},
+	}
+
+	const groups = "root:x:0:\ndocker:x:990:core\n"
+	logger := log.New()
+	for i, test := range tests {
+		dir, err := ioutil.TempDir("", "ignition-passwd")
+		if err != nil {
+			t.Fatalf("failed to create temp dir: %v", err)
+		}
+		defer os.RemoveAll(dir)
+		os.MkdirAll(filepath.Join(dir, "etc"), 0755)
+		ioutil.WriteFile(filepath.Join(dir, "etc", "group"), []byte(groups), 0644)
+
+		u := Util{Logger: &logger, DestDir: dir}
+		err = u.CreateGroup(test.in.group)
+		if ok := err == nil; ok != test.out.ok {
+			t.Errorf("#%d: bad result: want %v, got %v", i, test.out.ok, err)
+		}
+		if b, _ := ioutil.ReadFile(filepath.Join(dir, "etc", "group")); string(b) != groups {
+			t.Errorf("#%d: bad group: want %q, got %q", i, groups, b)
+		}
+	}
+}
+
+func TestLockPasswordNative(t *testing.T) {
+	type in struct {
+		shadow string
+	}
+	type out struct {
+		shadow string
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{shadow: "core:$6$hash:1:0:99999:7:::\n"},
+			out: out{shadow: "core:!$6$hash:D:0:99999:7:::\n"},
+		},
+		{
+			in:  in{shadow: "core:!*:1:0:99999:7:::\n"},
+			out: out{shadow: "core:!*:1:0:99999:7:::\n"},
+		},
+	}
+
+	days := regexp.MustCompile(`:[0-9]+:0:99999`)
+	for i, test := range tests {
+		dir, err := ioutil.TempDir("", "ignition-passwd")
+		if err != nil {
+			t.Fatalf("failed to create temp dir: %v", err)
+		}
+		defer os.RemoveAll(dir)
+		os.MkdirAll(filepath.Join(dir, "etc"), 0755)
+		ioutil.WriteFile(filepath.Join(dir, "etc", "shadow"), []byte(test.in.shadow), 0640)
+
+		u := Util{DestDir: dir}
+		if err := u.lockPasswordNative("core"); err != nil {
+			t.Errorf("#%d: failed to lock password: %v", i, err)
+			continue
+		}
+		b, _ := ioutil.ReadFile(filepath.Join(dir, "etc", "shadow"))
+		if test.out.shadow != test.in.shadow {
+			b = []byte(days.ReplaceAllString(string(b), ":D:0:99999"))
+		}
+		if string(b) != test.out.shadow {
+			t.Errorf("#%d: bad shadow: want %q, got %q", i, test.out.shadow, b)
+		}
+	}
+}
+
+func TestResolveNodeOwner(t *testing.T) {
+	type in struct {
+		node types.Node
+	}
+	type out struct {
+		node types.Node
+		ok   bool
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{node: types.Node{Path: "/a", User: types.NodeUser{Id: intToPtr(42)}, Group: types.NodeGroup{Id: intToPtr(43)}}},
+			out: out{node: types.Node{Path: "/a", User: types.NodeUser{Id: intToPtr(42)}, Group: types.NodeGroup{Id: intToPtr(43)}}, ok: true},
+		},
+		{
+			in:  in{node: types.Node{Path: "/a", User: types.NodeUser{Name: "core"}, Group: types.NodeGroup{Name: "wheel"}}},
+			out: out{node: types.Node{Path: "/a", User: types.NodeUser{Id: intToPtr(500)}, Group: types.NodeGroup{Id: intToPtr(10)}}, ok: true},
+		},
+		{
+			in:  in{node: types.Node{Path: "/a", User: types.NodeUser{Name: "core"}}},
+			out: out{node: types.Node{Path: "/a", User: types.NodeUser{Id: intToPtr(500)}}, ok: true},
+		},
+		{
+			in:  in{node: types.Node{Path: "/a", User: types.NodeUser{Name: "missing"}}},
+			out: out{ok: false},
+		},
+		{
+			in:  in{node: types.Node{Path: "/a", Group: types.NodeGroup{Name: "missing"}}},
+			out: out{ok: false},
+		},
+	}
+
+	dir, err := ioutil.TempDir("", "ignition-passwd")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	os.Mkdir(filepath.Join(dir, "etc"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, "etc", "passwd"), []byte("root:x:0:0:root:/root:/bin/bash\ncore:x:500:500::/home/core:\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "etc", "group"), []byte("root:x:0:\nwheel:x:10:root\n"), 0644)
+
+	// Nodes on another filesystem are resolved against the target root.
+	for _, u := range []Util{{DestDir: dir}, {DestDir: filepath.Join(dir, "mnt"), AccountRoot: dir}} {
+		for i, test := range tests {
+			node, err := u.ResolveNodeOwner(test.in.node)
+			if test.out.ok != (err == nil) {
+				t.Errorf("#%d: bad error: want ok %v, got %v", i, test.out.ok, err)
+				continue
+			}
+			if test.out.ok && !reflect.DeepEqual(test.out.node, node) {
+				t.Errorf("#%d: bad node: want %#v, got %#v", i, test.out.node, node)
+			}
+		}
+	}
+}
@@ -0,0 +1,138 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"io/ioutil"
+	"os"
+	"os/user"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/coreos/ignition/config/types"
+)
+
+func TestUseraddArgs(t *testing.T) {
+	type in struct {
+		user types.User
+	}
+	type out struct {
+		args []string
+	}
+
+	uid := uint(1010)
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{user: types.User{Name: "core", Create: &types.UserCreate{}}},
+			out: out{args: []string{"--root", "/sysroot", "--password", "*", "--create-home", "core"}},
+		},
+		{
+			in: in{user: types.User{Name: "svc", PasswordHash: "$6$x", Create: &types.UserCreate{
+				Uid:          &uid,
+				GECOS:        "Service Account, Ops",
+				Homedir:      "/var/lib/svc",
+				NoCreateHome: true,
+				PrimaryGroup: "svc",
+				Groups:       []string{"wheel", "docker"},
+				System:       true,
+				Shell:        "/sbin/nologin",
+			}}},
+			out: out{args: []string{
+				"--root", "/sysroot", "--password", "$6$x", "--uid", "1010",
+				"--comment", "Service Account, Ops", "--home-dir", "/var/lib/svc",
+				"--no-create-home", "--gid", "svc", "--groups", "wheel,docker",
+				"--system", "--shell", "/sbin/nologin", "svc",
+			}},
+		},
+	}
+
+	for i, test := range tests {
+		args := useraddArgs("/sysroot", test.in.user)
+		if !reflect.DeepEqual(test.out.args, args) {
+			t.Errorf("#%d: bad args: want %q, got %q", i, test.out.args, args)
+		}
+	}
+}
+
+func TestWriteAuthorizedKeysFile(t *testing.T) {
+	type in struct {
+		existing string
+		keys     []string
+	}
+	type out struct {
+		contents string
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{keys: []string{"ssh-ed25519 AAAA one", "ssh-rsa BBBB two\n"}},
+			out: out{contents: "ssh-ed25519 AAAA one\nssh-rsa BBBB two\n"},
+		},
+		{
+			in:  in{existing: "# image key\nssh-rsa BBBB two\n", keys: []string{"ssh-rsa BBBB two", "ssh-ed25519 AAAA one\nssh-ed25519 CCCC three"}},
+			out: out{contents: "# image key\nssh-rsa BBBB two\nssh-ed25519 AAAA one\nssh-ed25519 CCCC three\n"},
+		},
+	}
+
+	for i, test := range tests {
+		home, err := ioutil.TempDir("", "ignition-passwd-test")
+		if err != nil {
+			t.Fatalf("#%d: failed to create temp dir: %v", i, err)
+		}
+		defer os.RemoveAll(home)
+
+		path := filepath.Join(home, ".ssh", "authorized_keys")
+		if test.in.existing != "" {
+			if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+				t.Fatalf("#%d: failed to create .ssh: %v", i, err)
+			}
+			if err := ioutil.WriteFile(path, []byte(test.in.existing), 0644); err != nil {
+				t.Fatalf("#%d: failed to write existing keys: %v", i, err)
+			}
+		}
+		usr := &user.User{
+			Uid:     strconv.Itoa(os.Getuid()),
+			Gid:     strconv.Itoa(os.Getgid()),
+			HomeDir: home,
+		}
+		if err := writeAuthorizedKeysFile(usr, test.in.keys); err != nil {
+			t.Errorf("#%d: unexpected error: %v", i, err)
+			continue
+		}
+		contents, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatalf("#%d: failed to read keys: %v", i, err)
+		}
+		if string(contents) != test.out.contents {
+			t.Errorf("#%d: bad contents: want %q, got %q", i, test.out.contents, contents)
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatalf("#%d: failed to stat keys: %v", i, err)
+		}
+		if info.Mode().Perm() != 0600 {
+			t.Errorf("#%d: bad mode: want %v, got %v", i, os.FileMode(0600), info.Mode().Perm())
+		}
+	}
+}
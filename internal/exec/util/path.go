@@ -29,3 +29,11 @@ func NetworkdUnitsPath() string {
 func SystemdDropinsPath(unitName string) string {
 	return filepath.Join("etc", "systemd", "system", unitName+".d")
 }
+
+func NetworkdDropinsPath(unitName string) string {
+	return filepath.Join("etc", "systemd", "network", unitName+".d")
+}
+
+func SystemdPresetsPath() string {
+	return filepath.Join("etc", "systemd", "system-preset")
+}
@@ -0,0 +1,74 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/coreos/ignition/config/types"
+)
+
+const (
+	resolvBlockBegin = "# BEGIN Ignition provisioning resolver"
+	resolvBlockEnd   = "# END Ignition provisioning resolver"
+)
+
+// ResolvConfWithDns returns the contents of a resolv.conf which uses the
+// nameservers and search domains of dns ahead of those in existing. The
+// settings are kept in a block delimited by markers at the top of the file,
+// replacing any block written previously. Existing search and domain lines
+// are dropped when dns declares search domains, since only the last such line
+// takes effect.
+func ResolvConfWithDns(existing []byte, dns types.Dns) []byte {
+	out := &bytes.Buffer{}
+	fmt.Fprintln(out, resolvBlockBegin)
+	for _, ns := range dns.Nameservers {
+		fmt.Fprintf(out, "nameserver %s\n", ns)
+	}
+	if len(dns.Search) != 0 {
+		domains := make([]string, len(dns.Search))
+		for i, d := range dns.Search {
+			domains[i] = string(d)
+		}
+		fmt.Fprintf(out, "search %s\n", strings.Join(domains, " "))
+	}
+	fmt.Fprintln(out, resolvBlockEnd)
+
+	inBlock := false
+	for _, line := range strings.SplitAfter(string(existing), "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch trimmed {
+		case resolvBlockBegin:
+			inBlock = true
+			continue
+		case resolvBlockEnd:
+			inBlock = false
+			continue
+		}
+		if inBlock || line == "" {
+			continue
+		}
+		if len(dns.Search) != 0 && (strings.HasPrefix(trimmed, "search") || strings.HasPrefix(trimmed, "domain")) {
+			continue
+		}
+		out.WriteString(line)
+		if !strings.HasSuffix(line, "\n") {
+			out.WriteString("\n")
+		}
+	}
+	return out.Bytes()
+}
@@ -0,0 +1,66 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"testing"
+
+	"github.com/coreos/ignition/config/types"
+)
+
+func TestResolvConfWithDns(t *testing.T) {
+	type in struct {
+		existing string
+		dns      types.Dns
+	}
+	type out struct {
+		resolv string
+	}
+
+	nameservers := types.Dns{Nameservers: []types.Nameserver{"10.0.0.53", "10.0.1.53"}}
+	search := types.Dns{Nameservers: []types.Nameserver{"10.0.0.53"}, Search: []types.SearchDomain{"prov.example.com", "example.com"}}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{dns: nameservers},
+			out: out{resolv: "# BEGIN Ignition provisioning resolver\nnameserver 10.0.0.53\nnameserver 10.0.1.53\n# END Ignition provisioning resolver\n"},
+		},
+		{
+			in:  in{existing: "nameserver 192.168.0.1\nsearch lan\n", dns: nameservers},
+			out: out{resolv: "# BEGIN Ignition provisioning resolver\nnameserver 10.0.0.53\nnameserver 10.0.1.53\n# END Ignition provisioning resolver\nnameserver 192.168.0.1\nsearch lan\n"},
+		},
+		{
+			in:  in{existing: "nameserver 192.168.0.1\nsearch lan\ndomain lan", dns: search},
+			out: out{resolv: "# BEGIN Ignition provisioning resolver\nnameserver 10.0.0.53\nsearch prov.example.com example.com\n# END Ignition provisioning resolver\nnameserver 192.168.0.1\n"},
+		},
+		{
+			in: in{
+				existing: "# BEGIN Ignition provisioning resolver\nnameserver 10.9.9.9\n# END Ignition provisioning resolver\nnameserver 192.168.0.1\n",
+				dns:      nameservers,
+			},
+			out: out{resolv: "# BEGIN Ignition provisioning resolver\nnameserver 10.0.0.53\nnameserver 10.0.1.53\n# END Ignition provisioning resolver\nnameserver 192.168.0.1\n"},
+		},
+	}
+
+	for i, test := range tests {
+		resolv := string(ResolvConfWithDns([]byte(test.in.existing), test.in.dns))
+		if resolv != test.out.resolv {
+			t.Errorf("#%d: bad resolv.conf: want %q, got %q", i, test.out.resolv, resolv)
+		}
+	}
+}
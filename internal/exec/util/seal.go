@@ -0,0 +1,162 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+
+	"github.com/coreos/ignition/config/types"
+)
+
+// SealedPrefix marks the secrets replaced by SealConfig.
+const SealedPrefix = "ignition-sealed:"
+
+// SealConfig renders cfg as JSON with each secret which DumpConfig would
+// redact (the values of sensitive keys and headers, data URL sources, and
+// URLs with user info) replaced by SealedPrefix and the result of seal.
+func SealConfig(cfg types.Config, seal func(string) (string, error)) ([]byte, error) {
+	tree, err := toTree(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := mapSecrets(tree, func(s string) (string, error) {
+		sealed, err := seal(s)
+		return SealedPrefix + sealed, err
+	}); err != nil {
+		return nil, err
+	}
+	return json.Marshal(tree)
+}
+
+// UnsealConfig parses the config rendered by SealConfig, restoring each
+// sealed secret with unseal.
+func UnsealConfig(b []byte, unseal func(string) (string, error)) (types.Config, error) {
+	var tree interface{}
+	if err := json.Unmarshal(b, &tree); err != nil {
+		return types.Config{}, err
+	}
+	if err := unsealTree(tree, unseal); err != nil {
+		return types.Config{}, err
+	}
+	b, err := json.Marshal(tree)
+	if err != nil {
+		return types.Config{}, err
+	}
+	var cfg types.Config
+	err = json.Unmarshal(b, &cfg)
+	return cfg, err
+}
+
+// mapSecrets replaces the non-empty secrets within node in place with the
+// result of f.
+func mapSecrets(node interface{}, f func(string) (string, error)) error {
+	switch n := node.(type) {
+	case map[string]interface{}:
+		for k, v := range n {
+			s, isString := v.(string)
+			_, sensitive := sensitiveKeys[k]
+			_, header := headerKeys[k]
+			switch {
+			case sensitive && isString, isString && secretUrl(k, s):
+				if s == "" {
+					continue
+				}
+				sealed, err := f(s)
+				if err != nil {
+					return err
+				}
+				n[k] = sealed
+			case header:
+				for _, h := range headerList(v) {
+					if value, ok := h["value"].(string); ok && value != "" {
+						sealed, err := f(value)
+						if err != nil {
+							return err
+						}
+						h["value"] = sealed
+					}
+				}
+			default:
+				if err := mapSecrets(v, f); err != nil {
+					return err
+				}
+			}
+		}
+	case []interface{}:
+		for _, v := range n {
+			if err := mapSecrets(v, f); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// unsealTree restores the sealed strings within node in place with unseal.
+func unsealTree(node interface{}, unseal func(string) (string, error)) error {
+	restore := func(v interface{}) (interface{}, error) {
+		s, ok := v.(string)
+		if !ok || !strings.HasPrefix(s, SealedPrefix) {
+			return v, unsealTree(v, unseal)
+		}
+		return unseal(strings.TrimPrefix(s, SealedPrefix))
+	}
+	switch n := node.(type) {
+	case map[string]interface{}:
+		for k, v := range n {
+			r, err := restore(v)
+			if err != nil {
+				return err
+			}
+			n[k] = r
+		}
+	case []interface{}:
+		for i, v := range n {
+			r, err := restore(v)
+			if err != nil {
+				return err
+			}
+			n[i] = r
+		}
+	}
+	return nil
+}
+
+// secretUrl reports whether s, the value of key k, is a URL whose redaction
+// would hide its contents or credentials.
+func secretUrl(k, s string) bool {
+	if k == "source" && strings.HasPrefix(s, "data:") {
+		return true
+	}
+	u, err := url.Parse(s)
+	return err == nil && u.User != nil && strings.Contains(s, "://")
+}
+
+// headerList returns the header, or array of headers, at node.
+func headerList(node interface{}) []map[string]interface{} {
+	headers, ok := node.([]interface{})
+	if !ok {
+		headers = []interface{}{node}
+	}
+	var list []map[string]interface{}
+	for _, h := range headers {
+		if header, ok := h.(map[string]interface{}); ok {
+			list = append(list, header)
+		}
+	}
+	return list
+}
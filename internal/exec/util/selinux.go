@@ -0,0 +1,142 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/coreos/ignition/internal/tool"
+)
+
+// selinuxConfigPath is the SELinux configuration of the target system.
+const selinuxConfigPath = "/etc/selinux/config"
+
+// Relabeler collects the paths Ignition creates on the target system so that
+// they can be given their SELinux contexts once they have all been written.
+// Relabeling a directory relabels everything within it. A nil Relabeler
+// discards paths.
+type Relabeler struct {
+	paths []string
+}
+
+// Add records path, on the host rather than within DestDir, for relabeling.
+func (r *Relabeler) Add(path string) {
+	if r != nil {
+		r.paths = append(r.paths, path)
+	}
+}
+
+// mkdirForFile creates the directory components of path, recording the
+// topmost directory it creates for relabeling.
+func (u Util) mkdirForFile(path string) error {
+	top := ""
+	for p := filepath.Dir(path); p != "/" && p != "."; p = filepath.Dir(p) {
+		if _, err := os.Lstat(p); err == nil {
+			break
+		} else if !os.IsNotExist(err) {
+			return err
+		}
+		top = p
+	}
+	if err := MkdirForFile(path); err != nil {
+		return err
+	}
+	if top != "" {
+		u.Relabel.Add(top)
+	}
+	return nil
+}
+
+// RelabelFiles gives the paths recorded by u.Relabel the contexts of the
+// target system's SELinux policy, as listed in its file_contexts. Nothing is
+// relabeled if the target system has no policy or has SELinux disabled.
+func (u Util) RelabelFiles() error {
+	if u.Relabel == nil || len(u.Relabel.paths) == 0 {
+		return nil
+	}
+	contexts, err := u.selinuxFileContexts()
+	if err != nil || contexts == "" {
+		return err
+	}
+	if err := tool.Require(tool.Setfiles); err != nil {
+		return err
+	}
+
+	// Paths may since have been replaced or removed.
+	var paths []string
+	seen := map[string]bool{}
+	for _, p := range u.Relabel.paths {
+		if _, err := os.Lstat(p); err == nil && !seen[p] {
+			paths = append(paths, p)
+			seen[p] = true
+		}
+	}
+	if len(paths) == 0 {
+		return nil
+	}
+
+	cmd := tool.Setfiles.Command("-F", "-0", "-r", u.DestDir, contexts, "-f", "-")
+	cmd.Stdin = strings.NewReader(strings.Join(paths, "\x00") + "\x00")
+	return u.LogCmd(cmd, "relabeling %d paths", len(paths))
+}
+
+// selinuxFileContexts returns the path of the file_contexts of the target
+// system's SELinux policy, or "" if SELinux is disabled or not configured.
+func (u Util) selinuxFileContexts() (string, error) {
+	f, err := os.Open(u.JoinPath(selinuxConfigPath))
+	if os.IsNotExist(err) {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	mode, policy := "", ""
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		kv := strings.SplitN(line, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		value := strings.Trim(strings.TrimSpace(kv[1]), `"`)
+		switch strings.TrimSpace(kv[0]) {
+		case "SELINUX":
+			mode = value
+		case "SELINUXTYPE":
+			policy = value
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	if mode == "disabled" || policy == "" {
+		return "", nil
+	}
+
+	contexts := u.JoinPath("etc", "selinux", policy, "contexts", "files", "file_contexts")
+	if _, err := os.Stat(contexts); os.IsNotExist(err) {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+	return contexts, nil
+}
@@ -0,0 +1,153 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSelinuxFileContexts(t *testing.T) {
+	type in struct {
+		config   *string
+		contexts bool // whether the targeted policy's file_contexts exists
+	}
+	type out struct {
+		contexts string // relative to the root
+	}
+
+	str := func(s string) *string { return &s }
+	targeted := "/etc/selinux/targeted/contexts/files/file_contexts"
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{contexts: true},
+			out: out{},
+		},
+		{
+			in:  in{config: str("SELINUX=enforcing\nSELINUXTYPE=targeted\n"), contexts: true},
+			out: out{contexts: targeted},
+		},
+		{
+			in:  in{config: str("# comment\nSELINUX = permissive\nSELINUXTYPE=\"targeted\"\n"), contexts: true},
+			out: out{contexts: targeted},
+		},
+		{
+			in:  in{config: str("SELINUX=disabled\nSELINUXTYPE=targeted\n"), contexts: true},
+			out: out{},
+		},
+		{
+			in:  in{config: str("SELINUX=enforcing\nSELINUXTYPE=targeted\n")},
+			out: out{},
+		},
+		{
+			in:  in{config: str("SELINUX=enforcing\nSELINUXTYPE=mls\n"), contexts: true},
+			out: out{},
+		},
+	}
+
+	for i, test := range tests {
+		root, err := ioutil.TempDir("", "ignition-selinux-test")
+		if err != nil {
+			t.Fatalf("#%d: failed to create temp dir: %v", i, err)
+		}
+		defer os.RemoveAll(root)
+
+		if test.in.config != nil {
+			path := filepath.Join(root, selinuxConfigPath)
+			if err := MkdirForFile(path); err != nil {
+				t.Fatalf("#%d: failed to create directory: %v", i, err)
+			}
+			if err := ioutil.WriteFile(path, []byte(*test.in.config), 0644); err != nil {
+				t.Fatalf("#%d: failed to write config: %v", i, err)
+			}
+		}
+		if test.in.contexts {
+			path := filepath.Join(root, targeted)
+			if err := MkdirForFile(path); err != nil {
+				t.Fatalf("#%d: failed to create directory: %v", i, err)
+			}
+			if err := ioutil.WriteFile(path, nil, 0644); err != nil {
+				t.Fatalf("#%d: failed to write file_contexts: %v", i, err)
+			}
+		}
+
+		contexts, err := Util{DestDir: root}.selinuxFileContexts()
+		if err != nil {
+			t.Errorf("#%d: unexpected error: %v", i, err)
+			continue
+		}
+		want := ""
+		if test.out.contexts != "" {
+			want = filepath.Join(root, test.out.contexts)
+		}
+		if want != contexts {
+			t.Errorf("#%d: bad file_contexts: want %q, got %q", i, want, contexts)
+		}
+	}
+}
+
+func TestMkdirForFileRelabel(t *testing.T) {
+	type in struct {
+		path string
+	}
+	type out struct {
+		paths []string
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{path: "/etc/file"},
+			out: out{},
+		},
+		{
+			in:  in{path: "/etc/a/b/file"},
+			out: out{paths: []string{"/etc/a"}},
+		},
+	}
+
+	for i, test := range tests {
+		root, err := ioutil.TempDir("", "ignition-selinux-test")
+		if err != nil {
+			t.Fatalf("#%d: failed to create temp dir: %v", i, err)
+		}
+		defer os.RemoveAll(root)
+		if err := os.Mkdir(filepath.Join(root, "etc"), 0755); err != nil {
+			t.Fatalf("#%d: failed to create etc: %v", i, err)
+		}
+
+		u := Util{DestDir: root, Relabel: &Relabeler{}}
+		if err := u.mkdirForFile(u.JoinPath(test.in.path)); err != nil {
+			t.Errorf("#%d: unexpected error: %v", i, err)
+			continue
+		}
+		var want []string
+		for _, p := range test.out.paths {
+			want = append(want, u.JoinPath(p))
+		}
+		if !reflect.DeepEqual(want, u.Relabel.paths) {
+			t.Errorf("#%d: bad paths: want %v, got %v", i, want, u.Relabel.paths)
+		}
+	}
+}
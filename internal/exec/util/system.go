@@ -0,0 +1,155 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/coreos/ignition/config/types"
+)
+
+const (
+	DefaultMachineIdPermissions os.FileMode = 0444
+
+	hostsBlockBegin = "# BEGIN Ignition hosts entries"
+	hostsBlockEnd   = "# END Ignition hosts entries"
+)
+
+// FileFromMachineId returns the /etc/machine-id holding id.
+func FileFromMachineId(id string) *File {
+	return &File{
+		Path:       types.Path(filepath.Join("/etc", "machine-id")),
+		ReadCloser: ioutil.NopCloser(strings.NewReader(id + "\n")),
+		Mode:       DefaultMachineIdPermissions,
+	}
+}
+
+// FileFromSysctl returns the sysctl.d file which sets settings at boot.
+func FileFromSysctl(settings []types.Sysctl) *File {
+	buf := &bytes.Buffer{}
+	buf.WriteString("# Generated by Ignition\n")
+	for _, s := range settings {
+		fmt.Fprintf(buf, "%s = %s\n", s.Key, s.Value)
+	}
+	return &File{
+		Path:       types.Path(filepath.Join("/etc", "sysctl.d", "90-ignition.conf")),
+		ReadCloser: ioutil.NopCloser(buf),
+		Mode:       DefaultFilePermissions,
+	}
+}
+
+// FilesFromKernelModules returns the modules-load.d and modprobe.d files which
+// configure modules at boot. Either is nil if it would be empty.
+func FilesFromKernelModules(modules []types.KernelModule) (load *File, modprobe *File) {
+	loadBuf := &bytes.Buffer{}
+	probeBuf := &bytes.Buffer{}
+	for _, m := range modules {
+		if m.Load {
+			fmt.Fprintf(loadBuf, "%s\n", m.Name)
+		}
+		if m.Blacklist {
+			fmt.Fprintf(probeBuf, "blacklist %s\n", m.Name)
+		}
+		if m.Options != "" {
+			fmt.Fprintf(probeBuf, "options %s %s\n", m.Name, m.Options)
+		}
+	}
+
+	if loadBuf.Len() > 0 {
+		load = &File{
+			Path:       types.Path(filepath.Join("/etc", "modules-load.d", "ignition.conf")),
+			ReadCloser: ioutil.NopCloser(io.MultiReader(strings.NewReader("# Generated by Ignition\n"), loadBuf)),
+			Mode:       DefaultFilePermissions,
+		}
+	}
+	if probeBuf.Len() > 0 {
+		modprobe = &File{
+			Path:       types.Path(filepath.Join("/etc", "modprobe.d", "ignition.conf")),
+			ReadCloser: ioutil.NopCloser(io.MultiReader(strings.NewReader("# Generated by Ignition\n"), probeBuf)),
+			Mode:       DefaultFilePermissions,
+		}
+	}
+	return
+}
+
+// NewMachineId returns a random machine ID in the format of systemd, a
+// version 4 UUID written as 32 lowercase hex digits.
+func NewMachineId() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	id[6] = (id[6] & 0x0f) | 0x40
+	id[8] = (id[8] & 0x3f) | 0x80
+	return hex.EncodeToString(id), nil
+}
+
+// HostsWithEntries returns the contents of an /etc/hosts which keeps the
+// lines of existing and lists entries in a block delimited by markers. Any
+// block written previously is replaced in place, so that rewriting the file
+// is idempotent.
+func HostsWithEntries(existing []byte, entries []types.HostEntry) []byte {
+	block := &bytes.Buffer{}
+	for _, e := range entries {
+		fmt.Fprintf(block, "%s\t%s\n", e.Address, strings.Join(e.Hostnames, " "))
+	}
+	return withBlock(existing, hostsBlockBegin, hostsBlockEnd, block.Bytes())
+}
+
+// withBlock returns the lines of existing with lines placed between the begin
+// and end markers, replacing any block delimited by them already.
+func withBlock(existing []byte, begin, end string, lines []byte) []byte {
+	block := &bytes.Buffer{}
+	fmt.Fprintln(block, begin)
+	block.Write(lines)
+	fmt.Fprintln(block, end)
+
+	out := &bytes.Buffer{}
+	written := false
+	inBlock := false
+	for _, line := range strings.SplitAfter(string(existing), "\n") {
+		switch strings.TrimSpace(line) {
+		case begin:
+			inBlock = true
+			continue
+		case end:
+			if inBlock && !written {
+				out.Write(block.Bytes())
+				written = true
+			}
+			inBlock = false
+			continue
+		}
+		if inBlock || line == "" {
+			continue
+		}
+		out.WriteString(line)
+		if !strings.HasSuffix(line, "\n") {
+			out.WriteString("\n")
+		}
+	}
+	if !written {
+		out.Write(block.Bytes())
+	}
+	return out.Bytes()
+}
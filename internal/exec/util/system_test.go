@@ -0,0 +1,119 @@
+// Copyright 2016 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/coreos/ignition/config/types"
+)
+
+func TestHostsWithEntries(t *testing.T) {
+	type in struct {
+		existing string
+		entries  []types.HostEntry
+	}
+	type out struct {
+		hosts string
+	}
+
+	entries := []types.HostEntry{
+		{Address: "10.0.0.1", Hostnames: []string{"node1.cluster", "node1"}},
+		{Address: "10.0.0.2", Hostnames: []string{"node2"}},
+	}
+	block := "# BEGIN Ignition hosts entries\n10.0.0.1\tnode1.cluster node1\n10.0.0.2\tnode2\n# END Ignition hosts entries\n"
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{entries: entries},
+			out: out{hosts: block},
+		},
+		{
+			in:  in{existing: "127.0.0.1\tlocalhost\n", entries: entries},
+			out: out{hosts: "127.0.0.1\tlocalhost\n" + block},
+		},
+		{
+			in:  in{existing: "127.0.0.1\tlocalhost", entries: entries},
+			out: out{hosts: "127.0.0.1\tlocalhost\n" + block},
+		},
+		{
+			in: in{
+				existing: "127.0.0.1\tlocalhost\n# BEGIN Ignition hosts entries\n10.0.0.9\told\n# END Ignition hosts entries\n::1\tlocalhost\n",
+				entries:  entries,
+			},
+			out: out{hosts: "127.0.0.1\tlocalhost\n" + block + "::1\tlocalhost\n"},
+		},
+	}
+
+	for i, test := range tests {
+		hosts := string(HostsWithEntries([]byte(test.in.existing), test.in.entries))
+		if hosts != test.out.hosts {
+			t.Errorf("#%d: bad hosts: want %q, got %q", i, test.out.hosts, hosts)
+		}
+	}
+}
+
+func TestFilesFromKernelModules(t *testing.T) {
+	type in struct {
+		modules []types.KernelModule
+	}
+	type out struct {
+		load     string
+		modprobe string
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{},
+			out: out{},
+		},
+		{
+			in: in{modules: []types.KernelModule{
+				{Name: "vfio-pci", Load: true, Options: "ids=10de:1b80"},
+				{Name: "nouveau", Blacklist: true},
+				{Name: "br_netfilter", Load: true},
+			}},
+			out: out{
+				load:     "# Generated by Ignition\nvfio-pci\nbr_netfilter\n",
+				modprobe: "# Generated by Ignition\noptions vfio-pci ids=10de:1b80\nblacklist nouveau\n",
+			},
+		},
+	}
+
+	contents := func(f *File) string {
+		if f == nil {
+			return ""
+		}
+		b, _ := ioutil.ReadAll(f)
+		return string(b)
+	}
+
+	for i, test := range tests {
+		load, modprobe := FilesFromKernelModules(test.in.modules)
+		if c := contents(load); c != test.out.load {
+			t.Errorf("#%d: bad modules-load.d: want %q, got %q", i, test.out.load, c)
+		}
+		if c := contents(modprobe); c != test.out.modprobe {
+			t.Errorf("#%d: bad modprobe.d: want %q, got %q", i, test.out.modprobe, c)
+		}
+	}
+}
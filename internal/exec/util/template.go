@@ -0,0 +1,117 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"text/template"
+)
+
+// TemplateVariables are the values available to templated file contents,
+// e.g. {{.Hostname}}.
+type TemplateVariables struct {
+	Hostname   string
+	Platform   string
+	PrimaryMAC string
+	PrimaryIP  string
+}
+
+// TemplateVariables detects the values of the template variables on this
+// machine. The primary interface is the first one, in index order, which is
+// up, is not a loopback, and has a hardware address; its IPv4 address is
+// preferred over any IPv6 address.
+func (u Util) TemplateVariables() TemplateVariables {
+	vars := TemplateVariables{Platform: u.Platform}
+	if hostname, err := os.Hostname(); err == nil {
+		vars.Hostname = hostname
+	}
+
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		u.Warning("failed to list network interfaces: %v", err)
+		return vars
+	}
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 || len(iface.HardwareAddr) == 0 {
+			continue
+		}
+		vars.PrimaryMAC = iface.HardwareAddr.String()
+		addrs, _ := iface.Addrs()
+		for _, addr := range addrs {
+			ipnet, ok := addr.(*net.IPNet)
+			if !ok {
+				continue
+			}
+			if ipnet.IP.To4() != nil {
+				vars.PrimaryIP = ipnet.IP.String()
+				break
+			}
+			if vars.PrimaryIP == "" {
+				vars.PrimaryIP = ipnet.IP.String()
+			}
+		}
+		break
+	}
+
+	return vars
+}
+
+// TemplateFile arranges for the template variables to be substituted into the
+// contents of f as it is read.
+func (u Util) TemplateFile(f *File) {
+	f.ReadCloser = newTemplateReader(f.ReadCloser, u.TemplateVariables())
+	f.templated = true
+}
+
+// templateReader substitutes the template variables into the contents of the
+// ReadCloser it wraps. The wrapped contents are read in full on the first
+// Read, so that any verification of them completes before the rendered
+// contents are returned.
+type templateReader struct {
+	source   io.ReadCloser
+	vars     TemplateVariables
+	rendered io.Reader
+}
+
+func newTemplateReader(reader io.ReadCloser, vars TemplateVariables) io.ReadCloser {
+	return &templateReader{source: reader, vars: vars}
+}
+
+func (r *templateReader) Read(p []byte) (int, error) {
+	if r.rendered == nil {
+		contents, err := ioutil.ReadAll(r.source)
+		if err != nil {
+			return 0, err
+		}
+		tmpl, err := template.New("contents").Parse(string(contents))
+		if err != nil {
+			return 0, err
+		}
+		buf := &bytes.Buffer{}
+		if err := tmpl.Execute(buf, r.vars); err != nil {
+			return 0, err
+		}
+		r.rendered = buf
+	}
+	return r.rendered.Read(p)
+}
+
+func (r *templateReader) Close() error {
+	return r.source.Close()
+}
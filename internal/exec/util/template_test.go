@@ -0,0 +1,76 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestTemplateReader(t *testing.T) {
+	type in struct {
+		contents string
+		vars     TemplateVariables
+	}
+	type out struct {
+		contents string
+		err      bool
+	}
+
+	vars := TemplateVariables{
+		Hostname:   "node1",
+		Platform:   "qemu",
+		PrimaryMAC: "52:54:00:12:34:56",
+		PrimaryIP:  "10.0.2.15",
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{contents: "no placeholders\n", vars: vars},
+			out: out{contents: "no placeholders\n"},
+		},
+		{
+			in:  in{contents: "{{.Hostname}} on {{.Platform}}: {{.PrimaryMAC}} {{.PrimaryIP}}", vars: vars},
+			out: out{contents: "node1 on qemu: 52:54:00:12:34:56 10.0.2.15"},
+		},
+		{
+			in:  in{contents: "{{.Hostname}}", vars: TemplateVariables{}},
+			out: out{contents: ""},
+		},
+		{
+			in:  in{contents: "{{.Unknown}}", vars: vars},
+			out: out{err: true},
+		},
+		{
+			in:  in{contents: "{{.Hostname", vars: vars},
+			out: out{err: true},
+		},
+	}
+
+	for i, test := range tests {
+		reader := newTemplateReader(ioutil.NopCloser(strings.NewReader(test.in.contents)), test.in.vars)
+		b, err := ioutil.ReadAll(reader)
+		if test.out.err != (err != nil) {
+			t.Errorf("#%d: bad error: want error %t, got %v", i, test.out.err, err)
+		}
+		if string(b) != test.out.contents {
+			t.Errorf("#%d: bad contents: want %q, got %q", i, test.out.contents, string(b))
+		}
+	}
+}
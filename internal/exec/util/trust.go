@@ -0,0 +1,96 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/coreos/ignition/config/types"
+)
+
+const (
+	TrustUpdateUnitName = "ignition-trust-update.service"
+
+	trustUpdatedStamp = "/var/lib/ignition/trust-updated"
+)
+
+// TrustStore describes how the system trust store of a distribution is
+// extended with additional CA certificates.
+type TrustStore struct {
+	Dir    string   // directory holding the additional certificates
+	Ext    string   // extension the update mechanism expects
+	Update []string // command rebuilding the trust store
+}
+
+var (
+	// p11-kit based stores (Fedora, RHEL)
+	caTrustStore = TrustStore{
+		Dir:    "/etc/pki/ca-trust/source/anchors",
+		Ext:    ".pem",
+		Update: []string{"/usr/bin/update-ca-trust", "extract"},
+	}
+	// ca-certificates based stores (Debian, Ubuntu)
+	caCertificatesStore = TrustStore{
+		Dir:    "/usr/local/share/ca-certificates",
+		Ext:    ".crt",
+		Update: []string{"/usr/sbin/update-ca-certificates"},
+	}
+)
+
+// TrustStore returns the trust store of the target root, preferring p11-kit if
+// its update tool is present.
+func (u Util) TrustStore() TrustStore {
+	if _, err := os.Stat(u.JoinPath(caTrustStore.Update[0])); err == nil {
+		return caTrustStore
+	}
+	return caCertificatesStore
+}
+
+// TrustAnchorPath returns the path of the nth additional CA certificate in
+// store.
+func (store TrustStore) TrustAnchorPath(n int) types.Path {
+	return types.Path(filepath.Join(store.Dir, fmt.Sprintf("ignition-%d%s", n, store.Ext)))
+}
+
+// TrustUpdateUnit returns an enabled unit which rebuilds store once, early on
+// the first boot of the target system.
+func TrustUpdateUnit(store TrustStore) types.SystemdUnit {
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "# Generated by Ignition\n")
+	fmt.Fprintf(buf, "[Unit]\n")
+	fmt.Fprintf(buf, "Description=Add Ignition-provided CAs to the system trust store\n")
+	fmt.Fprintf(buf, "DefaultDependencies=no\n")
+	fmt.Fprintf(buf, "After=local-fs.target\n")
+	fmt.Fprintf(buf, "Before=sysinit.target\n")
+	fmt.Fprintf(buf, "ConditionPathExists=!%s\n", trustUpdatedStamp)
+	fmt.Fprintf(buf, "\n[Service]\n")
+	fmt.Fprintf(buf, "Type=oneshot\n")
+	fmt.Fprintf(buf, "RemainAfterExit=yes\n")
+	fmt.Fprintf(buf, "ExecStart=%s\n", strings.Join(store.Update, " "))
+	fmt.Fprintf(buf, "ExecStartPost=/usr/bin/mkdir -p %s\n", filepath.Dir(trustUpdatedStamp))
+	fmt.Fprintf(buf, "ExecStartPost=/usr/bin/touch %s\n", trustUpdatedStamp)
+	fmt.Fprintf(buf, "\n[Install]\n")
+	fmt.Fprintf(buf, "WantedBy=sysinit.target\n")
+
+	return types.SystemdUnit{
+		Name:     TrustUpdateUnitName,
+		Enable:   true,
+		Contents: buf.String(),
+	}
+}
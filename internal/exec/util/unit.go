@@ -16,10 +16,10 @@ package util
 
 import (
 	"bytes"
-	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/coreos/ignition/config/types"
 )
@@ -53,6 +53,22 @@ func FileFromUnitDropin(unit types.SystemdUnit, dropin types.SystemdUnitDropIn)
 	}
 }
 
+func FileFromNetworkdUnitDropin(unit types.NetworkdUnit, dropin types.SystemdUnitDropIn) *File {
+	return &File{
+		Path:       types.Path(filepath.Join(NetworkdDropinsPath(string(unit.Name)), string(dropin.Name))),
+		ReadCloser: ioutil.NopCloser(bytes.NewReader([]byte(dropin.Contents))),
+		Mode:       DefaultFilePermissions,
+	}
+}
+
+func FileFromSystemdPreset(preset types.SystemdPreset) *File {
+	return &File{
+		Path:       types.Path(filepath.Join(SystemdPresetsPath(), string(preset.Name))),
+		ReadCloser: ioutil.NopCloser(bytes.NewReader([]byte(preset.Contents))),
+		Mode:       DefaultPresetPermissions,
+	}
+}
+
 func (u Util) MaskUnit(unit types.SystemdUnit) error {
 	path := u.JoinPath(SystemdUnitsPath(), string(unit.Name))
 	if err := MkdirForFile(path); err != nil {
@@ -64,7 +80,15 @@ func (u Util) MaskUnit(unit types.SystemdUnit) error {
 	return os.Symlink("/dev/null", path)
 }
 
-func (u Util) EnableUnit(unit types.SystemdUnit) error {
+// WritePresets adds enable and disable directives for units to Ignition's
+// preset file on the target root. Ignition's preset file sorts before the
+// vendor ones, so a unit disabled here stays disabled even if a vendor preset
+// enables it.
+func (u Util) WritePresets(units []types.SystemdUnit) error {
+	directives := unitPresets(units)
+	if len(directives) == 0 {
+		return nil
+	}
 	path := u.JoinPath(presetPath)
 	if err := MkdirForFile(path); err != nil {
 		return err
@@ -75,6 +99,98 @@ func (u Util) EnableUnit(unit types.SystemdUnit) error {
 	}
 	defer file.Close()
 
-	_, err = file.WriteString(fmt.Sprintf("enable %s\n", unit.Name))
-	return err
+	if _, err = file.WriteString(strings.Join(directives, "\n") + "\n"); err != nil {
+		return err
+	}
+	return file.Sync()
+}
+
+// unitPreset gathers the requested enablement of a unit, or of a template and
+// its instances.
+type unitPreset struct {
+	name      types.SystemdUnitName
+	self      *bool
+	def       string
+	instances []string
+	enabled   map[string]bool
+}
+
+// unitPresets returns the preset directives for units, in the order the units
+// are first listed. A later setting for a unit replaces an earlier one.
+//
+// Presets apply to unit files, so instances can't be named directly: the
+// enabled instances of a template are listed on a single directive for the
+// template, since only the first directive matching a unit counts. A template
+// enabled by itself stands for its DefaultInstance. Presets can't disable
+// individual instances, so disabling an instance, with no other instance of
+// its template enabled, disables the template.
+func unitPresets(units []types.SystemdUnit) []string {
+	var order []*unitPreset
+	presets := map[types.SystemdUnitName]*unitPreset{}
+	for _, unit := range units {
+		enabled := unit.Enablement()
+		if enabled == nil {
+			continue
+		}
+		name, instance := unit.Name.Instance()
+		p, ok := presets[name]
+		if !ok {
+			p = &unitPreset{name: name, enabled: map[string]bool{}}
+			presets[name] = p
+			order = append(order, p)
+		}
+		if instance == "" {
+			p.self = enabled
+			if def := unit.DefaultInstance(); def != "" {
+				p.def = def
+			}
+			continue
+		}
+		if _, ok := p.enabled[instance]; !ok {
+			p.instances = append(p.instances, instance)
+		}
+		p.enabled[instance] = *enabled
+	}
+
+	var directives []string
+	for _, p := range order {
+		if !p.name.IsTemplate() {
+			directives = append(directives, presetDirective(*p.self, p.name))
+			continue
+		}
+		selfEnabled := p.self != nil && *p.self
+		var instances []string
+		if selfEnabled && p.def != "" {
+			if enabled, ok := p.enabled[p.def]; !ok {
+				instances = append(instances, p.def)
+			} else if !enabled {
+				selfEnabled = false
+			}
+		}
+		disabled := p.self != nil && !*p.self
+		for _, instance := range p.instances {
+			if p.enabled[instance] {
+				instances = append(instances, instance)
+			} else {
+				disabled = true
+			}
+		}
+		switch {
+		case len(instances) > 0:
+			directives = append(directives, presetDirective(true, p.name, instances...))
+		case selfEnabled:
+			directives = append(directives, presetDirective(true, p.name))
+		case disabled:
+			directives = append(directives, presetDirective(false, p.name))
+		}
+	}
+	return directives
+}
+
+func presetDirective(enable bool, name types.SystemdUnitName, instances ...string) string {
+	directive := "disable"
+	if enable {
+		directive = "enable"
+	}
+	return strings.Join(append([]string{directive, string(name)}, instances...), " ")
 }
@@ -0,0 +1,80 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/coreos/ignition/config/types"
+)
+
+func TestUnitPresets(t *testing.T) {
+	type in struct {
+		units []types.SystemdUnit
+	}
+	type out struct {
+		directives []string
+	}
+
+	yes := true
+	no := false
+	withDefault := "[Install]\nDefaultInstance=tty1\nWantedBy=getty.target"
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{units: []types.SystemdUnit{{Name: "a.service", Enable: true}, {Name: "b.service"}, {Name: "c.timer", Enabled: &no}}},
+			out: out{directives: []string{"enable a.service", "disable c.timer"}},
+		},
+		{
+			in:  in{units: []types.SystemdUnit{{Name: "a.service", Enable: true}, {Name: "a.service", Enabled: &no}}},
+			out: out{directives: []string{"disable a.service"}},
+		},
+		{
+			in:  in{units: []types.SystemdUnit{{Name: "getty@.service", Enable: true}}},
+			out: out{directives: []string{"enable getty@.service"}},
+		},
+		{
+			in:  in{units: []types.SystemdUnit{{Name: "getty@tty2.service", Enabled: &yes}, {Name: "getty@ttyS0.service", Enable: true}}},
+			out: out{directives: []string{"enable getty@.service tty2 ttyS0"}},
+		},
+		{
+			in:  in{units: []types.SystemdUnit{{Name: "getty@tty2.service", Enable: true}, {Name: "getty@.service", Enable: true, Contents: withDefault}}},
+			out: out{directives: []string{"enable getty@.service tty1 tty2"}},
+		},
+		{
+			in:  in{units: []types.SystemdUnit{{Name: "getty@.service", Enable: true, Contents: withDefault}, {Name: "getty@tty1.service", Enabled: &no}}},
+			out: out{directives: []string{"disable getty@.service"}},
+		},
+		{
+			in:  in{units: []types.SystemdUnit{{Name: "getty@tty2.service", Enabled: &no}}},
+			out: out{directives: []string{"disable getty@.service"}},
+		},
+		{
+			in:  in{units: []types.SystemdUnit{{Name: "getty@tty2.service", Enabled: &no}, {Name: "getty@tty3.service", Enable: true}}},
+			out: out{directives: []string{"enable getty@.service tty3"}},
+		},
+	}
+
+	for i, test := range tests {
+		directives := unitPresets(test.in.units)
+		if !reflect.DeepEqual(test.out.directives, directives) {
+			t.Errorf("#%d: bad directives: want %q, got %q", i, test.out.directives, directives)
+		}
+	}
+}
@@ -15,17 +15,88 @@
 package util
 
 import (
+	"fmt"
 	"path/filepath"
+	"strings"
 
+	"github.com/coreos/ignition/config/types"
 	"github.com/coreos/ignition/internal/log"
+
+	"golang.org/x/net/context"
 )
 
+// DefaultStateDir is where the stages keep state for the later stages of the
+// boot, when Util.StateDir is empty.
+const DefaultStateDir = "/run/ignition"
+
 // Util encapsulates logging and destdir indirection for the util methods.
 type Util struct {
-	DestDir string // directory prefix to use in applying fs paths.
+	DestDir  string // directory prefix to use in applying fs paths.
+	Platform string // name of the platform, as given by the oem.
+	// StateDir is where the stages keep state for the later stages of the
+	// boot, such as the mount record and fetched luks keys. An empty
+	// StateDir means DefaultStateDir.
+	StateDir string
+	// ResultFile is where, on the target system, the results of
+	// provisioning are recorded, or "" if they aren't.
+	ResultFile string
+	// AccountRoot is the root whose account databases resolve owners given
+	// by name. An empty AccountRoot means DestDir.
+	AccountRoot string
+	// Context cancels the stage's fetches and stops it between steps. A nil
+	// Context never cancels.
+	Context context.Context
+	// Relabel collects the paths created on the target system for SELinux
+	// relabeling. A nil Relabel skips relabeling.
+	Relabel *Relabeler
 	*log.Logger
 }
 
+// Step is one of the operations a stage performs in turn.
+type Step struct {
+	Name string // e.g. "create partitions"
+	Run  func(types.Config) error
+}
+
+// RunSteps runs steps against cfg in order, stopping at the first failure or,
+// before starting the next step, once u.Context is done. Either way, the
+// steps completed so far are logged, since the system is left partially
+// provisioned, and the reason the steps stopped is returned.
+func (u Util) RunSteps(cfg types.Config, steps []Step) error {
+	for i, step := range steps {
+		if u.Context != nil && u.Context.Err() != nil {
+			u.logCompleted(steps[:i])
+			return fmt.Errorf("cancelled before %s: %v", step.Name, u.Context.Err())
+		}
+		if err := step.Run(cfg); err != nil {
+			u.logCompleted(steps[:i])
+			return fmt.Errorf("failed to %s: %v", step.Name, err)
+		}
+	}
+	return nil
+}
+
+func (u Util) logCompleted(steps []Step) {
+	if len(steps) == 0 {
+		u.Info("no steps were completed")
+		return
+	}
+	names := make([]string, 0, len(steps))
+	for _, step := range steps {
+		names = append(names, step.Name)
+	}
+	u.Info("completed steps: %s", strings.Join(names, ", "))
+}
+
+// StatePath returns the path of name within the state directory.
+func (u Util) StatePath(name string) string {
+	dir := u.StateDir
+	if dir == "" {
+		dir = DefaultStateDir
+	}
+	return filepath.Join(dir, name)
+}
+
 // JoinPath returns a path into the context ala filepath.Join(d, args)
 func (u Util) JoinPath(path ...string) string {
 	return filepath.Join(u.DestDir, filepath.Join(path...))
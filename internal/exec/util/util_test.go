@@ -0,0 +1,94 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/coreos/ignition/config/types"
+	"github.com/coreos/ignition/internal/log"
+
+	"golang.org/x/net/context"
+)
+
+func TestRunSteps(t *testing.T) {
+	type in struct {
+		failAt   int // index of the step that fails, or -1
+		cancelAt int // index of the step that cancels the context, or -1
+	}
+	type out struct {
+		ok  bool
+		ran []int
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{failAt: -1, cancelAt: -1},
+			out: out{ok: true, ran: []int{0, 1, 2}},
+		},
+		{
+			in:  in{failAt: 1, cancelAt: -1},
+			out: out{ok: false, ran: []int{0, 1}},
+		},
+		{
+			in:  in{failAt: -1, cancelAt: 0},
+			out: out{ok: false, ran: []int{0}},
+		},
+		{
+			in:  in{failAt: -1, cancelAt: 2},
+			out: out{ok: true, ran: []int{0, 1, 2}},
+		},
+	}
+
+	logger := log.New()
+	defer logger.Close()
+
+	for i, test := range tests {
+		ctx, cancel := context.WithCancel(context.Background())
+		ran := []int{}
+		steps := []Step{}
+		for j := 0; j < 3; j++ {
+			j := j
+			steps = append(steps, Step{
+				Name: "step",
+				Run: func(types.Config) error {
+					ran = append(ran, j)
+					if j == test.in.cancelAt {
+						cancel()
+					}
+					if j == test.in.failAt {
+						return errors.New("failed")
+					}
+					return nil
+				},
+			})
+		}
+
+		u := Util{Context: ctx, Logger: &logger}
+		ok := u.RunSteps(types.Config{}, steps) == nil
+		cancel()
+		if ok != test.out.ok {
+			t.Errorf("#%d: bad result: want %v, got %v", i, test.out.ok, ok)
+		}
+		if !reflect.DeepEqual(test.out.ran, ran) {
+			t.Errorf("#%d: bad steps run: want %v, got %v", i, test.out.ran, ran)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Format is how a Logger renders messages. It can be set from the command
+// line.
+type Format int
+
+const (
+	// FormatText prefixes each message with the prefix stack.
+	FormatText Format = iota
+	// FormatJSON renders each message as a single-line JSON object, so that
+	// log aggregators can parse it without regexes.
+	FormatJSON
+)
+
+var formatNames = []string{"text", "json"}
+
+func (f Format) String() string {
+	if int(f) < len(formatNames) && f >= 0 {
+		return formatNames[f]
+	}
+	return fmt.Sprintf("Format(%d)", int(f))
+}
+
+func (f *Format) Set(val string) error {
+	for i, name := range formatNames {
+		if strings.EqualFold(val, name) {
+			*f = Format(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("%s is not a valid log format %v", val, formatNames)
+}
+
+// jsonMessage is a message rendered in FormatJSON.
+type jsonMessage struct {
+	Timestamp string `json:"timestamp"`
+	Level     string `json:"level"`
+	Prefix    string `json:"prefix,omitempty"`
+	Message   string `json:"message"`
+}
+
+// jsonSprintf renders the message as a JSON object, with the prefix stack
+// kept apart from the message.
+func (l Logger) jsonSprintf(level Level, format string, a ...interface{}) string {
+	b, err := json.Marshal(jsonMessage{
+		Timestamp: time.Now().UTC().Format(time.RFC3339Nano),
+		Level:     level.String(),
+		Prefix:    strings.Join(l.prefixStack, ": "),
+		Message:   fmt.Sprintf(format, a...),
+	})
+	if err != nil {
+		return l.sprintf(format, a...)
+	}
+	return string(b)
+}
@@ -0,0 +1,85 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// recorder records the messages passed to it regardless of priority.
+type recorder struct {
+	msgs []string
+}
+
+func (r *recorder) record(msg string) error { r.msgs = append(r.msgs, msg); return nil }
+
+func (r *recorder) Emerg(msg string) error   { return r.record(msg) }
+func (r *recorder) Alert(msg string) error   { return r.record(msg) }
+func (r *recorder) Crit(msg string) error    { return r.record(msg) }
+func (r *recorder) Err(msg string) error     { return r.record(msg) }
+func (r *recorder) Warning(msg string) error { return r.record(msg) }
+func (r *recorder) Notice(msg string) error  { return r.record(msg) }
+func (r *recorder) Info(msg string) error    { return r.record(msg) }
+func (r *recorder) Debug(msg string) error   { return r.record(msg) }
+func (r *recorder) Close() error             { return nil }
+
+func TestFormatJSON(t *testing.T) {
+	type in struct {
+		prefixes []string
+		message  string
+	}
+	type out struct {
+		prefix  string
+		message string
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{message: "no prefix"},
+			out: out{message: "no prefix"},
+		},
+		{
+			in:  in{prefixes: []string{"disks", "op(1)"}, message: `"quoted"` + "\nand split"},
+			out: out{prefix: "disks: op(1)", message: `"quoted"` + "\nand split"},
+		},
+	}
+
+	for i, test := range tests {
+		ops := &recorder{}
+		logger := Logger{ops: ops, level: LevelDebug}
+		logger.SetFormat(FormatJSON)
+		for _, p := range test.in.prefixes {
+			logger.PushPrefix("%s", p)
+		}
+		logger.Warning("%s", test.in.message)
+
+		if len(ops.msgs) != 1 {
+			t.Errorf("#%d: bad messages: want 1, got %q", i, ops.msgs)
+			continue
+		}
+		msg := jsonMessage{}
+		if err := json.Unmarshal([]byte(ops.msgs[0]), &msg); err != nil {
+			t.Errorf("#%d: bad JSON %q: %v", i, ops.msgs[0], err)
+			continue
+		}
+		if msg.Level != "warning" || msg.Prefix != test.out.prefix || msg.Message != test.out.message || msg.Timestamp == "" {
+			t.Errorf("#%d: bad message: want %q at warning with prefix %q, got %+v", i, test.out.message, test.out.prefix, msg)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"fmt"
+	"log/syslog"
+	"os"
+	"strings"
+)
+
+var (
+	kmsgPath    = "/dev/kmsg"
+	consolePath = "/dev/console"
+)
+
+// OpenKmsg opens the kernel log for writing, so that messages survive even if
+// journald never starts or the journal is lost. Each line of a message is a
+// record at the message's priority.
+func OpenKmsg() (LoggerOps, error) {
+	f, err := os.OpenFile(kmsgPath, os.O_WRONLY, 0)
+	if err != nil {
+		return nil, err
+	}
+	pid := os.Getpid()
+	return levelOps{
+		write: func(level Level, msg string) error {
+			for _, line := range strings.Split(msg, "\n") {
+				if _, err := fmt.Fprintf(f, "<%d>ignition[%d]: %s\n", syslog.LOG_USER|syslog.Priority(level), pid, line); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		close: f.Close,
+	}, nil
+}
+
+// OpenConsole opens the system console for writing, so that failures are
+// visible to whoever watches the machine boot.
+func OpenConsole() (LoggerOps, error) {
+	f, err := os.OpenFile(consolePath, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		return nil, err
+	}
+	return levelOps{
+		write: func(level Level, msg string) error {
+			_, err := fmt.Fprintf(f, "ignition: %s: %s\n", level, msg)
+			return err
+		},
+		close: f.Close,
+	}, nil
+}
+
+// levelOps adapts a function writing messages at a given level to LoggerOps.
+type levelOps struct {
+	write func(Level, string) error
+	close func() error
+}
+
+func (o levelOps) Emerg(msg string) error   { return o.write(LevelEmerg, msg) }
+func (o levelOps) Alert(msg string) error   { return o.write(LevelAlert, msg) }
+func (o levelOps) Crit(msg string) error    { return o.write(LevelCrit, msg) }
+func (o levelOps) Err(msg string) error     { return o.write(LevelErr, msg) }
+func (o levelOps) Warning(msg string) error { return o.write(LevelWarning, msg) }
+func (o levelOps) Notice(msg string) error  { return o.write(LevelNotice, msg) }
+func (o levelOps) Info(msg string) error    { return o.write(LevelInfo, msg) }
+func (o levelOps) Debug(msg string) error   { return o.write(LevelDebug, msg) }
+func (o levelOps) Close() error             { return o.close() }
+
+// teeOps writes every message to each of its LoggerOps in turn.
+type teeOps []LoggerOps
+
+func (t teeOps) each(f func(LoggerOps) error) (err error) {
+	for _, ops := range t {
+		if e := f(ops); e != nil && err == nil {
+			err = e
+		}
+	}
+	return
+}
+
+func (t teeOps) Emerg(msg string) error {
+	return t.each(func(o LoggerOps) error { return o.Emerg(msg) })
+}
+func (t teeOps) Alert(msg string) error {
+	return t.each(func(o LoggerOps) error { return o.Alert(msg) })
+}
+func (t teeOps) Crit(msg string) error { return t.each(func(o LoggerOps) error { return o.Crit(msg) }) }
+func (t teeOps) Err(msg string) error  { return t.each(func(o LoggerOps) error { return o.Err(msg) }) }
+func (t teeOps) Warning(msg string) error {
+	return t.each(func(o LoggerOps) error { return o.Warning(msg) })
+}
+func (t teeOps) Notice(msg string) error {
+	return t.each(func(o LoggerOps) error { return o.Notice(msg) })
+}
+func (t teeOps) Info(msg string) error { return t.each(func(o LoggerOps) error { return o.Info(msg) }) }
+func (t teeOps) Debug(msg string) error {
+	return t.each(func(o LoggerOps) error { return o.Debug(msg) })
+}
+func (t teeOps) Close() error { return t.each(func(o LoggerOps) error { return o.Close() }) }
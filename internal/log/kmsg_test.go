@@ -0,0 +1,62 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestKmsg(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ignition-kmsg")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	kmsgPath = filepath.Join(dir, "kmsg")
+	defer func() { kmsgPath = "/dev/kmsg" }()
+	if err := ioutil.WriteFile(kmsgPath, nil, 0600); err != nil {
+		t.Fatalf("failed to create kmsg: %v", err)
+	}
+
+	ops, err := OpenKmsg()
+	if err != nil {
+		t.Fatalf("failed to open kmsg: %v", err)
+	}
+	primary := &recorder{}
+	logger := Logger{ops: primary, level: LevelInfo}
+	logger.Tee(ops)
+	logger.PushPrefix("disks")
+	logger.Crit("failed\nbadly")
+	logger.Debug("suppressed")
+	logger.Close()
+
+	b, err := ioutil.ReadFile(kmsgPath)
+	if err != nil {
+		t.Fatalf("failed to read kmsg: %v", err)
+	}
+	pid := os.Getpid()
+	want := fmt.Sprintf("<10>ignition[%d]: disks: failed\n<10>ignition[%d]: badly\n", pid, pid)
+	if string(b) != want {
+		t.Errorf("bad kmsg: want %q, got %q", want, b)
+	}
+	if len(primary.msgs) != 1 {
+		t.Errorf("bad primary messages: want 1, got %q", primary.msgs)
+	}
+}
@@ -0,0 +1,55 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"fmt"
+	"log/syslog"
+	"strings"
+)
+
+// Level is the least severe priority of message that a Logger emits. It can
+// be set from the command line.
+type Level syslog.Priority
+
+const (
+	LevelEmerg   = Level(syslog.LOG_EMERG)
+	LevelAlert   = Level(syslog.LOG_ALERT)
+	LevelCrit    = Level(syslog.LOG_CRIT)
+	LevelErr     = Level(syslog.LOG_ERR)
+	LevelWarning = Level(syslog.LOG_WARNING)
+	LevelNotice  = Level(syslog.LOG_NOTICE)
+	LevelInfo    = Level(syslog.LOG_INFO)
+	LevelDebug   = Level(syslog.LOG_DEBUG)
+)
+
+var levelNames = []string{"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug"}
+
+func (l Level) String() string {
+	if int(l) < len(levelNames) && l >= 0 {
+		return levelNames[l]
+	}
+	return fmt.Sprintf("Level(%d)", int(l))
+}
+
+func (l *Level) Set(val string) error {
+	for i, name := range levelNames {
+		if strings.EqualFold(val, name) {
+			*l = Level(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("%s is not a valid log level %v", val, levelNames)
+}
@@ -0,0 +1,62 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"testing"
+)
+
+func TestLevelSet(t *testing.T) {
+	type in struct {
+		val string
+	}
+	type out struct {
+		level Level
+		err   bool
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{val: "debug"},
+			out: out{level: LevelDebug},
+		},
+		{
+			in:  in{val: "WARNING"},
+			out: out{level: LevelWarning},
+		},
+		{
+			in:  in{val: "emerg"},
+			out: out{level: LevelEmerg},
+		},
+		{
+			in:  in{val: "verbose"},
+			out: out{level: LevelInfo, err: true},
+		},
+	}
+
+	for i, test := range tests {
+		level := LevelInfo
+		err := level.Set(test.in.val)
+		if (err != nil) != test.out.err {
+			t.Errorf("#%d: bad error: want %t, got %v", i, test.out.err, err)
+		}
+		if level != test.out.level {
+			t.Errorf("#%d: bad level: want %s, got %s", i, test.out.level, level)
+		}
+	}
+}
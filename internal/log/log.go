@@ -37,6 +37,8 @@ type LoggerOps interface {
 // Logger implements a variadic flavor of log/syslog.Writer
 type Logger struct {
 	ops           LoggerOps
+	level         Level
+	format        Format
 	prefixStack   []string
 	opSequenceNum int
 }
@@ -44,7 +46,7 @@ type Logger struct {
 // New creates a new logger.
 // syslog is tried first, if syslog fails Stdout is used.
 func New() Logger {
-	logger := Logger{}
+	logger := Logger{level: LevelDebug}
 	if slogger, err := syslog.New(syslog.LOG_DEBUG, "ignition"); err == nil {
 		logger.ops = slogger
 	} else {
@@ -54,6 +56,28 @@ func New() Logger {
 	return logger
 }
 
+// NewWithOps creates a new logger writing to ops.
+func NewWithOps(ops LoggerOps) Logger {
+	return Logger{ops: ops, level: LevelDebug}
+}
+
+// Tee additionally writes every message the Logger emits to ops.
+func (l *Logger) Tee(ops LoggerOps) {
+	if tee, ok := l.ops.(teeOps); ok {
+		l.ops = append(tee, ops)
+		return
+	}
+	l.ops = teeOps{l.ops, ops}
+}
+
+// Fork returns a Logger writing to the same destinations as l, with a copy of
+// its prefixes, for use by another goroutine while l remains in use.
+func (l *Logger) Fork() *Logger {
+	fork := *l
+	fork.prefixStack = append([]string(nil), l.prefixStack...)
+	return &fork
+}
+
 // Close closes the logger.
 func (l Logger) Close() {
 	l.ops.Close()
@@ -61,42 +85,42 @@ func (l Logger) Close() {
 
 // Emerg logs a message at emergency priority.
 func (l Logger) Emerg(format string, a ...interface{}) error {
-	return l.log(l.ops.Emerg, format, a...)
+	return l.log(LevelEmerg, l.ops.Emerg, format, a...)
 }
 
 // Alert logs a message at alert priority.
 func (l Logger) Alert(format string, a ...interface{}) error {
-	return l.log(l.ops.Alert, format, a...)
+	return l.log(LevelAlert, l.ops.Alert, format, a...)
 }
 
 // Crit logs a message at critical priority.
 func (l Logger) Crit(format string, a ...interface{}) error {
-	return l.log(l.ops.Crit, format, a...)
+	return l.log(LevelCrit, l.ops.Crit, format, a...)
 }
 
 // Err logs a message at error priority.
 func (l Logger) Err(format string, a ...interface{}) error {
-	return l.log(l.ops.Err, format, a...)
+	return l.log(LevelErr, l.ops.Err, format, a...)
 }
 
 // Warning logs a message at warning priority.
 func (l Logger) Warning(format string, a ...interface{}) error {
-	return l.log(l.ops.Warning, format, a...)
+	return l.log(LevelWarning, l.ops.Warning, format, a...)
 }
 
 // Notice logs a message at notice priority.
 func (l Logger) Notice(format string, a ...interface{}) error {
-	return l.log(l.ops.Notice, format, a...)
+	return l.log(LevelNotice, l.ops.Notice, format, a...)
 }
 
 // Info logs a message at info priority.
 func (l Logger) Info(format string, a ...interface{}) error {
-	return l.log(l.ops.Info, format, a...)
+	return l.log(LevelInfo, l.ops.Info, format, a...)
 }
 
 // Debug logs a message at debug priority.
 func (l Logger) Debug(format string, a ...interface{}) error {
-	return l.log(l.ops.Debug, format, a...)
+	return l.log(LevelDebug, l.ops.Debug, format, a...)
 }
 
 // PushPrefix pushes the supplied message onto the Logger's prefix stack.
@@ -178,8 +202,25 @@ func (l Logger) logFinish(format string, a ...interface{}) {
 	l.Info(fmt.Sprintf("[finished] %s", format), a...)
 }
 
-// log logs a formatted message using the supplied logFunc.
-func (l Logger) log(logFunc func(string) error, format string, a ...interface{}) error {
+// SetLevel sets the least severe level of message that is logged.
+func (l *Logger) SetLevel(level Level) {
+	l.level = level
+}
+
+// SetFormat sets how messages are rendered.
+func (l *Logger) SetFormat(format Format) {
+	l.format = format
+}
+
+// log logs a formatted message using the supplied logFunc, unless level is
+// less severe than the Logger's level.
+func (l Logger) log(level Level, logFunc func(string) error, format string, a ...interface{}) error {
+	if level > l.level {
+		return nil
+	}
+	if l.format == FormatJSON {
+		return logFunc(l.jsonSprintf(level, format, a...))
+	}
 	return logFunc(l.sprintf(format, a...))
 }
 
@@ -15,36 +15,139 @@
 package main
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"github.com/coreos/ignition/internal/distro"
 	"github.com/coreos/ignition/internal/exec"
 	"github.com/coreos/ignition/internal/exec/stages"
 	_ "github.com/coreos/ignition/internal/exec/stages/disks"
 	_ "github.com/coreos/ignition/internal/exec/stages/files"
+	_ "github.com/coreos/ignition/internal/exec/stages/kargs"
+	_ "github.com/coreos/ignition/internal/exec/stages/metadata"
+	_ "github.com/coreos/ignition/internal/exec/stages/umount"
 	"github.com/coreos/ignition/internal/log"
 	"github.com/coreos/ignition/internal/oem"
+	"github.com/coreos/ignition/internal/providers"
+	"github.com/coreos/ignition/internal/providers/cmdline"
+	"github.com/coreos/ignition/internal/resource"
 	"github.com/coreos/ignition/internal/version"
+
+	"golang.org/x/net/context"
+)
+
+// Exit codes distinguishing the classes of failure, so units can handle each
+// differently. 2 is reserved for usage errors.
+const (
+	exitStageFailed   = 1
+	exitFetchFailed   = 3
+	exitConfigInvalid = 4
 )
 
+// envOverrides maps environment variables to the flags whose defaults they
+// override. Flags given on the command line still take precedence.
+var envOverrides = map[string]string{
+	"IGNITION_CONFIG_CACHE":    "config-cache",
+	"IGNITION_LOG_FORMAT":      "log-format",
+	"IGNITION_LOG_LEVEL":       "log-level",
+	"IGNITION_NO_CONFIG_CACHE": "no-config-cache",
+	"IGNITION_OEM":             "oem",
+	"IGNITION_ONLINE_TIMEOUT":  "online-timeout",
+	"IGNITION_PROVIDERS":       "providers",
+}
+
 func main() {
 	flags := struct {
-		clearCache  bool
-		configCache string
-		oem         oem.Name
-		root        string
-		stage       stages.Name
-		version     bool
-	}{}
+		allowUnsigned   bool
+		baseConfigDir   string
+		clearCache      bool
+		clientCert      string
+		clientKey       string
+		completionFile  string
+		configCache     string
+		diagnose        bool
+		dryRun          bool
+		fromFile        string
+		force           bool
+		fromStdin       bool
+		live            bool
+		liveToken       string
+		logConsole      bool
+		logFormat       log.Format
+		logKmsg         bool
+		logLevel        log.Level
+		maxConfigSize   int64
+		maxRedirects    int
+		maxRefDepth     int
+		measurePcr      int
+		noConfigCache   bool
+		oem             oem.Name
+		onlineTimeout   time.Duration
+		providers       oem.Chain
+		render          bool
+		renderedConfig  string
+		resolvConf      string
+		resultFile      string
+		root            string
+		stage           stages.Name
+		strict          bool
+		userdataHandler string
+		version         bool
+	}{
+		logLevel: log.LevelDebug,
+	}
 
+	flag.BoolVar(&flags.allowUnsigned, "allow-unsigned-configs", false, "accept unsigned configs even when signing keys are trusted")
+	flag.StringVar(&flags.baseConfigDir, "base-config-dir", exec.DefaultBaseConfigDir, "directory whose base.d and base.platform.d configs are merged beneath the user config, and whose user.ign and user.d configs are merged beneath the provider's (empty to disable)")
 	flag.BoolVar(&flags.clearCache, "clear-cache", false, "clear any cached config")
-	flag.StringVar(&flags.configCache, "config-cache", "/run/ignition.json", "where to cache the config")
+	flag.StringVar(&flags.clientCert, "client-cert", "", fmt.Sprintf("client certificate for https fetches, as a path or oem URL (default from %s)", cmdline.TlsCertFlag))
+	flag.StringVar(&flags.clientKey, "client-key", "", fmt.Sprintf("client key for https fetches, as a path or oem URL (default from %s)", cmdline.TlsKeyFlag))
+	flag.StringVar(&flags.completionFile, "completion-file", exec.DefaultCompletionFile, "marker written after a successful run, which skips later runs (empty to disable)")
+	flag.StringVar(&flags.configCache, "config-cache", exec.DefaultConfigCache, "where to cache the config")
+	flag.BoolVar(&flags.diagnose, "diagnose", false, "probe the config providers, print what each returns, and exit")
+	flag.BoolVar(&flags.dryRun, "dry-run", false, "fetch and validate the config, print the operations the stages would perform, and exit without changing anything")
+	flag.BoolVar(&flags.force, "force", false, "run even if the completion marker shows a previous run completed")
+	flag.StringVar(&flags.fromFile, "from-file", "", "run against the config in this file, bypassing all providers")
+	flag.BoolVar(&flags.fromStdin, "from-stdin", false, "run against the config read from stdin, bypassing all providers")
+	flag.BoolVar(&flags.live, "live", false, "plan, and with -live-token apply, the files stage against the running system at -root")
+	flag.StringVar(&flags.liveToken, "live-token", "", "apply the reviewed live plan with this token")
+	flag.BoolVar(&flags.logConsole, "log-console", false, "also write log messages to the system console")
+	flag.Var(&flags.logFormat, "log-format", "how to render log messages: text, or json for one object per message")
+	flag.BoolVar(&flags.logKmsg, "log-kmsg", false, "also write log messages to the kernel log")
+	flag.Var(&flags.logLevel, "log-level", fmt.Sprintf("least severe level of message to log (overridden by %s)", cmdline.LogLevelFlag))
+	flag.Int64Var(&flags.maxConfigSize, "max-config-size", resource.DefaultMaxConfigSize, "largest config, in bytes, that is fetched")
+	flag.IntVar(&flags.maxRedirects, "max-redirects", resource.DefaultMaxRedirects, "how many redirects a fetch follows")
+	flag.IntVar(&flags.maxRefDepth, "max-reference-depth", exec.DefaultMaxReferenceDepth, "how deeply configs may replace or append other configs")
+	flag.IntVar(&flags.measurePcr, "measure-pcr", 0, fmt.Sprintf("TPM PCR to extend with the digest of the rendered config, once per boot (0 to disable; overridden by %s)", cmdline.MeasurePcrFlag))
+	flag.BoolVar(&flags.noConfigCache, "no-config-cache", false, "fetch the config in every invocation rather than caching it between stages")
 	flag.Var(&flags.oem, "oem", fmt.Sprintf("current oem. %v", oem.Names()))
+	flag.DurationVar(&flags.onlineTimeout, "online-timeout", exec.DefaultOnlineTimeout, "how long to keep retrying a fetch before giving up (0 to disable)")
+	flag.Var(&flags.providers, "providers", "comma-separated oems whose providers are tried in order instead of that of -oem, each optionally as name=timeout (e.g. openstack=30s,ec2)")
+	flag.BoolVar(&flags.render, "render", false, "print the fully rendered config and exit without running any stages")
+	flag.StringVar(&flags.renderedConfig, "rendered-config", exec.DefaultRenderedConfig, "where to write the rendered config, with secrets redacted, for debugging (empty to disable)")
+	flag.StringVar(&flags.resolvConf, "resolv-conf", exec.DefaultResolvConf, "resolver config to update with the config's provisioning DNS (empty to disable)")
+	flag.StringVar(&flags.resultFile, "result-file", exec.DefaultResultFile, "where, under -root, to record the provider, referenced configs, and outcome of each stage (empty to disable)")
 	flag.StringVar(&flags.root, "root", "/", "root of the filesystem")
-	flag.Var(&flags.stage, "stage", fmt.Sprintf("execution stage. %v", stages.Names()))
+	flag.Var(&flags.stage, "stage", fmt.Sprintf("execution stage. %v (default %q)", append(stages.Names(), stages.All, stages.Fetch, stages.FetchOffline), stages.All))
+	flag.BoolVar(&flags.strict, "strict", false, fmt.Sprintf("treat unrecognized config keys as errors rather than warnings (also enabled by %s)", cmdline.StrictFlag))
+	flag.StringVar(&flags.userdataHandler, "userdata-handler", distro.UserdataHandler(), fmt.Sprintf("executable run as '<handler> cloud-config|script %s' when the userdata isn't an Ignition config (overridden by %s)", exec.DefaultUserdataFile, cmdline.UserdataHandlerFlag))
 	flag.BoolVar(&flags.version, "version", false, "print the version and exit")
 
+	for env, name := range envOverrides {
+		if val, ok := os.LookupEnv(env); ok {
+			if err := flag.Set(name, val); err != nil {
+				fmt.Fprintf(os.Stderr, "invalid %s: %v\n", env, err)
+				os.Exit(2)
+			}
+		}
+	}
+
 	flag.Parse()
 
 	if flags.version {
@@ -52,20 +155,118 @@ func main() {
 		return
 	}
 
-	if flags.oem == "" {
-		fmt.Fprint(os.Stderr, "'--oem' must be provided\n")
+	// Modes from the config are set explicitly, but the directories created
+	// for them along the way would otherwise depend on the umask Ignition
+	// inherits.
+	syscall.Umask(0022)
+
+	if flags.fromFile != "" && flags.fromStdin {
+		fmt.Fprint(os.Stderr, "'--from-file' and '--from-stdin' are mutually exclusive\n")
 		os.Exit(2)
 	}
 
+	var localConfig []byte
+	switch {
+	case flags.fromFile != "":
+		b, err := ioutil.ReadFile(flags.fromFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "couldn't read config %q: %v\n", flags.fromFile, err)
+			os.Exit(1)
+		}
+		localConfig = b
+	case flags.fromStdin:
+		b, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "couldn't read config from stdin: %v\n", err)
+			os.Exit(1)
+		}
+		localConfig = b
+	}
+
+	if flags.diagnose {
+		os.Exit(runDiagnose(flags.oem))
+	}
+
 	if flags.stage == "" {
-		fmt.Fprint(os.Stderr, "'--stage' must be provided\n")
+		flags.stage = stages.All
+	}
+
+	if flags.liveToken != "" && !flags.live {
+		fmt.Fprint(os.Stderr, "'--live-token' requires '--live'\n")
+		os.Exit(2)
+	}
+	if flags.live && flags.stage != "files" && flags.stage != stages.All {
+		fmt.Fprint(os.Stderr, "'--live' only supports the files stage\n")
 		os.Exit(2)
 	}
 
 	logger := log.New()
+	logger.SetLevel(flags.logLevel)
+	if value, ok := cmdline.FlagValue(&logger, cmdline.LogLevelFlag); ok {
+		if err := flags.logLevel.Set(value); err != nil {
+			logger.Err("invalid %s: %v", cmdline.LogLevelFlag, err)
+		} else {
+			logger.SetLevel(flags.logLevel)
+		}
+	}
+	logger.SetFormat(flags.logFormat)
+	if flags.logKmsg {
+		if ops, err := log.OpenKmsg(); err == nil {
+			logger.Tee(ops)
+		} else {
+			logger.Err("unable to open kernel log: %v", err)
+		}
+	}
+	if flags.logConsole {
+		if ops, err := log.OpenConsole(); err == nil {
+			logger.Tee(ops)
+		} else {
+			logger.Err("unable to open console: %v", err)
+		}
+	}
 	defer logger.Close()
 
-	logger.Info(version.String)
+	logger.Info("%s", version.String)
+
+	// Kernel arguments let the platform and timeout be tuned per boot
+	// without rebuilding the initramfs.
+	if platform, ok := cmdline.Platform(&logger); ok {
+		if err := flags.oem.Set(platform); err != nil {
+			logger.Crit("invalid %s: %v", cmdline.PlatformFlag, err)
+			os.Exit(2)
+		}
+		logger.Info("using platform %q from %s", platform, cmdline.PlatformFlag)
+	}
+	if timeout, ok, err := cmdline.FetchTimeout(&logger); err != nil {
+		logger.Err("%v, using %v", err, flags.onlineTimeout)
+	} else if ok {
+		flags.onlineTimeout = timeout
+		logger.Info("using online timeout %v from %s", timeout, cmdline.FetchTimeoutFlag)
+	}
+	if pcr, ok, err := cmdline.MeasurePcr(&logger); err != nil {
+		logger.Err("%v, using %d", err, flags.measurePcr)
+	} else if ok {
+		flags.measurePcr = pcr
+	}
+	if handler, ok := cmdline.FlagValue(&logger, cmdline.UserdataHandlerFlag); ok {
+		flags.userdataHandler = handler
+	}
+	if flags.measurePcr < 0 || flags.measurePcr > exec.MaxPcr {
+		logger.Crit("PCR %d is outside 0-%d", flags.measurePcr, exec.MaxPcr)
+		os.Exit(2)
+	}
+
+	if flags.maxConfigSize <= 0 || flags.maxRedirects <= 0 || flags.maxRefDepth <= 0 {
+		logger.Crit("'--max-config-size', '--max-redirects', and '--max-reference-depth' must be positive")
+		os.Exit(2)
+	}
+
+	if flags.oem == "" && localConfig == nil {
+		logger.Crit("'--oem' or %s must be provided", cmdline.PlatformFlag)
+		os.Exit(2)
+	}
+
+	ctx := cancelOnSignal(&logger)
 
 	if flags.clearCache {
 		if err := os.Remove(flags.configCache); err != nil {
@@ -73,17 +274,204 @@ func main() {
 		}
 	}
 
-	oemConfig := oem.MustGet(flags.oem.String())
+	if flags.clientCert == "" && flags.clientKey == "" {
+		flags.clientCert, _ = cmdline.FlagValue(&logger, cmdline.TlsCertFlag)
+		flags.clientKey, _ = cmdline.FlagValue(&logger, cmdline.TlsKeyFlag)
+	}
+
 	engine := exec.Engine{
-		Root:              flags.root,
-		Logger:            &logger,
-		ConfigCache:       flags.configCache,
-		FetchFunc:         oemConfig.FetchFunc(),
-		OemBaseConfig:     oemConfig.BaseConfig(),
-		DefaultUserConfig: oemConfig.DefaultUserConfig(),
+		Root:                 flags.root,
+		Logger:               &logger,
+		ConfigCache:          flags.configCache,
+		NoConfigCache:        flags.noConfigCache,
+		ResolvConf:           flags.resolvConf,
+		CompletionFile:       flags.completionFile,
+		RenderedConfig:       flags.renderedConfig,
+		ResultFile:           flags.resultFile,
+		BaseConfigDir:        flags.baseConfigDir,
+		Force:                flags.force || cmdline.HasFlag(&logger, cmdline.ForceFlag),
+		Strict:               flags.strict || cmdline.HasFlag(&logger, cmdline.StrictFlag),
+		ClientCert:           flags.clientCert,
+		ClientKey:            flags.clientKey,
+		Proxy:                cmdline.Proxy(&logger),
+		OnlineTimeout:        flags.onlineTimeout,
+		LocalConfig:          localConfig,
+		NeedNetMarker:        exec.DefaultNeedNetMarker,
+		StatusReport:         cmdline.StatusReport(&logger),
+		NetWait:              cmdline.ParseNetWait(&logger),
+		SigningKeysDir:       exec.DefaultSigningKeysDir,
+		SigningKeys:          cmdline.SigningKeys(&logger),
+		AllowUnsignedConfigs: flags.allowUnsigned,
+		MeasurePcr:           flags.measurePcr,
+		UserdataFile:         exec.DefaultUserdataFile,
+		UserdataHandler:      flags.userdataHandler,
+		RequireImdsV2:        cmdline.HasFlag(&logger, cmdline.RequireImdsV2Flag),
+		MaxConfigSize:        flags.maxConfigSize,
+		MaxRedirects:         flags.maxRedirects,
+		MaxReferenceDepth:    flags.maxRefDepth,
+	}
+	if flags.oem != "" {
+		oemConfig := oem.MustGet(flags.oem.String())
+		engine.Platform = oemConfig.Name()
+		engine.FetchFunc = oemConfig.FetchFunc()
+		engine.OemBaseConfig = oemConfig.BaseConfig()
+		engine.DefaultUserConfig = oemConfig.DefaultUserConfig()
+		engine.ReportReady = oemConfig.ReadyFunc()
+	}
+	if len(flags.providers) != 0 {
+		var answered string
+		engine.FetchFunc = providers.Chain(flags.providers, &answered)
+		engine.ProviderName = func() string { return answered }
+	}
+
+	if flags.live {
+		// The running system's resolver is its own to manage, and the boot's
+		// rendered config its record of provisioning.
+		engine.ResolvConf = ""
+		engine.RenderedConfig = ""
+		engine.ResultFile = ""
+		os.Exit(runLive(ctx, &engine, &logger, flags.liveToken))
+	}
+
+	if flags.dryRun {
+		printOnly(&engine)
+		cfg, result := engine.Render(ctx)
+		if result == exec.ResultSuccess {
+			for _, op := range exec.Plan(cfg) {
+				fmt.Println(op)
+			}
+		}
+		os.Exit(exitCode(result))
+	}
+
+	if flags.render {
+		printOnly(&engine)
+		cfg, result := engine.Render(ctx)
+		if result == exec.ResultSuccess {
+			b, err := json.MarshalIndent(cfg, "", "  ")
+			if err != nil {
+				logger.Crit("failed to marshal rendered config: %v", err)
+				os.Exit(1)
+			}
+			fmt.Printf("%s\n", b)
+		}
+		os.Exit(exitCode(result))
+	}
+
+	os.Exit(exitCode(engine.Run(ctx, flags.stage.String())))
+}
+
+// printOnly keeps engine from changing anything while it renders a config
+// which is only printed: the config cache is neither read nor written, the
+// resolver, rendered config, result file, and need-net marker are left
+// alone, and userdata which isn't a config is neither written nor handed off.
+func printOnly(engine *exec.Engine) {
+	engine.NoConfigCache = true
+	engine.ResolvConf = ""
+	engine.RenderedConfig = ""
+	engine.ResultFile = ""
+	engine.NeedNetMarker = ""
+	engine.UserdataFile = ""
+	engine.UserdataHandler = ""
+}
+
+// cancelOnSignal returns a context cancelled by the first SIGTERM or SIGINT,
+// so that fetches in flight are aborted and stages stop before their next step
+// rather than being killed partway through. A second signal is left to the
+// default handling.
+func cancelOnSignal(logger *log.Logger) context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+	go func() {
+		sig := <-signals
+		signal.Stop(signals)
+		logger.Warning("received %v, cancelling", sig)
+		cancel()
+	}()
+	return ctx
+}
+
+// runLive prints the plan for applying the config to the running system and,
+// if token confirms the plan was reviewed, applies it. It returns the exit
+// code.
+func runLive(ctx context.Context, engine *exec.Engine, logger *log.Logger, token string) int {
+	cfg, result := engine.Render(ctx)
+	if result != exec.ResultSuccess {
+		return exitCode(result)
+	}
+	if err := exec.CheckLive(cfg); err != nil {
+		logger.Crit("refusing to apply config to the running system: %v", err)
+		return exitConfigInvalid
 	}
 
-	if !engine.Run(flags.stage.String()) {
-		os.Exit(1)
+	plan, err := engine.PlanLive(cfg)
+	if err != nil {
+		logger.Crit("failed to plan live apply: %v", err)
+		return exitFetchFailed
+	}
+	for _, change := range plan {
+		fmt.Println(change)
+	}
+	if token == "" {
+		fmt.Printf("to apply these changes to %q, rerun with -live-token=%s\n", engine.Root, exec.LiveToken(plan))
+		return 0
+	}
+
+	result, err = engine.ApplyLive(cfg, token)
+	if err != nil {
+		logger.Crit("failed to apply config to the running system: %v", err)
+	}
+	return exitCode(result)
+}
+
+// runDiagnose prints the platform and the outcome of fetching the config
+// from the cmdline provider and from the provider of oemName, or of every oem
+// if oemName is empty. It returns the exit code.
+func runDiagnose(oemName oem.Name) int {
+	logger := log.New()
+	defer logger.Close()
+
+	platform, source := string(oemName), "-oem"
+	if p, ok := cmdline.Platform(&logger); ok {
+		platform, source = p, cmdline.PlatformFlag
+		if _, ok := oem.Get(p); ok {
+			oemName = oem.Name(p)
+		}
+	}
+	if platform == "" {
+		platform, _ = cmdline.FlagValue(&logger, cmdline.OemFlag)
+		source = cmdline.OemFlag
+	}
+	if platform == "" {
+		fmt.Printf("platform: unknown (none of -oem, %s or %s given)\n", cmdline.PlatformFlag, cmdline.OemFlag)
+	} else {
+		fmt.Printf("platform: %s (from %s)\n", platform, source)
+	}
+
+	names := oem.Names()
+	if oemName != "" {
+		names = []string{string(oemName)}
+	}
+	probes := []exec.Probe{{Name: "cmdline", Fetch: cmdline.FetchConfig}}
+	for _, name := range names {
+		probes = append(probes, exec.Probe{Name: name, Fetch: oem.MustGet(name).FetchFunc()})
+	}
+	for _, result := range exec.DiagnoseProviders(&logger, exec.DefaultProbeTimeout, probes) {
+		fmt.Println(result)
+	}
+	return 0
+}
+
+// exitCode maps the result of running the engine to a process exit code.
+func exitCode(result exec.Result) int {
+	switch result {
+	case exec.ResultStageFailed:
+		return exitStageFailed
+	case exec.ResultFetchFailed:
+		return exitFetchFailed
+	case exec.ResultConfigInvalid:
+		return exitConfigInvalid
 	}
+	return 0
 }
@@ -0,0 +1,63 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oem
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/coreos/ignition/internal/providers"
+)
+
+// Chain is an ordered list of OEMs whose providers are tried in turn, given
+// as comma-separated names, each optionally followed by "=" and the duration
+// bounding the wait for that provider (e.g. "openstack=30s,ec2").
+type Chain []providers.Source
+
+func (c Chain) String() string {
+	entries := make([]string, 0, len(c))
+	for _, s := range c {
+		if s.Timeout != 0 {
+			entries = append(entries, fmt.Sprintf("%s=%s", s.Name, s.Timeout))
+		} else {
+			entries = append(entries, s.Name)
+		}
+	}
+	return strings.Join(entries, ",")
+}
+
+func (c *Chain) Set(val string) error {
+	chain := Chain{}
+	for _, entry := range strings.Split(val, ",") {
+		parts := strings.SplitN(entry, "=", 2)
+		config, ok := Get(parts[0])
+		if !ok {
+			return fmt.Errorf("%s is not a valid oem", parts[0])
+		}
+		source := providers.Source{Name: config.Name(), Fetch: config.FetchFunc()}
+		if len(parts) == 2 {
+			timeout, err := time.ParseDuration(parts[1])
+			if err != nil || timeout <= 0 {
+				return fmt.Errorf("invalid timeout for %s: %q", parts[0], parts[1])
+			}
+			source.Timeout = timeout
+		}
+		chain = append(chain, source)
+	}
+
+	*c = chain
+	return nil
+}
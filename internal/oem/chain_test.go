@@ -0,0 +1,78 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oem
+
+import (
+	"testing"
+	"time"
+)
+
+func TestChainSet(t *testing.T) {
+	type in struct {
+		val string
+	}
+	type out struct {
+		names    []string
+		timeouts []time.Duration
+		err      bool
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{val: "ec2"},
+			out: out{names: []string{"ec2"}, timeouts: []time.Duration{0}},
+		},
+		{
+			in:  in{val: "openstack=30s,ec2"},
+			out: out{names: []string{"openstack", "ec2"}, timeouts: []time.Duration{30 * time.Second, 0}},
+		},
+		{
+			in:  in{val: "bogus"},
+			out: out{err: true},
+		},
+		{
+			in:  in{val: "ec2="},
+			out: out{err: true},
+		},
+		{
+			in:  in{val: "ec2=-1s"},
+			out: out{err: true},
+		},
+	}
+
+	for i, test := range tests {
+		var chain Chain
+		err := chain.Set(test.in.val)
+		if (err != nil) != test.out.err {
+			t.Errorf("#%d: bad error: want %t, got %v", i, test.out.err, err)
+			continue
+		}
+		if len(chain) != len(test.out.names) {
+			t.Errorf("#%d: bad length: want %d, got %d", i, len(test.out.names), len(chain))
+			continue
+		}
+		for j, source := range chain {
+			if source.Name != test.out.names[j] || source.Timeout != test.out.timeouts[j] || source.Fetch == nil {
+				t.Errorf("#%d: bad source %d: want %s=%s, got %s=%s", i, j, test.out.names[j], test.out.timeouts[j], source.Name, source.Timeout)
+			}
+		}
+		if !test.out.err && chain.String() != test.in.val {
+			t.Errorf("#%d: bad string: want %q, got %q", i, test.in.val, chain.String())
+		}
+	}
+}
@@ -19,16 +19,26 @@ import (
 
 	"github.com/coreos/ignition/config/types"
 	"github.com/coreos/ignition/internal/providers"
+	"github.com/coreos/ignition/internal/providers/aliyun"
 	"github.com/coreos/ignition/internal/providers/azure"
+	"github.com/coreos/ignition/internal/providers/cloudstack"
 	"github.com/coreos/ignition/internal/providers/digitalocean"
 	"github.com/coreos/ignition/internal/providers/ec2"
 	"github.com/coreos/ignition/internal/providers/file"
 	"github.com/coreos/ignition/internal/providers/gce"
+	"github.com/coreos/ignition/internal/providers/hyperv"
+	"github.com/coreos/ignition/internal/providers/ibmcloud"
 	"github.com/coreos/ignition/internal/providers/noop"
 	"github.com/coreos/ignition/internal/providers/openstack"
 	"github.com/coreos/ignition/internal/providers/packet"
+	"github.com/coreos/ignition/internal/providers/partition"
+	"github.com/coreos/ignition/internal/providers/proxmoxve"
 	"github.com/coreos/ignition/internal/providers/qemu"
+	"github.com/coreos/ignition/internal/providers/virtualbox"
 	"github.com/coreos/ignition/internal/providers/vmware"
+	"github.com/coreos/ignition/internal/providers/vsock"
+	"github.com/coreos/ignition/internal/providers/vultr"
+	"github.com/coreos/ignition/internal/providers/zvm"
 	"github.com/coreos/ignition/internal/registry"
 
 	"github.com/vincent-petithory/dataurl"
@@ -38,6 +48,9 @@ import (
 type Config struct {
 	name              string
 	fetch             providers.FuncFetchConfig
+	metadata          providers.FuncFetchMetadata
+	deleteConfig      providers.FuncDeleteConfig
+	reportReady       providers.FuncReportReady
 	baseConfig        types.Config
 	defaultUserConfig types.Config
 }
@@ -50,6 +63,24 @@ func (c Config) FetchFunc() providers.FuncFetchConfig {
 	return c.fetch
 }
 
+// MetadataFunc returns the function fetching the platform metadata, or nil if
+// the platform has none.
+func (c Config) MetadataFunc() providers.FuncFetchMetadata {
+	return c.metadata
+}
+
+// DeleteFunc returns the function deleting the config from the platform, or
+// nil if the platform doesn't let the machine delete it.
+func (c Config) DeleteFunc() providers.FuncDeleteConfig {
+	return c.deleteConfig
+}
+
+// ReadyFunc returns the function telling the platform that the machine has
+// been provisioned, or nil if the platform doesn't need to be told.
+func (c Config) ReadyFunc() providers.FuncReportReady {
+	return c.reportReady
+}
+
 func (c Config) BaseConfig() types.Config {
 	return c.baseConfig
 }
@@ -62,8 +93,13 @@ var configs = registry.Create("oem configs")
 
 func init() {
 	configs.Register(Config{
-		name:  "azure",
-		fetch: azure.FetchConfig,
+		name:  "aliyun",
+		fetch: aliyun.FetchConfig,
+	})
+	configs.Register(Config{
+		name:        "azure",
+		fetch:       azure.FetchConfig,
+		reportReady: azure.ReportReady,
 		baseConfig: types.Config{
 			Systemd: types.Systemd{
 				Units: []types.SystemdUnit{
@@ -86,7 +122,7 @@ func init() {
 	})
 	configs.Register(Config{
 		name:  "cloudstack",
-		fetch: noop.FetchConfig,
+		fetch: cloudstack.FetchConfig,
 	})
 	configs.Register(Config{
 		name:  "digitalocean",
@@ -109,8 +145,9 @@ func init() {
 		defaultUserConfig: types.Config{Systemd: types.Systemd{Units: []types.SystemdUnit{userCloudInit("OpenStack", "ec2-compat")}}},
 	})
 	configs.Register(Config{
-		name:  "ec2",
-		fetch: ec2.FetchConfig,
+		name:     "ec2",
+		fetch:    ec2.FetchConfig,
+		metadata: ec2.FetchMetadata,
 		baseConfig: types.Config{
 			Systemd: types.Systemd{
 				Units: []types.SystemdUnit{
@@ -139,8 +176,9 @@ func init() {
 		fetch: noop.FetchConfig,
 	})
 	configs.Register(Config{
-		name:  "gce",
-		fetch: gce.FetchConfig,
+		name:     "gce",
+		fetch:    gce.FetchConfig,
+		metadata: gce.FetchMetadata,
 		baseConfig: types.Config{
 			Systemd: types.Systemd{
 				Units: []types.SystemdUnit{
@@ -177,13 +215,22 @@ alias gsutil="(docker images google/cloud-sdk || docker pull google/cloud-sdk) >
 		defaultUserConfig: types.Config{Systemd: types.Systemd{Units: []types.SystemdUnit{userCloudInit("GCE", "gce")}}},
 	})
 	configs.Register(Config{
-		name:  "hyperv",
-		fetch: noop.FetchConfig,
+		name:         "hyperv",
+		fetch:        hyperv.FetchConfig,
+		deleteConfig: hyperv.DeleteConfig,
+	})
+	configs.Register(Config{
+		name:  "ibmcloud",
+		fetch: ibmcloud.FetchConfig,
 	})
 	configs.Register(Config{
 		name:  "niftycloud",
 		fetch: noop.FetchConfig,
 	})
+	configs.Register(Config{
+		name:  "noop",
+		fetch: noop.FetchConfig,
+	})
 	configs.Register(Config{
 		name:  "packet",
 		fetch: packet.FetchConfig,
@@ -198,6 +245,18 @@ alias gsutil="(docker images google/cloud-sdk || docker pull google/cloud-sdk) >
 		},
 		defaultUserConfig: types.Config{Systemd: types.Systemd{Units: []types.SystemdUnit{userCloudInit("Packet", "packet")}}},
 	})
+	configs.Register(Config{
+		name:  "partition",
+		fetch: partition.FetchConfig,
+	})
+	configs.Register(Config{
+		name:  "powervs",
+		fetch: ibmcloud.FetchPowerVSConfig,
+	})
+	configs.Register(Config{
+		name:  "proxmoxve",
+		fetch: proxmoxve.FetchConfig,
+	})
 	configs.Register(Config{
 		name:  "pxe",
 		fetch: noop.FetchConfig,
@@ -215,18 +274,31 @@ alias gsutil="(docker images google/cloud-sdk || docker pull google/cloud-sdk) >
 		fetch: noop.FetchConfig,
 	})
 	configs.Register(Config{
-		name:  "vmware",
-		fetch: vmware.FetchConfig,
+		name:  "virtualbox",
+		fetch: virtualbox.FetchConfig,
+	})
+	configs.Register(Config{
+		name:         "vmware",
+		fetch:        vmware.FetchConfig,
+		deleteConfig: vmware.DeleteConfig,
 		baseConfig: types.Config{
 			Systemd: types.Systemd{Units: []types.SystemdUnit{{Enable: true, Name: "vmtoolsd.service"}}},
 			Storage: types.Storage{Files: []types.File{serviceFromOem("vmtoolsd.service")}},
 		},
 		defaultUserConfig: types.Config{Systemd: types.Systemd{Units: []types.SystemdUnit{userCloudInit("VMware", "vmware")}}},
 	})
+	configs.Register(Config{
+		name:  "vultr",
+		fetch: vultr.FetchConfig,
+	})
 	configs.Register(Config{
 		name:  "xendom0",
 		fetch: noop.FetchConfig,
 	})
+	configs.Register(Config{
+		name:  "zvm",
+		fetch: zvm.FetchConfig,
+	})
 	configs.Register(Config{
 		name:  "interoute",
 		fetch: noop.FetchConfig,
@@ -235,6 +307,10 @@ alias gsutil="(docker images google/cloud-sdk || docker pull google/cloud-sdk) >
 		name:  "qemu",
 		fetch: qemu.FetchConfig,
 	})
+	configs.Register(Config{
+		name:  "vsock",
+		fetch: vsock.FetchConfig,
+	})
 	configs.Register(Config{
 		name:  "file",
 		fetch: file.FetchConfig,
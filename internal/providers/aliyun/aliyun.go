@@ -0,0 +1,89 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// The aliyun provider fetches a remote configuration from the user-data of
+// the Alibaba Cloud ECS metadata service.
+
+package aliyun
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/coreos/ignition/config/types"
+	"github.com/coreos/ignition/config/validate/report"
+	"github.com/coreos/ignition/internal/log"
+	"github.com/coreos/ignition/internal/providers/util"
+	"github.com/coreos/ignition/internal/resource"
+
+	"golang.org/x/net/context"
+)
+
+const (
+	tokenHeader    = "X-aliyun-ecs-metadata-token"
+	tokenTtlHeader = "X-aliyun-ecs-metadata-token-ttl-seconds"
+	tokenTtl       = "300"
+)
+
+var (
+	tokenUrl = url.URL{
+		Scheme: "http",
+		Host:   "100.100.100.200",
+		Path:   "latest/api/token",
+	}
+	userdataUrl = url.URL{
+		Scheme: "http",
+		Host:   "100.100.100.200",
+		Path:   "latest/user-data",
+	}
+)
+
+// FetchConfig fetches the user-data, which is empty if none was provided.
+// Instances in the hardened metadata mode only answer requests carrying a
+// token, so one is requested first; should that fail, the user-data is
+// requested without one, as the normal mode allows.
+func FetchConfig(logger *log.Logger, client *resource.HttpClient, ctx context.Context) (types.Config, report.Report, error) {
+	header := http.Header{}
+	if token, err := fetchToken(client, ctx); err == nil {
+		header.Set(tokenHeader, token)
+	} else {
+		logger.Info("fetching user-data without a metadata token: %v", err)
+	}
+
+	data, err := resource.FetchConfigWithHeader(logger, client, ctx, userdataUrl, header)
+	if err != nil {
+		return types.Config{}, report.Report{}, err
+	}
+
+	return util.ParseConfig(logger, data)
+}
+
+func fetchToken(client *resource.HttpClient, ctx context.Context) (string, error) {
+	resp, err := client.PutWithHeader(ctx, tokenUrl.String(), nil, http.Header{tokenTtlHeader: {tokenTtl}})
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to fetch metadata token: %s", http.StatusText(resp.StatusCode))
+	}
+	token, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(token)), nil
+}
@@ -0,0 +1,99 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aliyun
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/coreos/ignition/internal/log"
+	"github.com/coreos/ignition/internal/resource"
+
+	"golang.org/x/net/context"
+)
+
+func TestFetchConfig(t *testing.T) {
+	type in struct {
+		hardened bool
+		tokens   bool
+		userdata string
+	}
+	type out struct {
+		files int
+		err   bool
+	}
+
+	const cfg = `{"ignition":{"version":"2.1.0-experimental"},"storage":{"files":[{"filesystem":"root","path":"/a"}]}}`
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{hardened: true, tokens: true, userdata: cfg},
+			out: out{files: 1},
+		},
+		{
+			in:  in{tokens: false, userdata: cfg},
+			out: out{files: 1},
+		},
+		{
+			in:  in{hardened: true, tokens: false, userdata: cfg},
+			out: out{err: true},
+		},
+	}
+
+	defaultTokenUrl, defaultUserdataUrl := tokenUrl, userdataUrl
+	defer func() { tokenUrl, userdataUrl = defaultTokenUrl, defaultUserdataUrl }()
+
+	logger := log.New()
+	for i, test := range tests {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/latest/api/token":
+				if !test.in.tokens || r.Method != "PUT" || r.Header.Get(tokenTtlHeader) == "" {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+				w.Write([]byte("secret\n"))
+			case "/latest/user-data":
+				if test.in.hardened && r.Header.Get(tokenHeader) != "secret" {
+					w.WriteHeader(http.StatusForbidden)
+					return
+				}
+				w.Write([]byte(test.in.userdata))
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+		serverUrl, err := url.Parse(server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tokenUrl.Host, userdataUrl.Host = serverUrl.Host, serverUrl.Host
+
+		client := resource.NewHttpClient(&logger)
+		cfg, _, err := FetchConfig(&logger, &client, context.Background())
+		server.Close()
+		if (err != nil) != test.out.err {
+			t.Errorf("#%d: bad error: want %t, got %v", i, test.out.err, err)
+		}
+		if len(cfg.Storage.Files) != test.out.files {
+			t.Errorf("#%d: bad files: want %d, got %d", i, test.out.files, len(cfg.Storage.Files))
+		}
+	}
+}
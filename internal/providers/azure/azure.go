@@ -12,7 +12,8 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-// The azure provider fetches a configuration from the Azure OVF DVD.
+// The azure provider fetches a configuration from the Azure OVF DVD and,
+// once provisioning succeeds, reports readiness to the Azure wire server.
 
 package azure
 
@@ -29,6 +30,8 @@ import (
 	"github.com/coreos/ignition/internal/log"
 	"github.com/coreos/ignition/internal/providers/util"
 	"github.com/coreos/ignition/internal/resource"
+
+	"golang.org/x/net/context"
 )
 
 const (
@@ -36,6 +39,11 @@ const (
 	configPath   = "/CustomData.bin"
 )
 
+// cdromFstypes are the filesystems tried, in order, when mounting the
+// provisioning media. Depending on the age of the image, Azure presents it as
+// either udf or iso9660.
+var cdromFstypes = []string{"udf", "iso9660"}
+
 // These constants come from <cdrom.h>.
 const (
 	CDROM_DRIVE_STATUS = 0x5326
@@ -50,9 +58,11 @@ const (
 	CDS_DISC_OK
 )
 
-func FetchConfig(logger *log.Logger, _ *resource.HttpClient) (types.Config, report.Report, error) {
+func FetchConfig(logger *log.Logger, _ *resource.HttpClient, ctx context.Context) (types.Config, report.Report, error) {
 	logger.Debug("waiting for config DVD...")
-	waitForCdrom(logger)
+	if err := waitForCdrom(logger, ctx); err != nil {
+		return types.Config{}, report.Report{}, err
+	}
 
 	mnt, err := ioutil.TempDir("", "ignition-azure")
 	if err != nil {
@@ -61,11 +71,8 @@ func FetchConfig(logger *log.Logger, _ *resource.HttpClient) (types.Config, repo
 	defer os.Remove(mnt)
 
 	logger.Debug("mounting config device")
-	if err := logger.LogOp(
-		func() error { return syscall.Mount(configDevice, mnt, "udf", syscall.MS_RDONLY, "") },
-		"mounting %q at %q", configDevice, mnt,
-	); err != nil {
-		return types.Config{}, report.Report{}, fmt.Errorf("failed to mount device %q at %q: %v", configDevice, mnt, err)
+	if err := util.MountReadOnly(logger, configDevice, mnt, cdromFstypes); err != nil {
+		return types.Config{}, report.Report{}, err
 	}
 	defer logger.LogOp(
 		func() error { return syscall.Unmount(mnt, 0) },
@@ -73,18 +80,37 @@ func FetchConfig(logger *log.Logger, _ *resource.HttpClient) (types.Config, repo
 	)
 
 	logger.Debug("reading config")
-	rawConfig, err := ioutil.ReadFile(filepath.Join(mnt, configPath))
-	if err != nil && !os.IsNotExist(err) {
-		return types.Config{}, report.Report{}, fmt.Errorf("failed to read config: %v", err)
+	rawConfig, err := readCustomData(mnt)
+	if err != nil {
+		return types.Config{}, report.Report{}, err
 	}
 
 	return util.ParseConfig(logger, rawConfig)
 }
 
-func waitForCdrom(logger *log.Logger) {
-	for !isCdromPresent(logger) {
-		time.Sleep(time.Second)
+// readCustomData returns the custom data from the provisioning media mounted
+// at mnt, preferring CustomData.bin and falling back to ovf-env.xml.
+func readCustomData(mnt string) ([]byte, error) {
+	rawConfig, err := ioutil.ReadFile(filepath.Join(mnt, configPath))
+	if err == nil {
+		return rawConfig, nil
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read config: %v", err)
+	}
+
+	env, err := ioutil.ReadFile(filepath.Join(mnt, ovfEnvPath))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read ovf-env.xml: %v", err)
 	}
+	return parseOvfCustomData(env)
+}
+
+func waitForCdrom(logger *log.Logger, ctx context.Context) error {
+	return util.Poll(ctx, time.Second, func() (bool, error) {
+		return isCdromPresent(logger), nil
+	})
 }
 
 func isCdromPresent(logger *log.Logger) bool {
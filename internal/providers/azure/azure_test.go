@@ -0,0 +1,142 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package azure
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/coreos/ignition/internal/log"
+	"github.com/coreos/ignition/internal/resource"
+
+	"golang.org/x/net/context"
+)
+
+func TestParseOvfCustomData(t *testing.T) {
+	type in struct {
+		env string
+	}
+	type out struct {
+		data []byte
+		err  bool
+	}
+
+	const envFormat = `<?xml version="1.0" encoding="utf-8"?>
+<Environment xmlns="http://schemas.dmtf.org/ovf/environment/1" xmlns:wa="http://schemas.microsoft.com/windowsazure">
+  <wa:ProvisioningSection>
+    <LinuxProvisioningConfigurationSet xmlns="http://schemas.microsoft.com/windowsazure">
+      <HostName>core</HostName>
+      %s
+    </LinuxProvisioningConfigurationSet>
+  </wa:ProvisioningSection>
+</Environment>`
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{env: fmt.Sprintf(envFormat, "<CustomData>eyJpZ25pdGlvbiI6e319</CustomData>")},
+			out: out{data: []byte(`{"ignition":{}}`)},
+		},
+		{
+			in:  in{env: fmt.Sprintf(envFormat, "")},
+			out: out{data: []byte{}},
+		},
+		{
+			in:  in{env: fmt.Sprintf(envFormat, "<CustomData>not base64</CustomData>")},
+			out: out{err: true},
+		},
+		{
+			in:  in{env: "<Environment>"},
+			out: out{err: true},
+		},
+	}
+
+	for i, test := range tests {
+		data, err := parseOvfCustomData([]byte(test.in.env))
+		if (err != nil) != test.out.err {
+			t.Errorf("#%d: bad error: want %t, got %v", i, test.out.err, err)
+		}
+		if !test.out.err && !reflect.DeepEqual(test.out.data, data) {
+			t.Errorf("#%d: bad data: want %q, got %q", i, test.out.data, data)
+		}
+	}
+}
+
+func TestReportReady(t *testing.T) {
+	type health struct {
+		Incarnation string `xml:"GoalStateIncarnation"`
+		ContainerId string `xml:"Container>ContainerId"`
+		InstanceId  string `xml:"Container>RoleInstanceList>Role>InstanceId"`
+		State       string `xml:"Container>RoleInstanceList>Role>Health>State"`
+	}
+
+	var reported health
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("x-ms-version") != wireServerVersion {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		switch r.URL.Query().Get("comp") {
+		case "goalstate":
+			w.Write([]byte(`<?xml version="1.0" encoding="utf-8"?>
+<GoalState>
+  <Incarnation>3</Incarnation>
+  <Container>
+    <ContainerId>c0ffee</ContainerId>
+    <RoleInstanceList>
+      <RoleInstance>
+        <InstanceId>instance-1</InstanceId>
+      </RoleInstance>
+    </RoleInstanceList>
+  </Container>
+</GoalState>`))
+		case "health":
+			body, _ := ioutil.ReadAll(r.Body)
+			if r.Method != "POST" || xml.Unmarshal(body, &reported) != nil {
+				w.WriteHeader(http.StatusBadRequest)
+			}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	serverUrl, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defaultUrl := wireServerUrl
+	wireServerUrl.Host = serverUrl.Host
+	defer func() { wireServerUrl = defaultUrl }()
+
+	logger := log.New()
+	client := resource.NewHttpClient(&logger)
+	if err := ReportReady(&logger, &client, context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := health{Incarnation: "3", ContainerId: "c0ffee", InstanceId: "instance-1", State: "Ready"}
+	if reported != want {
+		t.Errorf("bad health report: want %+v, got %+v", want, reported)
+	}
+}
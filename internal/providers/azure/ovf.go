@@ -0,0 +1,44 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package azure
+
+import (
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"strings"
+)
+
+const ovfEnvPath = "/ovf-env.xml"
+
+// ovfEnvironment is the part of ovf-env.xml, on the provisioning media,
+// holding the instance's custom data.
+type ovfEnvironment struct {
+	CustomData string `xml:"ProvisioningSection>LinuxProvisioningConfigurationSet>CustomData"`
+}
+
+// parseOvfCustomData returns the decoded custom data of the ovf-env.xml
+// document env, which is empty if none was provided.
+func parseOvfCustomData(env []byte) ([]byte, error) {
+	var ovf ovfEnvironment
+	if err := xml.Unmarshal(env, &ovf); err != nil {
+		return nil, fmt.Errorf("failed to parse ovf-env.xml: %v", err)
+	}
+	data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(ovf.CustomData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode custom data: %v", err)
+	}
+	return data, nil
+}
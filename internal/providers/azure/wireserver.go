@@ -0,0 +1,101 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package azure
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"text/template"
+
+	"github.com/coreos/ignition/internal/log"
+	"github.com/coreos/ignition/internal/resource"
+
+	"golang.org/x/net/context"
+)
+
+const wireServerVersion = "2012-11-30"
+
+var (
+	wireServerUrl = url.URL{Scheme: "http", Host: "168.63.129.16", Path: "/machine/"}
+
+	healthTemplate = template.Must(template.New("health").Parse(`<?xml version="1.0" encoding="utf-8"?>
+<Health xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xmlns:xsd="http://www.w3.org/2001/XMLSchema">
+  <GoalStateIncarnation>{{.Incarnation}}</GoalStateIncarnation>
+  <Container>
+    <ContainerId>{{.Container.Id}}</ContainerId>
+    <RoleInstanceList>
+      <Role>
+        <InstanceId>{{.Container.InstanceId}}</InstanceId>
+        <Health>
+          <State>Ready</State>
+        </Health>
+      </Role>
+    </RoleInstanceList>
+  </Container>
+</Health>
+`))
+)
+
+// goalState identifies the instance to the wire server.
+type goalState struct {
+	Incarnation string `xml:"Incarnation"`
+	Container   struct {
+		Id         string `xml:"ContainerId"`
+		InstanceId string `xml:"RoleInstanceList>RoleInstance>InstanceId"`
+	} `xml:"Container"`
+}
+
+func wireServerHeader() http.Header {
+	return http.Header{
+		"X-Ms-Version":    {wireServerVersion},
+		"X-Ms-Agent-Name": {"ignition"},
+	}
+}
+
+// ReportReady tells the Azure wire server that the instance has been
+// provisioned, which Azure otherwise waits for before considering the
+// deployment complete.
+func ReportReady(logger *log.Logger, client *resource.HttpClient, ctx context.Context) error {
+	stateUrl := wireServerUrl
+	stateUrl.RawQuery = "comp=goalstate"
+	data, err := resource.FetchWithHeader(logger, client, ctx, stateUrl, wireServerHeader())
+	if err != nil {
+		return fmt.Errorf("failed to fetch goal state: %v", err)
+	}
+	var state goalState
+	if err := xml.Unmarshal(data, &state); err != nil {
+		return fmt.Errorf("failed to parse goal state: %v", err)
+	}
+
+	body := &bytes.Buffer{}
+	if err := healthTemplate.Execute(body, state); err != nil {
+		return err
+	}
+	healthUrl := wireServerUrl
+	healthUrl.RawQuery = "comp=health"
+	header := wireServerHeader()
+	header.Set("Content-Type", "text/xml; charset=utf-8")
+	status, err := client.PostWithHeader(ctx, healthUrl.String(), body.Bytes(), header)
+	if err != nil {
+		return err
+	}
+	if status != http.StatusOK {
+		return fmt.Errorf("wire server rejected health report: %s", http.StatusText(status))
+	}
+	return nil
+}
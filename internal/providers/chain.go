@@ -0,0 +1,124 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package providers
+
+import (
+	"time"
+
+	"github.com/coreos/ignition/config"
+	"github.com/coreos/ignition/config/types"
+	"github.com/coreos/ignition/config/validate/report"
+	"github.com/coreos/ignition/internal/log"
+	"github.com/coreos/ignition/internal/resource"
+
+	"golang.org/x/net/context"
+)
+
+// Source is a provider tried as part of a chain.
+type Source struct {
+	Name  string
+	Fetch FuncFetchConfig
+	// Timeout bounds the wait for the provider; zero waits for as long as
+	// the client retries.
+	Timeout time.Duration
+}
+
+// Chain returns a provider which tries each of sources in order, falling back
+// to the next when one is not online, provides no config, fails to fetch, or
+// doesn't respond within its timeout. A provider which times out is
+// cancelled and waited for before the next is tried, so that none outlives
+// the chain. The chain ends at the first provider which returns a config,
+// non-Ignition userdata, or an invalid config; if none does, the outcome of
+// the last provider to respond is returned. The chain stops, returning ctx's
+// error, once ctx is done. answered, if not nil,
+// is set to the name of the provider whose outcome is returned.
+func Chain(sources []Source, answered *string) FuncFetchConfig {
+	return func(logger *log.Logger, client *resource.HttpClient, ctx context.Context) (types.Config, report.Report, error) {
+		cfg, r, err := types.Config{}, report.Report{}, ErrNoProvider
+		for _, s := range sources {
+			logger.Info("trying the %s provider", s.Name)
+			c, rep, e, timedOut := fetchSource(logger, client, ctx, s)
+			if ctx.Err() != nil {
+				return types.Config{}, report.Report{}, ctx.Err()
+			}
+			if timedOut {
+				logger.Warning("%s provider did not respond within %s", s.Name, s.Timeout)
+				continue
+			}
+			cfg, r, err = c, rep, e
+			if answered != nil {
+				*answered = s.Name
+			}
+			if !shouldFallBack(err, r) {
+				return cfg, r, err
+			}
+			logger.Info("falling back from the %s provider: %v", s.Name, err)
+		}
+		return cfg, r, err
+	}
+}
+
+func fetchSource(logger *log.Logger, client *resource.HttpClient, ctx context.Context, s Source) (types.Config, report.Report, error, bool) {
+	if s.Timeout == 0 {
+		cfg, r, err := s.Fetch(logger, client, ctx)
+		return cfg, r, err, false
+	}
+
+	parent := ctx
+	ctx, cancel := context.WithTimeout(parent, s.Timeout)
+	defer cancel()
+
+	type result struct {
+		cfg types.Config
+		r   report.Report
+		err error
+	}
+	sourceClient := *client
+	sourceClient.SetTimeout(s.Timeout)
+	done := make(chan result, 1)
+	go func() {
+		cfg, r, err := s.Fetch(logger, &sourceClient, ctx)
+		done <- result{cfg, r, err}
+	}()
+
+	select {
+	case res := <-done:
+		return res.cfg, res.r, res.err, false
+	case <-ctx.Done():
+	}
+	cancel()
+	<-done
+	// The source's deadline may expire along with the parent's; only the
+	// former makes the source time out.
+	if err := parent.Err(); err != nil {
+		return types.Config{}, report.Report{}, err, false
+	}
+	return types.Config{}, report.Report{}, nil, true
+}
+
+// shouldFallBack reports whether the outcome of a provider leaves the config
+// to the next one.
+func shouldFallBack(err error, r report.Report) bool {
+	if u, ok := err.(UserdataError); ok {
+		err = u.Err
+	}
+	switch {
+	case err == nil, r.IsFatal():
+		return false
+	case err == config.ErrCloudConfig, err == config.ErrScript:
+		return false
+	}
+	return true
+}
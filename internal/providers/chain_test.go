@@ -0,0 +1,133 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package providers
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/coreos/ignition/config"
+	"github.com/coreos/ignition/config/types"
+	"github.com/coreos/ignition/config/validate/report"
+	"github.com/coreos/ignition/internal/log"
+	"github.com/coreos/ignition/internal/resource"
+
+	"golang.org/x/net/context"
+)
+
+func TestChain(t *testing.T) {
+	type in struct {
+		sources []Source
+		// timeout bounds the whole chain, if not zero.
+		timeout time.Duration
+	}
+	type out struct {
+		files    int
+		err      error
+		answered string
+	}
+
+	// running counts the fetches which haven't returned.
+	running := 0
+	var mu sync.Mutex
+	respond := func(delay time.Duration, files int, err error) FuncFetchConfig {
+		return func(_ *log.Logger, _ *resource.HttpClient, ctx context.Context) (types.Config, report.Report, error) {
+			mu.Lock()
+			running++
+			mu.Unlock()
+			defer func() {
+				mu.Lock()
+				running--
+				mu.Unlock()
+			}()
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+				return types.Config{}, report.Report{}, ctx.Err()
+			}
+			return types.Config{Storage: types.Storage{Files: make([]types.File, files)}}, report.Report{}, err
+		}
+	}
+	invalid := func(*log.Logger, *resource.HttpClient, context.Context) (types.Config, report.Report, error) {
+		return types.Config{}, report.ReportFromError(config.ErrInvalid, report.EntryError), config.ErrInvalid
+	}
+	errFetch := errors.New("connection refused")
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{},
+			out: out{err: ErrNoProvider},
+		},
+		{
+			in:  in{sources: []Source{{Name: "a", Fetch: respond(0, 1, nil)}, {Name: "b", Fetch: respond(0, 2, nil)}}},
+			out: out{files: 1, answered: "a"},
+		},
+		{
+			in:  in{sources: []Source{{Name: "a", Fetch: respond(0, 0, ErrNoProvider)}, {Name: "b", Fetch: respond(0, 0, errFetch)}, {Name: "c", Fetch: respond(0, 2, nil)}}},
+			out: out{files: 2, answered: "c"},
+		},
+		{
+			in:  in{sources: []Source{{Name: "a", Fetch: respond(time.Second, 1, nil), Timeout: 10 * time.Millisecond}, {Name: "b", Fetch: respond(0, 2, nil)}}},
+			out: out{files: 2, answered: "b"},
+		},
+		{
+			in:  in{sources: []Source{{Name: "a", Fetch: respond(0, 0, config.ErrEmpty)}, {Name: "b", Fetch: respond(time.Second, 1, nil), Timeout: 10 * time.Millisecond}}},
+			out: out{err: config.ErrEmpty, answered: "a"},
+		},
+		{
+			in:  in{sources: []Source{{Name: "a", Fetch: respond(0, 0, config.ErrCloudConfig)}, {Name: "b", Fetch: respond(0, 2, nil)}}},
+			out: out{err: config.ErrCloudConfig, answered: "a"},
+		},
+		{
+			in:  in{sources: []Source{{Name: "a", Fetch: invalid}, {Name: "b", Fetch: respond(0, 2, nil)}}},
+			out: out{err: config.ErrInvalid, answered: "a"},
+		},
+		{
+			in:  in{sources: []Source{{Name: "a", Fetch: respond(time.Second, 1, nil), Timeout: 30 * time.Millisecond}, {Name: "b", Fetch: respond(0, 2, nil)}}, timeout: 10 * time.Millisecond},
+			out: out{err: context.DeadlineExceeded},
+		},
+	}
+
+	logger := log.New()
+	client := resource.NewHttpClient(&logger)
+	for i, test := range tests {
+		answered := ""
+		ctx, cancel := context.Background(), context.CancelFunc(func() {})
+		if test.in.timeout != 0 {
+			ctx, cancel = context.WithTimeout(ctx, test.in.timeout)
+		}
+		cfg, _, err := Chain(test.in.sources, &answered)(&logger, &client, ctx)
+		cancel()
+		mu.Lock()
+		if running != 0 {
+			t.Errorf("#%d: %d fetches outlived the chain", i, running)
+		}
+		mu.Unlock()
+		if err != test.out.err {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.out.err, err)
+		}
+		if len(cfg.Storage.Files) != test.out.files {
+			t.Errorf("#%d: bad files: want %d, got %d", i, test.out.files, len(cfg.Storage.Files))
+		}
+		if answered != test.out.answered {
+			t.Errorf("#%d: bad provider: want %q, got %q", i, test.out.answered, answered)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// The cloudstack provider fetches a configuration from the userdata on the
+// config drive, if the instance has one, and otherwise from the virtual
+// router, which is the DHCP server of the instance's network.
+
+package cloudstack
+
+import (
+	"bufio"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/coreos/ignition/config/types"
+	"github.com/coreos/ignition/config/validate/report"
+	"github.com/coreos/ignition/internal/log"
+	"github.com/coreos/ignition/internal/providers/util"
+	"github.com/coreos/ignition/internal/resource"
+
+	"golang.org/x/net/context"
+)
+
+const (
+	configDrivePath         = "/dev/disk/by-label/config-2"
+	configDriveUserdataPath = "/cloudstack/userdata/user_data.txt"
+
+	// configDriveTimeout bounds the wait for the config drive, which
+	// instances on networks with a virtual router don't have.
+	configDriveTimeout = 10 * time.Second
+	// routerTimeout bounds the wait for a DHCP lease naming the router.
+	routerTimeout = time.Minute
+)
+
+var (
+	ErrNoVirtualRouter = errors.New("no DHCP lease names a virtual router")
+)
+
+// leaseDirs are where systemd-networkd and dhclient keep their leases.
+var leaseDirs = []string{"/run/systemd/netif/leases", "/var/lib/dhclient", "/var/lib/dhcp"}
+
+func FetchConfig(logger *log.Logger, client *resource.HttpClient, ctx context.Context) (types.Config, report.Report, error) {
+	driveCtx, cancel := context.WithTimeout(ctx, configDriveTimeout)
+	data, err := util.ReadConfigDrive(logger, driveCtx, configDrivePath, configDriveUserdataPath)
+	cancel()
+	if err == nil {
+		logger.Info("using config from the config drive")
+		return util.ParseConfig(logger, data)
+	}
+	logger.Info("config drive unavailable (%v); trying the virtual router", err)
+
+	router, err := waitForVirtualRouter(logger, ctx)
+	if err != nil {
+		return types.Config{}, report.Report{}, err
+	}
+	userdataUrl := url.URL{
+		Scheme: "http",
+		Host:   router,
+		Path:   "latest/user-data",
+	}
+	data, err = resource.FetchConfig(logger, client, ctx, userdataUrl)
+	if err != nil {
+		return types.Config{}, report.Report{}, err
+	}
+
+	return util.ParseConfig(logger, data)
+}
+
+// waitForVirtualRouter returns the address of the virtual router once a DHCP
+// lease names it.
+func waitForVirtualRouter(logger *log.Logger, ctx context.Context) (string, error) {
+	routerCtx, cancel := context.WithTimeout(ctx, routerTimeout)
+	defer cancel()
+
+	var router string
+	err := util.Poll(routerCtx, time.Second, func() (bool, error) {
+		var ok bool
+		if router, ok = findVirtualRouter(leaseDirs); ok {
+			return true, nil
+		}
+		logger.Debug("no DHCP lease found. Waiting...")
+		return false, nil
+	})
+	// Only the router's own timeout means there's no virtual router.
+	if ctx.Err() != nil {
+		return "", ctx.Err()
+	}
+	if err == context.DeadlineExceeded {
+		return "", ErrNoVirtualRouter
+	} else if err != nil {
+		return "", err
+	}
+	logger.Info("found virtual router at %s", router)
+	return router, nil
+}
+
+// findVirtualRouter returns the DHCP server named by the first lease, in the
+// given directories, that names one.
+func findVirtualRouter(dirs []string) (string, bool) {
+	for _, dir := range dirs {
+		entries, err := ioutil.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			if entry.IsDir() {
+				continue
+			}
+			if router, ok := parseLease(filepath.Join(dir, entry.Name())); ok {
+				return router, true
+			}
+		}
+	}
+	return "", false
+}
+
+// parseLease returns the DHCP server named by a lease file, which is either a
+// systemd-networkd lease (SERVER_ADDRESS=...) or a dhclient lease (option
+// dhcp-server-identifier ...;).
+func parseLease(path string) (string, bool) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", false
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		var value string
+		if strings.HasPrefix(line, "SERVER_ADDRESS=") {
+			value = strings.TrimPrefix(line, "SERVER_ADDRESS=")
+		} else if strings.HasPrefix(line, "option dhcp-server-identifier ") {
+			value = strings.TrimSuffix(strings.TrimPrefix(line, "option dhcp-server-identifier "), ";")
+		} else {
+			continue
+		}
+		if ip := net.ParseIP(strings.TrimSpace(value)); ip != nil {
+			return ip.String(), true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,75 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudstack
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFindVirtualRouter(t *testing.T) {
+	type in struct {
+		leases map[string]string
+	}
+	type out struct {
+		router string
+		found  bool
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{},
+			out: out{},
+		},
+		{
+			in:  in{leases: map[string]string{"2": "# This is private data. Do not parse.\nADDRESS=10.1.1.20\nSERVER_ADDRESS=10.1.1.1\n"}},
+			out: out{router: "10.1.1.1", found: true},
+		},
+		{
+			in:  in{leases: map[string]string{"eth0.lease": "lease {\n  interface \"eth0\";\n  option dhcp-server-identifier 10.1.1.254;\n}\n"}},
+			out: out{router: "10.1.1.254", found: true},
+		},
+		{
+			in:  in{leases: map[string]string{"2": "ADDRESS=10.1.1.20\n", "3": "SERVER_ADDRESS=bogus\n"}},
+			out: out{},
+		},
+	}
+
+	for i, test := range tests {
+		dir, err := ioutil.TempDir("", "ignition-cloudstack")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for name, contents := range test.in.leases {
+			if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(contents), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		router, found := findVirtualRouter([]string{filepath.Join(dir, "missing"), dir})
+		os.RemoveAll(dir)
+		if found != test.out.found {
+			t.Errorf("#%d: bad found: want %t, got %t", i, test.out.found, found)
+		}
+		if router != test.out.router {
+			t.Errorf("#%d: bad router: want %q, got %q", i, test.out.router, router)
+		}
+	}
+}
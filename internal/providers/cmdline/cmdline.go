@@ -13,14 +13,22 @@
 // limitations under the License.
 
 // The cmdline provider fetches a remote configuration from the URL specified
-// in the kernel boot option "coreos.config.url".
+// in the kernel boot option "coreos.config.url", or its equivalent
+// "ignition.config.url". A comma-separated list of
+// URLs may be given, in which case each is tried in order until one succeeds.
+// If "coreos.config.verification" gives a hash, in the form used by the
+// config's verification sections, the fetched config must match it.
 
 package cmdline
 
 import (
+	"errors"
+	"fmt"
 	"io/ioutil"
 	"net/url"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/coreos/ignition/config/types"
 	"github.com/coreos/ignition/config/validate/report"
@@ -33,21 +41,123 @@ import (
 )
 
 const (
-	cmdlinePath    = "/proc/cmdline"
-	cmdlineUrlFlag = "coreos.config.url"
+	cmdlinePath     = "/proc/cmdline"
+	cmdlineUrlFlag  = "coreos.config.url"
+	ignitionUrlFlag = "ignition.config.url"
+	cmdlineHashFlag = "coreos.config.verification"
+
+	// ForceFlag is the kernel boot option forcing Ignition to run even if a
+	// previous run completed.
+	ForceFlag = "coreos.ignition.force"
+
+	// OemFlag is the kernel boot option naming the platform.
+	OemFlag = "coreos.oem.id"
+
+	// PlatformFlag names the platform whose provider fetches the config,
+	// overriding -oem for the boot.
+	PlatformFlag = "ignition.platform.id"
+
+	// LogLevelFlag overrides -log-level for the boot, e.g.
+	// "ignition.log-level=warning".
+	LogLevelFlag = "ignition.log-level"
+
+	// StrictFlag makes configs with unrecognized keys invalid, as -strict
+	// does.
+	StrictFlag = "ignition.config.strict"
+
+	// FetchTimeoutFlag overrides -online-timeout for the boot, either as a
+	// duration such as "2m" or as a number of seconds.
+	FetchTimeoutFlag = "ignition.fetch-timeout"
+
+	// TlsCertFlag and TlsKeyFlag locate the client certificate and key
+	// presented to https servers, either as absolute paths or as oem URLs.
+	TlsCertFlag = "coreos.config.tls.cert"
+	TlsKeyFlag  = "coreos.config.tls.key"
+
+	// The proxy flags configure the proxies used to fetch configs when the
+	// config itself declares none. NoProxyFlag is comma-separated.
+	HttpProxyFlag  = "coreos.config.proxy.http"
+	HttpsProxyFlag = "coreos.config.proxy.https"
+	NoProxyFlag    = "coreos.config.proxy.noproxy"
+
+	// PartitionFlag names the label of the partition read by the partition
+	// provider.
+	PartitionFlag = "coreos.config.partition"
+
+	// The status report flags name the endpoint notified of the outcome of
+	// each stage, and the key signing the reports, when the config itself
+	// names none. They also cover failures to fetch the config.
+	StatusReportUrlFlag = "ignition.report.url"
+	StatusReportKeyFlag = "ignition.report.signing-key"
+
+	// The net wait flags delay fetching the config until the network is
+	// ready: until the comma-separated interfaces are up with an address,
+	// until there is a default route, and until the comma-separated hosts
+	// resolve. A bare NetWaitDnsFlag names the host of the config URL.
+	NetWaitInterfaceFlag = "ignition.net.wait-interface"
+	NetWaitRouteFlag     = "ignition.net.wait-route"
+	NetWaitDnsFlag       = "ignition.net.wait-dns"
+
+	// SigningKeysFlag lists, comma-separated, the OpenPGP public keys
+	// trusted to sign referenced configs, as absolute paths or oem URLs, in
+	// addition to those baked into the initramfs.
+	SigningKeysFlag = "ignition.config.signing-keys"
+
+	// MeasurePcrFlag overrides -measure-pcr for the boot.
+	MeasurePcrFlag = "ignition.measure.pcr"
+
+	// UserdataHandlerFlag overrides -userdata-handler for the boot.
+	UserdataHandlerFlag = "ignition.userdata.handler"
+
+	// RequireImdsV2Flag makes requests to the EC2 instance metadata service
+	// fail when no IMDSv2 session token can be had, rather than go without.
+	RequireImdsV2Flag = "ignition.ec2.require-imdsv2"
 )
 
-func FetchConfig(logger *log.Logger, client *resource.HttpClient) (types.Config, report.Report, error) {
-	url, err := readCmdline(logger)
+// NetWait lists the conditions the network must meet before the config is
+// fetched.
+type NetWait struct {
+	Interfaces   []string
+	DefaultRoute bool
+	Hosts        []string
+}
+
+// Empty reports whether w has no conditions.
+func (w NetWait) Empty() bool {
+	return len(w.Interfaces) == 0 && !w.DefaultRoute && len(w.Hosts) == 0
+}
+
+func FetchConfig(logger *log.Logger, client *resource.HttpClient, ctx context.Context) (types.Config, report.Report, error) {
+	return FetchVerifiedConfig(logger, client, ctx, nil)
+}
+
+// FetchVerifiedConfig is FetchConfig, but the config fetched from each URL,
+// unless it is empty, must also pass verify, if it is non-nil, before it is
+// parsed.
+func FetchVerifiedConfig(logger *log.Logger, client *resource.HttpClient, ctx context.Context, verify func(u url.URL, data []byte) error) (types.Config, report.Report, error) {
+	urls, verification, err := readCmdline(logger)
 	if err != nil {
 		return types.Config{}, report.Report{}, err
 	}
 
-	if url == nil {
+	if len(urls) == 0 {
 		return types.Config{}, report.Report{}, providers.ErrNoProvider
 	}
 
-	data, err := resource.FetchConfig(logger, client, context.Background(), *url)
+	var data []byte
+	for _, u := range urls {
+		data, err = resource.FetchConfig(logger, client, ctx, u)
+		if err == nil {
+			err = resource.AssertValid(verification, data)
+		}
+		if err == nil && verify != nil && len(data) != 0 {
+			err = verify(u, data)
+		}
+		if err == nil {
+			break
+		}
+		logger.Warning("failed to fetch config from %q: %v", u.String(), err)
+	}
 	if err != nil {
 		return types.Config{}, report.Report{}, err
 	}
@@ -55,42 +165,248 @@ func FetchConfig(logger *log.Logger, client *resource.HttpClient) (types.Config,
 	return util.ParseConfig(logger, data)
 }
 
-func readCmdline(logger *log.Logger) (*url.URL, error) {
+func readCmdline(logger *log.Logger) ([]url.URL, types.Verification, error) {
 	args, err := ioutil.ReadFile(cmdlinePath)
 	if err != nil {
 		logger.Err("couldn't read cmdline: %v", err)
-		return nil, err
+		return nil, types.Verification{}, err
 	}
 
-	rawUrl := parseCmdline(args)
-	logger.Debug("parsed url from cmdline: %q", rawUrl)
-	if rawUrl == "" {
+	rawUrls := parseCmdline(args)
+	logger.Debug("parsed urls from cmdline: %q", rawUrls)
+	if len(rawUrls) == 0 {
 		logger.Info("no config URL provided")
-		return nil, nil
+		return nil, types.Verification{}, nil
 	}
 
-	url, err := url.Parse(rawUrl)
+	urls := make([]url.URL, 0, len(rawUrls))
+	for _, rawUrl := range rawUrls {
+		u, err := url.Parse(rawUrl)
+		if err != nil {
+			logger.Err("failed to parse url: %v", err)
+			return nil, types.Verification{}, err
+		}
+		urls = append(urls, *u)
+	}
+
+	verification, err := parseVerification(args)
 	if err != nil {
-		logger.Err("failed to parse url: %v", err)
-		return nil, err
+		logger.Err("failed to parse %s: %v", cmdlineHashFlag, err)
+		return nil, types.Verification{}, err
 	}
 
-	return url, err
+	return urls, verification, nil
 }
 
-func parseCmdline(cmdline []byte) (url string) {
+// parseVerification returns the verification given by the hash flag, which
+// is empty if the flag is absent.
+func parseVerification(cmdline []byte) (types.Verification, error) {
+	value, ok := parseFlag(cmdline, cmdlineHashFlag)
+	if !ok {
+		return types.Verification{}, nil
+	}
+	hash := types.Hash{}
+	if err := hash.UnmarshalJSON([]byte(strconv.Quote(value))); err != nil {
+		return types.Verification{}, err
+	}
+	if r := hash.Validate(); r.IsFatal() {
+		return types.Verification{}, errors.New(r.String())
+	}
+	return types.Verification{Hash: &hash}, nil
+}
+
+func parseCmdline(cmdline []byte) (urls []string) {
 	for _, arg := range strings.Split(string(cmdline), " ") {
 		parts := strings.SplitN(strings.TrimSpace(arg), "=", 2)
 		key := parts[0]
 
-		if key != cmdlineUrlFlag {
+		if key != cmdlineUrlFlag && key != ignitionUrlFlag {
 			continue
 		}
 
 		if len(parts) == 2 {
-			url = parts[1]
+			urls = nil
+			for _, u := range strings.Split(parts[1], ",") {
+				if u != "" {
+					urls = append(urls, u)
+				}
+			}
 		}
 	}
 
 	return
 }
+
+// HasFlag reports whether the kernel boot options include flag, either bare or
+// with a value other than 0, "false", or "no".
+func HasFlag(logger *log.Logger, flag string) bool {
+	value, ok := FlagValue(logger, flag)
+	if !ok {
+		return false
+	}
+	switch value {
+	case "0", "false", "no":
+		return false
+	}
+	return true
+}
+
+// FlagValue returns the value of the last occurrence of flag in the kernel
+// boot options, and whether it occurs at all.
+func FlagValue(logger *log.Logger, flag string) (string, bool) {
+	args, err := ioutil.ReadFile(cmdlinePath)
+	if err != nil {
+		logger.Err("couldn't read cmdline: %v", err)
+		return "", false
+	}
+	return parseFlag(args, flag)
+}
+
+func parseFlag(cmdline []byte, flag string) (value string, present bool) {
+	for _, arg := range strings.Fields(string(cmdline)) {
+		parts := strings.SplitN(arg, "=", 2)
+		if parts[0] != flag {
+			continue
+		}
+		present = true
+		value = ""
+		if len(parts) == 2 {
+			value = parts[1]
+		}
+	}
+	return
+}
+
+// Platform returns the platform named by PlatformFlag, and whether one is.
+func Platform(logger *log.Logger) (string, bool) {
+	value, _ := FlagValue(logger, PlatformFlag)
+	return value, value != ""
+}
+
+// FetchTimeout returns the online timeout given by FetchTimeoutFlag, and
+// whether one is.
+func FetchTimeout(logger *log.Logger) (time.Duration, bool, error) {
+	value, ok := FlagValue(logger, FetchTimeoutFlag)
+	if !ok {
+		return 0, false, nil
+	}
+	timeout, err := parseTimeout(value)
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid %s: %v", FetchTimeoutFlag, err)
+	}
+	return timeout, true, nil
+}
+
+// MeasurePcr returns the PCR given by MeasurePcrFlag, and whether one is.
+func MeasurePcr(logger *log.Logger) (int, bool, error) {
+	value, ok := FlagValue(logger, MeasurePcrFlag)
+	if !ok {
+		return 0, false, nil
+	}
+	pcr, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid %s: %v", MeasurePcrFlag, err)
+	}
+	return pcr, true, nil
+}
+
+func parseTimeout(value string) (time.Duration, error) {
+	if seconds, err := strconv.ParseUint(value, 10, 32); err == nil {
+		return time.Duration(seconds) * time.Second, nil
+	}
+	timeout, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, err
+	}
+	if timeout < 0 {
+		return 0, fmt.Errorf("negative timeout %q", value)
+	}
+	return timeout, nil
+}
+
+// Proxy returns the proxy settings given on the kernel command line.
+func Proxy(logger *log.Logger) types.Proxy {
+	proxy := types.Proxy{}
+	proxy.HttpProxy, _ = FlagValue(logger, HttpProxyFlag)
+	proxy.HttpsProxy, _ = FlagValue(logger, HttpsProxyFlag)
+	if noProxy, _ := FlagValue(logger, NoProxyFlag); noProxy != "" {
+		for _, item := range strings.Split(noProxy, ",") {
+			if item != "" {
+				proxy.NoProxy = append(proxy.NoProxy, types.NoProxyItem(item))
+			}
+		}
+	}
+	return proxy
+}
+
+// StatusReport returns the status report endpoint given on the kernel command
+// line, or nil if there is none.
+func StatusReport(logger *log.Logger) *types.StatusReport {
+	value, ok := FlagValue(logger, StatusReportUrlFlag)
+	if !ok || value == "" {
+		return nil
+	}
+	u, err := url.Parse(value)
+	if err != nil {
+		logger.Err("invalid %s: %v", StatusReportUrlFlag, err)
+		return nil
+	}
+	s := &types.StatusReport{Url: types.Url(*u)}
+	s.SigningKey, _ = FlagValue(logger, StatusReportKeyFlag)
+	if r := s.Validate(); r.IsFatal() {
+		logger.Err("invalid %s: %v", StatusReportUrlFlag, r)
+		return nil
+	}
+	return s
+}
+
+// ParseNetWait returns the network conditions given on the kernel command
+// line.
+func ParseNetWait(logger *log.Logger) NetWait {
+	args, err := ioutil.ReadFile(cmdlinePath)
+	if err != nil {
+		logger.Err("couldn't read cmdline: %v", err)
+		return NetWait{}
+	}
+	return parseNetWait(args)
+}
+
+func parseNetWait(cmdline []byte) NetWait {
+	w := NetWait{}
+	if value, _ := parseFlag(cmdline, NetWaitInterfaceFlag); value != "" {
+		w.Interfaces = splitList(value)
+	}
+	if value, ok := parseFlag(cmdline, NetWaitRouteFlag); ok {
+		switch value {
+		case "0", "false", "no":
+		default:
+			w.DefaultRoute = true
+		}
+	}
+	if value, ok := parseFlag(cmdline, NetWaitDnsFlag); ok {
+		if value != "" {
+			w.Hosts = splitList(value)
+		} else if urls := parseCmdline(cmdline); len(urls) != 0 {
+			if u, err := url.Parse(urls[0]); err == nil && u.Hostname() != "" {
+				w.Hosts = []string{u.Hostname()}
+			}
+		}
+	}
+	return w
+}
+
+// splitList returns the non-empty items of a comma-separated list.
+func splitList(value string) (items []string) {
+	for _, item := range strings.Split(value, ",") {
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+	return
+}
+
+// SigningKeys returns the signing keys given by SigningKeysFlag.
+func SigningKeys(logger *log.Logger) []string {
+	value, _ := FlagValue(logger, SigningKeysFlag)
+	return splitList(value)
+}
@@ -0,0 +1,252 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmdline
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/coreos/ignition/config/types"
+)
+
+func TestParseCmdline(t *testing.T) {
+	type in struct {
+		cmdline string
+	}
+	type out struct {
+		urls []string
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{cmdline: "BOOT_IMAGE=/coreos/vmlinuz console=ttyS0"},
+			out: out{},
+		},
+		{
+			in:  in{cmdline: "coreos.config.url=http://example.com/config.ign rw"},
+			out: out{urls: []string{"http://example.com/config.ign"}},
+		},
+		{
+			in:  in{cmdline: "coreos.config.url=http://a.example.com/c.ign,http://b.example.com/c.ign\n"},
+			out: out{urls: []string{"http://a.example.com/c.ign", "http://b.example.com/c.ign"}},
+		},
+		{
+			in:  in{cmdline: "coreos.config.url=http://a.example.com/c.ign,,oem:///c.ign,"},
+			out: out{urls: []string{"http://a.example.com/c.ign", "oem:///c.ign"}},
+		},
+		{
+			in:  in{cmdline: "coreos.config.url=http://a.example.com/c.ign coreos.config.url=oem:///c.ign"},
+			out: out{urls: []string{"oem:///c.ign"}},
+		},
+		{
+			in:  in{cmdline: "ignition.config.url=tftp://10.0.0.1/c.ign"},
+			out: out{urls: []string{"tftp://10.0.0.1/c.ign"}},
+		},
+		{
+			in:  in{cmdline: "coreos.config.url=http://a.example.com/c.ign ignition.config.url=oem:///c.ign"},
+			out: out{urls: []string{"oem:///c.ign"}},
+		},
+	}
+
+	for i, test := range tests {
+		urls := parseCmdline([]byte(test.in.cmdline))
+		if !reflect.DeepEqual(test.out.urls, urls) {
+			t.Errorf("#%d: bad urls: want %q, got %q", i, test.out.urls, urls)
+		}
+	}
+}
+
+func TestParseFlag(t *testing.T) {
+	type in struct {
+		cmdline string
+	}
+	type out struct {
+		value   string
+		present bool
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{cmdline: "BOOT_IMAGE=/coreos/vmlinuz console=ttyS0"},
+			out: out{},
+		},
+		{
+			in:  in{cmdline: "rw coreos.ignition.force\n"},
+			out: out{present: true},
+		},
+		{
+			in:  in{cmdline: "coreos.ignition.force=1 rw"},
+			out: out{value: "1", present: true},
+		},
+		{
+			in:  in{cmdline: "coreos.ignition.force=1 coreos.ignition.force=0"},
+			out: out{value: "0", present: true},
+		},
+		{
+			in:  in{cmdline: "coreos.ignition.forced"},
+			out: out{},
+		},
+	}
+
+	for i, test := range tests {
+		value, present := parseFlag([]byte(test.in.cmdline), ForceFlag)
+		if value != test.out.value || present != test.out.present {
+			t.Errorf("#%d: bad flag: want %q (%v), got %q (%v)", i, test.out.value, test.out.present, value, present)
+		}
+	}
+}
+
+func TestParseVerification(t *testing.T) {
+	type in struct {
+		cmdline string
+	}
+	type out struct {
+		hash *types.Hash
+		ok   bool
+	}
+
+	sum := "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{cmdline: "coreos.config.url=http://example.com/config.ign"},
+			out: out{ok: true},
+		},
+		{
+			in:  in{cmdline: "coreos.config.url=http://example.com/config.ign coreos.config.verification=sha256-" + sum},
+			out: out{hash: &types.Hash{Function: "sha256", Sum: sum}, ok: true},
+		},
+		{
+			in:  in{cmdline: "coreos.config.verification=sha256"},
+			out: out{ok: false},
+		},
+		{
+			in:  in{cmdline: "coreos.config.verification=sha256-0123"},
+			out: out{ok: false},
+		},
+	}
+
+	for i, test := range tests {
+		verification, err := parseVerification([]byte(test.in.cmdline))
+		if (err == nil) != test.out.ok {
+			t.Errorf("#%d: bad error: want ok %v, got %v", i, test.out.ok, err)
+			continue
+		}
+		if !reflect.DeepEqual(test.out.hash, verification.Hash) {
+			t.Errorf("#%d: bad hash: want %v, got %v", i, test.out.hash, verification.Hash)
+		}
+	}
+}
+
+func TestParseTimeout(t *testing.T) {
+	type in struct {
+		value string
+	}
+	type out struct {
+		timeout time.Duration
+		err     bool
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{value: "90"},
+			out: out{timeout: 90 * time.Second},
+		},
+		{
+			in:  in{value: "0"},
+			out: out{timeout: 0},
+		},
+		{
+			in:  in{value: "2m30s"},
+			out: out{timeout: 150 * time.Second},
+		},
+		{
+			in:  in{value: "-1m"},
+			out: out{err: true},
+		},
+		{
+			in:  in{value: ""},
+			out: out{err: true},
+		},
+		{
+			in:  in{value: "soon"},
+			out: out{err: true},
+		},
+	}
+
+	for i, test := range tests {
+		timeout, err := parseTimeout(test.in.value)
+		if (err != nil) != test.out.err {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.out.err, err)
+		}
+		if timeout != test.out.timeout {
+			t.Errorf("#%d: bad timeout: want %v, got %v", i, test.out.timeout, timeout)
+		}
+	}
+}
+
+func TestParseNetWait(t *testing.T) {
+	type in struct {
+		cmdline string
+	}
+	type out struct {
+		wait NetWait
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{cmdline: "coreos.config.url=http://example.com/config.ign"},
+			out: out{wait: NetWait{}},
+		},
+		{
+			in:  in{cmdline: "ignition.net.wait-interface=eth0,,eth1 ignition.net.wait-route"},
+			out: out{wait: NetWait{Interfaces: []string{"eth0", "eth1"}, DefaultRoute: true}},
+		},
+		{
+			in:  in{cmdline: "ignition.net.wait-route=0 ignition.net.wait-dns=metadata.internal,example.com"},
+			out: out{wait: NetWait{Hosts: []string{"metadata.internal", "example.com"}}},
+		},
+		{
+			in:  in{cmdline: "coreos.config.url=http://config.example.com:8080/config.ign,http://backup.example.com/config.ign ignition.net.wait-dns"},
+			out: out{wait: NetWait{Hosts: []string{"config.example.com"}}},
+		},
+		{
+			in:  in{cmdline: "ignition.net.wait-dns"},
+			out: out{wait: NetWait{}},
+		},
+	}
+
+	for i, test := range tests {
+		wait := parseNetWait([]byte(test.in.cmdline))
+		if !reflect.DeepEqual(test.out.wait, wait) {
+			t.Errorf("#%d: bad net wait: want %+v, got %+v", i, test.out.wait, wait)
+		}
+	}
+}
@@ -37,8 +37,8 @@ var (
 	}
 )
 
-func FetchConfig(logger *log.Logger, client *resource.HttpClient) (types.Config, report.Report, error) {
-	data, err := resource.FetchConfig(logger, client, context.Background(), userdataUrl)
+func FetchConfig(logger *log.Logger, client *resource.HttpClient, ctx context.Context) (types.Config, report.Report, error) {
+	data, err := resource.FetchConfig(logger, client, ctx, userdataUrl)
 	if err != nil {
 		return types.Config{}, report.Report{}, err
 	}
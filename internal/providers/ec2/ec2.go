@@ -13,7 +13,7 @@
 // limitations under the License.
 
 // The ec2 provider fetches a remote configuration from the ec2 user-data
-// metadata service URL.
+// metadata service URL, and the machine's metadata from the metadata service.
 
 package ec2
 
@@ -23,6 +23,7 @@ import (
 	"github.com/coreos/ignition/config/types"
 	"github.com/coreos/ignition/config/validate/report"
 	"github.com/coreos/ignition/internal/log"
+	"github.com/coreos/ignition/internal/providers"
 	"github.com/coreos/ignition/internal/providers/util"
 	"github.com/coreos/ignition/internal/resource"
 
@@ -35,13 +36,47 @@ var (
 		Host:   "169.254.169.254",
 		Path:   "2009-04-04/user-data",
 	}
+	metadataUrl = url.URL{
+		Scheme: "http",
+		Host:   "169.254.169.254",
+		Path:   "2009-04-04/meta-data",
+	}
+	metadataPaths = map[string]string{
+		providers.MetadataInstanceId:  "instance-id",
+		providers.MetadataZone:        "placement/availability-zone",
+		providers.MetadataIPv4Private: "local-ipv4",
+		providers.MetadataIPv4Public:  "public-ipv4",
+	}
 )
 
-func FetchConfig(logger *log.Logger, client *resource.HttpClient) (types.Config, report.Report, error) {
-	data, err := resource.FetchConfig(logger, client, context.Background(), userdataUrl)
+// FetchConfig fetches the user-data, with an IMDSv2 session token as hardened
+// instances require.
+func FetchConfig(logger *log.Logger, client *resource.HttpClient, ctx context.Context) (types.Config, report.Report, error) {
+	header, err := client.AwsMetadataHeader(ctx)
+	if err != nil {
+		return types.Config{}, report.Report{}, err
+	}
+	data, err := resource.FetchConfigWithHeader(logger, client, ctx, userdataUrl, header)
 	if err != nil {
 		return types.Config{}, report.Report{}, err
 	}
 
 	return util.ParseConfig(logger, data)
 }
+
+func FetchMetadata(logger *log.Logger, client *resource.HttpClient, ctx context.Context) (map[string]string, error) {
+	header, err := client.AwsMetadataHeader(ctx)
+	if err != nil {
+		return nil, err
+	}
+	attrs, err := util.FetchMetadataAttributes(logger, client, ctx, metadataUrl, header, metadataPaths)
+	if err != nil {
+		return nil, err
+	}
+
+	// Availability zones are named after their region, e.g. us-east-1a.
+	if zone := attrs[providers.MetadataZone]; len(zone) > 1 {
+		attrs[providers.MetadataRegion] = zone[:len(zone)-1]
+	}
+	return attrs, nil
+}
@@ -13,23 +13,45 @@
 // limitations under the License.
 
 // The gce provider fetches a remote configuration from the gce user-data
-// metadata service URL.
+// metadata service URL, and the machine's metadata from the metadata service.
 
 package gce
 
 import (
+	"errors"
+	"io/ioutil"
 	"net/http"
 	"net/url"
+	"path"
+	"strings"
+	"time"
 
 	"github.com/coreos/ignition/config/types"
 	"github.com/coreos/ignition/config/validate/report"
 	"github.com/coreos/ignition/internal/log"
+	"github.com/coreos/ignition/internal/providers"
 	"github.com/coreos/ignition/internal/providers/util"
 	"github.com/coreos/ignition/internal/resource"
 
 	"golang.org/x/net/context"
 )
 
+const (
+	// flavorAttempts is how many responses lacking the Metadata-Flavor
+	// header are tolerated, while the network is still coming up, before
+	// giving up on the metadata server.
+	flavorAttempts = 10
+)
+
+var (
+	initialBackoff = 100 * time.Millisecond
+	maxBackoff     = 5 * time.Second
+)
+
+var (
+	ErrNotMetadataServer = errors.New("response did not come from the GCE metadata server")
+)
+
 var (
 	userdataUrl = url.URL{
 		Scheme: "http",
@@ -37,13 +59,76 @@ var (
 		Path:   "computeMetadata/v1/instance/attributes/user-data",
 	}
 	metadataHeader = http.Header{"Metadata-Flavor": []string{"Google"}}
+	metadataUrl    = url.URL{
+		Scheme: "http",
+		Host:   "metadata.google.internal",
+		Path:   "computeMetadata/v1/instance",
+	}
+	metadataPaths = map[string]string{
+		providers.MetadataInstanceId:  "id",
+		providers.MetadataZone:        "zone",
+		providers.MetadataIPv4Private: "network-interfaces/0/ip",
+		providers.MetadataIPv4Public:  "network-interfaces/0/access-configs/0/external-ip",
+	}
 )
 
-func FetchConfig(logger *log.Logger, client *resource.HttpClient) (types.Config, report.Report, error) {
-	data, err := resource.FetchConfigWithHeader(logger, client, context.Background(), userdataUrl, metadataHeader)
+func FetchConfig(logger *log.Logger, client *resource.HttpClient, ctx context.Context) (types.Config, report.Report, error) {
+	data, err := fetchUserdata(logger, client, ctx)
 	if err != nil {
 		return types.Config{}, report.Report{}, err
 	}
 
 	return util.ParseConfig(logger, data)
 }
+
+// fetchUserdata fetches the user-data attribute, returning an empty config if
+// none was provided. The client retries until the metadata server is
+// reachable; responses which don't carry the Metadata-Flavor header (e.g.
+// from a captive proxy answering before the network is fully configured) are
+// retried as well, since they aren't the instance's user-data.
+func fetchUserdata(logger *log.Logger, client *resource.HttpClient, ctx context.Context) ([]byte, error) {
+	backoff := initialBackoff
+	for attempt := 1; ; attempt++ {
+		resp, err := client.GetWithHeader(ctx, userdataUrl.String(), metadataHeader)
+		if err != nil {
+			return nil, err
+		}
+		if resp.Header.Get("Metadata-Flavor") == "Google" {
+			defer resp.Body.Close()
+			switch resp.StatusCode {
+			case http.StatusOK:
+				return ioutil.ReadAll(resp.Body)
+			case http.StatusNotFound:
+				return []byte{}, nil
+			default:
+				return nil, resource.ErrFailed
+			}
+		}
+		resp.Body.Close()
+
+		if attempt == flavorAttempts {
+			return nil, ErrNotMetadataServer
+		}
+		logger.Info("response lacked the Metadata-Flavor header; retrying")
+		if err := util.Sleep(ctx, util.ExpBackoff(&backoff, maxBackoff)); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func FetchMetadata(logger *log.Logger, client *resource.HttpClient, ctx context.Context) (map[string]string, error) {
+	attrs, err := util.FetchMetadataAttributes(logger, client, ctx, metadataUrl, metadataHeader, metadataPaths)
+	if err != nil {
+		return nil, err
+	}
+
+	// Zones are given as projects/<number>/zones/<region>-<letter>.
+	if zone, ok := attrs[providers.MetadataZone]; ok {
+		zone = path.Base(zone)
+		attrs[providers.MetadataZone] = zone
+		if i := strings.LastIndex(zone, "-"); i > 0 {
+			attrs[providers.MetadataRegion] = zone[:i]
+		}
+	}
+	return attrs, nil
+}
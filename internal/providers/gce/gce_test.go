@@ -0,0 +1,103 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gce
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/coreos/ignition/internal/log"
+	"github.com/coreos/ignition/internal/resource"
+
+	"golang.org/x/net/context"
+)
+
+func TestFetchUserdata(t *testing.T) {
+	type in struct {
+		unflavored int
+		status     int
+		body       string
+	}
+	type out struct {
+		data []byte
+		err  error
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{status: http.StatusOK, body: "{}"},
+			out: out{data: []byte("{}")},
+		},
+		{
+			in:  in{unflavored: 2, status: http.StatusOK, body: "{}"},
+			out: out{data: []byte("{}")},
+		},
+		{
+			in:  in{status: http.StatusNotFound},
+			out: out{data: []byte{}},
+		},
+		{
+			in:  in{status: http.StatusForbidden},
+			out: out{err: resource.ErrFailed},
+		},
+		{
+			in:  in{unflavored: flavorAttempts, status: http.StatusOK},
+			out: out{err: ErrNotMetadataServer},
+		},
+	}
+
+	defaultUrl, defaultInitial, defaultMax := userdataUrl, initialBackoff, maxBackoff
+	initialBackoff, maxBackoff = time.Millisecond, time.Millisecond
+	defer func() { userdataUrl, initialBackoff, maxBackoff = defaultUrl, defaultInitial, defaultMax }()
+
+	logger := log.New()
+	for i, test := range tests {
+		requests := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			if r.Header.Get("Metadata-Flavor") != "Google" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			if requests > test.in.unflavored {
+				w.Header().Set("Metadata-Flavor", "Google")
+			}
+			w.WriteHeader(test.in.status)
+			w.Write([]byte(test.in.body))
+		}))
+		serverUrl, err := url.Parse(server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		userdataUrl.Host = serverUrl.Host
+
+		client := resource.NewHttpClient(&logger)
+		data, err := fetchUserdata(&logger, &client, context.Background())
+		server.Close()
+		if err != test.out.err {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.out.err, err)
+		}
+		if !reflect.DeepEqual(test.out.data, data) {
+			t.Errorf("#%d: bad data: want %q, got %q", i, test.out.data, data)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// The hyperv provider fetches a local configuration from the Hyper-V Data
+// Exchange (KVP) pool written by the host, which hv_kvp_daemon keeps in
+// /var/lib/hyperv. The config is either the value of the key
+// "ignition.config" or, since values are limited to 2048 bytes, the
+// concatenation of the values of "ignition.config.0", "ignition.config.1",
+// and so on.
+
+package hyperv
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/coreos/ignition/config/types"
+	"github.com/coreos/ignition/config/validate/report"
+	"github.com/coreos/ignition/internal/log"
+	"github.com/coreos/ignition/internal/providers"
+	"github.com/coreos/ignition/internal/providers/util"
+	"github.com/coreos/ignition/internal/resource"
+
+	"golang.org/x/net/context"
+)
+
+const (
+	// poolPath is the pool of pairs set by the host (pool 0, "external").
+	poolPath  = "/var/lib/hyperv/.kvp_pool_0"
+	configKey = "ignition.config"
+
+	// These sizes come from <linux/hyperv.h>.
+	maxKeySize   = 512
+	maxValueSize = 2048
+	recordSize   = maxKeySize + maxValueSize
+
+	// poolTimeout bounds the wait for hv_kvp_daemon to write the pool.
+	poolTimeout = 30 * time.Second
+)
+
+func FetchConfig(logger *log.Logger, _ *resource.HttpClient, ctx context.Context) (types.Config, report.Report, error) {
+	pool, err := waitForPool(logger, ctx)
+	if err != nil {
+		return types.Config{}, report.Report{}, err
+	}
+
+	data, ok := assembleConfig(parsePool(pool))
+	if !ok {
+		logger.Info("no %q KVP entries provided", configKey)
+	}
+	return util.ParseConfig(logger, data)
+}
+
+func waitForPool(logger *log.Logger, ctx context.Context) ([]byte, error) {
+	poolCtx, cancel := context.WithTimeout(ctx, poolTimeout)
+	defer cancel()
+
+	var pool []byte
+	err := util.Poll(poolCtx, time.Second, func() (bool, error) {
+		var err error
+		pool, err = ioutil.ReadFile(poolPath)
+		if err == nil {
+			return true, nil
+		} else if !os.IsNotExist(err) {
+			return false, fmt.Errorf("failed to read KVP pool: %v", err)
+		}
+		logger.Debug("KVP pool %q not found. Waiting...", poolPath)
+		return false, nil
+	})
+	// Only the pool's own timeout means there's no config.
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	if err == context.DeadlineExceeded {
+		logger.Info("KVP pool %q was not found. Continuing without a config...", poolPath)
+		return nil, nil
+	}
+	return pool, err
+}
+
+// DeleteConfig removes the pairs holding the config from the guest's copy of
+// the KVP pool. hv_kvp_daemon locks the pool while it reads or writes it, and
+// Ignition takes the same lock. The host keeps its own copy, which only the
+// host can clear, with RemoveKvpItems.
+func DeleteConfig(logger *log.Logger) error {
+	f, err := os.OpenFile(poolPath, os.O_RDWR, 0)
+	if os.IsNotExist(err) {
+		return providers.ErrNoProvider
+	} else if err != nil {
+		return fmt.Errorf("failed to open KVP pool: %v", err)
+	}
+	defer f.Close()
+
+	lock := syscall.Flock_t{Type: syscall.F_WRLCK}
+	if err := syscall.FcntlFlock(f.Fd(), syscall.F_SETLKW, &lock); err != nil {
+		return fmt.Errorf("failed to lock KVP pool: %v", err)
+	}
+
+	pool, err := ioutil.ReadAll(f)
+	if err != nil {
+		return fmt.Errorf("failed to read KVP pool: %v", err)
+	}
+	kept, removed := removeConfig(pool)
+	if removed == 0 {
+		logger.Info("no %q KVP entries to remove", configKey)
+		return nil
+	}
+	if _, err := f.WriteAt(kept, 0); err != nil {
+		return fmt.Errorf("failed to write KVP pool: %v", err)
+	}
+	if err := f.Truncate(int64(len(kept))); err != nil {
+		return fmt.Errorf("failed to truncate KVP pool: %v", err)
+	}
+	logger.Info("removed %d %q KVP entries", removed, configKey)
+	return nil
+}
+
+// removeConfig returns pool without the records holding the config, and the
+// number of records removed.
+func removeConfig(pool []byte) ([]byte, int) {
+	var kept []byte
+	removed := 0
+	for ; len(pool) >= recordSize; pool = pool[recordSize:] {
+		key := trimNul(pool[:maxKeySize])
+		if key == configKey || strings.HasPrefix(key, configKey+".") {
+			removed++
+			continue
+		}
+		kept = append(kept, pool[:recordSize]...)
+	}
+	return kept, removed
+}
+
+// parsePool returns the pairs in a KVP pool, which is a sequence of records
+// each holding a NUL-padded key and value.
+func parsePool(pool []byte) map[string]string {
+	kvs := map[string]string{}
+	for len(pool) >= recordSize {
+		key := trimNul(pool[:maxKeySize])
+		value := trimNul(pool[maxKeySize:recordSize])
+		if key != "" {
+			kvs[key] = value
+		}
+		pool = pool[recordSize:]
+	}
+	return kvs
+}
+
+func trimNul(field []byte) string {
+	if i := bytes.IndexByte(field, 0); i >= 0 {
+		field = field[:i]
+	}
+	return string(field)
+}
+
+// assembleConfig returns the config held by kvs, and whether there is one.
+// A config given whole takes precedence over one split into chunks.
+func assembleConfig(kvs map[string]string) ([]byte, bool) {
+	if value, ok := kvs[configKey]; ok {
+		return []byte(value), true
+	}
+
+	var config bytes.Buffer
+	for i := 0; ; i++ {
+		value, ok := kvs[configKey+"."+strconv.Itoa(i)]
+		if !ok {
+			return config.Bytes(), i > 0
+		}
+		config.WriteString(value)
+	}
+}
@@ -0,0 +1,141 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hyperv
+
+import (
+	"reflect"
+	"testing"
+)
+
+func record(key, value string) []byte {
+	r := make([]byte, recordSize)
+	copy(r, key)
+	copy(r[maxKeySize:], value)
+	return r
+}
+
+func TestParsePool(t *testing.T) {
+	type in struct {
+		pool []byte
+	}
+	type out struct {
+		kvs map[string]string
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{},
+			out: out{kvs: map[string]string{}},
+		},
+		{
+			in:  in{pool: append(record("a", "1"), record("b", "2")...)},
+			out: out{kvs: map[string]string{"a": "1", "b": "2"}},
+		},
+		{
+			in:  in{pool: append(record("a", "1"), 'x', 'y')},
+			out: out{kvs: map[string]string{"a": "1"}},
+		},
+	}
+
+	for i, test := range tests {
+		kvs := parsePool(test.in.pool)
+		if !reflect.DeepEqual(test.out.kvs, kvs) {
+			t.Errorf("#%d: bad pairs: want %v, got %v", i, test.out.kvs, kvs)
+		}
+	}
+}
+
+func TestRemoveConfig(t *testing.T) {
+	type in struct {
+		pool []byte
+	}
+	type out struct {
+		pool    []byte
+		removed int
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{pool: append(record("a", "1"), record("b", "2")...)},
+			out: out{pool: append(record("a", "1"), record("b", "2")...)},
+		},
+		{
+			in:  in{pool: append(append(record("ignition.config.0", "{"), record("a", "1")...), record("ignition.config.1", "}")...)},
+			out: out{pool: record("a", "1"), removed: 2},
+		},
+		{
+			in:  in{pool: append(record("ignition.config", "{}"), record("ignition.configs", "x")...)},
+			out: out{pool: record("ignition.configs", "x"), removed: 1},
+		},
+	}
+
+	for i, test := range tests {
+		pool, removed := removeConfig(test.in.pool)
+		if removed != test.out.removed {
+			t.Errorf("#%d: bad removed: want %d, got %d", i, test.out.removed, removed)
+		}
+		if !reflect.DeepEqual(test.out.pool, pool) {
+			t.Errorf("#%d: bad pool: want %d bytes, got %d", i, len(test.out.pool), len(pool))
+		}
+	}
+}
+
+func TestAssembleConfig(t *testing.T) {
+	type in struct {
+		kvs map[string]string
+	}
+	type out struct {
+		config string
+		ok     bool
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{kvs: map[string]string{"other": "x"}},
+			out: out{},
+		},
+		{
+			in:  in{kvs: map[string]string{"ignition.config": "{}"}},
+			out: out{config: "{}", ok: true},
+		},
+		{
+			in:  in{kvs: map[string]string{"ignition.config.0": `{"ignition":`, "ignition.config.1": `{}}`, "ignition.config.3": "x"}},
+			out: out{config: `{"ignition":{}}`, ok: true},
+		},
+		{
+			in:  in{kvs: map[string]string{"ignition.config": "{}", "ignition.config.0": "x"}},
+			out: out{config: "{}", ok: true},
+		},
+	}
+
+	for i, test := range tests {
+		config, ok := assembleConfig(test.in.kvs)
+		if ok != test.out.ok {
+			t.Errorf("#%d: bad ok: want %t, got %t", i, test.out.ok, ok)
+		}
+		if string(config) != test.out.config {
+			t.Errorf("#%d: bad config: want %q, got %q", i, test.out.config, config)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// The ibmcloud provider fetches a remote configuration from the userdata of
+// the IBM Cloud VPC metadata service, and the powervs provider fetches it
+// from the cloud-init config drive attached to IBM Power Virtual Server
+// instances.
+
+package ibmcloud
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+
+	"github.com/coreos/ignition/config/types"
+	"github.com/coreos/ignition/config/validate/report"
+	"github.com/coreos/ignition/internal/log"
+	"github.com/coreos/ignition/internal/providers/util"
+	"github.com/coreos/ignition/internal/resource"
+
+	"golang.org/x/net/context"
+)
+
+const (
+	metadataVersion = "2022-03-01"
+	configDrivePath = "/dev/disk/by-label/config-2"
+)
+
+var (
+	ErrNoToken = errors.New("metadata service returned no token")
+)
+
+var (
+	tokenUrl = url.URL{
+		Scheme:   "http",
+		Host:     "169.254.169.254",
+		Path:     "instance_identity/v1/token",
+		RawQuery: "version=" + metadataVersion,
+	}
+	initializationUrl = url.URL{
+		Scheme:   "http",
+		Host:     "169.254.169.254",
+		Path:     "metadata/v1/instance/initialization",
+		RawQuery: "version=" + metadataVersion,
+	}
+)
+
+// FetchConfig fetches the userdata from the VPC metadata service. Requests to
+// the service must carry a short-lived instance identity token.
+func FetchConfig(logger *log.Logger, client *resource.HttpClient, ctx context.Context) (types.Config, report.Report, error) {
+	data, err := fetchUserdata(logger, client, ctx)
+	if err != nil {
+		return types.Config{}, report.Report{}, err
+	}
+
+	return util.ParseConfig(logger, data)
+}
+
+// FetchPowerVSConfig fetches the userdata from the config drive.
+func FetchPowerVSConfig(logger *log.Logger, _ *resource.HttpClient, ctx context.Context) (types.Config, report.Report, error) {
+	data, err := util.ReadConfigDrive(logger, ctx, configDrivePath, util.ConfigDriveUserdataPath)
+	if err != nil {
+		return types.Config{}, report.Report{}, err
+	}
+
+	return util.ParseConfig(logger, data)
+}
+
+func fetchUserdata(logger *log.Logger, client *resource.HttpClient, ctx context.Context) ([]byte, error) {
+	token, err := fetchToken(client, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.GetWithHeader(ctx, initializationUrl.String(), http.Header{
+		"Authorization": {"Bearer " + token},
+		"Accept":        {"application/json"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch instance initialization: %s", http.StatusText(resp.StatusCode))
+	}
+
+	var initialization struct {
+		UserData string `json:"user_data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&initialization); err != nil {
+		return nil, fmt.Errorf("failed to parse instance initialization: %v", err)
+	}
+	if initialization.UserData == "" {
+		logger.Info("no userdata provided")
+	}
+	return []byte(initialization.UserData), nil
+}
+
+func fetchToken(client *resource.HttpClient, ctx context.Context) (string, error) {
+	resp, err := client.PutWithHeader(ctx, tokenUrl.String(), []byte(`{"expires_in":300}`), http.Header{
+		"Metadata-Flavor": {"ibm"},
+		"Content-Type":    {"application/json"},
+		"Accept":          {"application/json"},
+	})
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to fetch instance identity token: %s", http.StatusText(resp.StatusCode))
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.Unmarshal(body, &token); err != nil {
+		return "", fmt.Errorf("failed to parse instance identity token: %v", err)
+	}
+	if token.AccessToken == "" {
+		return "", ErrNoToken
+	}
+	return token.AccessToken, nil
+}
@@ -0,0 +1,97 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ibmcloud
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/coreos/ignition/internal/log"
+	"github.com/coreos/ignition/internal/resource"
+
+	"golang.org/x/net/context"
+)
+
+func TestFetchUserdata(t *testing.T) {
+	type in struct {
+		token          string
+		initialization string
+	}
+	type out struct {
+		data []byte
+		err  bool
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{token: `{"access_token":"secret"}`, initialization: `{"user_data":"{}"}`},
+			out: out{data: []byte("{}")},
+		},
+		{
+			in:  in{token: `{"access_token":"secret"}`, initialization: `{"keys":[]}`},
+			out: out{data: []byte{}},
+		},
+		{
+			in:  in{token: `{}`},
+			out: out{err: true},
+		},
+	}
+
+	defaultTokenUrl, defaultInitializationUrl := tokenUrl, initializationUrl
+	defer func() { tokenUrl, initializationUrl = defaultTokenUrl, defaultInitializationUrl }()
+
+	logger := log.New()
+	for i, test := range tests {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/instance_identity/v1/token":
+				if r.Method != "PUT" || r.Header.Get("Metadata-Flavor") != "ibm" {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				w.Write([]byte(test.in.token))
+			case "/metadata/v1/instance/initialization":
+				if r.Header.Get("Authorization") != "Bearer secret" {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				w.Write([]byte(test.in.initialization))
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+		serverUrl, err := url.Parse(server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tokenUrl.Host, initializationUrl.Host = serverUrl.Host, serverUrl.Host
+
+		client := resource.NewHttpClient(&logger)
+		data, err := fetchUserdata(&logger, &client, context.Background())
+		server.Close()
+		if (err != nil) != test.out.err {
+			t.Errorf("#%d: bad error: want %t, got %v", i, test.out.err, err)
+		}
+		if !test.out.err && !reflect.DeepEqual(test.out.data, data) {
+			t.Errorf("#%d: bad data: want %q, got %q", i, test.out.data, data)
+		}
+	}
+}
@@ -12,7 +12,8 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-// The noop provider does nothing, for use by unimplemented oems.
+// The noop provider does nothing, for use by unimplemented oems and by the
+// noop platform, which runs Ignition without any provider.
 
 package noop
 
@@ -22,9 +23,11 @@ import (
 	"github.com/coreos/ignition/config/validate/report"
 	"github.com/coreos/ignition/internal/log"
 	"github.com/coreos/ignition/internal/resource"
+
+	"golang.org/x/net/context"
 )
 
-func FetchConfig(logger *log.Logger, _ *resource.HttpClient) (types.Config, report.Report, error) {
+func FetchConfig(logger *log.Logger, _ *resource.HttpClient, _ context.Context) (types.Config, report.Report, error) {
 	logger.Debug("noop provider fetching empty config")
 	return types.Config{}, report.Report{}, config.ErrEmpty
 }
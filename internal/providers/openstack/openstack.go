@@ -15,32 +15,27 @@
 // The OpenStack provider fetches configurations from the userdata available in
 // both the config-drive as well as the network metadata service. Whichever
 // responds first is the config that is used.
-// NOTE: This provider is still EXPERIMENTAL.
 
 package openstack
 
 import (
-	"fmt"
-	"io/ioutil"
 	"net/url"
-	"os"
-	"os/exec"
-	"path/filepath"
-	"syscall"
 	"time"
 
-	"github.com/coreos/ignition/config"
 	"github.com/coreos/ignition/config/types"
 	"github.com/coreos/ignition/config/validate/report"
 	"github.com/coreos/ignition/internal/log"
+	"github.com/coreos/ignition/internal/providers/util"
 	"github.com/coreos/ignition/internal/resource"
 
 	"golang.org/x/net/context"
 )
 
 const (
-	diskByLabelPath         = "/dev/disk/by-label/"
-	configDriveUserdataPath = "/openstack/latest/user_data"
+	diskByLabelPath = "/dev/disk/by-label/"
+
+	// fetchTimeout bounds the wait for any of the sources to respond.
+	fetchTimeout = 30 * time.Second
 )
 
 var (
@@ -51,85 +46,68 @@ var (
 	}
 )
 
-func FetchConfig(logger *log.Logger, client *resource.HttpClient) (types.Config, report.Report, error) {
-	var data []byte
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-
-	dispatch := func(name string, fn func() ([]byte, error)) {
-		raw, err := fn()
-		if err != nil {
-			switch err {
-			case context.Canceled:
-			case context.DeadlineExceeded:
-				logger.Err("timed out while fetching config from %s", name)
-			default:
-				logger.Err("failed to fetch config from %s: %v", name, err)
-			}
-			return
-		}
-
-		data = raw
-		cancel()
-	}
-
-	go dispatch("config drive (config-2)", func() ([]byte, error) {
-		return fetchConfigFromDevice(logger, ctx, diskByLabelPath+"config-2")
-	})
-
-	go dispatch("config drive (CONFIG-2)", func() ([]byte, error) {
-		return fetchConfigFromDevice(logger, ctx, diskByLabelPath+"CONFIG-2")
-	})
+// source is one of the places the userdata may be found.
+type source struct {
+	name  string
+	fetch func(ctx context.Context) ([]byte, error)
+}
 
-	go dispatch("metadata service", func() ([]byte, error) {
-		return fetchConfigFromMetadataService(logger, client, ctx)
+func FetchConfig(logger *log.Logger, client *resource.HttpClient, ctx context.Context) (types.Config, report.Report, error) {
+	return fetchFirst(logger, ctx, []source{
+		{"config drive (config-2)", func(ctx context.Context) ([]byte, error) {
+			return util.ReadConfigDrive(logger, ctx, diskByLabelPath+"config-2", util.ConfigDriveUserdataPath)
+		}},
+		{"config drive (CONFIG-2)", func(ctx context.Context) ([]byte, error) {
+			return util.ReadConfigDrive(logger, ctx, diskByLabelPath+"CONFIG-2", util.ConfigDriveUserdataPath)
+		}},
+		{"metadata service", func(ctx context.Context) ([]byte, error) {
+			return fetchConfigFromMetadataService(logger, client, ctx)
+		}},
 	})
-
-	<-ctx.Done()
-	if ctx.Err() == context.DeadlineExceeded {
-		logger.Info("neither config drive nor metadata service were available in time. Continuing without a config...")
-	}
-
-	return config.Parse(data)
 }
 
-func fileExists(path string) bool {
-	_, err := os.Stat(path)
-	return (err == nil)
-}
+// fetchFirst queries every source at once and parses the userdata of the
+// first one to respond, cancelling the others. A source which responds
+// without userdata, such as a config drive lacking user_data, is an empty
+// config.
+func fetchFirst(logger *log.Logger, ctx context.Context, sources []source) (types.Config, report.Report, error) {
+	ctx, cancel := context.WithTimeout(ctx, fetchTimeout)
+	defer cancel()
+
+	type result struct {
+		name string
+		data []byte
+		err  error
+	}
+	results := make(chan result, len(sources))
+	for _, s := range sources {
+		go func(s source) {
+			data, err := s.fetch(ctx)
+			results <- result{name: s.name, data: data, err: err}
+		}(s)
+	}
 
-func fetchConfigFromDevice(logger *log.Logger, ctx context.Context, path string) ([]byte, error) {
-	for !fileExists(path) {
-		logger.Debug("config drive (%q) not found. Waiting...", path)
+	var data []byte
+wait:
+	for pending := len(sources); pending > 0; pending-- {
 		select {
-		case <-time.After(time.Second):
+		case r := <-results:
+			if r.err != nil {
+				logger.Err("failed to fetch config from %s: %v", r.name, r.err)
+				continue
+			}
+			logger.Info("using config from %s", r.name)
+			data = r.data
+			break wait
 		case <-ctx.Done():
-			return nil, ctx.Err()
+			logger.Info("neither config drive nor metadata service were available in time. Continuing without a config...")
+			break wait
 		}
 	}
 
-	logger.Debug("creating temporary mount point")
-	mnt, err := ioutil.TempDir("", "ignition-configdrive")
-	if err != nil {
-		return nil, fmt.Errorf("failed to create temp directory: %v", err)
-	}
-	defer os.Remove(mnt)
-
-	cmd := exec.Command("/usr/bin/mount", "-o", "ro", "-t", "auto", path, mnt)
-	if err := logger.LogCmd(cmd, "mounting config drive"); err != nil {
-		return nil, err
-	}
-	defer logger.LogOp(
-		func() error { return syscall.Unmount(mnt, 0) },
-		"unmounting %q at %q", path, mnt,
-	)
-
-	if !fileExists(filepath.Join(mnt, configDriveUserdataPath)) {
-		return nil, nil
-	}
-
-	return ioutil.ReadFile(filepath.Join(mnt, configDriveUserdataPath))
+	return util.ParseConfig(logger, data)
 }
 
 func fetchConfigFromMetadataService(logger *log.Logger, client *resource.HttpClient, ctx context.Context) ([]byte, error) {
-	return resource.FetchConfig(logger, client, context.Background(), metadataServiceUrl)
+	return resource.FetchConfig(logger, client, ctx, metadataServiceUrl)
 }
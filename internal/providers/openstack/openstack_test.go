@@ -0,0 +1,85 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package openstack
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/coreos/ignition/config"
+	"github.com/coreos/ignition/internal/log"
+
+	"golang.org/x/net/context"
+)
+
+func TestFetchFirst(t *testing.T) {
+	type in struct {
+		sources []source
+	}
+	type out struct {
+		files int
+		err   error
+	}
+
+	const cfg = `{"ignition":{"version":"2.1.0-experimental"},"storage":{"files":[{"filesystem":"root","path":"/a"}]}}`
+	respond := func(delay time.Duration, data string, err error) func(context.Context) ([]byte, error) {
+		return func(ctx context.Context) ([]byte, error) {
+			select {
+			case <-time.After(delay):
+				return []byte(data), err
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+	}
+	never := func(ctx context.Context) ([]byte, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{sources: []source{{"drive", never}, {"metadata", respond(0, cfg, nil)}}},
+			out: out{files: 1},
+		},
+		{
+			in:  in{sources: []source{{"drive", respond(0, "", errors.New("mount failed"))}, {"metadata", respond(10*time.Millisecond, cfg, nil)}}},
+			out: out{files: 1},
+		},
+		{
+			in:  in{sources: []source{{"drive", respond(0, "", nil)}, {"metadata", respond(10*time.Millisecond, cfg, nil)}}},
+			out: out{err: config.ErrEmpty},
+		},
+		{
+			in:  in{sources: []source{{"drive", respond(0, "", errors.New("mount failed"))}}},
+			out: out{err: config.ErrEmpty},
+		},
+	}
+
+	logger := log.New()
+	for i, test := range tests {
+		cfg, _, err := fetchFirst(&logger, context.Background(), test.in.sources)
+		if err != test.out.err {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.out.err, err)
+		}
+		if len(cfg.Storage.Files) != test.out.files {
+			t.Errorf("#%d: bad files: want %d, got %d", i, test.out.files, len(cfg.Storage.Files))
+		}
+	}
+}
@@ -37,10 +37,10 @@ var (
 	}
 )
 
-func FetchConfig(logger *log.Logger, client *resource.HttpClient) (types.Config, report.Report, error) {
+func FetchConfig(logger *log.Logger, client *resource.HttpClient, ctx context.Context) (types.Config, report.Report, error) {
 	// TODO: Packet's metadata service returns "Not Acceptable" when queried
 	// with the default headers. For now, just do a regular fetch.
-	data, err := resource.Fetch(logger, client, context.Background(), userdataUrl)
+	data, err := resource.Fetch(logger, client, ctx, userdataUrl)
 	if err != nil {
 		return types.Config{}, report.Report{}, err
 	}
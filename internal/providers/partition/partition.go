@@ -0,0 +1,83 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// The partition provider fetches a local configuration from a file on a
+// partition, found by its label, so that machines can be provisioned offline
+// from prepared media. The label defaults to OEM and may be overridden with
+// the kernel boot option "coreos.config.partition".
+
+package partition
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/coreos/ignition/config/types"
+	"github.com/coreos/ignition/config/validate/report"
+	"github.com/coreos/ignition/internal/log"
+	"github.com/coreos/ignition/internal/providers/cmdline"
+	"github.com/coreos/ignition/internal/providers/util"
+	"github.com/coreos/ignition/internal/resource"
+	"github.com/coreos/ignition/internal/systemd"
+
+	"golang.org/x/net/context"
+)
+
+const (
+	defaultLabel = "OEM"
+	configPath   = "/config.ign"
+)
+
+// fstypes are the filesystems tried, in order, when mounting the partition.
+var fstypes = []string{"ext4", "vfat", "iso9660", "btrfs", "xfs"}
+
+func FetchConfig(logger *log.Logger, _ *resource.HttpClient, _ context.Context) (types.Config, report.Report, error) {
+	label, ok := cmdline.FlagValue(logger, cmdline.PartitionFlag)
+	if !ok || label == "" {
+		label = defaultLabel
+	}
+	device := filepath.Join("/dev/disk/by-label", label)
+
+	logger.Debug("waiting for config partition %q...", device)
+	if err := systemd.WaitOnDevices([]string{device}, "partition"); err != nil {
+		return types.Config{}, report.Report{}, fmt.Errorf("failed to wait for device %q: %v", device, err)
+	}
+
+	mnt, err := ioutil.TempDir("", "ignition-partition")
+	if err != nil {
+		return types.Config{}, report.Report{}, fmt.Errorf("failed to create temp directory: %v", err)
+	}
+	defer os.Remove(mnt)
+
+	if err := util.MountReadOnly(logger, device, mnt, fstypes); err != nil {
+		return types.Config{}, report.Report{}, err
+	}
+	defer logger.LogOp(
+		func() error { return syscall.Unmount(mnt, 0) },
+		"unmounting %q at %q", device, mnt,
+	)
+
+	logger.Debug("reading config")
+	rawConfig, err := ioutil.ReadFile(filepath.Join(mnt, configPath))
+	if os.IsNotExist(err) {
+		logger.Info("%q was not found on %q. Ignoring...", configPath, device)
+	} else if err != nil {
+		return types.Config{}, report.Report{}, fmt.Errorf("failed to read config: %v", err)
+	}
+
+	return util.ParseConfig(logger, rawConfig)
+}
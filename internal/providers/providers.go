@@ -21,10 +21,48 @@ import (
 	"github.com/coreos/ignition/config/validate/report"
 	"github.com/coreos/ignition/internal/log"
 	"github.com/coreos/ignition/internal/resource"
+
+	"golang.org/x/net/context"
 )
 
 var (
 	ErrNoProvider = errors.New("config provider was not online")
 )
 
-type FuncFetchConfig func(logger *log.Logger, client *resource.HttpClient) (types.Config, report.Report, error)
+// UserdataError is returned by providers whose userdata is a cloud-config or
+// a script, as given by Err, rather than an Ignition config. It carries the
+// userdata so that it can be handed off to another agent.
+type UserdataError struct {
+	Err  error
+	Data []byte
+}
+
+func (e UserdataError) Error() string {
+	return e.Err.Error()
+}
+
+// The names of the platform metadata attributes.
+const (
+	MetadataInstanceId  = "INSTANCE_ID"
+	MetadataRegion      = "REGION"
+	MetadataZone        = "ZONE"
+	MetadataIPv4Private = "IPV4_PRIVATE"
+	MetadataIPv4Public  = "IPV4_PUBLIC"
+)
+
+// FuncFetchConfig fetches the config from a platform's provider. Providers
+// which wait for the config to become available give up when ctx is done.
+type FuncFetchConfig func(logger *log.Logger, client *resource.HttpClient, ctx context.Context) (types.Config, report.Report, error)
+
+// FuncDeleteConfig deletes the config from the platform, so that secrets
+// it holds don't linger in the machine's metadata once it is provisioned.
+type FuncDeleteConfig func(logger *log.Logger) error
+
+// FuncReportReady tells the platform that the machine has been provisioned,
+// for platforms which wait for that before considering it deployed.
+type FuncReportReady func(logger *log.Logger, client *resource.HttpClient, ctx context.Context) error
+
+// FuncFetchMetadata fetches the platform metadata attributes of the machine,
+// keyed by the names above. Attributes the platform doesn't provide for the
+// machine are omitted.
+type FuncFetchMetadata func(logger *log.Logger, client *resource.HttpClient, ctx context.Context) (map[string]string, error)
@@ -0,0 +1,60 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// The proxmoxve provider fetches a local configuration from the user-data on
+// the cloud-init drive (an ISO labeled cidata) which Proxmox VE attaches to
+// virtual machines. With the default cloud-init settings, Proxmox VE writes a
+// cloud-config there; an Ignition config is supplied as a custom user-data
+// snippet with `qm set <vmid> --cicustom user=<storage>:snippets/<file>`.
+
+package proxmoxve
+
+import (
+	"time"
+
+	"github.com/coreos/ignition/config/types"
+	"github.com/coreos/ignition/config/validate/report"
+	"github.com/coreos/ignition/internal/log"
+	"github.com/coreos/ignition/internal/providers/util"
+	"github.com/coreos/ignition/internal/resource"
+
+	"golang.org/x/net/context"
+)
+
+const (
+	cloudInitDrivePath = "/dev/disk/by-label/cidata"
+	userdataPath       = "/user-data"
+
+	// driveTimeout bounds the wait for the cloud-init drive, which virtual
+	// machines without a CloudInit device lack.
+	driveTimeout = 30 * time.Second
+)
+
+func FetchConfig(logger *log.Logger, _ *resource.HttpClient, ctx context.Context) (types.Config, report.Report, error) {
+	driveCtx, cancel := context.WithTimeout(ctx, driveTimeout)
+	defer cancel()
+
+	data, err := util.ReadConfigDrive(logger, driveCtx, cloudInitDrivePath, userdataPath)
+	// Only the drive's own timeout means there's no config.
+	if ctx.Err() != nil {
+		return types.Config{}, report.Report{}, ctx.Err()
+	}
+	if err == context.DeadlineExceeded {
+		logger.Info("cloud-init drive was not found. Continuing without a config...")
+	} else if err != nil {
+		return types.Config{}, report.Report{}, err
+	}
+
+	return util.ParseConfig(logger, data)
+}
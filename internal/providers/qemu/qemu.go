@@ -20,22 +20,27 @@ package qemu
 import (
 	"io/ioutil"
 	"os"
-	"os/exec"
 
 	"github.com/coreos/ignition/config/types"
 	"github.com/coreos/ignition/config/validate/report"
 	"github.com/coreos/ignition/internal/log"
 	"github.com/coreos/ignition/internal/providers/util"
 	"github.com/coreos/ignition/internal/resource"
+	"github.com/coreos/ignition/internal/tool"
+
+	"golang.org/x/net/context"
 )
 
 const (
-	firmwareConfigPath = "/sys/firmware/qemu_fw_cfg/by_name/opt/com.coreos/config/raw"
+	firmwareConfigDir  = "/sys/firmware/qemu_fw_cfg"
+	firmwareConfigPath = firmwareConfigDir + "/by_name/opt/com.coreos/config/raw"
 )
 
-func FetchConfig(logger *log.Logger, _ *resource.HttpClient) (types.Config, report.Report, error) {
-	err := logger.LogCmd(exec.Command("modprobe", "qemu_fw_cfg"), "loading QEMU firmware config module")
-	if err != nil {
+func FetchConfig(logger *log.Logger, _ *resource.HttpClient, _ context.Context) (types.Config, report.Report, error) {
+	// The driver may be built into the kernel, in which case there is no
+	// module to load.
+	err := logger.LogCmd(tool.Modprobe.Command("qemu_fw_cfg"), "loading QEMU firmware config module")
+	if _, statErr := os.Stat(firmwareConfigDir); err != nil && statErr != nil {
 		return types.Config{}, report.Report{}, err
 	}
 
@@ -16,6 +16,8 @@ package util
 
 import (
 	"time"
+
+	"golang.org/x/net/context"
 )
 
 // ExpBackoff calculates an exponential (power 2) backoff given the last
@@ -28,3 +30,29 @@ func ExpBackoff(backoff *time.Duration, maxBackoff time.Duration) time.Duration
 	}
 	return *backoff
 }
+
+// Poll calls check every interval until it reports that it is done or fails,
+// or until ctx is done, in which case the context's error is returned.
+func Poll(ctx context.Context, interval time.Duration, check func() (bool, error)) error {
+	for {
+		if done, err := check(); done || err != nil {
+			return err
+		}
+		select {
+		case <-time.After(interval):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Sleep waits for d, returning early with the context's error if ctx is done
+// first.
+func Sleep(ctx context.Context, d time.Duration) error {
+	select {
+	case <-time.After(d):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+func TestPoll(t *testing.T) {
+	type in struct {
+		doneAfter int
+		err       error
+	}
+	type out struct {
+		calls int
+		err   error
+	}
+
+	errCheck := errors.New("check failed")
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{doneAfter: 1},
+			out: out{calls: 1},
+		},
+		{
+			in:  in{doneAfter: 3},
+			out: out{calls: 3},
+		},
+		{
+			in:  in{err: errCheck},
+			out: out{calls: 1, err: errCheck},
+		},
+		{
+			in:  in{doneAfter: -1},
+			out: out{calls: -1, err: context.DeadlineExceeded},
+		},
+	}
+
+	for i, test := range tests {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		calls := 0
+		err := Poll(ctx, time.Millisecond, func() (bool, error) {
+			calls++
+			return calls == test.in.doneAfter, test.in.err
+		})
+		cancel()
+		if err != test.out.err {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.out.err, err)
+		}
+		if test.out.calls >= 0 && calls != test.out.calls {
+			t.Errorf("#%d: bad calls: want %d, got %d", i, test.out.calls, calls)
+		}
+	}
+}
@@ -15,14 +15,41 @@
 package util
 
 import (
+	stdcontext "context"
+
 	"github.com/coreos/ignition/config"
 	"github.com/coreos/ignition/config/types"
 	"github.com/coreos/ignition/config/validate/report"
+	"github.com/coreos/ignition/internal/exec/util"
 	"github.com/coreos/ignition/internal/log"
+	"github.com/coreos/ignition/internal/providers"
+	"github.com/coreos/ignition/internal/resource"
+
+	"golang.org/x/net/context"
 )
 
 func ParseConfig(logger *log.Logger, rawConfig []byte) (types.Config, report.Report, error) {
-	logger.Debug("parsing config: %s", string(rawConfig))
+	logger.Debug("parsing config (%d bytes)", len(rawConfig))
+
+	cfg, r, err := config.Parse(rawConfig)
+	switch err {
+	case nil:
+		logger.Debug("parsed config:\n%s", util.DumpConfig(cfg, types.Config{}))
+	case config.ErrCloudConfig, config.ErrScript:
+		return cfg, r, providers.UserdataError{Err: err, Data: rawConfig}
+	}
+	return cfg, r, err
+}
 
-	return config.Parse(rawConfig)
+// FetchRawFunc adapts fetch, which returns the raw config and gives up when
+// its standard library context is done, to a provider's fetch function, so
+// that code outside this tree can supply configs.
+func FetchRawFunc(fetch func(ctx stdcontext.Context) ([]byte, error)) providers.FuncFetchConfig {
+	return func(logger *log.Logger, _ *resource.HttpClient, ctx context.Context) (types.Config, report.Report, error) {
+		rawConfig, err := fetch(ctx)
+		if err != nil {
+			return types.Config{}, report.Report{}, err
+		}
+		return ParseConfig(logger, rawConfig)
+	}
 }
@@ -0,0 +1,75 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/coreos/ignition/internal/log"
+	"github.com/coreos/ignition/internal/tool"
+
+	"golang.org/x/net/context"
+)
+
+// ConfigDriveUserdataPath is where an OpenStack-style config drive keeps the
+// userdata.
+const ConfigDriveUserdataPath = "/openstack/latest/user_data"
+
+// ReadConfigDrive waits for the config drive at device to appear, mounts it
+// read-only, and returns the userdata it holds at path, which is empty if the
+// drive has none.
+func ReadConfigDrive(logger *log.Logger, ctx context.Context, device, path string) ([]byte, error) {
+	if err := Poll(ctx, time.Second, func() (bool, error) {
+		if fileExists(device) {
+			return true, nil
+		}
+		logger.Debug("config drive (%q) not found. Waiting...", device)
+		return false, nil
+	}); err != nil {
+		return nil, err
+	}
+
+	logger.Debug("creating temporary mount point")
+	mnt, err := ioutil.TempDir("", "ignition-configdrive")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp directory: %v", err)
+	}
+	defer os.Remove(mnt)
+
+	cmd := tool.Mount.Command("-o", "ro", "-t", "auto", device, mnt)
+	if err := logger.LogCmd(cmd, "mounting config drive"); err != nil {
+		return nil, err
+	}
+	defer logger.LogOp(
+		func() error { return syscall.Unmount(mnt, 0) },
+		"unmounting %q at %q", device, mnt,
+	)
+
+	if !fileExists(filepath.Join(mnt, path)) {
+		return []byte{}, nil
+	}
+
+	return ioutil.ReadFile(filepath.Join(mnt, path))
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return (err == nil)
+}
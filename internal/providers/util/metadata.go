@@ -0,0 +1,50 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/coreos/ignition/internal/log"
+	"github.com/coreos/ignition/internal/resource"
+
+	"golang.org/x/net/context"
+)
+
+// FetchMetadataAttributes fetches the attributes at the given paths, relative
+// to base, from a metadata service, returning them keyed by attribute name.
+// Attributes which aren't found are omitted.
+func FetchMetadataAttributes(logger *log.Logger, client *resource.HttpClient, ctx context.Context, base url.URL, header http.Header, paths map[string]string) (map[string]string, error) {
+	attrs := map[string]string{}
+	for name, p := range paths {
+		u := base
+		u.Path = path.Join(base.Path, p)
+		data, err := resource.FetchWithHeader(logger, client, ctx, u, header)
+		switch err {
+		case nil:
+			if value := strings.TrimSpace(string(data)); value != "" {
+				attrs[name] = value
+			}
+		case resource.ErrNotFound:
+			logger.Debug("metadata attribute %q not found", name)
+		default:
+			return nil, err
+		}
+	}
+	return attrs, nil
+}
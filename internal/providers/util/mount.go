@@ -0,0 +1,37 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"fmt"
+	"syscall"
+
+	"github.com/coreos/ignition/internal/log"
+)
+
+// MountReadOnly mounts device read-only at mnt, trying each of fstypes in
+// turn, and returns the error of the last attempt if none succeeds.
+func MountReadOnly(logger *log.Logger, device, mnt string, fstypes []string) error {
+	var err error
+	for _, fstype := range fstypes {
+		if err = logger.LogOp(
+			func() error { return syscall.Mount(device, mnt, fstype, syscall.MS_RDONLY, "") },
+			"mounting %q at %q as %s", device, mnt, fstype,
+		); err == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("failed to mount device %q at %q: %v", device, mnt, err)
+}
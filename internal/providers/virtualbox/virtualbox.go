@@ -0,0 +1,151 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// The virtualbox provider fetches a local configuration from the VirtualBox
+// guest property "/Ignition/Config", read with the Guest Additions, or,
+// failing that, from "config.ign" on an attached floppy or ISO labeled
+// "ignition".
+
+package virtualbox
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/coreos/ignition/config/types"
+	"github.com/coreos/ignition/config/validate/report"
+	"github.com/coreos/ignition/internal/log"
+	"github.com/coreos/ignition/internal/providers/util"
+	"github.com/coreos/ignition/internal/resource"
+	"github.com/coreos/ignition/internal/tool"
+
+	"golang.org/x/net/context"
+)
+
+const (
+	configProperty   = "/Ignition/Config"
+	encodingProperty = "/Ignition/Config/Encoding"
+	mediaConfigPath  = "/config.ign"
+
+	// mediaTimeout bounds the wait for the media to appear.
+	mediaTimeout = 10 * time.Second
+)
+
+// mediaPaths are the devices tried for the media. Floppies formatted with
+// FAT have their labels upper-cased.
+var mediaPaths = []string{"/dev/disk/by-label/ignition", "/dev/disk/by-label/IGNITION"}
+
+func FetchConfig(logger *log.Logger, _ *resource.HttpClient, ctx context.Context) (types.Config, report.Report, error) {
+	data, ok, err := readGuestProperties(logger)
+	if err != nil {
+		return types.Config{}, report.Report{}, err
+	}
+	if ok {
+		logger.Info("using config from guest property %q", configProperty)
+		return util.ParseConfig(logger, data)
+	}
+
+	data, err = readMedia(logger, ctx)
+	if err != nil {
+		return types.Config{}, report.Report{}, err
+	}
+	return util.ParseConfig(logger, data)
+}
+
+// readGuestProperties returns the config held by the guest properties, and
+// whether it is set. The config may be base64-encoded, in which case the
+// encoding property is set to "base64".
+func readGuestProperties(logger *log.Logger) ([]byte, bool, error) {
+	if !tool.VBoxControl.Available() {
+		logger.Info("Guest Additions are not installed; skipping guest properties")
+		return nil, false, nil
+	}
+
+	value, ok := getGuestProperty(logger, configProperty)
+	if !ok {
+		return nil, false, nil
+	}
+	encoding, _ := getGuestProperty(logger, encodingProperty)
+	switch encoding {
+	case "":
+		return []byte(value), true, nil
+	case "base64":
+		data, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to decode guest property %q: %v", configProperty, err)
+		}
+		return data, true, nil
+	default:
+		return nil, false, fmt.Errorf("unsupported encoding %q", encoding)
+	}
+}
+
+func getGuestProperty(logger *log.Logger, name string) (string, bool) {
+	// VBoxControl exits with failure when the property is unset, which is
+	// told apart by the lack of a value in its output.
+	out, err := tool.VBoxControl.Output(logger, "--nologo", "guestproperty", "get", name)
+	value, ok := parseGuestProperty(out)
+	if !ok && err != nil {
+		logger.Debug("guest property %q unavailable: %v", name, err)
+	}
+	return value, ok
+}
+
+// parseGuestProperty returns the value in the output of
+// "VBoxControl guestproperty get", and whether the property is set.
+func parseGuestProperty(out []byte) (string, bool) {
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		if line := scanner.Text(); strings.HasPrefix(line, "Value: ") {
+			return strings.TrimPrefix(line, "Value: "), true
+		}
+	}
+	return "", false
+}
+
+// readMedia returns the config on the first of mediaPaths to appear, or an
+// empty config if none does in time.
+func readMedia(logger *log.Logger, ctx context.Context) ([]byte, error) {
+	mediaCtx, cancel := context.WithTimeout(ctx, mediaTimeout)
+	defer cancel()
+
+	var media string
+	err := util.Poll(mediaCtx, time.Second, func() (bool, error) {
+		for _, path := range mediaPaths {
+			if _, err := os.Stat(path); err == nil {
+				media = path
+				return true, nil
+			}
+		}
+		return false, nil
+	})
+	// Only the media's own timeout means there's no config.
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	if err == context.DeadlineExceeded {
+		logger.Info("no config media was found. Continuing without a config...")
+		return []byte{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	logger.Info("using config from %q", media)
+	return util.ReadConfigDrive(logger, ctx, media, mediaConfigPath)
+}
@@ -0,0 +1,61 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package virtualbox
+
+import (
+	"testing"
+)
+
+func TestParseGuestProperty(t *testing.T) {
+	type in struct {
+		out string
+	}
+	type out struct {
+		value string
+		ok    bool
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{out: "Value: {\"ignition\":{}}\n"},
+			out: out{value: "{\"ignition\":{}}", ok: true},
+		},
+		{
+			in:  in{out: "Value: \n"},
+			out: out{value: "", ok: true},
+		},
+		{
+			in:  in{out: "No value set!\n"},
+			out: out{},
+		},
+		{
+			in:  in{out: ""},
+			out: out{},
+		},
+	}
+
+	for i, test := range tests {
+		value, ok := parseGuestProperty([]byte(test.in.out))
+		if ok != test.out.ok {
+			t.Errorf("#%d: bad ok: want %t, got %t", i, test.out.ok, ok)
+		}
+		if value != test.out.value {
+			t.Errorf("#%d: bad value: want %q, got %q", i, test.out.value, value)
+		}
+	}
+}
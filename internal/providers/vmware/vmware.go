@@ -25,6 +25,13 @@ import (
 	"strings"
 )
 
+// The guestinfo variables, less their "guestinfo." prefix, holding the config
+// and its encoding.
+const (
+	dataKey     = "coreos.config.data"
+	encodingKey = "coreos.config.data.encoding"
+)
+
 func decodeData(data string, encoding string) ([]byte, error) {
 	switch encoding {
 	case "":
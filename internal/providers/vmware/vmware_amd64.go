@@ -18,6 +18,8 @@
 package vmware
 
 import (
+	"fmt"
+
 	"github.com/coreos/ignition/config/types"
 	"github.com/coreos/ignition/config/validate/report"
 	"github.com/coreos/ignition/internal/log"
@@ -27,21 +29,23 @@ import (
 
 	"github.com/sigma/vmw-guestinfo/rpcvmx"
 	"github.com/sigma/vmw-guestinfo/vmcheck"
+
+	"golang.org/x/net/context"
 )
 
-func FetchConfig(logger *log.Logger, _ *resource.HttpClient) (types.Config, report.Report, error) {
+func FetchConfig(logger *log.Logger, _ *resource.HttpClient, _ context.Context) (types.Config, report.Report, error) {
 	if !vmcheck.IsVirtualWorld() {
 		return types.Config{}, report.Report{}, providers.ErrNoProvider
 	}
 
 	info := rpcvmx.NewConfig()
-	data, err := info.String("coreos.config.data", "")
+	data, err := info.String(dataKey, "")
 	if err != nil {
 		logger.Debug("failed to fetch config: %v", err)
 		return types.Config{}, report.Report{}, err
 	}
 
-	encoding, err := info.String("coreos.config.data.encoding", "")
+	encoding, err := info.String(encodingKey, "")
 	if err != nil {
 		logger.Debug("failed to fetch config encoding: %v", err)
 		return types.Config{}, report.Report{}, err
@@ -56,3 +60,20 @@ func FetchConfig(logger *log.Logger, _ *resource.HttpClient) (types.Config, repo
 	logger.Debug("config successfully fetched")
 	return util.ParseConfig(logger, decodedData)
 }
+
+// DeleteConfig clears the guestinfo variables holding the config. The guest
+// can't remove variables set by the host, but it can overwrite them.
+func DeleteConfig(logger *log.Logger) error {
+	if !vmcheck.IsVirtualWorld() {
+		return providers.ErrNoProvider
+	}
+
+	info := rpcvmx.NewConfig()
+	for _, key := range []string{dataKey, encodingKey} {
+		if err := info.SetString(key, ""); err != nil {
+			return fmt.Errorf("failed to clear guestinfo.%s: %v", key, err)
+		}
+		logger.Info("cleared guestinfo.%s", key)
+	}
+	return nil
+}
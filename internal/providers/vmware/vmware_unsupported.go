@@ -26,8 +26,16 @@ import (
 	"github.com/coreos/ignition/config/validate/report"
 	"github.com/coreos/ignition/internal/log"
 	"github.com/coreos/ignition/internal/resource"
+
+	"golang.org/x/net/context"
 )
 
-func FetchConfig(_ *log.Logger, _ *resource.HttpClient) (types.Config, report.Report, error) {
-	return types.Config{}, report.Report{}, errors.New("vmware provider is not supported on this architecture")
+var errUnsupported = errors.New("vmware provider is not supported on this architecture")
+
+func FetchConfig(_ *log.Logger, _ *resource.HttpClient, _ context.Context) (types.Config, report.Report, error) {
+	return types.Config{}, report.Report{}, errUnsupported
+}
+
+func DeleteConfig(_ *log.Logger) error {
+	return errUnsupported
 }
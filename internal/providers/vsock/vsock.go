@@ -0,0 +1,97 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// The vsock provider fetches a configuration over an AF_VSOCK connection to
+// the host, for microVMs that have neither a network nor a firmware config
+// interface. The host is expected to serve the raw config on configPort and
+// close the connection.
+
+//go:build !386
+// +build !386
+
+package vsock
+
+import (
+	"io/ioutil"
+	"os"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"github.com/coreos/ignition/config/types"
+	"github.com/coreos/ignition/config/validate/report"
+	"github.com/coreos/ignition/internal/log"
+	"github.com/coreos/ignition/internal/providers/util"
+	"github.com/coreos/ignition/internal/resource"
+	"github.com/coreos/ignition/internal/tool"
+
+	"golang.org/x/net/context"
+)
+
+const (
+	afVsock     = 40 // AF_VSOCK
+	hostCid     = 2  // VMADDR_CID_HOST
+	configPort  = 9999
+	readTimeout = 30 * time.Second
+)
+
+// sockaddrVM mirrors the kernel's struct sockaddr_vm.
+type sockaddrVM struct {
+	family    uint16
+	reserved1 uint16
+	port      uint32
+	cid       uint32
+	zero      [4]uint8
+}
+
+func FetchConfig(logger *log.Logger, _ *resource.HttpClient, _ context.Context) (types.Config, report.Report, error) {
+	err := logger.LogCmd(tool.Modprobe.Command("vmw_vsock_virtio_transport"), "loading vsock transport module")
+	if err != nil {
+		return types.Config{}, report.Report{}, err
+	}
+
+	var data []byte
+	err = logger.LogOp(func() (err error) {
+		data, err = fetch(hostCid, configPort)
+		return
+	}, "fetching config from vsock port %d", configPort)
+	if err != nil {
+		return types.Config{}, report.Report{}, err
+	}
+
+	return util.ParseConfig(logger, data)
+}
+
+// fetch connects to the given vsock address and reads until the peer closes
+// the connection.
+func fetch(cid, port uint32) ([]byte, error) {
+	fd, err := syscall.Socket(afVsock, syscall.SOCK_STREAM|syscall.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return nil, err
+	}
+	f := os.NewFile(uintptr(fd), "vsock")
+	defer f.Close()
+
+	tv := syscall.NsecToTimeval(int64(readTimeout))
+	if err := syscall.SetsockoptTimeval(fd, syscall.SOL_SOCKET, syscall.SO_RCVTIMEO, &tv); err != nil {
+		return nil, err
+	}
+
+	sa := sockaddrVM{family: afVsock, port: port, cid: cid}
+	if _, _, errno := syscall.Syscall(syscall.SYS_CONNECT, uintptr(fd), uintptr(unsafe.Pointer(&sa)), unsafe.Sizeof(sa)); errno != 0 {
+		return nil, errno
+	}
+
+	return ioutil.ReadAll(f)
+}
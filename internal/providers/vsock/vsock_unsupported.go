@@ -0,0 +1,36 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// The vsock provider fetches a configuration over an AF_VSOCK connection to
+// the host.
+
+//go:build 386
+// +build 386
+
+package vsock
+
+import (
+	"errors"
+
+	"github.com/coreos/ignition/config/types"
+	"github.com/coreos/ignition/config/validate/report"
+	"github.com/coreos/ignition/internal/log"
+	"github.com/coreos/ignition/internal/resource"
+
+	"golang.org/x/net/context"
+)
+
+func FetchConfig(_ *log.Logger, _ *resource.HttpClient, _ context.Context) (types.Config, report.Report, error) {
+	return types.Config{}, report.Report{}, errors.New("vsock provider is not supported on this architecture")
+}
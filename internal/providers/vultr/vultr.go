@@ -0,0 +1,54 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// The vultr provider fetches a remote configuration from the Vultr user-data
+// metadata service URL.
+
+package vultr
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/coreos/ignition/config/types"
+	"github.com/coreos/ignition/config/validate/report"
+	"github.com/coreos/ignition/internal/log"
+	"github.com/coreos/ignition/internal/providers/util"
+	"github.com/coreos/ignition/internal/resource"
+
+	"golang.org/x/net/context"
+)
+
+var (
+	userdataUrl = url.URL{
+		Scheme: "http",
+		Host:   "169.254.169.254",
+		Path:   "v1/user-data",
+	}
+	// The metadata service serves user-data as an opaque blob, so accept
+	// any content type rather than only Ignition's.
+	metadataHeader = http.Header{"Accept": []string{"*/*"}}
+)
+
+// FetchConfig fetches the user-data, which is empty if none was provided.
+// The client retries until the metadata service, which is only reachable
+// once the instance's link-local address is up, answers.
+func FetchConfig(logger *log.Logger, client *resource.HttpClient, ctx context.Context) (types.Config, report.Report, error) {
+	data, err := resource.FetchConfigWithHeader(logger, client, ctx, userdataUrl, metadataHeader)
+	if err != nil {
+		return types.Config{}, report.Report{}, err
+	}
+
+	return util.ParseConfig(logger, data)
+}
@@ -0,0 +1,103 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// The zvm provider fetches a local configuration from the spool file named
+// "ignition" on the virtual reader device of a z/VM guest, where it is
+// placed by punching it to the guest, e.g. with
+// "PUNCH CONFIG IGN A (NOHEADER" and "CHANGE RDR <spoolid> NAME IGNITION IGN".
+
+package zvm
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/coreos/ignition/config/types"
+	"github.com/coreos/ignition/config/validate/report"
+	"github.com/coreos/ignition/internal/log"
+	"github.com/coreos/ignition/internal/providers/util"
+	"github.com/coreos/ignition/internal/resource"
+	"github.com/coreos/ignition/internal/tool"
+
+	"golang.org/x/net/context"
+)
+
+const (
+	readerDevice = "000c"
+	spoolName    = "ignition"
+	vmurDriver   = "/sys/bus/ccw/drivers/vmur"
+)
+
+func FetchConfig(logger *log.Logger, _ *resource.HttpClient, _ context.Context) (types.Config, report.Report, error) {
+	if err := enableReader(logger); err != nil {
+		return types.Config{}, report.Report{}, err
+	}
+	defer logger.LogCmd(tool.Chccwdev.Command("-d", readerDevice), "disabling reader device %s", readerDevice)
+
+	list, err := tool.Vmur.Output(logger, "li")
+	if err != nil {
+		return types.Config{}, report.Report{}, fmt.Errorf("failed to list reader spool files: %v", err)
+	}
+	spoolId, ok := findSpoolFile(list, spoolName)
+	if !ok {
+		logger.Info("no spool file named %q is on the reader. Continuing without a config...", spoolName)
+		return util.ParseConfig(logger, nil)
+	}
+
+	// The file is received to stdout (-O) without conversion, and kept on
+	// the reader (-H) so that a forced rerun finds it again.
+	rawConfig, err := tool.Vmur.Output(logger, "re", "-f", "-H", "-O", spoolId)
+	if err != nil {
+		return types.Config{}, report.Report{}, fmt.Errorf("failed to receive spool file %s: %v", spoolId, err)
+	}
+
+	// Punched records are padded to their full length.
+	return util.ParseConfig(logger, bytes.TrimRight(rawConfig, "\x00 "))
+}
+
+// enableReader brings the reader device online, which the kernel ignores by
+// default on s390x.
+func enableReader(logger *log.Logger) error {
+	// The driver may be built into the kernel, in which case there is no
+	// module to load.
+	err := logger.LogCmd(tool.Modprobe.Command("vmur"), "loading vmur module")
+	if _, statErr := os.Stat(vmurDriver); err != nil && statErr != nil {
+		return err
+	}
+	if err := logger.LogCmd(tool.CioIgnore.Command("-r", readerDevice), "unignoring reader device %s", readerDevice); err != nil {
+		return err
+	}
+	return logger.LogCmd(tool.Chccwdev.Command("-e", readerDevice), "enabling reader device %s", readerDevice)
+}
+
+// findSpoolFile returns the spool ID of the first file named name in the
+// output of "vmur li", and whether there is one. Its lines have the fields
+// ORIGINID FILE CLASS RECORDS CPY HOLD DATE TIME NAME TYPE DIST, where CLASS
+// takes two fields (e.g. "A PUN").
+func findSpoolFile(list []byte, name string) (string, bool) {
+	scanner := bufio.NewScanner(bytes.NewReader(list))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 10 || fields[0] == "ORIGINID" {
+			continue
+		}
+		if strings.EqualFold(fields[9], name) {
+			return fields[1], true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,61 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package zvm
+
+import (
+	"testing"
+)
+
+func TestFindSpoolFile(t *testing.T) {
+	type in struct {
+		list string
+	}
+	type out struct {
+		id string
+		ok bool
+	}
+
+	const header = "ORIGINID FILE CLASS RECORDS  CPY HOLD DATE  TIME     NAME      TYPE      DIST\n"
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{list: header},
+			out: out{},
+		},
+		{
+			in: in{list: header +
+				"T6360025 0463 V DMP 00020222 001 NONE 06/11 15:07:42 VMDUMP    FILE      T6360025\n" +
+				"T6360025 0464 A PUN 00000012 001 NONE 06/11 15:08:01 IGNITION  IGN       T6360025\n"},
+			out: out{id: "0464", ok: true},
+		},
+		{
+			in:  in{list: header + "T6360025 0465 A PUN 00000012 001 NONE 06/11 15:08:01 CONFIG    IGN       T6360025\n"},
+			out: out{},
+		},
+	}
+
+	for i, test := range tests {
+		id, ok := findSpoolFile([]byte(test.in.list), spoolName)
+		if ok != test.out.ok {
+			t.Errorf("#%d: bad ok: want %t, got %t", i, test.out.ok, ok)
+		}
+		if id != test.out.id {
+			t.Errorf("#%d: bad id: want %q, got %q", i, test.out.id, id)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resource
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/ignition/config/types"
+
+	"golang.org/x/net/context"
+)
+
+// SetAuth sets the rules supplying credentials to fetches from matching URLs.
+func (c *HttpClient) SetAuth(rules []types.UrlAuth) {
+	c.auth = rules
+}
+
+// AddAuth adds rules to those already set, leaving copies of the client that
+// share the existing rules unaffected.
+func (c *HttpClient) AddAuth(rules []types.UrlAuth) {
+	c.auth = append(append([]types.UrlAuth{}, c.auth...), rules...)
+}
+
+// defaultTokenLifetime is how long a bearer token is reused when the
+// metadata service doesn't say when it expires.
+const defaultTokenLifetime = 5 * time.Minute
+
+// tokenCache holds the bearer tokens and IMDSv2 session tokens fetched by
+// every copy of a client, by metadata URL, until shortly before they expire.
+type tokenCache struct {
+	sync.Mutex
+	tokens map[string]cachedToken
+	// awsImdsV1 is set once the EC2 instance metadata service has given no
+	// session token, so that requests to it go without one.
+	awsImdsV1 bool
+}
+
+type cachedToken struct {
+	value   string
+	expires time.Time
+}
+
+// matchAuth returns the rule with the longest prefix of rawurl, or nil if no
+// rule matches. A prefix matches URLs with the same scheme and host whose
+// path lies beneath the prefix's, ending on a segment boundary, so that a
+// rule for https://example.com never matches https://example.com.evil.net.
+func matchAuth(rules []types.UrlAuth, rawurl string) *types.UrlAuth {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil
+	}
+	var match *types.UrlAuth
+	for i, rule := range rules {
+		if !prefixMatches(rule.Prefix, u) {
+			continue
+		}
+		if match == nil || len(rule.Prefix) > len(match.Prefix) {
+			match = &rules[i]
+		}
+	}
+	return match
+}
+
+// prefixMatches reports whether the auth prefix covers u.
+func prefixMatches(prefix string, u *url.URL) bool {
+	p, err := url.Parse(prefix)
+	if err != nil || prefix == "" || p.Host == "" {
+		return false
+	}
+	if !sameOrigin(p, u) {
+		return false
+	}
+	path := strings.TrimSuffix(p.Path, "/")
+	return path == "" || u.Path == path || strings.HasPrefix(u.Path, path+"/")
+}
+
+// sameOrigin reports whether a and b have the same scheme and host, to which
+// the credentials of a rule are confined.
+func sameOrigin(a, b *url.URL) bool {
+	return strings.EqualFold(a.Scheme, b.Scheme) && strings.EqualFold(a.Host, b.Host)
+}
+
+// authorize adds the credentials of the rule matching url to header, and
+// returns the name of the header it set, if any. Headers already set by the
+// caller are left untouched.
+func (c HttpClient) authorize(ctx context.Context, url string, header http.Header) (string, error) {
+	rule := matchAuth(c.auth, url)
+	if rule == nil {
+		return "", nil
+	}
+
+	switch {
+	case rule.Header != nil:
+		if header.Get(rule.Header.Name) == "" {
+			header.Set(rule.Header.Name, rule.Header.Value)
+			return rule.Header.Name, nil
+		}
+	case rule.Basic != nil:
+		if header.Get("Authorization") == "" {
+			req := http.Request{Header: header}
+			req.SetBasicAuth(rule.Basic.Username, rule.Basic.Password)
+			return "Authorization", nil
+		}
+	case rule.Bearer != nil:
+		if header.Get("Authorization") == "" {
+			token, err := c.bearerToken(ctx, rule.Bearer.Metadata.String(), rule.Bearer.HttpHeaders.Header())
+			if err != nil {
+				return "", err
+			}
+			header.Set("Authorization", "Bearer "+token)
+			return "Authorization", nil
+		}
+	}
+	return "", nil
+}
+
+// bearerToken returns the token from the metadata service at url, fetched
+// with header, reusing a cached one until shortly before it expires.
+func (c HttpClient) bearerToken(ctx context.Context, url string, header http.Header) (string, error) {
+	if c.tokens != nil {
+		c.tokens.Lock()
+		cached, ok := c.tokens.tokens[url]
+		c.tokens.Unlock()
+		if ok && time.Now().Before(cached.expires) {
+			return cached.value, nil
+		}
+	}
+
+	token, lifetime, err := c.fetchBearerToken(ctx, url, header)
+	if err != nil {
+		return "", err
+	}
+	if c.tokens != nil {
+		c.tokens.Lock()
+		c.tokens.tokens[url] = cachedToken{value: token, expires: time.Now().Add(lifetime)}
+		c.tokens.Unlock()
+	}
+	return token, nil
+}
+
+// fetchBearerToken fetches a token from the metadata service at url, sending
+// header, and returns it with how long it may be reused. The service may
+// return the bare token or a JSON object with an access_token field, as the
+// GCE metadata service does.
+func (c HttpClient) fetchBearerToken(ctx context.Context, url string, header http.Header) (string, time.Duration, error) {
+	// The token request itself is never authorized, which also prevents a
+	// rule from recursively matching its own metadata URL.
+	c.auth = nil
+	body, status, err := c.getReaderWithHeader(ctx, url, header)
+	if err != nil {
+		return "", 0, err
+	}
+	defer body.Close()
+	if status != http.StatusOK {
+		return "", 0, fmt.Errorf("failed to fetch bearer token from %q: %s", url, http.StatusText(status))
+	}
+	data, err := ioutil.ReadAll(body)
+	if err != nil {
+		return "", 0, err
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if json.Unmarshal(data, &token) == nil && token.AccessToken != "" {
+		return token.AccessToken, tokenLifetime(token.ExpiresIn), nil
+	}
+	if t := strings.TrimSpace(string(data)); t != "" {
+		return t, defaultTokenLifetime, nil
+	}
+	return "", 0, fmt.Errorf("empty bearer token from %q", url)
+}
+
+// tokenLifetime returns how long a token which expires in the given number
+// of seconds is reused, leaving a margin for the requests made with it.
+func tokenLifetime(expiresIn int) time.Duration {
+	if expiresIn <= 0 {
+		return defaultTokenLifetime
+	}
+	lifetime := time.Duration(expiresIn)*time.Second - 30*time.Second
+	if lifetime < 0 {
+		return 0
+	}
+	return lifetime
+}
@@ -0,0 +1,237 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resource
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/coreos/ignition/config/types"
+	"github.com/coreos/ignition/internal/log"
+	"github.com/coreos/ignition/internal/version"
+
+	"golang.org/x/net/context"
+)
+
+func TestMatchAuth(t *testing.T) {
+	rules := []types.UrlAuth{
+		{Prefix: "https://example.com/", Basic: &types.BasicAuth{Username: "a"}},
+		{Prefix: "https://example.com/private/", Basic: &types.BasicAuth{Username: "b"}},
+		{Prefix: "https://other.example.com/", Basic: &types.BasicAuth{Username: "c"}},
+		{Prefix: "https://segment.example.com/dir", Basic: &types.BasicAuth{Username: "d"}},
+	}
+
+	type in struct {
+		url string
+	}
+	type out struct {
+		user string
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{url: "https://example.com/config.ign"},
+			out: out{user: "a"},
+		},
+		{
+			in:  in{url: "https://example.com/private/config.ign"},
+			out: out{user: "b"},
+		},
+		{
+			in:  in{url: "https://other.example.com/x"},
+			out: out{user: "c"},
+		},
+		{
+			in:  in{url: "https://example.org/config.ign"},
+			out: out{user: ""},
+		},
+		{
+			in:  in{url: "https://example.com.example.net/config.ign"},
+			out: out{user: ""},
+		},
+		{
+			in:  in{url: "http://example.com/config.ign"},
+			out: out{user: ""},
+		},
+		{
+			in:  in{url: "https://EXAMPLE.com/config.ign"},
+			out: out{user: "a"},
+		},
+		{
+			in:  in{url: "https://segment.example.com/dir"},
+			out: out{user: "d"},
+		},
+		{
+			in:  in{url: "https://segment.example.com/dir/config.ign"},
+			out: out{user: "d"},
+		},
+		{
+			in:  in{url: "https://segment.example.com/directory/config.ign"},
+			out: out{user: ""},
+		},
+	}
+
+	for i, test := range tests {
+		user := ""
+		if rule := matchAuth(rules, test.in.url); rule != nil {
+			user = rule.Basic.Username
+		}
+		if user != test.out.user {
+			t.Errorf("#%d: bad rule: want %q, got %q", i, test.out.user, user)
+		}
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	tokens := 0
+	metadata := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Metadata-Flavor") != "Google" {
+			http.Error(w, "missing Metadata-Flavor", http.StatusForbidden)
+			return
+		}
+		tokens++
+		fmt.Fprint(w, `{"access_token":"tok","expires_in":3599,"token_type":"Bearer"}`)
+	}))
+	defer metadata.Close()
+	metadataUrl, err := url.Parse(metadata.URL)
+	if err != nil {
+		t.Fatalf("failed to parse URL: %v", err)
+	}
+
+	logger := log.New()
+	c := NewHttpClient(&logger)
+	c.SetAuth([]types.UrlAuth{
+		{Prefix: "https://header.example.com/", Header: &types.AuthHeader{Name: "X-Api-Key", Value: "key"}},
+		{Prefix: "https://basic.example.com/", Basic: &types.BasicAuth{Username: "user", Password: "pass"}},
+		{Prefix: "https://bearer.example.com/", Bearer: &types.BearerAuth{
+			Metadata:    types.Url(*metadataUrl),
+			HttpHeaders: types.HttpHeaders{{Name: "Metadata-Flavor", Value: "Google"}},
+		}},
+	})
+
+	type in struct {
+		url    string
+		header http.Header
+	}
+	type out struct {
+		header http.Header
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{url: "https://header.example.com/a", header: http.Header{}},
+			out: out{header: http.Header{"X-Api-Key": {"key"}}},
+		},
+		{
+			in:  in{url: "https://header.example.com/a", header: http.Header{"X-Api-Key": {"mine"}}},
+			out: out{header: http.Header{"X-Api-Key": {"mine"}}},
+		},
+		{
+			in:  in{url: "https://basic.example.com/a", header: http.Header{}},
+			out: out{header: http.Header{"Authorization": {"Basic dXNlcjpwYXNz"}}},
+		},
+		{
+			in:  in{url: "https://bearer.example.com/a", header: http.Header{}},
+			out: out{header: http.Header{"Authorization": {"Bearer tok"}}},
+		},
+		{
+			in:  in{url: "https://bearer.example.com/b", header: http.Header{}},
+			out: out{header: http.Header{"Authorization": {"Bearer tok"}}},
+		},
+		{
+			in:  in{url: "https://example.com/a", header: http.Header{}},
+			out: out{header: http.Header{}},
+		},
+	}
+
+	for i, test := range tests {
+		if _, err := c.authorize(context.Background(), test.in.url, test.in.header); err != nil {
+			t.Errorf("#%d: unexpected error: %v", i, err)
+			continue
+		}
+		if fmt.Sprint(test.in.header) != fmt.Sprint(test.out.header) {
+			t.Errorf("#%d: bad header: want %v, got %v", i, test.out.header, test.in.header)
+		}
+	}
+	if tokens != 1 {
+		t.Errorf("bad token fetches: want 1, got %d", tokens)
+	}
+}
+
+func TestAuthRedirect(t *testing.T) {
+	type in struct {
+		target string
+	}
+	type out struct {
+		header string
+	}
+
+	var seen string
+	record := func(r *http.Request) {
+		seen = r.Header.Get("X-Api-Key") + "," + r.Header.Get("Authorization") + "," + r.Header.Get("User-Agent")
+	}
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		record(r)
+	}))
+	defer other.Close()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, r.URL.Query().Get("to"), http.StatusFound)
+			return
+		}
+		record(r)
+	}))
+	defer server.Close()
+
+	userAgent := "Ignition/" + version.Raw
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{target: server.URL + "/config.ign"},
+			out: out{header: "key,Bearer config," + userAgent},
+		},
+		{
+			in:  in{target: other.URL + "/config.ign"},
+			out: out{header: ",," + userAgent},
+		},
+	}
+
+	logger := log.New()
+	c := NewHttpClient(&logger)
+	c.SetAuth([]types.UrlAuth{{Prefix: server.URL + "/", Header: &types.AuthHeader{Name: "X-Api-Key", Value: "key"}}})
+	for i, test := range tests {
+		seen = "unset"
+		body, _, err := c.getReaderWithHeader(context.Background(), server.URL+"/redirect?to="+url.QueryEscape(test.in.target), http.Header{"Authorization": {"Bearer config"}})
+		if err != nil {
+			t.Errorf("#%d: unexpected error: %v", i, err)
+			continue
+		}
+		body.Close()
+		if seen != test.out.header {
+			t.Errorf("#%d: bad header after redirect: want %q, got %q", i, test.out.header, seen)
+		}
+	}
+}
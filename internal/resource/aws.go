@@ -0,0 +1,260 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resource
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+const (
+	awsMetadataBase = "http://169.254.169.254/latest/meta-data/"
+	awsDateFormat   = "20060102T150405Z"
+
+	// AwsTokenHeader carries the IMDSv2 session token of a request to the
+	// instance metadata service.
+	AwsTokenHeader    = "X-aws-ec2-metadata-token"
+	awsTokenTtlHeader = "X-aws-ec2-metadata-token-ttl-seconds"
+	awsTokenTtl       = "21600"
+
+	// awsTokenLifetime is how long a session token is reused: the TTL it
+	// is requested with, less a margin for the requests in flight when
+	// it expires.
+	awsTokenLifetime = 21600*time.Second - time.Minute
+)
+
+// awsTokenUrl is where IMDSv2 session tokens are requested.
+var awsTokenUrl = "http://169.254.169.254/latest/api/token"
+
+// awsCredentials are the temporary credentials of an instance profile.
+type awsCredentials struct {
+	AccessKeyId     string
+	SecretAccessKey string
+	Token           string
+}
+
+// AwsMetadataHeader returns the header of requests to the EC2 instance
+// metadata service, carrying an IMDSv2 session token. If no token can be had,
+// the requests go without one, as IMDSv1 allows, unless the client requires
+// IMDSv2; instances which enforce IMDSv2 reject them. Every copy of the
+// client reuses the token until shortly before it expires, and once one has
+// fallen back to IMDSv1, the others do without asking again.
+func (c HttpClient) AwsMetadataHeader(ctx context.Context) (http.Header, error) {
+	if c.tokens != nil {
+		c.tokens.Lock()
+		cached, ok := c.tokens.tokens[awsTokenUrl]
+		imdsV1 := c.tokens.awsImdsV1
+		c.tokens.Unlock()
+		if ok && time.Now().Before(cached.expires) {
+			return http.Header{AwsTokenHeader: {cached.value}}, nil
+		}
+		if imdsV1 && !c.requireImdsV2 {
+			return http.Header{}, nil
+		}
+	}
+
+	requested := time.Now()
+	token, err := c.fetchAwsToken(ctx)
+	switch {
+	case err == nil:
+		if c.tokens != nil {
+			c.tokens.Lock()
+			c.tokens.tokens[awsTokenUrl] = cachedToken{value: token, expires: requested.Add(awsTokenLifetime)}
+			c.tokens.Unlock()
+		}
+		return http.Header{AwsTokenHeader: {token}}, nil
+	case err == ErrNeedNet, err == context.Canceled, err == context.DeadlineExceeded:
+		return nil, err
+	case c.requireImdsV2:
+		return nil, err
+	}
+	c.logger.Warning("falling back to IMDSv1: %v", err)
+	if c.tokens != nil {
+		c.tokens.Lock()
+		c.tokens.awsImdsV1 = true
+		c.tokens.Unlock()
+	}
+	return http.Header{}, nil
+}
+
+// fetchAwsToken requests an IMDSv2 session token.
+func (c HttpClient) fetchAwsToken(ctx context.Context) (string, error) {
+	resp, err := c.PutWithHeader(ctx, awsTokenUrl, nil, http.Header{awsTokenTtlHeader: {awsTokenTtl}})
+	switch err {
+	case nil:
+	case ErrTimedOut, ErrAttemptsExhausted:
+		// The metadata service sends its responses with the instance's
+		// hop limit as their TTL, so they never arrive if they would
+		// take more hops, e.g. into a container.
+		return "", fmt.Errorf("no answer to the IMDSv2 token request; the instance's metadata hop limit may be too low: %v", err)
+	default:
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to fetch IMDSv2 token: %s", http.StatusText(resp.StatusCode))
+	}
+	token, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(token)), nil
+}
+
+// fetchAwsMetadata returns the instance metadata at path.
+func (c HttpClient) fetchAwsMetadata(ctx context.Context, path string) (string, error) {
+	header, err := c.AwsMetadataHeader(ctx)
+	if err != nil {
+		return "", err
+	}
+	body, status, err := c.getReaderWithHeader(ctx, awsMetadataBase+path, header)
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+	if status != http.StatusOK {
+		return "", fmt.Errorf("failed to fetch instance metadata %q: %s", path, http.StatusText(status))
+	}
+	data, err := ioutil.ReadAll(body)
+	return strings.TrimSpace(string(data)), err
+}
+
+// fetchAwsCredentials returns the credentials of the instance profile of the
+// machine.
+func (c HttpClient) fetchAwsCredentials(ctx context.Context) (awsCredentials, error) {
+	roles, err := c.fetchAwsMetadata(ctx, "iam/security-credentials/")
+	if err != nil {
+		return awsCredentials{}, err
+	}
+	scanner := bufio.NewScanner(strings.NewReader(roles))
+	if !scanner.Scan() || scanner.Text() == "" {
+		return awsCredentials{}, fmt.Errorf("no instance profile found")
+	}
+
+	data, err := c.fetchAwsMetadata(ctx, "iam/security-credentials/"+scanner.Text())
+	if err != nil {
+		return awsCredentials{}, err
+	}
+	var creds awsCredentials
+	if err := json.Unmarshal([]byte(data), &creds); err != nil {
+		return awsCredentials{}, fmt.Errorf("failed to parse instance profile credentials: %v", err)
+	}
+	return creds, nil
+}
+
+// fetchAwsRegion returns the region of the machine.
+func (c HttpClient) fetchAwsRegion(ctx context.Context) (string, error) {
+	zone, err := c.fetchAwsMetadata(ctx, "placement/availability-zone")
+	if err != nil {
+		return "", err
+	}
+	if len(zone) < 2 {
+		return "", fmt.Errorf("invalid availability zone %q", zone)
+	}
+	// Availability zones are named after their region, e.g. us-east-1a.
+	return zone[:len(zone)-1], nil
+}
+
+// signAwsRequest adds the headers signing a request to the service in region
+// with Signature Version 4 to header. The signed headers are Host, any
+// Content-Type, and every X-Amz-* header.
+func signAwsRequest(method string, u url.URL, header http.Header, body []byte, creds awsCredentials, region, service string, now time.Time) {
+	amzDate := now.UTC().Format(awsDateFormat)
+	date := amzDate[:8]
+	header.Set("X-Amz-Date", amzDate)
+	if creds.Token != "" {
+		header.Set("X-Amz-Security-Token", creds.Token)
+	}
+
+	canonical := map[string]string{"host": u.Host}
+	for name, values := range header {
+		lower := strings.ToLower(name)
+		if lower == "content-type" || strings.HasPrefix(lower, "x-amz-") {
+			canonical[lower] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := []string{}
+	for name := range canonical {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	headers := &bytes.Buffer{}
+	for _, name := range names {
+		fmt.Fprintf(headers, "%s:%s\n", name, canonical[name])
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	payloadHash := sha256.Sum256(body)
+	request := strings.Join([]string{
+		method,
+		path,
+		awsCanonicalQuery(u.Query()),
+		headers.String(),
+		signedHeaders,
+		hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+
+	scope := strings.Join([]string{date, region, service, "aws4_request"}, "/")
+	requestHash := sha256.Sum256([]byte(request))
+	toSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, hex.EncodeToString(requestHash[:])}, "\n")
+
+	key := []byte("AWS4" + creds.SecretAccessKey)
+	for _, part := range []string{date, region, service, "aws4_request"} {
+		key = awsHmac(key, part)
+	}
+	signature := hex.EncodeToString(awsHmac(key, toSign))
+
+	header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		creds.AccessKeyId, scope, signedHeaders, signature))
+}
+
+func awsHmac(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// awsCanonicalQuery returns query sorted by key and value and encoded as
+// Signature Version 4 requires.
+func awsCanonicalQuery(query url.Values) string {
+	escape := func(s string) string {
+		return strings.Replace(url.QueryEscape(s), "+", "%20", -1)
+	}
+	pairs := []string{}
+	for key, values := range query {
+		for _, value := range values {
+			pairs = append(pairs, escape(key)+"="+escape(value))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
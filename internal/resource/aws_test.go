@@ -0,0 +1,209 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resource
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/coreos/ignition/internal/log"
+
+	"golang.org/x/net/context"
+)
+
+func TestSignAwsRequest(t *testing.T) {
+	// Example request from the AWS Signature Version 4 documentation.
+	u, err := url.Parse("https://iam.amazonaws.com/?Action=ListUsers&Version=2010-05-08")
+	if err != nil {
+		t.Fatalf("failed to parse URL: %v", err)
+	}
+	header := http.Header{}
+	header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	creds := awsCredentials{
+		AccessKeyId:     "AKIDEXAMPLE",
+		SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+	}
+	now := time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+
+	signAwsRequest("GET", *u, header, nil, creds, "us-east-1", "iam", now)
+
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/iam/aws4_request, SignedHeaders=content-type;host;x-amz-date, Signature=5d672d79c15b13162d9279b0855cfba6789a8edb4c82c400e06b5924a6f2b5d7"
+	if got := header.Get("Authorization"); got != want {
+		t.Errorf("bad authorization: want %q, got %q", want, got)
+	}
+	if got := header.Get("X-Amz-Date"); got != "20150830T123600Z" {
+		t.Errorf("bad date: want %q, got %q", "20150830T123600Z", got)
+	}
+}
+
+func TestSsmParameterName(t *testing.T) {
+	type in struct {
+		u string
+	}
+	type out struct {
+		name string
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{u: "ssm:///token"},
+			out: out{name: "token"},
+		},
+		{
+			in:  in{u: "ssm://us-west-2/token"},
+			out: out{name: "token"},
+		},
+		{
+			in:  in{u: "ssm:///app/config"},
+			out: out{name: "/app/config"},
+		},
+		{
+			in:  in{u: "ssm://us-west-2/"},
+			out: out{name: ""},
+		},
+	}
+
+	for i, test := range tests {
+		u, err := url.Parse(test.in.u)
+		if err != nil {
+			t.Errorf("#%d: failed to parse URL: %v", i, err)
+			continue
+		}
+		if name := ssmParameterName(*u); name != test.out.name {
+			t.Errorf("#%d: bad name: want %q, got %q", i, test.out.name, name)
+		}
+	}
+}
+
+func TestS3Endpoint(t *testing.T) {
+	type in struct {
+		u      string
+		region string
+	}
+	type out struct {
+		endpoint string
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{u: "s3://configs/nodes/worker.ign", region: "us-east-1"},
+			out: out{endpoint: "https://configs.s3.us-east-1.amazonaws.com/nodes/worker.ign"},
+		},
+		{
+			in:  in{u: "s3://configs/worker.ign?versionId=3sL4kqtJlcpXroDTDmJ%2BrmSpXd3dIbrHY", region: "eu-west-1"},
+			out: out{endpoint: "https://configs.s3.eu-west-1.amazonaws.com/worker.ign?versionId=3sL4kqtJlcpXroDTDmJ%2BrmSpXd3dIbrHY"},
+		},
+		{
+			in:  in{u: "s3://configs.example.com/worker.ign", region: "us-west-2"},
+			out: out{endpoint: "https://s3.us-west-2.amazonaws.com/configs.example.com/worker.ign"},
+		},
+		{
+			in:  in{u: "s3://configs/worker.ign?acl", region: "us-west-2"},
+			out: out{endpoint: "https://configs.s3.us-west-2.amazonaws.com/worker.ign"},
+		},
+	}
+
+	for i, test := range tests {
+		u, err := url.Parse(test.in.u)
+		if err != nil {
+			t.Errorf("#%d: failed to parse URL: %v", i, err)
+			continue
+		}
+		endpoint := s3Endpoint(*u, test.in.region)
+		if endpoint.String() != test.out.endpoint {
+			t.Errorf("#%d: bad endpoint: want %q, got %q", i, test.out.endpoint, endpoint.String())
+		}
+	}
+}
+
+func TestAwsMetadataHeader(t *testing.T) {
+	type in struct {
+		tokens  bool
+		require bool
+	}
+	type out struct {
+		header http.Header
+		err    bool
+		// requests is how many token requests two lookups make.
+		requests int
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{tokens: true},
+			out: out{header: http.Header{AwsTokenHeader: {"secret"}}, requests: 1},
+		},
+		{
+			in:  in{tokens: true, require: true},
+			out: out{header: http.Header{AwsTokenHeader: {"secret"}}, requests: 1},
+		},
+		{
+			in:  in{tokens: false},
+			out: out{header: http.Header{}, requests: 1},
+		},
+		{
+			in:  in{tokens: false, require: true},
+			out: out{err: true, requests: 2},
+		},
+	}
+
+	defer func(u string) { awsTokenUrl = u }(awsTokenUrl)
+
+	logger := log.New()
+	for i, test := range tests {
+		requests := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			if !test.in.tokens || r.Method != "PUT" || r.Header.Get(awsTokenTtlHeader) == "" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Write([]byte("secret\n"))
+		}))
+		awsTokenUrl = server.URL + "/latest/api/token"
+
+		client := NewHttpClient(&logger)
+		if test.in.require {
+			client.RequireImdsV2()
+		}
+		// A copy of the client shares what the first lookup learned.
+		for _, c := range []HttpClient{client, client.WithLogger(&logger)} {
+			header, err := c.AwsMetadataHeader(context.Background())
+			if (err != nil) != test.out.err {
+				t.Errorf("#%d: bad error: want %t, got %v", i, test.out.err, err)
+			}
+			if !reflect.DeepEqual(test.out.header, header) {
+				t.Errorf("#%d: bad header: want %v, got %v", i, test.out.header, header)
+			}
+		}
+		server.Close()
+		if requests != test.out.requests {
+			t.Errorf("#%d: bad token requests: want %d, got %d", i, test.out.requests, requests)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resource
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"sync"
+
+	"github.com/coreos/ignition/config/types"
+)
+
+const (
+	// maxCachedSize is the largest resource kept to share between fetches.
+	// Larger ones are fetched each time they are referenced rather than
+	// buffered in the initramfs's memory.
+	maxCachedSize = 4 << 20
+	// maxCacheSize bounds the contents kept by a client altogether.
+	maxCacheSize = 64 << 20
+)
+
+// cacheKey identifies a resource by its URL and the hash it is verified
+// against, so that only contents known to be identical are shared.
+type cacheKey struct {
+	url  string
+	hash string
+}
+
+// cachedResource holds the verified contents of a resource, as fetched, once
+// ok is set. Until then, the resource is being fetched.
+type cachedResource struct {
+	ok   bool
+	data []byte
+}
+
+// resourceCache is shared by the copies of a client, so that the resources
+// fetched by the engine and by each stage's fetches are shared too. Only
+// resources expected to be fetched more than once are kept.
+type resourceCache struct {
+	sync.Mutex
+	entries  map[cacheKey]*cachedResource
+	expected map[cacheKey]int
+	size     int
+}
+
+// cacheKeyFor returns the key of the resource at u fetched with opts, and
+// whether it may be cached, which it may only if it is remote and verified.
+func cacheKeyFor(u url.URL, opts FetchOptions) (cacheKey, bool) {
+	return cacheKeyOf(u, opts.Verification)
+}
+
+func cacheKeyOf(u url.URL, verification types.Verification) (cacheKey, bool) {
+	h := verification.Hash
+	if h == nil || u.Scheme == "data" {
+		return cacheKey{}, false
+	}
+	hash := h.Function + "-" + h.Sum
+	if verification.Decompressed {
+		hash = "decompressed:" + hash
+	}
+	return cacheKey{url: u.String(), hash: hash}, true
+}
+
+// ExpectFetch records that the resource at u, verified against
+// verification, is going to be fetched. Only resources expected more than
+// once are kept to share between their fetches.
+func (c HttpClient) ExpectFetch(u url.URL, verification types.Verification) {
+	key, ok := cacheKeyOf(u, verification)
+	if !ok || c.cache == nil {
+		return
+	}
+	c.cache.Lock()
+	defer c.cache.Unlock()
+	c.cache.expected[key]++
+}
+
+// acquire returns the cached entry for key, or registers a new entry which
+// the caller must fetch, reporting whether it did. A resource which isn't
+// expected more than once, or whose fetch is still in flight, gets no entry,
+// so the caller fetches it without caching rather than waiting on a fetch
+// which may never finish.
+func (c *resourceCache) acquire(key cacheKey) (*cachedResource, bool) {
+	c.Lock()
+	defer c.Unlock()
+	if c.expected[key] < 2 {
+		return nil, false
+	}
+	entry, ok := c.entries[key]
+	if !ok {
+		entry = &cachedResource{}
+		c.entries[key] = entry
+		return entry, true
+	}
+	if entry.ok {
+		return entry, false
+	}
+	return nil, false
+}
+
+// open returns a reader of the cached contents.
+func (r *cachedResource) open() io.ReadCloser {
+	return ioutil.NopCloser(bytes.NewReader(r.data))
+}
+
+// release forgets every entry.
+func (c *resourceCache) release() {
+	c.Lock()
+	defer c.Unlock()
+	c.entries = map[cacheKey]*cachedResource{}
+	c.size = 0
+}
+
+// spool collects the contents of a resource being fetched for its cache
+// entry, up to maxCachedSize. Failing to spool doesn't fail the fetch; the
+// contents just aren't cached.
+type spool struct {
+	cache    *resourceCache
+	key      cacheKey
+	entry    *cachedResource
+	buf      bytes.Buffer
+	tooLarge bool
+	once     sync.Once
+}
+
+func (s *spool) Write(p []byte) (int, error) {
+	if s.tooLarge {
+		return len(p), nil
+	}
+	if s.buf.Len()+len(p) > maxCachedSize {
+		s.tooLarge = true
+		s.buf = bytes.Buffer{}
+		return len(p), nil
+	}
+	s.buf.Write(p)
+	return len(p), nil
+}
+
+// finish publishes the spooled contents if they were verified and fit in the
+// cache, or drops the entry so that a later fetch tries again. Only the
+// first call counts.
+func (s *spool) finish(verified bool) {
+	s.once.Do(func() {
+		s.cache.Lock()
+		defer s.cache.Unlock()
+		if verified && !s.tooLarge && s.cache.size+s.buf.Len() <= maxCacheSize {
+			s.entry.ok = true
+			s.entry.data = s.buf.Bytes()
+			s.cache.size += s.buf.Len()
+		} else {
+			delete(s.cache.entries, s.key)
+		}
+	})
+}
+
+// ReleaseCache forgets the resources the client fetched and kept to share
+// between identical fetches. Later fetches fetch them again.
+func (c HttpClient) ReleaseCache() {
+	if c.cache != nil {
+		c.cache.release()
+	}
+}
@@ -0,0 +1,243 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resource
+
+import (
+	"compress/gzip"
+	"encoding/hex"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/coreos/ignition/config/types"
+	"github.com/coreos/ignition/internal/log"
+
+	"golang.org/x/net/context"
+)
+
+// Fetcher fetches the resources referenced by configs: referenced configs,
+// CAs, file contents, and fragments. Fetches go through Client, and so use
+// its retries, credentials, TLS, and proxy settings, and each resource is
+// verified and decompressed as its FetchOptions declare. Small verified
+// resources which the Client expects to fetch more than once are fetched only
+// once; identical fetches share the contents.
+type Fetcher struct {
+	Logger *log.Logger
+	Client *HttpClient
+	// Context cancels fetches in flight when it is done. A nil Context
+	// never cancels.
+	Context context.Context
+}
+
+// FetchOptions declare how a resource is fetched and decoded.
+type FetchOptions struct {
+	// Headers are sent with http and https requests.
+	Headers http.Header
+	// Verification is checked against the resource as fetched, before it is
+	// decompressed, unless it is for the decompressed resource.
+	Verification types.Verification
+	Compression  types.Compression
+	// MaxSize, if positive, bounds the size of the decompressed resource
+	// Fetch reads; larger ones fail with ErrTooLarge.
+	MaxSize int64
+}
+
+// Resource reads a fetched resource. Verify checks it once it has been read
+// in full.
+type Resource struct {
+	io.ReadCloser
+	hash     hash.Hash
+	expected string
+	// spool, if set, collects the contents for the client's cache once
+	// they are verified.
+	spool *spool
+}
+
+// Verify checks the contents read so far against the expected hash, if any.
+func (r *Resource) Verify() error {
+	if r.hash == nil {
+		return nil
+	}
+	sum := hex.EncodeToString(r.hash.Sum(nil))
+	if r.spool != nil {
+		r.spool.finish(sum == r.expected)
+	}
+	if sum != r.expected {
+		return ErrHashMismatch{
+			Calculated: sum,
+			Expected:   r.expected,
+		}
+	}
+	return nil
+}
+
+// Close closes the resource. Its contents are cached only if those read
+// match the expected hash.
+func (r *Resource) Close() error {
+	if r.spool != nil {
+		r.spool.finish(hex.EncodeToString(r.hash.Sum(nil)) == r.expected)
+	}
+	return r.ReadCloser.Close()
+}
+
+// NewResource returns a Resource which reads the contents of reader,
+// verifying and decompressing them as opts declare. The headers of opts are
+// ignored.
+func NewResource(reader io.ReadCloser, opts FetchOptions) (*Resource, error) {
+	hasher, err := GetHasher(opts.Verification)
+	if err != nil {
+		reader.Close()
+		return nil, err
+	}
+	r := &Resource{hash: hasher}
+	if hasher != nil {
+		if !opts.Verification.Decompressed {
+			reader = newHashedReader(reader, hasher)
+		}
+		r.expected = opts.Verification.Hash.Sum
+	}
+	if r.ReadCloser, err = decompress(opts.Compression, reader); err != nil {
+		reader.Close()
+		return nil, err
+	}
+	if hasher != nil && opts.Verification.Decompressed {
+		r.ReadCloser = newHashedReader(r.ReadCloser, hasher)
+	}
+	return r, nil
+}
+
+// Open starts fetching the resource at u. The caller must close it and
+// should verify it once it has been read.
+func (f Fetcher) Open(u url.URL, opts FetchOptions) (*Resource, error) {
+	// Reject unusable options before making any request.
+	if _, err := GetHasher(opts.Verification); err != nil {
+		return nil, err
+	}
+	if err := opts.Compression.Validate(); err.IsFatal() {
+		return nil, types.ErrCompressionInvalid
+	}
+	headers := opts.Headers
+	if headers == nil {
+		headers = http.Header{}
+	}
+	ctx := f.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	var entry *cachedResource
+	fetch := false
+	key, cacheable := cacheKeyFor(u, opts)
+	if cacheable && f.Client != nil && f.Client.cache != nil {
+		entry, fetch = f.Client.cache.acquire(key)
+	}
+	if entry == nil {
+		reader, err := FetchAsReaderWithHeader(f.Logger, f.Client, ctx, u, headers)
+		if err != nil {
+			return nil, err
+		}
+		return NewResource(reader, opts)
+	}
+	if !fetch {
+		f.Logger.Debug("reusing the contents fetched from %s", redactUrl(key.url))
+		return NewResource(entry.open(), opts)
+	}
+	s := &spool{cache: f.Client.cache, key: key, entry: entry}
+	reader, err := FetchAsReaderWithHeader(f.Logger, f.Client, ctx, u, headers)
+	if err != nil {
+		s.finish(false)
+		return nil, err
+	}
+	r, err := NewResource(newTeeReader(reader, s), opts)
+	if err != nil {
+		s.finish(false)
+		return nil, err
+	}
+	r.spool = s
+	return r, nil
+}
+
+// Fetch returns the verified, decompressed resource at u.
+func (f Fetcher) Fetch(u url.URL, opts FetchOptions) ([]byte, error) {
+	r, err := f.Open(u, opts)
+	if err != nil {
+		return nil, err
+	}
+	data, err := ReadAtMost(r, opts.MaxSize)
+	r.Close()
+	if err != nil {
+		return nil, err
+	}
+	if err := r.Verify(); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// newTeeReader returns a new ReadCloser that also writes to w.
+func newTeeReader(reader io.ReadCloser, w io.Writer) io.ReadCloser {
+	return struct {
+		io.Reader
+		io.Closer
+	}{
+		Reader: io.TeeReader(reader, w),
+		Closer: reader,
+	}
+}
+
+// newHashedReader returns a new ReadCloser that also writes to the provided hash.
+func newHashedReader(reader io.ReadCloser, hasher hash.Hash) io.ReadCloser {
+	return newTeeReader(reader, hasher)
+}
+
+// gzipReader is a wrapper for gzip's reader that closes the stream it wraps as well
+// as itself when Close() is called.
+type gzipReader struct {
+	*gzip.Reader //actually a ReadCloser
+	source       io.Closer
+}
+
+func newGzipReader(reader io.ReadCloser) (io.ReadCloser, error) {
+	gzReader, err := gzip.NewReader(reader)
+	if err != nil {
+		return nil, err
+	}
+	return gzipReader{
+		Reader: gzReader,
+		source: reader,
+	}, nil
+}
+
+func (gz gzipReader) Close() error {
+	if err := gz.Reader.Close(); err != nil {
+		return err
+	}
+	if err := gz.source.Close(); err != nil {
+		return err
+	}
+	return nil
+}
+
+func decompress(compression types.Compression, contents io.ReadCloser) (io.ReadCloser, error) {
+	switch compression {
+	case "":
+		return contents, nil
+	case "gzip":
+		return newGzipReader(contents)
+	default:
+		return nil, types.ErrCompressionInvalid
+	}
+}
@@ -0,0 +1,230 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resource
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/coreos/ignition/config/types"
+	"github.com/coreos/ignition/internal/log"
+)
+
+func TestFetcherFetch(t *testing.T) {
+	type in struct {
+		url  string
+		opts FetchOptions
+	}
+	type out struct {
+		data string
+		err  error
+	}
+
+	const gzipped = "data:;base64,H4sIAAAAAAAC/8tIzcnJ11Eozy/KSeECAFN0JPQNAAAA"
+	sha256 := func(sum string) types.Verification {
+		return types.Verification{Hash: &types.Hash{Function: "sha256", Sum: sum}}
+	}
+	decompressed := func(sum string) types.Verification {
+		v := sha256(sum)
+		v.Decompressed = true
+		return v
+	}
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{url: "data:,hello%2C%20world%0A"},
+			out: out{data: "hello, world\n"},
+		},
+		{
+			in:  in{url: "data:,hello%2C%20world%0A", opts: FetchOptions{Verification: sha256("853ff93762a06ddbf722c4ebe9ddd66d8f63ddaea97f521c3ecc20da7c976020")}},
+			out: out{data: "hello, world\n"},
+		},
+		{
+			in:  in{url: gzipped, opts: FetchOptions{Compression: "gzip", Verification: sha256("f349d20999432b3f6b174f35a335777a63cb24d256f07e6303cb39f5a4d38598")}},
+			out: out{data: "hello, world\n"},
+		},
+		{
+			in: in{url: gzipped, opts: FetchOptions{Compression: "gzip", Verification: sha256("853ff93762a06ddbf722c4ebe9ddd66d8f63ddaea97f521c3ecc20da7c976020")}},
+			out: out{err: ErrHashMismatch{
+				Calculated: "f349d20999432b3f6b174f35a335777a63cb24d256f07e6303cb39f5a4d38598",
+				Expected:   "853ff93762a06ddbf722c4ebe9ddd66d8f63ddaea97f521c3ecc20da7c976020",
+			}},
+		},
+		{
+			in:  in{url: gzipped, opts: FetchOptions{Compression: "gzip", Verification: decompressed("853ff93762a06ddbf722c4ebe9ddd66d8f63ddaea97f521c3ecc20da7c976020")}},
+			out: out{data: "hello, world\n"},
+		},
+		{
+			in: in{url: gzipped, opts: FetchOptions{Compression: "gzip", Verification: decompressed("f349d20999432b3f6b174f35a335777a63cb24d256f07e6303cb39f5a4d38598")}},
+			out: out{err: ErrHashMismatch{
+				Calculated: "853ff93762a06ddbf722c4ebe9ddd66d8f63ddaea97f521c3ecc20da7c976020",
+				Expected:   "f349d20999432b3f6b174f35a335777a63cb24d256f07e6303cb39f5a4d38598",
+			}},
+		},
+		{
+			in:  in{url: gzipped, opts: FetchOptions{Compression: "xz"}},
+			out: out{err: types.ErrCompressionInvalid},
+		},
+		{
+			in:  in{url: "data:,hello", opts: FetchOptions{Verification: types.Verification{Hash: &types.Hash{Function: "md5"}}}},
+			out: out{err: types.ErrHashUnrecognized},
+		},
+		{
+			in:  in{url: "data:,hello%2C%20world%0A", opts: FetchOptions{MaxSize: 13}},
+			out: out{data: "hello, world\n"},
+		},
+		{
+			in:  in{url: gzipped, opts: FetchOptions{Compression: "gzip", MaxSize: 12}},
+			out: out{err: ErrTooLarge},
+		},
+	}
+
+	logger := log.New()
+	c := NewHttpClient(&logger)
+	fetcher := Fetcher{Logger: &logger, Client: &c}
+	for i, test := range tests {
+		u, err := url.Parse(test.in.url)
+		if err != nil {
+			t.Fatalf("#%d: bad url: %v", i, err)
+		}
+		data, err := fetcher.Fetch(*u, test.in.opts)
+		if !reflect.DeepEqual(test.out.err, err) {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.out.err, err)
+		}
+		if string(data) != test.out.data {
+			t.Errorf("#%d: bad data: want %q, got %q", i, test.out.data, data)
+		}
+	}
+}
+
+func TestFetcherCache(t *testing.T) {
+	type in struct {
+		size         int
+		verification types.Verification
+		fetches      int
+		expected     int
+	}
+	type out struct {
+		requests int
+	}
+
+	sha256 := func(data []byte) types.Verification {
+		sum := sha256.Sum256(data)
+		return types.Verification{Hash: &types.Hash{Function: "sha256", Sum: hex.EncodeToString(sum[:])}}
+	}
+	payload := func(size int) []byte {
+		return bytes.Repeat([]byte{'x'}, size)
+	}
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{size: 16, verification: sha256(payload(16)), fetches: 3, expected: 3},
+			out: out{requests: 1},
+		},
+		{
+			in:  in{size: 16, verification: sha256(payload(16)), fetches: 2, expected: 1},
+			out: out{requests: 2},
+		},
+		{
+			in:  in{size: maxCachedSize + 1, verification: sha256(payload(maxCachedSize + 1)), fetches: 2, expected: 2},
+			out: out{requests: 2},
+		},
+		{
+			in:  in{size: 16, fetches: 2, expected: 2},
+			out: out{requests: 2},
+		},
+		{
+			in:  in{size: 16, verification: sha256(payload(8)), fetches: 2, expected: 2},
+			out: out{requests: 2},
+		},
+	}
+
+	logger := log.New()
+	for i, test := range tests {
+		requests := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			w.Write(payload(test.in.size))
+		}))
+		u, err := url.Parse(server.URL)
+		if err != nil {
+			t.Fatalf("#%d: bad url: %v", i, err)
+		}
+
+		c := NewHttpClient(&logger)
+		fetcher := Fetcher{Logger: &logger, Client: &c}
+		for j := 0; j < test.in.expected; j++ {
+			c.ExpectFetch(*u, test.in.verification)
+		}
+		for j := 0; j < test.in.fetches; j++ {
+			data, err := fetcher.Fetch(*u, FetchOptions{Verification: test.in.verification})
+			if err == nil && !bytes.Equal(data, payload(test.in.size)) {
+				t.Errorf("#%d: bad data of fetch %d", i, j)
+			}
+		}
+		server.Close()
+		if requests != test.out.requests {
+			t.Errorf("#%d: bad requests: want %d, got %d", i, test.out.requests, requests)
+		}
+		c.ReleaseCache()
+	}
+}
+
+func TestFetcherCacheAbandoned(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write([]byte("payload"))
+	}))
+	defer server.Close()
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatalf("bad url: %v", err)
+	}
+	sum := sha256.Sum256([]byte("payload"))
+	verification := types.Verification{Hash: &types.Hash{Function: "sha256", Sum: hex.EncodeToString(sum[:])}}
+
+	logger := log.New()
+	c := NewHttpClient(&logger)
+	c.ExpectFetch(*u, verification)
+	c.ExpectFetch(*u, verification)
+	fetcher := Fetcher{Logger: &logger, Client: &c}
+
+	// The first fetch is never read, verified, or closed; the second must
+	// not wait on it.
+	if _, err := fetcher.Open(*u, FetchOptions{Verification: verification}); err != nil {
+		t.Fatalf("failed to open: %v", err)
+	}
+	data, err := fetcher.Fetch(*u, FetchOptions{Verification: verification})
+	if err != nil {
+		t.Fatalf("failed to fetch: %v", err)
+	}
+	if string(data) != "payload" {
+		t.Errorf("bad data: want %q, got %q", "payload", data)
+	}
+	if requests != 2 {
+		t.Errorf("bad requests: want 2, got %d", requests)
+	}
+}
@@ -0,0 +1,68 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resource
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/coreos/ignition/internal/log"
+
+	"golang.org/x/net/context"
+)
+
+const gceTokenUrl = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+
+// gsEndpoint returns the URL of the Cloud Storage object referenced by a gs
+// URL of the form gs://bucket/object.
+func gsEndpoint(u url.URL) url.URL {
+	return url.URL{Scheme: "https", Host: "storage.googleapis.com", Path: "/" + u.Host + u.Path}
+}
+
+// fetchGsObject returns a reader over the Cloud Storage object referenced by
+// u. Requests carry the token of the instance's default service account, if
+// it has one, and are otherwise made anonymously for public objects.
+func fetchGsObject(l *log.Logger, c *HttpClient, ctx context.Context, u url.URL) (io.ReadCloser, error) {
+	if u.Host == "" || strings.Trim(u.Path, "/") == "" {
+		l.Err("gs url must name a bucket and object: %q", u.String())
+		return nil, ErrFailed
+	}
+
+	header := http.Header{}
+	if token, err := c.bearerToken(ctx, gceTokenUrl, http.Header{"Metadata-Flavor": {"Google"}}); err == nil {
+		header.Set("Authorization", "Bearer "+token)
+	} else {
+		l.Info("fetching gs object anonymously: %v", err)
+	}
+
+	endpoint := gsEndpoint(u)
+	body, status, err := c.getReaderWithHeader(ctx, endpoint.String(), header)
+	if err != nil {
+		return nil, err
+	}
+	switch status {
+	case http.StatusOK:
+		return body, nil
+	case http.StatusNotFound:
+		body.Close()
+		return nil, ErrNotFound
+	default:
+		body.Close()
+		l.Err("failed to fetch gs object %q: %s", u.String(), http.StatusText(status))
+		return nil, ErrFailed
+	}
+}
@@ -15,12 +15,18 @@
 package resource
 
 import (
+	"bytes"
 	"errors"
 	"io"
+	"io/ioutil"
 	"net"
 	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
 	"time"
 
+	"github.com/coreos/ignition/config/types"
 	"github.com/coreos/ignition/internal/log"
 	"github.com/coreos/ignition/internal/version"
 
@@ -29,26 +35,65 @@ import (
 )
 
 const (
-	maxAttempts    = 15
-	initialBackoff = 100 * time.Millisecond
-	maxBackoff     = 5 * time.Second
+	defaultAttempts   = 15
+	initialBackoff    = 100 * time.Millisecond
+	defaultMaxBackoff = 5 * time.Second
+	// maxRetryAfter caps the wait a server may ask for with Retry-After, so
+	// that a misbehaving one can't stall the boot.
+	maxRetryAfter = time.Minute
+
+	// DefaultMaxRedirects is how many redirects a request follows unless
+	// SetMaxRedirects says otherwise.
+	DefaultMaxRedirects = 10
+	// DefaultMaxConfigSize is the largest config fetched unless
+	// SetMaxConfigSize says otherwise.
+	DefaultMaxConfigSize = 10 << 20
 )
 
 var (
 	ErrAttemptsExhausted = errors.New("unable to fetch resource (no more attempts available)")
+	ErrTimedOut          = errors.New("unable to fetch resource (timed out)")
+	ErrNeedNet           = errors.New("unable to fetch resource (networking is required)")
+	ErrTooManyRedirects  = errors.New("unable to fetch resource (too many redirects)")
+	ErrTooLarge          = errors.New("resource is too large")
 )
 
 // HttpClient is a simple wrapper around the Go HTTP client that standardizes
 // the process and logging of fetching payloads.
 type HttpClient struct {
-	client *http.Client
-	logger *log.Logger
+	client     *http.Client
+	logger     *log.Logger
+	timeout    time.Duration
+	attempts   int
+	maxBackoff time.Duration
+	auth       []types.UrlAuth
+	trust      *trustState
+	offline    *offlineState
+	timing     *timingState
+	cache      *resourceCache
+	tokens     *tokenCache
+	etags      *etagCache
+	// maxConfigSize bounds the size of the configs fetched with the client.
+	maxConfigSize int64
+	// requireImdsV2 refuses to fall back to IMDSv1 when no IMDSv2 session
+	// token can be had from the EC2 instance metadata service.
+	requireImdsV2 bool
+}
+
+// offlineState is shared by the copies of a client, so that the requests a
+// provider makes from its own copy are refused and recorded too.
+type offlineState struct {
+	sync.Mutex
+	enabled   bool
+	needed    bool
+	onNeedNet func()
 }
 
 // NewHttpClient creates a new client with the given logger.
 func NewHttpClient(logger *log.Logger) HttpClient {
 	return HttpClient{
 		client: &http.Client{
+			CheckRedirect: limitRedirects(DefaultMaxRedirects),
 			Transport: &http.Transport{
 				ResponseHeaderTimeout: 10 * time.Second,
 				Dial: (&net.Dialer{
@@ -58,7 +103,124 @@ func NewHttpClient(logger *log.Logger) HttpClient {
 				TLSHandshakeTimeout: 10 * time.Second,
 			},
 		},
-		logger: logger,
+		logger:     logger,
+		attempts:   defaultAttempts,
+		maxBackoff: defaultMaxBackoff,
+		trust:      &trustState{sources: map[string]bool{}},
+		offline:    &offlineState{},
+		timing:     &timingState{},
+		cache:      &resourceCache{entries: map[cacheKey]*cachedResource{}, expected: map[cacheKey]int{}},
+		tokens:     &tokenCache{tokens: map[string]cachedToken{}},
+		etags:      &etagCache{configs: map[string]etagConfig{}},
+
+		maxConfigSize: DefaultMaxConfigSize,
+	}
+}
+
+// limitRedirects returns a redirect policy which follows at most max
+// redirects.
+func limitRedirects(max int) func(*http.Request, []*http.Request) error {
+	return func(req *http.Request, via []*http.Request) error {
+		if len(via) > max {
+			return ErrTooManyRedirects
+		}
+		return nil
+	}
+}
+
+// SetOffline makes every request that would use the network fail with
+// ErrNeedNet instead, recording that the network was needed. onNeedNet, if
+// non-nil, is called on the first such request, e.g. to cancel providers
+// which would otherwise keep retrying.
+func (c HttpClient) SetOffline(onNeedNet func()) {
+	c.offline.Lock()
+	defer c.offline.Unlock()
+	c.offline.enabled = true
+	c.offline.onNeedNet = onNeedNet
+}
+
+// NeedsNetwork reports whether a request was refused since the client was
+// made offline.
+func (c HttpClient) NeedsNetwork() bool {
+	if c.offline == nil {
+		return false
+	}
+	c.offline.Lock()
+	defer c.offline.Unlock()
+	return c.offline.needed
+}
+
+// refuseOffline returns ErrNeedNet, recording the refusal, if the client is
+// offline.
+func (c HttpClient) refuseOffline() error {
+	if c.offline == nil {
+		return nil
+	}
+	c.offline.Lock()
+	defer c.offline.Unlock()
+	if !c.offline.enabled {
+		return nil
+	}
+	if !c.offline.needed && c.offline.onNeedNet != nil {
+		c.offline.onNeedNet()
+	}
+	c.offline.needed = true
+	return ErrNeedNet
+}
+
+// WithLogger returns a copy of c which logs to logger, for use by another
+// goroutine. The copy shares c's connections and settings.
+func (c HttpClient) WithLogger(logger *log.Logger) HttpClient {
+	c.logger = logger
+	return c
+}
+
+// SetTimeout bounds the time spent retrying a single request. A zero timeout
+// leaves only the attempt limit in effect.
+func (c *HttpClient) SetTimeout(timeout time.Duration) {
+	c.timeout = timeout
+}
+
+// RequireImdsV2 makes requests to the EC2 instance metadata service fail when
+// they cannot carry an IMDSv2 session token, rather than go without.
+func (c *HttpClient) RequireImdsV2() {
+	c.requireImdsV2 = true
+}
+
+// SetMaxRedirects bounds how many redirects a request follows before it fails
+// with ErrTooManyRedirects. It sets the redirect policy of the underlying
+// *http.Client, which every copy of the client shares, so the limit changes
+// for all of them, including copies made before the call.
+func (c *HttpClient) SetMaxRedirects(max int) {
+	c.client.CheckRedirect = limitRedirects(max)
+}
+
+// SetMaxConfigSize bounds the size of the configs fetched with the client;
+// larger ones fail with ErrTooLarge rather than being read into memory.
+func (c *HttpClient) SetMaxConfigSize(size int64) {
+	c.maxConfigSize = size
+}
+
+// MaxConfigSize returns the largest config the client fetches.
+func (c HttpClient) MaxConfigSize() int64 {
+	return c.maxConfigSize
+}
+
+// SetTimeouts applies the fields of timeouts which are set, leaving the
+// others unchanged. The response header timeout is shared by every copy of
+// the client.
+func (c *HttpClient) SetTimeouts(timeouts types.Timeouts) {
+	if t := timeouts.HttpResponseHeaders; t != nil {
+		c.client.Transport.(*http.Transport).ResponseHeaderTimeout = time.Duration(*t) * time.Second
+	}
+	if t := timeouts.HttpTotal; t != nil {
+		c.timeout = time.Duration(*t) * time.Second
+	}
+	if r := timeouts.HttpRetries; r != nil {
+		c.attempts = *r + 1
+	}
+	if b := timeouts.HttpMaxBackoff; b != nil {
+		c.maxBackoff = time.Duration(*b) * time.Second
 	}
 }
 
@@ -66,10 +228,57 @@ func NewHttpClient(logger *log.Logger) HttpClient {
 // and returns the response body Reader, HTTP status code, and error (if any). By
 // default, User-Agent is added to the header but this can be overridden.
 func (c HttpClient) getReaderWithHeader(ctx context.Context, url string, header http.Header) (io.ReadCloser, int, error) {
-	req, err := http.NewRequest("GET", url, nil)
+	return c.doWithHeader(ctx, "GET", url, nil, header)
+}
+
+// GetWithHeader performs an HTTP GET on the provided URL with the provided
+// request header, retrying as getReaderWithHeader does, and returns the
+// response, whose body the caller must close.
+func (c HttpClient) GetWithHeader(ctx context.Context, url string, header http.Header) (*http.Response, error) {
+	return c.do(ctx, "GET", url, nil, header)
+}
+
+// PutWithHeader PUTs body to the provided URL with the provided request
+// header, retrying as getReaderWithHeader does, and returns the response,
+// whose body the caller must close.
+func (c HttpClient) PutWithHeader(ctx context.Context, url string, body []byte, header http.Header) (*http.Response, error) {
+	return c.do(ctx, "PUT", url, body, header)
+}
+
+// PostWithHeader POSTs body to the provided URL with the provided request
+// header, retrying as getReaderWithHeader does, and returns the HTTP status
+// code of the response.
+func (c HttpClient) PostWithHeader(ctx context.Context, url string, body []byte, header http.Header) (int, error) {
+	resp, status, err := c.doWithHeader(ctx, "POST", url, body, header)
+	if err != nil {
+		return 0, err
+	}
+	resp.Close()
+	return status, nil
+}
+
+// doWithHeader performs an HTTP request of the given method on the provided
+// URL with the provided body and request header, retrying as
+// getReaderWithHeader does.
+func (c HttpClient) doWithHeader(ctx context.Context, method, url string, body []byte, header http.Header) (io.ReadCloser, int, error) {
+	resp, err := c.do(ctx, method, url, body, header)
 	if err != nil {
 		return nil, 0, err
 	}
+	return resp.Body, resp.StatusCode, nil
+}
+
+// do performs the request as doWithHeader does, returning the response whose
+// body the caller must close.
+func (c HttpClient) do(ctx context.Context, method, url string, body []byte, header http.Header) (*http.Response, error) {
+	if err := c.refuseOffline(); err != nil {
+		c.logger.Debug("%s %s: %v", method, redactUrl(url), err)
+		return nil, err
+	}
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		return nil, err
+	}
 
 	req.Header.Set("User-Agent", "Ignition/"+version.Raw)
 
@@ -79,33 +288,146 @@ func (c HttpClient) getReaderWithHeader(ctx context.Context, url string, header
 			req.Header.Add(key, value)
 		}
 	}
+	authHeader, err := c.authorize(ctx, url, req.Header)
+	if err != nil {
+		return nil, err
+	}
+	confined := []string{}
+	for key := range header {
+		confined = append(confined, key)
+	}
+	if authHeader != "" {
+		confined = append(confined, authHeader)
+	}
+	client := c.confineHeaders(confined)
 
+	start := time.Now()
+	timing := FetchTiming{Method: method, Url: redactUrl(url)}
 	duration := initialBackoff
-	for attempt := 1; attempt <= maxAttempts; attempt++ {
-		c.logger.Debug("GET %s: attempt #%d", url, attempt)
-		resp, err := ctxhttp.Do(ctx, c.client, req)
+	for attempt := 1; attempt <= c.attempts; attempt++ {
+		c.logger.Debug("%s %s: attempt #%d", method, redactUrl(url), attempt)
+		timing.Attempts = attempt
+		if body != nil {
+			req.Body = ioutil.NopCloser(bytes.NewReader(body))
+			req.ContentLength = int64(len(body))
+		}
+		resp, err := ctxhttp.Do(ctx, client, req)
 
+		wait := time.Duration(0)
 		if err == nil {
-			c.logger.Debug("GET result: %s", http.StatusText(resp.StatusCode))
-			if resp.StatusCode < 500 {
-				return resp.Body, resp.StatusCode, nil
+			c.logger.Debug("%s result: %s", method, http.StatusText(resp.StatusCode))
+			timing.Status = resp.StatusCode
+			if !retryable(resp.StatusCode) {
+				resp.Body = c.timeBody(c.resumable(ctx, req, resp), timing, start)
+				return resp, nil
 			}
+			wait = retryAfter(resp.Header.Get("Retry-After"), time.Now())
 			resp.Body.Close()
+		} else if tooManyRedirects(err) {
+			c.logger.Debug("%s error: %v", method, err)
+			c.recordTiming(timing, start)
+			return nil, ErrTooManyRedirects
 		} else {
-			c.logger.Debug("GET error: %v", err)
+			c.logger.Debug("%s error: %v", method, err)
+		}
+
+		if attempt == c.attempts {
+			break
 		}
 
 		duration = duration * 2
-		if duration > maxBackoff {
-			duration = maxBackoff
+		if duration > c.maxBackoff {
+			duration = c.maxBackoff
+		}
+		if wait == 0 {
+			wait = duration
+		}
+
+		if c.timeout > 0 && time.Since(start)+wait > c.timeout {
+			c.recordTiming(timing, start)
+			return nil, ErrTimedOut
 		}
 
 		select {
-		case <-time.After(duration):
+		case <-time.After(wait):
 		case <-ctx.Done():
-			return nil, 0, ctx.Err()
+			c.recordTiming(timing, start)
+			return nil, ctx.Err()
 		}
 	}
 
-	return nil, 0, ErrAttemptsExhausted
+	c.recordTiming(timing, start)
+	return nil, ErrAttemptsExhausted
+}
+
+// confineHeaders returns the client to send a request with, which drops the
+// headers named, when the request is redirected to another scheme or host.
+// These are the header set by an auth rule and those supplied by the caller,
+// such as a config's httpHeaders, any of which may be a credential.
+func (c HttpClient) confineHeaders(names []string) *http.Client {
+	if len(names) == 0 {
+		return c.client
+	}
+	client := *c.client
+	checkRedirect := c.client.CheckRedirect
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if checkRedirect != nil {
+			if err := checkRedirect(req, via); err != nil {
+				return err
+			}
+		}
+		if !sameOrigin(req.URL, via[0].URL) {
+			for _, name := range names {
+				req.Header.Del(name)
+			}
+		}
+		return nil
+	}
+	return &client
+}
+
+// tooManyRedirects reports whether err is the failure of a request which
+// redirected more often than the client follows. Repeating it won't help.
+func tooManyRedirects(err error) bool {
+	uerr, ok := err.(*url.Error)
+	return ok && uerr.Err == ErrTooManyRedirects
+}
+
+// retryable reports whether a response with the given status may succeed if
+// the request is repeated: the server is overloaded or failed, rather than
+// having refused the request.
+func retryable(status int) bool {
+	return status == http.StatusTooManyRequests || status >= 500
+}
+
+// retryAfter returns how long the Retry-After header value asks the client to
+// wait from now, capped at maxRetryAfter, or 0 if it asks for nothing usable.
+func retryAfter(value string, now time.Time) time.Duration {
+	var wait time.Duration
+	if secs, err := strconv.Atoi(value); err == nil {
+		if secs > int(maxRetryAfter/time.Second) {
+			return maxRetryAfter
+		}
+		wait = time.Duration(secs) * time.Second
+	} else if date, err := http.ParseTime(value); err == nil {
+		wait = date.Sub(now)
+	}
+	if wait < 0 {
+		return 0
+	}
+	if wait > maxRetryAfter {
+		return maxRetryAfter
+	}
+	return wait
+}
+
+// redactUrl hides the user info of rawurl, which may hold credentials, so that
+// it can be logged.
+func redactUrl(rawurl string) string {
+	u, err := url.Parse(rawurl)
+	if err != nil || u.User == nil {
+		return rawurl
+	}
+	u.User = url.User("redacted")
+	return u.String()
 }
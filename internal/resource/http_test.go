@@ -0,0 +1,362 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resource
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/coreos/ignition/config/types"
+	"github.com/coreos/ignition/internal/log"
+
+	"golang.org/x/net/context"
+)
+
+func TestSetTimeouts(t *testing.T) {
+	type in struct {
+		timeouts types.Timeouts
+	}
+	type out struct {
+		attempts int
+		err      error
+	}
+
+	intp := func(i int) *int { return &i }
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{timeouts: types.Timeouts{HttpRetries: intp(0), HttpMaxBackoff: intp(0)}},
+			out: out{attempts: 1, err: ErrAttemptsExhausted},
+		},
+		{
+			in:  in{timeouts: types.Timeouts{HttpRetries: intp(3), HttpMaxBackoff: intp(0)}},
+			out: out{attempts: 4, err: ErrAttemptsExhausted},
+		},
+		{
+			in:  in{timeouts: types.Timeouts{HttpRetries: intp(100), HttpMaxBackoff: intp(1), HttpTotal: intp(1)}},
+			out: out{attempts: 3, err: ErrTimedOut},
+		},
+	}
+
+	logger := log.New()
+	for i, test := range tests {
+		attempts := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			attempts++
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		}))
+
+		c := NewHttpClient(&logger)
+		c.SetTimeouts(test.in.timeouts)
+		_, _, err := c.getReaderWithHeader(context.Background(), server.URL, nil)
+		server.Close()
+		if err != test.out.err {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.out.err, err)
+		}
+		if attempts != test.out.attempts {
+			t.Errorf("#%d: bad attempts: want %d, got %d", i, test.out.attempts, attempts)
+		}
+	}
+}
+
+func TestTimings(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts < 3 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("payload"))
+	}))
+	defer server.Close()
+
+	logger := log.New()
+	intp := func(i int) *int { return &i }
+	c := NewHttpClient(&logger)
+	c.SetTimeouts(types.Timeouts{HttpRetries: intp(3), HttpMaxBackoff: intp(0)})
+	body, _, err := c.WithLogger(&logger).getReaderWithHeader(context.Background(), server.URL, nil)
+	if err != nil {
+		t.Fatalf("failed to fetch: %v", err)
+	}
+	if timings := c.TakeTimings(); len(timings) != 0 {
+		t.Errorf("bad timings before the body is closed: want none, got %v", timings)
+	}
+	body.Close()
+	body.Close()
+
+	timings := c.TakeTimings()
+	if len(timings) != 1 {
+		t.Fatalf("bad timings: want 1, got %v", timings)
+	}
+	want := FetchTiming{Method: "GET", Url: server.URL, Status: http.StatusOK, Attempts: 3, Duration: timings[0].Duration}
+	if timings[0] != want {
+		t.Errorf("bad timing: want %+v, got %+v", want, timings[0])
+	}
+	if timings := c.TakeTimings(); len(timings) != 0 {
+		t.Errorf("bad timings once taken: want none, got %v", timings)
+	}
+}
+
+func TestResume(t *testing.T) {
+	type in struct {
+		etag       string
+		changeEtag bool
+	}
+	type out struct {
+		requests int
+		err      error
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{etag: `"v1"`},
+			out: out{requests: 2},
+		},
+		{
+			in:  in{},
+			out: out{requests: 1, err: io.ErrUnexpectedEOF},
+		},
+		{
+			in:  in{etag: `"v1"`, changeEtag: true},
+			out: out{requests: 2, err: io.ErrUnexpectedEOF},
+		},
+	}
+
+	payload := bytes.Repeat([]byte("0123456789"), 10000)
+	logger := log.New()
+	intp := func(i int) *int { return &i }
+	for i, test := range tests {
+		requests := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			etag := test.in.etag
+			if test.in.changeEtag && requests > 1 {
+				etag = `"v2"`
+			}
+			if etag != "" {
+				w.Header().Set("ETag", etag)
+			}
+			if requests == 1 {
+				// Cut the first response off halfway.
+				w.Header().Set("Accept-Ranges", "bytes")
+				w.Header().Set("Content-Length", strconv.Itoa(len(payload)))
+				w.Write(payload[:len(payload)/2])
+				w.(http.Flusher).Flush()
+				panic(http.ErrAbortHandler)
+			}
+			http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(payload))
+		}))
+
+		c := NewHttpClient(&logger)
+		c.SetTimeouts(types.Timeouts{HttpRetries: intp(3), HttpMaxBackoff: intp(0)})
+		body, _, err := c.getReaderWithHeader(context.Background(), server.URL, nil)
+		if err != nil {
+			t.Errorf("#%d: failed to fetch: %v", i, err)
+			server.Close()
+			continue
+		}
+		data, err := ioutil.ReadAll(body)
+		body.Close()
+		server.Close()
+		if err != test.out.err {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.out.err, err)
+		}
+		if err == nil && !bytes.Equal(data, payload) {
+			t.Errorf("#%d: bad data: want %d bytes, got %d", i, len(payload), len(data))
+		}
+		if requests != test.out.requests {
+			t.Errorf("#%d: bad requests: want %d, got %d", i, test.out.requests, requests)
+		}
+	}
+}
+
+func TestRetryableStatus(t *testing.T) {
+	type in struct {
+		status     int
+		retryAfter string
+	}
+	type out struct {
+		attempts int
+		err      error
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{status: http.StatusServiceUnavailable},
+			out: out{attempts: 3, err: ErrAttemptsExhausted},
+		},
+		{
+			in:  in{status: http.StatusTooManyRequests, retryAfter: "0"},
+			out: out{attempts: 3, err: ErrAttemptsExhausted},
+		},
+		{
+			in:  in{status: http.StatusForbidden},
+			out: out{attempts: 1},
+		},
+		{
+			in:  in{status: http.StatusTooManyRequests, retryAfter: "3600"},
+			out: out{attempts: 1, err: ErrTimedOut},
+		},
+	}
+
+	logger := log.New()
+	intp := func(i int) *int { return &i }
+	for i, test := range tests {
+		attempts := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			attempts++
+			if test.in.retryAfter != "" {
+				w.Header().Set("Retry-After", test.in.retryAfter)
+			}
+			http.Error(w, "refused", test.in.status)
+		}))
+
+		c := NewHttpClient(&logger)
+		c.SetTimeouts(types.Timeouts{HttpRetries: intp(2), HttpMaxBackoff: intp(0), HttpTotal: intp(30)})
+		body, _, err := c.getReaderWithHeader(context.Background(), server.URL, nil)
+		if err == nil {
+			body.Close()
+		}
+		server.Close()
+		if err != test.out.err {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.out.err, err)
+		}
+		if attempts != test.out.attempts {
+			t.Errorf("#%d: bad attempts: want %d, got %d", i, test.out.attempts, attempts)
+		}
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	type in struct {
+		value string
+	}
+	type out struct {
+		wait time.Duration
+	}
+
+	now := time.Date(2017, 6, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{value: ""},
+			out: out{wait: 0},
+		},
+		{
+			in:  in{value: "5"},
+			out: out{wait: 5 * time.Second},
+		},
+		{
+			in:  in{value: "-5"},
+			out: out{wait: 0},
+		},
+		{
+			in:  in{value: "99999999999"},
+			out: out{wait: maxRetryAfter},
+		},
+		{
+			in:  in{value: now.Add(30 * time.Second).Format(http.TimeFormat)},
+			out: out{wait: 30 * time.Second},
+		},
+		{
+			in:  in{value: now.Add(-time.Hour).Format(http.TimeFormat)},
+			out: out{wait: 0},
+		},
+		{
+			in:  in{value: "soon"},
+			out: out{wait: 0},
+		},
+	}
+
+	for i, test := range tests {
+		if wait := retryAfter(test.in.value, now); wait != test.out.wait {
+			t.Errorf("#%d: bad wait: want %v, got %v", i, test.out.wait, wait)
+		}
+	}
+}
+
+func TestMaxRedirects(t *testing.T) {
+	type in struct {
+		redirects    int
+		maxRedirects int
+	}
+	type out struct {
+		err error
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{redirects: 3, maxRedirects: 3},
+			out: out{},
+		},
+		{
+			in:  in{redirects: 4, maxRedirects: 3},
+			out: out{err: ErrTooManyRedirects},
+		},
+		{
+			in:  in{redirects: DefaultMaxRedirects + 1},
+			out: out{err: ErrTooManyRedirects},
+		},
+	}
+
+	logger := log.New()
+	for i, test := range tests {
+		requests := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			if n, _ := strconv.Atoi(r.URL.Query().Get("n")); n < test.in.redirects {
+				http.Redirect(w, r, "/?n="+strconv.Itoa(n+1), http.StatusFound)
+				return
+			}
+			w.Write([]byte("payload"))
+		}))
+
+		c := NewHttpClient(&logger)
+		if test.in.maxRedirects != 0 {
+			c.SetMaxRedirects(test.in.maxRedirects)
+		}
+		body, _, err := c.getReaderWithHeader(context.Background(), server.URL, nil)
+		if err == nil {
+			body.Close()
+		}
+		server.Close()
+		if err != test.out.err {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.out.err, err)
+		}
+		if err == ErrTooManyRedirects && requests > test.in.redirects+1 {
+			t.Errorf("#%d: bad requests: retried after too many redirects (%d requests)", i, requests)
+		}
+	}
+}
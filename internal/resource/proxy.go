@@ -0,0 +1,109 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resource
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/coreos/ignition/config/types"
+)
+
+// proxySettings selects the proxy for each request made by a client.
+type proxySettings struct {
+	http    *url.URL
+	https   *url.URL
+	noProxy []string
+}
+
+// SetProxy routes the client's http and https fetches through the proxies of
+// p, replacing any previous proxy settings. Loopback and link-local
+// addresses, such as metadata services, are always fetched from directly.
+func (c HttpClient) SetProxy(p types.Proxy) error {
+	settings := proxySettings{}
+	var err error
+	if p.HttpProxy != "" {
+		if settings.http, err = url.Parse(p.HttpProxy); err != nil {
+			return err
+		}
+	}
+	if p.HttpsProxy != "" {
+		if settings.https, err = url.Parse(p.HttpsProxy); err != nil {
+			return err
+		}
+	}
+	for _, item := range p.NoProxy {
+		settings.noProxy = append(settings.noProxy, strings.ToLower(string(item)))
+	}
+
+	c.client.Transport.(*http.Transport).Proxy = func(req *http.Request) (*url.URL, error) {
+		return settings.proxyFor(req.URL), nil
+	}
+	return nil
+}
+
+// proxyFor returns the proxy to use for u, or nil to fetch it directly.
+func (s proxySettings) proxyFor(u *url.URL) *url.URL {
+	var proxy *url.URL
+	switch u.Scheme {
+	case "http":
+		proxy = s.http
+	case "https":
+		proxy = s.https
+	}
+	if proxy == nil {
+		return nil
+	}
+
+	host := strings.ToLower(u.Host)
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.Trim(host, "[]")
+	if host == "localhost" {
+		return nil
+	}
+	ip := net.ParseIP(host)
+	if ip != nil && (ip.IsLoopback() || ip.IsLinkLocalUnicast()) {
+		return nil
+	}
+
+	for _, item := range s.noProxy {
+		if matchNoProxy(item, host, ip) {
+			return nil
+		}
+	}
+	return proxy
+}
+
+// matchNoProxy reports whether the noProxy item matches host, whose address
+// is ip if host is an IP address.
+func matchNoProxy(item, host string, ip net.IP) bool {
+	if item == "*" {
+		return true
+	}
+	if _, network, err := net.ParseCIDR(item); err == nil {
+		return ip != nil && network.Contains(ip)
+	}
+	if itemIp := net.ParseIP(item); itemIp != nil {
+		return ip != nil && itemIp.Equal(ip)
+	}
+	if strings.HasPrefix(item, ".") {
+		return strings.HasSuffix(host, item)
+	}
+	return host == item || strings.HasSuffix(host, "."+item)
+}
@@ -0,0 +1,146 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resource
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/coreos/ignition/config/types"
+	"github.com/coreos/ignition/internal/log"
+)
+
+func TestProxyFor(t *testing.T) {
+	type in struct {
+		url string
+	}
+	type out struct {
+		proxy string
+	}
+
+	settings := proxySettings{
+		http:    &url.URL{Scheme: "http", Host: "proxy:3128"},
+		https:   &url.URL{Scheme: "http", Host: "secure-proxy:3128"},
+		noProxy: []string{"example.com", ".internal", "10.0.0.0/8", "192.168.1.1"},
+	}
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{url: "http://coreos.com/config.ign"},
+			out: out{proxy: "http://proxy:3128"},
+		},
+		{
+			in:  in{url: "https://coreos.com/config.ign"},
+			out: out{proxy: "http://secure-proxy:3128"},
+		},
+		{
+			in:  in{url: "https://example.com/config.ign"},
+			out: out{},
+		},
+		{
+			in:  in{url: "https://www.Example.com:8443/config.ign"},
+			out: out{},
+		},
+		{
+			in:  in{url: "https://notexample.com/config.ign"},
+			out: out{proxy: "http://secure-proxy:3128"},
+		},
+		{
+			in:  in{url: "http://internal/config.ign"},
+			out: out{proxy: "http://proxy:3128"},
+		},
+		{
+			in:  in{url: "http://config.internal/config.ign"},
+			out: out{},
+		},
+		{
+			in:  in{url: "http://10.1.2.3/config.ign"},
+			out: out{},
+		},
+		{
+			in:  in{url: "http://192.168.1.1:8080/config.ign"},
+			out: out{},
+		},
+		{
+			in:  in{url: "http://192.168.1.2/config.ign"},
+			out: out{proxy: "http://proxy:3128"},
+		},
+		{
+			in:  in{url: "http://169.254.169.254/latest/user-data"},
+			out: out{},
+		},
+		{
+			in:  in{url: "http://localhost:8080/config.ign"},
+			out: out{},
+		},
+		{
+			in:  in{url: "http://[::1]/config.ign"},
+			out: out{},
+		},
+	}
+
+	for i, test := range tests {
+		u, err := url.Parse(test.in.url)
+		if err != nil {
+			t.Fatalf("#%d: bad url: %v", i, err)
+		}
+		proxy := ""
+		if p := settings.proxyFor(u); p != nil {
+			proxy = p.String()
+		}
+		if proxy != test.out.proxy {
+			t.Errorf("#%d: bad proxy: want %q, got %q", i, test.out.proxy, proxy)
+		}
+	}
+}
+
+func TestProxyForWildcard(t *testing.T) {
+	settings := proxySettings{
+		http:    &url.URL{Scheme: "http", Host: "proxy:3128"},
+		noProxy: []string{"*"},
+	}
+	if p := settings.proxyFor(&url.URL{Scheme: "http", Host: "coreos.com"}); p != nil {
+		t.Errorf("bad proxy: want none, got %v", p)
+	}
+}
+
+func TestSetProxy(t *testing.T) {
+	logger := log.New()
+	c := NewHttpClient(&logger)
+	if err := c.SetProxy(types.Proxy{HttpsProxy: "http://proxy:3128", NoProxy: []types.NoProxyItem{"Example.com"}}); err != nil {
+		t.Fatalf("failed to set proxy: %v", err)
+	}
+	transport := c.client.Transport.(*http.Transport)
+	for _, test := range []struct {
+		url   string
+		proxy string
+	}{
+		{url: "https://coreos.com/", proxy: "http://proxy:3128"},
+		{url: "http://coreos.com/", proxy: ""},
+		{url: "https://example.com/", proxy: ""},
+	} {
+		req, _ := http.NewRequest("GET", test.url, nil)
+		p, err := transport.Proxy(req)
+		if err != nil {
+			t.Fatalf("%s: failed to pick proxy: %v", test.url, err)
+		}
+		if (p == nil && test.proxy != "") || (p != nil && p.String() != test.proxy) {
+			t.Errorf("%s: bad proxy: want %q, got %v", test.url, test.proxy, p)
+		}
+	}
+}
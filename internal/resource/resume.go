@@ -0,0 +1,162 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resource
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+	"golang.org/x/net/context/ctxhttp"
+)
+
+// errResourceChanged means the resource changed since the download started,
+// so the rest of it can't be resumed.
+var errResourceChanged = errors.New("resource changed since the download started")
+
+// resumingBody reads the body of a GET response and, if reading it fails
+// partway, resumes it with a Range request from the offset reached rather
+// than failing the download. Everything before that offset has already been
+// passed on, and hashed, by the reader. The Range request carries If-Range,
+// so a resource that changed in the meantime isn't spliced together.
+type resumingBody struct {
+	io.ReadCloser
+	client    HttpClient
+	ctx       context.Context
+	url       string
+	header    http.Header
+	validator string
+	offset    int64
+	resumes   int
+}
+
+// resumable returns the body of resp, which answered the GET request req,
+// resuming it when reading fails if the server supports Range requests for
+// it. Bodies the transport decompressed can't be resumed, since ranges count
+// the compressed bytes.
+func (c HttpClient) resumable(ctx context.Context, req *http.Request, resp *http.Response) io.ReadCloser {
+	if req.Method != "GET" || resp.StatusCode != http.StatusOK || resp.Uncompressed {
+		return resp.Body
+	}
+	if resp.Header.Get("Accept-Ranges") != "bytes" {
+		return resp.Body
+	}
+	validator := resp.Header.Get("ETag")
+	if validator == "" || strings.HasPrefix(validator, "W/") {
+		validator = resp.Header.Get("Last-Modified")
+	}
+	if validator == "" {
+		return resp.Body
+	}
+	header := http.Header{}
+	for key, values := range req.Header {
+		header[key] = append([]string(nil), values...)
+	}
+	return &resumingBody{
+		ReadCloser: resp.Body,
+		client:     c,
+		ctx:        ctx,
+		url:        req.URL.String(),
+		header:     header,
+		validator:  validator,
+	}
+}
+
+func (b *resumingBody) Read(p []byte) (int, error) {
+	for {
+		n, err := b.ReadCloser.Read(p)
+		b.offset += int64(n)
+		if err == nil || err == io.EOF {
+			return n, err
+		}
+		if n > 0 {
+			// The error resurfaces on the next read.
+			return n, nil
+		}
+		if rerr := b.resume(); rerr != nil {
+			b.client.logger.Debug("GET %s: failed to resume at byte %d: %v", redactUrl(b.url), b.offset, rerr)
+			return 0, err
+		}
+	}
+}
+
+// resume replaces the failed body with the rest of the resource, retrying
+// with backoff as many times as a request is attempted.
+func (b *resumingBody) resume() error {
+	duration := initialBackoff
+	var err error
+	for b.resumes < b.client.attempts {
+		b.resumes++
+		b.client.logger.Debug("GET %s: resuming at byte %d: attempt #%d", redactUrl(b.url), b.offset, b.resumes)
+		var body io.ReadCloser
+		if body, err = b.request(); err == nil {
+			b.ReadCloser.Close()
+			b.ReadCloser = body
+			return nil
+		}
+		b.client.logger.Debug("GET resume error: %v", err)
+		if err == errResourceChanged {
+			return err
+		}
+
+		duration = duration * 2
+		if duration > b.client.maxBackoff {
+			duration = b.client.maxBackoff
+		}
+		select {
+		case <-time.After(duration):
+		case <-b.ctx.Done():
+			return b.ctx.Err()
+		}
+	}
+	return err
+}
+
+// request fetches the resource from b.offset on, failing unless the server
+// sends exactly that range of the same resource.
+func (b *resumingBody) request() (io.ReadCloser, error) {
+	req, err := http.NewRequest("GET", b.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range b.header {
+		req.Header[key] = values
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-", b.offset))
+	req.Header.Set("If-Range", b.validator)
+
+	resp, err := ctxhttp.Do(b.ctx, b.client.client, req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusOK {
+		// If-Range sends the whole resource once it has changed.
+		resp.Body.Close()
+		return nil, errResourceChanged
+	}
+	if resp.StatusCode != http.StatusPartialContent {
+		resp.Body.Close()
+		return nil, fmt.Errorf("server answered the range request with %q", resp.Status)
+	}
+	if !strings.HasPrefix(resp.Header.Get("Content-Range"), fmt.Sprintf("bytes %d-", b.offset)) {
+		resp.Body.Close()
+		return nil, fmt.Errorf("server sent the wrong range %q", resp.Header.Get("Content-Range"))
+	}
+	return resp.Body, nil
+}
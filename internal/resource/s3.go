@@ -0,0 +1,102 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resource
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/coreos/ignition/internal/log"
+
+	"golang.org/x/net/context"
+)
+
+// s3Endpoint returns the URL of the object referenced by an s3 URL of the form
+// s3://bucket/key[?versionId=version] in region. Buckets whose names contain
+// dots are addressed by path, since they don't match the wildcard
+// certificate of the virtual-hosted endpoint.
+func s3Endpoint(u url.URL, region string) url.URL {
+	endpoint := url.URL{Scheme: "https", Path: u.Path}
+	if strings.Contains(u.Host, ".") {
+		endpoint.Host = fmt.Sprintf("s3.%s.amazonaws.com", region)
+		endpoint.Path = "/" + u.Host + u.Path
+	} else {
+		endpoint.Host = fmt.Sprintf("%s.s3.%s.amazonaws.com", u.Host, region)
+	}
+	if version := u.Query().Get("versionId"); version != "" {
+		endpoint.RawQuery = url.Values{"versionId": {version}}.Encode()
+	}
+	return endpoint
+}
+
+// fetchS3Object returns a reader over the S3 object referenced by u. Requests
+// are signed with the instance profile credentials of the machine, if it has
+// any, and are otherwise made anonymously for public objects. The bucket is
+// assumed to be in the machine's region; requests are redirected once to the
+// region S3 reports for the bucket.
+func fetchS3Object(l *log.Logger, c *HttpClient, ctx context.Context, u url.URL) (io.ReadCloser, error) {
+	if u.Host == "" || strings.Trim(u.Path, "/") == "" {
+		l.Err("s3 url must name a bucket and key: %q", u.String())
+		return nil, ErrFailed
+	}
+
+	region, err := c.fetchAwsRegion(ctx)
+	if err != nil {
+		l.Err("failed to determine region: %v", err)
+		return nil, ErrFailed
+	}
+	creds, err := c.fetchAwsCredentials(ctx)
+	if err != nil {
+		l.Info("fetching s3 object anonymously: %v", err)
+		creds = awsCredentials{}
+	}
+
+	for redirected := false; ; redirected = true {
+		endpoint := s3Endpoint(u, region)
+		header := http.Header{}
+		if creds.AccessKeyId != "" {
+			payloadHash := sha256.Sum256(nil)
+			header.Set("X-Amz-Content-Sha256", hex.EncodeToString(payloadHash[:]))
+			signAwsRequest("GET", endpoint, header, nil, creds, region, "s3", time.Now())
+		}
+
+		resp, err := c.do(ctx, "GET", endpoint.String(), nil, header)
+		if err != nil {
+			return nil, err
+		}
+		bucketRegion := resp.Header.Get("X-Amz-Bucket-Region")
+		switch {
+		case resp.StatusCode == http.StatusOK:
+			return resp.Body, nil
+		case resp.StatusCode == http.StatusNotFound:
+			resp.Body.Close()
+			return nil, ErrNotFound
+		case bucketRegion != "" && bucketRegion != region && !redirected:
+			resp.Body.Close()
+			l.Info("bucket %q is in region %q", u.Host, bucketRegion)
+			region = bucketRegion
+		default:
+			resp.Body.Close()
+			l.Err("failed to fetch s3 object %q: %s", u.String(), resp.Status)
+			return nil, ErrFailed
+		}
+	}
+}
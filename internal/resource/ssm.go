@@ -0,0 +1,113 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resource
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/coreos/ignition/internal/log"
+
+	"golang.org/x/net/context"
+)
+
+// ssmParameterName returns the name of the parameter referenced by an ssm URL
+// of the form ssm://[region]/name. Names with a single path segment are not
+// fully qualified and are returned without the leading slash.
+func ssmParameterName(u url.URL) string {
+	name := u.Path
+	if strings.Count(name, "/") == 1 {
+		name = strings.TrimPrefix(name, "/")
+	}
+	return name
+}
+
+// fetchSsmParameter returns a reader over the decrypted value of the SSM
+// parameter referenced by u, using the instance profile credentials of the
+// machine. The region is taken from the URL host or, if empty, from the
+// instance metadata.
+func fetchSsmParameter(l *log.Logger, c *HttpClient, ctx context.Context, u url.URL) (io.ReadCloser, error) {
+	name := ssmParameterName(u)
+	if name == "" {
+		l.Err("ssm url has no parameter name: %q", u.String())
+		return nil, ErrFailed
+	}
+
+	region := u.Host
+	if region == "" {
+		var err error
+		if region, err = c.fetchAwsRegion(ctx); err != nil {
+			l.Err("failed to determine region: %v", err)
+			return nil, ErrFailed
+		}
+	}
+	creds, err := c.fetchAwsCredentials(ctx)
+	if err != nil {
+		l.Err("failed to fetch credentials: %v", err)
+		return nil, ErrFailed
+	}
+
+	body, err := json.Marshal(struct {
+		Name           string
+		WithDecryption bool
+	}{name, true})
+	if err != nil {
+		return nil, err
+	}
+	endpoint := url.URL{Scheme: "https", Host: fmt.Sprintf("ssm.%s.amazonaws.com", region), Path: "/"}
+	header := http.Header{}
+	header.Set("Content-Type", "application/x-amz-json-1.1")
+	header.Set("X-Amz-Target", "AmazonSSM.GetParameter")
+	signAwsRequest("POST", endpoint, header, body, creds, region, "ssm", time.Now())
+
+	resp, status, err := c.doWithHeader(ctx, "POST", endpoint.String(), body, header)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Close()
+	data, err := ioutil.ReadAll(resp)
+	if err != nil {
+		return nil, err
+	}
+
+	if status != http.StatusOK {
+		var failure struct {
+			Type string `json:"__type"`
+		}
+		json.Unmarshal(data, &failure)
+		if strings.HasSuffix(failure.Type, "ParameterNotFound") {
+			return nil, ErrNotFound
+		}
+		l.Err("failed to fetch ssm parameter %q: %s %s", name, http.StatusText(status), failure.Type)
+		return nil, ErrFailed
+	}
+
+	var result struct {
+		Parameter struct {
+			Value string
+		}
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		l.Err("failed to parse ssm response: %v", err)
+		return nil, ErrFailed
+	}
+	return ioutil.NopCloser(strings.NewReader(result.Parameter.Value)), nil
+}
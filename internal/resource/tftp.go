@@ -0,0 +1,200 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resource
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/coreos/ignition/internal/log"
+
+	"golang.org/x/net/context"
+)
+
+const (
+	tftpDefaultPort = "69"
+	tftpBlockSize   = 512
+	tftpTimeout     = time.Second
+	tftpAttempts    = 5
+
+	tftpOpReadRequest = 1
+	tftpOpData        = 3
+	tftpOpAck         = 4
+	tftpOpError       = 5
+
+	tftpErrNotFound = 1
+)
+
+var (
+	ErrTftpTimedOut = errors.New("tftp server stopped responding")
+)
+
+// fetchTftp returns a reader over the file referenced by a URL of the form
+// tftp://host[:port]/filename, transferred in octet mode as described by
+// RFC 1350. The file is streamed: each block is only acknowledged, and the
+// next one requested, once the previous one has been read.
+func fetchTftp(l *log.Logger, ctx context.Context, u url.URL) (io.ReadCloser, error) {
+	filename := strings.TrimPrefix(u.Path, "/")
+	if u.Host == "" || filename == "" {
+		l.Err("tftp url must name a host and file: %q", u.String())
+		return nil, ErrFailed
+	}
+	host := u.Host
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		host = net.JoinHostPort(strings.Trim(host, "[]"), tftpDefaultPort)
+	}
+	server, err := net.ResolveUDPAddr("udp", host)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUDP("udp", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	request := &bytes.Buffer{}
+	binary.Write(request, binary.BigEndian, uint16(tftpOpReadRequest))
+	fmt.Fprintf(request, "%s\x00octet\x00", filename)
+
+	l.Debug("tftp: reading %q from %s", filename, server)
+	r := &tftpReader{
+		ctx:      ctx,
+		conn:     conn,
+		packet:   request.Bytes(),
+		to:       server,
+		expected: 1,
+		buf:      make([]byte, 4+tftpBlockSize),
+	}
+	// Wait for the first block, so that a missing file is reported here.
+	if err := r.next(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+// tftpReader reads a file as it is transferred over TFTP.
+type tftpReader struct {
+	ctx  context.Context
+	conn *net.UDPConn
+	// packet is sent to to until it is answered: the request, and then
+	// the acknowledgement of the last block received.
+	packet   []byte
+	to, peer *net.UDPAddr
+	expected uint16
+	buf      []byte
+	pending  []byte // the unread data of the last block received
+	done     bool   // whether the last block has been received
+}
+
+func (r *tftpReader) Read(p []byte) (int, error) {
+	for len(r.pending) == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+		if err := r.next(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
+
+func (r *tftpReader) Close() error {
+	return r.conn.Close()
+}
+
+// next receives the next block of the file.
+func (r *tftpReader) next() error {
+	for {
+		n, from, err := tftpExchange(r.ctx, r.conn, r.packet, r.to, r.peer, r.buf)
+		if err != nil {
+			return err
+		}
+		if n < 4 {
+			continue
+		}
+
+		switch binary.BigEndian.Uint16(r.buf) {
+		case tftpOpError:
+			if binary.BigEndian.Uint16(r.buf[2:]) == tftpErrNotFound {
+				return ErrNotFound
+			}
+			return fmt.Errorf("tftp error: %s", strings.TrimRight(string(r.buf[4:n]), "\x00"))
+		case tftpOpData:
+			// The server answers from a port of its own, which identifies
+			// the transfer from then on.
+			if r.peer == nil {
+				r.peer = from
+			}
+			block := binary.BigEndian.Uint16(r.buf[2:])
+			ack := make([]byte, 4)
+			binary.BigEndian.PutUint16(ack, tftpOpAck)
+			binary.BigEndian.PutUint16(ack[2:], block)
+			r.packet, r.to = ack, r.peer
+			if block != r.expected {
+				// A duplicate of a block already received; acknowledge
+				// it again.
+				continue
+			}
+			r.pending = r.buf[4:n]
+			r.expected++
+			if n-4 < tftpBlockSize {
+				r.conn.WriteToUDP(ack, r.peer)
+				r.done = true
+			}
+			return nil
+		}
+	}
+}
+
+// tftpExchange sends packet to addr and waits for the reply from peer, or
+// from any address if peer is nil, resending packet when no reply arrives in
+// time.
+func tftpExchange(ctx context.Context, conn *net.UDPConn, packet []byte, addr, peer *net.UDPAddr, buf []byte) (int, *net.UDPAddr, error) {
+	for attempt := 0; attempt < tftpAttempts; attempt++ {
+		if _, err := conn.WriteToUDP(packet, addr); err != nil {
+			return 0, nil, err
+		}
+		deadline := time.Now().Add(tftpTimeout)
+		for {
+			select {
+			case <-ctx.Done():
+				return 0, nil, ctx.Err()
+			default:
+			}
+			conn.SetReadDeadline(deadline)
+			n, from, err := conn.ReadFromUDP(buf)
+			if err, ok := err.(net.Error); ok && err.Timeout() {
+				break
+			} else if err != nil {
+				return 0, nil, err
+			}
+			if peer != nil && (!from.IP.Equal(peer.IP) || from.Port != peer.Port) {
+				continue
+			}
+			return n, from, nil
+		}
+	}
+	return 0, nil, ErrTftpTimedOut
+}
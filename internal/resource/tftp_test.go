@@ -0,0 +1,158 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resource
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coreos/ignition/internal/log"
+
+	"golang.org/x/net/context"
+)
+
+// serveTftp answers read requests on conn with the files in files, sending
+// each block from a separate port as a real server would. When duplicate is
+// set, every block is sent twice, as happens when an ack is lost.
+func serveTftp(t *testing.T, conn *net.UDPConn, files map[string][]byte, duplicate bool) {
+	buf := make([]byte, 1024)
+	for {
+		n, client, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+		fields := strings.Split(string(buf[2:n]), "\x00")
+		go func(filename string) {
+			transfer, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+			if err != nil {
+				t.Errorf("failed to listen: %v", err)
+				return
+			}
+			defer transfer.Close()
+			transfer.SetReadDeadline(time.Now().Add(2 * time.Second))
+
+			data, ok := files[filename]
+			if !ok {
+				packet := []byte{0, tftpOpError, 0, tftpErrNotFound}
+				transfer.WriteToUDP(append(packet, "not found\x00"...), client)
+				return
+			}
+			ack := make([]byte, 4)
+			for block := 1; ; block++ {
+				end := block * tftpBlockSize
+				if end > len(data) {
+					end = len(data)
+				}
+				packet := &bytes.Buffer{}
+				binary.Write(packet, binary.BigEndian, uint16(tftpOpData))
+				binary.Write(packet, binary.BigEndian, uint16(block))
+				packet.Write(data[(block-1)*tftpBlockSize : end])
+				transfer.WriteToUDP(packet.Bytes(), client)
+				if duplicate {
+					transfer.WriteToUDP(packet.Bytes(), client)
+				}
+				if _, _, err := transfer.ReadFromUDP(ack); err != nil {
+					return
+				}
+				if int(binary.BigEndian.Uint16(ack[2:])) != block {
+					t.Errorf("bad ack: want %d, got %d", block, binary.BigEndian.Uint16(ack[2:]))
+					return
+				}
+				if end-(block-1)*tftpBlockSize < tftpBlockSize {
+					return
+				}
+				if duplicate {
+					// The duplicate is acknowledged again.
+					if _, _, err := transfer.ReadFromUDP(ack); err != nil {
+						return
+					}
+				}
+			}
+		}(fields[0])
+	}
+}
+
+func TestFetchTftp(t *testing.T) {
+	type in struct {
+		path      string
+		duplicate bool
+	}
+	type out struct {
+		data []byte
+		err  error
+	}
+
+	files := map[string][]byte{
+		"empty":              []byte{},
+		"pxelinux.cfg/ign":   bytes.Repeat([]byte("a"), 1200),
+		"exactly-two-blocks": bytes.Repeat([]byte("b"), 2*tftpBlockSize),
+		"configs/worker.ign": []byte(`{"ignition":{"version":"2.1.0-experimental"}}`),
+	}
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{path: "/empty"},
+			out: out{data: files["empty"]},
+		},
+		{
+			in:  in{path: "/pxelinux.cfg/ign"},
+			out: out{data: files["pxelinux.cfg/ign"]},
+		},
+		{
+			in:  in{path: "/exactly-two-blocks"},
+			out: out{data: files["exactly-two-blocks"]},
+		},
+		{
+			in:  in{path: "/configs/worker.ign", duplicate: true},
+			out: out{data: files["configs/worker.ign"]},
+		},
+		{
+			in:  in{path: "/missing"},
+			out: out{err: ErrNotFound},
+		},
+	}
+
+	logger := log.New()
+	for i, test := range tests {
+		conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+		if err != nil {
+			t.Fatalf("failed to listen: %v", err)
+		}
+		go serveTftp(t, conn, files, test.in.duplicate)
+
+		u := url.URL{Scheme: "tftp", Host: conn.LocalAddr().String(), Path: test.in.path}
+		reader, err := fetchTftp(&logger, context.Background(), u)
+		conn.Close()
+		if err != test.out.err {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.out.err, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		data, _ := ioutil.ReadAll(reader)
+		if !bytes.Equal(data, test.out.data) {
+			t.Errorf("#%d: bad data: want %d bytes, got %d", i, len(test.out.data), len(data))
+		}
+	}
+}
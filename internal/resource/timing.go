@@ -0,0 +1,88 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resource
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// FetchTiming records how long a request took, from its first attempt until
+// its response body was closed, so that the time spent downloading is
+// included.
+type FetchTiming struct {
+	Method string
+	// Url is redacted of any credentials.
+	Url string
+	// Status is the status code of the last response, or 0 if none was
+	// received.
+	Status   int
+	Attempts int
+	Duration time.Duration
+}
+
+// timingState is shared by the copies of a client, so that the requests the
+// stages make from their own copies are recorded too.
+type timingState struct {
+	sync.Mutex
+	timings []FetchTiming
+}
+
+// TakeTimings returns the timings of the requests finished since the last
+// call.
+func (c HttpClient) TakeTimings() []FetchTiming {
+	if c.timing == nil {
+		return nil
+	}
+	c.timing.Lock()
+	defer c.timing.Unlock()
+	timings := c.timing.timings
+	c.timing.timings = nil
+	return timings
+}
+
+// recordTiming records timing as finishing now, for a request started at
+// start.
+func (c HttpClient) recordTiming(timing FetchTiming, start time.Time) {
+	timing.Duration = time.Since(start)
+	c.logger.Debug("%s %s: took %v", timing.Method, timing.Url, timing.Duration)
+	if c.timing == nil {
+		return
+	}
+	c.timing.Lock()
+	defer c.timing.Unlock()
+	c.timing.timings = append(c.timing.timings, timing)
+}
+
+// timedBody records the timing of its request once it is closed.
+type timedBody struct {
+	io.ReadCloser
+	once   sync.Once
+	record func()
+}
+
+func (b *timedBody) Close() error {
+	b.once.Do(b.record)
+	return b.ReadCloser.Close()
+}
+
+// timeBody wraps body so that closing it records timing.
+func (c HttpClient) timeBody(body io.ReadCloser, timing FetchTiming, start time.Time) io.ReadCloser {
+	return &timedBody{
+		ReadCloser: body,
+		record:     func() { c.recordTiming(timing, start) },
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resource
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net/http"
+)
+
+var (
+	ErrNoCertificates = errors.New("no PEM-encoded certificates found")
+)
+
+// trustState is shared by the copies of a client, so that the CAs added while
+// rendering the config are also trusted by the stages.
+type trustState struct {
+	roots   *x509.CertPool
+	sources map[string]bool
+}
+
+// tlsConfig returns the TLS configuration of the client's transport, creating
+// it if needed.
+func (c HttpClient) tlsConfig() *tls.Config {
+	transport := c.client.Transport.(*http.Transport)
+	if transport.TLSClientConfig == nil {
+		transport.TLSClientConfig = &tls.Config{}
+	}
+	return transport.TLSClientConfig
+}
+
+// AddCA trusts the PEM-encoded CA certificates fetched from source, in
+// addition to the system's CAs, for https fetches.
+func (c HttpClient) AddCA(source string, pem []byte) error {
+	if c.trust.sources[source] {
+		return nil
+	}
+	if c.trust.roots == nil {
+		roots, err := x509.SystemCertPool()
+		if err != nil {
+			c.logger.Warning("failed to load system CAs, trusting only the provided CAs: %v", err)
+			roots = x509.NewCertPool()
+		}
+		c.trust.roots = roots
+	}
+	if !c.trust.roots.AppendCertsFromPEM(pem) {
+		return ErrNoCertificates
+	}
+	c.tlsConfig().RootCAs = c.trust.roots
+	c.trust.sources[source] = true
+	return nil
+}
+
+// HasCA reports whether the CAs from source were already added.
+func (c HttpClient) HasCA(source string) bool {
+	return c.trust.sources[source]
+}
+
+// SetClientCertificate presents the PEM-encoded certificate and key to https
+// servers which request client authentication.
+func (c HttpClient) SetClientCertificate(certPem, keyPem []byte) error {
+	cert, err := tls.X509KeyPair(certPem, keyPem)
+	if err != nil {
+		return err
+	}
+	c.tlsConfig().Certificates = []tls.Certificate{cert}
+	return nil
+}
@@ -0,0 +1,132 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resource
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/coreos/ignition/internal/log"
+
+	"golang.org/x/net/context"
+)
+
+func TestAddCA(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "trusted")
+	}))
+	defer server.Close()
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+
+	logger := log.New()
+	c := NewHttpClient(&logger)
+	c.SetTimeout(500 * time.Millisecond)
+
+	if _, _, err := c.getReaderWithHeader(context.Background(), server.URL, nil); err == nil {
+		t.Fatalf("fetched from a server with an untrusted certificate")
+	}
+
+	if err := c.AddCA("data:,ca", []byte("not a certificate")); err != ErrNoCertificates {
+		t.Errorf("bad error: want %v, got %v", ErrNoCertificates, err)
+	}
+	if err := c.AddCA("data:,ca", ca); err != nil {
+		t.Fatalf("failed to add CA: %v", err)
+	}
+	if !c.HasCA("data:,ca") {
+		t.Errorf("CA not recorded as added")
+	}
+
+	body, status, err := c.getReaderWithHeader(context.Background(), server.URL, nil)
+	if err != nil {
+		t.Fatalf("failed to fetch with the added CA: %v", err)
+	}
+	defer body.Close()
+	data, _ := ioutil.ReadAll(body)
+	if status != http.StatusOK || string(data) != "trusted" {
+		t.Errorf("bad response: %d %q", status, data)
+	}
+}
+
+func TestSetClientCertificate(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+	certPem := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPem := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, r.TLS.PeerCertificates[0].Subject.CommonName)
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	server.StartTLS()
+	defer server.Close()
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+
+	logger := log.New()
+	c := NewHttpClient(&logger)
+	c.SetTimeout(500 * time.Millisecond)
+	if err := c.AddCA("data:,ca", ca); err != nil {
+		t.Fatalf("failed to add CA: %v", err)
+	}
+
+	if _, _, err := c.getReaderWithHeader(context.Background(), server.URL, nil); err == nil {
+		t.Fatalf("fetched without a client certificate")
+	}
+
+	if err := c.SetClientCertificate(certPem, []byte("not a key")); err == nil {
+		t.Errorf("accepted an invalid key")
+	}
+	if err := c.SetClientCertificate(certPem, keyPem); err != nil {
+		t.Fatalf("failed to set client certificate: %v", err)
+	}
+
+	body, status, err := c.getReaderWithHeader(context.Background(), server.URL, nil)
+	if err != nil {
+		t.Fatalf("failed to fetch with the client certificate: %v", err)
+	}
+	defer body.Close()
+	data, _ := ioutil.ReadAll(body)
+	if status != http.StatusOK || string(data) != "client" {
+		t.Errorf("bad response: %d %q", status, data)
+	}
+}
@@ -24,6 +24,7 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"sync"
 	"syscall"
 
 	"github.com/coreos/ignition/internal/log"
@@ -54,7 +55,10 @@ func FetchConfig(l *log.Logger, c *HttpClient, ctx context.Context, u url.URL) (
 // FetchConfigWithHeader fetches a raw config from the provided URL and returns
 // the response body on success or nil on failure. The HTTP response must be
 // OK, otherwise an empty (v.s. nil) config is returned. The provided headers
-// are merged with a set of default headers.
+// are merged with a set of default headers. Configs larger than the client's
+// MaxConfigSize fail with ErrTooLarge. An HTTP config fetched again with the
+// same client is requested conditionally, with the ETag it was last served
+// with, and left as it was if the server answers that it is unchanged.
 func FetchConfigWithHeader(l *log.Logger, c *HttpClient, ctx context.Context, u url.URL, h http.Header) ([]byte, error) {
 	header := http.Header{
 		"Accept-Encoding": []string{"identity"},
@@ -67,26 +71,108 @@ func FetchConfigWithHeader(l *log.Logger, c *HttpClient, ctx context.Context, u
 		}
 	}
 
-	data, err := FetchWithHeader(l, c, ctx, u, header)
+	if (u.Scheme == "http" || u.Scheme == "https") && c != nil && c.etags != nil {
+		return c.fetchConfigConditionally(ctx, u.String(), header)
+	}
+
+	reader, err := FetchAsReaderWithHeader(l, c, ctx, u, header)
 	switch err {
 	case nil:
-		return data, nil
 	case ErrNotFound:
 		return []byte{}, nil
 	default:
 		return nil, err
 	}
+	defer reader.Close()
+
+	var max int64
+	if c != nil {
+		max = c.maxConfigSize
+	}
+	return ReadAtMost(reader, max)
+}
+
+// etagCache is shared by the copies of a client, so that a config fetched by
+// one is revalidated rather than fetched anew by the others.
+type etagCache struct {
+	sync.Mutex
+	configs map[string]etagConfig
+}
+
+type etagConfig struct {
+	etag string
+	data []byte
+}
+
+// fetchConfigConditionally fetches the config at rawurl as
+// FetchConfigWithHeader does, sending If-None-Match with the ETag of the copy
+// last fetched from rawurl, if any, and returning that copy if the server
+// answers 304. Configs served with an ETag are kept for the next fetch.
+func (c HttpClient) fetchConfigConditionally(ctx context.Context, rawurl string, header http.Header) ([]byte, error) {
+	c.etags.Lock()
+	cached, ok := c.etags.configs[rawurl]
+	c.etags.Unlock()
+	if ok && header.Get("If-None-Match") == "" {
+		header.Set("If-None-Match", cached.etag)
+	}
+
+	resp, err := c.GetWithHeader(ctx, rawurl, header)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusNoContent:
+	case http.StatusNotModified:
+		if !ok {
+			return nil, ErrFailed
+		}
+		c.logger.Debug("%s is unchanged since it was last fetched", redactUrl(rawurl))
+		return cached.data, nil
+	case http.StatusNotFound:
+		return []byte{}, nil
+	default:
+		return nil, ErrFailed
+	}
+
+	data, err := ReadAtMost(resp.Body, c.maxConfigSize)
+	if err != nil {
+		return nil, err
+	}
+	if etag := resp.Header.Get("ETag"); etag != "" && resp.StatusCode == http.StatusOK {
+		c.etags.Lock()
+		c.etags.configs[rawurl] = etagConfig{etag: etag, data: data}
+		c.etags.Unlock()
+	}
+	return data, nil
+}
+
+// ReadAtMost reads r to its end, failing with ErrTooLarge rather than reading
+// more than max bytes. A max of 0 or less reads without bound.
+func ReadAtMost(r io.Reader, max int64) ([]byte, error) {
+	if max <= 0 {
+		return ioutil.ReadAll(r)
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(r, max+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > max {
+		return nil, ErrTooLarge
+	}
+	return data, nil
 }
 
 // Fetch fetches a resource given a URL. The supported schemes are
-// http, data, and oem.
+// http, https, data, oem, ssm, s3, gs, and tftp.
 func Fetch(l *log.Logger, c *HttpClient, ctx context.Context, u url.URL) ([]byte, error) {
 	return FetchWithHeader(l, c, ctx, u, http.Header{})
 }
 
 // FetchWithHeader fetches a resource given a URL. If the resource is
 // of the http or https scheme, the provided header will be used when
-// fetching. The supported schemes are http, data, and oem.
+// fetching. The supported schemes are http, https, data, oem, ssm, s3, gs, and tftp.
 func FetchWithHeader(l *log.Logger, c *HttpClient, ctx context.Context, u url.URL, h http.Header) ([]byte, error) {
 	var data []byte
 
@@ -149,6 +235,21 @@ func FetchAsReaderWithHeader(l *log.Logger, c *HttpClient, ctx context.Context,
 		}
 		return ioutil.NopCloser(bytes.NewReader(url.Data)), nil
 
+	case "ssm":
+		return fetchSsmParameter(l, c, ctx, u)
+
+	case "s3":
+		return fetchS3Object(l, c, ctx, u)
+
+	case "gs":
+		return fetchGsObject(l, c, ctx, u)
+
+	case "tftp":
+		if err := c.refuseOffline(); err != nil {
+			return nil, err
+		}
+		return fetchTftp(l, ctx, u)
+
 	case "oem":
 		path := filepath.Clean(u.Path)
 		if !filepath.IsAbs(path) {
@@ -0,0 +1,172 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resource
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/coreos/ignition/internal/log"
+
+	"golang.org/x/net/context"
+)
+
+func TestFetchDataUrl(t *testing.T) {
+	type in struct {
+		url string
+	}
+	type out struct {
+		data string
+		err  bool
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{url: "data:,hello"},
+			out: out{data: "hello"},
+		},
+		{
+			in:  in{url: "data:,hello%2C%20world%0A"},
+			out: out{data: "hello, world\n"},
+		},
+		{
+			in:  in{url: "data:text/plain;charset=utf-8;base64,aGVsbG8sIHdvcmxkCg=="},
+			out: out{data: "hello, world\n"},
+		},
+		{
+			in:  in{url: "data:;base64,aGVsbG8"},
+			out: out{err: true},
+		},
+		{
+			in:  in{url: "data:hello"},
+			out: out{err: true},
+		},
+	}
+
+	logger := log.New()
+	c := NewHttpClient(&logger)
+	for i, test := range tests {
+		u, err := url.Parse(test.in.url)
+		if err != nil {
+			t.Fatalf("#%d: bad url: %v", i, err)
+		}
+		data, err := Fetch(&logger, &c, context.Background(), *u)
+		if (err != nil) != test.out.err {
+			t.Errorf("#%d: bad error: want error %t, got %v", i, test.out.err, err)
+			continue
+		}
+		if string(data) != test.out.data {
+			t.Errorf("#%d: bad data: want %q, got %q", i, test.out.data, data)
+		}
+	}
+}
+
+func TestGsEndpoint(t *testing.T) {
+	type in struct {
+		u string
+	}
+	type out struct {
+		endpoint string
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{u: "gs://configs/worker.ign"},
+			out: out{endpoint: "https://storage.googleapis.com/configs/worker.ign"},
+		},
+		{
+			in:  in{u: "gs://configs.example.com/nodes/worker%20a.ign"},
+			out: out{endpoint: "https://storage.googleapis.com/configs.example.com/nodes/worker%20a.ign"},
+		},
+	}
+
+	for i, test := range tests {
+		u, err := url.Parse(test.in.u)
+		if err != nil {
+			t.Fatalf("#%d: bad url: %v", i, err)
+		}
+		endpoint := gsEndpoint(*u)
+		if endpoint.String() != test.out.endpoint {
+			t.Errorf("#%d: bad endpoint: want %q, got %q", i, test.out.endpoint, endpoint.String())
+		}
+	}
+}
+
+func TestFetchConfigConditionally(t *testing.T) {
+	type in struct {
+		etag string
+	}
+	type out struct {
+		notModified int
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{etag: `"v1"`},
+			out: out{notModified: 1},
+		},
+		{
+			in:  in{},
+			out: out{notModified: 0},
+		},
+	}
+
+	logger := log.New()
+	for i, test := range tests {
+		notModified := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if test.in.etag != "" {
+				if r.Header.Get("If-None-Match") == test.in.etag {
+					notModified++
+					w.WriteHeader(http.StatusNotModified)
+					return
+				}
+				w.Header().Set("ETag", test.in.etag)
+			}
+			w.Write([]byte(`{"ignition":{"version":"2.0.0"}}`))
+		}))
+		u, err := url.Parse(server.URL + "/config.ign")
+		if err != nil {
+			t.Fatalf("#%d: bad url: %v", i, err)
+		}
+
+		// A copy of the client revalidates what the first one fetched.
+		client := NewHttpClient(&logger)
+		for _, c := range []HttpClient{client, client.WithLogger(&logger)} {
+			data, err := FetchConfig(&logger, &c, context.Background(), *u)
+			if err != nil {
+				t.Errorf("#%d: unexpected error: %v", i, err)
+			}
+			if string(data) != `{"ignition":{"version":"2.0.0"}}` {
+				t.Errorf("#%d: bad config: %q", i, data)
+			}
+		}
+		server.Close()
+		if notModified != test.out.notModified {
+			t.Errorf("#%d: bad revalidations: want %d, got %d", i, test.out.notModified, notModified)
+		}
+	}
+}
@@ -12,9 +12,10 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-package util
+package resource
 
 import (
+	"crypto/sha256"
 	"crypto/sha512"
 	"encoding/hex"
 	"fmt"
@@ -34,26 +35,19 @@ func (e ErrHashMismatch) Error() string {
 }
 
 func AssertValid(verify types.Verification, data []byte) error {
-	if hash := verify.Hash; hash != nil {
-		var sum []byte
-		switch hash.Function {
-		case "sha512":
-			rawSum := sha512.Sum512(data)
-			sum = rawSum[:]
-		default:
-			return types.ErrHashUnrecognized
-		}
+	hasher, err := GetHasher(verify)
+	if err != nil || hasher == nil {
+		return err
+	}
 
-		encodedSum := make([]byte, hex.EncodedLen(len(sum)))
-		hex.Encode(encodedSum, sum)
-		if string(encodedSum) != hash.Sum {
-			return ErrHashMismatch{
-				Calculated: string(encodedSum),
-				Expected:   hash.Sum,
-			}
+	hasher.Write(data)
+	encodedSum := hex.EncodeToString(hasher.Sum(nil))
+	if encodedSum != verify.Hash.Sum {
+		return ErrHashMismatch{
+			Calculated: encodedSum,
+			Expected:   verify.Hash.Sum,
 		}
 	}
-
 	return nil
 }
 
@@ -63,6 +57,8 @@ func GetHasher(verify types.Verification) (hash.Hash, error) {
 	}
 
 	switch verify.Hash.Function {
+	case "sha256":
+		return sha256.New(), nil
 	case "sha512":
 		return sha512.New(), nil
 	default:
@@ -12,7 +12,7 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-package util
+package resource
 
 import (
 	"reflect"
@@ -50,6 +50,18 @@ func TestAssertValid(t *testing.T) {
 			},
 			out: out{},
 		},
+		{
+			in: in{
+				verification: types.Verification{
+					Hash: &types.Hash{
+						Function: "sha256",
+						Sum:      "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824",
+					},
+				},
+				data: []byte("hello"),
+			},
+			out: out{},
+		},
 		{
 			in: in{
 				verification: types.Verification{
@@ -15,27 +15,34 @@
 package sgdisk
 
 import (
+	"bufio"
+	"bytes"
 	"fmt"
-	"os/exec"
+	"strconv"
+	"strings"
 
 	"github.com/coreos/ignition/internal/log"
+	"github.com/coreos/ignition/internal/tool"
 )
 
-const sgdiskPath = "/sbin/sgdisk"
-
 type Operation struct {
-	logger *log.Logger
-	dev    string
-	wipe   bool
-	parts  []Partition
+	logger     *log.Logger
+	dev        string
+	wipe       bool
+	moveBackup bool
+	alignment  uint64
+	deletions  []int
+	parts      []Partition
 }
 
 type Partition struct {
 	Number   int
-	Offset   uint64 // 512-byte sectors
-	Length   uint64 // 512-byte sectors
+	Offset   uint64 // logical sectors
+	Length   uint64 // logical sectors
 	Label    string
 	TypeGUID string
+	GUID     string // unique partition GUID; random if empty
+	Bootable bool   // sets the legacy BIOS bootable attribute
 }
 
 // Begin begins an sgdisk operation
@@ -49,6 +56,25 @@ func (op *Operation) CreatePartition(p Partition) {
 	op.parts = append(op.parts, p)
 }
 
+// DeletePartition adds the numbered partition to the list of partitions to be
+// deleted, before any are created, as part of an operation.
+func (op *Operation) DeletePartition(number int) {
+	op.deletions = append(op.deletions, number)
+}
+
+// MoveBackupHeader toggles if the backup GPT header is to be moved to the end
+// of the disk, as is needed after the disk has been enlarged.
+func (op *Operation) MoveBackupHeader(move bool) {
+	op.moveBackup = move
+}
+
+// SetAlignment sets the boundary, in sectors, to which the starts of the
+// partitions created are aligned. sgdisk's default of 2048 sectors is used if
+// it is 0.
+func (op *Operation) SetAlignment(sectors uint64) {
+	op.alignment = sectors
+}
+
 // WipeTable toggles if the table is to be wiped first when commiting this operation.
 func (op *Operation) WipeTable(wipe bool) {
 	op.wipe = wipe
@@ -57,31 +83,126 @@ func (op *Operation) WipeTable(wipe bool) {
 // Commit commits an partitioning operation.
 func (op *Operation) Commit() error {
 	if op.wipe {
-		cmd := exec.Command(sgdiskPath, "--zap-all", op.dev)
+		cmd := tool.Sgdisk.Command("--zap-all", op.dev)
 		if err := op.logger.LogCmd(cmd, "wiping table on %q", op.dev); err != nil {
 			op.logger.Info("potential error encountered while wiping table... retrying")
-			cmd = exec.Command(sgdiskPath, "--zap-all", op.dev)
+			cmd = tool.Sgdisk.Command("--zap-all", op.dev)
 			if err := op.logger.LogCmd(cmd, "wiping table on %q", op.dev); err != nil {
 				return fmt.Errorf("wipe failed: %v", err)
 			}
 		}
 	}
 
-	if len(op.parts) != 0 {
+	if op.moveBackup || len(op.deletions) != 0 || len(op.parts) != 0 {
 		opts := []string{}
+		if op.moveBackup {
+			opts = append(opts, "--move-second-header")
+		}
+		if op.alignment != 0 {
+			opts = append(opts, fmt.Sprintf("--set-alignment=%d", op.alignment))
+		}
+		for _, n := range op.deletions {
+			opts = append(opts, fmt.Sprintf("--delete=%d", n))
+		}
 		for _, p := range op.parts {
 			opts = append(opts, fmt.Sprintf("--new=%d:%d:+%d", p.Number, p.Offset, p.Length))
 			opts = append(opts, fmt.Sprintf("--change-name=%d:%s", p.Number, p.Label))
 			if p.TypeGUID != "" {
 				opts = append(opts, fmt.Sprintf("--typecode=%d:%s", p.Number, p.TypeGUID))
 			}
+			if p.GUID != "" {
+				opts = append(opts, fmt.Sprintf("--partition-guid=%d:%s", p.Number, p.GUID))
+			}
+			if p.Bootable {
+				opts = append(opts, fmt.Sprintf("--attributes=%d:set:2", p.Number))
+			}
 		}
 		opts = append(opts, op.dev)
-		cmd := exec.Command(sgdiskPath, opts...)
-		if err := op.logger.LogCmd(cmd, "creating %d partitions on %q", len(op.parts), op.dev); err != nil {
+		cmd := tool.Sgdisk.Command(opts...)
+		if err := op.logger.LogCmd(cmd, "deleting %d and creating %d partitions on %q", len(op.deletions), len(op.parts), op.dev); err != nil {
 			return fmt.Errorf("create partitions failed: %v", err)
 		}
 	}
 
 	return nil
 }
+
+// Partitions returns the partitions currently present in the partition table
+// of dev.
+func Partitions(logger *log.Logger, dev string) ([]Partition, error) {
+	logger.Debug("reading partition table of %q", dev)
+	out, err := tool.Sgdisk.Command("--print", dev).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read partition table: %v", err)
+	}
+
+	// The partition entries follow the "Number  Start (sector) ..." header.
+	numbers := []int{}
+	inTable := false
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if !inTable {
+			inTable = len(fields) > 0 && fields[0] == "Number"
+			continue
+		}
+		if len(fields) == 0 {
+			continue
+		}
+		n, err := strconv.Atoi(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse partition entry %q: %v", scanner.Text(), err)
+		}
+		numbers = append(numbers, n)
+	}
+
+	parts := []Partition{}
+	for _, n := range numbers {
+		p, err := partitionInfo(dev, n)
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, p)
+	}
+
+	return parts, nil
+}
+
+// partitionInfo returns the details of partition number n on dev.
+func partitionInfo(dev string, n int) (Partition, error) {
+	out, err := tool.Sgdisk.Command(fmt.Sprintf("--info=%d", n), dev).Output()
+	if err != nil {
+		return Partition{}, fmt.Errorf("failed to read partition %d: %v", n, err)
+	}
+
+	p := Partition{Number: n}
+	var first, last uint64
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		kv := strings.SplitN(scanner.Text(), ":", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		value := strings.TrimSpace(kv[1])
+
+		switch kv[0] {
+		case "Partition GUID code":
+			p.TypeGUID = strings.Fields(value)[0]
+		case "Partition unique GUID":
+			p.GUID = value
+		case "First sector":
+			first, err = strconv.ParseUint(strings.Fields(value)[0], 10, 64)
+		case "Last sector":
+			last, err = strconv.ParseUint(strings.Fields(value)[0], 10, 64)
+		case "Partition name":
+			p.Label = strings.Trim(value, "'")
+		}
+		if err != nil {
+			return Partition{}, fmt.Errorf("failed to parse partition %d: %v", n, err)
+		}
+	}
+
+	p.Offset = first
+	p.Length = last - first + 1
+	return p, nil
+}
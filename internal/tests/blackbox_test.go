@@ -0,0 +1,217 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build blackbox
+// +build blackbox
+
+package tests
+
+import (
+	"os"
+	"testing"
+)
+
+type partition struct {
+	device string
+	label  string
+	// size is in 512-byte sectors.
+	size string
+}
+
+type filesystem struct {
+	device string
+	format string
+	label  string
+}
+
+type file struct {
+	// device holds the file's filesystem, which is the target root if it
+	// is empty.
+	device   string
+	format   string
+	path     string
+	contents string
+	mode     os.FileMode
+}
+
+func TestBlackbox(t *testing.T) {
+	type in struct {
+		disks  []int
+		config string
+	}
+	type out struct {
+		partitions  []partition
+		filesystems []filesystem
+		files       []file
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		// Partitions are created as requested.
+		{
+			in: in{
+				disks: []int{64},
+				config: `{
+					"ignition": {"version": "2.1.0-experimental"},
+					"storage": {
+						"disks": [{
+							"device": "$disk0",
+							"wipeTable": true,
+							"partitions": [
+								{"number": 1, "label": "BB-ONE", "size": 32768},
+								{"number": 2, "label": "BB-TWO", "size": 16384}
+							]
+						}]
+					}
+				}`,
+			},
+			out: out{
+				partitions: []partition{
+					{device: "$disk0p1", label: "BB-ONE", size: "32768"},
+					{device: "$disk0p2", label: "BB-TWO", size: "16384"},
+				},
+			},
+		},
+		// Filesystems are created on the new partitions.
+		{
+			in: in{
+				disks: []int{64},
+				config: `{
+					"ignition": {"version": "2.1.0-experimental"},
+					"storage": {
+						"disks": [{
+							"device": "$disk0",
+							"wipeTable": true,
+							"partitions": [{"number": 1, "label": "BB-DATA"}]
+						}],
+						"filesystems": [{
+							"name": "data",
+							"mount": {
+								"device": "$disk0p1",
+								"format": "ext4",
+								"create": {},
+								"label": "DATA"
+							}
+						}]
+					}
+				}`,
+			},
+			out: out{
+				filesystems: []filesystem{
+					{device: "$disk0p1", format: "ext4", label: "DATA"},
+				},
+			},
+		},
+		// Files are written to the root and into the filesystems mounted
+		// beneath it, which the umount stage then detaches.
+		{
+			in: in{
+				disks: []int{64},
+				config: `{
+					"ignition": {"version": "2.1.0-experimental"},
+					"storage": {
+						"disks": [{
+							"device": "$disk0",
+							"wipeTable": true,
+							"partitions": [{"number": 1, "label": "BB-VAR"}]
+						}],
+						"filesystems": [{
+							"name": "var",
+							"mount": {
+								"device": "$disk0p1",
+								"format": "ext4",
+								"create": {},
+								"path": "/var"
+							}
+						}],
+						"files": [
+							{
+								"filesystem": "root",
+								"path": "/etc/hostname",
+								"mode": 420,
+								"contents": {"source": "data:,blackbox%0A"}
+							},
+							{
+								"filesystem": "var",
+								"path": "/lib/blackbox/state",
+								"mode": 384,
+								"contents": {"source": "data:,on-disk"}
+							}
+						]
+					}
+				}`,
+			},
+			out: out{
+				files: []file{
+					{path: "/etc/hostname", contents: "blackbox\n", mode: 0644},
+					{device: "$disk0p1", format: "ext4", path: "/lib/blackbox/state", contents: "on-disk", mode: 0600},
+				},
+			},
+		},
+	}
+
+	for i, test := range tests {
+		h := newHarness(t, test.in.disks)
+		if err := h.run(test.in.config); err != nil {
+			t.Errorf("#%d: stages failed: %v", i, err)
+			h.cleanup()
+			continue
+		}
+
+		for _, p := range test.out.partitions {
+			props, err := probe(h.expand(p.device))
+			if err != nil {
+				t.Errorf("#%d: %v", i, err)
+				continue
+			}
+			if props["PART_ENTRY_NAME"] != p.label {
+				t.Errorf("#%d: bad label of %s: want %q, got %q", i, p.device, p.label, props["PART_ENTRY_NAME"])
+			}
+			if props["PART_ENTRY_SIZE"] != p.size {
+				t.Errorf("#%d: bad size of %s: want %s, got %s", i, p.device, p.size, props["PART_ENTRY_SIZE"])
+			}
+		}
+
+		for _, fs := range test.out.filesystems {
+			props, err := probe(h.expand(fs.device))
+			if err != nil {
+				t.Errorf("#%d: %v", i, err)
+				continue
+			}
+			if props["TYPE"] != fs.format {
+				t.Errorf("#%d: bad format of %s: want %q, got %q", i, fs.device, fs.format, props["TYPE"])
+			}
+			if props["LABEL"] != fs.label {
+				t.Errorf("#%d: bad filesystem label of %s: want %q, got %q", i, fs.device, fs.label, props["LABEL"])
+			}
+		}
+
+		for _, f := range test.out.files {
+			contents, info, err := h.readFile(h.expand(f.device), f.format, f.path)
+			if err != nil {
+				t.Errorf("#%d: failed to read %s: %v", i, f.path, err)
+				continue
+			}
+			if string(contents) != f.contents {
+				t.Errorf("#%d: bad contents of %s: want %q, got %q", i, f.path, f.contents, contents)
+			}
+			if info.Mode() != f.mode {
+				t.Errorf("#%d: bad mode of %s: want %v, got %v", i, f.path, f.mode, info.Mode())
+			}
+		}
+		h.cleanup()
+	}
+}
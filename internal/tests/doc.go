@@ -0,0 +1,21 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package tests holds the blackbox tests, which run the stages against
+// loopback disks and check the partitions, filesystems, and files they leave
+// behind. They are built only with the blackbox tag and must be run as root
+// on a host running systemd:
+//
+//	sudo go test -tags blackbox github.com/coreos/ignition/internal/tests
+package tests
@@ -0,0 +1,190 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build blackbox
+// +build blackbox
+
+package tests
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	osexec "os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/coreos/ignition/engine"
+	"github.com/coreos/ignition/internal/exec/util"
+)
+
+// requiredTools are the tools the harness itself, and the configs it runs,
+// rely on.
+var requiredTools = []string{"losetup", "blkid", "sgdisk", "mkfs.ext4"}
+
+// runStages are run in order against each config.
+var runStages = []string{"disks", "files", "umount"}
+
+// harness is the scratch state of a single blackbox test: the loopback disks
+// and the directory standing in for the target root.
+type harness struct {
+	t     *testing.T
+	dir   string
+	root  string
+	disks []string
+}
+
+// newHarness creates the scratch directory and the loopback disks of the
+// given sizes in MiB, skipping the test if it cannot run here.
+func newHarness(t *testing.T, sizes []int) *harness {
+	if os.Geteuid() != 0 {
+		t.Skip("blackbox tests must run as root")
+	}
+	for _, tool := range requiredTools {
+		if _, err := osexec.LookPath(tool); err != nil {
+			t.Skipf("blackbox tests need %s: %v", tool, err)
+		}
+	}
+
+	dir, err := ioutil.TempDir("", "ignition-blackbox")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	h := &harness{t: t, dir: dir, root: filepath.Join(dir, "root")}
+	if err := os.Mkdir(h.root, 0755); err != nil {
+		h.cleanup()
+		t.Fatalf("failed to create root: %v", err)
+	}
+	for i, size := range sizes {
+		dev, err := createLoopDisk(filepath.Join(dir, fmt.Sprintf("disk%d.img", i)), size)
+		if err != nil {
+			h.cleanup()
+			t.Fatalf("failed to create disk %d: %v", i, err)
+		}
+		h.disks = append(h.disks, dev)
+	}
+	return h
+}
+
+// createLoopDisk creates a sparse image of size MiB at path and attaches it
+// to a loop device with partition scanning, returning the device.
+func createLoopDisk(path string, size int) (string, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+	err = f.Truncate(int64(size) << 20)
+	f.Close()
+	if err != nil {
+		return "", err
+	}
+	out, err := osexec.Command("losetup", "--find", "--show", "--partscan", path).Output()
+	if err != nil {
+		return "", fmt.Errorf("losetup: %v", err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// cleanup unmounts anything the stages left mounted, detaches the disks, and
+// removes the scratch directory.
+func (h *harness) cleanup() {
+	if mounts, err := (util.Util{StateDir: h.stateDir()}).RecordedMounts(); err == nil {
+		for i := len(mounts) - 1; i >= 0; i-- {
+			syscall.Unmount(mounts[i], 0)
+		}
+	}
+	for _, dev := range h.disks {
+		if err := osexec.Command("losetup", "--detach", dev).Run(); err != nil {
+			h.t.Logf("failed to detach %s: %v", dev, err)
+		}
+	}
+	os.RemoveAll(h.dir)
+}
+
+// expand replaces the $diskN placeholders in s with the loop devices.
+func (h *harness) expand(s string) string {
+	// Replace the highest numbers first so that $disk1 doesn't clobber
+	// $disk10.
+	for i := len(h.disks) - 1; i >= 0; i-- {
+		s = strings.Replace(s, fmt.Sprintf("$disk%d", i), h.disks[i], -1)
+	}
+	return s
+}
+
+// stateDir is where the stages keep their state, outside the root so that
+// the tests can inspect the root as the config left it.
+func (h *harness) stateDir() string {
+	return filepath.Join(h.dir, "state")
+}
+
+// run runs runStages against cfg, with the root filesystem at the harness's
+// root directory.
+func (h *harness) run(cfg string) error {
+	e, err := engine.New(
+		engine.WithRoot(h.root),
+		engine.WithStateDir(h.stateDir()),
+		engine.WithProvider(engine.StaticConfig(h.expand(cfg))),
+		engine.WithStages(runStages...),
+	)
+	if err != nil {
+		return err
+	}
+	return e.Run(context.Background())
+}
+
+// probe returns the properties blkid reports for dev, such as TYPE and
+// PART_ENTRY_NAME, reading the device rather than the udev database.
+func probe(dev string) (map[string]string, error) {
+	out, err := osexec.Command("blkid", "--probe", "--output", "export", dev).Output()
+	if err != nil {
+		return nil, fmt.Errorf("blkid %s: %v", dev, err)
+	}
+	props := map[string]string{}
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		if kv := strings.SplitN(scanner.Text(), "=", 2); len(kv) == 2 {
+			props[kv[0]] = kv[1]
+		}
+	}
+	return props, scanner.Err()
+}
+
+// readFile reads path from the filesystem on dev, mounting it read-only, or
+// from the root directory if dev is empty. The stages have already unmounted
+// everything, so this also checks that the files reached the disk.
+func (h *harness) readFile(dev, format, path string) ([]byte, os.FileInfo, error) {
+	dir := h.root
+	if dev != "" {
+		var err error
+		if dir, err = ioutil.TempDir(h.dir, "mnt"); err != nil {
+			return nil, nil, err
+		}
+		if err := syscall.Mount(dev, dir, format, syscall.MS_RDONLY, ""); err != nil {
+			return nil, nil, fmt.Errorf("failed to mount %s: %v", dev, err)
+		}
+		defer syscall.Unmount(dir, 0)
+	}
+	path = filepath.Join(dir, path)
+	info, err := os.Lstat(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	contents, err := ioutil.ReadFile(path)
+	return contents, info, err
+}
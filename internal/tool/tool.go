@@ -0,0 +1,145 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package tool locates the external programs the stages invoke, so that a
+// stripped-down initramfs missing one of them is detected before a stage
+// starts modifying the system, and so that invocations are logged uniformly.
+package tool
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/coreos/ignition/internal/log"
+)
+
+var (
+	ErrUnavailable = errors.New("required tools are not available")
+)
+
+// NewCommand constructs the commands returned by Tool.Command. Tests replace
+// it to run a fake in place of the tool.
+var NewCommand = exec.Command
+
+// Tool is an external program.
+type Tool struct {
+	Name string
+	// Paths are the locations tried, in order, before searching $PATH.
+	Paths []string
+}
+
+var (
+	Blkid         = Tool{Name: "blkid", Paths: []string{"/sbin/blkid", "/usr/sbin/blkid"}}
+	Btrfs         = Tool{Name: "btrfs", Paths: []string{"/sbin/btrfs", "/usr/sbin/btrfs"}}
+	Btrfstune     = Tool{Name: "btrfstune", Paths: []string{"/sbin/btrfstune", "/usr/sbin/btrfstune"}}
+	Chccwdev      = Tool{Name: "chccwdev", Paths: []string{"/sbin/chccwdev", "/usr/sbin/chccwdev"}}
+	CioIgnore     = Tool{Name: "cio_ignore", Paths: []string{"/sbin/cio_ignore", "/usr/sbin/cio_ignore"}}
+	Clevis        = Tool{Name: "clevis", Paths: []string{"/usr/bin/clevis", "/bin/clevis"}}
+	Cryptsetup    = Tool{Name: "cryptsetup", Paths: []string{"/sbin/cryptsetup", "/usr/sbin/cryptsetup"}}
+	Efibootmgr    = Tool{Name: "efibootmgr", Paths: []string{"/usr/sbin/efibootmgr", "/sbin/efibootmgr"}}
+	Fatlabel      = Tool{Name: "fatlabel", Paths: []string{"/sbin/fatlabel", "/usr/sbin/fatlabel"}}
+	Gpgv          = Tool{Name: "gpgv", Paths: []string{"/usr/bin/gpgv", "/bin/gpgv"}}
+	Groupadd      = Tool{Name: "groupadd"}
+	Mdadm         = Tool{Name: "mdadm", Paths: []string{"/sbin/mdadm", "/usr/sbin/mdadm"}}
+	MkfsBtrfs     = Tool{Name: "mkfs.btrfs", Paths: []string{"/sbin/mkfs.btrfs", "/usr/sbin/mkfs.btrfs"}}
+	MkfsExt4      = Tool{Name: "mkfs.ext4", Paths: []string{"/sbin/mkfs.ext4", "/usr/sbin/mkfs.ext4"}}
+	MkfsVfat      = Tool{Name: "mkfs.vfat", Paths: []string{"/sbin/mkfs.vfat", "/usr/sbin/mkfs.vfat"}}
+	MkfsXfs       = Tool{Name: "mkfs.xfs", Paths: []string{"/sbin/mkfs.xfs", "/usr/sbin/mkfs.xfs"}}
+	Mkswap        = Tool{Name: "mkswap", Paths: []string{"/sbin/mkswap", "/usr/sbin/mkswap"}}
+	Modprobe      = Tool{Name: "modprobe"}
+	Mount         = Tool{Name: "mount", Paths: []string{"/usr/bin/mount", "/bin/mount"}}
+	Ostree        = Tool{Name: "ostree", Paths: []string{"/usr/bin/ostree", "/bin/ostree"}}
+	Partx         = Tool{Name: "partx", Paths: []string{"/usr/sbin/partx", "/sbin/partx"}}
+	Resize2fs     = Tool{Name: "resize2fs", Paths: []string{"/sbin/resize2fs", "/usr/sbin/resize2fs"}}
+	Setfiles      = Tool{Name: "setfiles", Paths: []string{"/usr/sbin/setfiles", "/sbin/setfiles"}}
+	Sgdisk        = Tool{Name: "sgdisk", Paths: []string{"/sbin/sgdisk", "/usr/sbin/sgdisk"}}
+	Swaplabel     = Tool{Name: "swaplabel", Paths: []string{"/sbin/swaplabel", "/usr/sbin/swaplabel"}}
+	Systemctl     = Tool{Name: "systemctl", Paths: []string{"/usr/bin/systemctl", "/bin/systemctl"}}
+	Tpm2Pcrextend = Tool{Name: "tpm2_pcrextend", Paths: []string{"/usr/bin/tpm2_pcrextend", "/bin/tpm2_pcrextend"}}
+	Udevadm       = Tool{Name: "udevadm", Paths: []string{"/usr/bin/udevadm", "/bin/udevadm", "/sbin/udevadm"}}
+	Tune2fs       = Tool{Name: "tune2fs", Paths: []string{"/sbin/tune2fs", "/usr/sbin/tune2fs"}}
+	Useradd       = Tool{Name: "useradd"}
+	Usermod       = Tool{Name: "usermod"}
+	VBoxControl   = Tool{Name: "VBoxControl", Paths: []string{"/usr/bin/VBoxControl", "/usr/sbin/VBoxControl"}}
+	Vmur          = Tool{Name: "vmur", Paths: []string{"/sbin/vmur", "/usr/sbin/vmur"}}
+	XfsAdmin      = Tool{Name: "xfs_admin", Paths: []string{"/usr/sbin/xfs_admin", "/sbin/xfs_admin"}}
+	XfsGrowfs     = Tool{Name: "xfs_growfs", Paths: []string{"/sbin/xfs_growfs", "/usr/sbin/xfs_growfs"}}
+	XfsQuota      = Tool{Name: "xfs_quota", Paths: []string{"/usr/sbin/xfs_quota", "/sbin/xfs_quota"}}
+)
+
+// Path returns the location of t, or an error if it cannot be found.
+func (t Tool) Path() (string, error) {
+	for _, path := range t.Paths {
+		if isExecutable(path) {
+			return path, nil
+		}
+	}
+	if path, err := exec.LookPath(t.Name); err == nil {
+		return path, nil
+	}
+	return "", fmt.Errorf("%s not found", t.Name)
+}
+
+// Available reports whether t can be found.
+func (t Tool) Available() bool {
+	_, err := t.Path()
+	return err == nil
+}
+
+// Command returns the command running t with args. If t cannot be found, the
+// command runs its preferred path, so that running it fails as it otherwise
+// would.
+func (t Tool) Command(args ...string) *exec.Cmd {
+	path, err := t.Path()
+	if err != nil {
+		path = t.Name
+		if len(t.Paths) != 0 {
+			path = t.Paths[0]
+		}
+	}
+	return NewCommand(path, args...)
+}
+
+// Output runs t with args and returns its standard output. Unlike commands
+// run with Logger.LogCmd, the invocation is logged only at debug level, since
+// it merely inspects the system.
+func (t Tool) Output(logger *log.Logger, args ...string) ([]byte, error) {
+	cmd := t.Command(args...)
+	logger.Debug("executing: %s", strings.Join(cmd.Args, " "))
+	return cmd.Output()
+}
+
+// Require returns an error naming each of tools which cannot be found.
+func Require(tools ...Tool) error {
+	missing := []string{}
+	seen := map[string]bool{}
+	for _, t := range tools {
+		if !seen[t.Name] && !t.Available() {
+			missing = append(missing, t.Name)
+		}
+		seen[t.Name] = true
+	}
+	if len(missing) != 0 {
+		return fmt.Errorf("%v: %s", ErrUnavailable, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+func isExecutable(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular() && info.Mode()&0111 != 0
+}
@@ -0,0 +1,104 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tool
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPath(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ignition-tool")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	exe := filepath.Join(dir, "fake")
+	if err := ioutil.WriteFile(exe, []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatalf("failed to write fake tool: %v", err)
+	}
+	plain := filepath.Join(dir, "plain")
+	if err := ioutil.WriteFile(plain, []byte{}, 0644); err != nil {
+		t.Fatalf("failed to write plain file: %v", err)
+	}
+
+	type in struct {
+		tool Tool
+	}
+	type out struct {
+		path string
+		ok   bool
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{tool: Tool{Name: "fake", Paths: []string{filepath.Join(dir, "missing"), exe}}},
+			out: out{path: exe, ok: true},
+		},
+		{
+			in:  in{tool: Tool{Name: "ignition-no-such-tool", Paths: []string{plain}}},
+			out: out{ok: false},
+		},
+	}
+
+	for i, test := range tests {
+		path, err := test.in.tool.Path()
+		if (err == nil) != test.out.ok || path != test.out.path {
+			t.Errorf("#%d: bad path: want %q (%v), got %q (%v)", i, test.out.path, test.out.ok, path, err)
+		}
+		if cmd := test.in.tool.Command("arg"); !test.out.ok && cmd.Path != plain {
+			t.Errorf("#%d: bad fallback command path: want %q, got %q", i, plain, cmd.Path)
+		}
+	}
+}
+
+func TestCommand(t *testing.T) {
+	defer func(orig func(string, ...string) *exec.Cmd) { NewCommand = orig }(NewCommand)
+	var got []string
+	NewCommand = func(name string, args ...string) *exec.Cmd {
+		got = append([]string{name}, args...)
+		return exec.Command("true")
+	}
+
+	tool := Tool{Name: "ignition-no-such-tool", Paths: []string{"/ignition/fake"}}
+	if err := tool.Command("-a", "b").Run(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []string{"/ignition/fake", "-a", "b"}; !reflect.DeepEqual(want, got) {
+		t.Errorf("bad command: want %v, got %v", want, got)
+	}
+}
+
+func TestRequire(t *testing.T) {
+	missing := Tool{Name: "ignition-no-such-tool"}
+	err := Require(missing, missing, Tool{Name: "ignition-other-tool"})
+	if err == nil {
+		t.Fatalf("missing tools were not reported")
+	}
+	if !strings.HasSuffix(err.Error(), ": ignition-no-such-tool, ignition-other-tool") {
+		t.Errorf("bad error: %v", err)
+	}
+	if err := Require(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
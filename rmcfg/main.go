@@ -0,0 +1,85 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// ignition-rmcfg deletes the config from the platform once the machine has
+// been provisioned, so that secrets it holds don't linger in the machine's
+// metadata, where any process able to query it could read them.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/coreos/ignition/internal/log"
+	"github.com/coreos/ignition/internal/oem"
+	"github.com/coreos/ignition/internal/providers"
+	"github.com/coreos/ignition/internal/version"
+)
+
+// defaultCompletionFile is the marker Ignition writes to the provisioned
+// root, as it is seen once the machine has booted from it.
+const defaultCompletionFile = "/etc/.ignition-complete"
+
+func main() {
+	flags := struct {
+		completionFile string
+		platform       string
+		version        bool
+	}{}
+
+	flag.StringVar(&flags.completionFile, "completion-file", defaultCompletionFile, "marker written by Ignition after a successful run, without which nothing is deleted (empty to delete regardless)")
+	flag.StringVar(&flags.platform, "platform", "", fmt.Sprintf("platform holding the config. %v", oem.Names()))
+	flag.BoolVar(&flags.version, "version", false, "print the version and exit")
+	flag.Parse()
+
+	if flags.version {
+		fmt.Printf("%s\n", version.String)
+		return
+	}
+	if flags.platform == "" {
+		fmt.Fprint(os.Stderr, "'--platform' must be provided\n")
+		os.Exit(2)
+	}
+	platform, ok := oem.Get(flags.platform)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown platform %q\n", flags.platform)
+		os.Exit(2)
+	}
+
+	logger := log.New()
+	defer logger.Close()
+
+	if flags.completionFile != "" {
+		if _, err := os.Stat(flags.completionFile); err != nil {
+			logger.Crit("not deleting the config, since provisioning hasn't completed: %v", err)
+			os.Exit(1)
+		}
+	}
+
+	deleteConfig := platform.DeleteFunc()
+	if deleteConfig == nil {
+		logger.Info("the %s platform doesn't let the machine delete its config; nothing to delete", flags.platform)
+		return
+	}
+	if err := deleteConfig(&logger); err != nil {
+		if err == providers.ErrNoProvider {
+			logger.Crit("not running on %s", flags.platform)
+		} else {
+			logger.Crit("failed to delete the config: %v", err)
+		}
+		os.Exit(1)
+	}
+	logger.Info("deleted the config from %s", flags.platform)
+}
@@ -0,0 +1,118 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// ignition-validate checks configs offline, printing the report of problems
+// found in each. It exits non-zero if any config is invalid, so it can gate CI
+// pipelines before an image ever boots.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/coreos/ignition/config"
+	"github.com/coreos/ignition/config/validate/report"
+	"github.com/coreos/ignition/internal/version"
+)
+
+// result is what is printed for each config with -json.
+type result struct {
+	File    string         `json:"file"`
+	Valid   bool           `json:"valid"`
+	Error   string         `json:"error,omitempty"`
+	Entries []report.Entry `json:"entries"`
+}
+
+func main() {
+	flags := struct {
+		json    bool
+		strict  bool
+		version bool
+	}{}
+
+	flag.BoolVar(&flags.json, "json", false, "print the reports as JSON, one object per config")
+	flag.BoolVar(&flags.strict, "strict", false, "treat unrecognized config keys as errors rather than warnings")
+	flag.BoolVar(&flags.version, "version", false, "print the version and exit")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s [options] <config>... (- for stdin)\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flags.version {
+		fmt.Printf("%s\n", version.String)
+		return
+	}
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	exitCode := 0
+	for _, file := range flag.Args() {
+		res := validate(file, flags.strict)
+		if !res.Valid {
+			exitCode = 1
+		}
+		if flags.json {
+			b, err := json.Marshal(res)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "failed to marshal report: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Printf("%s\n", b)
+			continue
+		}
+		for _, entry := range res.Entries {
+			fmt.Printf("%s: %v\n", file, entry)
+		}
+		if res.Error != "" {
+			fmt.Printf("%s: %s\n", file, res.Error)
+		}
+	}
+	os.Exit(exitCode)
+}
+
+// validate parses the config in file, or stdin if file is "-".
+func validate(file string, strict bool) result {
+	res := result{File: file, Entries: []report.Entry{}}
+
+	var b []byte
+	var err error
+	if file == "-" {
+		b, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		b, err = ioutil.ReadFile(file)
+	}
+	if err != nil {
+		res.Error = fmt.Sprintf("couldn't read config: %v", err)
+		return res
+	}
+
+	_, r, err := config.Parse(b)
+	err = config.CheckReport(&r, err, strict)
+	r.Sort()
+	if r.Entries != nil {
+		res.Entries = r.Entries
+	}
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	res.Valid = true
+	return res
+}
@@ -0,0 +1,81 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/coreos/ignition/config"
+)
+
+func TestValidate(t *testing.T) {
+	type in struct {
+		config string
+		strict bool
+	}
+	type out struct {
+		valid   bool
+		err     string
+		entries int
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{config: `{"ignition": {"version": "2.1.0-experimental"}}`},
+			out: out{valid: true},
+		},
+		{
+			in:  in{config: `{"ignition": {"version": "2.1.0-experimental"}, "storgae": {}}`},
+			out: out{valid: true, entries: 1},
+		},
+		{
+			in:  in{config: `{"ignition": {"version": "2.1.0-experimental"}, "storgae": {}}`, strict: true},
+			out: out{err: config.ErrInvalid.Error(), entries: 1},
+		},
+		{
+			in:  in{config: ``, strict: true},
+			out: out{err: config.ErrEmpty.Error()},
+		},
+	}
+
+	dir, err := ioutil.TempDir("", "ignition-validate")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	for i, test := range tests {
+		file := filepath.Join(dir, "config.ign")
+		if err := ioutil.WriteFile(file, []byte(test.in.config), 0644); err != nil {
+			t.Fatalf("#%d: failed to write config: %v", i, err)
+		}
+		res := validate(file, test.in.strict)
+		if res.Valid != test.out.valid {
+			t.Errorf("#%d: bad validity: want %v, got %v", i, test.out.valid, res.Valid)
+		}
+		if res.Error != test.out.err {
+			t.Errorf("#%d: bad error: want %q, got %q", i, test.out.err, res.Error)
+		}
+		if len(res.Entries) != test.out.entries {
+			t.Errorf("#%d: bad entries: want %d, got %v", i, test.out.entries, res.Entries)
+		}
+	}
+}